@@ -87,6 +87,7 @@ let package = Package(
         .library(name: "P2PPlumtree", targets: ["P2PPlumtree"]),
         .library(name: "P2PRendezvous", targets: ["P2PRendezvous"]),
         .library(name: "P2PHTTP", targets: ["P2PHTTP"]),
+        .library(name: "P2PFetch", targets: ["P2PFetch"]),
         .library(name: "P2PTransportWebTransport", targets: ["P2PTransportWebTransport"]),
         .library(name: "P2PRuntime", targets: ["P2PRuntime"]),
 
@@ -112,6 +113,9 @@ let package = Package(
         // Embedded BoringSSL). Required by the Embedded node target to specialise
         // the Embedded-clean Noise / QUIC facade at a concrete crypto seam.
         .package(url: "https://github.com/1amageek/swift-p2p-crypto.git", from: "0.1.1"),
+        // bitcoin-core libsecp256k1, for local secp256k1 identities. The
+        // binding is pre-1.0 and breaks its API in minor releases.
+        .package(url: "https://github.com/21-DOT-DEV/swift-secp256k1.git", .upToNextMinor(from: "0.21.1")),
     ],
     targets: [
         .target(
@@ -144,6 +148,10 @@ let package = Package(
                 "LibP2PCore",
                 .product(name: "P2PCoreFoundation", package: "swift-p2p-core"),
                 .product(name: "Crypto", package: "swift-crypto"),
+                // RSA verification for remote go-libp2p identities.
+                .product(name: "_CryptoExtras", package: "swift-crypto"),
+                // secp256k1 private keys and signing.
+                .product(name: "P256K", package: "swift-secp256k1"),
                 .product(name: "Logging", package: "swift-log"),
                 .product(name: "NIOCore", package: "swift-nio"),
                 .product(name: "NIOFoundationCompat", package: "swift-nio"),
@@ -643,6 +651,7 @@ let package = Package(
                 "P2PProtocols",
                 "P2PCore",
                 "P2PMux",
+                "P2PDiscovery",
                 .product(name: "Logging", package: "swift-log"),
             ],
             path: "Sources/Protocols/Identify",
@@ -730,7 +739,7 @@ let package = Package(
         ),
         .target(
             name: "P2PKademlia",
-            dependencies: ["P2PProtocols", "P2PCore", "P2PMux"],
+            dependencies: ["P2PProtocols", "P2PCore", "P2PMux", "P2PDiscovery"],
             path: "Sources/Protocols/Kademlia",
             exclude: ["CONTEXT.md", "README.md"]
         ),
@@ -772,6 +781,17 @@ let package = Package(
             dependencies: ["P2PHTTP", "P2PCore", "P2PMux", "P2PProtocols"],
             path: "Tests/Protocols/HTTPTests"
         ),
+        .target(
+            name: "P2PFetch",
+            dependencies: ["P2PProtocols", "P2PCore", "P2PMux"],
+            path: "Sources/Protocols/Fetch",
+            exclude: ["CONTEXT.md"]
+        ),
+        .testTarget(
+            name: "P2PFetchTests",
+            dependencies: ["P2PFetch", "P2PCore", "P2PMux", "P2PProtocols"],
+            path: "Tests/Protocols/FetchTests"
+        ),
         // MARK: - WebTransport
         .target(
             name: "P2PTransportWebTransport",
@@ -859,6 +879,7 @@ let package = Package(
                 "P2PMuxYamux",
                 "P2PPing",
                 "P2PIdentify",
+                "P2PKademlia",
                 "P2PPnet",
             ],
             path: "Tests/Integration/P2PTests"
@@ -880,7 +901,9 @@ let package = Package(
                 "P2PTransportTCP",
                 "P2PTransportWebSocket",
                 "P2PSecurityNoise",
+                "P2PSecurityTLS",
                 "P2PMuxYamux",
+                "P2PMuxMplex",
                 "P2PGossipSub",
                 "P2PKademlia",
                 "P2PCircuitRelay",
@@ -0,0 +1,53 @@
+/// AddrInfo - A peer and the addresses it can be dialed at.
+///
+/// Equivalent of go-libp2p's `peer.AddrInfo`. Used wherever a caller hands
+/// over a peer to connect to, such as `Node.openStream(to:protocol:retry:)`
+/// and GossipSub's direct peers.
+
+/// A peer and the addresses it can be dialed at.
+public struct AddrInfo: Sendable, Hashable {
+
+    /// The peer.
+    public var peer: PeerID
+
+    /// Addresses to dial, in order. When empty, the caller decides how to
+    /// reach the peer (address book, routing).
+    public var addresses: [Multiaddr]
+
+    public init(peer: PeerID, addresses: [Multiaddr] = []) {
+        self.peer = peer
+        self.addresses = addresses
+    }
+
+    /// `addresses` as dial targets, each ending in `/p2p/<peer>`.
+    ///
+    /// An address without a peer ID gets `/p2p/<peer>` appended. An address
+    /// whose peer ID names a different peer is left out, as is one that
+    /// cannot take another component. In a relay address only the part after
+    /// the last `/p2p-circuit` names the dialed peer.
+    public var dialAddresses: [Multiaddr] {
+        addresses.compactMap { address in
+            switch AddrInfo.dialedPeer(in: address) {
+            case nil:
+                return try? address.appending(.p2p(peer))
+            case let named?:
+                return named == peer ? address : nil
+            }
+        }
+    }
+
+    /// The `/p2p` peer ID after the last `/p2p-circuit`, if any.
+    private static func dialedPeer(in address: Multiaddr) -> PeerID? {
+        let circuit = address.protocols.lastIndex { proto in
+            if case .p2pCircuit = proto { return true }
+            return false
+        }
+        let tail = address.protocols[(circuit.map { $0 + 1 } ?? 0)...]
+        for proto in tail {
+            if case .p2p(let id) = proto {
+                return id
+            }
+        }
+        return nil
+    }
+}
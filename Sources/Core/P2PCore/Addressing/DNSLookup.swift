@@ -0,0 +1,36 @@
+/// DNSLookup - Record lookups behind multiaddr DNS resolution.
+///
+/// Separates the network lookups (A/AAAA and TXT records) from multiaddr
+/// expansion, so `SystemDNSResolver` can be driven by canned answers.
+
+/// Address family of an A or AAAA lookup.
+public enum DNSAddressFamily: Sendable {
+    case ipv4
+    case ipv6
+}
+
+/// The records returned by one lookup and how long they may be cached.
+public struct DNSAnswer: Sendable, Equatable {
+    /// IP address strings for A/AAAA lookups; for TXT lookups, one string per
+    /// record with its character-strings joined.
+    public let values: [String]
+
+    /// How long the answer may be cached (the smallest record TTL).
+    /// `.zero` disables caching.
+    public let ttl: Duration
+
+    public init(values: [String], ttl: Duration) {
+        self.values = values
+        self.ttl = ttl
+    }
+}
+
+/// Performs DNS record lookups.
+public protocol DNSLookup: Sendable {
+    /// Looks up the A (`.ipv4`) or AAAA (`.ipv6`) records of `hostname`.
+    func addresses(of hostname: String, family: DNSAddressFamily) async throws -> DNSAnswer
+
+    /// Looks up the TXT records of `name`. A name without TXT records yields
+    /// an empty answer rather than an error.
+    func textRecords(of name: String) async throws -> DNSAnswer
+}
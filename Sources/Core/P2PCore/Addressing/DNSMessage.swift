@@ -0,0 +1,152 @@
+/// DNSMessage - Minimal DNS wire format (RFC 1035) for TXT queries.
+///
+/// Only what `SystemDNSLookup` needs: encoding a single-question query with
+/// an EDNS0 OPT record, and reading the answer records of the response.
+/// Names in answers are skipped (compression pointers included), never
+/// decoded.
+
+enum DNSMessage {
+
+    static let typeTXT: UInt16 = 16
+    static let typeOPT: UInt16 = 41
+    static let classIN: UInt16 = 1
+
+    /// RCODE for a name that does not exist (NXDOMAIN).
+    static let rcodeNameError: UInt8 = 3
+
+    /// UDP payload size advertised via EDNS0, so answers with several
+    /// dnsaddr records are not truncated at 512 bytes.
+    static let udpPayloadSize: UInt16 = 4096
+
+    enum ParseError: Error, Equatable {
+        case invalidName(String)
+        case truncated
+        case idMismatch
+        case notAResponse
+        case badLabel
+    }
+
+    /// One resource record from the answer section.
+    struct Record: Equatable {
+        let type: UInt16
+        let ttl: UInt32
+        let data: [UInt8]
+    }
+
+    struct Response: Equatable {
+        let rcode: UInt8
+        let answers: [Record]
+    }
+
+    /// Encodes a recursive query for `name` with one EDNS0 OPT record.
+    static func query(id: UInt16, name: String, type: UInt16) throws -> [UInt8] {
+        var out: [UInt8] = []
+        appendUInt16(&out, id)
+        appendUInt16(&out, 0x0100)  // RD
+        appendUInt16(&out, 1)       // QDCOUNT
+        appendUInt16(&out, 0)       // ANCOUNT
+        appendUInt16(&out, 0)       // NSCOUNT
+        appendUInt16(&out, 1)       // ARCOUNT (OPT)
+
+        var nameLength = 1
+        for label in name.split(separator: ".", omittingEmptySubsequences: false) {
+            let bytes = Array(label.utf8)
+            if bytes.isEmpty {
+                // Only a trailing dot may produce an empty label
+                guard label.endIndex == name.endIndex, nameLength > 1 else {
+                    throw ParseError.invalidName(name)
+                }
+                continue
+            }
+            guard bytes.count <= 63 else { throw ParseError.invalidName(name) }
+            nameLength += 1 + bytes.count
+            out.append(UInt8(bytes.count))
+            out.append(contentsOf: bytes)
+        }
+        guard nameLength > 1, nameLength <= 255 else { throw ParseError.invalidName(name) }
+        out.append(0)
+        appendUInt16(&out, type)
+        appendUInt16(&out, classIN)
+
+        // OPT pseudo-record: root name, requestor's UDP payload size as class
+        out.append(0)
+        appendUInt16(&out, typeOPT)
+        appendUInt16(&out, udpPayloadSize)
+        out.append(contentsOf: [0, 0, 0, 0])  // extended RCODE, version, flags
+        appendUInt16(&out, 0)                 // RDLENGTH
+        return out
+    }
+
+    /// Parses the response to the query with ID `id`.
+    static func parseResponse(_ bytes: [UInt8], id: UInt16) throws -> Response {
+        guard bytes.count >= 12 else { throw ParseError.truncated }
+        guard readUInt16(bytes, at: 0) == id else { throw ParseError.idMismatch }
+        let flags = readUInt16(bytes, at: 2)
+        guard flags & 0x8000 != 0 else { throw ParseError.notAResponse }
+        let questionCount = Int(readUInt16(bytes, at: 4))
+        let answerCount = Int(readUInt16(bytes, at: 6))
+
+        var offset = 12
+        for _ in 0..<questionCount {
+            offset = try skipName(bytes, at: offset) + 4
+        }
+
+        var answers: [Record] = []
+        for _ in 0..<answerCount {
+            offset = try skipName(bytes, at: offset)
+            guard offset + 10 <= bytes.count else { throw ParseError.truncated }
+            let type = readUInt16(bytes, at: offset)
+            let ttl = UInt32(readUInt16(bytes, at: offset + 4)) << 16 | UInt32(readUInt16(bytes, at: offset + 6))
+            let length = Int(readUInt16(bytes, at: offset + 8))
+            offset += 10
+            guard offset + length <= bytes.count else { throw ParseError.truncated }
+            answers.append(Record(type: type, ttl: ttl, data: Array(bytes[offset..<offset + length])))
+            offset += length
+        }
+        return Response(rcode: UInt8(flags & 0x000F), answers: answers)
+    }
+
+    /// Joins the character-strings of a TXT record's RDATA.
+    static func textValue(_ data: [UInt8]) throws -> String {
+        var text: [UInt8] = []
+        var offset = 0
+        while offset < data.count {
+            let length = Int(data[offset])
+            guard offset + 1 + length <= data.count else { throw ParseError.truncated }
+            text.append(contentsOf: data[(offset + 1)..<(offset + 1 + length)])
+            offset += 1 + length
+        }
+        return String(decoding: text, as: UTF8.self)
+    }
+
+    // MARK: - Helpers
+
+    /// Returns the offset just past the name starting at `offset`.
+    private static func skipName(_ bytes: [UInt8], at offset: Int) throws -> Int {
+        var offset = offset
+        while true {
+            guard offset < bytes.count else { throw ParseError.truncated }
+            let length = bytes[offset]
+            switch length & 0xC0 {
+            case 0xC0:
+                // Compression pointer ends the name
+                guard offset + 2 <= bytes.count else { throw ParseError.truncated }
+                return offset + 2
+            case 0:
+                if length == 0 { return offset + 1 }
+                offset += 1 + Int(length)
+            default:
+                throw ParseError.badLabel
+            }
+        }
+    }
+
+    private static func appendUInt16(_ out: inout [UInt8], _ value: UInt16) {
+        out.append(UInt8(value >> 8))
+        out.append(UInt8(value & 0xFF))
+    }
+
+    private static func readUInt16(_ bytes: [UInt8], at offset: Int) -> UInt16 {
+        UInt16(bytes[offset]) << 8 | UInt16(bytes[offset + 1])
+    }
+}
@@ -19,6 +19,8 @@ public enum DNSResolverError: Error, Sendable {
     case resolutionFailed(hostname: String)
     /// No addresses of the requested family were found.
     case noAddressesFound(hostname: String, family: String)
-    /// The dnsaddr TXT record lookup failed.
+    /// The dnsaddr TXT record lookup failed, or none of its records resolved.
     case dnsaddrLookupFailed(domain: String)
+    /// No nameserver returned a usable answer to a TXT query.
+    case txtLookupFailed(name: String)
 }
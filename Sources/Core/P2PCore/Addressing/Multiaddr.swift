@@ -46,6 +46,8 @@ public struct Multiaddr: Sendable, Hashable, CustomStringConvertible {
                 guard MultiaddrProtocol.isValidIPv6(address) else {
                     throw MultiaddrError.invalidAddress
                 }
+            case .registered(let codec, let value):
+                try codec.validateValue(value)
             default:
                 continue
             }
@@ -205,6 +207,27 @@ public struct Multiaddr: Sendable, Hashable, CustomStringConvertible {
         protocols.filter { $0.code == code }
     }
 
+    /// The string value of the first protocol matching the given code, like
+    /// go-multiaddr's `ValueForProtocol`.
+    ///
+    /// Returns an empty string for protocols without a value (e.g.
+    /// `/p2p-circuit`), and `nil` if no component has the code. For a relayed
+    /// address `/…/p2p/<relay>/p2p-circuit/p2p/<target>` this is the relay.
+    public func value(forProtocol code: UInt64) -> String? {
+        guard let proto = first(code: code) else { return nil }
+        return proto.valueString ?? ""
+    }
+
+    /// The string value of the last protocol matching the given code.
+    ///
+    /// This is the innermost encapsulated component, e.g. the target peer of
+    /// a relayed address. Same empty-string and `nil` rules as
+    /// `value(forProtocol:)`.
+    public func lastValue(forProtocol code: UInt64) -> String? {
+        guard let proto = protocols.last(where: { $0.code == code }) else { return nil }
+        return proto.valueString ?? ""
+    }
+
     /// Whether this multiaddr contains a p2p (PeerID) component.
     public var hasPeerID: Bool {
         peerID != nil
@@ -392,6 +415,10 @@ public struct Multiaddr: Sendable, Hashable, CustomStringConvertible {
     }
 
     /// Creates a new Multiaddr by removing protocols after and including the given code.
+    ///
+    /// When the code appears more than once, the last occurrence is removed,
+    /// so `/…/p2p/<relay>/p2p-circuit/p2p/<target>` keeps the relay's `/p2p`.
+    /// Returns the address unchanged if the code does not appear.
     public func decapsulate(code: UInt64) -> Multiaddr {
         if let index = protocols.lastIndex(where: { $0.code == code }) {
             return Multiaddr(uncheckedProtocols: Array(protocols.prefix(upTo: index)))
@@ -399,6 +426,21 @@ public struct Multiaddr: Sendable, Hashable, CustomStringConvertible {
         return self
     }
 
+    /// Creates a new Multiaddr by removing the last occurrence of `other` and
+    /// everything after it, like go-multiaddr's `Decapsulate`.
+    ///
+    /// Returns the address unchanged if `other` is empty or does not appear
+    /// as a contiguous run of components.
+    public func decapsulate(_ other: Multiaddr) -> Multiaddr {
+        let suffix = other.protocols
+        guard !suffix.isEmpty, suffix.count <= protocols.count else { return self }
+        for start in stride(from: protocols.count - suffix.count, through: 0, by: -1)
+        where protocols[start..<(start + suffix.count)].elementsEqual(suffix) {
+            return Multiaddr(uncheckedProtocols: Array(protocols.prefix(upTo: start)))
+        }
+        return self
+    }
+
 }
 public enum MultiaddrError: Error, Equatable {
     case invalidFormat
@@ -409,6 +451,8 @@ public enum MultiaddrError: Error, Equatable {
     case fieldTooLarge
     case inputTooLarge(size: Int, max: Int)
     case tooManyComponents(count: Int, max: Int)
+    case protocolConflict(name: String, code: UInt64)
+    case invalidProtocolCodec(name: String)
 }
 
 // MARK: - Codable
@@ -36,6 +36,9 @@ public enum MultiaddrProtocol: Sendable, Hashable {
     case wifiDirect(Data)
     case lora(Data)
     case nfc(Data)
+    /// A protocol from `MultiaddrProtocolRegistry`, with its binary value
+    /// (without any length prefix).
+    case registered(MultiaddrProtocolCodec, Data)
 
     /// The protocol code as defined in the multiaddr spec.
     public var code: UInt64 {
@@ -64,6 +67,7 @@ public enum MultiaddrProtocol: Sendable, Hashable {
         case .wifiDirect: return 0x01B1 // Custom code for WiFi Direct transport
         case .lora: return 0x01B2       // Custom code for LoRa transport
         case .nfc: return 0x01B3        // Custom code for NFC transport
+        case .registered(let codec, _): return codec.code
         }
     }
 
@@ -94,6 +98,7 @@ public enum MultiaddrProtocol: Sendable, Hashable {
         case .wifiDirect: return "wifi-direct"
         case .lora: return "lora"
         case .nfc: return "nfc"
+        case .registered(let codec, _): return codec.name
         }
     }
 
@@ -122,6 +127,13 @@ public enum MultiaddrProtocol: Sendable, Hashable {
         case .dnsaddr(let name): return name
         case .unix(let path): return path
         case .memory(let id): return id
+        case .registered(let codec, let value):
+            guard codec.requiresValue else { return nil }
+            do {
+                return try codec.decodeValue(value)
+            } catch {
+                preconditionFailure("Invalid \(codec.name) value stored in MultiaddrProtocol.registered")
+            }
         }
     }
 
@@ -161,6 +173,13 @@ public enum MultiaddrProtocol: Sendable, Hashable {
         case .memory(let id):
             let bytes = Data(id.utf8)
             return Varint.encode(UInt64(bytes.count)) + bytes
+        case .registered(let codec, let value):
+            switch codec.size {
+            case .none, .fixed:
+                return value
+            case .lengthPrefixed:
+                return Varint.encode(UInt64(value.count)) + value
+            }
         }
     }
 
@@ -366,19 +385,52 @@ public enum MultiaddrProtocol: Sendable, Hashable {
             return (proto, lengthBytes + len)
 
         default:
-            throw MultiaddrError.unknownProtocol(code)
+            guard let codec = MultiaddrProtocolRegistry.shared.codec(forCode: code) else {
+                throw MultiaddrError.unknownProtocol(code)
+            }
+            return try decodeRegistered(codec, from: data, at: offset)
         }
     }
 
+    /// Decodes the value of a registered protocol according to its size.
+    private static func decodeRegistered(
+        _ codec: MultiaddrProtocolCodec,
+        from data: Data,
+        at offset: Int
+    ) throws -> (MultiaddrProtocol, Int) {
+        let valueStart: Int
+        let valueEnd: Int
+        switch codec.size {
+        case .none:
+            return (.registered(codec, Data()), 0)
+        case .fixed(let count):
+            valueStart = offset
+            valueEnd = offset + count
+        case .lengthPrefixed:
+            let (length, lengthBytes) = try Varint.decode(from: data, at: offset)
+            guard length <= 1024 else { throw MultiaddrError.fieldTooLarge }
+            valueStart = offset + lengthBytes
+            valueEnd = valueStart + Int(length)
+        }
+        guard valueEnd <= data.count else { throw MultiaddrError.invalidAddress }
+        let value = Data(data[fieldRange(in: data, offset: valueStart, end: valueEnd)])
+        try codec.validateValue(value)
+        return (.registered(codec, value), valueEnd - offset)
+    }
+
     private static func fieldRange(in data: Data, offset: Int, end: Int) -> Range<Data.Index> {
         let startIndex = data.index(data.startIndex, offsetBy: offset)
         let endIndex = data.index(data.startIndex, offsetBy: end)
         return startIndex..<endIndex
     }
 
-    /// Whether a protocol name requires a value component (core table).
+    /// Whether a protocol name requires a value component (core table, then
+    /// registered protocols).
     static func requiresValue(name: String) -> Bool? {
-        MultiaddrCodec.requiresValue(name: name)
+        if let requiresValue = MultiaddrCodec.requiresValue(name: name) {
+            return requiresValue
+        }
+        return MultiaddrProtocolRegistry.shared.codec(forName: name)?.requiresValue
     }
 
     /// Creates a protocol from its name and value string.
@@ -489,7 +541,12 @@ public enum MultiaddrProtocol: Sendable, Hashable {
             default: throw MultiaddrError.unknownProtocolName(name)
             }
         default:
-            throw MultiaddrError.unknownProtocolName(name)
+            guard let codec = MultiaddrProtocolRegistry.shared.codec(forName: name) else {
+                throw MultiaddrError.unknownProtocolName(name)
+            }
+            guard codec.requiresValue else { return .registered(codec, Data()) }
+            guard let v = value else { throw MultiaddrError.missingValue }
+            return .registered(codec, try codec.encodeValue(v))
         }
     }
 
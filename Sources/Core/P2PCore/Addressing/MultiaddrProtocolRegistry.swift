@@ -0,0 +1,215 @@
+/// MultiaddrProtocolRegistry - Runtime extension point for multiaddr protocols.
+///
+/// The built-in protocol table is fixed at compile time. Protocols outside it
+/// (e.g. `/webrtc`, `/sni`, `/http`) are registered here with a codec and then
+/// round-trip through `Multiaddr`'s string and binary forms as
+/// `MultiaddrProtocol.registered` components.
+
+import Foundation
+import LibP2PCore
+import Synchronization
+
+/// Converts a registered protocol's value between its string and binary forms.
+public protocol MultiaddrTranscoder: Sendable {
+
+    /// Encodes the textual value of a component to its binary value.
+    func bytes(from string: String) throws -> Data
+
+    /// Decodes the binary value of a component to its textual value.
+    ///
+    /// This also validates values decoded from the wire; throwing rejects the
+    /// address.
+    func string(from bytes: Data) throws -> String
+}
+
+/// Transcoder for values that are UTF-8 text on the wire (e.g. `/sni`).
+public struct MultiaddrUTF8Transcoder: MultiaddrTranscoder {
+
+    public init() {}
+
+    public func bytes(from string: String) throws -> Data {
+        guard !string.isEmpty, !string.contains("/") else {
+            throw MultiaddrError.invalidAddress
+        }
+        return Data(string.utf8)
+    }
+
+    public func string(from bytes: Data) throws -> String {
+        guard let string = String(data: bytes, encoding: .utf8), !string.isEmpty, !string.contains("/") else {
+            throw MultiaddrError.invalidAddress
+        }
+        return string
+    }
+}
+
+/// Describes a multiaddr protocol that is not part of the built-in table.
+public struct MultiaddrProtocolCodec: Sendable, Hashable {
+
+    /// How the binary value of a component is sized.
+    public enum Size: Sendable, Hashable {
+        /// The protocol takes no value (e.g. `/webrtc`).
+        case none
+        /// The value is always exactly this many bytes.
+        case fixed(Int)
+        /// The value is prefixed with its varint-encoded length.
+        case lengthPrefixed
+    }
+
+    /// The protocol name used in the string form.
+    public let name: String
+
+    /// The protocol code used in the binary form.
+    public let code: UInt64
+
+    /// How the value is sized on the wire.
+    public let size: Size
+
+    /// Value transcoder. Required unless `size` is `.none`.
+    public let transcoder: (any MultiaddrTranscoder)?
+
+    public init(
+        name: String,
+        code: UInt64,
+        size: Size,
+        transcoder: (any MultiaddrTranscoder)? = nil
+    ) {
+        self.name = name
+        self.code = code
+        self.size = size
+        self.transcoder = transcoder
+    }
+
+    /// Codecs are identified by their wire metadata; transcoders are not compared.
+    public static func == (lhs: MultiaddrProtocolCodec, rhs: MultiaddrProtocolCodec) -> Bool {
+        lhs.name == rhs.name && lhs.code == rhs.code && lhs.size == rhs.size
+    }
+
+    public func hash(into hasher: inout Hasher) {
+        hasher.combine(name)
+        hasher.combine(code)
+        hasher.combine(size)
+    }
+
+    /// Whether the string form carries a value after the protocol name.
+    var requiresValue: Bool {
+        size != .none
+    }
+
+    /// Encodes a textual value, checking it against `size`.
+    func encodeValue(_ value: String) throws -> Data {
+        guard let transcoder else { throw MultiaddrError.invalidAddress }
+        let bytes: Data
+        do {
+            bytes = try transcoder.bytes(from: value)
+        } catch {
+            throw MultiaddrError.invalidAddress
+        }
+        try validateValue(bytes)
+        return bytes
+    }
+
+    /// Checks a binary value against `size` and the transcoder.
+    func validateValue(_ bytes: Data) throws {
+        switch size {
+        case .none:
+            guard bytes.isEmpty else { throw MultiaddrError.invalidAddress }
+            return
+        case .fixed(let count):
+            guard bytes.count == count else { throw MultiaddrError.invalidAddress }
+        case .lengthPrefixed:
+            guard bytes.count <= 1024 else { throw MultiaddrError.fieldTooLarge }
+        }
+        _ = try decodeValue(bytes)
+    }
+
+    /// Decodes a binary value to its textual form.
+    func decodeValue(_ bytes: Data) throws -> String {
+        guard let transcoder else { throw MultiaddrError.invalidAddress }
+        do {
+            return try transcoder.string(from: bytes)
+        } catch {
+            throw MultiaddrError.invalidAddress
+        }
+    }
+}
+
+/// Process-wide table of multiaddr protocols registered at runtime.
+///
+/// `Multiaddr` consults `shared` for any name or code missing from the
+/// built-in table, so a protocol must be registered before addresses using it
+/// are parsed or decoded.
+///
+/// ```swift
+/// try MultiaddrProtocolRegistry.shared.register(
+///     MultiaddrProtocolCodec(name: "webrtc", code: 0x0118, size: .none)
+/// )
+/// let addr = try Multiaddr("/ip4/1.2.3.4/udp/9090/p2p-circuit/webrtc")
+/// ```
+public final class MultiaddrProtocolRegistry: Sendable {
+
+    /// Registry used by `Multiaddr` parsing and decoding.
+    public static let shared = MultiaddrProtocolRegistry()
+
+    private struct Table {
+        var byCode: [UInt64: MultiaddrProtocolCodec] = [:]
+        var byName: [String: MultiaddrProtocolCodec] = [:]
+    }
+
+    private let table = Mutex(Table())
+
+    /// Creates an empty registry.
+    public init() {}
+
+    /// Registers `codec`.
+    ///
+    /// Registering a codec equal to one already present is a no-op, so
+    /// independent modules may register the same protocol.
+    ///
+    /// - Throws: `MultiaddrError.protocolConflict` if the name or code is
+    ///   built in or registered with different metadata,
+    ///   `MultiaddrError.invalidProtocolCodec` if the codec is malformed.
+    public func register(_ codec: MultiaddrProtocolCodec) throws {
+        guard !codec.name.isEmpty, !codec.name.contains("/") else {
+            throw MultiaddrError.invalidProtocolCodec(name: codec.name)
+        }
+        switch codec.size {
+        case .none:
+            break
+        case .fixed(let count):
+            guard count > 0, codec.transcoder != nil else {
+                throw MultiaddrError.invalidProtocolCodec(name: codec.name)
+            }
+        case .lengthPrefixed:
+            guard codec.transcoder != nil else {
+                throw MultiaddrError.invalidProtocolCodec(name: codec.name)
+            }
+        }
+        guard MultiaddrCodec.requiresValue(name: codec.name) == nil,
+              !MultiaddrCodec.isKnown(code: codec.code) else {
+            throw MultiaddrError.protocolConflict(name: codec.name, code: codec.code)
+        }
+
+        try table.withLock { table in
+            let existingByCode = table.byCode[codec.code]
+            let existingByName = table.byName[codec.name]
+            if existingByCode == nil && existingByName == nil {
+                table.byCode[codec.code] = codec
+                table.byName[codec.name] = codec
+                return
+            }
+            guard existingByCode == codec, existingByName == codec else {
+                throw MultiaddrError.protocolConflict(name: codec.name, code: codec.code)
+            }
+        }
+    }
+
+    /// The codec registered for `code`, if any.
+    public func codec(forCode code: UInt64) -> MultiaddrProtocolCodec? {
+        table.withLock { $0.byCode[code] }
+    }
+
+    /// The codec registered for `name`, if any.
+    public func codec(forName name: String) -> MultiaddrProtocolCodec? {
+        table.withLock { $0.byName[name] }
+    }
+}
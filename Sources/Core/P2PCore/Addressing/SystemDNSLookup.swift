@@ -0,0 +1,242 @@
+/// SystemDNSLookup - DNS lookups through the operating system.
+///
+/// A/AAAA records come from getaddrinfo, which honours /etc/hosts but does not
+/// report TTLs, so `addressTTL` stands in for them. getaddrinfo cannot look up
+/// TXT records; those are queried over UDP from the nameservers listed in
+/// /etc/resolv.conf, and carry their real TTL.
+
+import Foundation
+
+public final class SystemDNSLookup: DNSLookup, Sendable {
+
+    /// Cache lifetime reported for getaddrinfo results.
+    public let addressTTL: Duration
+
+    /// How long to wait for each nameserver to answer a TXT query.
+    public let queryTimeout: Duration
+
+    /// Nameserver IP addresses TXT queries are sent to, in order.
+    public let nameservers: [String]
+
+    private static let resolvConfPath = "/etc/resolv.conf"
+    private static let dnsPort: UInt16 = 53
+
+    public init(
+        addressTTL: Duration = .seconds(60),
+        queryTimeout: Duration = .seconds(5),
+        nameservers: [String]? = nil
+    ) {
+        self.addressTTL = addressTTL
+        self.queryTimeout = queryTimeout
+        self.nameservers = nameservers ?? Self.systemNameservers()
+    }
+
+    public func addresses(of hostname: String, family: DNSAddressFamily) async throws -> DNSAnswer {
+        // Use getaddrinfo via Foundation.
+        // DispatchQueue usage is acceptable here as it wraps a blocking C system call.
+        let ttl = addressTTL
+        return try await withCheckedThrowingContinuation { continuation in
+            DispatchQueue.global().async {
+                var hints = addrinfo()
+                hints.ai_family = family == .ipv4 ? AF_INET : AF_INET6
+                #if os(Linux)
+                hints.ai_socktype = Int32(SOCK_STREAM.rawValue)
+                #else
+                hints.ai_socktype = SOCK_STREAM
+                #endif
+
+                var result: UnsafeMutablePointer<addrinfo>?
+                let status = getaddrinfo(hostname, nil, &hints, &result)
+
+                guard status == 0, let addrList = result else {
+                    if let result { freeaddrinfo(result) }
+                    let familyName = family == .ipv4 ? "IPv4" : "IPv6"
+                    continuation.resume(throwing: DNSResolverError.noAddressesFound(
+                        hostname: hostname,
+                        family: familyName
+                    ))
+                    return
+                }
+
+                defer { freeaddrinfo(addrList) }
+
+                var addresses: [String] = []
+                var current: UnsafeMutablePointer<addrinfo>? = addrList
+
+                while let info = current {
+                    var buffer = [CChar](repeating: 0, count: Int(INET6_ADDRSTRLEN))
+
+                    if family == .ipv4, let sockaddr = info.pointee.ai_addr {
+                        sockaddr.withMemoryRebound(to: sockaddr_in.self, capacity: 1) { addr in
+                            var sinAddr = addr.pointee.sin_addr
+                            inet_ntop(AF_INET, &sinAddr, &buffer, socklen_t(INET_ADDRSTRLEN))
+                        }
+                    } else if family == .ipv6, let sockaddr = info.pointee.ai_addr {
+                        sockaddr.withMemoryRebound(to: sockaddr_in6.self, capacity: 1) { addr in
+                            var sin6Addr = addr.pointee.sin6_addr
+                            inet_ntop(AF_INET6, &sin6Addr, &buffer, socklen_t(INET6_ADDRSTRLEN))
+                        }
+                    }
+
+                    let ipBytes = buffer.prefix { $0 != 0 }.map { UInt8(bitPattern: $0) }
+                    let ip = String(decoding: ipBytes, as: UTF8.self)
+                    if !ip.isEmpty && !addresses.contains(ip) {
+                        addresses.append(ip)
+                    }
+
+                    current = info.pointee.ai_next
+                }
+
+                if addresses.isEmpty {
+                    let familyName = family == .ipv4 ? "IPv4" : "IPv6"
+                    continuation.resume(throwing: DNSResolverError.noAddressesFound(
+                        hostname: hostname,
+                        family: familyName
+                    ))
+                } else {
+                    continuation.resume(returning: DNSAnswer(values: addresses, ttl: ttl))
+                }
+            }
+        }
+    }
+
+    public func textRecords(of name: String) async throws -> DNSAnswer {
+        let id = UInt16.random(in: .min ... .max)
+        let query: [UInt8]
+        do {
+            query = try DNSMessage.query(id: id, name: name, type: DNSMessage.typeTXT)
+        } catch {
+            throw DNSResolverError.txtLookupFailed(name: name)
+        }
+
+        let nameservers = self.nameservers
+        let timeout = queryTimeout
+        return try await withCheckedThrowingContinuation { continuation in
+            DispatchQueue.global().async {
+                // Ask each nameserver in turn until one gives a definitive answer
+                for nameserver in nameservers {
+                    let response: DNSMessage.Response
+                    do {
+                        let bytes = try Self.exchange(query, with: nameserver, timeout: timeout)
+                        response = try DNSMessage.parseResponse(bytes, id: id)
+                    } catch {
+                        continue
+                    }
+
+                    if response.rcode == DNSMessage.rcodeNameError {
+                        continuation.resume(returning: DNSAnswer(values: [], ttl: .zero))
+                        return
+                    }
+                    guard response.rcode == 0 else { continue }
+
+                    let records = response.answers.filter { $0.type == DNSMessage.typeTXT }
+                    var values: [String] = []
+                    do {
+                        for record in records {
+                            values.append(try DNSMessage.textValue(record.data))
+                        }
+                    } catch {
+                        continue
+                    }
+                    let ttl = records.map(\.ttl).min() ?? 0
+                    continuation.resume(returning: DNSAnswer(values: values, ttl: .seconds(Int64(ttl))))
+                    return
+                }
+                continuation.resume(throwing: DNSResolverError.txtLookupFailed(name: name))
+            }
+        }
+    }
+
+    // MARK: - Private
+
+    private struct SocketError: Error {
+        let operation: String
+    }
+
+    /// Sends one UDP query to `nameserver` and returns its reply.
+    private static func exchange(_ query: [UInt8], with nameserver: String, timeout: Duration) throws -> [UInt8] {
+        var storage = sockaddr_storage()
+        let length: socklen_t
+        if nameserver.contains(":") {
+            var address = sockaddr_in6()
+            address.sin6_family = sa_family_t(AF_INET6)
+            #if !os(Linux)
+            address.sin6_len = UInt8(MemoryLayout<sockaddr_in6>.size)
+            #endif
+            address.sin6_port = dnsPort.bigEndian
+            guard inet_pton(AF_INET6, nameserver, &address.sin6_addr) == 1 else {
+                throw SocketError(operation: "inet_pton")
+            }
+            withUnsafeBytes(of: &address) { source in
+                withUnsafeMutableBytes(of: &storage) { $0.copyMemory(from: source) }
+            }
+            length = socklen_t(MemoryLayout<sockaddr_in6>.size)
+        } else {
+            var address = sockaddr_in()
+            address.sin_family = sa_family_t(AF_INET)
+            #if !os(Linux)
+            address.sin_len = UInt8(MemoryLayout<sockaddr_in>.size)
+            #endif
+            address.sin_port = dnsPort.bigEndian
+            guard inet_pton(AF_INET, nameserver, &address.sin_addr) == 1 else {
+                throw SocketError(operation: "inet_pton")
+            }
+            withUnsafeBytes(of: &address) { source in
+                withUnsafeMutableBytes(of: &storage) { $0.copyMemory(from: source) }
+            }
+            length = socklen_t(MemoryLayout<sockaddr_in>.size)
+        }
+
+        #if os(Linux)
+        let fd = socket(Int32(storage.ss_family), Int32(SOCK_DGRAM.rawValue), 0)
+        #else
+        let fd = socket(Int32(storage.ss_family), SOCK_DGRAM, 0)
+        #endif
+        guard fd >= 0 else { throw SocketError(operation: "socket") }
+        defer { close(fd) }
+
+        let (seconds, attoseconds) = timeout.components
+        var receiveTimeout = timeval(
+            tv_sec: .init(seconds),
+            tv_usec: .init(attoseconds / 1_000_000_000_000)
+        )
+        guard setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &receiveTimeout, socklen_t(MemoryLayout<timeval>.size)) == 0 else {
+            throw SocketError(operation: "setsockopt")
+        }
+
+        // A connected UDP socket only receives datagrams from the nameserver
+        let connected = withUnsafePointer(to: &storage) {
+            $0.withMemoryRebound(to: sockaddr.self, capacity: 1) { connect(fd, $0, length) }
+        }
+        guard connected == 0 else { throw SocketError(operation: "connect") }
+        guard send(fd, query, query.count, 0) == query.count else {
+            throw SocketError(operation: "send")
+        }
+
+        var buffer = [UInt8](repeating: 0, count: 65_535)
+        let received = recv(fd, &buffer, buffer.count, 0)
+        guard received > 0 else { throw SocketError(operation: "recv") }
+        return Array(buffer.prefix(received))
+    }
+
+    /// Nameservers from /etc/resolv.conf, or the local resolver if it cannot
+    /// be read. Scoped (`%zone`) IPv6 nameservers are skipped.
+    private static func systemNameservers() -> [String] {
+        let contents: String
+        do {
+            contents = try String(contentsOfFile: resolvConfPath, encoding: .utf8)
+        } catch {
+            return ["127.0.0.1"]
+        }
+
+        var nameservers: [String] = []
+        for line in contents.split(whereSeparator: \.isNewline) {
+            let fields = line.split(whereSeparator: \.isWhitespace)
+            guard fields.count >= 2, fields[0] == "nameserver", !fields[1].contains("%") else {
+                continue
+            }
+            nameservers.append(String(fields[1]))
+        }
+        return nameservers.isEmpty ? ["127.0.0.1"] : nameservers
+    }
+}
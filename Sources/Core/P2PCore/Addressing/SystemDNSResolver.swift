@@ -1,20 +1,54 @@
-/// SystemDNSResolver - DNS resolution using system resolver (getaddrinfo).
+/// SystemDNSResolver - DNS resolution of multiaddrs with a TTL cache.
 ///
 /// Resolves /dns4, /dns6, /dns, and /dnsaddr multiaddr components
-/// into their IP-based equivalents using the operating system's DNS resolver.
+/// into their IP-based equivalents. Lookups go through a `DNSLookup` (by
+/// default the operating system's resolver) and answers are cached until
+/// their TTL expires.
 
 import Foundation
+import Synchronization
 
 public final class SystemDNSResolver: DNSResolver, Sendable {
 
     /// Maximum number of resolved addresses to return per DNS component.
     public let maxResults: Int
 
-    public init(maxResults: Int = 10) {
+    /// Maximum nesting of /dnsaddr records followed from one address.
+    public let maxDNSAddrDepth: Int
+
+    private let lookup: any DNSLookup
+    private let cache = Mutex<[CacheKey: CacheEntry]>([:])
+
+    /// TXT record value prefix of a dnsaddr entry.
+    private static let dnsaddrRecordPrefix = "dnsaddr="
+
+    public init(
+        maxResults: Int = 10,
+        maxDNSAddrDepth: Int = 4,
+        lookup: any DNSLookup = SystemDNSLookup()
+    ) {
         self.maxResults = maxResults
+        self.maxDNSAddrDepth = maxDNSAddrDepth
+        self.lookup = lookup
     }
 
     public func resolve(_ address: Multiaddr) async throws -> [Multiaddr] {
+        try await resolve(address, depth: 0)
+    }
+
+    // MARK: - Private
+
+    private enum CacheKey: Hashable {
+        case addresses(String, DNSAddressFamily)
+        case text(String)
+    }
+
+    private struct CacheEntry: Sendable {
+        let values: [String]
+        let expires: ContinuousClock.Instant
+    }
+
+    private func resolve(_ address: Multiaddr, depth: Int) async throws -> [Multiaddr] {
         // Non-DNS addresses pass through unchanged
         guard address.hasDNSComponent else {
             return [address]
@@ -24,38 +58,26 @@ public final class SystemDNSResolver: DNSResolver, Sendable {
         for (index, proto) in address.protocols.enumerated() {
             switch proto {
             case .dns4(let hostname):
-                let ips = try await resolveHostname(hostname, family: .ipv4)
-                var resolvedAddresses: [Multiaddr] = []
-                for ip in ips.prefix(maxResults) {
-                    var newProtos = address.protocols
-                    newProtos[index] = .ip4(ip)
-                    resolvedAddresses.append(Multiaddr(uncheckedProtocols: newProtos))
-                }
-                return resolvedAddresses
+                let ips = try await addresses(of: hostname, family: .ipv4)
+                return ips.prefix(maxResults).map { address.replacing(at: index, with: .ip4($0)) }
 
             case .dns6(let hostname):
-                let ips = try await resolveHostname(hostname, family: .ipv6)
-                var resolvedAddresses: [Multiaddr] = []
-                for ip in ips.prefix(maxResults) {
-                    var newProtos = address.protocols
-                    newProtos[index] = .ip6(ip)
-                    resolvedAddresses.append(Multiaddr(uncheckedProtocols: newProtos))
-                }
-                return resolvedAddresses
+                let ips = try await addresses(of: hostname, family: .ipv6)
+                return ips.prefix(maxResults).map { address.replacing(at: index, with: .ip6($0)) }
 
             case .dns(let hostname):
                 // Resolve both IPv4 and IPv6, collecting all results
                 var allIPs: [(ip: String, isIPv6: Bool)] = []
 
                 do {
-                    let v4 = try await resolveHostname(hostname, family: .ipv4)
+                    let v4 = try await addresses(of: hostname, family: .ipv4)
                     allIPs.append(contentsOf: v4.map { (ip: $0, isIPv6: false) })
                 } catch {
                     // IPv4 resolution failed; continue to try IPv6
                 }
 
                 do {
-                    let v6 = try await resolveHostname(hostname, family: .ipv6)
+                    let v6 = try await addresses(of: hostname, family: .ipv6)
                     allIPs.append(contentsOf: v6.map { (ip: $0, isIPv6: true) })
                 } catch {
                     // IPv6 resolution failed; check if we have any results
@@ -65,18 +87,12 @@ public final class SystemDNSResolver: DNSResolver, Sendable {
                     throw DNSResolverError.resolutionFailed(hostname: hostname)
                 }
 
-                var resolvedAddresses: [Multiaddr] = []
-                for (ip, isV6) in allIPs.prefix(maxResults) {
-                    var newProtos = address.protocols
-                    newProtos[index] = isV6 ? .ip6(ip) : .ip4(ip)
-                    resolvedAddresses.append(Multiaddr(uncheckedProtocols: newProtos))
+                return allIPs.prefix(maxResults).map { ip, isV6 in
+                    address.replacing(at: index, with: isV6 ? .ip6(ip) : .ip4(ip))
                 }
-                return resolvedAddresses
 
             case .dnsaddr(let domain):
-                // dnsaddr requires TXT record lookup for _dnsaddr.<domain>
-                let resolved = try await resolveDNSAddr(domain)
-                return resolved
+                return try await resolveDNSAddr(domain, in: address, at: index, depth: depth)
 
             default:
                 continue
@@ -87,86 +103,105 @@ public final class SystemDNSResolver: DNSResolver, Sendable {
         return [address]
     }
 
-    // MARK: - Private
+    /// Expands `/dnsaddr/<domain>` from the `dnsaddr=<multiaddr>` TXT records
+    /// of `_dnsaddr.<domain>`.
+    ///
+    /// Records that do not end with the components following the dnsaddr
+    /// component (typically `/p2p/<id>`) are skipped. DNS components in the
+    /// remaining records are resolved recursively; a record that fails to
+    /// resolve is dropped without failing the others.
+    private func resolveDNSAddr(
+        _ domain: String,
+        in address: Multiaddr,
+        at index: Int,
+        depth: Int
+    ) async throws -> [Multiaddr] {
+        guard depth < maxDNSAddrDepth else {
+            throw DNSResolverError.dnsaddrLookupFailed(domain: domain)
+        }
 
-    private enum AddressFamily: Sendable {
-        case ipv4
-        case ipv6
-    }
+        let records: [String]
+        do {
+            records = try await textRecords(of: "_dnsaddr." + domain)
+        } catch {
+            throw DNSResolverError.dnsaddrLookupFailed(domain: domain)
+        }
 
-    private func resolveHostname(_ hostname: String, family: AddressFamily) async throws -> [String] {
-        // Use getaddrinfo via Foundation.
-        // DispatchQueue usage is acceptable here as it wraps a blocking C system call.
-        try await withCheckedThrowingContinuation { continuation in
-            DispatchQueue.global().async {
-                var hints = addrinfo()
-                hints.ai_family = family == .ipv4 ? AF_INET : AF_INET6
-                #if os(Linux)
-                hints.ai_socktype = Int32(SOCK_STREAM.rawValue)
-                #else
-                hints.ai_socktype = SOCK_STREAM
-                #endif
-
-                var result: UnsafeMutablePointer<addrinfo>?
-                let status = getaddrinfo(hostname, nil, &hints, &result)
-
-                guard status == 0, let addrList = result else {
-                    if let result { freeaddrinfo(result) }
-                    let familyName = family == .ipv4 ? "IPv4" : "IPv6"
-                    continuation.resume(throwing: DNSResolverError.noAddressesFound(
-                        hostname: hostname,
-                        family: familyName
-                    ))
-                    return
-                }
+        let prefix = Array(address.protocols[..<index])
+        let suffix = Array(address.protocols[(index + 1)...])
+        var resolved: [Multiaddr] = []
 
-                defer { freeaddrinfo(addrList) }
-
-                var addresses: [String] = []
-                var current: UnsafeMutablePointer<addrinfo>? = addrList
-
-                while let info = current {
-                    var buffer = [CChar](repeating: 0, count: Int(INET6_ADDRSTRLEN))
-
-                    if family == .ipv4, let sockaddr = info.pointee.ai_addr {
-                        sockaddr.withMemoryRebound(to: sockaddr_in.self, capacity: 1) { addr in
-                            var sinAddr = addr.pointee.sin_addr
-                            inet_ntop(AF_INET, &sinAddr, &buffer, socklen_t(INET_ADDRSTRLEN))
-                        }
-                    } else if family == .ipv6, let sockaddr = info.pointee.ai_addr {
-                        sockaddr.withMemoryRebound(to: sockaddr_in6.self, capacity: 1) { addr in
-                            var sin6Addr = addr.pointee.sin6_addr
-                            inet_ntop(AF_INET6, &sin6Addr, &buffer, socklen_t(INET6_ADDRSTRLEN))
-                        }
-                    }
-
-                    let ipBytes = buffer.prefix { $0 != 0 }.map { UInt8(bitPattern: $0) }
-                    let ip = String(decoding: ipBytes, as: UTF8.self)
-                    if !ip.isEmpty && !addresses.contains(ip) {
-                        addresses.append(ip)
-                    }
-
-                    current = info.pointee.ai_next
-                }
+        for record in records where record.hasPrefix(Self.dnsaddrRecordPrefix) {
+            let entry: Multiaddr
+            do {
+                entry = try Multiaddr(String(record.dropFirst(Self.dnsaddrRecordPrefix.count)))
+            } catch {
+                continue
+            }
+            guard entry.protocols.suffix(suffix.count).elementsEqual(suffix) else {
+                continue
+            }
 
-                if addresses.isEmpty {
-                    let familyName = family == .ipv4 ? "IPv4" : "IPv6"
-                    continuation.resume(throwing: DNSResolverError.noAddressesFound(
-                        hostname: hostname,
-                        family: familyName
-                    ))
-                } else {
-                    continuation.resume(returning: addresses)
+            do {
+                let expanded = Multiaddr(uncheckedProtocols: prefix + entry.protocols)
+                for addr in try await resolve(expanded, depth: depth + 1) where !resolved.contains(addr) {
+                    resolved.append(addr)
                 }
+            } catch {
+                // This record is unusable; the others may still resolve
+                continue
+            }
+            if resolved.count >= maxResults {
+                break
+            }
+        }
+
+        guard !resolved.isEmpty else {
+            throw DNSResolverError.dnsaddrLookupFailed(domain: domain)
+        }
+        return Array(resolved.prefix(maxResults))
+    }
+
+    private func addresses(of hostname: String, family: DNSAddressFamily) async throws -> [String] {
+        try await cachedLookup(.addresses(hostname.lowercased(), family)) {
+            try await lookup.addresses(of: hostname, family: family)
+        }
+    }
+
+    private func textRecords(of name: String) async throws -> [String] {
+        try await cachedLookup(.text(name.lowercased())) {
+            try await lookup.textRecords(of: name)
+        }
+    }
+
+    /// Returns the cached values for `key`, or performs the lookup and caches
+    /// its answer for the answer's TTL. Failures are not cached.
+    private func cachedLookup(
+        _ key: CacheKey,
+        _ perform: () async throws -> DNSAnswer
+    ) async throws -> [String] {
+        let now = ContinuousClock.now
+        if let entry = cache.withLock({ $0[key] }), entry.expires > now {
+            return entry.values
+        }
+
+        let answer = try await perform()
+        if answer.ttl > .zero {
+            let entry = CacheEntry(values: answer.values, expires: .now + answer.ttl)
+            cache.withLock { entries in
+                entries = entries.filter { $0.value.expires > now }
+                entries[key] = entry
             }
         }
+        return answer.values
     }
+}
 
-    private func resolveDNSAddr(_ domain: String) async throws -> [Multiaddr] {
-        // dnsaddr TXT record: _dnsaddr.<domain> contains dnsaddr=<multiaddr>
-        // Full dnsaddr support requires DNS TXT record lookup which is not
-        // available through getaddrinfo. A future implementation could use
-        // dns_sd or a DNS library.
-        throw DNSResolverError.dnsaddrLookupFailed(domain: domain)
+private extension Multiaddr {
+    /// A copy with the component at `index` replaced.
+    func replacing(at index: Int, with proto: MultiaddrProtocol) -> Multiaddr {
+        var protocols = self.protocols
+        protocols[index] = proto
+        return Multiaddr(uncheckedProtocols: protocols)
     }
 }
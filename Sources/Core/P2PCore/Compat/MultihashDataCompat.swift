@@ -3,8 +3,8 @@
 /// The Embedded-clean core (``LibP2PCore``) owns the multihash *framing*
 /// (`init(code:digest:[UInt8])`, `init(bytes:[UInt8])`, `bytes: [UInt8]`,
 /// `identity(_:[UInt8])`). This adapter restores the historical `Data`-based
-/// inits and the SHA-256 factory, which is the only part that needs Crypto and
-/// therefore stays adapter-side (the Crypto seam).
+/// inits and the digest factories, the only parts that need Crypto and
+/// therefore stay adapter-side (the Crypto seam).
 
 import Foundation
 import Crypto
@@ -29,6 +29,32 @@ extension Multihash {
         return Multihash(code: .sha2_256, digest: digest)
     }
 
+    /// Creates a SHA-512 multihash of the given data (Crypto seam).
+    public static func sha512(_ data: Data) -> Multihash {
+        let digest = [UInt8](SHA512.hash(data: data))
+        return Multihash(code: .sha2_512, digest: digest)
+    }
+
+    /// Hashes data with the given function at its full digest length, as
+    /// go-multihash's `Sum(data, code, -1)` does.
+    ///
+    /// - Throws: `MultihashError.unsupportedHashFunction` for functions other
+    ///   than identity, SHA-1, SHA2-256 and SHA2-512.
+    public static func sum(_ data: Data, code: HashCode) throws(MultihashError) -> Multihash {
+        switch code {
+        case .identity:
+            return identity(data)
+        case .sha1:
+            return Multihash(code: .sha1, digest: [UInt8](Insecure.SHA1.hash(data: data)))
+        case .sha2_256:
+            return sha256(data)
+        case .sha2_512:
+            return sha512(data)
+        default:
+            throw MultihashError.unsupportedHashFunction(code)
+        }
+    }
+
     /// Creates an identity multihash (no hashing, just wraps the data).
     public static func identity(_ data: Data) -> Multihash {
         Multihash(code: .identity, digest: [UInt8](data))
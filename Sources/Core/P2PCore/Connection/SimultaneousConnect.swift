@@ -0,0 +1,24 @@
+/// Role assignment for dials that take part in a simultaneous connect.
+///
+/// When both peers dial each other at the same moment (DCUtR hole punching
+/// over TCP), a TCP simultaneous open yields a single connection on which both
+/// sides are dialers. multistream-select and the security handshake still need
+/// exactly one initiator, so the coordinating protocol assigns the roles and
+/// binds them for the duration of the dial — the counterpart of go-libp2p's
+/// `network.WithSimultaneousConnect(ctx, isClient, reason)`:
+///
+/// ```swift
+/// try await SimultaneousConnect.$role.withValue(.responder) {
+///     try await dialer(address)
+/// }
+/// ```
+///
+/// Connection providers read `role` when upgrading an outbound connection and
+/// use it in place of `.initiator`. The swarm records it so duplicate
+/// connections are resolved by handshake role rather than dial direction.
+public enum SimultaneousConnect {
+
+    /// Security role for outbound upgrades in the current task, or `nil` for
+    /// an ordinary dial (which upgrades as initiator).
+    @TaskLocal public static var role: SecurityRole?
+}
@@ -33,6 +33,36 @@ public struct KeyPair: Sendable {
         return KeyPair(privateKey: privateKey)
     }
 
+    /// Creates a new random secp256k1 key pair.
+    public static func generateSecp256k1() -> KeyPair {
+        let privateKey = PrivateKey.generateSecp256k1()
+        return KeyPair(privateKey: privateKey)
+    }
+
+    /// Creates the Ed25519 key pair derived from a 32-byte seed.
+    ///
+    /// For reproducible peer IDs in tests. See
+    /// `PrivateKey.generateEd25519(fromSeed:)`; the seed is as secret as the
+    /// key.
+    ///
+    /// - Parameter seed: 32 bytes
+    /// - Throws: `PrivateKeyError` if the seed is not 32 bytes
+    public static func generateEd25519(fromSeed seed: Data) throws -> KeyPair {
+        KeyPair(privateKey: try PrivateKey.generateEd25519(fromSeed: seed))
+    }
+
+    /// Creates the secp256k1 key pair derived from a 32-byte seed.
+    ///
+    /// For reproducible peer IDs in tests. See
+    /// `PrivateKey.generateSecp256k1(fromSeed:)`; the seed is as secret as
+    /// the key.
+    ///
+    /// - Parameter seed: 32 bytes encoding a scalar in 1..<n
+    /// - Throws: `PrivateKeyError` if the seed is not a valid scalar
+    public static func generateSecp256k1(fromSeed seed: Data) throws -> KeyPair {
+        KeyPair(privateKey: try PrivateKey.generateSecp256k1(fromSeed: seed))
+    }
+
     /// Creates a key pair from a private key.
     ///
     /// - Parameter privateKey: The private key
@@ -50,6 +80,24 @@ public struct KeyPair: Sendable {
         self.privateKey = try PrivateKey(keyType: keyType, rawBytes: rawBytes)
     }
 
+    /// Creates a key pair from a protobuf-encoded private key, such as a key
+    /// file written by go-libp2p's `crypto.MarshalPrivateKey`.
+    ///
+    /// See `PrivateKey.init(protobufEncoded:)`.
+    ///
+    /// - Parameter data: The protobuf-encoded private key
+    /// - Throws: `PrivateKeyError` if the encoding or the key is invalid
+    public init(protobufEncoded data: Data) throws {
+        self.privateKey = try PrivateKey(protobufEncoded: data)
+    }
+
+    /// The protobuf-encoded private key, in go-libp2p's
+    /// `crypto.MarshalPrivateKey` format. Write it to keep this identity
+    /// across restarts; it is as secret as the key.
+    public var protobufEncoded: Data {
+        privateKey.protobufEncoded
+    }
+
     /// Signs data with this key pair.
     ///
     /// - Parameter data: The data to sign
@@ -27,15 +27,18 @@ public enum KeyType: UInt64, Sendable, CaseIterable {
         }
     }
 
-    /// Whether this key type supports identity multihash encoding.
+    /// Whether keys of this type are small enough for identity multihash
+    /// encoding.
     ///
     /// Identity encoding embeds the full public key in the PeerID,
-    /// which is only feasible for small keys like Ed25519.
+    /// which is only feasible for small keys like Ed25519 and compressed
+    /// secp256k1 (the `16Uiu2…` PeerIDs go-libp2p derives). PeerID
+    /// derivation decides by encoded length, not by this property.
     public var supportsIdentityEncoding: Bool {
         switch self {
-        case .ed25519:
+        case .ed25519, .secp256k1:
             return true
-        case .rsa, .secp256k1, .ecdsa:
+        case .rsa, .ecdsa:
             return false
         }
     }
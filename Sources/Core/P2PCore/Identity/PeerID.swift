@@ -13,9 +13,9 @@ import LibP2PCore
 /// A unique identifier for a peer in the libp2p network.
 ///
 /// A PeerID is derived from a public key and encoded as a multihash.
-/// For Ed25519 keys (≤42 bytes when protobuf-encoded), the identity
-/// multihash is used, embedding the full public key. For larger keys,
-/// SHA-256 is used.
+/// Keys that are at most 42 bytes when protobuf-encoded (Ed25519,
+/// secp256k1) use the identity multihash, embedding the full public key.
+/// Larger keys (RSA, ECDSA) use SHA-256. This matches go-libp2p.
 public struct PeerID: Sendable, Hashable, CustomStringConvertible {
 
     /// The multihash representation of this PeerID.
@@ -29,10 +29,7 @@ public struct PeerID: Sendable, Hashable, CustomStringConvertible {
 
         // The identity-vs-SHA-256 selection rule lives in the core; the SHA-256
         // digest (Crypto seam) and the identity wrap come from the core/adapter.
-        if PeerIDFraming.usesIdentityEncoding(
-            supportsIdentityEncoding: publicKey.keyType.supportsIdentityEncoding,
-            encodedLength: encoded.count
-        ) {
+        if PeerIDFraming.usesIdentityEncoding(encodedLength: encoded.count) {
             self.multihash = PeerIDFraming.identityMultihash(forEncodedKey: [UInt8](encoded))
         } else {
             self.multihash = Multihash.sha256(encoded)
@@ -133,6 +130,10 @@ public struct PeerID: Sendable, Hashable, CustomStringConvertible {
 
     /// Validates that this PeerID matches the given public key.
     ///
+    /// The PeerID must be the one derived from the key, multihash form
+    /// included: a SHA-256 PeerID does not match a key small enough to be
+    /// identity-encoded, as in go-libp2p's `MatchesPublicKey`.
+    ///
     /// - Parameter publicKey: The public key to validate against
     /// - Returns: `true` if the PeerID matches the public key
     public func matches(publicKey: PublicKey) -> Bool {
@@ -140,12 +141,15 @@ public struct PeerID: Sendable, Hashable, CustomStringConvertible {
 
         switch multihash.code {
         case .identity:
-            guard publicKey.keyType.supportsIdentityEncoding, encoded.count <= 42 else {
+            guard PeerIDFraming.usesIdentityEncoding(encodedLength: encoded.count) else {
                 return false
             }
             return multihash.digest == encoded
 
         case .sha2_256:
+            guard !PeerIDFraming.usesIdentityEncoding(encodedLength: encoded.count) else {
+                return false
+            }
             return multihash.digest == Data(SHA256.hash(data: encoded))
 
         default:
@@ -2,6 +2,8 @@
 
 import Foundation
 import Crypto
+import P256K
+import LibP2PCore
 
 /// A private key used for signing and key derivation.
 public struct PrivateKey: Sendable {
@@ -27,6 +29,23 @@ public struct PrivateKey: Sendable {
         return PrivateKey(ed25519: key)
     }
 
+    /// Creates the Ed25519 private key whose RFC 8032 seed is `seed`.
+    ///
+    /// The same seed always gives the same key, and so the same PeerID. It
+    /// is the key go-libp2p's `crypto.GenerateEd25519Key` returns when its
+    /// reader yields `seed`. Meant for tests that pin peer IDs across runs:
+    /// anyone who knows the seed holds the identity, so a production seed
+    /// must be kept as secret as the key itself.
+    ///
+    /// - Parameter seed: 32 bytes
+    /// - Throws: `PrivateKeyError.invalidKeySize` if `seed` is not 32 bytes
+    public static func generateEd25519(fromSeed seed: Data) throws -> PrivateKey {
+        guard seed.count == 32 else {
+            throw PrivateKeyError.invalidKeySize(expected: 32, actual: seed.count)
+        }
+        return PrivateKey(ed25519: try Curve25519.Signing.PrivateKey(rawRepresentation: seed))
+    }
+
     /// Creates a private key from a Curve25519 signing key.
     ///
     /// - Parameter key: The Ed25519 private key
@@ -49,12 +68,48 @@ public struct PrivateKey: Sendable {
     ///
     /// - Parameter key: The ECDSA P-256 private key
     public init(ecdsa key: P256.Signing.PrivateKey) {
+        self.init(ecdsa: key, publicKey: PublicKey(ecdsa: key.publicKey))
+    }
+
+    private init(ecdsa key: P256.Signing.PrivateKey, publicKey: PublicKey) {
         self.keyType = .ecdsa
         self.rawBytes = Data(key.rawRepresentation)
-        self.publicKey = PublicKey(ecdsa: key.publicKey)
+        self.publicKey = publicKey
         self._cryptoKey = .ecdsa(key)
     }
 
+    // MARK: - Secp256k1
+
+    /// Creates a new random secp256k1 private key.
+    public static func generateSecp256k1() -> PrivateKey {
+        var generator = SystemRandomNumberGenerator()
+        while true {
+            // A random scalar is zero or not below the curve order with
+            // probability about 2^-128; draw again in that case.
+            let scalar = Data((0..<32).map { _ in UInt8.random(in: .min ... .max, using: &generator) })
+            if let key = try? PrivateKey(keyType: .secp256k1, rawBytes: scalar) {
+                return key
+            }
+        }
+    }
+
+    /// Creates the secp256k1 private key whose scalar is `seed`, read as a
+    /// big-endian integer.
+    ///
+    /// The same seed always gives the same key, and so the same PeerID; go
+    /// derives it with `crypto.UnmarshalSecp256k1PrivateKey(seed)`. Meant for
+    /// tests that pin peer IDs across runs: anyone who knows the seed holds
+    /// the identity, so a production seed must be kept as secret as the key
+    /// itself.
+    ///
+    /// - Parameter seed: 32 bytes encoding a scalar in 1..<n
+    /// - Throws: `PrivateKeyError.invalidKeySize` if `seed` is not 32 bytes,
+    ///   `PrivateKeyError.invalidKeyData` if it is zero or not below the
+    ///   curve order
+    public static func generateSecp256k1(fromSeed seed: Data) throws -> PrivateKey {
+        try PrivateKey(keyType: .secp256k1, rawBytes: seed)
+    }
+
     // MARK: - Raw Bytes Initialization
 
     /// Creates a private key from raw bytes.
@@ -62,7 +117,9 @@ public struct PrivateKey: Sendable {
     /// - Parameters:
     ///   - keyType: The type of the key
     ///   - rawBytes: The raw private key bytes
-    /// - Throws: `PrivateKeyError` if the bytes are invalid
+    /// - Throws: `PrivateKeyError` if the bytes are invalid;
+    ///   `PrivateKeyError.unsupportedKeyType` for RSA, which is accepted for
+    ///   remote peers' public keys only.
     public init(keyType: KeyType, rawBytes: Data) throws {
         self.keyType = keyType
         self.rawBytes = rawBytes
@@ -85,7 +142,22 @@ public struct PrivateKey: Sendable {
             self.publicKey = PublicKey(ecdsa: key.publicKey)
             self._cryptoKey = .ecdsa(key)
 
-        case .secp256k1, .rsa:
+        case .secp256k1:
+            // secp256k1 private key is a 32-byte scalar in 1..<n
+            guard rawBytes.count == 32 else {
+                throw PrivateKeyError.invalidKeySize(expected: 32, actual: rawBytes.count)
+            }
+            let key: P256K.Signing.PrivateKey
+            do {
+                key = try P256K.Signing.PrivateKey(dataRepresentation: rawBytes)
+            } catch {
+                throw PrivateKeyError.invalidKeyData
+            }
+            // Compressed SEC1 point, the form go-libp2p sends
+            self.publicKey = try PublicKey(keyType: .secp256k1, rawBytes: key.publicKey.dataRepresentation)
+            self._cryptoKey = .secp256k1
+
+        case .rsa:
             throw PrivateKeyError.unsupportedKeyType(keyType)
         }
     }
@@ -129,23 +201,112 @@ public struct PrivateKey: Sendable {
             let signature = try key.signature(for: data)
             // Use DER representation for libp2p compatibility
             return Data(signature.derRepresentation)
+
+        case .secp256k1:
+            // libsecp256k1 signs SHA-256(data) with an RFC 6979 nonce and a
+            // low S, so signatures are byte-identical to go-libp2p's.
+            do {
+                let key = try P256K.Signing.PrivateKey(dataRepresentation: rawBytes)
+                return try key.signature(for: data).derRepresentation
+            } catch {
+                throw PrivateKeyError.signingFailed
+            }
         }
     }
 
-    /// The protobuf-encoded representation of this private key.
+    // MARK: - Protobuf
+
+    /// The protobuf-encoded representation of this private key, byte for
+    /// byte what go-libp2p's `crypto.MarshalPrivateKey` writes.
+    ///
+    /// The Data field holds go's raw form, which is not always `rawBytes`:
+    /// the 32-byte seed followed by the public key for Ed25519, the 32-byte
+    /// scalar for secp256k1 and a SEC1 `ECPrivateKey` DER structure for
+    /// ECDSA.
     public var protobufEncoded: Data {
-        var result = Data()
+        let keyData: Data
+        switch _cryptoKey {
+        case .ed25519:
+            keyData = rawBytes + publicKey.rawBytes
+        case .ecdsa(let key):
+            keyData = Self.sec1ECPrivateKey(scalar: rawBytes, publicKey: key.publicKey.x963Representation)
+        case .secp256k1:
+            keyData = rawBytes
+        }
+        return Data(PublicKeyProtobuf.encode(keyType: keyType.rawValue, keyData: [UInt8](keyData)))
+    }
+
+    /// Decodes a private key from its protobuf representation, such as a key
+    /// file written with go-libp2p's `crypto.MarshalPrivateKey`.
+    ///
+    /// The key has the PeerID go-libp2p derives from the same bytes. Ed25519
+    /// keys may also use go's legacy 96-byte form, which repeats the public
+    /// key. An ECDSA key identifies with the PKIX DER form of its public key,
+    /// as in go-libp2p; that differs from the x963 form `generateECDSA()`
+    /// uses (Tests/Interop/KNOWN_ISSUES.md, issue #6), so a Swift-generated ECDSA key gets a new
+    /// PeerID once decoded.
+    ///
+    /// - Parameter data: The protobuf-encoded private key
+    /// - Throws: `PrivateKeyError.invalidProtobuf` or `.unknownKeyType` for
+    ///   bad framing, `.invalidKeySize` or `.invalidKeyData` for key data go
+    ///   would reject, `.unsupportedKeyType` for RSA
+    public init(protobufEncoded data: Data) throws {
+        let fields: PublicKeyProtobuf
+        do {
+            // Same framing as the PublicKey message
+            fields = try PublicKeyProtobuf.decode(from: [UInt8](data))
+        } catch {
+            throw PrivateKeyError.invalidProtobuf
+        }
+        guard let type = KeyType(rawValue: fields.keyType) else {
+            throw PrivateKeyError.unknownKeyType(fields.keyType)
+        }
+        let keyData = Data(fields.keyData)
+
+        switch type {
+        case .ed25519:
+            // seed + public key, or the legacy form with the public key twice
+            guard keyData.count == 64 || keyData.count == 96 else {
+                throw PrivateKeyError.invalidKeySize(expected: 64, actual: keyData.count)
+            }
+            let embedded = keyData[32..<64]
+            if keyData.count == 96, keyData[64...] != embedded {
+                throw PrivateKeyError.invalidKeyData
+            }
+            try self.init(keyType: .ed25519, rawBytes: keyData.prefix(32))
+            guard publicKey.rawBytes == embedded else {
+                throw PrivateKeyError.invalidKeyData
+            }
+
+        case .ecdsa:
+            let key: P256.Signing.PrivateKey
+            do {
+                key = try P256.Signing.PrivateKey(derRepresentation: keyData)
+            } catch {
+                throw PrivateKeyError.invalidKeyData
+            }
+            let pkix = try PublicKey(keyType: .ecdsa, rawBytes: Data(key.publicKey.derRepresentation))
+            self.init(ecdsa: key, publicKey: pkix)
+
+        case .secp256k1, .rsa:
+            try self.init(keyType: type, rawBytes: keyData)
+        }
+    }
 
-        // Field 1: KeyType
-        result.append(0x08)
-        result.append(contentsOf: Varint.encode(keyType.rawValue))
+    /// DER prefix of a SEC1 `ECPrivateKey` for P-256 (RFC 5915), as Go's
+    /// `x509.MarshalECPrivateKey` writes it: SEQUENCE, version 1 and the
+    /// 32-byte private key OCTET STRING header.
+    private static let sec1Prefix: [UInt8] = [0x30, 0x77, 0x02, 0x01, 0x01, 0x04, 0x20]
 
-        // Field 2: Data
-        result.append(0x12)
-        result.append(contentsOf: Varint.encode(UInt64(rawBytes.count)))
-        result.append(rawBytes)
+    /// `[0]` prime256v1 OID, then the `[1]` BIT STRING header of the 65-byte
+    /// uncompressed public key.
+    private static let sec1Middle: [UInt8] = [
+        0xA0, 0x0A, 0x06, 0x08, 0x2A, 0x86, 0x48, 0xCE, 0x3D, 0x03, 0x01, 0x07,
+        0xA1, 0x44, 0x03, 0x42, 0x00,
+    ]
 
-        return result
+    private static func sec1ECPrivateKey(scalar: Data, publicKey: Data) -> Data {
+        Data(sec1Prefix) + scalar + Data(sec1Middle) + publicKey
     }
 }
 
@@ -153,9 +314,12 @@ public struct PrivateKey: Sendable {
 
 /// Internal enum holding the constructed CryptoKit private key.
 /// Constructed once at PrivateKey init to avoid rebuilding on every sign().
+/// secp256k1 keys are validated at init and handed to libsecp256k1 from
+/// `rawBytes` when signing.
 private enum CryptoSigningKey: Sendable {
     case ed25519(Curve25519.Signing.PrivateKey)
     case ecdsa(P256.Signing.PrivateKey)
+    case secp256k1
 }
 
 public enum PrivateKeyError: Error, Equatable {
@@ -164,4 +328,10 @@ public enum PrivateKeyError: Error, Equatable {
     case unsupportedKeyType(KeyType)
     case unsupportedOperation
     case signingFailed
+    /// The key bytes do not encode a valid key (e.g. a zero scalar).
+    case invalidKeyData
+    /// The protobuf-encoded key is malformed.
+    case invalidProtobuf
+    /// The protobuf-encoded key names a key type libp2p does not define.
+    case unknownKeyType(UInt64)
 }
@@ -8,6 +8,7 @@
 
 import Foundation
 import Crypto
+import _CryptoExtras
 import LibP2PCore
 
 /// A public key used for peer identification and verification.
@@ -33,17 +34,16 @@ public struct PublicKey: Sendable {
     /// - Parameters:
     ///   - keyType: The type of the key
     ///   - rawBytes: The raw public key bytes
-    /// - Throws: `PublicKeyError.invalidKeySize` if the bytes are invalid;
-    ///   `PublicKeyError.unsupportedKeyType` for RSA / secp256k1.
+    /// - Throws: `PublicKeyError.invalidKeySize` or
+    ///   `PublicKeyError.invalidKeyData` if the bytes are invalid.
     ///
-    /// ## Fail-fast on unsupported key types
+    /// ## Fail-fast on malformed keys
     ///
-    /// This project intentionally supports only Ed25519 and ECDSA P-256 (see
-    /// `P2PCore/CONTEXT.md`). RSA and secp256k1 are rejected here at
-    /// construction — the earliest point — rather than constructing an
-    /// unverifiable key that only fails later at `verify()`. This mirrors
-    /// `PrivateKey`, which already rejects these types, and means a PeerID can
-    /// never be derived from an unverifiable key.
+    /// All four libp2p key types are accepted, in the encodings go-libp2p
+    /// sends: Ed25519 raw bytes, ECDSA P-256 (x963, compressed or PKIX DER),
+    /// secp256k1 SEC1 points and RSA PKIX DER. Keys are fully parsed here —
+    /// the earliest point — rather than only failing later at `verify()`, so
+    /// a PeerID can never be derived from an unverifiable key.
     public init(keyType: KeyType, rawBytes: Data) throws {
         self.keyType = keyType
         self.rawBytes = rawBytes
@@ -57,26 +57,47 @@ public struct PublicKey: Sendable {
             let key = try Curve25519.Signing.PublicKey(rawRepresentation: rawBytes)
             self._cryptoKey = .ed25519(key)
         case .ecdsa:
-            // P-256 public key: 65 bytes uncompressed or 33 bytes compressed
-            guard rawBytes.count == 33 || rawBytes.count == 65 else {
-                throw PublicKeyError.invalidKeySize(expected: 65, actual: rawBytes.count)
-            }
+            // P-256 public key: 65 bytes uncompressed, 33 bytes compressed, or
+            // the PKIX DER form go-libp2p sends. The bytes are kept as given,
+            // so the PeerID matches the one the remote derived.
             let key: P256.Signing.PublicKey
-            if rawBytes.count == 65 {
+            switch rawBytes.count {
+            case 65:
                 key = try P256.Signing.PublicKey(x963Representation: rawBytes)
-            } else {
+            case 33:
                 key = try P256.Signing.PublicKey(compressedRepresentation: rawBytes)
+            case Self.ecdsaP256DERSize:
+                key = try P256.Signing.PublicKey(derRepresentation: rawBytes)
+            default:
+                throw PublicKeyError.invalidKeySize(expected: 65, actual: rawBytes.count)
             }
             self._cryptoKey = .ecdsa(key)
-        case .secp256k1, .rsa:
-            // Unsupported by design: reject at derivation rather than producing
-            // an unverifiable key that only fails at verify() time.
-            throw PublicKeyError.unsupportedKeyType(keyType)
+        case .secp256k1:
+            self._cryptoKey = .secp256k1(try Secp256k1.PublicKey(serialized: rawBytes))
+        case .rsa:
+            // PKIX DER. swift-crypto rejects moduli below 2048 bits; the upper
+            // bound matches go-libp2p's.
+            let key: _RSA.Signing.PublicKey
+            do {
+                key = try _RSA.Signing.PublicKey(derRepresentation: rawBytes)
+            } catch {
+                throw PublicKeyError.invalidKeyData
+            }
+            guard key.keySizeInBits <= Self.rsaMaxKeyBits else {
+                throw PublicKeyError.invalidKeyData
+            }
+            self._cryptoKey = .rsa(key)
         }
 
         self._protobufEncoded = Self.buildProtobufEncoded(keyType: keyType, rawBytes: rawBytes)
     }
 
+    /// Size of a P-256 SubjectPublicKeyInfo (PKIX DER) with an uncompressed point.
+    private static let ecdsaP256DERSize = 91
+
+    /// Largest RSA modulus accepted, in bits.
+    private static let rsaMaxKeyBits = 8192
+
     // MARK: - Ed25519
 
     /// Creates an Ed25519 public key from a Curve25519 signing key.
@@ -142,6 +163,15 @@ public struct PublicKey: Sendable {
             // Signature is DER encoded
             let ecdsaSignature = try P256.Signing.ECDSASignature(derRepresentation: signature)
             return key.isValidSignature(ecdsaSignature, for: data)
+
+        case .secp256k1(let key):
+            // DER encoded, over SHA-256 of the data
+            return key.isValidSignature(signature, for: data)
+
+        case .rsa(let key):
+            // RSASSA-PKCS1-v1_5 over SHA-256 of the data, as go-libp2p signs
+            let rsaSignature = _RSA.Signing.RSASignature(rawRepresentation: signature)
+            return key.isValidSignature(rsaSignature, for: data, padding: .insecurePKCS1v1_5)
         }
     }
 
@@ -194,6 +224,8 @@ public struct PublicKey: Sendable {
 private enum CryptoKey: Sendable {
     case ed25519(Curve25519.Signing.PublicKey)
     case ecdsa(P256.Signing.PublicKey)
+    case secp256k1(Secp256k1.PublicKey)
+    case rsa(_RSA.Signing.PublicKey)
 }
 
 // MARK: - Equatable / Hashable
@@ -218,7 +250,8 @@ public enum PublicKeyError: Error, Equatable {
     case invalidProtobuf
     case unsupportedOperation
     case keyDataTooLarge(UInt64)
-    /// The key type is recognized but not supported by this implementation
-    /// (RSA / secp256k1). Rejected at construction so it fails fast.
+    /// The key type is recognized but not supported by this implementation.
     case unsupportedKeyType(KeyType)
+    /// The key bytes do not encode a valid key (e.g. a point off the curve).
+    case invalidKeyData
 }
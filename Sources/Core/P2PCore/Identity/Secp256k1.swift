@@ -0,0 +1,386 @@
+/// secp256k1 ECDSA for libp2p identities.
+/// https://github.com/libp2p/specs/blob/master/peer-ids/peer-ids.md#secp256k1
+///
+/// swift-crypto has no secp256k1, so this is a small self-contained
+/// implementation of what verifying a remote peer needs: SEC1 public keys
+/// and DER-encoded ECDSA signatures over the SHA-256 digest of the message.
+///
+/// It only ever handles public data. The limb arithmetic is plain Swift and
+/// not hardened against timing side channels, so private keys never come
+/// here: `PrivateKey` generates and signs with the libsecp256k1 binding.
+
+import Foundation
+import Crypto
+
+enum Secp256k1 {
+
+    // MARK: - Public Key
+
+    /// A secp256k1 public key (curve point).
+    struct PublicKey: Sendable {
+
+        fileprivate let point: Point
+
+        /// Parses a SEC1 encoded point: 33-byte compressed or 65-byte uncompressed.
+        ///
+        /// - Throws: `PublicKeyError.invalidKeySize` for other lengths,
+        ///   `PublicKeyError.invalidKeyData` if the point is not on the curve.
+        init(serialized bytes: Data) throws {
+            let field = Secp256k1.field
+            let bytes = [UInt8](bytes)
+            switch bytes.count {
+            case 33:
+                guard bytes[0] == 0x02 || bytes[0] == 0x03 else {
+                    throw PublicKeyError.invalidKeyData
+                }
+                let x = Limbs.fromBigEndian(bytes[1..<33])
+                guard Limbs.compare(x, field.value) < 0 else {
+                    throw PublicKeyError.invalidKeyData
+                }
+                let rhs = field.add(field.multiply(field.multiply(x, x), x), Secp256k1.curveB)
+                var y = field.power(rhs, Secp256k1.sqrtExponent)
+                guard field.multiply(y, y) == rhs else {
+                    throw PublicKeyError.invalidKeyData
+                }
+                if y[0] & 1 != UInt64(bytes[0] & 1) {
+                    y = field.subtract(Limbs.zero, y)
+                }
+                self.point = Point(x: x, y: y, z: Limbs.one)
+            case 65:
+                guard bytes[0] == 0x04 else {
+                    throw PublicKeyError.invalidKeyData
+                }
+                let x = Limbs.fromBigEndian(bytes[1..<33])
+                let y = Limbs.fromBigEndian(bytes[33..<65])
+                let rhs = field.add(field.multiply(field.multiply(x, x), x), Secp256k1.curveB)
+                guard Limbs.compare(x, field.value) < 0, Limbs.compare(y, field.value) < 0,
+                      field.multiply(y, y) == rhs else {
+                    throw PublicKeyError.invalidKeyData
+                }
+                self.point = Point(x: x, y: y, z: Limbs.one)
+            default:
+                throw PublicKeyError.invalidKeySize(expected: 33, actual: bytes.count)
+            }
+        }
+
+        /// Verifies a DER-encoded ECDSA signature over `SHA256(data)`.
+        ///
+        /// High-S signatures are accepted, as go-libp2p does.
+        func isValidSignature(_ signature: Data, for data: Data) -> Bool {
+            guard let parsed = Signature.parseDER([UInt8](signature)) else {
+                return false
+            }
+            let order = Secp256k1.order
+            let e = order.reduce(Limbs.fromBigEndian(SHA256.hash(data: data)))
+            let w = order.inverse(parsed.s)
+            let u1 = order.multiply(e, w)
+            let u2 = order.multiply(parsed.r, w)
+            let sum = Point.add(Point.multiply(Secp256k1.generator, by: u1), Point.multiply(point, by: u2))
+            guard !sum.isInfinity else { return false }
+            return order.reduce(sum.affine().x) == parsed.r
+        }
+    }
+
+    // MARK: - Curve Parameters
+
+    /// p = 2^256 - 2^32 - 977
+    fileprivate static let field = Modulus(
+        value: [0xFFFF_FFFE_FFFF_FC2F, 0xFFFF_FFFF_FFFF_FFFF, 0xFFFF_FFFF_FFFF_FFFF, 0xFFFF_FFFF_FFFF_FFFF],
+        complement: [0x1_0000_03D1]
+    )
+
+    /// Group order n.
+    fileprivate static let order = Modulus(
+        value: [0xBFD2_5E8C_D036_4141, 0xBAAE_DCE6_AF48_A03B, 0xFFFF_FFFF_FFFF_FFFE, 0xFFFF_FFFF_FFFF_FFFF],
+        complement: [0x402D_A173_2FC9_BEBF, 0x4551_2319_50B7_5FC4, 0x1]
+    )
+
+    /// (p + 1) / 4; p ≡ 3 (mod 4), so `a^((p+1)/4)` is a square root of `a`.
+    fileprivate static let sqrtExponent: [UInt64] = [
+        0xFFFF_FFFF_BFFF_FF0C, 0xFFFF_FFFF_FFFF_FFFF, 0xFFFF_FFFF_FFFF_FFFF, 0x3FFF_FFFF_FFFF_FFFF,
+    ]
+
+    /// b in y^2 = x^3 + b.
+    fileprivate static let curveB: [UInt64] = [7, 0, 0, 0]
+
+    fileprivate static let generator = Point(
+        x: [0x59F2_815B_16F8_1798, 0x029B_FCDB_2DCE_28D9, 0x55A0_6295_CE87_0B07, 0x79BE_667E_F9DC_BBAC],
+        y: [0x9C47_D08F_FB10_D4B8, 0xFD17_B448_A685_5419, 0x5DA4_FBFC_0E11_08A8, 0x483A_DA77_26A3_C465],
+        z: Limbs.one
+    )
+}
+
+// MARK: - Multi-precision Arithmetic
+
+/// Little-endian 64-bit limb arithmetic on `[UInt64]`.
+private enum Limbs {
+
+    static let zero: [UInt64] = [0, 0, 0, 0]
+    static let one: [UInt64] = [1, 0, 0, 0]
+
+    /// Reads a 256-bit big-endian integer.
+    static func fromBigEndian<Bytes: Sequence<UInt8>>(_ bytes: Bytes) -> [UInt64] {
+        let bytes = Array(bytes)
+        precondition(bytes.count == 32)
+        var limbs = zero
+        for (index, byte) in bytes.reversed().enumerated() {
+            limbs[index / 8] |= UInt64(byte) << (8 * (index % 8))
+        }
+        return limbs
+    }
+
+    static func isZero(_ a: [UInt64]) -> Bool {
+        a.allSatisfy { $0 == 0 }
+    }
+
+    static func bit(_ a: [UInt64], _ index: Int) -> UInt64 {
+        (a[index / 64] >> UInt64(index % 64)) & 1
+    }
+
+    /// Compares two integers of any limb count: -1, 0 or 1.
+    static func compare(_ a: [UInt64], _ b: [UInt64]) -> Int {
+        for index in stride(from: max(a.count, b.count) - 1, through: 0, by: -1) {
+            let x = index < a.count ? a[index] : 0
+            let y = index < b.count ? b[index] : 0
+            if x != y { return x > y ? 1 : -1 }
+        }
+        return 0
+    }
+
+    static func add(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        let count = max(a.count, b.count)
+        var result = [UInt64](repeating: 0, count: count + 1)
+        var carry: UInt64 = 0
+        for index in 0..<count {
+            let x = index < a.count ? a[index] : 0
+            let y = index < b.count ? b[index] : 0
+            let (partial, overflow1) = x.addingReportingOverflow(y)
+            let (sum, overflow2) = partial.addingReportingOverflow(carry)
+            result[index] = sum
+            carry = (overflow1 ? 1 : 0) + (overflow2 ? 1 : 0)
+        }
+        result[count] = carry
+        return result
+    }
+
+    /// `a - b`; requires `a >= b`.
+    static func subtract(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        var result = [UInt64](repeating: 0, count: a.count)
+        var borrow: UInt64 = 0
+        for index in 0..<a.count {
+            let y = index < b.count ? b[index] : 0
+            let (partial, overflow1) = a[index].subtractingReportingOverflow(y)
+            let (difference, overflow2) = partial.subtractingReportingOverflow(borrow)
+            result[index] = difference
+            borrow = (overflow1 || overflow2) ? 1 : 0
+        }
+        return result
+    }
+
+    static func multiply(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        var result = [UInt64](repeating: 0, count: a.count + b.count)
+        for i in 0..<a.count {
+            var carry: UInt64 = 0
+            for j in 0..<b.count {
+                let (high, low) = a[i].multipliedFullWidth(by: b[j])
+                let (partial, overflow1) = result[i + j].addingReportingOverflow(low)
+                let (sum, overflow2) = partial.addingReportingOverflow(carry)
+                result[i + j] = sum
+                // a*b + result + carry < 2^128, so this cannot overflow
+                carry = high &+ (overflow1 ? 1 : 0) &+ (overflow2 ? 1 : 0)
+            }
+            result[i + b.count] = carry
+        }
+        return result
+    }
+}
+
+/// Arithmetic modulo a 256-bit `value` close to 2^256.
+private struct Modulus: Sendable {
+
+    let value: [UInt64]
+
+    /// 2^256 - value, used to fold the high limbs back in.
+    let complement: [UInt64]
+
+    /// Reduces an integer of any limb count to four limbs below `value`.
+    func reduce(_ wide: [UInt64]) -> [UInt64] {
+        var x = wide
+        while x.count > 4 {
+            let high = Array(x[4...])
+            if Limbs.isZero(high) {
+                x = Array(x[0..<4])
+                break
+            }
+            // x = low + high * 2^256 ≡ low + high * complement
+            x = Limbs.add(Array(x[0..<4]), Limbs.multiply(high, complement))
+        }
+        while x.count < 4 {
+            x.append(0)
+        }
+        while Limbs.compare(x, value) >= 0 {
+            x = Limbs.subtract(x, value)
+        }
+        return x
+    }
+
+    func add(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        reduce(Limbs.add(a, b))
+    }
+
+    func subtract(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        if Limbs.compare(a, b) >= 0 {
+            return reduce(Limbs.subtract(a, b))
+        }
+        return reduce(Limbs.subtract(Limbs.add(a, value), b))
+    }
+
+    func multiply(_ a: [UInt64], _ b: [UInt64]) -> [UInt64] {
+        reduce(Limbs.multiply(a, b))
+    }
+
+    func power(_ base: [UInt64], _ exponent: [UInt64]) -> [UInt64] {
+        var result = Limbs.one
+        for index in stride(from: 255, through: 0, by: -1) {
+            result = multiply(result, result)
+            if Limbs.bit(exponent, index) == 1 {
+                result = multiply(result, base)
+            }
+        }
+        return result
+    }
+
+    /// Inverse by Fermat's little theorem (`value` is prime).
+    func inverse(_ a: [UInt64]) -> [UInt64] {
+        power(a, Limbs.subtract(value, [2]))
+    }
+}
+
+// MARK: - Curve Points
+
+/// A point in Jacobian coordinates; `z == 0` is the point at infinity.
+private struct Point: Sendable {
+    var x: [UInt64]
+    var y: [UInt64]
+    var z: [UInt64]
+
+    static let infinity = Point(x: Limbs.zero, y: Limbs.zero, z: Limbs.zero)
+
+    var isInfinity: Bool {
+        Limbs.isZero(z)
+    }
+
+    /// Affine coordinates; must not be called on infinity.
+    func affine() -> (x: [UInt64], y: [UInt64]) {
+        let field = Secp256k1.field
+        let zInverse = field.inverse(z)
+        let zInverse2 = field.multiply(zInverse, zInverse)
+        return (
+            field.multiply(x, zInverse2),
+            field.multiply(field.multiply(y, zInverse2), zInverse)
+        )
+    }
+
+    /// dbl-2009-l (a = 0).
+    static func double(_ p: Point) -> Point {
+        let field = Secp256k1.field
+        guard !p.isInfinity, !Limbs.isZero(p.y) else { return .infinity }
+        let a = field.multiply(p.x, p.x)
+        let b = field.multiply(p.y, p.y)
+        let c = field.multiply(b, b)
+        let xb = field.add(p.x, b)
+        var d = field.subtract(field.subtract(field.multiply(xb, xb), a), c)
+        d = field.add(d, d)
+        let e = field.add(field.add(a, a), a)
+        let f = field.multiply(e, e)
+        let x3 = field.subtract(f, field.add(d, d))
+        var c8 = field.add(c, c)
+        c8 = field.add(c8, c8)
+        c8 = field.add(c8, c8)
+        let y3 = field.subtract(field.multiply(e, field.subtract(d, x3)), c8)
+        let yz = field.multiply(p.y, p.z)
+        return Point(x: x3, y: y3, z: field.add(yz, yz))
+    }
+
+    /// add-2007-bl.
+    static func add(_ p: Point, _ q: Point) -> Point {
+        let field = Secp256k1.field
+        if p.isInfinity { return q }
+        if q.isInfinity { return p }
+        let z1z1 = field.multiply(p.z, p.z)
+        let z2z2 = field.multiply(q.z, q.z)
+        let u1 = field.multiply(p.x, z2z2)
+        let u2 = field.multiply(q.x, z1z1)
+        let s1 = field.multiply(field.multiply(p.y, q.z), z2z2)
+        let s2 = field.multiply(field.multiply(q.y, p.z), z1z1)
+        let h = field.subtract(u2, u1)
+        var r = field.subtract(s2, s1)
+        if Limbs.isZero(h) {
+            return Limbs.isZero(r) ? double(p) : .infinity
+        }
+        let h2 = field.add(h, h)
+        let i = field.multiply(h2, h2)
+        let j = field.multiply(h, i)
+        r = field.add(r, r)
+        let v = field.multiply(u1, i)
+        let x3 = field.subtract(field.subtract(field.multiply(r, r), j), field.add(v, v))
+        let s1j = field.multiply(s1, j)
+        let y3 = field.subtract(field.multiply(r, field.subtract(v, x3)), field.add(s1j, s1j))
+        let zz = field.add(p.z, q.z)
+        let z3 = field.multiply(field.subtract(field.subtract(field.multiply(zz, zz), z1z1), z2z2), h)
+        return Point(x: x3, y: y3, z: z3)
+    }
+
+    /// Double-and-add. Not constant time: only for public scalars.
+    static func multiply(_ p: Point, by scalar: [UInt64]) -> Point {
+        var result = Point.infinity
+        for index in stride(from: 255, through: 0, by: -1) {
+            result = double(result)
+            if Limbs.bit(scalar, index) == 1 {
+                result = add(result, p)
+            }
+        }
+        return result
+    }
+}
+
+// MARK: - Signatures
+
+/// DER decoding of `ECDSA-Sig-Value ::= SEQUENCE { r INTEGER, s INTEGER }`.
+private enum Signature {
+
+    /// Parses a strict DER signature; returns `nil` unless `r` and `s` are in `[1, n-1]`.
+    static func parseDER(_ bytes: [UInt8]) -> (r: [UInt64], s: [UInt64])? {
+        guard bytes.count >= 8, bytes.count <= 72,
+              bytes[0] == 0x30, Int(bytes[1]) == bytes.count - 2 else {
+            return nil
+        }
+        var offset = 2
+        guard let r = parseInteger(bytes, &offset),
+              let s = parseInteger(bytes, &offset),
+              offset == bytes.count else {
+            return nil
+        }
+        return (r, s)
+    }
+
+    private static func parseInteger(_ bytes: [UInt8], _ offset: inout Int) -> [UInt64]? {
+        guard offset + 2 <= bytes.count, bytes[offset] == 0x02 else { return nil }
+        let length = Int(bytes[offset + 1])
+        let start = offset + 2
+        guard length > 0, start + length <= bytes.count else { return nil }
+        var content = bytes[start..<(start + length)]
+        // Negative values and non-minimal encodings are rejected
+        guard content[content.startIndex] & 0x80 == 0 else { return nil }
+        if content[content.startIndex] == 0 {
+            guard content.count > 1, content[content.startIndex + 1] & 0x80 != 0 else { return nil }
+            content = content.dropFirst()
+        }
+        guard content.count <= 32 else { return nil }
+        offset = start + length
+
+        let value = Limbs.fromBigEndian([UInt8](repeating: 0, count: 32 - content.count) + content)
+        guard !Limbs.isZero(value), Limbs.compare(value, Secp256k1.order.value) < 0 else { return nil }
+        return value
+    }
+}
@@ -0,0 +1,265 @@
+/// SubsystemLogger - Per-subsystem log levels over swift-log
+///
+/// Each part of the stack logs through a `SubsystemLogger` for one named
+/// `LogSubsystem`. Levels are set per subsystem at runtime with
+/// `P2PLogging.setLevel(_:for:)` (or a go-log style spec such as
+/// `"info,yamux=trace,noise=debug"`), independently of each other. Messages
+/// are emitted through swift-log, so whatever backend the application
+/// bootstrapped with `LoggingSystem` receives them, with the subsystem, peer,
+/// protocol and connection as structured metadata.
+
+import Foundation
+import Logging
+import Synchronization
+
+/// A part of the stack whose log level is set independently.
+public enum LogSubsystem: String, Sendable, CaseIterable {
+    case swarm
+    case noise
+    case yamux
+    case dht
+    case pubsub
+    case relay
+    case identify
+
+    /// The default swift-log label, `p2p.<name>`.
+    public var label: String { "p2p.\(rawValue)" }
+}
+
+/// Errors from parsing a log level spec.
+public enum P2PLoggingError: Error, Sendable, Equatable {
+    /// A level name swift-log does not know.
+    case unknownLevel(String)
+    /// A subsystem name that is not a `LogSubsystem`.
+    case unknownSubsystem(String)
+}
+
+/// Process-wide log levels for the subsystems.
+public enum P2PLogging {
+
+    /// Level of a subsystem nothing has been set for.
+    public static let defaultLevel: Logger.Level = .info
+
+    private static let levels = Mutex<[LogSubsystem: Logger.Level]>([:])
+
+    /// The level in force for `subsystem`.
+    public static func level(for subsystem: LogSubsystem) -> Logger.Level {
+        levels.withLock { $0[subsystem] } ?? defaultLevel
+    }
+
+    /// Sets the level of one subsystem.
+    public static func setLevel(_ level: Logger.Level, for subsystem: LogSubsystem) {
+        levels.withLock { $0[subsystem] = level }
+    }
+
+    /// Sets the level of every subsystem.
+    public static func setLevel(_ level: Logger.Level) {
+        levels.withLock { levels in
+            for subsystem in LogSubsystem.allCases {
+                levels[subsystem] = level
+            }
+        }
+    }
+
+    /// Returns every subsystem to `defaultLevel`.
+    public static func resetLevels() {
+        levels.withLock { $0.removeAll() }
+    }
+
+    /// Applies a comma-separated spec in the style of go-log's
+    /// `GOLOG_LOG_LEVEL`: a bare level sets every subsystem, and
+    /// `<subsystem>=<level>` entries override single ones, e.g.
+    /// `"info,yamux=trace,noise=debug"`.
+    ///
+    /// Nothing changes if any entry is invalid.
+    public static func configure(_ spec: String) throws {
+        var global: Logger.Level?
+        var overrides: [LogSubsystem: Logger.Level] = [:]
+        for entry in spec.split(separator: ",") {
+            let parts = entry.split(separator: "=", maxSplits: 1).map {
+                $0.trimmingCharacters(in: .whitespaces)
+            }
+            guard let levelName = parts.last, let level = Logger.Level(rawValue: levelName.lowercased()) else {
+                throw P2PLoggingError.unknownLevel(String(entry))
+            }
+            if parts.count == 1 {
+                global = level
+            } else {
+                guard let subsystem = LogSubsystem(rawValue: parts[0].lowercased()) else {
+                    throw P2PLoggingError.unknownSubsystem(parts[0])
+                }
+                overrides[subsystem] = level
+            }
+        }
+        levels.withLock { levels in
+            if let global {
+                for subsystem in LogSubsystem.allCases {
+                    levels[subsystem] = global
+                }
+            }
+            levels.merge(overrides) { _, new in new }
+        }
+    }
+}
+
+/// A logger for one subsystem.
+///
+/// The method signatures follow `Logger`'s, with optional `peer`, `protocol`
+/// and `connection` fields that become the `peer`, `protocol` and `conn`
+/// metadata keys. Every message also carries `subsystem`.
+public struct SubsystemLogger: Sendable {
+
+    /// The subsystem whose level gates this logger.
+    public let subsystem: LogSubsystem
+
+    private let logger: Logger
+
+    /// Creates a logger for `subsystem`.
+    ///
+    /// - Parameters:
+    ///   - subsystem: The subsystem whose level applies.
+    ///   - label: swift-log label. Default: `subsystem.label`.
+    public init(_ subsystem: LogSubsystem, label: String? = nil) {
+        self.subsystem = subsystem
+        var logger = Logger(label: label ?? subsystem.label)
+        logger[metadataKey: "subsystem"] = "\(subsystem.rawValue)"
+        self.logger = logger
+    }
+
+    /// Creates a logger for `subsystem` that emits through `logger`, for a
+    /// backend other than the one bootstrapped with `LoggingSystem`.
+    public init(_ subsystem: LogSubsystem, logger: Logger) {
+        self.subsystem = subsystem
+        var logger = logger
+        logger[metadataKey: "subsystem"] = "\(subsystem.rawValue)"
+        self.logger = logger
+    }
+
+    /// Whether a message at `level` would be emitted.
+    public func isEnabled(_ level: Logger.Level) -> Bool {
+        level >= P2PLogging.level(for: subsystem)
+    }
+
+    /// Emits a message at `level` if the subsystem's level allows it.
+    public func log(
+        level: Logger.Level,
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        let threshold = P2PLogging.level(for: subsystem)
+        guard level >= threshold else { return }
+
+        var fields = metadata() ?? [:]
+        if let peer {
+            fields["peer"] = "\(peer)"
+        }
+        if let protocolID {
+            fields["protocol"] = "\(protocolID)"
+        }
+        if let connection {
+            fields["conn"] = "\(connection.description)"
+        }
+        // The subsystem level is authoritative: the backend's own threshold
+        // would otherwise drop messages the subsystem was raised to show.
+        var logger = self.logger
+        logger.logLevel = threshold
+        logger.log(
+            level: level,
+            message(),
+            metadata: fields.isEmpty ? nil : fields,
+            file: file,
+            function: function,
+            line: line
+        )
+    }
+
+    public func trace(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .trace, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+
+    public func debug(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .debug, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+
+    public func info(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .info, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+
+    public func notice(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .notice, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+
+    public func warning(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .warning, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+
+    public func error(
+        _ message: @autoclosure () -> Logger.Message,
+        peer: PeerID? = nil,
+        protocol protocolID: String? = nil,
+        connection: (any CustomStringConvertible & Sendable)? = nil,
+        metadata: @autoclosure () -> Logger.Metadata? = nil,
+        file: String = #fileID,
+        function: String = #function,
+        line: UInt = #line
+    ) {
+        log(level: .error, message(), peer: peer, protocol: protocolID, connection: connection,
+            metadata: metadata(), file: file, function: function, line: line)
+    }
+}
@@ -0,0 +1,81 @@
+/// WireTracer - Raw bytes of handshake messages and muxer control frames
+///
+/// A `WireTracer` is handed the exact bytes a layer sent or received at
+/// points where interop failures are usually decided: the Noise XX handshake
+/// messages and the muxers' control frames. It is off unless a tracer is
+/// configured; call sites use optional chaining, so with no tracer the bytes
+/// are never copied.
+
+import Foundation
+import Logging
+
+/// Whether traced bytes were sent or received.
+public enum WireDirection: String, Sendable {
+    case outbound
+    case inbound
+}
+
+/// The protocol step a traced message belongs to, e.g. `noise.message-a`.
+public struct WirePhase: RawRepresentable, Hashable, Sendable, CustomStringConvertible {
+    public let rawValue: String
+
+    public init(rawValue: String) {
+        self.rawValue = rawValue
+    }
+
+    public var description: String { rawValue }
+
+    // Noise XX handshake messages, including the 2-byte length prefix
+    /// `-> e`
+    public static let noiseMessageA = WirePhase(rawValue: "noise.message-a")
+    /// `<- e, ee, s, es`
+    public static let noiseMessageB = WirePhase(rawValue: "noise.message-b")
+    /// `-> s, se`
+    public static let noiseMessageC = WirePhase(rawValue: "noise.message-c")
+
+    // Yamux control frames: the 12-byte header
+    /// A data frame carrying SYN, ACK, FIN or RST (payload not included).
+    public static let yamuxData = WirePhase(rawValue: "yamux.data")
+    public static let yamuxWindowUpdate = WirePhase(rawValue: "yamux.window-update")
+    public static let yamuxPing = WirePhase(rawValue: "yamux.ping")
+    public static let yamuxGoAway = WirePhase(rawValue: "yamux.go-away")
+
+    // Mplex control frames: the whole frame
+    public static let mplexNewStream = WirePhase(rawValue: "mplex.new-stream")
+    public static let mplexClose = WirePhase(rawValue: "mplex.close")
+    public static let mplexReset = WirePhase(rawValue: "mplex.reset")
+}
+
+/// A callback receiving traced wire bytes.
+///
+/// The callback runs inline on the connection's read or write path, so it
+/// should hand the bytes off rather than block.
+public struct WireTracer: Sendable {
+
+    private let body: @Sendable (WireDirection, WirePhase, [UInt8]) -> Void
+
+    /// Creates a tracer that calls `body` with each traced message.
+    public init(_ body: @escaping @Sendable (_ direction: WireDirection, _ phase: WirePhase, _ bytes: [UInt8]) -> Void) {
+        self.body = body
+    }
+
+    /// Reports one message.
+    public func trace(_ direction: WireDirection, _ phase: WirePhase, _ bytes: [UInt8]) {
+        body(direction, phase, bytes)
+    }
+
+    /// A tracer that logs each message as hex through `logger`, in the
+    /// spirit of the go debug node's frame dumps.
+    public static func logging(to logger: Logger, level: Logger.Level = .debug) -> WireTracer {
+        WireTracer { direction, phase, bytes in
+            logger.log(
+                level: level,
+                "wire \(direction.rawValue) \(phase)",
+                metadata: [
+                    "bytes": "\(bytes.count)",
+                    "hex": "\(bytes.map { String(format: "%02x", $0) }.joined())",
+                ]
+            )
+        }
+    }
+}
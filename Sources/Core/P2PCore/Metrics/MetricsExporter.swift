@@ -79,4 +79,7 @@ public enum LibP2PMetrics {
     public static let streamsOpenedTotal: MetricName = "libp2p_streams_opened_total"
     /// Total number of peers discovered (counter).
     public static let peersDiscoveredTotal: MetricName = "libp2p_peers_discovered_total"
+    /// Inbound streams a muxed connection has received but not yet handed
+    /// to an accepter (gauge).
+    public static let muxerAcceptQueueDepth: MetricName = "libp2p_muxer_accept_queue_depth"
 }
@@ -3,8 +3,11 @@ import Crypto
 
 /// A signed envelope that wraps a record with cryptographic signature.
 ///
-/// The envelope format is compatible with libp2p's signed envelope specification.
-/// It contains a public key, payload type, payload, and signature.
+/// The envelope format is compatible with libp2p's signed envelope specification
+/// (RFC 0002). It contains a public key, payload type, payload, and signature.
+/// The signature covers the domain, payload type and payload, each prefixed with
+/// its varint length, exactly as go-libp2p's `record.Seal` signs them, so
+/// envelopes sealed on either side verify on the other.
 public struct Envelope: Sendable, Equatable {
     /// The public key of the signer.
     public let publicKey: PublicKey
@@ -99,102 +102,100 @@ public struct Envelope: Sendable, Equatable {
         publicKey.peerID
     }
 
-    /// Serializes the envelope to bytes.
+    /// Serializes the envelope to its protobuf form, byte-identical to
+    /// go-libp2p's `Envelope.Marshal`.
+    ///
+    /// Fields: `public_key` (1), `payload_type` (2), `payload` (3) and
+    /// `signature` (5). Empty fields are omitted, as proto3 does.
     public func marshal() throws -> Data {
-        let publicKeyBytes = publicKey.protobufEncoded
-        // Estimate: 4 varint prefixes (max 10 bytes each) + field data
-        let estimatedSize = 40 + publicKeyBytes.count + payloadType.count + payload.count + signature.count
-        var result = Data(capacity: estimatedSize)
-
-        // Public key (length-prefixed protobuf)
-        result.append(contentsOf: Varint.encode(UInt64(publicKeyBytes.count)))
-        result.append(publicKeyBytes)
-
-        // Payload type (length-prefixed)
-        result.append(contentsOf: Varint.encode(UInt64(payloadType.count)))
-        result.append(payloadType)
-
-        // Payload (length-prefixed)
-        result.append(contentsOf: Varint.encode(UInt64(payload.count)))
-        result.append(payload)
-
-        // Signature (length-prefixed)
-        result.append(contentsOf: Varint.encode(UInt64(signature.count)))
-        result.append(signature)
-
+        var result = Data()
+        for (fieldNumber, value) in [
+            (Self.fieldPublicKey, publicKey.protobufEncoded),
+            (Self.fieldPayloadType, payloadType),
+            (Self.fieldPayload, payload),
+            (Self.fieldSignature, signature),
+        ] where !value.isEmpty {
+            result.append(encodeProtobufField(fieldNumber: fieldNumber, data: value))
+        }
         return result
     }
 
     /// Maximum allowed length for individual fields to prevent DoS attacks.
     private static let maxFieldLength: UInt64 = 1024 * 1024  // 1MB for payload
 
-    /// Deserializes an envelope from bytes.
-    public static func unmarshal(_ data: Data) throws -> Envelope {
-        var offset = 0
+    private static let fieldPublicKey: UInt64 = 1
+    private static let fieldPayloadType: UInt64 = 2
+    private static let fieldPayload: UInt64 = 3
+    private static let fieldSignature: UInt64 = 5
 
-        // Public key (typically small, 4KB max)
-        let (publicKeyLength, pkLenBytes) = try Varint.decode(from: data, at: offset)
-        guard publicKeyLength <= 4096 else {
-            throw EnvelopeError.fieldTooLarge(publicKeyLength)
-        }
-        offset += pkLenBytes
-        let pkLen = Int(publicKeyLength)
-        let publicKeyEnd = offset + pkLen
-        guard publicKeyEnd <= data.count else {
-            throw EnvelopeError.invalidFormat
-        }
-        let publicKey = try PublicKey(protobufEncoded: data[fieldRange(in: data, offset: offset, end: publicKeyEnd)])
-        offset = publicKeyEnd
-
-        // Payload type (typically a few bytes)
-        let (payloadTypeLength, ptLenBytes) = try Varint.decode(from: data, at: offset)
-        guard payloadTypeLength <= 256 else {
-            throw EnvelopeError.fieldTooLarge(payloadTypeLength)
-        }
-        offset += ptLenBytes
-        let ptLen = Int(payloadTypeLength)
-        let payloadTypeEnd = offset + ptLen
-        guard payloadTypeEnd <= data.count else {
+    /// Deserializes an envelope from its protobuf form.
+    ///
+    /// Unknown length-delimited fields are skipped. The signature is not
+    /// checked here; use `consume(_:as:)` or `verify(domain:)`.
+    ///
+    /// - Throws: `EnvelopeError.invalidFormat` for malformed protobuf or a
+    ///   missing public key, `EnvelopeError.fieldTooLarge` for oversized fields.
+    public static func unmarshal(_ data: Data) throws -> Envelope {
+        let fields: [ProtobufField]
+        do {
+            fields = try decodeProtobufFields(from: data, maxFieldSize: Int(maxFieldLength))
+        } catch ProtobufLiteError.fieldTooLarge(let size, _) {
+            throw EnvelopeError.fieldTooLarge(size)
+        } catch {
             throw EnvelopeError.invalidFormat
         }
-        let payloadType = data[fieldRange(in: data, offset: offset, end: payloadTypeEnd)]
-        offset = payloadTypeEnd
 
-        // Payload
-        let (payloadLength, pLenBytes) = try Varint.decode(from: data, at: offset)
-        guard payloadLength <= maxFieldLength else {
-            throw EnvelopeError.fieldTooLarge(payloadLength)
-        }
-        offset += pLenBytes
-        let pLen = Int(payloadLength)
-        let payloadEnd = offset + pLen
-        guard payloadEnd <= data.count else {
-            throw EnvelopeError.invalidFormat
+        var publicKeyBytes: [UInt8]?
+        var payloadType: [UInt8] = []
+        var payload: [UInt8] = []
+        var signature: [UInt8] = []
+        for field in fields {
+            switch field.fieldNumber {
+            case fieldPublicKey:
+                // Public key (typically small, 4KB max)
+                try checkFieldLength(field.data, max: 4096)
+                publicKeyBytes = field.data
+            case fieldPayloadType:
+                // Payload type (typically a few bytes)
+                try checkFieldLength(field.data, max: 256)
+                payloadType = field.data
+            case fieldPayload:
+                payload = field.data
+            case fieldSignature:
+                // Signature (typically 64-512 bytes)
+                try checkFieldLength(field.data, max: 1024)
+                signature = field.data
+            default:
+                continue
+            }
         }
-        let payload = data[fieldRange(in: data, offset: offset, end: payloadEnd)]
-        offset = payloadEnd
 
-        // Signature (typically 64-512 bytes)
-        let (signatureLength, sLenBytes) = try Varint.decode(from: data, at: offset)
-        guard signatureLength <= 1024 else {
-            throw EnvelopeError.fieldTooLarge(signatureLength)
-        }
-        offset += sLenBytes
-        let sigLen = Int(signatureLength)
-        let signatureEnd = offset + sigLen
-        guard signatureEnd <= data.count else {
+        guard let publicKeyBytes else {
             throw EnvelopeError.invalidFormat
         }
-        let signature = data[fieldRange(in: data, offset: offset, end: signatureEnd)]
-
         return Envelope(
-            publicKey: publicKey,
-            payloadType: payloadType,
-            payload: payload,
-            signature: signature
+            publicKey: try PublicKey(protobufEncoded: Data(publicKeyBytes)),
+            payloadType: Data(payloadType),
+            payload: Data(payload),
+            signature: Data(signature)
         )
     }
 
+    /// Unmarshals an envelope and extracts its record, verifying the payload
+    /// type and the signature under the record type's domain (go-libp2p
+    /// `ConsumeTypedEnvelope`).
+    ///
+    /// - Returns: The envelope and its decoded record.
+    /// - Throws: `EnvelopeError` if the envelope is malformed, of another
+    ///   payload type, or not validly signed for `R.domain`.
+    public static func consume<R: SignedRecord>(
+        _ data: Data,
+        as type: R.Type
+    ) throws -> (envelope: Envelope, record: R) {
+        let envelope = try unmarshal(data)
+        return (envelope, try envelope.record(as: type))
+    }
+
     // MARK: - Private
 
     private init(
@@ -234,14 +235,17 @@ public struct Envelope: Sendable, Equatable {
         return data
     }
 
-    private static func fieldRange(in data: Data, offset: Int, end: Int) -> Range<Data.Index> {
-        let startIndex = data.index(data.startIndex, offsetBy: offset)
-        let endIndex = data.index(data.startIndex, offsetBy: end)
-        return startIndex..<endIndex
+    private static func checkFieldLength(_ field: [UInt8], max: UInt64) throws {
+        guard UInt64(field.count) <= max else {
+            throw EnvelopeError.fieldTooLarge(UInt64(field.count))
+        }
     }
 
 }
 
+/// The libp2p specification's name for ``Envelope``.
+public typealias SignedEnvelope = Envelope
+
 /// Errors that can occur when working with envelopes.
 public enum EnvelopeError: Error, Sendable {
     case invalidSignature
@@ -0,0 +1,145 @@
+/// P2PDiscovery - Peers manifest
+///
+/// Preloads a PeerStore from a file of known peers and dumps a PeerStore
+/// back to one, so test topologies can be wired from a shared manifest
+/// instead of code.
+///
+/// A manifest is either a JSON array of entries or JSON lines, one entry
+/// per line:
+///
+/// ```json
+/// {"peerID": "12D3KooW...", "addrs": ["/ip4/127.0.0.1/tcp/4001"]}
+/// ```
+
+import Foundation
+import P2PCore
+
+// MARK: - Manifest Entry
+
+/// One peer of a peers manifest.
+public struct PeersManifestEntry: Codable, Sendable, Equatable {
+
+    /// The peer ID in its string form.
+    public var peerID: String
+
+    /// The peer's multiaddrs in their string form.
+    public var addrs: [String]
+
+    /// Creates an entry.
+    public init(peerID: String, addrs: [String]) {
+        self.peerID = peerID
+        self.addrs = addrs
+    }
+}
+
+// MARK: - PeersManifestError
+
+/// Errors raised while reading a peers manifest.
+public enum PeersManifestError: Error, Sendable, Equatable {
+
+    /// An entry could not be decoded. `line` is 1-based for JSON lines
+    /// manifests and nil for JSON array manifests.
+    case malformedEntry(line: Int?)
+
+    /// An entry's peer ID does not parse.
+    case invalidPeerID(String)
+
+    /// An entry's address does not parse.
+    case invalidAddress(peerID: String, address: String)
+}
+
+// MARK: - PeerStore Import/Export
+
+extension PeerStore {
+
+    /// Adds every peer of the manifest at `url` to the store.
+    ///
+    /// The whole manifest is validated before anything is added, so a bad
+    /// entry leaves the store untouched.
+    ///
+    /// - Parameters:
+    ///   - url: A JSON array or JSON lines manifest.
+    ///   - ttl: TTL for the imported addresses (`nil` = store default).
+    /// - Returns: The number of peers imported.
+    @discardableResult
+    public func importPeers(from url: URL, ttl: Duration? = nil) async throws -> Int {
+        let entries = try PeersManifest.decode(try Data(contentsOf: url))
+        var peers: [(PeerID, [Multiaddr])] = []
+        for entry in entries {
+            let peer: PeerID
+            do {
+                peer = try PeerID(string: entry.peerID)
+            } catch {
+                throw PeersManifestError.invalidPeerID(entry.peerID)
+            }
+            var addresses: [Multiaddr] = []
+            for address in entry.addrs {
+                do {
+                    addresses.append(try Multiaddr(address))
+                } catch {
+                    throw PeersManifestError.invalidAddress(peerID: entry.peerID, address: address)
+                }
+            }
+            peers.append((peer, addresses))
+        }
+
+        for (peer, addresses) in peers where !addresses.isEmpty {
+            await addAddresses(addresses, for: peer, ttl: ttl)
+        }
+        return peers.count
+    }
+
+    /// Writes every peer with a live address to `url` as a JSON array
+    /// manifest, sorted by peer ID.
+    public func exportPeers(to url: URL) async throws {
+        var entries: [PeersManifestEntry] = []
+        for peer in await allPeers() {
+            let addresses = await addresses(for: peer)
+            guard !addresses.isEmpty else { continue }
+            entries.append(PeersManifestEntry(
+                peerID: peer.description,
+                addrs: addresses.map(\.description)
+            ))
+        }
+        entries.sort { $0.peerID < $1.peerID }
+
+        let encoder = JSONEncoder()
+        encoder.outputFormatting = [.prettyPrinted, .sortedKeys]
+        try encoder.encode(entries).write(to: url, options: .atomic)
+    }
+}
+
+// MARK: - Decoding
+
+enum PeersManifest {
+
+    /// Decodes a JSON array manifest or, when the data does not start with
+    /// `[`, a JSON lines manifest. Blank lines are skipped.
+    static func decode(_ data: Data) throws -> [PeersManifestEntry] {
+        let decoder = JSONDecoder()
+        let firstByte = data.first { !Self.isWhitespace($0) }
+        if firstByte == UInt8(ascii: "[") {
+            do {
+                return try decoder.decode([PeersManifestEntry].self, from: data)
+            } catch {
+                throw PeersManifestError.malformedEntry(line: nil)
+            }
+        }
+
+        var entries: [PeersManifestEntry] = []
+        for (index, line) in data.split(separator: UInt8(ascii: "\n"), omittingEmptySubsequences: false).enumerated() {
+            guard line.contains(where: { !Self.isWhitespace($0) }) else { continue }
+            do {
+                entries.append(try decoder.decode(PeersManifestEntry.self, from: Data(line)))
+            } catch {
+                throw PeersManifestError.malformedEntry(line: index + 1)
+            }
+        }
+        return entries
+    }
+
+    private static func isWhitespace(_ byte: UInt8) -> Bool {
+        byte == UInt8(ascii: " ") || byte == UInt8(ascii: "\t")
+            || byte == UInt8(ascii: "\n") || byte == UInt8(ascii: "\r")
+    }
+}
@@ -0,0 +1,38 @@
+/// Connectedness - How reachable a peer is right now
+///
+/// Derived from the open connections and the recent dial history, like
+/// go-libp2p's `network.Connectedness`.
+
+/// How reachable a peer is.
+///
+/// Equivalent to go-libp2p's `Connectedness`, with `limited` for a peer that
+/// is reachable only over relayed connections.
+public enum Connectedness: Sendable, Equatable {
+    /// No open connection and nothing recent to go on.
+    case notConnected
+
+    /// At least one open direct connection.
+    case connected
+
+    /// Open connections, all of them limited (relayed).
+    case limited
+
+    /// No open connection, but one was open within the recent window and no
+    /// dial has failed since.
+    case canConnect
+
+    /// The last dial to the peer failed and its dial backoff has not expired.
+    case cannotConnect
+}
+
+extension Connectedness {
+    /// Whether there is an open connection, limited or not.
+    public var isConnected: Bool {
+        switch self {
+        case .connected, .limited:
+            return true
+        case .notConnected, .canConnect, .cannotConnect:
+            return false
+        }
+    }
+}
@@ -0,0 +1,97 @@
+/// ConnectednessTracker - Reports each peer's connectedness once per change
+///
+/// Keeps the connectedness last reported for each peer and when it was last
+/// connected, so `canConnect` survives the disconnect for a while.
+
+import Foundation
+import Synchronization
+import P2PCore
+
+/// Remembers the connectedness last reported for each peer, so changes can
+/// be reported once, and when each peer was last connected.
+internal final class ConnectednessTracker: Sendable {
+
+    private struct Entry: Sendable {
+        var reported: Connectedness
+        /// When the last open connection went away.
+        var disconnectedAt: ContinuousClock.Instant?
+        var updatedAt: ContinuousClock.Instant
+    }
+
+    /// How long a closed connection keeps a peer at `canConnect`.
+    ///
+    /// Matches go-libp2p's `RecentlyConnectedAddrTTL` order of magnitude.
+    let recentWindow: Duration
+
+    private let entries: Mutex<[PeerID: Entry]>
+
+    init(recentWindow: Duration = .seconds(600)) {
+        self.recentWindow = recentWindow
+        self.entries = Mutex([:])
+    }
+
+    /// Classifies `peer` from the pool and the dial backoff.
+    func connectedness(of peer: PeerID, pool: ConnectionPool, dialBackoff: DialBackoff) -> Connectedness {
+        let now = ContinuousClock.now
+        return entries.withLock { entries in
+            classify(peer, entry: entries[peer], now: now, pool: pool, dialBackoff: dialBackoff)
+        }
+    }
+
+    /// Reclassifies `peer` and returns the new connectedness if it differs
+    /// from the one last reported.
+    func update(_ peer: PeerID, pool: ConnectionPool, dialBackoff: DialBackoff) -> Connectedness? {
+        let now = ContinuousClock.now
+        return entries.withLock { entries in
+            let previous = entries[peer]
+            let current = classify(peer, entry: previous, now: now, pool: pool, dialBackoff: dialBackoff)
+            var entry = previous ?? Entry(reported: .notConnected, disconnectedAt: nil, updatedAt: now)
+            if entry.reported.isConnected && !current.isConnected {
+                entry.disconnectedAt = now
+            }
+            entry.updatedAt = now
+            let changed = entry.reported != current
+            entry.reported = current
+            entries[peer] = entry
+            prune(&entries, now: now)
+            return changed ? current : nil
+        }
+    }
+
+    /// Forgets every peer. Called during shutdown.
+    func clear() {
+        entries.withLock { $0.removeAll() }
+    }
+
+    private func classify(
+        _ peer: PeerID,
+        entry: Entry?,
+        now: ContinuousClock.Instant,
+        pool: ConnectionPool,
+        dialBackoff: DialBackoff
+    ) -> Connectedness {
+        if pool.isConnected(to: peer) {
+            return pool.isLimitedConnection(to: peer) ? .limited : .connected
+        }
+        if dialBackoff.shouldBackOff(from: peer) {
+            return .cannotConnect
+        }
+        guard let entry else { return .notConnected }
+        // Reported connected but not yet told about the disconnect
+        if entry.reported.isConnected {
+            return .canConnect
+        }
+        if let disconnectedAt = entry.disconnectedAt, now - disconnectedAt < recentWindow {
+            return .canConnect
+        }
+        return .notConnected
+    }
+
+    /// Drops peers that have not been connected for longer than the recent
+    /// window.
+    private func prune(_ entries: inout [PeerID: Entry], now: ContinuousClock.Instant) {
+        entries = entries.filter { _, entry in
+            entry.reported.isConnected || now - entry.updatedAt < recentWindow
+        }
+    }
+}
@@ -5,6 +5,7 @@
 import Foundation
 import P2PCore
 import P2PRuntime
+import P2PMuxYamux
 
 /// Events related to connection lifecycle.
 ///
@@ -85,6 +86,17 @@ public enum ConnectionEvent: Sendable {
     ///   - address: The address that was gated
     ///   - stage: At which stage the connection was rejected
     case gated(peer: PeerID?, address: Multiaddr, stage: GateStage)
+
+    /// The peer sent GoAway on a connection.
+    ///
+    /// The connection is draining: its open streams keep working, new
+    /// streams use another connection, and a replacement is dialed when the
+    /// peer's address is known.
+    ///
+    /// - Parameters:
+    ///   - peer: The peer that sent GoAway
+    ///   - reason: The reason code it sent
+    case goAway(peer: PeerID, reason: YamuxGoAwayReason)
 }
 
 // MARK: - Convenience Properties
@@ -100,7 +112,8 @@ extension ConnectionEvent {
              .reconnectionFailed(let peer, _),
              .trimmed(peer: let peer, reason: _),
              .trimmedWithContext(peer: let peer, context: _),
-             .healthCheckFailed(let peer):
+             .healthCheckFailed(let peer),
+             .goAway(let peer, _):
             return peer
         case .gated(let peer, _, _):
             return peer
@@ -185,6 +198,8 @@ extension ConnectionEvent: CustomStringConvertible {
             return "healthCheckFailed(\(peer))"
         case .gated(let peer, let address, let stage):
             return "gated(\(peer?.description ?? "unknown"), \(address), \(stage))"
+        case .goAway(let peer, let reason):
+            return "goAway(\(peer), reason: \(reason))"
         }
     }
 }
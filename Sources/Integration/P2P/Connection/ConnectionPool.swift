@@ -23,6 +23,10 @@ internal struct ManagedConnection: Sendable {
     /// Connection direction.
     let direction: ConnectionDirection
 
+    /// Security handshake role assigned to a simultaneous-connect dial, or
+    /// `nil` when the role follows `direction`.
+    var assignedSecurityRole: SecurityRole? = nil
+
     /// The underlying muxed connection (nil if disconnected).
     var connection: (any MuxedConnection)?
 
@@ -47,6 +51,11 @@ internal struct ManagedConnection: Sendable {
     /// Whether this is a limited (relay) connection.
     var isLimited: Bool
 
+    /// Whether the remote peer sent GoAway. A draining connection keeps its
+    /// open streams but takes no new ones, and does not count toward the
+    /// per-peer limit, so a replacement can be dialed.
+    var isDraining: Bool = false
+
     /// Whether this connection should be kept alive (C3).
     /// Set by protocols (e.g. GossipSub for mesh peers).
     var keepAlive: Bool = false
@@ -55,6 +64,23 @@ internal struct ManagedConnection: Sendable {
     /// released. Used to guarantee exactly-once release across the racing
     /// close paths (closePeer / handleConnectionClosed / idle trim / shutdown).
     var resourceReleased: Bool = false
+
+    /// Ping-based liveness score in `0...1`, lowered by slow or failed pings.
+    var livenessScore: Double = LivenessScoringPolicy.initialScore
+
+    /// Round-trip time of the last successful liveness ping.
+    var lastPingRTT: Duration? = nil
+
+    /// Score used to order trim candidates; lower is trimmed first.
+    var effectiveScore: Double {
+        Double(tags.count) + livenessScore
+    }
+
+    /// Role this side took in the security handshake. Both peers agree on
+    /// it even when both dialed, unlike `direction`.
+    var securityRole: SecurityRole {
+        assignedSecurityRole ?? (direction == .outbound ? .initiator : .responder)
+    }
 }
 
 /// A token returned by the pool when a connection is removed, indicating
@@ -127,12 +153,14 @@ internal final class ConnectionPool: Sendable {
     ///   - peer: The remote peer
     ///   - address: The connection address
     ///   - direction: Connection direction
+    ///   - securityRole: Handshake role of a simultaneous-connect dial
     /// - Returns: The assigned connection ID
     @discardableResult
     func addConnecting(
         for peer: PeerID,
         address: Multiaddr,
         direction: ConnectionDirection,
+        securityRole: SecurityRole? = nil,
         isLimited: Bool = false
     ) -> ConnectionID {
         let id = ConnectionID()
@@ -143,6 +171,7 @@ internal final class ConnectionPool: Sendable {
             peer: peer,
             address: address,
             direction: direction,
+            assignedSecurityRole: securityRole,
             connection: nil,
             state: .connecting,
             retryCount: 0,
@@ -213,6 +242,7 @@ internal final class ConnectionPool: Sendable {
         for peer: PeerID,
         address: Multiaddr,
         direction: ConnectionDirection,
+        securityRole: SecurityRole? = nil,
         isLimited: Bool = false
     ) -> ConnectionID? {
         let id = ConnectionID()
@@ -223,6 +253,7 @@ internal final class ConnectionPool: Sendable {
             peer: peer,
             address: address,
             direction: direction,
+            assignedSecurityRole: securityRole,
             connection: connection,
             state: .connected,
             retryCount: 0,
@@ -388,6 +419,7 @@ internal final class ConnectionPool: Sendable {
             state.connections[id]?.state = .connected
             state.connections[id]?.lastActivity = now
             state.connections[id]?.connectedAt = now
+            state.connections[id]?.isDraining = false
             if let peer = state.connections[id]?.peer {
                 state.connectedPeerCache.insert(peer)
             }
@@ -413,6 +445,7 @@ internal final class ConnectionPool: Sendable {
             state.connections[id]?.state = .connected
             state.connections[id]?.lastActivity = now
             state.connections[id]?.connectedAt = now
+            state.connections[id]?.isDraining = false
             // A fresh connection resource is reserved by the caller on
             // reactivation, so this entry is reserved again — clear the
             // released flag so the next close releases exactly once.
@@ -430,25 +463,56 @@ internal final class ConnectionPool: Sendable {
     /// This is the correct place to track activity because getting a connection
     /// implies it will be used.
     ///
-    /// Prioritizes connections in `.connected` state.
+    /// Prioritizes connections in `.connected` state, and among those an
+    /// unlimited (direct) connection over a limited (relay) one. Draining
+    /// connections are skipped.
     ///
     /// - Parameter peer: The peer to look up
     /// - Returns: The muxed connection, or nil if not connected
     func connection(to peer: PeerID) -> (any MuxedConnection)? {
+        activeConnection(to: peer)?.connection
+    }
+
+    /// Gets the connection `connection(to:)` picks, with its ID.
+    ///
+    /// - Parameter peer: The peer to look up
+    /// - Returns: The connection ID and muxed connection, or nil if not connected
+    func activeConnection(to peer: PeerID) -> (id: ConnectionID, connection: any MuxedConnection)? {
         let now = ContinuousClock.now
         return state.withLock { state in
             guard let ids = state.peerConnections[peer] else { return nil }
 
-            // Find first connected entry and record activity atomically
+            // Find the best connected entry and record activity atomically
+            var best: (id: ConnectionID, connection: any MuxedConnection, isLimited: Bool)?
             for id in ids {
-                if let managed = state.connections[id],
-                   managed.state.isConnected,
-                   managed.connection != nil {
-                    state.connections[id]?.lastActivity = now
-                    return managed.connection
+                guard let managed = state.connections[id],
+                      managed.state.isConnected,
+                      !managed.isDraining,
+                      let connection = managed.connection else { continue }
+                if best == nil || (best?.isLimited == true && !managed.isLimited) {
+                    best = (id, connection, managed.isLimited)
                 }
             }
-            return nil
+            guard let best else { return nil }
+            state.connections[best.id]?.lastActivity = now
+            return (best.id, best.connection)
+        }
+    }
+
+    /// Gets a connection by ID if it can take new streams (connected and not
+    /// draining) and records activity.
+    ///
+    /// - Parameter id: The connection ID
+    /// - Returns: The muxed connection, or nil if it is gone or unusable
+    func activeConnection(_ id: ConnectionID) -> (any MuxedConnection)? {
+        let now = ContinuousClock.now
+        return state.withLock { state in
+            guard let managed = state.connections[id],
+                  managed.state.isConnected,
+                  !managed.isDraining,
+                  let connection = managed.connection else { return nil }
+            state.connections[id]?.lastActivity = now
+            return connection
         }
     }
 
@@ -540,6 +604,17 @@ internal final class ConnectionPool: Sendable {
         }
     }
 
+    /// Remote addresses of the active inbound connections.
+    ///
+    /// Only `.connected` state connections are included.
+    var inboundAddresses: [Multiaddr] {
+        state.withLock { state in
+            state.connections.values.filter {
+                $0.direction == .inbound && $0.state.isConnected
+            }.map(\.address)
+        }
+    }
+
     /// Number of active outbound connections.
     ///
     /// Only `.connected` state connections are counted.
@@ -554,17 +629,37 @@ internal final class ConnectionPool: Sendable {
     /// Checks if the connection to a peer is limited (relay).
     ///
     /// - Parameter peer: The peer to check
-    /// - Returns: true if the peer's active connection is limited
+    /// - Returns: true if the peer's active connections are all limited
     func isLimitedConnection(to peer: PeerID) -> Bool {
         state.withLock { state in
             guard let ids = state.peerConnections[peer] else { return false }
+            var hasLimited = false
             for id in ids {
                 if let managed = state.connections[id],
                    managed.state.isConnected {
-                    return managed.isLimited
+                    if !managed.isLimited { return false }
+                    hasLimited = true
                 }
             }
-            return false
+            return hasLimited
+        }
+    }
+
+    /// Checks if a peer has an active unlimited (non-relay) connection.
+    ///
+    /// A dial to such a peer reuses the existing connection unless multiple
+    /// connections per peer are allowed. A draining connection does not
+    /// count, so its replacement is dialed.
+    ///
+    /// - Parameter peer: The peer to check
+    /// - Returns: true if at least one active connection is unlimited
+    func hasUnlimitedConnection(to peer: PeerID) -> Bool {
+        state.withLock { state in
+            guard let ids = state.peerConnections[peer] else { return false }
+            return ids.contains { id in
+                guard let managed = state.connections[id] else { return false }
+                return managed.state.isConnected && !managed.isLimited && !managed.isDraining
+            }
         }
     }
 
@@ -586,7 +681,8 @@ internal final class ConnectionPool: Sendable {
     /// Returns all connected ManagedConnection entries for a peer.
     ///
     /// Used by simultaneous connect resolution to determine which
-    /// connection to keep when duplicates exist.
+    /// connection to keep when duplicates exist. Draining connections are
+    /// left out; they close on their own.
     ///
     /// - Parameter peer: The peer to look up
     /// - Returns: All managed connections in `.connected` state
@@ -595,12 +691,27 @@ internal final class ConnectionPool: Sendable {
             guard let ids = state.peerConnections[peer] else { return [] }
             return ids.compactMap { id in
                 guard let managed = state.connections[id],
-                      managed.state.isConnected else { return nil }
+                      managed.state.isConnected,
+                      !managed.isDraining else { return nil }
                 return managed
             }
         }
     }
 
+    /// Marks a connection as draining after the remote peer's GoAway.
+    ///
+    /// - Parameter id: The connection ID
+    /// - Returns: The entry, if it was connected and not already draining
+    func markDraining(_ id: ConnectionID) -> ManagedConnection? {
+        state.withLock { state in
+            guard let managed = state.connections[id],
+                  managed.state.isConnected,
+                  !managed.isDraining else { return nil }
+            state.connections[id]?.isDraining = true
+            return state.connections[id]
+        }
+    }
+
     // MARK: - Tagging & Protection
 
     /// Adds a tag to a peer's connections.
@@ -673,7 +784,8 @@ internal final class ConnectionPool: Sendable {
     /// Trimming prioritizes based on:
     /// 1. Protected connections are never trimmed
     /// 2. Connections within grace period are not trimmed
-    /// 3. Fewer tags = lower priority (trimmed first)
+    /// 3. Lower effective score (tag count plus liveness score) = lower
+    ///    priority (trimmed first)
     /// 4. Older last activity = lower priority
     /// 5. Inbound connections trimmed before outbound
     ///
@@ -969,6 +1081,30 @@ internal final class ConnectionPool: Sendable {
         }
     }
 
+    /// Folds the result of a liveness ping into a connection's score.
+    ///
+    /// - Parameters:
+    ///   - id: The connection ID
+    ///   - rtt: The round-trip time, or `nil` if the ping failed
+    ///   - policy: The scoring policy
+    /// - Returns: The updated score, or `nil` if the connection is gone
+    @discardableResult
+    func recordLivenessProbe(
+        _ id: ConnectionID,
+        rtt: Duration?,
+        policy: LivenessScoringPolicy
+    ) -> Double? {
+        state.withLock { state in
+            guard let managed = state.connections[id] else { return nil }
+            let score = policy.updated(score: managed.livenessScore, rtt: rtt)
+            state.connections[id]?.livenessScore = score
+            if let rtt {
+                state.connections[id]?.lastPingRTT = rtt
+            }
+            return score
+        }
+    }
+
     /// Sets the keep-alive flag for all connections to a peer (C3).
     ///
     /// Keep-alive connections are excluded from idle connection trimming.
@@ -1026,16 +1162,14 @@ internal final class ConnectionPool: Sendable {
 
     /// Checks if another connection to a peer is allowed.
     ///
-    /// Only counts active (`.connected`) connections toward the limit.
+    /// Only counts active (`.connected`), non-draining connections toward
+    /// the limit.
     ///
     /// - Parameter peer: The peer to check
     /// - Returns: true if within per-peer limit
     func canConnectTo(peer: PeerID) -> Bool {
         let activeCount = state.withLock { state in
-            guard let ids = state.peerConnections[peer] else { return 0 }
-            return ids.filter { id in
-                state.connections[id]?.state.isConnected == true
-            }.count
+            Self.connectedCount(for: peer, in: state)
         }
         return activeCount < configuration.limits.maxConnectionsPerPeer
     }
@@ -1054,7 +1188,10 @@ internal final class ConnectionPool: Sendable {
     ) -> Int {
         guard let ids = state.peerConnections[peer] else { return 0 }
         return ids.reduce(into: 0) { count, id in
-            guard id != excludedID, state.connections[id]?.state.isConnected == true else { return }
+            guard id != excludedID,
+                  let managed = state.connections[id],
+                  managed.state.isConnected,
+                  !managed.isDraining else { return }
             count += 1
         }
     }
@@ -1096,6 +1233,9 @@ internal final class ConnectionPool: Sendable {
                 direction: managed.direction,
                 state: managed.state,
                 tagCount: managed.tags.count,
+                livenessScore: managed.livenessScore,
+                lastPingRTT: managed.lastPingRTT,
+                effectiveScore: managed.effectiveScore,
                 isProtected: managed.isProtected,
                 idleDuration: now - managed.lastActivity,
                 connectedDuration: managed.connectedAt.map { now - $0 },
@@ -1139,8 +1279,8 @@ internal final class ConnectionPool: Sendable {
     }
 
     private static func shouldTrimBefore(_ lhs: ManagedConnection, _ rhs: ManagedConnection) -> Bool {
-        if lhs.tags.count != rhs.tags.count {
-            return lhs.tags.count < rhs.tags.count
+        if lhs.effectiveScore != rhs.effectiveScore {
+            return lhs.effectiveScore < rhs.effectiveScore
         }
         if lhs.lastActivity != rhs.lastActivity {
             return lhs.lastActivity < rhs.lastActivity
@@ -46,6 +46,15 @@ public struct ConnectionTrimReport: Sendable {
         /// Number of tags on this connection.
         public let tagCount: Int
 
+        /// Ping-based liveness score in `0...1` (1 until the first ping).
+        public let livenessScore: Double
+
+        /// Round-trip time of the last successful liveness ping, if any.
+        public let lastPingRTT: Duration?
+
+        /// Tag count plus liveness score. Lower scores are trimmed first.
+        public let effectiveScore: Double
+
         /// Whether the connection is currently protected.
         public let isProtected: Bool
 
@@ -0,0 +1,118 @@
+/// EchoProtocol - The echo protocol served by the interop test nodes
+///
+/// Every go and rust interop node serves `/test/echo/1.0.0`: the responder
+/// writes back everything it reads until the initiator closes its write side,
+/// then closes its own. `EchoProtocol` implements both ends over `RawStream`
+/// so tests and demos do not hand-roll the loop. The protocol ID is a
+/// parameter; the default is the interop one.
+
+import Foundation
+import P2PCore
+import NIOCore
+
+/// Errors from `EchoProtocol.echo(peer:protocol:data:on:)`.
+public enum EchoError: Error, Sendable, Equatable {
+    /// The responder sent back more bytes than were sent to it.
+    case overrun(sent: Int, received: Int)
+}
+
+/// Server and client for the echo protocol.
+public enum EchoProtocol {
+
+    /// The protocol ID the go and rust interop nodes register.
+    public static let interopProtocolID = "/test/echo/1.0.0"
+
+    /// Registers an echo responder on `node`.
+    ///
+    /// Each inbound stream is echoed chunk by chunk until the remote closes
+    /// its write side; the responder then closes the stream. A stream that
+    /// fails mid-echo is reset.
+    ///
+    /// - Parameters:
+    ///   - node: The node to serve echo on
+    ///   - protocolID: The protocol to register (default `/test/echo/1.0.0`)
+    ///   - limit: Cap on concurrent echo streams (see `Node.handle`)
+    public static func registerEcho(
+        on node: Node,
+        protocol protocolID: String = interopProtocolID,
+        limit: HandlerStreamLimit? = nil
+    ) async {
+        await node.handleRaw(protocolID, limit: limit) { raw in
+            await serve(raw)
+        }
+    }
+
+    /// Sends `data` to `peer` over a new echo stream and returns what comes
+    /// back.
+    ///
+    /// The payload is written while the reply is read, so payloads larger
+    /// than the muxer's window do not stall; the write side is closed after
+    /// the last byte to tell the responder the payload is complete.
+    ///
+    /// - Parameters:
+    ///   - peer: The peer to echo against
+    ///   - protocolID: The protocol to negotiate (default `/test/echo/1.0.0`)
+    ///   - data: The payload
+    ///   - node: The node to open the stream from
+    /// - Returns: The echoed bytes; equal to `data` for a correct responder
+    /// - Throws: `EchoError.overrun` if more bytes come back than were sent,
+    ///   or the stream's error
+    public static func echo(
+        peer: PeerID,
+        protocol protocolID: String = interopProtocolID,
+        data: Data,
+        on node: Node
+    ) async throws -> Data {
+        let raw = try await node.newRawStream(to: peer, protocol: protocolID)
+        do {
+            let writer = Task {
+                try await raw.write(ByteBuffer(bytes: data))
+                try await raw.closeWrite()
+            }
+
+            var echoed = Data()
+            do {
+                while true {
+                    let chunk = try await raw.read()
+                    if chunk.isEmpty { break }
+                    echoed.append(contentsOf: chunk)
+                    if echoed.count > data.count {
+                        throw EchoError.overrun(sent: data.count, received: echoed.count)
+                    }
+                }
+            } catch {
+                writer.cancel()
+                throw error
+            }
+            try await writer.value
+
+            try await raw.close()
+            return echoed
+        } catch {
+            do {
+                try await raw.reset()
+            } catch {
+                // The stream is already unusable.
+            }
+            throw error
+        }
+    }
+
+    /// Echoes one inbound stream.
+    static func serve(_ raw: RawStream) async {
+        do {
+            while true {
+                let chunk = try await raw.read()
+                if chunk.isEmpty { break }
+                try await raw.write(chunk)
+            }
+            try await raw.close()
+        } catch {
+            do {
+                try await raw.reset()
+            } catch {
+                // The stream is already unusable.
+            }
+        }
+    }
+}
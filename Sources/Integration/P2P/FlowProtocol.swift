@@ -0,0 +1,199 @@
+/// FlowProtocol - The slow-consumer protocol served by the go yamux node
+///
+/// The go yamux interop node serves `/test/flow/1.0.0`: it reads up to 1KB,
+/// sleeps 10ms, writes the bytes back and repeats until the initiator closes
+/// its write side. Because the responder drains slower than a sender can
+/// write, the muxer's receive window fills and the sender has to wait for
+/// window updates. `FlowProtocol` implements that responder with a
+/// configurable chunk size and delay, and a client that pushes a payload
+/// through it and reports throughput and how long writes were held back.
+
+import Foundation
+import P2PCore
+import NIOCore
+
+/// Errors from `FlowProtocol.measure(peer:protocol:bytes:chunkSize:stallThreshold:on:)`.
+public enum FlowError: Error, Sendable, Equatable {
+    /// The echoed bytes differ from what was sent, first at `offset`.
+    case echoMismatch(offset: Int, sent: Int, received: Int)
+}
+
+/// The outcome of one `/test/flow/1.0.0` run.
+public struct FlowReport: Sendable, Equatable {
+    /// Bytes written by the client.
+    public let bytesSent: Int
+
+    /// Time from the first write until the last echoed byte arrived.
+    public let duration: Duration
+
+    /// The longest a single write waited before returning.
+    public let longestWriteWait: Duration
+
+    /// Writes that waited at least the stall threshold, i.e. that were held
+    /// back by flow control rather than completing immediately.
+    public let stalledWrites: Int
+
+    /// Echoed bytes per second over the whole run.
+    public var throughput: Double {
+        let seconds = Double(duration.components.seconds)
+            + Double(duration.components.attoseconds) / 1e18
+        return seconds > 0 ? Double(bytesSent) / seconds : 0
+    }
+}
+
+/// Slow-consumer server and measuring client for the flow-control protocol.
+public enum FlowProtocol {
+
+    /// The protocol ID the go yamux node registers.
+    public static let interopProtocolID = "/test/flow/1.0.0"
+
+    /// The go node's read size.
+    public static let defaultChunkSize = 1024
+
+    /// The go node's delay per chunk.
+    public static let defaultProcessingDelay: Duration = .milliseconds(10)
+
+    /// Registers a slow consumer on `node`.
+    ///
+    /// Each inbound stream is read in pieces of at most `chunkSize` bytes;
+    /// every piece is echoed after `processingDelay`. The stream is closed
+    /// once the remote closes its write side, and reset if it fails.
+    ///
+    /// Throughput settles at `chunkSize` per `processingDelay`, as on the go
+    /// node. Window goes back to the sender when a read is taken from the
+    /// muxer rather than per piece, so it returns in larger steps than go's.
+    ///
+    /// - Parameters:
+    ///   - node: The node to serve on
+    ///   - protocolID: The protocol to register (default `/test/flow/1.0.0`)
+    ///   - chunkSize: The most bytes processed per delay
+    ///   - processingDelay: The pause before each piece is echoed
+    ///   - limit: Cap on concurrent flow streams (see `Node.handle`)
+    public static func registerSlowConsumer(
+        on node: Node,
+        protocol protocolID: String = interopProtocolID,
+        chunkSize: Int = defaultChunkSize,
+        processingDelay: Duration = defaultProcessingDelay,
+        limit: HandlerStreamLimit? = nil
+    ) async {
+        let chunkSize = max(1, chunkSize)
+        await node.handleRaw(protocolID, limit: limit) { raw in
+            await serve(raw, chunkSize: chunkSize, processingDelay: processingDelay)
+        }
+    }
+
+    /// Sends `bytes` bytes to a slow consumer on `peer` and reads them back.
+    ///
+    /// The payload is written in `chunkSize` writes while the echo is read
+    /// concurrently. Each write is timed: one that takes `stallThreshold` or
+    /// longer counts as stalled, which against a slow consumer is the sender
+    /// waiting for window.
+    ///
+    /// - Parameters:
+    ///   - peer: The peer serving the flow protocol
+    ///   - protocolID: The protocol to negotiate (default `/test/flow/1.0.0`)
+    ///   - bytes: The payload size
+    ///   - chunkSize: The size of each write
+    ///   - stallThreshold: The write duration that counts as a stall
+    ///   - node: The node to open the stream from
+    /// - Returns: Throughput and stall figures for the run
+    /// - Throws: `FlowError.echoMismatch` if the echo is not the payload, or
+    ///   the stream's error
+    public static func measure(
+        peer: PeerID,
+        protocol protocolID: String = interopProtocolID,
+        bytes: Int,
+        chunkSize: Int = defaultChunkSize,
+        stallThreshold: Duration = .milliseconds(50),
+        on node: Node
+    ) async throws -> FlowReport {
+        let chunkSize = max(1, chunkSize)
+        let payload = (0..<bytes).map { UInt8(truncatingIfNeeded: $0) }
+        let raw = try await node.newRawStream(to: peer, protocol: protocolID)
+        let clock = ContinuousClock()
+        let start = clock.now
+
+        do {
+            let writer = Task { () -> (longest: Duration, stalled: Int) in
+                var longest: Duration = .zero
+                var stalled = 0
+                var offset = 0
+                while offset < payload.count {
+                    let end = min(offset + chunkSize, payload.count)
+                    let wait = try await clock.measure {
+                        try await raw.write(Array(payload[offset..<end]))
+                    }
+                    longest = max(longest, wait)
+                    if wait >= stallThreshold {
+                        stalled += 1
+                    }
+                    offset = end
+                }
+                try await raw.closeWrite()
+                return (longest, stalled)
+            }
+
+            var echoed: [UInt8] = []
+            echoed.reserveCapacity(payload.count)
+            do {
+                while true {
+                    let chunk = try await raw.read()
+                    if chunk.isEmpty { break }
+                    echoed += chunk
+                    if echoed.count > payload.count {
+                        break
+                    }
+                }
+            } catch {
+                writer.cancel()
+                throw error
+            }
+            let duration = clock.now - start
+            let writes = try await writer.value
+
+            if echoed != payload {
+                let offset = zip(echoed, payload).firstIndex { $0 != $1 } ?? min(echoed.count, payload.count)
+                throw FlowError.echoMismatch(offset: offset, sent: payload.count, received: echoed.count)
+            }
+            try await raw.close()
+
+            return FlowReport(
+                bytesSent: payload.count,
+                duration: duration,
+                longestWriteWait: writes.longest,
+                stalledWrites: writes.stalled
+            )
+        } catch {
+            do {
+                try await raw.reset()
+            } catch {
+                // The stream is already unusable.
+            }
+            throw error
+        }
+    }
+
+    /// Serves one inbound stream.
+    static func serve(_ raw: RawStream, chunkSize: Int, processingDelay: Duration) async {
+        do {
+            while true {
+                let chunk = try await raw.read()
+                if chunk.isEmpty { break }
+                var offset = 0
+                while offset < chunk.count {
+                    let end = min(offset + chunkSize, chunk.count)
+                    try await Task.sleep(for: processingDelay)
+                    try await raw.write(Array(chunk[offset..<end]))
+                    offset = end
+                }
+            }
+            try await raw.close()
+        } catch {
+            do {
+                try await raw.reset()
+            } catch {
+                // The stream is already unusable.
+            }
+        }
+    }
+}
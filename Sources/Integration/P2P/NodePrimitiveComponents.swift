@@ -90,6 +90,10 @@ public extension ServiceNodeComponent {
         Self(servicePrimitive: servicePrimitive.consumesSupportedProtocols())
     }
 
+    func consumesPeerStore() -> Self where ServiceType: PeerStoreConsumer {
+        Self(servicePrimitive: servicePrimitive.consumesPeerStore())
+    }
+
     func activatesOnStart() -> Self {
         Self(servicePrimitive: servicePrimitive.activatesOnStart())
     }
@@ -239,6 +243,10 @@ public extension Service {
         Service(descriptor: descriptor.updating { $0.consumesSupportedProtocols() })
     }
 
+    func consumesPeerStore() -> Service where ServiceType: PeerStoreConsumer {
+        Service(descriptor: descriptor.updating { $0.consumesPeerStore() })
+    }
+
     func activatesOnStart() -> Service {
         Service(descriptor: descriptor.updating { $0.activatesOnStart() })
     }
@@ -411,6 +419,7 @@ public struct Identify: ServiceNodeComponent {
             .consumesLocalIdentity()
             .consumesListenAddresses()
             .consumesSupportedProtocols()
+            .consumesPeerStore()
             .activatesWithStreamOpening()
             .markingDefaultsApplied()
     }
@@ -481,6 +490,11 @@ public struct GossipSub: ServiceNodeComponent {
             .handlesInboundStreams()
             .observesPeers()
             .activatesWithStreamOpening()
+            .postStart { context, service in
+                service.setDialer { address in
+                    _ = try await context.addressDialer.connect(to: address)
+                }
+            }
             .markingDefaultsApplied()
     }
 
@@ -575,6 +589,8 @@ public struct Kademlia: ServiceNodeComponent {
         guard !primitive.defaultsApplied else { return primitive }
         return primitive
             .handlesInboundStreams()
+            .observesPeers()
+            .consumesPeerStore()
             .activatesWithStreamOpening()
             .markingDefaultsApplied()
     }
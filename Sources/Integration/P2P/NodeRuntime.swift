@@ -2,6 +2,7 @@ import Foundation
 import P2PCore
 import P2PDiscovery
 import P2PMux
+import P2PNegotiation
 import P2PProtocols
 
 private let runtimeLogger = Logger(label: "p2p.node.runtime")
@@ -12,6 +13,7 @@ internal actor NodeRuntime {
     private let swarm: Swarm
     nonisolated let pool: ConnectionPool
     nonisolated let dialBackoff: DialBackoff
+    nonisolated let connectednessTracker = ConnectednessTracker()
     nonisolated let listenAddressStore: ListenAddressStore
     nonisolated let advertisedAddressStore: ListenAddressStore
     nonisolated let relayAddressStore = ListenAddressStore()
@@ -70,12 +72,14 @@ internal actor NodeRuntime {
             idleTimeout: runtime.pool.idleTimeout,
             reconnectionPolicy: runtime.pool.reconnectionPolicy,
             maxNegotiatingInboundStreams: runtime.maxNegotiatingInboundStreams,
+            handlerStreamLimit: runtime.handlerStreamLimit,
             connectionGater: runtime.pool.gater,
             connectionResources: connectionResources,
             streamResources: streamResources,
             streamLifecycle: streamLifecycle,
             reconnectPlanner: reconnectPlanner,
-            conflictResolver: conflictResolver
+            conflictResolver: conflictResolver,
+            dnsResolver: runtime.dnsResolver
         ))
         self.swarm = swarm
         self.pool = swarm.pool
@@ -88,9 +92,13 @@ internal actor NodeRuntime {
         self.keyBook = keyBook
     }
 
-    func registerHandler(for protocolID: String, handler: @escaping ProtocolHandler) async {
+    func registerHandler(
+        for protocolID: String,
+        limit: HandlerStreamLimit? = nil,
+        handler: @escaping ProtocolHandler
+    ) async {
         handlers[protocolID] = handler
-        await swarm.registerHandler(for: protocolID, handler: handler)
+        await swarm.registerHandler(for: protocolID, limit: limit, handler: handler)
     }
 
     func supportedProtocols() -> [String] {
@@ -310,6 +318,7 @@ internal actor NodeRuntime {
         activePeerObservers = []
 
         relayAddressStore.clear()
+        connectednessTracker.clear()
 
         eventForwardingTask?.cancel()
         do {
@@ -368,9 +377,41 @@ internal actor NodeRuntime {
         await swarm.closePeer(peer)
     }
 
+    func closeConnection(_ id: ConnectionID) async {
+        guard lifecycleState == .running else { return }
+        await swarm.closeConnection(id)
+    }
+
     func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
+        try await newStream(to: peer, protocol: protocolID, on: nil)
+    }
+
+    func newStream(
+        to peer: PeerID,
+        protocol protocolID: String,
+        on id: ConnectionID?
+    ) async throws -> MuxedStream {
         guard lifecycleState == .running else { throw NodeError.nodeNotRunning }
-        return try await swarm.newStream(to: peer, protocol: protocolID)
+        let advertised = await protoBook.firstSupportedProtocol([protocolID], for: peer) != nil
+        do {
+            return try await swarm.newStream(to: peer, protocol: protocolID, advertised: advertised, on: id)
+        } catch NegotiationError.noAgreement where advertised {
+            // The peer no longer supports what it advertised
+            await protoBook.removeProtocols([protocolID], from: peer)
+            throw NegotiationError.noAgreement
+        }
+    }
+
+    nonisolated func streamNegotiationStats() -> StreamNegotiationStats {
+        swarm.streamNegotiationStats
+    }
+
+    nonisolated func handlerStreamStats() -> [String: ProtocolStreamStats] {
+        swarm.handlerStreamStats
+    }
+
+    nonisolated func ipColocationStats() -> IPColocationStats {
+        swarm.ipColocationStats
     }
 
     func listenAddresses() -> [Multiaddr] {
@@ -385,10 +426,18 @@ internal actor NodeRuntime {
         pool.connection(to: peer)
     }
 
+    nonisolated func connectionID(to peer: PeerID) -> ConnectionID? {
+        pool.activeConnection(to: peer)?.id
+    }
+
     nonisolated func connectionState(of peer: PeerID) -> ConnectionState? {
         pool.connectionState(of: peer)
     }
 
+    nonisolated func connectedness(of peer: PeerID) -> Connectedness {
+        connectednessTracker.connectedness(of: peer, pool: pool, dialBackoff: dialBackoff)
+    }
+
     nonisolated var connectedPeers: [PeerID] {
         pool.connectedPeers
     }
@@ -442,15 +491,20 @@ internal actor NodeRuntime {
                 await observer.peerConnected(peer)
             }
             emit(.peerConnected(peer))
+            reportConnectedness(of: peer)
             trackHealthMonitorTask { await $0.healthMonitor?.startMonitoring(peer: peer) }
         case .peerDisconnected(let peer):
             for observer in activePeerObservers {
                 await observer.peerDisconnected(peer)
             }
             emit(.peerDisconnected(peer))
+            reportConnectedness(of: peer)
             trackHealthMonitorTask { await $0.healthMonitor?.stopMonitoring(peer: peer) }
         case .connection(let connectionEvent):
             emit(.connection(connectionEvent))
+            if let peer = connectionEvent.peer {
+                reportConnectedness(of: peer)
+            }
         case .listenError(let addr, let error):
             emit(.listenError(addr, error))
         case .newListenAddr(let addr):
@@ -461,11 +515,22 @@ internal actor NodeRuntime {
             emit(.dialing(peer))
         case .outgoingConnectionError(let peer, let error):
             emit(.outgoingConnectionError(peer: peer, error: error))
+            if let peer {
+                reportConnectedness(of: peer)
+            }
         case .connectionError(let peer, let error):
             emit(.connectionError(peer, error))
         }
     }
 
+    /// Emits `peerConnectednessChanged` if the peer's connectedness differs
+    /// from the one last emitted.
+    private func reportConnectedness(of peer: PeerID) {
+        if let connectedness = connectednessTracker.update(peer, pool: pool, dialBackoff: dialBackoff) {
+            emit(.peerConnectednessChanged(peer: peer, connectedness: connectedness))
+        }
+    }
+
     private func handleHealthCheckFailed(peer: PeerID) async {
         emit(.connection(.healthCheckFailed(peer: peer)))
         await closePeer(peer)
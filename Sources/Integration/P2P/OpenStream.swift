@@ -0,0 +1,93 @@
+/// OpenStream - Dial, negotiate and retry in one call
+///
+/// `Node.openStream(to:protocol:retry:)` connects to a peer if needed, opens a
+/// stream for a protocol and retries transient failures (refused dials, dial
+/// backoff, connections dropped mid-negotiation) with a backoff between
+/// attempts. Failures that another attempt cannot fix, such as the peer not
+/// supporting the protocol or a different peer answering, are thrown at once.
+
+import P2PCore
+import P2PMux
+import P2PRuntime
+
+extension Node {
+
+    /// Opens a stream to `info.peer` for `protocolID`, connecting first if
+    /// there is no connection yet.
+    ///
+    /// Each attempt dials `info.dialAddresses` in order (ending in
+    /// `/p2p/<peer>` so the handshake checks the identity; addresses naming
+    /// another peer are skipped) or, without addresses, connects through
+    /// `connect(to:)`, then negotiates the protocol on that connection. A
+    /// transient failure closes the connection the attempt used if this call
+    /// dialed it or the failure was the connection's own
+    /// (`RetryPolicy.isConnectionFailure(_:)`), so the next attempt dials
+    /// afresh; the peer's other connections are left alone. After
+    /// `retry.maxAttempts` attempts the last error is thrown.
+    ///
+    /// - Parameters:
+    ///   - info: The peer and the addresses to dial it at
+    ///   - protocolID: The protocol to negotiate
+    ///   - retry: Attempt limit and backoff
+    /// - Returns: The negotiated stream
+    public func openStream(
+        to info: AddrInfo,
+        protocol protocolID: String,
+        retry: RetryPolicy = .default
+    ) async throws -> MuxedStream {
+        var attempt = 0
+        while true {
+            var used: (id: ConnectionID, dialed: Bool)?
+            do {
+                let connection = try await ensureConnection(to: info)
+                used = connection
+                return try await newStream(to: info.peer, protocol: protocolID, on: connection.id)
+            } catch {
+                attempt += 1
+                guard attempt < retry.maxAttempts, RetryPolicy.isTransient(error) else {
+                    throw error
+                }
+                if let used, used.dialed || RetryPolicy.isConnectionFailure(error) {
+                    await closeConnection(used.id)
+                }
+                try await Task.sleep(for: retry.backoff.delay(for: attempt - 1))
+            }
+        }
+    }
+
+    /// Connects to `info.peer` unless already connected.
+    ///
+    /// - Returns: The connection to use and whether it was dialed here
+    private func ensureConnection(to info: AddrInfo) async throws -> (id: ConnectionID, dialed: Bool) {
+        if let id = connectionID(to: info.peer) { return (id, false) }
+        try await dial(info)
+        // Closed again before we could use it
+        guard let id = connectionID(to: info.peer) else {
+            throw NodeError.notConnected(info.peer)
+        }
+        return (id, true)
+    }
+
+    /// Dials `info.addresses` in order, or `connect(to:)` without addresses.
+    private func dial(_ info: AddrInfo) async throws {
+        guard !info.addresses.isEmpty else {
+            try await connect(to: info.peer)
+            return
+        }
+        let addresses = info.dialAddresses
+        guard !addresses.isEmpty else {
+            // Every address names a different peer
+            throw NodeError.noAddressesKnown(info.peer)
+        }
+        var lastError: any Error = NodeError.noSuitableTransport
+        for address in addresses {
+            do {
+                try await connect(to: address)
+                return
+            } catch {
+                lastError = error
+            }
+        }
+        throw lastError
+    }
+}
@@ -207,6 +207,9 @@ public struct NodeConfiguration: Sendable {
     /// Default: 128 (rust-libp2p default).
     public var maxNegotiatingInboundStreams: Int { runtime.maxNegotiatingInboundStreams }
 
+    /// Limit for protocol handlers registered without their own (nil: unlimited).
+    public var handlerStreamLimit: HandlerStreamLimit? { runtime.handlerStreamLimit }
+
     /// Explicitly registered services.
     public let services: ServicePipeline
 
@@ -241,6 +244,17 @@ public struct NodeConfiguration: Sendable {
         security.contains { $0.protocolID == plaintextSecurityProtocolID }
     }
 
+    /// Points the Yamux muxers that have no exporter of their own at `metrics`.
+    static func instrument(_ muxers: [any Muxer], metrics: (any MetricsExporter)?) -> [any Muxer] {
+        guard let metrics else { return muxers }
+        return muxers.map { muxer in
+            guard let yamux = muxer as? YamuxMuxer, yamux.configuration.metrics == nil else {
+                return muxer
+            }
+            return yamux.withMetrics(metrics)
+        }
+    }
+
     public init(
         runtime: RuntimeConfiguration = .init(),
         healthCheck: HealthMonitorConfiguration? = .default,
@@ -364,6 +378,9 @@ public struct NodeConfiguration: Sendable {
         privateNetwork: PnetConfiguration? = nil,
         productionAuditPolicy: NodeProductionAuditPolicy = .permissive,
         maxNegotiatingInboundStreams: Int = 128,
+        handlerStreamLimit: HandlerStreamLimit? = nil,
+        dnsResolver: (any DNSResolver)? = SystemDNSResolver(),
+        metrics: (any MetricsExporter)? = nil,
         services: ServicePipeline = .empty,
         discovery: DiscoveryPipeline? = nil
     ) {
@@ -380,12 +397,14 @@ public struct NodeConfiguration: Sendable {
                 ? ConnectionProviders.compose(
                     transports: transports,
                     security: security,
-                    muxers: muxers,
+                    muxers: Self.instrument(muxers, metrics: metrics),
                     protector: protector
                 )
                 : connectionProviders,
             pool: pool,
-            maxNegotiatingInboundStreams: maxNegotiatingInboundStreams
+            maxNegotiatingInboundStreams: maxNegotiatingInboundStreams,
+            handlerStreamLimit: handlerStreamLimit,
+            dnsResolver: dnsResolver
         )
         self.healthCheck = healthCheck
         self.discoveryConfig = discoveryConfig
@@ -555,6 +574,10 @@ public enum NodeEvent: Sendable {
 
     /// An outgoing connection attempt failed.
     case outgoingConnectionError(peer: PeerID?, error: any Error)
+
+    /// A peer's connectedness changed, e.g. from `connected` to `canConnect`
+    /// when its last connection closed.
+    case peerConnectednessChanged(peer: PeerID, connectedness: Connectedness)
 }
 
 // MARK: - Node
@@ -755,6 +778,7 @@ public actor Node:
         resourceManager: any ResourceManager = DefaultResourceManager(configuration: .default),
         traversal: TraversalConfiguration? = nil,
         maxNegotiatingInboundStreams: Int = 128,
+        metrics: (any MetricsExporter)? = nil,
         @NodeGroupBuilder _ content: () -> NodeGroup = { NodeGroup() }
     ) throws {
         try self.init(
@@ -765,7 +789,7 @@ public actor Node:
                     ? ConnectionProviders.compose(
                         transports: transports,
                         security: security,
-                        muxers: muxers
+                        muxers: NodeConfiguration.instrument(muxers, metrics: metrics)
                     )
                     : connectionProviders,
                 pool: pool,
@@ -790,29 +814,60 @@ public actor Node:
     ///
     /// - Parameters:
     ///   - protocolID: The protocol identifier (e.g., "/chat/1.0.0")
+    ///   - limit: Cap on concurrent inbound streams for this protocol; streams
+    ///     past it queue or are reset. `nil` applies
+    ///     `NodeConfiguration.handlerStreamLimit`.
     ///   - handler: The handler function for incoming streams
     public func handle(
         _ protocolID: String,
+        limit: HandlerStreamLimit? = nil,
         handler: @escaping ProtocolHandler
     ) async {
         guard lifecycleState != .stopped else { return }
-        await runtime.registerHandler(for: protocolID, handler: handler)
+        await runtime.registerHandler(for: protocolID, limit: limit, handler: handler)
     }
 
     /// Registers a simple protocol handler that only needs the stream.
     ///
     /// - Parameters:
     ///   - protocolID: The protocol identifier (e.g., "/chat/1.0.0")
+    ///   - limit: Cap on concurrent inbound streams (see `handle`)
     ///   - handler: The handler function for incoming streams
     public func handleStream(
         _ protocolID: String,
+        limit: HandlerStreamLimit? = nil,
         handler: @escaping @Sendable (MuxedStream) async -> Void
     ) async {
         guard lifecycleState != .stopped else { return }
         let wrappedHandler: ProtocolHandler = { context in
             await handler(context.stream)
         }
-        await runtime.registerHandler(for: protocolID, handler: wrappedHandler)
+        await runtime.registerHandler(for: protocolID, limit: limit, handler: wrappedHandler)
+    }
+
+    /// Registers a handler that receives negotiated streams as raw bytes.
+    ///
+    /// The counterpart of `newRawStream(to:protocol:)`: no codec sits between
+    /// the handler and the stream, so it can speak ad-hoc framing.
+    ///
+    /// - Parameters:
+    ///   - protocolID: The protocol identifier (e.g., "/test/raw/1.0.0")
+    ///   - limit: Cap on concurrent inbound streams (see `handle`)
+    ///   - handler: The handler function for incoming raw streams
+    public func handleRaw(
+        _ protocolID: String,
+        limit: HandlerStreamLimit? = nil,
+        handler: @escaping @Sendable (RawStream) async -> Void
+    ) async {
+        guard lifecycleState != .stopped else { return }
+        let wrappedHandler: ProtocolHandler = { context in
+            await handler(RawStream(
+                stream: context.stream,
+                protocolID: context.protocolID,
+                remotePeer: context.remotePeer
+            ))
+        }
+        await runtime.registerHandler(for: protocolID, limit: limit, handler: wrappedHandler)
     }
 
     // MARK: - Lifecycle
@@ -914,22 +969,61 @@ public actor Node:
         await runtime.closePeer(peer)
     }
 
+    /// Closes one connection, leaving the peer's others open.
+    func closeConnection(_ id: ConnectionID) async {
+        guard lifecycleState == .running else { return }
+        await runtime.closeConnection(id)
+    }
+
     /// Opens a stream to a peer with the given protocol.
     public func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
         guard lifecycleState == .running else { throw NodeError.nodeNotRunning }
         return try await runtime.newStream(to: peer, protocol: protocolID)
     }
 
+    /// Opens a stream on one connection to a peer, failing with
+    /// `notConnected` if that connection is gone.
+    func newStream(
+        to peer: PeerID,
+        protocol protocolID: String,
+        on id: ConnectionID
+    ) async throws -> MuxedStream {
+        guard lifecycleState == .running else { throw NodeError.nodeNotRunning }
+        return try await runtime.newStream(to: peer, protocol: protocolID, on: id)
+    }
+
+    /// Opens a stream to a peer and returns it as raw bytes once `protocolID`
+    /// is negotiated.
+    ///
+    /// Reads and writes go straight to the negotiated stream, for harnesses
+    /// that implement their own framing. The peer side registers with
+    /// `handleRaw(_:handler:)` or any other handler.
+    public func newRawStream(to peer: PeerID, protocol protocolID: String) async throws -> RawStream {
+        let stream = try await newStream(to: peer, protocol: protocolID)
+        return RawStream(stream: stream, protocolID: protocolID, remotePeer: peer)
+    }
+
     /// Returns the connection to a peer if connected.
     public func connection(to peer: PeerID) -> MuxedConnection? {
         runtime.connection(to: peer)
     }
 
+    /// Returns the ID of the connection `connection(to:)` would return.
+    func connectionID(to peer: PeerID) -> ConnectionID? {
+        runtime.connectionID(to: peer)
+    }
+
     /// Returns the connection state for a peer.
     public func connectionState(of peer: PeerID) -> ConnectionState? {
         runtime.connectionState(of: peer)
     }
 
+    /// Returns how reachable a peer is, from its open connections and recent
+    /// dial history.
+    public func connectedness(of peer: PeerID) -> Connectedness {
+        runtime.connectedness(of: peer)
+    }
+
     /// Returns all connected peers.
     public var connectedPeers: [PeerID] {
         runtime.connectedPeers
@@ -950,6 +1044,27 @@ public actor Node:
         runtime.connectionTrimReport()
     }
 
+    /// Returns counters for outbound stream negotiation, including the round
+    /// trips spent. Protocols the peer is known to support take one round
+    /// trip instead of two.
+    public func streamNegotiationStats() -> StreamNegotiationStats {
+        runtime.streamNegotiationStats()
+    }
+
+    /// Returns inbound handler counters per protocol: streams running, streams
+    /// waiting for a slot, and streams reset because the handler was at its
+    /// `HandlerStreamLimit`.
+    public func handlerStreamStats() -> [String: ProtocolStreamStats] {
+        runtime.handlerStreamStats()
+    }
+
+    /// Returns inbound connection counts per remote IP and subnet under
+    /// `PoolConfiguration.ipColocation`, including upgrades in progress, and
+    /// how many connections its limits rejected.
+    public func ipColocationStats() -> IPColocationStats {
+        runtime.ipColocationStats()
+    }
+
     // MARK: - Tagging & Protection
 
     /// Adds a tag to a peer's connections.
@@ -1010,6 +1125,9 @@ public enum NodeError: Error, Sendable {
     /// A dial was suppressed because the peer is currently in dial backoff
     /// after recent failures. Retry after the backoff window expires.
     case dialBackedOff(PeerID)
+    /// The dialed address named a peer (`/p2p/<id>`) but the security
+    /// handshake authenticated a different one. The connection was closed.
+    case identityMismatch(expected: PeerID, actual: PeerID)
 }
 
 // MARK: - AsyncSemaphore (C2)
@@ -0,0 +1,167 @@
+/// RawStream - Negotiated stream bytes for ad-hoc protocols
+///
+/// `Node.newRawStream(to:protocol:)` and `Node.handleRaw(_:handler:)` hand out
+/// the stream left after multistream-select as a plain byte duplex. Reads are
+/// not tied to muxer frame boundaries and writes are flushed immediately, so
+/// test harnesses can implement their own framing (length prefixes, fixed-size
+/// records) directly against a Swift peer.
+///
+/// `MultistreamSelect.negotiate(on:protocols:role:)` runs a nested
+/// multistream-select over such a stream, for protocols that pick a
+/// sub-protocol in-band after the stream itself was negotiated.
+
+import Synchronization
+import P2PCore
+import P2PMux
+import P2PNegotiation
+import NIOCore
+
+/// Errors from `RawStream` reads.
+public enum RawStreamError: Error, Sendable, Equatable {
+    /// The remote closed its write side before `expected` bytes arrived.
+    case endOfStream(received: Int, expected: Int)
+}
+
+/// A negotiated stream read and written as raw bytes.
+///
+/// Reads are meant for one reader at a time; concurrent `readExactly` calls
+/// may interleave their bytes.
+public final class RawStream: Sendable {
+
+    /// The underlying negotiated stream.
+    public let stream: MuxedStream
+
+    /// The protocol negotiated for the stream.
+    public let protocolID: String
+
+    /// The remote peer.
+    public let remotePeer: PeerID
+
+    /// Bytes read from the stream but not yet returned.
+    private let pending: Mutex<ByteBuffer>
+
+    init(stream: MuxedStream, protocolID: String, remotePeer: PeerID, buffered: ByteBuffer = ByteBuffer()) {
+        self.stream = stream
+        self.protocolID = protocolID
+        self.remotePeer = remotePeer
+        self.pending = Mutex(buffered)
+    }
+
+    /// Returns the next available bytes, or an empty array once the remote has
+    /// closed its write side.
+    public func read() async throws -> [UInt8] {
+        let buffered = pending.withLock { buffer -> [UInt8]? in
+            guard buffer.readableBytes > 0 else { return nil }
+            return buffer.readBytes(length: buffer.readableBytes)
+        }
+        if let buffered {
+            return buffered
+        }
+        guard let chunk = try await nextChunk() else { return [] }
+        return Array(chunk.readableBytesView)
+    }
+
+    /// Reads exactly `count` bytes.
+    ///
+    /// - Throws: `RawStreamError.endOfStream` if the stream reports
+    ///   end-of-stream first (muxers that throw on a closed stream throw
+    ///   their own error). The bytes received so far stay buffered for `read()`.
+    public func readExactly(_ count: Int) async throws -> [UInt8] {
+        while true {
+            let (bytes, available): ([UInt8]?, Int) = pending.withLock { buffer in
+                guard buffer.readableBytes >= count else {
+                    return (nil, buffer.readableBytes)
+                }
+                return (buffer.readBytes(length: count), count)
+            }
+            if let bytes {
+                return bytes
+            }
+
+            guard var chunk = try await nextChunk() else {
+                throw RawStreamError.endOfStream(received: available, expected: count)
+            }
+            pending.withLock { $0.writeBuffer(&chunk) }
+        }
+    }
+
+    /// Writes `bytes` and flushes them to the muxer.
+    public func write(_ bytes: [UInt8]) async throws {
+        try await write(ByteBuffer(bytes: bytes))
+    }
+
+    /// Writes `buffer` and flushes it to the muxer.
+    public func write(_ buffer: ByteBuffer) async throws {
+        try await stream.write(buffer)
+        try await stream.flush()
+    }
+
+    /// Closes the write side; the remote reads end-of-stream.
+    public func closeWrite() async throws {
+        try await stream.closeWrite()
+    }
+
+    /// Closes the stream in both directions.
+    public func close() async throws {
+        try await stream.close()
+    }
+
+    /// Resets the stream.
+    public func reset() async throws {
+        try await stream.reset()
+    }
+
+    /// Reads one chunk from the stream, `nil` at end-of-stream.
+    private func nextChunk() async throws -> ByteBuffer? {
+        let chunk = try await stream.read()
+        return chunk.readableBytes == 0 ? nil : chunk
+    }
+}
+
+// MARK: - Nested negotiation
+
+extension MultistreamSelect {
+
+    /// Runs multistream-select over a stream that is already open, such as a
+    /// relay STOP stream or a router protocol that dispatches to
+    /// sub-protocols.
+    ///
+    /// The initiator proposes `protocols` in order; the responder accepts the
+    /// first proposal it finds in `protocols`, bounded by `limits`. Bytes the
+    /// peer sent after the agreement are kept, so the returned stream reads
+    /// them first.
+    ///
+    /// - Parameters:
+    ///   - stream: The stream to negotiate on; read and write through the
+    ///     returned stream afterwards
+    ///   - protocols: Protocols to propose (initiator) or accept (responder)
+    ///   - role: Which side of the exchange this end plays
+    ///   - limits: Responder-side bounds (ignored for the initiator)
+    /// - Returns: A `RawStream` over the same muxed stream whose `protocolID`
+    ///   is the agreed protocol
+    /// - Throws: `NegotiationError.noAgreement` if the initiator runs out of
+    ///   proposals, or any other `NegotiationError` / stream error
+    public static func negotiate(
+        on stream: RawStream,
+        protocols: [String],
+        role: NegotiationRole,
+        limits: HandleLimits = .default
+    ) async throws -> RawStream {
+        let read: () async throws -> ByteBuffer = { ByteBuffer(bytes: try await stream.read()) }
+        let write: (ByteBuffer) async throws -> Void = { try await stream.write($0) }
+
+        let result: NegotiationResult
+        switch role {
+        case .initiator:
+            result = try await negotiate(protocols: protocols, read: read, write: write)
+        case .responder:
+            result = try await handle(supported: protocols, limits: limits, read: read, write: write)
+        }
+        return RawStream(
+            stream: stream.stream,
+            protocolID: result.protocolID,
+            remotePeer: stream.remotePeer,
+            buffered: result.remainderBuffer
+        )
+    }
+}
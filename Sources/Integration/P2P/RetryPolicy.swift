@@ -0,0 +1,98 @@
+/// RetryPolicy - Attempt limit and backoff for Node.openStream
+///
+/// Also decides which failures are worth another attempt and which of them
+/// leave the connection they happened on unusable.
+
+import P2PCore
+import P2PNegotiation
+import P2PRuntime
+
+/// How `Node.openStream(to:protocol:retry:)` retries transient failures.
+public struct RetryPolicy: Sendable {
+
+    /// Total number of attempts, including the first one.
+    public var maxAttempts: Int
+
+    /// Delay before each retry; attempt 0 is the delay after the first failure.
+    public var backoff: BackoffStrategy
+
+    /// Five attempts, 100ms apart at first, doubling up to 2 seconds.
+    public static let `default` = RetryPolicy(
+        maxAttempts: 5,
+        backoff: BackoffStrategy(
+            kind: .exponential(base: .milliseconds(100), multiplier: 2.0, max: .seconds(2)),
+            jitter: 0.1
+        )
+    )
+
+    /// A single attempt.
+    public static let none = RetryPolicy(maxAttempts: 1, backoff: .none)
+
+    /// Creates a retry policy.
+    ///
+    /// - Parameters:
+    ///   - maxAttempts: Total attempts, at least 1
+    ///   - backoff: Delay calculation between attempts
+    public init(maxAttempts: Int, backoff: BackoffStrategy = .default) {
+        precondition(maxAttempts >= 1, "maxAttempts must be at least 1")
+        self.maxAttempts = maxAttempts
+        self.backoff = backoff
+    }
+
+    /// Whether another attempt may succeed after `error`.
+    ///
+    /// Cancellation, a stopped node, gating, identity and self-dial failures,
+    /// missing transports and protocol negotiation refusals are final;
+    /// anything else is treated as a network hiccup.
+    public static func isTransient(_ error: any Error) -> Bool {
+        switch error {
+        case is CancellationError:
+            return false
+        case let error as NodeError:
+            switch error {
+            case .nodeNotRunning, .selfDialNotAllowed, .identityMismatch,
+                 .connectionGated, .noSuitableTransport, .noAddressesKnown:
+                return false
+            default:
+                return true
+            }
+        case let error as NegotiationError:
+            switch error {
+            case .noAgreement, .protocolMismatch:
+                return false
+            default:
+                return true
+            }
+        default:
+            return true
+        }
+    }
+
+    /// Whether `error` points at the connection itself rather than the
+    /// stream, so the next attempt should not reuse that connection.
+    ///
+    /// Lost connections, closed sessions, negotiation timeouts and muxer or
+    /// transport errors count; limits, refusals and cancellation do not.
+    public static func isConnectionFailure(_ error: any Error) -> Bool {
+        switch error {
+        case is CancellationError:
+            return false
+        case let error as NodeError:
+            switch error {
+            case .notConnected, .streamClosed:
+                return true
+            default:
+                return false
+            }
+        case let error as NegotiationError:
+            switch error {
+            case .negotiationTimeout:
+                return true
+            default:
+                return false
+            }
+        default:
+            return true
+        }
+    }
+}
@@ -15,14 +15,22 @@ internal struct DeterministicConnectionConflictResolver: ConnectionConflictResol
         localPeerID: PeerID,
         remotePeerID: PeerID
     ) -> [ManagedConnection] {
-        guard connections.count >= 2 else { return [] }
+        // A direct connection always beats a relay one: once a direct path is
+        // up, every limited connection is a duplicate.
+        let direct = connections.filter { !$0.isLimited }
+        let relayed = direct.isEmpty ? [] : connections.filter(\.isLimited)
+        let connections = direct.isEmpty ? connections : direct
+        guard connections.count >= 2 else { return relayed }
 
-        let winningDirection: ConnectionDirection = localPeerID < remotePeerID ? .outbound : .inbound
+        // Compare handshake roles, not dial directions: a TCP simultaneous
+        // open is outbound on both sides, but exactly one side is initiator,
+        // so both peers pick the same winner.
+        let winningRole: SecurityRole = localPeerID < remotePeerID ? .initiator : .responder
         var winner: ManagedConnection?
         var losers: [ManagedConnection] = []
 
         for connection in connections {
-            if connection.direction == winningDirection && winner == nil {
+            if connection.securityRole == winningRole && winner == nil {
                 winner = connection
             } else {
                 losers.append(connection)
@@ -34,6 +42,6 @@ internal struct DeterministicConnectionConflictResolver: ConnectionConflictResol
             _ = losers.removeFirst()
         }
 
-        return losers
+        return losers + relayed
     }
 }
@@ -0,0 +1,106 @@
+/// IPColocationLimiter - Admission of inbound connections per IP and subnet
+///
+/// An accepted connection takes a slot under `IPColocationPolicy` before its
+/// upgrade starts and gives it back once the upgrade is over. Established
+/// inbound connections are counted from the pool on every check, so the
+/// limiter only tracks the handshakes in progress. A connection past a limit
+/// is rejected with `ResourceError.limitExceeded` and counted.
+
+import Synchronization
+import P2PCore
+import P2PRuntime
+
+/// Inbound connection counts per remote IP and subnet.
+public struct IPColocationStats: Sendable, Equatable {
+    /// Inbound connections (established or upgrading) per remote IP.
+    public var perIP: [String: Int] = [:]
+
+    /// Inbound connections (established or upgrading) per subnet, keyed in
+    /// CIDR form.
+    public var perSubnet: [String: Int] = [:]
+
+    /// Connections rejected because an IP or subnet was at its limit.
+    public var rejected: Int = 0
+
+    public init(perIP: [String: Int] = [:], perSubnet: [String: Int] = [:], rejected: Int = 0) {
+        self.perIP = perIP
+        self.perSubnet = perSubnet
+        self.rejected = rejected
+    }
+}
+
+internal final class IPColocationLimiter: Sendable {
+
+    private struct State: Sendable {
+        var upgrading: [IPColocationKey: Int] = [:]
+        var rejected = 0
+    }
+
+    let policy: IPColocationPolicy
+    private let state = Mutex(State())
+
+    init(policy: IPColocationPolicy) {
+        self.policy = policy
+    }
+
+    /// Takes a slot for an inbound connection from `address`.
+    ///
+    /// - Parameter established: Remote addresses of the established inbound
+    ///   connections.
+    /// - Returns: The key to pass to `release` once the upgrade is over, or
+    ///   `nil` when the address is not limited.
+    /// - Throws: `ResourceError.limitExceeded` when the IP or its subnet is
+    ///   at its limit.
+    func reserve(_ address: Multiaddr, established: [Multiaddr]) throws -> IPColocationKey? {
+        guard let key = policy.key(for: address) else { return nil }
+        let establishedKeys = established.compactMap { policy.key(for: $0) }
+
+        try state.withLock { state in
+            let counts = Self.counts(established: establishedKeys, upgrading: state.upgrading)
+            if let max = policy.maxPerIP, counts.perIP[key.ip, default: 0] >= max {
+                state.rejected += 1
+                throw ResourceError.limitExceeded(scope: "ip:\(key.ip)", resource: "inboundConnections")
+            }
+            if let max = policy.maxPerSubnet, counts.perSubnet[key.subnet, default: 0] >= max {
+                state.rejected += 1
+                throw ResourceError.limitExceeded(scope: "subnet:\(key.subnet)", resource: "inboundConnections")
+            }
+            state.upgrading[key, default: 0] += 1
+        }
+        return key
+    }
+
+    /// Gives back the slot taken by `reserve`.
+    func release(_ key: IPColocationKey) {
+        state.withLock { state in
+            guard let count = state.upgrading[key] else { return }
+            state.upgrading[key] = count > 1 ? count - 1 : nil
+        }
+    }
+
+    /// Current counts, given the established inbound connections.
+    func stats(established: [Multiaddr]) -> IPColocationStats {
+        let establishedKeys = established.compactMap { policy.key(for: $0) }
+        return state.withLock { state in
+            var stats = Self.counts(established: establishedKeys, upgrading: state.upgrading)
+            stats.rejected = state.rejected
+            return stats
+        }
+    }
+
+    private static func counts(
+        established: [IPColocationKey],
+        upgrading: [IPColocationKey: Int]
+    ) -> IPColocationStats {
+        var stats = IPColocationStats()
+        for key in established {
+            stats.perIP[key.ip, default: 0] += 1
+            stats.perSubnet[key.subnet, default: 0] += 1
+        }
+        for (key, count) in upgrading {
+            stats.perIP[key.ip, default: 0] += count
+            stats.perSubnet[key.subnet, default: 0] += count
+        }
+        return stats
+    }
+}
@@ -0,0 +1,82 @@
+/// ProtocolNegotiationCache - Per-peer protocols known to be supported
+///
+/// An outbound stream for a protocol the peer is known to support skips the
+/// interactive multistream-select exchange: the header and the single proposal
+/// are sent together (V1-Lazy), one round trip instead of two. go-libp2p does
+/// the same once Identify has put the peer's protocols in its peerstore.
+/// Knowledge comes from successful negotiations and from the caller (the
+/// protocol book Identify fills). It is dropped when the peer disconnects or
+/// rejects an optimistic proposal.
+
+import Synchronization
+import P2PCore
+
+/// Counters for outbound stream protocol negotiation.
+public struct StreamNegotiationStats: Sendable, Equatable {
+    /// Outbound negotiations attempted.
+    public var negotiations: Int = 0
+
+    /// Negotiations that sent the proposal together with the header.
+    public var optimisticNegotiations: Int = 0
+
+    /// Optimistic proposals the peer answered with `na`.
+    public var optimisticRejections: Int = 0
+
+    /// Round trips spent negotiating: two per interactive negotiation (header,
+    /// then proposal), one per optimistic one.
+    public var roundTrips: Int = 0
+
+    public init() {}
+
+    /// Mean round trips per negotiation, 0 before the first one.
+    public var averageRoundTrips: Double {
+        negotiations == 0 ? 0 : Double(roundTrips) / Double(negotiations)
+    }
+}
+
+internal final class ProtocolNegotiationCache: Sendable {
+
+    private struct State: Sendable {
+        var known: [PeerID: Set<String>] = [:]
+        var stats = StreamNegotiationStats()
+    }
+
+    private let state = Mutex(State())
+
+    /// Whether a negotiation with the peer has agreed on `protocolID` since it connected.
+    func isKnown(_ protocolID: String, for peer: PeerID) -> Bool {
+        state.withLock { $0.known[peer]?.contains(protocolID) ?? false }
+    }
+
+    func recordSupported(_ protocolID: String, for peer: PeerID) {
+        state.withLock { _ = $0.known[peer, default: []].insert(protocolID) }
+    }
+
+    func recordRejected(_ protocolID: String, for peer: PeerID) {
+        state.withLock { state in
+            state.known[peer]?.remove(protocolID)
+            state.stats.optimisticRejections += 1
+        }
+    }
+
+    /// Counts one negotiation attempt, whatever its outcome.
+    func recordNegotiation(optimistic: Bool) {
+        state.withLock { state in
+            state.stats.negotiations += 1
+            if optimistic {
+                state.stats.optimisticNegotiations += 1
+                state.stats.roundTrips += 1
+            } else {
+                state.stats.roundTrips += 2
+            }
+        }
+    }
+
+    func forgetPeer(_ peer: PeerID) {
+        state.withLock { _ = $0.known.removeValue(forKey: peer) }
+    }
+
+    var stats: StreamNegotiationStats {
+        state.withLock { $0.stats }
+    }
+}
@@ -0,0 +1,149 @@
+/// ProtocolStreamLimiter - Admission of inbound streams to protocol handlers
+///
+/// Each negotiated inbound stream takes a slot of its protocol's
+/// `HandlerStreamLimit` before its handler runs and gives it back when the
+/// handler returns. A stream that finds no free slot waits in FIFO order
+/// (`.queue`) or is refused (`.reject`, or a full queue); the Swarm resets
+/// refused streams and they are counted as rejections for the protocol.
+
+import Synchronization
+import P2PRuntime
+
+/// Handler admission counters for one protocol.
+public struct ProtocolStreamStats: Sendable, Equatable {
+    /// Streams whose handler is running.
+    public var active: Int = 0
+
+    /// Streams waiting for a free handler slot.
+    public var queued: Int = 0
+
+    /// Streams reset because the handler was at its limit.
+    public var rejected: Int = 0
+
+    public init(active: Int = 0, queued: Int = 0, rejected: Int = 0) {
+        self.active = active
+        self.queued = queued
+        self.rejected = rejected
+    }
+}
+
+internal final class ProtocolStreamLimiter: Sendable {
+
+    private struct State: Sendable {
+        var limits: [String: HandlerStreamLimit] = [:]
+        var stats: [String: ProtocolStreamStats] = [:]
+        var waiters: [String: [CheckedContinuation<Bool, Never>]] = [:]
+        /// Once drained (at shutdown), waiters are refused and nothing new waits.
+        var isDrained = false
+    }
+
+    private let defaultLimit: HandlerStreamLimit?
+    private let state = Mutex(State())
+
+    init(defaultLimit: HandlerStreamLimit?) {
+        self.defaultLimit = defaultLimit
+    }
+
+    /// Sets the limit for `protocolID`; `nil` falls back to the default.
+    func setLimit(_ limit: HandlerStreamLimit?, for protocolID: String) {
+        state.withLock { $0.limits[protocolID] = limit }
+    }
+
+    /// The limit in force for `protocolID`, if any.
+    func limit(for protocolID: String) -> HandlerStreamLimit? {
+        state.withLock { $0.limits[protocolID] } ?? defaultLimit
+    }
+
+    /// Takes a handler slot for `protocolID`, waiting for one if the limit
+    /// allows queueing.
+    ///
+    /// - Returns: `true` when the handler may run (call `release` after it
+    ///   returns); `false` when the stream is refused.
+    func acquire(_ protocolID: String) async -> Bool {
+        enum Admission {
+            case admitted
+            case refused
+            case wait
+        }
+
+        let admission: Admission = state.withLock { state in
+            let limit = state.limits[protocolID] ?? defaultLimit
+            guard let limit else {
+                state.stats[protocolID, default: ProtocolStreamStats()].active += 1
+                return .admitted
+            }
+            var stats = state.stats[protocolID, default: ProtocolStreamStats()]
+            defer { state.stats[protocolID] = stats }
+
+            if stats.active < limit.maxConcurrentStreams {
+                stats.active += 1
+                return .admitted
+            }
+            if case .queue(let maxQueued) = limit.overflow, !state.isDrained, stats.queued < maxQueued {
+                stats.queued += 1
+                return .wait
+            }
+            stats.rejected += 1
+            return .refused
+        }
+
+        switch admission {
+        case .admitted:
+            return true
+        case .refused:
+            return false
+        case .wait:
+            return await withCheckedContinuation { continuation in
+                let refuseImmediately = state.withLock { state -> Bool in
+                    if state.isDrained {
+                        state.stats[protocolID]?.queued -= 1
+                        return true
+                    }
+                    state.waiters[protocolID, default: []].append(continuation)
+                    return false
+                }
+                if refuseImmediately {
+                    continuation.resume(returning: false)
+                }
+            }
+        }
+    }
+
+    /// Returns a slot taken by `acquire`, handing it to the oldest waiter.
+    func release(_ protocolID: String) {
+        let waiter: CheckedContinuation<Bool, Never>? = state.withLock { state in
+            if var waiters = state.waiters[protocolID], !waiters.isEmpty {
+                // The slot passes to the waiter; `active` is unchanged
+                let waiter = waiters.removeFirst()
+                state.waiters[protocolID] = waiters.isEmpty ? nil : waiters
+                state.stats[protocolID]?.queued -= 1
+                return waiter
+            }
+            state.stats[protocolID]?.active -= 1
+            return nil
+        }
+        waiter?.resume(returning: true)
+    }
+
+    /// Counters per protocol that has seen an inbound stream.
+    var stats: [String: ProtocolStreamStats] {
+        state.withLock { $0.stats }
+    }
+
+    /// Refuses every waiting stream and stops queueing. Called at shutdown.
+    func drain() {
+        let waiters: [CheckedContinuation<Bool, Never>] = state.withLock { state in
+            state.isDrained = true
+            var pending: [CheckedContinuation<Bool, Never>] = []
+            for (protocolID, protocolWaiters) in state.waiters {
+                state.stats[protocolID]?.queued -= protocolWaiters.count
+                pending += protocolWaiters
+            }
+            state.waiters.removeAll()
+            return pending
+        }
+        for waiter in waiters {
+            waiter.resume(returning: false)
+        }
+    }
+}
@@ -7,12 +7,21 @@ import P2PProtocols
 private let streamLifecycleLogger = Logger(label: "p2p.swarm.stream-lifecycle")
 
 internal protocol StreamLifecycleCoordinator: Sendable {
+    /// Opens a stream and negotiates `protocolID` on it. When `advertised`
+    /// or an earlier negotiation says the peer supports the protocol, the
+    /// proposal is sent with the multistream header (one round trip).
     func openOutboundStream(
         on connection: MuxedConnection,
         peer: PeerID,
-        protocolID: String
+        protocolID: String,
+        advertised: Bool
     ) async throws -> MuxedStream
 
+    /// Forgets what negotiations with the peer have learned.
+    func peerDisconnected(_ peer: PeerID)
+
+    var negotiationStats: StreamNegotiationStats { get }
+
     func negotiateInboundStream(
         _ stream: MuxedStream,
         supportedProtocols: [String],
@@ -25,15 +34,25 @@ internal protocol StreamLifecycleCoordinator: Sendable {
 
 internal struct DefaultStreamLifecycleCoordinator: StreamLifecycleCoordinator {
     private let resources: any StreamResourceAccounting
+    private let negotiationCache = ProtocolNegotiationCache()
 
     init(resources: any StreamResourceAccounting) {
         self.resources = resources
     }
 
+    func peerDisconnected(_ peer: PeerID) {
+        negotiationCache.forgetPeer(peer)
+    }
+
+    var negotiationStats: StreamNegotiationStats {
+        negotiationCache.stats
+    }
+
     func openOutboundStream(
         on connection: MuxedConnection,
         peer: PeerID,
-        protocolID: String
+        protocolID: String,
+        advertised: Bool
     ) async throws -> MuxedStream {
         // Reserve the outbound stream against the protocol scope: the protocol
         // ID is known up front for an outbound dial, so per-protocol limits are
@@ -56,14 +75,28 @@ internal struct DefaultStreamLifecycleCoordinator: StreamLifecycleCoordinator {
         }
 
         let reader = BufferedStreamReader(stream: stream)
+        let optimistic = advertised || negotiationCache.isKnown(protocolID, for: peer)
         let result: NegotiationResult
         do {
-            result = try await MultistreamSelect.negotiate(
-                protocols: [protocolID],
-                read: { try await reader.readMessage() },
-                write: { try await stream.write($0) }
-            )
+            if optimistic {
+                result = try await MultistreamSelect.negotiateLazy(
+                    protocols: [protocolID],
+                    read: { try await reader.readMessage() },
+                    write: { try await stream.write($0) }
+                )
+            } else {
+                result = try await MultistreamSelect.negotiate(
+                    protocols: [protocolID],
+                    read: { try await reader.readMessage() },
+                    write: { try await stream.write($0) }
+                )
+            }
+            negotiationCache.recordNegotiation(optimistic: optimistic)
         } catch {
+            negotiationCache.recordNegotiation(optimistic: optimistic)
+            if optimistic, (error as? NegotiationError) == .noAgreement {
+                negotiationCache.recordRejected(protocolID, for: peer)
+            }
             resources.releaseStream(protocolID: protocolID, peer: peer, direction: .outbound)
             do {
                 try await stream.close()
@@ -84,6 +117,7 @@ internal struct DefaultStreamLifecycleCoordinator: StreamLifecycleCoordinator {
             }
             throw NodeError.protocolNegotiationFailed
         }
+        negotiationCache.recordSupported(protocolID, for: peer)
 
         let negotiatedStream = bufferedStream(
             base: stream,
@@ -9,6 +9,8 @@
 /// - Upgrade raw connections (security + muxer negotiation)
 /// - Handle inbound stream negotiation and dispatch
 /// - Manage reconnection and idle connection cleanup
+/// - Probe connections under the pool's keepalive policy
+/// - Drain connections the peer sent GoAway on and dial replacements
 /// - Emit SwarmEvents for Node to consume
 ///
 /// ## Design Decisions
@@ -22,8 +24,11 @@ import P2PCore
 import P2PTransport
 import P2PSecurity
 import P2PMux
+import P2PMuxYamux
 import P2PNegotiation
 import P2PRuntime
+import P2PProtocols
+import P2PPing
 
 #if canImport(Darwin)
 import Darwin
@@ -32,7 +37,7 @@ import Glibc
 #endif
 
 /// Logger for Swarm operations.
-private let swarmLogger = Logger(label: "p2p.swarm")
+private let swarmLogger = SubsystemLogger(.swarm)
 
 private func runBestEffort(_ context: String, _ operation: () async throws -> Void) async {
     do {
@@ -87,6 +92,8 @@ internal actor Swarm {
     private let providers: [any ConnectionProvider]
     private nonisolated let broadcaster = EventBroadcaster<SwarmEvent>()
     private nonisolated let negotiationSemaphore: AsyncSemaphore
+    private nonisolated let streamLimiter: ProtocolStreamLimiter
+    private nonisolated let colocationLimiter: IPColocationLimiter?
 
     // Listeners
     private var listeners: [any ConnectionAcceptor] = []
@@ -121,13 +128,23 @@ internal actor Swarm {
         self.listenAddresses = ListenAddressStore()
         self.advertisedAddresses = ListenAddressStore()
         self.negotiationSemaphore = AsyncSemaphore(count: configuration.maxNegotiatingInboundStreams)
+        self.streamLimiter = ProtocolStreamLimiter(defaultLimit: configuration.handlerStreamLimit)
+        self.colocationLimiter = configuration.pool.ipColocation.map { IPColocationLimiter(policy: $0) }
     }
 
     // MARK: - Handler Registration
 
     /// Registers a protocol handler for inbound stream negotiation.
-    func registerHandler(for protocolID: String, handler: @escaping ProtocolHandler) {
+    ///
+    /// `limit` caps the handler's concurrent streams; `nil` applies
+    /// `SwarmConfiguration.handlerStreamLimit`.
+    func registerHandler(
+        for protocolID: String,
+        limit: HandlerStreamLimit? = nil,
+        handler: @escaping ProtocolHandler
+    ) {
         handlers[protocolID] = handler
+        streamLimiter.setLimit(limit, for: protocolID)
     }
 
     /// Returns all registered protocol IDs.
@@ -153,7 +170,9 @@ internal actor Swarm {
                     do {
                         let listener = try await provider.listen(address, identity: identity)
                         listeners.append(listener)
-                        emit(.newListenAddr(listener.localAddress))
+                        for localAddress in listener.localAddresses {
+                            emit(.newListenAddr(localAddress))
+                        }
 
                         let acceptTask = Task { [weak self] in
                             guard let self else { return }
@@ -173,7 +192,8 @@ internal actor Swarm {
         }
 
         // Update current listen addresses for synchronous access
-        let boundAddresses = listeners.map(\.localAddress)
+        // A dual-stack listener contributes both its IPv6 and IPv4 wildcard
+        let boundAddresses = listeners.flatMap(\.localAddresses)
         listenAddresses.update(boundAddresses)
 
         // Resolve unspecified addresses (0.0.0.0 / ::) to actual interface IPs
@@ -204,12 +224,17 @@ internal actor Swarm {
         // closed streams instead of hanging forever).
         negotiationSemaphore.drain()
 
+        // Refuse streams queued for a handler slot; they are reset
+        streamLimiter.drain()
+
         // Cancel pending dials
         pool.cancelAllPendingDials()
 
         // Close all listeners
         for listener in listeners {
-            emit(.expiredListenAddr(listener.localAddress))
+            for localAddress in listener.localAddresses {
+                emit(.expiredListenAddr(localAddress))
+            }
             do {
                 try await listener.close()
             } catch {
@@ -249,7 +274,9 @@ internal actor Swarm {
 
     /// Dials a peer at the given address.
     ///
-    /// If a dial to the same peer is already in progress, joins the existing dial.
+    /// If the peer already has a direct connection it is reused (unless
+    /// multiple connections per peer are allowed). If a dial to the same peer
+    /// is already in progress, joins the existing dial.
     func dial(to address: Multiaddr) async throws -> PeerID {
         guard isRunning else { throw NodeError.nodeNotRunning }
 
@@ -267,8 +294,22 @@ internal actor Swarm {
             }
         }
 
-        // Check for pending dial to same peer (join existing)
-        if let peerID = address.peerID, let pendingTask = pool.pendingDial(to: peerID) {
+        // Reuse an existing direct connection instead of opening another.
+        // A limited (relay) connection does not count, so a direct dial can
+        // still upgrade it; neither does a hole punch, which must dial.
+        if !configuration.pool.allowMultipleConnectionsPerPeer,
+           SimultaneousConnect.role == nil,
+           let peerID = address.peerID,
+           pool.hasUnlimitedConnection(to: peerID) {
+            return peerID
+        }
+
+        // Check for pending dial to same peer (join existing). A
+        // simultaneous-connect dial carries its own handshake role, so it
+        // must not piggyback on an ordinary dial.
+        if SimultaneousConnect.role == nil,
+           let peerID = address.peerID,
+           let pendingTask = pool.pendingDial(to: peerID) {
             return try await pendingTask.value
         }
 
@@ -340,6 +381,7 @@ internal actor Swarm {
             if removal.shouldReleaseResource && managed.state.isConnected {
                 configuration.connectionResources.releaseConnection(peer: peer, direction: managed.direction)
             }
+            swarmLogger.debug("Connection closed", peer: peer, connection: managed.id, metadata: ["reason": "localClose"])
         }
 
         if !removed.isEmpty {
@@ -348,17 +390,70 @@ internal actor Swarm {
         }
     }
 
+    /// Closes one connection, leaving the peer's other connections and its
+    /// auto-reconnect registration alone.
+    func closeConnection(_ id: ConnectionID) async {
+        // Removed first, as in closePeer, so a racing handleConnectionClosed
+        // cannot double-release.
+        guard let removal = pool.removeReleasing(id) else { return }
+        let managed = removal.managed
+        if let connection = managed.connection {
+            await runBestEffort("close connection") {
+                try await connection.close()
+            }
+        }
+        if removal.shouldReleaseResource && managed.state.isConnected {
+            configuration.connectionResources.releaseConnection(peer: managed.peer, direction: managed.direction)
+        }
+        swarmLogger.debug("Connection closed", peer: managed.peer, connection: managed.id, metadata: ["reason": "localClose"])
+
+        if !pool.isConnected(to: managed.peer) {
+            onPeerDisconnected(managed.peer)
+            emitConnectionEvent(.disconnected(peer: managed.peer, reason: .localClose))
+        }
+    }
+
     /// Opens a new stream to a peer with the given protocol.
-    func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
+    ///
+    /// `advertised` marks a protocol the peer is known to support (from the
+    /// protocol book), letting negotiation skip the interactive round trip.
+    /// `id` pins the stream to one connection instead of the best one to the
+    /// peer; it fails with `notConnected` if that connection is gone.
+    func newStream(
+        to peer: PeerID,
+        protocol protocolID: String,
+        advertised: Bool = false,
+        on id: ConnectionID? = nil
+    ) async throws -> MuxedStream {
         guard isRunning else { throw NodeError.nodeNotRunning }
-        guard let connection = pool.connection(to: peer) else {
+        let connection: (any MuxedConnection)? = if let id { pool.activeConnection(id) } else { pool.connection(to: peer) }
+        guard let connection else {
             throw NodeError.notConnected(peer)
         }
-        return try await configuration.streamLifecycle.openOutboundStream(
+        let stream = try await configuration.streamLifecycle.openOutboundStream(
             on: connection,
             peer: peer,
-            protocolID: protocolID
+            protocolID: protocolID,
+            advertised: advertised
         )
+        swarmLogger.debug("Outbound stream opened", peer: peer, protocol: protocolID, metadata: ["stream": "\(stream.id)"])
+        return stream
+    }
+
+    /// Outbound stream negotiation counters.
+    nonisolated var streamNegotiationStats: StreamNegotiationStats {
+        configuration.streamLifecycle.negotiationStats
+    }
+
+    /// Inbound handler admission counters per protocol.
+    nonisolated var handlerStreamStats: [String: ProtocolStreamStats] {
+        streamLimiter.stats
+    }
+
+    /// Inbound connection counts per IP and subnet; empty without an
+    /// `IPColocationPolicy`.
+    nonisolated var ipColocationStats: IPColocationStats {
+        colocationLimiter?.stats(established: pool.inboundAddresses) ?? IPColocationStats()
     }
 
     /// Enables auto-reconnect for a peer at the given address.
@@ -374,6 +469,12 @@ internal actor Swarm {
     // MARK: - Private: Dial
 
     private func performDial(to address: Multiaddr) async throws -> PeerID {
+        if address.hasDNSComponent,
+           let resolver = configuration.dnsResolver,
+           !providers.contains(where: { $0.canDial(address) }) {
+            return try await performResolvedDial(to: address, using: resolver)
+        }
+
         // Emit dialing event
         if let peerID = address.peerID {
             emit(.dialing(peerID))
@@ -385,11 +486,19 @@ internal actor Swarm {
         }
 
         let isRelay = provider.pathKind == .relay
+        // Non-nil when a hole punch assigned this dial's handshake role
+        let securityRole = SimultaneousConnect.role
 
         // Track connecting state if peer ID is known from address
         let connectingID: ConnectionID?
         if let peerID = address.peerID {
-            connectingID = pool.addConnecting(for: peerID, address: address, direction: .outbound, isLimited: isRelay)
+            connectingID = pool.addConnecting(
+                for: peerID,
+                address: address,
+                direction: .outbound,
+                securityRole: securityRole,
+                isLimited: isRelay
+            )
         } else {
             connectingID = nil
         }
@@ -408,6 +517,17 @@ internal actor Swarm {
         )
         let remotePeer = muxedConnection.remotePeer
 
+        // Identity check: the authenticated peer must be the one the address
+        // names. Security upgraders enforce this during the handshake; this
+        // covers providers and upgraders that do not.
+        if let expected = address.peerID, remotePeer != expected {
+            await runBestEffort("close connection to mismatched peer after handshake") {
+                try await muxedConnection.close()
+            }
+            swarmLogger.warning("Dialed peer proved a different identity", peer: expected, metadata: ["actual": "\(remotePeer)"])
+            throw NodeError.identityMismatch(expected: expected, actual: remotePeer)
+        }
+
         // Self-connection guard (post-handshake, for addresses without embedded PeerID)
         if remotePeer == configuration.localIdentity.keyPair.peerID {
             await runBestEffort("close self-connection after handshake") {
@@ -457,6 +577,7 @@ internal actor Swarm {
                 for: remotePeer,
                 address: address,
                 direction: .outbound,
+                securityRole: securityRole,
                 isLimited: isRelay
             ) else {
                 configuration.connectionResources.releaseConnection(peer: remotePeer, direction: .outbound)
@@ -498,10 +619,19 @@ internal actor Swarm {
             await swarm.handleInboundStreams(connection: muxedConnection)
             await swarm.handleConnectionClosed(id: connID, peer: remotePeer)
         }
+        startKeepAlive(id: connID, connection: muxedConnection)
+        startLivenessScoring(id: connID, connection: muxedConnection)
+        startGoAwayWatch(id: connID, connection: muxedConnection)
 
         // Emit events (guarded: only fires for first connection to this peer)
         onPeerConnected(remotePeer)
         emitConnectionEvent(.connected(peer: remotePeer, address: address, direction: .outbound))
+        swarmLogger.debug(
+            "Connection established",
+            peer: remotePeer,
+            connection: connID,
+            metadata: ["direction": "outbound", "address": "\(address)"]
+        )
 
         return remotePeer
     }
@@ -548,6 +678,23 @@ internal actor Swarm {
             return
         }
 
+        // Per-IP / per-subnet limits, held until the upgrade is over
+        var colocationKey: IPColocationKey?
+        if let colocationLimiter {
+            do {
+                colocationKey = try colocationLimiter.reserve(remoteAddress, established: pool.inboundAddresses)
+            } catch {
+                await candidate.reject()
+                emit(.connectionError(nil, error))
+                return
+            }
+        }
+        defer {
+            if let colocationKey {
+                colocationLimiter?.release(colocationKey)
+            }
+        }
+
         do {
             let muxedConnection = try await candidate.establish()
             await handleEstablishedInboundConnection(muxedConnection)
@@ -631,10 +778,19 @@ internal actor Swarm {
             await swarm.handleInboundStreams(connection: muxedConnection)
             await swarm.handleConnectionClosed(id: connID, peer: remotePeer)
         }
+        startKeepAlive(id: connID, connection: muxedConnection)
+        startLivenessScoring(id: connID, connection: muxedConnection)
+        startGoAwayWatch(id: connID, connection: muxedConnection)
 
         // Emit events (guarded: only fires for first connection to this peer)
         onPeerConnected(remotePeer)
         emitConnectionEvent(.connected(peer: remotePeer, address: remoteAddress, direction: .inbound))
+        swarmLogger.debug(
+            "Connection established",
+            peer: remotePeer,
+            connection: connID,
+            metadata: ["direction": "inbound", "address": "\(remoteAddress)"]
+        )
     }
 
     // MARK: - Private: Stream Handling
@@ -656,6 +812,18 @@ internal actor Swarm {
                 continue
             }
 
+            // Wait for a negotiation slot before spawning, so the loop stops
+            // pulling streams while all slots are busy. Later streams then
+            // wait in the muxer, whose bounded accept queue throttles the peer.
+            await semaphore.wait()
+            guard isRunning else {
+                semaphore.signal()
+                await runBestEffort("close inbound stream after shutdown") {
+                    try await stream.close()
+                }
+                continue
+            }
+
             let capturedHandlers = handlers
 
             let remotePeer = connection.remotePeer
@@ -681,6 +849,9 @@ internal actor Swarm {
 
     /// Negotiates a single inbound stream and dispatches it to a handler.
     ///
+    /// Called holding a slot of `semaphore`, which is released once
+    /// negotiation ends.
+    ///
     /// Resource lifetime: the peer-scoped reservation taken before negotiation
     /// is upgraded to a protocol-scoped reservation once the protocol is known,
     /// and the reservation is then bound to the stream via `ResourceTrackedStream`
@@ -697,9 +868,8 @@ internal actor Swarm {
         resources rm: any StreamResourceAccounting,
         semaphore: AsyncSemaphore
     ) async {
-        // Limit concurrent inbound stream negotiations.
-        await semaphore.wait()
-
+        // The caller holds a negotiation slot for this stream; every path
+        // below releases it.
         // Reserve inbound stream resource (peer scope; protocol is unknown until
         // negotiation completes).
         do {
@@ -725,6 +895,7 @@ internal actor Swarm {
         } catch {
             semaphore.signal()
             rm.releaseStream(peer: remotePeer, direction: .inbound)
+            swarmLogger.debug("Inbound stream negotiation failed: \(error)", peer: remotePeer)
             await runBestEffort("close inbound stream after negotiation failure") {
                 try await stream.close()
             }
@@ -774,7 +945,24 @@ internal actor Swarm {
             localAddress: context.localAddress,
             protocolID: context.protocolID
         )
+
+        // Per-protocol handler limit: wait for a slot or reset the stream
+        guard await streamLimiter.acquire(context.protocolID) else {
+            swarmLogger.debug(
+                "Resetting inbound stream: handler stream limit exceeded",
+                peer: remotePeer,
+                protocol: context.protocolID
+            )
+            await runBestEffort("reset inbound stream over handler stream limit") {
+                try await trackedStream.reset()
+            }
+            return
+        }
+        let streamID = "\(context.stream.id)"
+        swarmLogger.debug("Inbound stream opened", peer: remotePeer, protocol: context.protocolID, metadata: ["stream": "\(streamID)"])
         await handler(trackedContext)
+        streamLimiter.release(context.protocolID)
+        swarmLogger.debug("Inbound stream handler returned", peer: remotePeer, protocol: context.protocolID, metadata: ["stream": "\(streamID)"])
     }
 
     // MARK: - Private: Connection Close / Reconnect
@@ -798,6 +986,7 @@ internal actor Swarm {
 
         // Update state
         pool.updateState(id, to: .disconnected(reason: .remoteClose))
+        swarmLogger.debug("Connection closed", peer: peer, connection: id, metadata: ["reason": "remoteClose"])
 
         if wasConnected && !pool.isConnected(to: peer) {
             onPeerDisconnected(peer)
@@ -853,6 +1042,33 @@ internal actor Swarm {
         }
     }
 
+    /// Resolves a DNS address that no provider dials as-is and dials the
+    /// results in order until one connects. A failed candidate does not stop
+    /// the others; if none connects, the last failure is thrown.
+    private func performResolvedDial(
+        to address: Multiaddr,
+        using resolver: any DNSResolver
+    ) async throws -> PeerID {
+        let candidates = try await resolver.resolve(address)
+
+        var lastError: any Error = NodeError.noSuitableTransport
+        for candidate in candidates where candidate != address {
+            try Task.checkCancellation()
+            if let gater = configuration.connectionGater,
+               !gater.interceptDial(peer: candidate.peerID, address: candidate) {
+                emitConnectionEvent(.gated(peer: candidate.peerID, address: candidate, stage: .dial))
+                lastError = NodeError.connectionGated(stage: .dial)
+                continue
+            }
+            do {
+                return try await performDial(to: candidate)
+            } catch {
+                lastError = error
+            }
+        }
+        throw lastError
+    }
+
     private func performReconnect(id: ConnectionID, peer: PeerID, address: Multiaddr, attempt: Int) async {
         guard isRunning else { return }
         guard pool.reconnectAddress(for: peer) != nil else { return }
@@ -919,6 +1135,7 @@ internal actor Swarm {
                 await swarm.handleInboundStreams(connection: muxedConnection)
                 await swarm.handleConnectionClosed(id: id, peer: remotePeer)
             }
+            startGoAwayWatch(id: id, connection: muxedConnection)
 
             onPeerConnected(remotePeer)
             emitConnectionEvent(.reconnected(peer: peer, attempt: attempt))
@@ -958,6 +1175,8 @@ internal actor Swarm {
     // MARK: - Private: Simultaneous Connect Resolution
 
     private func resolveSimultaneousConnect(for peer: PeerID) async {
+        // Duplicates are kept on purpose when multiple connections are allowed
+        guard !configuration.pool.allowMultipleConnectionsPerPeer else { return }
         let connections = pool.connectedManagedConnections(for: peer)
         let losers = configuration.conflictResolver.duplicateConnections(
             from: connections,
@@ -1078,6 +1297,216 @@ internal actor Swarm {
         dialBackoff.cleanup()
     }
 
+    // MARK: - Private: Keepalive
+
+    /// Starts probing `connection` under the pool's keepalive policy, if any.
+    ///
+    /// The connection's own keepalive is disabled first, so only the policy
+    /// decides when the peer is dead.
+    private func startKeepAlive(id: ConnectionID, connection: any MuxedConnection) {
+        guard let policy = configuration.pool.keepAlive,
+              let mechanism = policy.mechanism(for: connection) else { return }
+        connection.keepAliveProbe?.disableBuiltInKeepAlive()
+        swarmLogger.debug("Keepalive for \(connection.remotePeer) uses \(mechanism.rawValue)")
+
+        track { swarm in
+            await swarm.runKeepAlive(id: id, connection: connection, policy: policy, mechanism: mechanism)
+        }
+    }
+
+    /// Probes once per `policy.probeInterval` until the connection leaves the
+    /// pool, closing it after `policy.probeCount` consecutive failures.
+    private func runKeepAlive(
+        id: ConnectionID,
+        connection: any MuxedConnection,
+        policy: KeepAlivePolicy,
+        mechanism: KeepAliveMechanism
+    ) async {
+        let interval = policy.probeInterval
+        let streamLifecycle = configuration.streamLifecycle
+        var failures = 0
+
+        while isRunning && !Task.isCancelled {
+            guard pool.managedConnection(id)?.state.isConnected == true else { return }
+
+            let started = ContinuousClock.now
+            do {
+                _ = try await Self.probe(
+                    connection,
+                    mechanism: mechanism,
+                    timeout: interval,
+                    streamLifecycle: streamLifecycle
+                )
+                failures = 0
+            } catch is CancellationError {
+                return
+            } catch {
+                failures += 1
+                swarmLogger.debug("Keepalive probe \(failures)/\(policy.probeCount) to \(connection.remotePeer) failed: \(error)")
+                if failures >= policy.probeCount {
+                    await closeUnresponsiveConnection(id: id, mechanism: mechanism)
+                    return
+                }
+            }
+
+            let remaining = interval - (ContinuousClock.now - started)
+            if remaining > .zero {
+                guard await sleepUnlessCancelled(for: remaining, context: "keepalive probe interval") else { return }
+            }
+        }
+    }
+
+    /// Sends one probe over `mechanism`, failing with
+    /// `KeepAliveProbeTimeout` after `timeout`.
+    ///
+    /// - Returns: The round-trip time.
+    private static func probe(
+        _ connection: any MuxedConnection,
+        mechanism: KeepAliveMechanism,
+        timeout: Duration,
+        streamLifecycle: any StreamLifecycleCoordinator
+    ) async throws -> Duration {
+        let nativeProbe = connection.keepAliveProbe
+        return try await withThrowingTaskGroup(of: Duration.self) { group in
+            group.addTask {
+                switch mechanism {
+                case .quicPing, .muxerPing:
+                    guard let nativeProbe else { throw KeepAliveProbeTimeout() }
+                    return try await nativeProbe.probe()
+                case .libp2pPing:
+                    let opener = ConnectionStreamOpener(connection: connection, streamLifecycle: streamLifecycle)
+                    let service = PingService(configuration: PingConfiguration(timeout: timeout))
+                    return try await service.ping(connection.remotePeer, using: opener).rtt
+                }
+            }
+            group.addTask {
+                try await Task.sleep(for: timeout)
+                throw KeepAliveProbeTimeout()
+            }
+            defer { group.cancelAll() }
+            guard let rtt = try await group.next() else { throw KeepAliveProbeTimeout() }
+            return rtt
+        }
+    }
+
+    // MARK: - Private: Liveness Scoring
+
+    /// Starts scoring `connection` under the pool's liveness scoring policy,
+    /// if any.
+    private func startLivenessScoring(id: ConnectionID, connection: any MuxedConnection) {
+        guard let policy = configuration.pool.livenessScoring else { return }
+        track { swarm in
+            await swarm.runLivenessScoring(id: id, connection: connection, policy: policy)
+        }
+    }
+
+    /// Pings the connection once per `policy.probeInterval` while it has been
+    /// idle for at least `policy.idleThreshold`, folding each result into its
+    /// liveness score. Unlike keepalive, a failed ping never closes the
+    /// connection; it only makes it a better trim candidate.
+    private func runLivenessScoring(
+        id: ConnectionID,
+        connection: any MuxedConnection,
+        policy: LivenessScoringPolicy
+    ) async {
+        let streamLifecycle = configuration.streamLifecycle
+
+        while isRunning && !Task.isCancelled {
+            guard await sleepUnlessCancelled(for: policy.probeInterval, context: "liveness probe interval") else { return }
+            guard let managed = pool.managedConnection(id), managed.state.isConnected else { return }
+            guard ContinuousClock.now - managed.lastActivity >= policy.idleThreshold else { continue }
+
+            let rtt: Duration?
+            do {
+                rtt = try await Self.probe(
+                    connection,
+                    mechanism: .libp2pPing,
+                    timeout: policy.timeout,
+                    streamLifecycle: streamLifecycle
+                )
+            } catch is CancellationError {
+                return
+            } catch {
+                swarmLogger.debug("Liveness ping to \(connection.remotePeer) failed: \(error)")
+                rtt = nil
+            }
+            if let score = pool.recordLivenessProbe(id, rtt: rtt, policy: policy) {
+                swarmLogger.debug("Liveness score for \(connection.remotePeer): \(score)")
+            }
+        }
+    }
+
+    // MARK: - Private: GoAway
+
+    /// Watches a Yamux connection for the peer's GoAway.
+    ///
+    /// Takes the session's `events` stream, which has a single consumer.
+    private func startGoAwayWatch(id: ConnectionID, connection: any MuxedConnection) {
+        guard let session = connection.yamuxConnection else { return }
+        track { swarm in
+            // Subscribe before checking, so a GoAway in between is not missed
+            let events = session.events
+            if let reason = session.remoteGoAwayReason {
+                await swarm.handleGoAway(id: id, reason: reason)
+                return
+            }
+            for await event in events {
+                switch event {
+                case .sessionGoAway(let reason):
+                    await swarm.handleGoAway(id: id, reason: reason)
+                    return
+                }
+            }
+        }
+    }
+
+    /// Marks the connection draining, reports the GoAway and dials a
+    /// replacement.
+    ///
+    /// The replacement goes to the address the connection was dialed at, or
+    /// for an inbound connection the peer's auto-reconnect address. Without
+    /// either the peer stays on the draining connection until it closes.
+    private func handleGoAway(id: ConnectionID, reason: YamuxGoAwayReason) async {
+        guard let managed = pool.markDraining(id) else { return }
+        let peer = managed.peer
+        swarmLogger.debug("Connection draining after GoAway", peer: peer, connection: id, metadata: ["reason": "\(reason)"])
+        emitConnectionEvent(.goAway(peer: peer, reason: reason))
+
+        let address = managed.direction == .outbound ? managed.address : pool.reconnectAddress(for: peer)
+        guard isRunning,
+              let address,
+              let target = AddrInfo(peer: peer, addresses: [address]).dialAddresses.first else {
+            swarmLogger.debug("No address to replace draining connection", peer: peer, connection: id)
+            return
+        }
+        do {
+            _ = try await dial(to: target)
+        } catch {
+            swarmLogger.debug("Replacement dial after GoAway failed", peer: peer, metadata: ["error": "\(error)"])
+        }
+    }
+
+    /// Closes a connection whose peer stopped answering keepalive probes.
+    private func closeUnresponsiveConnection(id: ConnectionID, mechanism: KeepAliveMechanism) async {
+        // Remove first, as in the idle check, so a racing
+        // handleConnectionClosed cannot double-release.
+        guard let removal = pool.removeReleasing(id) else { return }
+        let managed = removal.managed
+        if removal.shouldReleaseResource {
+            configuration.connectionResources.releaseConnection(peer: managed.peer, direction: managed.direction)
+        }
+        swarmLogger.info("Closing connection to \(managed.peer): no answer to \(mechanism.rawValue) keepalive")
+        if let connection = managed.connection {
+            await runBestEffort("close connection after keepalive timeout") {
+                try await connection.close()
+            }
+        }
+        if !pool.isConnected(to: managed.peer) {
+            onPeerDisconnected(managed.peer)
+            emitConnectionEvent(.disconnected(peer: managed.peer, reason: .keepAliveTimeout))
+        }
+    }
+
     private static func trimContext(for candidate: ConnectionTrimReport.Candidate) -> ConnectionTrimmedContext {
         ConnectionTrimmedContext(
             rank: candidate.trimRank,
@@ -1133,6 +1562,7 @@ internal actor Swarm {
 
     private func onPeerDisconnected(_ peer: PeerID) {
         guard !pool.isConnected(to: peer) else { return }
+        configuration.streamLifecycle.peerDisconnected(peer)
         peerConnectedEmitted.remove(peer)
         emit(.peerDisconnected(peer))
     }
@@ -1148,12 +1578,25 @@ internal actor Swarm {
     // MARK: - Private: Address Resolution
 
     static func resolveUnspecifiedAddresses(_ boundAddresses: [Multiaddr]) -> [Multiaddr] {
+        resolveUnspecifiedAddresses(boundAddresses, interfaceIPs: getInterfaceAddresses())
+    }
+
+    /// Expands each unspecified address to the interface IPs of its family.
+    ///
+    /// IPv6 link-local addresses are not advertised: they are only reachable
+    /// with a zone ID, which multiaddrs for remote peers cannot carry. An
+    /// interface with only link-local IPv6 therefore contributes no IPv6 address.
+    static func resolveUnspecifiedAddresses(
+        _ boundAddresses: [Multiaddr],
+        interfaceIPs: [String]
+    ) -> [Multiaddr] {
         var result: [Multiaddr] = []
-        let interfaceIPs = getInterfaceAddresses()
 
         for addr in boundAddresses {
             guard addr.isUnspecifiedIP else {
-                result.append(addr)
+                if !result.contains(addr) {
+                    result.append(addr)
+                }
                 continue
             }
 
@@ -1168,7 +1611,13 @@ internal actor Swarm {
             }
 
             for ip in matchingIPs {
-                result.append(addr.replacingIPAddress(ip))
+                let resolved = addr.replacingIPAddress(ip)
+                if isIPv6 && resolved.isLinkLocalIP {
+                    continue
+                }
+                if !result.contains(resolved) {
+                    result.append(resolved)
+                }
             }
         }
 
@@ -1188,37 +1637,44 @@ internal actor Swarm {
         var current: UnsafeMutablePointer<ifaddrs>? = firstAddr
         while let addr = current {
             let interface = addr.pointee
-            let family = interface.ifa_addr.pointee.sa_family
+            current = interface.ifa_next
+
+            guard let socketAddress = interface.ifa_addr else { continue }
+            let family = socketAddress.pointee.sa_family
 
-            if family == sa_family_t(AF_INET) {
+            if family == sa_family_t(AF_INET) || family == sa_family_t(AF_INET6) {
                 var hostname = [CChar](repeating: 0, count: Int(NI_MAXHOST))
                 #if canImport(Darwin)
-                let addrLen = socklen_t(interface.ifa_addr.pointee.sa_len)
+                let addrLen = socklen_t(socketAddress.pointee.sa_len)
                 #else
-                let addrLen = socklen_t(MemoryLayout<sockaddr_in>.size)
+                let addrLen = family == sa_family_t(AF_INET6)
+                    ? socklen_t(MemoryLayout<sockaddr_in6>.size)
+                    : socklen_t(MemoryLayout<sockaddr_in>.size)
                 #endif
                 getnameinfo(
-                    interface.ifa_addr, addrLen,
+                    socketAddress, addrLen,
                     &hostname, socklen_t(hostname.count),
                     nil, 0, NI_NUMERICHOST
                 )
-                let ip: String = hostname.withUnsafeBufferPointer { buf in
+                var ip: String = hostname.withUnsafeBufferPointer { buf in
                     let len = buf.firstIndex(of: 0) ?? buf.count
                     return String(decoding: buf[..<len].lazy.map { UInt8(bitPattern: $0) }, as: UTF8.self)
                 }
+                // Drop the zone ID of scoped IPv6 addresses (fe80::1%en0)
+                if let zoneStart = ip.firstIndex(of: "%") {
+                    ip = String(ip[..<zoneStart])
+                }
 
-                if ip == "127.0.0.1" {
+                if ip == "127.0.0.1" || ip == "::1" {
                     addresses.append(ip)
                 } else if !ip.isEmpty {
                     addresses.insert(ip, at: hasNonLoopback ? 1 : 0)
                     hasNonLoopback = true
                 }
             }
-
-            current = interface.ifa_next
         }
 
-        if addresses.isEmpty {
+        if !addresses.contains(where: { !$0.contains(":") }) {
             addresses.append("127.0.0.1")
         }
         return addresses
@@ -1230,3 +1686,25 @@ internal actor Swarm {
         addr.protocols.contains { if case .p2pCircuit = $0 { return true }; return false }
     }
 }
+
+/// A keepalive probe that got no answer within the probe interval.
+private struct KeepAliveProbeTimeout: Error, CustomStringConvertible {
+    var description: String { "keepalive probe timed out" }
+}
+
+/// Opens `/ipfs/ping/1.0.0` streams on one specific connection, so a
+/// libp2p-ping keepalive checks the connection it is attached to rather than
+/// whichever connection the pool picks for the peer.
+private struct ConnectionStreamOpener: StreamOpener {
+    let connection: any MuxedConnection
+    let streamLifecycle: any StreamLifecycleCoordinator
+
+    func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
+        try await streamLifecycle.openOutboundStream(
+            on: connection,
+            peer: peer,
+            protocolID: protocolID,
+            advertised: false
+        )
+    }
+}
@@ -9,10 +9,12 @@ internal struct SwarmConfiguration: Sendable {
     let idleTimeout: Duration
     let reconnectionPolicy: ReconnectionPolicy
     let maxNegotiatingInboundStreams: Int
+    let handlerStreamLimit: HandlerStreamLimit?
     let connectionGater: (any ConnectionGater)?
     let connectionResources: any ConnectionResourceAccounting
     let streamResources: any StreamResourceAccounting
     let streamLifecycle: any StreamLifecycleCoordinator
     let reconnectPlanner: any ReconnectPlanner
     let conflictResolver: any ConnectionConflictResolver
+    let dnsResolver: (any DNSResolver)?
 }
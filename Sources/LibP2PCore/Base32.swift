@@ -0,0 +1,88 @@
+/// Base32 encoding/decoding (RFC 4648 alphabet, no padding).
+/// Used for the default CIDv1 string form (multibase prefix `b`).
+///
+/// Embedded-clean: no Foundation. Encodes lowercase, as multibase `base32`
+/// does; decoding accepts either case so the uppercase `B` form decodes too.
+
+public enum Base32 {
+
+    @usableFromInline
+    static let alphabet = Array("abcdefghijklmnopqrstuvwxyz234567".utf8)
+
+    /// Encodes bytes as an unpadded lowercase Base32 string.
+    ///
+    /// - Parameter bytes: The bytes to encode.
+    /// - Returns: Base32-encoded string.
+    public static func encode(_ bytes: [UInt8]) -> String {
+        var result = [UInt8]()
+        result.reserveCapacity((bytes.count * 8 + 4) / 5)
+
+        var buffer: UInt32 = 0
+        var bitCount = 0
+        for byte in bytes {
+            buffer = (buffer << 8) | UInt32(byte)
+            bitCount += 8
+            while bitCount >= 5 {
+                bitCount -= 5
+                result.append(alphabet[Int((buffer >> UInt32(bitCount)) & 0x1F)])
+            }
+        }
+        if bitCount > 0 {
+            result.append(alphabet[Int((buffer << UInt32(5 - bitCount)) & 0x1F)])
+        }
+
+        return String(decoding: result, as: UTF8.self)
+    }
+
+    /// Decodes an unpadded Base32 string to bytes.
+    ///
+    /// - Parameter string: The Base32-encoded string, in either case.
+    /// - Returns: Decoded bytes.
+    /// - Throws: `Base32Error.invalidCharacter` for characters outside the
+    ///   alphabet (padding included), `Base32Error.invalidLength` if no
+    ///   whole number of bytes encodes to this many characters.
+    public static func decode(_ string: String) throws(Base32Error) -> [UInt8] {
+        var result = [UInt8]()
+        result.reserveCapacity(string.utf8.count * 5 / 8)
+
+        var buffer: UInt32 = 0
+        var bitCount = 0
+        for char in string.utf8 {
+            guard let value = value(of: char) else {
+                throw Base32Error.invalidCharacter(Character(Unicode.Scalar(char)))
+            }
+            buffer = (buffer << 5) | UInt32(value)
+            bitCount += 5
+            if bitCount >= 8 {
+                bitCount -= 8
+                result.append(UInt8((buffer >> UInt32(bitCount)) & 0xFF))
+            }
+        }
+
+        // Whole bytes leave fewer than 5 bits over. Like Go's decoder, the
+        // value of those bits is not checked.
+        guard bitCount < 5 else {
+            throw Base32Error.invalidLength
+        }
+        return result
+    }
+
+    @inline(__always)
+    static func value(of char: UInt8) -> UInt8? {
+        switch char {
+        case UInt8(ascii: "a")...UInt8(ascii: "z"):
+            return char - UInt8(ascii: "a")
+        case UInt8(ascii: "A")...UInt8(ascii: "Z"):
+            return char - UInt8(ascii: "A")
+        case UInt8(ascii: "2")...UInt8(ascii: "7"):
+            return char - UInt8(ascii: "2") + 26
+        default:
+            return nil
+        }
+    }
+}
+
+public enum Base32Error: Error, Equatable, Sendable {
+    case invalidCharacter(Character)
+    case invalidLength
+}
@@ -0,0 +1,258 @@
+/// Content identifiers (CIDs).
+/// https://github.com/multiformats/cid
+///
+/// Embedded-clean: no Foundation. A CIDv0 is a bare SHA-256 multihash of
+/// dag-pb content, written in base58btc (`Qm…`). A CIDv1 is
+/// `<version 1><codec><multihash>`, written with a multibase prefix (base32 `b`
+/// by default). Parsing follows go-cid, and `routingKey` is the multihash
+/// bytes that go-libp2p-kad-dht uses as the provider record key.
+
+public struct CID: Sendable, Hashable, CustomStringConvertible {
+
+    /// The CID version.
+    public enum Version: UInt64, Sendable {
+        case v0 = 0
+        case v1 = 1
+    }
+
+    /// Content codecs from the multicodec table that CIDs commonly carry.
+    /// Other codec values are accepted as-is.
+    public enum Codec {
+        public static let raw: UInt64 = 0x55
+        public static let dagPB: UInt64 = 0x70
+        public static let dagCBOR: UInt64 = 0x71
+        public static let libp2pKey: UInt64 = 0x72
+    }
+
+    /// String encodings a CID can be written in.
+    public enum Base: Sendable {
+        /// Multibase `b`, the default for CIDv1.
+        case base32
+        /// Multibase `z`, or bare base58btc for CIDv0.
+        case base58btc
+    }
+
+    public let version: Version
+
+    /// The multicodec of the content (`Codec.dagPB` for CIDv0).
+    public let codec: UInt64
+
+    public let multihash: Multihash
+
+    /// Creates a CIDv0 from a SHA-256 multihash.
+    ///
+    /// - Throws: `CIDError.invalidV0Multihash` for any other multihash.
+    public init(v0 multihash: Multihash) throws(CIDError) {
+        guard Self.isV0Multihash(multihash) else {
+            throw CIDError.invalidV0Multihash
+        }
+        self.version = .v0
+        self.codec = Codec.dagPB
+        self.multihash = multihash
+    }
+
+    /// Creates a CIDv1.
+    public init(codec: UInt64, multihash: Multihash) {
+        self.version = .v1
+        self.codec = codec
+        self.multihash = multihash
+    }
+
+    /// Wraps a bare multihash as a raw-codec CIDv1, as go-libp2p does for
+    /// DHT keys given as multihashes.
+    public init(rawMultihash multihash: Multihash) {
+        self.init(codec: Codec.raw, multihash: multihash)
+    }
+
+    /// Decodes a CID from its binary form.
+    ///
+    /// 34 bytes starting `0x12 0x20` are a CIDv0; anything else must be a
+    /// CIDv1 whose multihash runs to the end of the input.
+    ///
+    /// - Throws: `CIDError` if the bytes are not a CID.
+    public init(bytes: [UInt8]) throws(CIDError) {
+        if bytes.count >= 2, bytes[0] == 0x12, bytes[1] == 0x20 {
+            self = try CID(v0: Self.multihash(from: bytes, at: 0))
+            return
+        }
+
+        let version: UInt64
+        let versionBytes: Int
+        do {
+            (version, versionBytes) = try Varint.decode(from: bytes, at: 0)
+        } catch {
+            throw .invalidVarint
+        }
+        guard version == Version.v1.rawValue else {
+            throw CIDError.unsupportedVersion(version)
+        }
+
+        let codec: UInt64
+        let codecBytes: Int
+        do {
+            (codec, codecBytes) = try Varint.decode(from: bytes, at: versionBytes)
+        } catch {
+            throw .invalidVarint
+        }
+        self.init(codec: codec, multihash: try Self.multihash(from: bytes, at: versionBytes + codecBytes))
+    }
+
+    /// Parses a CID string: a 46-character `Qm…` CIDv0, or a multibase string
+    /// with prefix `b`/`B` (base32), `z` (base58btc) or `f`/`F` (base16).
+    ///
+    /// - Throws: `CIDError` if the string is not a CID.
+    public init(string: String) throws(CIDError) {
+        if string.utf8.count == 46, string.hasPrefix("Qm") {
+            let bytes: [UInt8]
+            do {
+                bytes = try Base58.decode(string)
+            } catch {
+                throw CIDError.invalidEncoding
+            }
+            self = try CID(v0: Self.multihash(from: bytes, at: 0))
+            return
+        }
+
+        guard let prefix = string.first else {
+            throw CIDError.invalidEncoding
+        }
+        let payload = String(string.dropFirst())
+        let bytes: [UInt8]
+        switch prefix {
+        case "b", "B":
+            do {
+                bytes = try Base32.decode(payload)
+            } catch {
+                throw CIDError.invalidEncoding
+            }
+        case "z":
+            do {
+                bytes = try Base58.decode(payload)
+            } catch {
+                throw CIDError.invalidEncoding
+            }
+        case "f", "F":
+            guard let decoded = Hex.decode(payload) else {
+                throw CIDError.invalidEncoding
+            }
+            bytes = decoded
+        default:
+            throw CIDError.unsupportedMultibase(prefix)
+        }
+        try self.init(bytes: bytes)
+    }
+
+    /// Parses a DHT key the way the go interop node does: a CID string, else
+    /// a base58btc multihash, else a hex multihash. Bare multihashes become
+    /// raw-codec CIDv1s.
+    ///
+    /// - Throws: The `CIDError` from parsing `string` as a CID when it is
+    ///   none of the three forms.
+    public init(cidOrMultihash string: String) throws(CIDError) {
+        let cidError: CIDError
+        do {
+            self = try CID(string: string)
+            return
+        } catch {
+            cidError = error
+        }
+
+        let base58: [UInt8]?
+        do {
+            base58 = try Base58.decode(string)
+        } catch {
+            base58 = nil
+        }
+        if let base58, let multihash = Self.wholeMultihash(base58) {
+            self.init(rawMultihash: multihash)
+            return
+        }
+
+        guard let hex = Hex.decode(string), let multihash = Self.wholeMultihash(hex) else {
+            throw cidError
+        }
+        self.init(rawMultihash: multihash)
+    }
+
+    /// The binary form: the multihash for CIDv0, otherwise version, codec
+    /// and multihash.
+    public var bytes: [UInt8] {
+        switch version {
+        case .v0:
+            return multihash.bytes
+        case .v1:
+            var result = Varint.encodeBytes(version.rawValue)
+            result.append(contentsOf: Varint.encodeBytes(codec))
+            result.append(contentsOf: multihash.bytes)
+            return result
+        }
+    }
+
+    /// The DHT key for provider records: the multihash bytes. CIDs that
+    /// differ only in version or codec share it, as in go-libp2p-kad-dht.
+    public var routingKey: [UInt8] {
+        multihash.bytes
+    }
+
+    /// The same content as a CIDv1 (a CIDv1 is returned unchanged).
+    public var v1: CID {
+        CID(codec: codec, multihash: multihash)
+    }
+
+    /// The string form in the given base. CIDv0 is always bare base58btc.
+    public func string(base: Base) -> String {
+        switch (version, base) {
+        case (.v0, _):
+            return Base58.encode(multihash.bytes)
+        case (.v1, .base32):
+            return "b" + Base32.encode(bytes)
+        case (.v1, .base58btc):
+            return "z" + Base58.encode(bytes)
+        }
+    }
+
+    /// The default string form: base58btc for CIDv0, base32 for CIDv1.
+    public var description: String {
+        string(base: .base32)
+    }
+
+    private static func isV0Multihash(_ multihash: Multihash) -> Bool {
+        multihash.code == .sha2_256 && multihash.digest.count == 32
+    }
+
+    /// Decodes the multihash at `offset`, which must end the input.
+    private static func multihash(from bytes: [UInt8], at offset: Int) throws(CIDError) -> Multihash {
+        guard offset <= bytes.count else {
+            throw CIDError.invalidMultihash
+        }
+        let remainder = Array(bytes[offset...])
+        let multihash: Multihash
+        do {
+            multihash = try Multihash(bytes: remainder)
+        } catch {
+            throw CIDError.invalidMultihash
+        }
+        guard multihash.bytes.count == remainder.count else {
+            throw CIDError.trailingBytes
+        }
+        return multihash
+    }
+
+    private static func wholeMultihash(_ bytes: [UInt8]) -> Multihash? {
+        do {
+            return try multihash(from: bytes, at: 0)
+        } catch {
+            return nil
+        }
+    }
+}
+
+public enum CIDError: Error, Equatable, Sendable {
+    case invalidEncoding
+    case unsupportedMultibase(Character)
+    case unsupportedVersion(UInt64)
+    case invalidVarint
+    case invalidMultihash
+    case invalidV0Multihash
+    case trailingBytes
+}
@@ -187,6 +187,21 @@ public enum IPAddress {
         return bytes
     }
 
+    /// Returns the IPv4 address embedded in an IPv4-mapped IPv6 address
+    /// (`::ffff:a.b.c.d`), as reported for IPv4 peers of a dual-stack socket.
+    ///
+    /// - Parameter address: An IPv6 address string (no zone suffix).
+    /// - Returns: The dotted-decimal IPv4 address, or `nil` if `address` is
+    ///   not IPv4-mapped.
+    public static func unmappedIPv4(_ address: String) -> String? {
+        guard let bytes = encodeIPv6(address),
+              bytes[0..<10].allSatisfy({ $0 == 0 }),
+              bytes[10] == 0xFF, bytes[11] == 0xFF else {
+            return nil
+        }
+        return formatIPv4(Array(bytes[12..<16]))
+    }
+
     // MARK: - Helpers
 
     @inline(__always)
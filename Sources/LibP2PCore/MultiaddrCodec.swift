@@ -62,6 +62,20 @@ public enum MultiaddrCodec {
         }
     }
 
+    /// Whether a protocol code is part of this table.
+    public static func isKnown(code: UInt64) -> Bool {
+        switch code {
+        case Code.ip4, Code.tcp, Code.dns, Code.dns4, Code.dns6, Code.dnsaddr,
+             Code.ip6, Code.ip6zone, Code.unix, Code.p2p, Code.udp, Code.p2pCircuit,
+             Code.quic, Code.quicV1, Code.webrtcDirect, Code.ws, Code.wss,
+             Code.webtransport, Code.certhash, Code.memory,
+             Code.ble, Code.wifiDirect, Code.lora, Code.nfc:
+            return true
+        default:
+            return false
+        }
+    }
+
     // MARK: - Value Encoding (IP / port)
 
     /// Encodes a dotted-decimal IPv4 address to its 4 wire bytes.
@@ -3,9 +3,11 @@
 ///
 /// Embedded-clean: no Foundation, no Crypto. This is the multihash *framing*
 /// (code + length + digest) over `[UInt8]`. The SHA-256 digest factory
-/// (`Multihash.sha256`) is a crypto call and lives in the `P2PCore` adapter via
-/// the Crypto seam; the identity factory and binary encode/decode are pure and
-/// live here.
+/// (`Multihash.sha256`, `Multihash.sum`) are crypto calls and live in the
+/// `P2PCore` adapter via the Crypto seam; the identity factory, binary
+/// encode/decode and the base58/hex string forms are pure and live here. The
+/// entry points mirror go-multihash (`Encode`, `Cast`, `FromB58String`,
+/// `FromHexString`) so keys built on either side are byte-identical.
 
 /// A self-describing hash digest.
 public struct Multihash: Sendable, Hashable {
@@ -81,6 +83,52 @@ public struct Multihash: Sendable, Hashable {
         self._bytes = Array(bytes[0..<digestEnd])
     }
 
+    /// Casts bytes onto a multihash, as go-multihash's `Cast` does: the bytes
+    /// must hold exactly one multihash.
+    ///
+    /// - Parameter bytes: The multihash bytes.
+    /// - Throws: `MultihashError` if the bytes are malformed, or
+    ///   `MultihashError.inconsistentLength` if anything follows the digest.
+    public static func cast(_ bytes: [UInt8]) throws(MultihashError) -> Multihash {
+        let multihash = try Multihash(bytes: bytes)
+        guard multihash._bytes.count == bytes.count else {
+            throw MultihashError.inconsistentLength
+        }
+        return multihash
+    }
+
+    /// Parses a base58btc-encoded multihash (go-multihash `FromB58String`).
+    ///
+    /// - Parameter string: The base58btc string, with no multibase prefix.
+    /// - Throws: `MultihashError.invalidEncoding` if the string is not
+    ///   base58btc, otherwise as `cast(_:)`.
+    public init(base58 string: String) throws(MultihashError) {
+        let bytes: [UInt8]
+        do {
+            bytes = try Base58.decode(string)
+        } catch {
+            throw .invalidEncoding
+        }
+        self = try Self.cast(bytes)
+    }
+
+    /// Parses a hex-encoded multihash (go-multihash `FromHexString`).
+    ///
+    /// - Parameter string: The hex string, in either case.
+    /// - Throws: `MultihashError.invalidEncoding` if the string is not hex,
+    ///   otherwise as `cast(_:)`.
+    public init(hex string: String) throws(MultihashError) {
+        guard let bytes = Hex.decode(string) else {
+            throw MultihashError.invalidEncoding
+        }
+        self = try Self.cast(bytes)
+    }
+
+    /// The base58btc encoding of `bytes` (go-multihash `B58String`).
+    public var base58String: String {
+        Base58.encode(_bytes)
+    }
+
     /// Creates an identity multihash (no hashing, just wraps the bytes).
     ///
     /// - Parameter bytes: The bytes to wrap.
@@ -89,29 +137,66 @@ public struct Multihash: Sendable, Hashable {
         Multihash(code: .identity, digest: bytes)
     }
 
-    @usableFromInline
-    static func encodeBytes(code: HashCode, digest: [UInt8]) -> [UInt8] {
-        var result = Varint.encodeBytes(code.rawValue)
+    /// Frames a digest under any hash function code, including codes not in
+    /// `HashCode` (go-multihash `Encode`).
+    ///
+    /// - Parameters:
+    ///   - digest: The raw digest bytes.
+    ///   - code: The multicodec hash function code.
+    /// - Returns: The multihash bytes (code + length + digest).
+    public static func encode(_ digest: [UInt8], code: UInt64) -> [UInt8] {
+        var result = Varint.encodeBytes(code)
         result.append(contentsOf: Varint.encodeBytes(UInt64(digest.count)))
         result.append(contentsOf: digest)
         return result
     }
+
+    @usableFromInline
+    static func encodeBytes(code: HashCode, digest: [UInt8]) -> [UInt8] {
+        encode(digest, code: code.rawValue)
+    }
 }
 
 /// Hash function codes as defined in the multicodec table.
 /// https://github.com/multiformats/multicodec/blob/master/table.csv
+///
+/// Covers the fixed codes go-multihash names, plus the 256-bit BLAKE2 variants
+/// and BLAKE2b-512 from its BLAKE2 ranges.
 public enum HashCode: UInt64, Sendable {
     case identity = 0x00
+    case sha1 = 0x11
     case sha2_256 = 0x12
     case sha2_512 = 0x13
+    case sha3_224 = 0x17
     case sha3_256 = 0x16
+    case sha3_384 = 0x15
     case sha3_512 = 0x14
+    case shake_128 = 0x18
+    case shake_256 = 0x19
+    case keccak_224 = 0x1a
+    case keccak_256 = 0x1b
+    case keccak_384 = 0x1c
+    case keccak_512 = 0x1d
+    case blake3 = 0x1e
+    case murmur3_x64_64 = 0x22
+    case dbl_sha2_256 = 0x56
+    case md5 = 0xd5
+    case sha2_256_trunc254_padded = 0x1012
+    case x11 = 0x1100
     case blake2b_256 = 0xb220
+    case blake2b_512 = 0xb240
     case blake2s_256 = 0xb260
+    case poseidon_bls12_381_a2_fc1 = 0xb401
 }
 
 public enum MultihashError: Error, Equatable, Sendable {
     case unknownCode(UInt64)
     case insufficientData
     case digestTooLarge(UInt64)
+    /// Bytes follow the digest where exactly one multihash was expected.
+    case inconsistentLength
+    /// The base58 or hex string form could not be decoded.
+    case invalidEncoding
+    /// `Multihash.sum` cannot compute this hash function.
+    case unsupportedHashFunction(HashCode)
 }
@@ -3,8 +3,8 @@
 ///
 /// Embedded-clean: no Foundation, no Crypto, no `any`. A PeerID is the multihash
 /// of the protobuf-encoded public key: an *identity* multihash that embeds the
-/// full key for small keys (Ed25519, protobuf form <= 42 bytes), otherwise a
-/// SHA-256 multihash. This namespace owns the framing decisions over `[UInt8]`:
+/// full key when its protobuf form is <= 42 bytes (Ed25519, secp256k1),
+/// otherwise a SHA-256 multihash (RSA, ECDSA). This namespace owns the framing decisions over `[UInt8]`:
 /// the identity-vs-SHA-256 selection rule, the identity-multihash wrap, and the
 /// textual base58btc/multibase prefix handling. The actual SHA-256 digest is a
 /// crypto call that stays in the `P2PCore` adapter via the `HashFunction` seam;
@@ -14,21 +14,21 @@ public enum PeerIDFraming {
 
     /// Maximum protobuf-encoded public-key length eligible for identity encoding.
     /// Identity encoding embeds the whole key in the PeerID, so it is only used
-    /// for small keys (Ed25519 protobuf form is 36 bytes).
+    /// for small keys (Ed25519 protobuf form is 36 bytes, compressed secp256k1
+    /// 37). go-libp2p calls this `maxInlineKeyLength`.
     public static let identityEncodingMaxLength = 42
 
     /// Whether a protobuf-encoded public key should use an identity multihash.
     ///
-    /// - Parameters:
-    ///   - supportsIdentityEncoding: Whether the key type permits identity
-    ///     encoding (true only for Ed25519 in this project).
-    ///   - encodedLength: The byte length of the protobuf-encoded public key.
+    /// The choice depends on the encoded length alone, as in go-libp2p's
+    /// `IDFromPublicKey`: the key type plays no part, so any key that encodes
+    /// to at most ``identityEncodingMaxLength`` bytes is inlined.
+    ///
+    /// - Parameter encodedLength: The byte length of the protobuf-encoded public key.
     /// - Returns: `true` if the identity multihash should be used, `false` if
     ///   SHA-256 should be used instead.
-    public static func usesIdentityEncoding(
-        supportsIdentityEncoding: Bool, encodedLength: Int
-    ) -> Bool {
-        supportsIdentityEncoding && encodedLength <= identityEncodingMaxLength
+    public static func usesIdentityEncoding(encodedLength: Int) -> Bool {
+        encodedLength <= identityEncodingMaxLength
     }
 
     /// Wraps a protobuf-encoded public key as an identity multihash.
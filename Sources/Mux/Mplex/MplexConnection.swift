@@ -30,6 +30,18 @@ private actor MplexFrameWriter {
     }
 }
 
+extension MplexFrame {
+    /// The trace phase of a control frame, or `nil` for a message frame.
+    var controlPhase: WirePhase? {
+        switch flag {
+        case .newStream: return .mplexNewStream
+        case .messageReceiver, .messageInitiator: return nil
+        case .closeReceiver, .closeInitiator: return .mplexClose
+        case .resetReceiver, .resetInitiator: return .mplexReset
+        }
+    }
+}
+
 /// Composite key for Mplex stream lookup.
 ///
 /// Mplex spec: both sides use independent counters starting at 0.
@@ -54,6 +66,11 @@ private struct MplexConnectionState: Sendable {
     var readBuffer = ByteBuffer()
     var inboundContinuation: AsyncStream<MuxedStream>.Continuation?
 
+    /// Number of open streams opened locally or by the remote peer.
+    func streamCount(initiatedLocally: Bool) -> Int {
+        streams.keys.count(where: { $0.initiatedLocally == initiatedLocally })
+    }
+
     /// Compacts the read buffer if the consumed prefix is large enough.
     mutating func compactReadBufferIfNeeded() {
         if readBuffer.readerIndex > mplexReadBufferCompactThreshold {
@@ -158,8 +175,9 @@ public final class MplexConnection: MuxedConnection, Sendable {
     public func newStream() async throws -> MuxedStream {
         // Result type to capture both value and potential error from lock
         enum StreamIDResult {
-            case success(UInt64)
+            case success(UInt64, MplexStream)
             case closed
+            case limitReached(Int)
             case exhausted
         }
 
@@ -167,6 +185,10 @@ public final class MplexConnection: MuxedConnection, Sendable {
             if state.isClosed {
                 return .closed
             }
+            let outgoing = state.streamCount(initiatedLocally: true)
+            if outgoing >= configuration.maxOutgoingStreams {
+                return .limitReached(outgoing)
+            }
 
             let id = state.nextStreamID
 
@@ -177,25 +199,27 @@ public final class MplexConnection: MuxedConnection, Sendable {
             }
 
             state.nextStreamID = newID
-            return .success(id)
+            // We initiate this stream; insert under the same lock so
+            // concurrent opens count each other
+            let stream = MplexStream(id: id, connection: self, isInitiator: true, maxReadBufferSize: configuration.maxReadBufferSizePerStream, receiveTimeout: configuration.receiveTimeout)
+            state.streams[MplexStreamKey(id: id, initiatedLocally: true)] = stream
+            return .success(id, stream)
         }
 
         let streamID: UInt64
+        let stream: MplexStream
         switch result {
-        case .success(let id):
+        case .success(let id, let openedStream):
             streamID = id
+            stream = openedStream
         case .closed:
             throw MplexError.connectionClosed
+        case .limitReached(let current):
+            throw MplexError.maxStreamsExceeded(current: current, max: configuration.maxOutgoingStreams)
         case .exhausted:
             throw MplexError.streamIDExhausted
         }
-
-        // We initiate this stream
         let key = MplexStreamKey(id: streamID, initiatedLocally: true)
-        let stream = MplexStream(id: streamID, connection: self, isInitiator: true, maxReadBufferSize: configuration.maxReadBufferSizePerStream)
-        state.withLock { state in
-            state.streams[key] = stream
-        }
 
         // Send NewStream frame - clean up on failure
         do {
@@ -311,6 +335,9 @@ public final class MplexConnection: MuxedConnection, Sendable {
         }
         var buffer = ByteBuffer()
         frame.encode(into: &buffer)
+        if let wireTracer = configuration.wireTracer, let phase = frame.controlPhase {
+            wireTracer.trace(.outbound, phase, Array(buffer.readableBytesView))
+        }
         try await frameWriter.write(buffer)
     }
 
@@ -352,6 +379,11 @@ public final class MplexConnection: MuxedConnection, Sendable {
                         return decoded
                     }
                     guard let frame else { break }
+                    if let wireTracer = configuration.wireTracer, let phase = frame.controlPhase {
+                        var encoded = ByteBuffer()
+                        frame.encode(into: &encoded)
+                        wireTracer.trace(.inbound, phase, Array(encoded.readableBytesView))
+                    }
                     try await handleFrame(frame)
                 }
             }
@@ -368,7 +400,7 @@ public final class MplexConnection: MuxedConnection, Sendable {
             try await handleNewStream(frame)
 
         case .messageReceiver, .messageInitiator:
-            handleMessage(frame)
+            await handleMessage(frame)
 
         case .closeReceiver, .closeInitiator:
             handleClose(frame)
@@ -399,9 +431,12 @@ public final class MplexConnection: MuxedConnection, Sendable {
             if state.streams.count >= configuration.maxConcurrentStreams {
                 return .rejectLimit
             }
+            if state.streamCount(initiatedLocally: false) >= configuration.maxIncomingStreams {
+                return .rejectLimit
+            }
 
             // Remote initiated this stream, so we are not the initiator
-            let stream = MplexStream(id: streamID, connection: self, isInitiator: false, maxReadBufferSize: configuration.maxReadBufferSizePerStream)
+            let stream = MplexStream(id: streamID, connection: self, isInitiator: false, maxReadBufferSize: configuration.maxReadBufferSizePerStream, receiveTimeout: configuration.receiveTimeout)
             state.streams[key] = stream
             return .accept(stream)
         }
@@ -485,12 +520,13 @@ public final class MplexConnection: MuxedConnection, Sendable {
         }
     }
 
-    private func handleMessage(_ frame: MplexFrame) {
+    private func handleMessage(_ frame: MplexFrame) async {
         let key = streamKeyFromFlag(frame)
         let stream = state.withLock { state in state.streams[key] }
 
         if let stream = stream {
-            stream.dataReceived(frame.data)
+            // May suspend until the stream's reader catches up (backpressure)
+            await stream.dataReceived(frame.data)
         }
         // Ignore data for unknown streams (they may have been closed)
     }
@@ -248,6 +248,8 @@ public enum MplexError: Error, Sendable {
     case readBufferOverflow
     /// Stream ID exhausted
     case streamIDExhausted
+    /// Too many locally opened streams
+    case maxStreamsExceeded(current: Int, max: Int)
 }
 
 // MARK: - Configuration
@@ -260,6 +262,18 @@ public struct MplexConfiguration: Sendable {
     /// Default: 1000
     public var maxConcurrentStreams: Int
 
+    /// Maximum number of open streams the remote peer may have opened.
+    ///
+    /// A NewStream past this limit is answered with a Reset.
+    /// Default: 1000
+    public var maxIncomingStreams: Int
+
+    /// Maximum number of open streams opened locally.
+    ///
+    /// `newStream()` throws `MplexError.maxStreamsExceeded` past this limit.
+    /// Default: 1000
+    public var maxOutgoingStreams: Int
+
     /// Maximum number of pending inbound streams in the delivery buffer.
     ///
     /// When this limit is reached, new inbound streams are rejected with RST.
@@ -286,19 +300,40 @@ public struct MplexConfiguration: Sendable {
     /// Default: 1MB
     public var maxReadBufferSizePerStream: Int
 
+    /// How long the read loop waits for an application to drain a full
+    /// per-stream buffer before resetting that stream.
+    ///
+    /// While it waits no further frames are read from the connection, so the
+    /// sender is throttled by transport backpressure. Matches go-mplex's
+    /// `ReceiveTimeout`.
+    /// Default: 5 seconds
+    public var receiveTimeout: Duration
+
+    /// Receives every NewStream, Close and Reset frame sent or received.
+    /// Default: nil (no tracing)
+    public var wireTracer: WireTracer?
+
     /// Creates a Mplex configuration.
     public init(
         maxConcurrentStreams: Int = 1000,
+        maxIncomingStreams: Int = 1000,
+        maxOutgoingStreams: Int = 1000,
         maxPendingInboundStreams: Int = 100,
         maxFrameSize: Int = 1024 * 1024,
         maxReadBufferSize: Int = 8 * 1024 * 1024,
-        maxReadBufferSizePerStream: Int = 1024 * 1024
+        maxReadBufferSizePerStream: Int = 1024 * 1024,
+        receiveTimeout: Duration = .seconds(5),
+        wireTracer: WireTracer? = nil
     ) {
         self.maxConcurrentStreams = maxConcurrentStreams
+        self.maxIncomingStreams = maxIncomingStreams
+        self.maxOutgoingStreams = maxOutgoingStreams
         self.maxPendingInboundStreams = maxPendingInboundStreams
         self.maxFrameSize = maxFrameSize
         self.maxReadBufferSize = maxReadBufferSize
         self.maxReadBufferSizePerStream = maxReadBufferSizePerStream
+        self.receiveTimeout = receiveTimeout
+        self.wireTracer = wireTracer
     }
 
     /// Default configuration.
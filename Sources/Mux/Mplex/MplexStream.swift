@@ -28,6 +28,12 @@ private struct MplexStreamState: Sendable {
     /// Queue of readers waiting for data
     var readContinuations: [CheckedContinuation<ByteBuffer, Error>] = []
 
+    /// Read-loop deliveries parked until the application drains the buffer.
+    /// Resumed with `true` when space may be available, `false` on timeout.
+    var bufferSpaceWaiters: [(id: UInt64, continuation: CheckedContinuation<Bool, Never>)] = []
+    /// Next id to assign to a parked buffer-space waiter.
+    var nextBufferSpaceWaiterID: UInt64 = 0
+
     // Write direction state
     /// Local has closed write side (sent CLOSE)
     var localWriteClosed = false
@@ -43,6 +49,25 @@ private struct MplexStreamState: Sendable {
 
     /// Negotiated protocol for this stream
     var protocolID: String?
+
+    /// Takes all parked buffer-space waiters so they can be resumed outside the lock.
+    mutating func takeBufferSpaceWaiters() -> [CheckedContinuation<Bool, Never>] {
+        let waiters = bufferSpaceWaiters.map(\.continuation)
+        bufferSpaceWaiters = []
+        return waiters
+    }
+
+    /// Marks the stream reset after a buffer overflow and takes the waiting readers.
+    mutating func resetForOverflow() -> [CheckedContinuation<ByteBuffer, Error>] {
+        isReset = true
+        localWriteClosed = true
+        localReadClosed = true
+        remoteWriteClosed = true
+        readBuffer = ByteBuffer()
+        let conts = readContinuations
+        readContinuations = []
+        return conts
+    }
 }
 
 /// A multiplexed stream over a Mplex connection.
@@ -65,27 +90,37 @@ public final class MplexStream: MuxedStream, Sendable {
     /// Maximum read buffer size before reset (DoS protection).
     private let maxReadBufferSize: Int
 
-    init(id: UInt64, connection: MplexConnection, isInitiator: Bool, maxReadBufferSize: Int = 1024 * 1024) {
+    /// How long a delivery waits for the application to drain a full buffer.
+    private let receiveTimeout: Duration
+
+    init(
+        id: UInt64,
+        connection: MplexConnection,
+        isInitiator: Bool,
+        maxReadBufferSize: Int = 1024 * 1024,
+        receiveTimeout: Duration = .seconds(5)
+    ) {
         self.id = id
         self.connection = connection
         self.isInitiator = isInitiator
         self.maxReadBufferSize = maxReadBufferSize
+        self.receiveTimeout = receiveTimeout
         self.state = Mutex(MplexStreamState())
     }
 
     public func read() async throws -> ByteBuffer {
         try await withCheckedThrowingContinuation { continuation in
-            state.withLock { state in
+            let spaceWaiters = state.withLock { state -> [CheckedContinuation<Bool, Never>] in
                 // Reset state - immediate failure
                 if state.isReset {
                     continuation.resume(throwing: MplexError.streamClosed)
-                    return
+                    return []
                 }
 
                 // Local closed read side - immediate failure
                 if state.localReadClosed {
                     continuation.resume(throwing: MplexError.streamClosed)
-                    return
+                    return []
                 }
 
                 // Return buffered data if available
@@ -93,6 +128,8 @@ public final class MplexStream: MuxedStream, Sendable {
                     let data = state.readBuffer
                     state.readBuffer = ByteBuffer()
                     continuation.resume(returning: data)
+                    // The buffer was drained: let a parked delivery proceed
+                    return state.takeBufferSpaceWaiters()
                 } else if state.remoteWriteClosed {
                     // Remote closed and buffer empty - no more data coming
                     continuation.resume(throwing: MplexError.streamClosed)
@@ -100,6 +137,11 @@ public final class MplexStream: MuxedStream, Sendable {
                     // Queue this reader to wait for data
                     state.readContinuations.append(continuation)
                 }
+                return []
+            }
+
+            for waiter in spaceWaiters {
+                waiter.resume(returning: true)
             }
         }
     }
@@ -133,19 +175,23 @@ public final class MplexStream: MuxedStream, Sendable {
     public func closeRead() async throws {
         // Mark read side as closed locally
         // Received data after this will be discarded
-        let readConts = state.withLock { state -> [CheckedContinuation<ByteBuffer, Error>] in
-            if state.localReadClosed || state.isReset { return [] }
+        let (readConts, spaceWaiters) = state.withLock { state -> ([CheckedContinuation<ByteBuffer, Error>], [CheckedContinuation<Bool, Never>]) in
+            if state.localReadClosed || state.isReset { return ([], []) }
             state.localReadClosed = true
             state.readBuffer = ByteBuffer()
             let r = state.readContinuations
             state.readContinuations = []
-            return r
+            return (r, state.takeBufferSpaceWaiters())
         }
 
         // Resume all waiting readers with error
         for cont in readConts {
             cont.resume(throwing: MplexError.streamClosed)
         }
+        // Parked deliveries re-check and discard their data
+        for waiter in spaceWaiters {
+            waiter.resume(returning: true)
+        }
     }
 
     public func close() async throws {
@@ -167,7 +213,7 @@ public final class MplexStream: MuxedStream, Sendable {
     }
 
     public func reset() async throws {
-        let readConts = state.withLock { state -> [CheckedContinuation<ByteBuffer, Error>] in
+        let (readConts, spaceWaiters) = state.withLock { state -> ([CheckedContinuation<ByteBuffer, Error>], [CheckedContinuation<Bool, Never>]) in
             state.isReset = true
             state.localWriteClosed = true
             state.localReadClosed = true
@@ -175,13 +221,16 @@ public final class MplexStream: MuxedStream, Sendable {
             state.readBuffer = ByteBuffer()
             let r = state.readContinuations
             state.readContinuations = []
-            return r
+            return (r, state.takeBufferSpaceWaiters())
         }
 
         // Resume all waiting readers with error (outside lock)
         for cont in readConts {
             cont.resume(throwing: MplexError.streamClosed)
         }
+        for waiter in spaceWaiters {
+            waiter.resume(returning: true)
+        }
 
         let frame = MplexFrame.reset(id: id, isInitiator: isInitiator)
         try await connection.sendFrame(frame)
@@ -191,66 +240,128 @@ public final class MplexStream: MuxedStream, Sendable {
     // MARK: - Internal
 
     /// Called when data is received for this stream.
-    func dataReceived(_ data: ByteBuffer) {
+    ///
+    /// Mplex has no flow control, so backpressure comes from the read loop:
+    /// when the unread buffer cannot take `data`, this suspends (and with it
+    /// the connection's read loop) until the application reads, which stops
+    /// reading from the transport and throttles the sender. If the buffer is
+    /// not drained within `receiveTimeout`, the stream is reset.
+    func dataReceived(_ data: ByteBuffer) async {
         enum DataReceiveAction {
             case ignore
             case deliver(CheckedContinuation<ByteBuffer, Error>)
             case reset([CheckedContinuation<ByteBuffer, Error>])
+            case waitForSpace
         }
 
-        let action = state.withLock { state -> DataReceiveAction in
-            // Ignore if reset or read-closed
-            if state.isReset || state.localReadClosed {
+        while true {
+            let action = state.withLock { state -> DataReceiveAction in
+                // Ignore if reset or read-closed
+                if state.isReset || state.localReadClosed {
+                    return .ignore
+                }
+
+                // Enforce the per-stream unread-data bound before both direct
+                // delivery and buffering. A waiting reader must not bypass the DoS
+                // guard that protects streams without flow control.
+                guard data.readableBytes <= maxReadBufferSize else {
+                    return .reset(state.resetForOverflow())
+                }
+
+                // Deliver to waiting reader or buffer
+                if !state.readContinuations.isEmpty {
+                    let cont = state.readContinuations.removeFirst()
+                    return .deliver(cont)
+                }
+
+                // Hold the delivery until the application makes room.
+                if state.readBuffer.readableBytes + data.readableBytes > maxReadBufferSize {
+                    return .waitForSpace
+                }
+
+                state.readBuffer.writeImmutableBuffer(data)
                 return .ignore
             }
 
-            // Enforce the per-stream unread-data bound before both direct
-            // delivery and buffering. A waiting reader must not bypass the DoS
-            // guard that protects streams without flow control.
-            guard data.readableBytes <= maxReadBufferSize else {
-                state.isReset = true
-                state.localWriteClosed = true
-                state.localReadClosed = true
-                state.remoteWriteClosed = true
-                state.readBuffer = ByteBuffer()
-                let conts = state.readContinuations
-                state.readContinuations = []
-                return .reset(conts)
+            switch action {
+            case .ignore:
+                return
+            case .deliver(let cont):
+                cont.resume(returning: data)
+                return
+            case .reset(let conts):
+                resetAfterOverflow(conts)
+                return
+            case .waitForSpace:
+                if await waitForBufferSpace(needed: data.readableBytes) {
+                    continue
+                }
+                // The application did not drain the buffer in time.
+                let conts = state.withLock { state -> [CheckedContinuation<ByteBuffer, Error>] in
+                    guard !state.isReset && !state.localReadClosed else { return [] }
+                    return state.resetForOverflow()
+                }
+                resetAfterOverflow(conts)
+                return
             }
+        }
+    }
 
-            // Deliver to waiting reader or buffer
-            if !state.readContinuations.isEmpty {
-                let cont = state.readContinuations.removeFirst()
-                return .deliver(cont)
+    /// Suspends until the buffer may have room for `needed` bytes.
+    ///
+    /// - Returns: `true` if the buffer was drained (or the stream closed),
+    ///   `false` if `receiveTimeout` elapsed first.
+    private func waitForBufferSpace(needed: Int) async -> Bool {
+        var timeoutTask: Task<Void, Never>?
+        let drained = await withCheckedContinuation { (continuation: CheckedContinuation<Bool, Never>) in
+            let waiterID = state.withLock { state -> UInt64? in
+                // Re-check under the lock: a read may have drained the buffer
+                // between the delivery attempt and parking here.
+                if state.isReset || state.localReadClosed
+                    || !state.readContinuations.isEmpty
+                    || state.readBuffer.readableBytes + needed <= maxReadBufferSize {
+                    return nil
+                }
+                let id = state.nextBufferSpaceWaiterID
+                state.nextBufferSpaceWaiterID += 1
+                state.bufferSpaceWaiters.append((id: id, continuation: continuation))
+                return id
             }
 
-            // Check buffer size limit (DoS protection).
-            // Mplex has no flow control, so reset is the only safe response.
-            if state.readBuffer.readableBytes + data.readableBytes > maxReadBufferSize {
-                state.isReset = true
-                state.localWriteClosed = true
-                state.localReadClosed = true
-                state.remoteWriteClosed = true
-                state.readBuffer = ByteBuffer()
-                let conts = state.readContinuations
-                state.readContinuations = []
-                return .reset(conts)
+            guard let waiterID else {
+                continuation.resume(returning: true)
+                return
             }
 
-            state.readBuffer.writeImmutableBuffer(data)
-            return .ignore
+            let timeout = receiveTimeout
+            timeoutTask = Task { [weak self] in
+                do {
+                    try await Task.sleep(for: timeout)
+                } catch {
+                    return // Cancelled: the waiter was resumed by a drain
+                }
+                self?.timeOutBufferSpaceWaiter(id: waiterID)
+            }
         }
+        timeoutTask?.cancel()
+        return drained
+    }
 
-        switch action {
-        case .ignore:
-            return
-        case .deliver(let cont):
-            cont.resume(returning: data)
-            return
-        case .reset(let conts):
-            for cont in conts {
-                cont.resume(throwing: MplexError.readBufferOverflow)
+    /// Resumes a parked delivery with `false` if it is still waiting.
+    private func timeOutBufferSpaceWaiter(id: UInt64) {
+        let waiter = state.withLock { state -> CheckedContinuation<Bool, Never>? in
+            guard let index = state.bufferSpaceWaiters.firstIndex(where: { $0.id == id }) else {
+                return nil
             }
+            return state.bufferSpaceWaiters.remove(at: index).continuation
+        }
+        waiter?.resume(returning: false)
+    }
+
+    /// Fails waiting readers and sends RST after a buffer overflow.
+    private func resetAfterOverflow(_ conts: [CheckedContinuation<ByteBuffer, Error>]) {
+        for cont in conts {
+            cont.resume(throwing: MplexError.readBufferOverflow)
         }
 
         // Send reset frame outside lock to avoid deadlock
@@ -285,7 +396,7 @@ public final class MplexStream: MuxedStream, Sendable {
 
     /// Called when the stream is reset by remote (received RESET).
     func remoteReset() {
-        let readConts = state.withLock { state -> [CheckedContinuation<ByteBuffer, Error>] in
+        let (readConts, spaceWaiters) = state.withLock { state -> ([CheckedContinuation<ByteBuffer, Error>], [CheckedContinuation<Bool, Never>]) in
             state.isReset = true
             state.localWriteClosed = true
             state.localReadClosed = true
@@ -293,12 +404,15 @@ public final class MplexStream: MuxedStream, Sendable {
             state.readBuffer = ByteBuffer()
             let r = state.readContinuations
             state.readContinuations = []
-            return r
+            return (r, state.takeBufferSpaceWaiters())
         }
 
         // Resume all waiting continuations outside of lock
         for cont in readConts {
             cont.resume(throwing: MplexError.streamClosed)
         }
+        for waiter in spaceWaiters {
+            waiter.resume(returning: true)
+        }
     }
 }
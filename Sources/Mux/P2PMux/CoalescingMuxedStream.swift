@@ -0,0 +1,231 @@
+/// CoalescingMuxedStream - Write coalescing for MuxedStream
+import NIOCore
+import Synchronization
+
+/// Configuration for write coalescing on a `CoalescingMuxedStream`.
+public struct WriteCoalescingConfiguration: Sendable {
+
+    /// Pending bytes at which buffered writes are sent immediately.
+    ///
+    /// A single write at or above this size is handed to the muxer as-is
+    /// (after any earlier pending bytes) without being copied.
+    public var flushThreshold: Int
+
+    /// How long small writes may wait for more data before being sent.
+    public var flushDelay: Duration
+
+    public init(
+        flushThreshold: Int = 16 * 1024,
+        flushDelay: Duration = .milliseconds(1)
+    ) {
+        precondition(flushThreshold > 0, "flushThreshold must be positive")
+        self.flushThreshold = flushThreshold
+        self.flushDelay = flushDelay
+    }
+
+    /// Default configuration: 16 KiB threshold, 1 ms flush window.
+    public static let `default` = WriteCoalescingConfiguration()
+}
+
+private struct CoalescingStreamState: Sendable {
+    /// Writes not yet handed to the underlying stream.
+    var pending: [ByteBuffer] = []
+    var pendingBytes = 0
+    /// Fires after `flushDelay` to send pending writes.
+    var flushTimer: Task<Void, Never>?
+    /// Most recent write handed to the underlying stream. Each flush chains
+    /// on the previous one so data reaches the muxer in write order, and a
+    /// failed write fails every flush after it.
+    var lastWrite: Task<Void, Error>?
+    /// Write side closed or stream reset; writes go straight through.
+    var bypass = false
+    /// First error from a deferred send; every later write and flush
+    /// rethrows it.
+    var failure: (any Error)?
+}
+
+/// A `MuxedStream` that coalesces small consecutive writes.
+///
+/// Writes smaller than `flushThreshold` are buffered and sent as one muxer
+/// frame once the threshold is reached, the flush window elapses, or
+/// `flush()` is called. Pending data is always sent before `closeWrite()`
+/// and `close()`, and is handed to the muxer before `read()` waits, so
+/// request/response protocols never stall on a buffered request.
+///
+/// The first error from a deferred send is kept and rethrown by every later
+/// `write(_:)`, `flush()`, `closeWrite()` and `close()`, so buffered writes
+/// never appear to succeed on a broken stream. `reset()` discards pending
+/// data.
+public final class CoalescingMuxedStream: MuxedStream, Sendable {
+
+    /// The wrapped stream.
+    public let base: any MuxedStream
+    public let configuration: WriteCoalescingConfiguration
+
+    private let state: Mutex<CoalescingStreamState>
+
+    public init(_ base: any MuxedStream, configuration: WriteCoalescingConfiguration = .default) {
+        self.base = base
+        self.configuration = configuration
+        self.state = Mutex(CoalescingStreamState())
+    }
+
+    public var id: UInt64 { base.id }
+
+    public var protocolID: String? { base.protocolID }
+
+    public func read() async throws -> ByteBuffer {
+        // Hand off without waiting: a read must not block behind a write
+        // that is itself waiting on the peer's flow-control window.
+        _ = startFlush()
+        return try await base.read()
+    }
+
+    public func write(_ data: ByteBuffer) async throws {
+        enum Action {
+            case passthrough
+            case buffered
+            case flush
+            case failed(any Error)
+        }
+
+        let action: Action = state.withLock { s in
+            if let failure = s.failure { return .failed(failure) }
+            if s.bypass { return .passthrough }
+            guard data.readableBytes > 0 else { return .buffered }
+            s.pending.append(data)
+            s.pendingBytes += data.readableBytes
+            if s.pendingBytes >= configuration.flushThreshold {
+                return .flush
+            }
+            if s.flushTimer == nil {
+                let delay = configuration.flushDelay
+                s.flushTimer = Task { [weak self] in
+                    do {
+                        try await Task.sleep(for: delay)
+                    } catch {
+                        return
+                    }
+                    // Errors stay on the write chain for the next flush.
+                    _ = self?.startFlush()
+                }
+            }
+            return .buffered
+        }
+
+        switch action {
+        case .passthrough:
+            try await base.write(data)
+        case .buffered:
+            return
+        case .flush:
+            try await startFlush()?.value
+        case .failed(let error):
+            throw error
+        }
+    }
+
+    /// Sends all buffered writes and waits until the underlying stream has
+    /// accepted them.
+    public func flush() async throws {
+        if let failure = state.withLock({ $0.failure }) {
+            throw failure
+        }
+        try await startFlush()?.value
+    }
+
+    public func closeWrite() async throws {
+        try await flush()
+        state.withLock { $0.bypass = true }
+        try await base.closeWrite()
+    }
+
+    public func closeRead() async throws {
+        try await base.closeRead()
+    }
+
+    public func close() async throws {
+        try await flush()
+        state.withLock { $0.bypass = true }
+        try await base.close()
+    }
+
+    public func reset() async throws {
+        let timer = state.withLock { s -> Task<Void, Never>? in
+            let timer = s.flushTimer
+            s.flushTimer = nil
+            s.pending.removeAll()
+            s.pendingBytes = 0
+            s.bypass = true
+            return timer
+        }
+        timer?.cancel()
+        try await base.reset()
+    }
+
+    // MARK: - Private
+
+    /// Moves pending writes onto the write chain.
+    ///
+    /// - Returns: The task that completes once everything written so far has
+    ///   been accepted by the underlying stream, or `nil` if nothing was ever
+    ///   sent.
+    private func startFlush() -> Task<Void, Error>? {
+        let (timer, task) = state.withLock { s -> (Task<Void, Never>?, Task<Void, Error>?) in
+            let timer = s.flushTimer
+            s.flushTimer = nil
+            guard !s.pending.isEmpty else {
+                return (timer, s.lastWrite)
+            }
+            let chunks = s.pending
+            let totalBytes = s.pendingBytes
+            s.pending = []
+            s.pendingBytes = 0
+
+            let previous = s.lastWrite
+            let base = self.base
+            let task = Task { [weak self] in
+                if let previous {
+                    try await previous.value
+                }
+                do {
+                    try await base.write(Self.coalesce(chunks, totalBytes: totalBytes))
+                } catch {
+                    self?.state.withLock { s in
+                        if s.failure == nil { s.failure = error }
+                    }
+                    throw error
+                }
+            }
+            s.lastWrite = task
+            return (timer, task)
+        }
+        timer?.cancel()
+        return task
+    }
+
+    /// Joins chunks into one buffer; a single chunk is returned uncopied.
+    private static func coalesce(_ chunks: [ByteBuffer], totalBytes: Int) -> ByteBuffer {
+        if chunks.count == 1 {
+            return chunks[0]
+        }
+        var combined = ByteBufferAllocator().buffer(capacity: totalBytes)
+        for var chunk in chunks {
+            combined.writeBuffer(&chunk)
+        }
+        return combined
+    }
+}
+
+extension MuxedStream {
+
+    /// Wraps this stream so that small consecutive writes are coalesced.
+    ///
+    /// - Parameter configuration: Threshold and flush window for coalescing.
+    /// - Returns: A write-coalescing view of this stream.
+    public func coalescingWrites(
+        configuration: WriteCoalescingConfiguration = .default
+    ) -> CoalescingMuxedStream {
+        CoalescingMuxedStream(self, configuration: configuration)
+    }
+}
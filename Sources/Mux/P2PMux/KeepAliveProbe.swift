@@ -0,0 +1,39 @@
+/// KeepAliveProbe - Connection-native liveness probes
+///
+/// A connection whose transport or muxer can check that the peer is still
+/// there without opening a stream exposes a `KeepAliveProbe` through
+/// `MuxedConnection.keepAliveProbe`: QUIC connections probe with PING frames,
+/// Yamux sessions with Yamux pings. The node's keepalive policy uses the probe
+/// when there is one and falls back to `/ipfs/ping/1.0.0` otherwise.
+
+/// How a connection's liveness is checked.
+public enum KeepAliveMechanism: String, Sendable, Hashable {
+    /// QUIC PING frames, acknowledged by the peer's QUIC stack.
+    case quicPing = "quic-ping"
+
+    /// The muxer's own ping (Yamux type 0x2 frames).
+    case muxerPing = "muxer-ping"
+
+    /// A `/ipfs/ping/1.0.0` round trip on a new stream.
+    case libp2pPing = "libp2p-ping"
+}
+
+/// Checks that the peer at the other end of a connection is responsive.
+public protocol KeepAliveProbe: Sendable {
+
+    /// The mechanism `probe()` uses.
+    var mechanism: KeepAliveMechanism { get }
+
+    /// Sends one probe and waits for the peer's answer.
+    ///
+    /// Does not time out on its own; callers bound it and cancel it.
+    ///
+    /// - Returns: The round-trip time.
+    /// - Throws: If the connection is closed or the task is cancelled.
+    func probe() async throws -> Duration
+
+    /// Stops any keepalive the connection runs on its own, so that a caller
+    /// probing on its own schedule is the only one deciding the connection
+    /// is dead.
+    func disableBuiltInKeepAlive()
+}
@@ -32,6 +32,13 @@ public protocol MuxedStream: Sendable {
     /// Writes data to the stream.
     func write(_ data: ByteBuffer) async throws
 
+    /// Sends any data buffered by `write(_:)` that has not yet been handed
+    /// to the muxer.
+    ///
+    /// Streams that write through immediately need not implement this; the
+    /// default implementation does nothing.
+    func flush() async throws
+
     /// Closes the stream for writing (half-close).
     ///
     /// After calling this, `write()` will fail but `read()` can still receive data
@@ -57,6 +64,10 @@ public protocol MuxedStream: Sendable {
     func reset() async throws
 }
 
+extension MuxedStream {
+    public func flush() async throws {}
+}
+
 /// A multiplexed connection that can create multiple streams.
 public protocol MuxedConnection: Sendable {
     /// The local peer ID.
@@ -85,6 +96,80 @@ public protocol MuxedConnection: Sendable {
 
     /// Closes all streams and the connection.
     func close() async throws
+
+    /// The transport, security protocol and muxer the connection was built
+    /// with, or `nil` if the connection does not record them.
+    ///
+    /// Connections upgraded by `NegotiatingUpgrader` report their stack.
+    /// The default implementation returns `nil`.
+    var negotiatedStack: ConnectionStack? { get }
+
+    /// A probe that checks the peer's liveness without opening a stream, or
+    /// `nil` if the connection has none.
+    ///
+    /// The default implementation returns `nil`.
+    var keepAliveProbe: (any KeepAliveProbe)? { get }
+
+    /// The connection the muxer created, below any wrapper.
+    ///
+    /// A wrapper returns its wrapped connection's `muxerConnection`, so
+    /// muxer-specific API (such as `YamuxConnection.goAway(reason:)`) stays
+    /// reachable through it. The default implementation returns `self`.
+    var muxerConnection: any MuxedConnection { get }
+}
+
+extension MuxedConnection {
+    public var negotiatedStack: ConnectionStack? { nil }
+
+    public var keepAliveProbe: (any KeepAliveProbe)? { nil }
+
+    public var muxerConnection: any MuxedConnection { self }
+
+    /// The transport the connection runs over (e.g. "tcp", "ws", "memory").
+    public var transport: String? { negotiatedStack?.transport }
+
+    /// The negotiated security protocol (e.g. "/noise", "/tls/1.0.0").
+    public var securityProtocol: String? { negotiatedStack?.securityProtocol }
+
+    /// The negotiated muxer protocol (e.g. "/yamux/1.0.0", "/mplex/6.7.0").
+    public var muxerProtocol: String? { negotiatedStack?.muxerProtocol }
+}
+
+/// The layers a connection was upgraded through.
+public struct ConnectionStack: Sendable, Hashable {
+
+    /// How the muxer was agreed.
+    public enum MuxerSelection: String, Sendable, Hashable {
+        /// Inside the security handshake (TLS ALPN muxer hints).
+        case securityHandshake
+        /// By multistream-select over the secured connection.
+        case multistreamSelect
+    }
+
+    /// The outermost transport protocol of the remote address
+    /// (e.g. "tcp", "ws", "memory").
+    public let transport: String
+
+    /// The negotiated security protocol ID.
+    public let securityProtocol: String
+
+    /// The negotiated muxer protocol ID.
+    public let muxerProtocol: String
+
+    /// How `muxerProtocol` was selected.
+    public let muxerSelection: MuxerSelection
+
+    public init(
+        transport: String,
+        securityProtocol: String,
+        muxerProtocol: String,
+        muxerSelection: MuxerSelection
+    ) {
+        self.transport = transport
+        self.securityProtocol = securityProtocol
+        self.muxerProtocol = muxerProtocol
+        self.muxerSelection = muxerSelection
+    }
 }
 
 /// A muxer that upgrades secured connections.
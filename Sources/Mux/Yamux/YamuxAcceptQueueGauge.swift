@@ -0,0 +1,30 @@
+/// YamuxAcceptQueueGauge - Accept-queue depth summed over connections
+import Synchronization
+import P2PCore
+
+/// Publishes the accept-queue depth of all connections sharing it as one
+/// `LibP2PMetrics.muxerAcceptQueueDepth` series.
+///
+/// Connections report changes of their own depth; the gauge keeps the sum,
+/// so the series is labelled only with the muxer and its cardinality does
+/// not grow with the number of peers.
+final class YamuxAcceptQueueGauge: Sendable {
+
+    private let exporter: any MetricsExporter
+    private let total = Mutex(0)
+
+    init(exporter: any MetricsExporter) {
+        self.exporter = exporter
+    }
+
+    /// Adds `delta` to the total and publishes it.
+    func adjust(by delta: Int) {
+        guard delta != 0 else { return }
+        // Published under the lock so concurrent updates reach the
+        // exporter in the order they were summed.
+        total.withLock { total in
+            total += delta
+            exporter.set(LibP2PMetrics.muxerAcceptQueueDepth, to: Double(total), labels: ["muxer": "yamux"])
+        }
+    }
+}
@@ -5,7 +5,7 @@ import P2PCore
 import P2PMux
 import Synchronization
 
-private let logger = Logger(label: "p2p.mux.yamux.connection")
+private let logger = SubsystemLogger(.yamux, label: "p2p.mux.yamux.connection")
 
 /// Threshold for compacting the read buffer (64KB)
 private let readBufferCompactThreshold = 64 * 1024
@@ -24,19 +24,44 @@ private let yamuxControlFrameQueueCapacity = 1024
 /// and corrupt the frame boundary on the wire.
 private actor FrameWriter {
     private let connection: any SecuredConnection
+    private let wireTracer: WireTracer?
     private var scratchBuffer = ByteBuffer()
 
-    init(connection: any SecuredConnection) {
+    init(connection: any SecuredConnection, wireTracer: WireTracer?) {
         self.connection = connection
+        self.wireTracer = wireTracer
     }
 
     func write(_ frame: YamuxFrame) async throws {
         scratchBuffer.clear()
         frame.encode(into: &scratchBuffer)
+        if let wireTracer, let phase = frame.controlPhase {
+            wireTracer.trace(.outbound, phase, Array(scratchBuffer.readableBytesView.prefix(yamuxHeaderSize)))
+        }
         try await connection.write(scratchBuffer)
     }
 }
 
+extension YamuxFrame {
+    /// The trace phase of a control frame, or `nil` for a data frame
+    /// without flags.
+    var controlPhase: WirePhase? {
+        switch type {
+        case .data: return flags.isEmpty ? nil : WirePhase.yamuxData
+        case .windowUpdate: return .yamuxWindowUpdate
+        case .ping: return .yamuxPing
+        case .goAway: return .yamuxGoAway
+        }
+    }
+
+    /// The encoded 12-byte header.
+    var headerBytes: [UInt8] {
+        var buffer = ByteBuffer()
+        YamuxFrame(type: type, flags: flags, streamID: streamID, length: length, data: nil).encode(into: &buffer)
+        return Array(buffer.readableBytesView)
+    }
+}
+
 /// Internal state for YamuxConnection.
 private struct YamuxConnectionState: Sendable {
     var streams: [UInt64: YamuxStream] = [:]
@@ -49,20 +74,56 @@ private struct YamuxConnectionState: Sendable {
     var nextAcceptID: UInt64 = 0
     var isClosed = false
     var isStarted = false
-    /// GoAway received - reject new streams but allow existing to continue
-    var isGoAwayReceived = false
+    /// Reason of the GoAway received from the remote peer, if any. The
+    /// session is draining: no new outbound streams, existing ones continue.
+    var remoteGoAway: YamuxGoAwayReason?
+    /// GoAway sent to the remote peer - reject new inbound streams
+    var isGoAwaySent = false
     var readBuffer = ByteBuffer()
-    var inboundContinuation: AsyncStream<MuxedStream>.Continuation?
+    /// Inbound streams ACKed and waiting to be accepted, oldest first. Holds
+    /// at most `maxPendingInboundStreams`.
+    var acceptQueue: [YamuxStream] = []
+    /// Inbound streams that arrived while `acceptQueue` was full. Their SYN
+    /// stays unanswered until they move up into the queue.
+    var deferredAccepts: [YamuxStream] = []
+    /// Accept-queue depth last added to the shared gauge.
+    var reportedAcceptQueueDepth = 0
     /// Pending keep-alive pings awaiting pong response. Maps ping ID to send time.
     var pendingPings: [UInt32: ContinuousClock.Instant] = [:]
     /// Next ping ID to use for keep-alive.
     var nextPingID: UInt32 = 1
+    /// `probe()` callers awaiting the ack of their ping, keyed by ping ID.
+    var pingWaiters: [UInt32: CheckedContinuation<Void, Error>] = [:]
+    /// Set by `disableBuiltInKeepAlive()`; the keep-alive loop does not run.
+    var isBuiltInKeepAliveDisabled = false
 
     init(isInitiator: Bool) {
         // Initiator uses odd IDs, responder uses even IDs
         self.nextStreamID = isInitiator ? 1 : 2
     }
 
+    /// Number of open streams opened by the initiator (odd IDs) or by the
+    /// responder (even IDs).
+    func streamCount(openedByInitiator: Bool) -> Int {
+        streams.keys.count(where: { ($0 % 2 == 1) == openedByInitiator })
+    }
+
+    /// Inbound streams waiting to be accepted, ACKed or not.
+    var acceptQueueDepth: Int {
+        acceptQueue.count + deferredAccepts.count
+    }
+
+    /// Takes the oldest queued inbound stream and moves the oldest deferred
+    /// one, if any, into the slot it frees.
+    mutating func dequeueAccept() -> (stream: YamuxStream, promoted: YamuxStream?)? {
+        guard !acceptQueue.isEmpty else { return nil }
+        let stream = acceptQueue.removeFirst()
+        guard !deferredAccepts.isEmpty else { return (stream, nil) }
+        let promoted = deferredAccepts.removeFirst()
+        acceptQueue.append(promoted)
+        return (stream, promoted)
+    }
+
     /// Compacts the read buffer if consumed portion exceeds threshold.
     mutating func compactReadBufferIfNeeded() {
         if readBuffer.readerIndex > readBufferCompactThreshold {
@@ -89,6 +150,27 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         state.withLock { !$0.streams.isEmpty }
     }
 
+    /// Session events, such as a GoAway from the remote peer (single consumer).
+    ///
+    /// Events emitted before the first access are not retained; check
+    /// `remoteGoAwayReason` for a GoAway that may already have arrived.
+    public var events: AsyncStream<YamuxSessionEvent> { eventChannel.stream }
+
+    /// The reason of the GoAway received from the remote peer, or `nil` if
+    /// none has arrived. Non-`nil` means the session is draining.
+    public var remoteGoAwayReason: YamuxGoAwayReason? {
+        state.withLock { $0.remoteGoAway }
+    }
+
+    /// Inbound streams received but not yet accepted, including those whose
+    /// SYN is held back because the accept queue is full.
+    ///
+    /// Also published as `LibP2PMetrics.muxerAcceptQueueDepth` when
+    /// `YamuxConfiguration.metrics` is set.
+    public var pendingInboundStreamCount: Int {
+        state.withLock { $0.acceptQueueDepth }
+    }
+
     /// Internal diagnostic used by tests to wait for deterministic accept parking.
     var pendingAcceptCountForTesting: Int {
         state.withLock { $0.pendingAccepts.count }
@@ -106,9 +188,30 @@ public final class YamuxConnection: MuxedConnection, Sendable {
     private let frameWriter: FrameWriter
     /// Decouples control-frame sends from the read loop (head-of-line safety).
     private let controlQueue: ControlFrameQueue
+    private let eventChannel = EventChannel<YamuxSessionEvent>()
+    /// Sums the accept-queue depth with the other connections of the muxer.
+    private let acceptQueueGauge: YamuxAcceptQueueGauge?
 
+    /// Inbound streams in arrival order, taken from the same accept queue as
+    /// `acceptStream()` (single consumer). Iteration ends when the connection
+    /// closes or the iterating task is cancelled.
     public let inboundStreams: AsyncStream<MuxedStream>
 
+    /// Weak reference to the connection for `inboundStreams`, which is
+    /// created before `self` is available and must not retain it.
+    private final class AcceptSource: Sendable {
+        private struct WeakRef: Sendable {
+            weak var value: YamuxConnection?
+        }
+
+        private let ref = Mutex(WeakRef())
+
+        var connection: YamuxConnection? {
+            get { ref.withLock { $0.value } }
+            set { ref.withLock { $0.value = newValue } }
+        }
+    }
+
     private let readTask: Mutex<Task<Void, Never>?>
     private let keepAliveTask: Mutex<Task<Void, Never>?>
     private let controlDrainTask: Mutex<Task<Void, Never>?>
@@ -118,33 +221,36 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         localPeer: PeerID,
         remotePeer: PeerID,
         isInitiator: Bool,
-        configuration: YamuxConfiguration = .default
+        configuration: YamuxConfiguration = .default,
+        acceptQueueGauge: YamuxAcceptQueueGauge? = nil
     ) {
         self.underlying = underlying
         self.localPeer = localPeer
         self.remotePeer = remotePeer
         self.isInitiator = isInitiator
         self.configuration = configuration
-        self.frameWriter = FrameWriter(connection: underlying)
+        self.frameWriter = FrameWriter(connection: underlying, wireTracer: configuration.wireTracer)
         self.rttEstimator = RTTEstimator()
         self.connectionFlowController = ConnectionFlowController(
             maxReceiveWindow: configuration.connectionReceiveWindow
         )
         self.controlQueue = ControlFrameQueue(capacity: yamuxControlFrameQueueCapacity)
+        self.acceptQueueGauge = acceptQueueGauge ?? configuration.metrics.map { YamuxAcceptQueueGauge(exporter: $0) }
 
-        var initialState = YamuxConnectionState(isInitiator: isInitiator)
-
-        // Create bounded AsyncStream for inbound streams with backpressure support
-        let (inboundStream, inboundContinuation) = AsyncStream<MuxedStream>.makeStream(
-            bufferingPolicy: .bufferingOldest(configuration.maxPendingInboundStreams)
-        )
-        self.inboundStreams = inboundStream
-        initialState.inboundContinuation = inboundContinuation
-
-        self.state = Mutex(initialState)
+        self.state = Mutex(YamuxConnectionState(isInitiator: isInitiator))
         self.readTask = Mutex(nil)
         self.keepAliveTask = Mutex(nil)
         self.controlDrainTask = Mutex(nil)
+
+        let source = AcceptSource()
+        self.inboundStreams = AsyncStream(unfolding: {
+            do {
+                return try await source.connection?.acceptStream()
+            } catch {
+                return nil
+            }
+        })
+        source.connection = self
     }
 
     /// Starts the read loop and keep-alive timer. Must be called after init.
@@ -174,7 +280,8 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         readTask.withLock { $0 = task }
 
         // Start keep-alive loop if enabled
-        if configuration.enableKeepAlive {
+        let keepAliveDisabled = state.withLock { $0.isBuiltInKeepAliveDisabled }
+        if configuration.enableKeepAlive && !keepAliveDisabled {
             let keepAlive: Task<Void, Never> = Task { [weak self] in
                 await self?.keepAliveLoop()
             }
@@ -185,9 +292,10 @@ public final class YamuxConnection: MuxedConnection, Sendable {
     public func newStream() async throws -> MuxedStream {
         // Result type to capture both value and potential error from lock
         enum StreamIDResult {
-            case success(UInt32)
+            case success(UInt32, YamuxStream)
             case closed
-            case goAwayReceived
+            case goAwayReceived(YamuxGoAwayReason)
+            case limitReached(Int)
             case exhausted
         }
 
@@ -195,8 +303,12 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             if state.isClosed {
                 return .closed
             }
-            if state.isGoAwayReceived {
-                return .goAwayReceived
+            if let reason = state.remoteGoAway {
+                return .goAwayReceived(reason)
+            }
+            let outgoing = state.streamCount(openedByInitiator: isInitiator)
+            if outgoing >= configuration.maxOutgoingStreams {
+                return .limitReached(outgoing)
             }
 
             let id = state.nextStreamID
@@ -208,24 +320,28 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             }
 
             state.nextStreamID = newID
-            return .success(id)
+            // Insert under the same lock so concurrent opens count each other
+            let stream = YamuxStream(id: UInt64(id), connection: self, initialWindowSize: configuration.initialWindowSize)
+            state.streams[UInt64(id)] = stream
+            return .success(id, stream)
         }
 
         let streamID: UInt32
+        let stream: YamuxStream
         switch result {
-        case .success(let id):
+        case .success(let id, let openedStream):
             streamID = id
-        case .closed, .goAwayReceived:
+            stream = openedStream
+        case .closed:
             throw YamuxError.connectionClosed
+        case .goAwayReceived(let reason):
+            throw YamuxError.remoteGoAway(reason)
+        case .limitReached(let current):
+            throw YamuxError.maxStreamsExceeded(current: current, max: configuration.maxOutgoingStreams)
         case .exhausted:
             throw YamuxError.streamIDExhausted
         }
 
-        let stream = YamuxStream(id: UInt64(streamID), connection: self, initialWindowSize: configuration.initialWindowSize)
-        state.withLock { state in
-            state.streams[UInt64(streamID)] = stream
-        }
-
         // Send SYN frame - clean up on failure to prevent leak
         do {
             let frame = YamuxFrame(
@@ -243,6 +359,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             }
             throw error
         }
+        logger.trace("Yamux stream opened", peer: remotePeer, metadata: ["stream": "\(streamID)", "direction": "outbound"])
 
         return stream
     }
@@ -268,9 +385,20 @@ public final class YamuxConnection: MuxedConnection, Sendable {
 
         return try await withTaskCancellationHandler {
             try await withCheckedThrowingContinuation { (continuation: CheckedContinuation<MuxedStream, Error>) in
-                let immediateError: Error? = state.withLock { state in
+                enum Immediate {
+                    case fail(Error)
+                    case queued(YamuxStream, promoted: YamuxStream?, depth: Int)
+                }
+
+                let immediate: Immediate? = state.withLock { state in
                     if state.isClosed {
-                        return YamuxError.connectionClosed
+                        return .fail(YamuxError.connectionClosed)
+                    }
+
+                    // Checked under the same lock as parking, so a stream
+                    // queued just before cannot be left behind a parked waiter.
+                    if let next = state.dequeueAccept() {
+                        return .queued(next.stream, promoted: next.promoted, depth: state.acceptQueueDepth)
                     }
 
                     state.pendingAccepts.append((id: id, continuation: continuation))
@@ -282,14 +410,23 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                         if let index = state.pendingAccepts.firstIndex(where: { $0.id == id }) {
                             _ = state.pendingAccepts.remove(at: index)
                         }
-                        return CancellationError()
+                        return .fail(CancellationError())
                     }
 
                     return nil
                 }
 
-                if let immediateError {
-                    continuation.resume(throwing: immediateError)
+                switch immediate {
+                case .fail(let error):
+                    continuation.resume(throwing: error)
+                case .queued(let stream, let promoted, let depth):
+                    if let promoted {
+                        acknowledgeDeferred(promoted)
+                    }
+                    reportAcceptQueueDepth(depth)
+                    continuation.resume(returning: stream)
+                case nil:
+                    break
                 }
             }
         } onCancel: {
@@ -317,7 +454,56 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         continuation?.resume(throwing: CancellationError())
     }
 
+    /// Sends the ACK held back for a stream that has moved from the deferred
+    /// list into the accept queue, unless the peer reset it meanwhile.
+    private func acknowledgeDeferred(_ stream: YamuxStream) {
+        let isOpen = state.withLock { $0.streams[stream.id] != nil }
+        guard isOpen else { return }
+        let ack = YamuxFrame(type: .data, flags: .ack, streamID: UInt32(stream.id), length: 0, data: nil)
+        do {
+            try enqueueControlFrame(ack, context: "ACK deferred inbound stream")
+        } catch {
+            abruptShutdown(error: .readBufferOverflow)
+        }
+    }
+
+    /// Adds the change in accept-queue depth to the shared gauge, if a
+    /// metrics exporter is configured.
+    private func reportAcceptQueueDepth(_ depth: Int) {
+        guard let acceptQueueGauge else { return }
+        let delta = state.withLock { state in
+            defer { state.reportedAcceptQueueDepth = depth }
+            return depth - state.reportedAcceptQueueDepth
+        }
+        acceptQueueGauge.adjust(by: delta)
+    }
+
+    /// Sends GoAway without closing the session.
+    ///
+    /// New inbound streams are refused from then on, while streams already
+    /// open keep working until they finish or `close()` is called. Only the
+    /// first call sends a frame; `close()` sends none after it.
+    ///
+    /// - Parameter reason: The reason reported to the peer. Default: `.normal`.
+    public func goAway(reason: YamuxGoAwayReason = .normal) async throws {
+        let shouldSend = state.withLock { state -> Bool in
+            guard !state.isClosed, !state.isGoAwaySent else { return false }
+            state.isGoAwaySent = true
+            return true
+        }
+        guard shouldSend else { return }
+        try await sendFrame(.goAway(reason: reason))
+    }
+
     public func close() async throws {
+        // Send GoAway before anything else so the peer stops opening streams
+        // while ours are closed (best effort)
+        do {
+            try await goAway(reason: .normal)
+        } catch {
+            logger.debug("Best-effort Yamux frame send failed (connection close go-away): \(error)")
+        }
+
         // Atomically capture state - returns nil if already closed
         guard let capture = captureForShutdown() else {
             return
@@ -332,10 +518,8 @@ public final class YamuxConnection: MuxedConnection, Sendable {
 
         // Notify continuations
         capture.notifyContinuations(error: YamuxError.connectionClosed)
-
-        // Send GoAway (best effort)
-        let frame = YamuxFrame.goAway(reason: .normal)
-        await sendFrameBestEffort(frame, context: "connection close go-away")
+        reportAcceptQueueDepth(0)
+        eventChannel.finish()
 
         // Close all streams gracefully (sends FIN frames)
         await capture.closeAllStreamsGracefully()
@@ -358,6 +542,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         state.withLock { state in
             _ = state.streams.removeValue(forKey: id)
         }
+        logger.trace("Yamux stream closed", peer: remotePeer, metadata: ["stream": "\(id)"])
     }
 
     // MARK: - Connection-Level Flow Control
@@ -443,6 +628,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             data: nil
         )
         try enqueueControlFrame(rstFrame, context: "RST: \(context)")
+        logger.debug("Yamux stream reset: \(context)", peer: remotePeer, metadata: ["stream": "\(streamID)"])
     }
 
     /// Enqueues a control frame to be sent by the drain task.
@@ -502,6 +688,9 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                         return frame
                     }
                     guard let frame else { break }
+                    if let wireTracer = configuration.wireTracer, let phase = frame.controlPhase {
+                        wireTracer.trace(.inbound, phase, frame.headerBytes)
+                    }
                     try await handleFrame(frame)
                 }
             }
@@ -521,7 +710,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         case .ping:
             try await handlePing(frame)
         case .goAway:
-            try handleGoAway(frame)
+            handleGoAway(frame)
         }
     }
 
@@ -562,8 +751,8 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             }
 
             let result: SynResult = state.withLock { state -> SynResult in
-                // Reject new streams after GoAway received
-                if state.isGoAwayReceived {
+                // Reject new streams after we sent GoAway
+                if state.isGoAwaySent {
                     return .rejectGoAway
                 }
 
@@ -576,6 +765,9 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                 if state.streams.count >= configuration.maxConcurrentStreams {
                     return .rejectLimit
                 }
+                if state.streamCount(openedByInitiator: !isInitiator) >= configuration.maxIncomingStreams {
+                    return .rejectLimit
+                }
 
                 // Create and insert stream atomically
                 let stream = YamuxStream(id: streamID, connection: self, initialWindowSize: configuration.initialWindowSize)
@@ -587,7 +779,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             let stream: YamuxStream
             switch result {
             case .rejectGoAway:
-                // GoAway received - reject new streams
+                // GoAway sent - reject new streams
                 try enqueueReset(
                     streamID: frame.streamID,
                     context: "reject new stream after go-away"
@@ -612,23 +804,32 @@ public final class YamuxConnection: MuxedConnection, Sendable {
 
             case .accept(let acceptedStream):
                 stream = acceptedStream
+                logger.trace("Yamux stream opened", peer: remotePeer, metadata: ["stream": "\(streamID)", "direction": "inbound"])
             }
 
-            // Determine delivery mechanism and deliver with proper backpressure
-            // ACK is sent AFTER successful delivery to ensure protocol consistency
-            enum DeliveryResult {
-                case directDelivery(CheckedContinuation<MuxedStream, Error>, YamuxStream)
-                case bufferDelivery(AsyncStream<MuxedStream>.Continuation?, YamuxStream)
+            // Hand the stream to a parked accept, or queue it. A stream that
+            // fits in the accept queue is ACKed right away; past the queue
+            // bound its SYN is left unanswered until the consumer takes one,
+            // so the peer's own limit on unacknowledged streams throttles it
+            // while the stream table still caps how many can pile up.
+            enum Delivery {
+                case direct(CheckedContinuation<MuxedStream, Error>)
+                case queued(depth: Int)
+                case deferred(depth: Int)
             }
 
-            let deliveryResult = state.withLock { state -> DeliveryResult in
+            let delivery = state.withLock { state -> Delivery in
                 if !state.pendingAccepts.isEmpty {
                     // FIFO: the oldest parked waiter receives the stream. Removing
                     // it by index is the exactly-once guard against cancel/shutdown.
-                    let waiter = state.pendingAccepts.removeFirst()
-                    return .directDelivery(waiter.continuation, stream)
+                    return .direct(state.pendingAccepts.removeFirst().continuation)
                 }
-                return .bufferDelivery(state.inboundContinuation, stream)
+                if state.acceptQueue.count < configuration.maxPendingInboundStreams {
+                    state.acceptQueue.append(stream)
+                    return .queued(depth: state.acceptQueueDepth)
+                }
+                state.deferredAccepts.append(stream)
+                return .deferred(depth: state.acceptQueueDepth)
             }
 
             let ackFrame = YamuxFrame(
@@ -639,8 +840,8 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                 data: nil
             )
 
-            switch deliveryResult {
-            case .directDelivery(let cont, let deliveredStream):
+            switch delivery {
+            case .direct(let cont):
                 // Direct delivery to waiting accepter. ACK is enqueued (not sent
                 // inline) so a back-pressured transport cannot stall the read
                 // loop. ACK is enqueued only AFTER the stream is delivered, so a
@@ -648,7 +849,7 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                 // connection down.
                 do {
                     try enqueueControlFrame(ackFrame, context: "ACK direct delivery")
-                    cont.resume(returning: deliveredStream)
+                    cont.resume(returning: stream)
                 } catch {
                     // Control queue full - clean up stream and resume with error
                     state.withLock { _ = $0.streams.removeValue(forKey: streamID) }
@@ -656,40 +857,17 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                     throw error
                 }
 
-            case .bufferDelivery(let continuation, let deliveredStream):
-                guard let continuation = continuation else {
-                    // No consumer available - reject stream
-                    try enqueueReset(
-                        streamID: frame.streamID,
-                        context: "reject inbound stream with no continuation"
-                    )
-                    state.withLock { _ = $0.streams.removeValue(forKey: streamID) }
-                    return
-                }
-
-                // Try to buffer - check result for backpressure
-                let yieldResult = continuation.yield(deliveredStream)
+            case .queued(let depth):
+                reportAcceptQueueDepth(depth)
+                try enqueueControlFrame(ackFrame, context: "ACK queued delivery")
 
-                switch yieldResult {
-                case .enqueued:
-                    // Success - enqueue ACK
-                    try enqueueControlFrame(ackFrame, context: "ACK buffer delivery")
-
-                case .dropped:
-                    // Buffer full - reject with RST (proper backpressure)
-                    try enqueueReset(
-                        streamID: frame.streamID,
-                        context: "reject dropped inbound stream"
-                    )
-                    state.withLock { _ = $0.streams.removeValue(forKey: streamID) }
-
-                case .terminated:
-                    // Connection closing - clean up
-                    state.withLock { _ = $0.streams.removeValue(forKey: streamID) }
-
-                @unknown default:
-                    break
-                }
+            case .deferred(let depth):
+                reportAcceptQueueDepth(depth)
+                logger.trace(
+                    "Yamux accept queue full, deferring ACK",
+                    peer: remotePeer,
+                    metadata: ["stream": "\(streamID)", "depth": "\(depth)"]
+                )
             }
         }
 
@@ -745,9 +923,11 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             // Get stream and optionally remove in single lock
             let stream = state.withLock { state -> YamuxStream? in
                 let s = state.streams[streamID]
-                // RST removes the stream from the map
+                // RST removes the stream from the map, and from the deferred
+                // list: the peer gave up before we acknowledged it
                 if hasRst {
                     _ = state.streams.removeValue(forKey: streamID)
+                    state.deferredAccepts.removeAll { $0.id == streamID }
                 }
                 return s
             }
@@ -756,9 +936,11 @@ public final class YamuxConnection: MuxedConnection, Sendable {
             if let stream = stream {
                 if hasRst {
                     // RST takes precedence - abrupt termination
+                    logger.trace("Yamux stream reset by remote", peer: remotePeer, metadata: ["stream": "\(streamID)"])
                     stream.remoteReset()
                 } else if hasFin {
                     // Graceful close
+                    logger.trace("Yamux stream half-closed by remote", peer: remotePeer, metadata: ["stream": "\(streamID)"])
                     stream.remoteClose()
                 }
             }
@@ -774,10 +956,12 @@ public final class YamuxConnection: MuxedConnection, Sendable {
     private func handlePing(_ frame: YamuxFrame) async throws {
         if frame.flags.contains(.ack) {
             // Pong response - remove from pending pings and record RTT (B1)
-            state.withLock { state in
+            let waiter = state.withLock { state in
                 _ = state.pendingPings.removeValue(forKey: frame.length)
+                return state.pingWaiters.removeValue(forKey: frame.length)
             }
             rttEstimator.pongReceived(id: frame.length)
+            waiter?.resume()
             return
         }
 
@@ -787,12 +971,27 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         try enqueueControlFrame(pong, context: "pong response")
     }
 
-    private func handleGoAway(_ frame: YamuxFrame) throws {
-        // GoAway signals session termination.
-        // Reset all existing streams and reject new ones.
-        abruptShutdown(error: .connectionClosed)
-        // Throw to exit the readLoop (prevents blocking on underlying.read())
-        throw YamuxError.connectionClosed
+    /// Moves the session to draining: `newStream()` fails from now on, while
+    /// open streams and inbound SYNs are still served, as in go-yamux. The
+    /// session ends when the peer closes the connection.
+    private func handleGoAway(_ frame: YamuxFrame) {
+        let reason: YamuxGoAwayReason
+        if let known = YamuxGoAwayReason(rawValue: frame.length) {
+            reason = known
+        } else {
+            logger.debug("Unknown Yamux GoAway code \(frame.length), treating as protocol error")
+            reason = .protocolError
+        }
+
+        let isFirst = state.withLock { state -> Bool in
+            guard state.remoteGoAway == nil else { return false }
+            state.remoteGoAway = reason
+            return true
+        }
+        guard isFirst else { return }
+
+        logger.debug("Yamux session from \(remotePeer) received GoAway (\(reason)), draining")
+        eventChannel.yield(.sessionGoAway(reason: reason))
     }
 
     // MARK: - Shutdown Infrastructure
@@ -800,20 +999,22 @@ public final class YamuxConnection: MuxedConnection, Sendable {
     /// Captured state during shutdown, processed outside the lock.
     private struct ShutdownCapture {
         let streams: [YamuxStream]
-        let inboundContinuation: AsyncStream<MuxedStream>.Continuation?
         let pendingAccepts: [(id: UInt64, continuation: CheckedContinuation<MuxedStream, Error>)]
+        let pingWaiters: [CheckedContinuation<Void, Error>]
 
-        /// Finishes the inbound stream and resumes pending accepts with error.
+        /// Resumes pending accepts and probes with error.
         ///
         /// Each parked accept that is still present at capture time is resumed
         /// here with the connection-closed error. A waiter is captured at most
         /// once (the capture clears `pendingAccepts` under the lock), so this
         /// cannot double-resume one already removed by delivery or cancel.
         func notifyContinuations(error: Error) {
-            inboundContinuation?.finish()
             for waiter in pendingAccepts {
                 waiter.continuation.resume(throwing: error)
             }
+            for waiter in pingWaiters {
+                waiter.resume(throwing: error)
+            }
         }
 
         /// Resets all streams (for abrupt/error shutdown).
@@ -847,14 +1048,16 @@ public final class YamuxConnection: MuxedConnection, Sendable {
 
             let capture = ShutdownCapture(
                 streams: Array(state.streams.values),
-                inboundContinuation: state.inboundContinuation,
-                pendingAccepts: state.pendingAccepts
+                pendingAccepts: state.pendingAccepts,
+                pingWaiters: Array(state.pingWaiters.values)
             )
 
             state.streams.removeAll()
-            state.inboundContinuation = nil
+            state.acceptQueue.removeAll()
+            state.deferredAccepts.removeAll()
             state.pendingAccepts.removeAll()
             state.pendingPings.removeAll()
+            state.pingWaiters.removeAll()
 
             return capture
         }
@@ -875,6 +1078,8 @@ public final class YamuxConnection: MuxedConnection, Sendable {
 
         capture.notifyContinuations(error: error)
         capture.resetAllStreams()
+        reportAcceptQueueDepth(0)
+        eventChannel.finish()
     }
 
     // MARK: - Keep-Alive
@@ -892,9 +1097,9 @@ public final class YamuxConnection: MuxedConnection, Sendable {
                 return
             }
 
-            // Check if connection is closed
-            let isClosed = state.withLock { $0.isClosed }
-            if isClosed { return }
+            // Check if connection is closed or an outside prober took over
+            let shouldStop = state.withLock { $0.isClosed || $0.isBuiltInKeepAliveDisabled }
+            if shouldStop { return }
 
             // Check for timed out pings
             if checkPingTimeout(timeout: timeout) {
@@ -946,3 +1151,74 @@ public final class YamuxConnection: MuxedConnection, Sendable {
         }
     }
 }
+
+// MARK: - KeepAliveProbe
+
+extension YamuxConnection: KeepAliveProbe {
+
+    public var keepAliveProbe: (any KeepAliveProbe)? { self }
+
+    public var mechanism: KeepAliveMechanism { .muxerPing }
+
+    /// Sends a Yamux ping and waits for its ack.
+    ///
+    /// Probe pings share IDs with the keep-alive loop's pings, so the two
+    /// never confuse each other's acks.
+    public func probe() async throws -> Duration {
+        let pingID: UInt32? = state.withLock { state in
+            guard !state.isClosed else { return nil }
+            let id = state.nextPingID
+            state.nextPingID &+= 1
+            return id
+        }
+        guard let pingID else { throw YamuxError.connectionClosed }
+
+        let start = ContinuousClock.now
+        rttEstimator.pingSent(id: pingID)
+        try await withTaskCancellationHandler {
+            try await withCheckedThrowingContinuation { (continuation: CheckedContinuation<Void, Error>) in
+                let rejection: Error? = state.withLock { state in
+                    if state.isClosed { return YamuxError.connectionClosed }
+                    if Task.isCancelled { return CancellationError() }
+                    state.pingWaiters[pingID] = continuation
+                    return nil
+                }
+                if let rejection {
+                    continuation.resume(throwing: rejection)
+                    return
+                }
+                Task { [weak self] in
+                    guard let self else { return }
+                    do {
+                        try await self.sendFrame(.ping(opaque: pingID, ack: false))
+                    } catch {
+                        self.failPingWaiter(pingID, error: error)
+                    }
+                }
+            }
+        } onCancel: {
+            self.failPingWaiter(pingID, error: CancellationError())
+        }
+        return ContinuousClock.now - start
+    }
+
+    /// Stops the keep-alive loop; `probe()` keeps working.
+    public func disableBuiltInKeepAlive() {
+        state.withLock { $0.isBuiltInKeepAliveDisabled = true }
+        keepAliveTask.withLock { $0?.cancel() }
+    }
+
+    /// Removes and fails the `probe()` waiter for `pingID`, if still parked.
+    private func failPingWaiter(_ pingID: UInt32, error: Error) {
+        let waiter = state.withLock { $0.pingWaiters.removeValue(forKey: pingID) }
+        waiter?.resume(throwing: error)
+    }
+}
+
+extension MuxedConnection {
+    /// The Yamux session under this connection, or `nil` if another muxer
+    /// was negotiated.
+    public var yamuxConnection: YamuxConnection? {
+        muxerConnection as? YamuxConnection
+    }
+}
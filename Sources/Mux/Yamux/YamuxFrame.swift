@@ -14,6 +14,7 @@
 /// ```
 import Foundation
 import NIOCore
+import P2PCore
 
 /// Yamux protocol version.
 let yamuxVersion: UInt8 = 0
@@ -50,9 +51,12 @@ struct YamuxFlags: OptionSet, Sendable {
 }
 
 /// GoAway reason codes.
-enum YamuxGoAwayReason: UInt32, Sendable {
+public enum YamuxGoAwayReason: UInt32, Sendable {
+    /// The session is being closed normally.
     case normal = 0
+    /// The peer saw a protocol violation.
     case protocolError = 1
+    /// The peer hit an internal error.
     case internalError = 2
 }
 
@@ -216,6 +220,8 @@ enum YamuxError: Error, Sendable {
     case readBufferOverflow
     /// Stream ID space exhausted (connection too long-lived)
     case streamIDExhausted
+    /// The remote peer sent GoAway; no new streams may be opened
+    case remoteGoAway(YamuxGoAwayReason)
 }
 
 // MARK: - Configuration
@@ -228,11 +234,28 @@ public struct YamuxConfiguration: Sendable {
     /// Default: 1000
     public var maxConcurrentStreams: Int
 
-    /// Maximum number of pending inbound streams in the delivery buffer.
+    /// Maximum number of open streams the remote peer may have opened.
     ///
-    /// When this limit is reached, new inbound streams are rejected with RST
-    /// instead of being silently dropped. This provides proper backpressure
-    /// to the remote peer.
+    /// A SYN past this limit is refused with RST, so a peer opening streams
+    /// in a loop cannot grow the stream table without bound.
+    /// Default: 1000
+    public var maxIncomingStreams: Int
+
+    /// Maximum number of open streams opened locally.
+    ///
+    /// `newStream()` throws `YamuxError.maxStreamsExceeded` past this limit
+    /// without sending a SYN.
+    /// Default: 1000
+    public var maxOutgoingStreams: Int
+
+    /// Maximum number of inbound streams ACKed but not yet accepted.
+    ///
+    /// Past this limit a new inbound stream is still opened, but its SYN is
+    /// not ACKed until an accepted stream frees a slot in the queue. A peer
+    /// bounding its unacknowledged streams (go-yamux does) stops opening new
+    /// ones, so a slow consumer throttles the peer instead of the queue
+    /// growing. How many streams can wait unacknowledged is bounded by
+    /// `maxIncomingStreams`; SYNs past that get RST.
     /// Default: 100
     public var maxPendingInboundStreams: Int
 
@@ -287,10 +310,22 @@ public struct YamuxConfiguration: Sendable {
     /// Default: 16MB
     public var connectionReceiveWindow: UInt32
 
+    /// Receives the header of every control frame sent or received: window
+    /// updates, pings, GoAways and data frames carrying SYN, ACK, FIN or RST.
+    /// Default: nil (no tracing)
+    public var wireTracer: WireTracer?
+
+    /// Receives the accept-queue depth (`LibP2PMetrics.muxerAcceptQueueDepth`)
+    /// each time it changes, summed over the connections of a `YamuxMuxer`.
+    /// Default: nil (no metrics)
+    public var metrics: (any MetricsExporter)?
+
     /// Creates a Yamux configuration.
     ///
     /// - Parameters:
     ///   - maxConcurrentStreams: Maximum concurrent streams (default: 1000)
+    ///   - maxIncomingStreams: Maximum streams opened by the peer (default: 1000)
+    ///   - maxOutgoingStreams: Maximum streams opened locally (default: 1000)
     ///   - maxPendingInboundStreams: Maximum pending inbound streams (default: 100)
     ///   - initialWindowSize: Initial window size in bytes (default: 256KB)
     ///   - enableKeepAlive: Whether to enable keep-alive pings (default: true)
@@ -299,8 +334,12 @@ public struct YamuxConfiguration: Sendable {
     ///   - enableWindowAutoTuning: Enable auto window tuning (default: true)
     ///   - maxAutoTuneWindow: Max window when auto-tuning (default: 16MB)
     ///   - connectionReceiveWindow: Aggregate connection receive window (default: 16MB)
+    ///   - wireTracer: Tracer for control frames (default: nil)
+    ///   - metrics: Exporter for the accept-queue depth (default: nil)
     public init(
         maxConcurrentStreams: Int = 1000,
+        maxIncomingStreams: Int = 1000,
+        maxOutgoingStreams: Int = 1000,
         maxPendingInboundStreams: Int = 100,
         initialWindowSize: UInt32 = 256 * 1024,
         enableKeepAlive: Bool = true,
@@ -308,13 +347,17 @@ public struct YamuxConfiguration: Sendable {
         keepAliveTimeout: Duration = .seconds(60),
         enableWindowAutoTuning: Bool = true,
         maxAutoTuneWindow: UInt32 = 16 * 1024 * 1024,
-        connectionReceiveWindow: UInt32 = 16 * 1024 * 1024
+        connectionReceiveWindow: UInt32 = 16 * 1024 * 1024,
+        wireTracer: WireTracer? = nil,
+        metrics: (any MetricsExporter)? = nil
     ) {
         precondition(keepAliveTimeout >= keepAliveInterval,
             "keepAliveTimeout must be >= keepAliveInterval")
         precondition(connectionReceiveWindow >= initialWindowSize,
             "connectionReceiveWindow must be >= initialWindowSize")
         self.maxConcurrentStreams = maxConcurrentStreams
+        self.maxIncomingStreams = maxIncomingStreams
+        self.maxOutgoingStreams = maxOutgoingStreams
         self.maxPendingInboundStreams = maxPendingInboundStreams
         self.initialWindowSize = initialWindowSize
         self.enableKeepAlive = enableKeepAlive
@@ -323,6 +366,8 @@ public struct YamuxConfiguration: Sendable {
         self.enableWindowAutoTuning = enableWindowAutoTuning
         self.maxAutoTuneWindow = maxAutoTuneWindow
         self.connectionReceiveWindow = connectionReceiveWindow
+        self.wireTracer = wireTracer
+        self.metrics = metrics
     }
 
     /// Default configuration.
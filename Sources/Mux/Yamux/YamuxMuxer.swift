@@ -14,9 +14,12 @@ public final class YamuxMuxer: Muxer, Sendable {
     /// Configuration for this muxer.
     public let configuration: YamuxConfiguration
 
+    /// Accept-queue depth summed over this muxer's connections.
+    private let acceptQueueGauge: YamuxAcceptQueueGauge?
+
     /// Creates a Yamux muxer with default configuration.
-    public init() {
-        self.configuration = .default
+    public convenience init() {
+        self.init(configuration: .default)
     }
 
     /// Creates a Yamux muxer with custom configuration.
@@ -24,6 +27,16 @@ public final class YamuxMuxer: Muxer, Sendable {
     /// - Parameter configuration: The configuration to use.
     public init(configuration: YamuxConfiguration) {
         self.configuration = configuration
+        self.acceptQueueGauge = configuration.metrics.map { YamuxAcceptQueueGauge(exporter: $0) }
+    }
+
+    /// A muxer with the same configuration reporting to `metrics`.
+    ///
+    /// - Parameter metrics: The exporter for the accept-queue depth.
+    public func withMetrics(_ metrics: any MetricsExporter) -> YamuxMuxer {
+        var configuration = configuration
+        configuration.metrics = metrics
+        return YamuxMuxer(configuration: configuration)
     }
 
     public func multiplex(
@@ -35,7 +48,8 @@ public final class YamuxMuxer: Muxer, Sendable {
             localPeer: connection.localPeer,
             remotePeer: connection.remotePeer,
             isInitiator: isInitiator,
-            configuration: configuration
+            configuration: configuration,
+            acceptQueueGauge: acceptQueueGauge
         )
         yamuxConnection.start()
         return yamuxConnection
@@ -0,0 +1,9 @@
+/// YamuxSessionEvent - Session-level notifications from a Yamux connection
+
+/// An event reported by a `YamuxConnection` about its session.
+public enum YamuxSessionEvent: Sendable, Equatable {
+    /// The remote peer sent GoAway. The session is draining: streams already
+    /// open keep working, but `newStream()` fails with the same reason until
+    /// the connection closes.
+    case sessionGoAway(reason: YamuxGoAwayReason)
+}
@@ -28,6 +28,14 @@ public struct NegotiationResult: Sendable {
     }
 }
 
+/// Which side of a multistream-select exchange a party plays.
+public enum NegotiationRole: Sendable {
+    /// Proposes protocols (`negotiate`).
+    case initiator
+    /// Answers proposals (`handle`).
+    case responder
+}
+
 /// Multistream-select protocol negotiation.
 public enum MultistreamSelect {
 
@@ -10,7 +10,7 @@ import P2PMux
 import P2PProtocols
 
 /// Logger for RelayClient operations.
-private let logger = Logger(label: "p2p.circuit-relay.client")
+private let logger = SubsystemLogger(.relay, label: "p2p.circuit-relay.client")
 
 /// Configuration for RelayClient.
 public struct RelayClientConfiguration: Sendable {
@@ -326,6 +326,7 @@ public final class RelayClient: EventEmitting, Sendable {
             }
 
             emit(.reservationCreated(relay: relay, reservation: reservation))
+            logger.debug("Relay reservation created", peer: relay, protocol: CircuitRelayProtocol.hopProtocolID)
 
             // Schedule renewal or expiration
             scheduleRenewalOrExpiration(relay: relay, at: expiration)
@@ -396,6 +397,12 @@ public final class RelayClient: EventEmitting, Sendable {
 
             try throwIfShutdown()
             emit(.circuitEstablished(relay: relay, remote: target))
+            logger.debug(
+                "Relay circuit established",
+                peer: target,
+                protocol: CircuitRelayProtocol.hopProtocolID,
+                metadata: ["relay": "\(relay)"]
+            )
 
             return connection
 
@@ -10,7 +10,7 @@ import P2PMux
 import P2PProtocols
 
 /// Logger for RelayServer operations.
-private let logger = Logger(label: "p2p.circuit-relay.server")
+private let logger = SubsystemLogger(.relay, label: "p2p.circuit-relay.server")
 
 /// Configuration for RelayServer.
 public struct RelayServerConfiguration: Sendable {
@@ -296,6 +296,7 @@ public final class RelayServer: EventEmitting, Sendable {
         do {
             try await writeMessage(responseData, to: stream)
             emit(.reservationAccepted(from: requester, expiration: expiration))
+            logger.debug("Relay reservation accepted", peer: requester, protocol: CircuitRelayProtocol.hopProtocolID)
         } catch let writeError {
             logger.debug("Failed to send reservation response: \(writeError)")
             // Failed to send response, remove reservation
@@ -421,6 +422,7 @@ public final class RelayServer: EventEmitting, Sendable {
             }
 
             emit(.circuitOpened(source: source, destination: target))
+            logger.debug("Relay circuit opened", peer: source, metadata: ["destination": "\(target)"])
 
             // Start relaying data between streams
             await relayData(from: stream, to: targetStream, circuitID: circuitID)
@@ -462,6 +464,11 @@ public final class RelayServer: EventEmitting, Sendable {
         }
 
         emit(.circuitCompleted(source: circuitID.source, destination: circuitID.destination, bytesTransferred: bytesTransferred))
+        logger.debug(
+            "Relay circuit closed",
+            peer: circuitID.source,
+            metadata: ["destination": "\(circuitID.destination)", "bytes": "\(bytesTransferred)"]
+        )
 
         do {
             try await sourceStream.close()
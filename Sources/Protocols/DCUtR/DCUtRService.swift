@@ -305,8 +305,10 @@ public final class DCUtRService: EventEmitting, Sendable {
             // Close the DCUtR stream - we're done with negotiation
             try await stream.close()
 
-            // Attempt to dial addresses in parallel for better hole punch success rate
-            if let successAddress = await dialParallel(addresses: theirAddresses, dialer: dialer) {
+            // Attempt to dial addresses in parallel for better hole punch success rate.
+            // As the hole punch initiator we run the handshake as initiator,
+            // matching go-libp2p (the stream opener is the client).
+            if let successAddress = await dialParallel(addresses: theirAddresses, role: .initiator, dialer: dialer) {
                 emit(.directConnectionEstablished(peer: peer, address: successAddress))
                 return
             }
@@ -376,7 +378,9 @@ public final class DCUtRService: EventEmitting, Sendable {
             // This is the responder side of hole punching
             let effectiveDialer = _dialerOverride.withLock { $0 } ?? configuration.dialer
             if let dialer = effectiveDialer, !theirAddresses.isEmpty {
-                if let successAddress = await dialParallel(addresses: theirAddresses, dialer: dialer) {
+                // Our dial meets the initiator's in a TCP simultaneous open;
+                // take the responder role so exactly one side initiates.
+                if let successAddress = await dialParallel(addresses: theirAddresses, role: .responder, dialer: dialer) {
                     emit(.directConnectionEstablished(peer: peer, address: successAddress))
                     return
                 }
@@ -448,49 +452,54 @@ public final class DCUtRService: EventEmitting, Sendable {
     ///
     /// - Parameters:
     ///   - addresses: The addresses to dial.
+    ///   - role: Security role bound via `SimultaneousConnect` for the dials.
     ///   - dialer: The dialer function.
     /// - Returns: The first successfully connected address, or nil if all failed.
     private func dialParallel(
         addresses: [Multiaddr],
+        role: SecurityRole,
         dialer: @escaping @Sendable (Multiaddr) async throws -> Void
     ) async -> Multiaddr? {
         let timeout = configuration.timeout
 
-        return await withTaskGroup(of: Multiaddr?.self) { group in
-            for address in addresses {
-                // Defense in depth: re-validate each address at dial time. An
-                // address that is not a public, dialable IP (private, DNS-form,
-                // IPv4-mapped private, etc.) must never be dialed for hole punching.
-                guard isDialableForHolePunch(address) else { continue }
-                group.addTask {
-                    do {
-                        // Apply timeout to each dial attempt to prevent stalling
-                        try await withThrowingTaskGroup(of: Void.self) { innerGroup in
-                            innerGroup.addTask {
-                                try await dialer(address)
+        // Child tasks inherit the task-local role
+        return await SimultaneousConnect.$role.withValue(role) {
+            await withTaskGroup(of: Multiaddr?.self) { group in
+                for address in addresses {
+                    // Defense in depth: re-validate each address at dial time. An
+                    // address that is not a public, dialable IP (private, DNS-form,
+                    // IPv4-mapped private, etc.) must never be dialed for hole punching.
+                    guard isDialableForHolePunch(address) else { continue }
+                    group.addTask {
+                        do {
+                            // Apply timeout to each dial attempt to prevent stalling
+                            try await withThrowingTaskGroup(of: Void.self) { innerGroup in
+                                innerGroup.addTask {
+                                    try await dialer(address)
+                                }
+                                innerGroup.addTask {
+                                    try await Task.sleep(for: timeout)
+                                    throw DCUtRError.timeout
+                                }
+                                _ = try await innerGroup.next()!
+                                innerGroup.cancelAll()
                             }
-                            innerGroup.addTask {
-                                try await Task.sleep(for: timeout)
-                                throw DCUtRError.timeout
-                            }
-                            _ = try await innerGroup.next()!
-                            innerGroup.cancelAll()
+                            return address
+                        } catch {
+                            return nil
                         }
-                        return address
-                    } catch {
-                        return nil
                     }
                 }
-            }
 
-            // Return the first successful result
-            for await result in group {
-                if let address = result {
-                    group.cancelAll()  // Cancel remaining dial attempts
-                    return address
+                // Return the first successful result
+                for await result in group {
+                    if let address = result {
+                        group.cancelAll()  // Cancel remaining dial attempts
+                        return address
+                    }
                 }
+                return nil
             }
-            return nil
         }
     }
 
@@ -0,0 +1,25 @@
+/// FetchError - Error types for the fetch protocol.
+
+/// Errors that can occur during fetch operations.
+public enum FetchError: Error, Sendable, Equatable {
+    /// The message could not be decoded.
+    case decodingFailed(String)
+
+    /// The requested identifier exceeds `FetchProtocol.maxIdentifierSize`.
+    case identifierTooLarge(Int)
+
+    /// The remote peer answered with the `ERROR` status.
+    case remoteError
+
+    /// The remote peer answered with a status code this side does not know.
+    case unknownStatus(UInt64)
+
+    /// The stream was closed before the operation completed.
+    case streamClosed
+
+    /// The operation timed out.
+    case timeout
+
+    /// Could not open a stream to the remote peer.
+    case connectionFailed(String)
+}
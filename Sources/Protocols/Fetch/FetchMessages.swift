@@ -0,0 +1,39 @@
+/// FetchMessages - Message types for the fetch wire protocol.
+
+import Foundation
+
+/// Status codes carried in a fetch response.
+public enum FetchStatus: UInt64, Sendable {
+    /// The value was found and is carried in the response.
+    case ok = 0
+
+    /// The responder has no value for the identifier.
+    case notFound = 1
+
+    /// The responder failed while looking up the value.
+    case error = 2
+}
+
+/// A fetch request for the value stored under `identifier`.
+public struct FetchRequest: Sendable, Equatable {
+    /// The key being requested.
+    public var identifier: String
+
+    public init(identifier: String) {
+        self.identifier = identifier
+    }
+}
+
+/// A fetch response.
+public struct FetchResponse: Sendable, Equatable {
+    /// The lookup status.
+    public var status: FetchStatus
+
+    /// The value; empty unless `status` is `.ok`.
+    public var data: Data
+
+    public init(status: FetchStatus, data: Data = Data()) {
+        self.status = status
+        self.data = data
+    }
+}
@@ -0,0 +1,169 @@
+/// FetchProtobuf - Wire format encoding/decoding for the fetch protocol.
+///
+/// ```protobuf
+/// message FetchRequest {
+///   string identifier = 1;
+/// }
+///
+/// message FetchResponse {
+///   enum StatusCode { OK = 0; NOT_FOUND = 1; ERROR = 2; }
+///   StatusCode status = 1;
+///   bytes data = 2;
+/// }
+/// ```
+///
+/// Both messages are proto3: zero-valued fields are omitted on encode and
+/// default when absent on decode, as go-libp2p's generated code does.
+
+import Foundation
+import NIOCore
+import P2PCore
+
+/// Protobuf encoding/decoding for fetch messages.
+enum FetchProtobuf {
+
+    private static let wireTypeVarint: UInt64 = 0
+    private static let wireTypeLengthDelimited: UInt64 = 2
+    private static let tagIdentifier: UInt8 = 0x0A  // field 1, length-delimited
+    private static let tagStatus: UInt8 = 0x08      // field 1, varint
+    private static let tagData: UInt8 = 0x12        // field 2, length-delimited
+
+    // MARK: - Encoding
+
+    /// Encodes a fetch request to protobuf wire format.
+    static func encode(_ request: FetchRequest) -> ByteBuffer {
+        let identifier = Array(request.identifier.utf8)
+        var buffer = ByteBufferAllocator().buffer(capacity: 1 + 10 + identifier.count)
+        if !identifier.isEmpty {
+            buffer.writeInteger(tagIdentifier)
+            Varint.encode(UInt64(identifier.count), into: &buffer)
+            buffer.writeBytes(identifier)
+        }
+        return buffer
+    }
+
+    /// Encodes a fetch response to protobuf wire format.
+    static func encode(_ response: FetchResponse) -> ByteBuffer {
+        var buffer = ByteBufferAllocator().buffer(capacity: 2 + 10 + response.data.count)
+        if response.status != .ok {
+            buffer.writeInteger(tagStatus)
+            Varint.encode(response.status.rawValue, into: &buffer)
+        }
+        if !response.data.isEmpty {
+            buffer.writeInteger(tagData)
+            Varint.encode(UInt64(response.data.count), into: &buffer)
+            buffer.writeBytes(response.data)
+        }
+        return buffer
+    }
+
+    // MARK: - Decoding
+
+    /// Decodes a fetch request from protobuf wire format.
+    static func decodeRequest(_ buffer: ByteBuffer) throws -> FetchRequest {
+        let data = Data(buffer: buffer)
+        var identifier = ""
+        var offset = 0
+
+        while offset < data.count {
+            let (tag, tagBytes) = try decodeVarint(data, at: offset)
+            offset += tagBytes
+
+            switch (tag >> 3, tag & 0x07) {
+            case (1, wireTypeLengthDelimited):
+                let field = try decodeLengthDelimitedField(data, offset: &offset)
+                guard field.count <= FetchProtocol.maxIdentifierSize else {
+                    throw FetchError.identifierTooLarge(field.count)
+                }
+                guard let value = String(validating: field, as: UTF8.self) else {
+                    throw FetchError.decodingFailed("Identifier is not valid UTF-8")
+                }
+                identifier = value
+
+            default:
+                offset = try skipField(data, wireType: tag & 0x07, offset: offset)
+            }
+        }
+
+        return FetchRequest(identifier: identifier)
+    }
+
+    /// Decodes a fetch response from protobuf wire format.
+    ///
+    /// - Throws: `FetchError.unknownStatus` for a status code outside the
+    ///   schema, rather than guessing what the responder meant.
+    static func decodeResponse(_ buffer: ByteBuffer) throws -> FetchResponse {
+        let data = Data(buffer: buffer)
+        var statusValue: UInt64 = 0
+        var value = Data()
+        var offset = 0
+
+        while offset < data.count {
+            let (tag, tagBytes) = try decodeVarint(data, at: offset)
+            offset += tagBytes
+
+            switch (tag >> 3, tag & 0x07) {
+            case (1, wireTypeVarint):
+                let (status, statusBytes) = try decodeVarint(data, at: offset)
+                statusValue = status
+                offset += statusBytes
+
+            case (2, wireTypeLengthDelimited):
+                value = try decodeLengthDelimitedField(data, offset: &offset)
+
+            default:
+                offset = try skipField(data, wireType: tag & 0x07, offset: offset)
+            }
+        }
+
+        guard let status = FetchStatus(rawValue: statusValue) else {
+            throw FetchError.unknownStatus(statusValue)
+        }
+        return FetchResponse(status: status, data: value)
+    }
+
+    // MARK: - Helpers
+
+    private static func decodeVarint(_ data: Data, at offset: Int) throws -> (UInt64, Int) {
+        do {
+            return try Varint.decode(from: data, at: offset)
+        } catch {
+            throw FetchError.decodingFailed("Truncated varint")
+        }
+    }
+
+    private static func decodeLengthDelimitedField(_ data: Data, offset: inout Int) throws -> Data {
+        let (lengthValue, lengthBytes) = try decodeVarint(data, at: offset)
+        offset += lengthBytes
+
+        guard lengthValue <= UInt64(data.count - offset) else {
+            throw FetchError.decodingFailed("Field truncated")
+        }
+        let end = offset + Int(lengthValue)
+        let field = Data(data[offset..<end])
+        offset = end
+        return field
+    }
+
+    private static func skipField(_ data: Data, wireType: UInt64, offset: Int) throws -> Int {
+        switch wireType {
+        case wireTypeVarint:
+            let (_, bytesRead) = try decodeVarint(data, at: offset)
+            return offset + bytesRead
+        case 1:
+            let end = offset + 8
+            guard end <= data.count else { throw FetchError.decodingFailed("Field truncated") }
+            return end
+        case wireTypeLengthDelimited:
+            var cursor = offset
+            _ = try decodeLengthDelimitedField(data, offset: &cursor)
+            return cursor
+        case 5:
+            let end = offset + 4
+            guard end <= data.count else { throw FetchError.decodingFailed("Field truncated") }
+            return end
+        default:
+            throw FetchError.decodingFailed("Unsupported wire type \(wireType)")
+        }
+    }
+}
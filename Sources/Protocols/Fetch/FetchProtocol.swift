@@ -0,0 +1,18 @@
+/// FetchProtocol - Protocol constants for the libp2p fetch protocol.
+///
+/// See: https://github.com/libp2p/specs/tree/master/fetch
+
+/// Constants for the fetch protocol.
+public enum FetchProtocol {
+    /// Protocol ID for fetch, as registered by go-libp2p.
+    public static let protocolID = "/libp2p/fetch/0.0.1"
+
+    /// Maximum size of a single request or response message (4MB).
+    ///
+    /// Matches go-libp2p's `network.MessageSizeMax`, which bounds the
+    /// varint-delimited reader on both sides.
+    public static let maxMessageSize = 4 * 1024 * 1024
+
+    /// Maximum length of a request identifier in bytes.
+    public static let maxIdentifierSize = 4096
+}
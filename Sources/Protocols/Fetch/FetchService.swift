@@ -0,0 +1,276 @@
+/// FetchService - Fetch protocol service for libp2p.
+///
+/// Requests a value by key from a single peer and serves local values through
+/// a registered resolver, speaking go-libp2p's `/libp2p/fetch/0.0.1`.
+
+import Foundation
+import Synchronization
+import P2PCore
+import P2PMux
+import P2PProtocols
+
+/// Logger for fetch operations.
+private let logger = Logger(label: "p2p.fetch")
+
+/// Configuration for FetchService.
+public struct FetchConfiguration: Sendable {
+    /// Timeout for a fetch round trip (outbound) and for reading a request
+    /// (inbound).
+    public var timeout: Duration
+
+    /// Creates a new fetch configuration.
+    ///
+    /// - Parameter timeout: Timeout for operations (default: 30 seconds)
+    public init(timeout: Duration = .seconds(30)) {
+        self.timeout = timeout
+    }
+}
+
+/// Service for the fetch protocol.
+///
+/// Fetch is a single request/response exchange per stream: the dialer writes
+/// a varint-delimited `FetchRequest` and closes its write side, the listener
+/// answers with one varint-delimited `FetchResponse` and closes the stream.
+/// It gives a direct peer-to-peer key/value path without DHT semantics.
+///
+/// ## Usage
+///
+/// ```swift
+/// let fetchService = FetchService()
+///
+/// // Serve local values
+/// fetchService.setResolver { key in
+///     store[key]
+/// }
+///
+/// // Fetch a value from a peer (nil when the peer has none)
+/// let value = try await fetchService.fetch(peer: remotePeer, key: "/record/a", using: node)
+/// ```
+public final class FetchService: EventEmitting, Sendable {
+
+    // MARK: - Event
+
+    /// Events emitted by FetchService.
+    public enum Event: Sendable {
+        /// A request for a key was received from a peer.
+        case requestReceived(PeerID, String)
+
+        /// A response with the given status was sent to a peer.
+        case responseSent(PeerID, FetchStatus)
+
+        /// An error occurred during fetch processing.
+        case error(PeerID?, FetchError)
+    }
+
+    // MARK: - Resolver Type
+
+    /// Looks up the value for a key; `nil` means not found.
+    ///
+    /// A thrown error is answered with the `ERROR` status.
+    public typealias Resolver = @Sendable (String) async throws -> Data?
+
+    // MARK: - StreamService
+
+    public var protocolIDs: [String] {
+        [FetchProtocol.protocolID]
+    }
+
+    // MARK: - Properties
+
+    /// Configuration for this service.
+    public let configuration: FetchConfiguration
+
+    /// Event channel.
+    private let channel = EventChannel<Event>()
+
+    /// The registered resolver, if any.
+    private let resolverState: Mutex<Resolver?>
+
+    // MARK: - EventEmitting
+
+    /// Event stream for monitoring fetch events.
+    public var events: AsyncStream<Event> { channel.stream }
+
+    // MARK: - Initialization
+
+    /// Creates a new fetch service.
+    ///
+    /// - Parameter configuration: Service configuration
+    public init(configuration: FetchConfiguration = .init()) {
+        self.configuration = configuration
+        self.resolverState = Mutex(nil)
+    }
+
+    // MARK: - Resolver Registration
+
+    /// Registers the resolver that answers inbound requests, replacing any
+    /// previous one.
+    ///
+    /// Without a resolver every request is answered with `NOT_FOUND`.
+    ///
+    /// - Parameter resolver: The resolver, or `nil` to remove it
+    public func setResolver(_ resolver: Resolver?) {
+        resolverState.withLock { $0 = resolver }
+    }
+
+    // MARK: - Client API
+
+    /// Fetches the value stored under `key` on a remote peer.
+    ///
+    /// - Parameters:
+    ///   - peer: The remote peer to ask
+    ///   - key: The identifier to look up
+    ///   - opener: The stream opener for creating new streams
+    /// - Returns: The value, or `nil` if the peer answered `NOT_FOUND`
+    /// - Throws: `FetchError` if the operation fails or the peer answered `ERROR`
+    public func fetch(
+        peer: PeerID,
+        key: String,
+        using opener: any StreamOpener
+    ) async throws -> Data? {
+        let identifierSize = key.utf8.count
+        guard identifierSize <= FetchProtocol.maxIdentifierSize else {
+            throw FetchError.identifierTooLarge(identifierSize)
+        }
+
+        let stream: MuxedStream
+        do {
+            stream = try await opener.newStream(to: peer, protocol: FetchProtocol.protocolID)
+        } catch {
+            let fetchError = FetchError.connectionFailed("\(error)")
+            emit(.error(peer, fetchError))
+            throw fetchError
+        }
+
+        defer {
+            Task {
+                do {
+                    try await stream.close()
+                } catch {
+                    logger.debug("Failed to close fetch stream: \(error)")
+                }
+            }
+        }
+
+        do {
+            try await stream.writeLengthPrefixedMessage(FetchProtobuf.encode(FetchRequest(identifier: key)))
+            try await stream.closeWrite()
+
+            let response = try await withThrowingTaskGroup(of: FetchResponse.self) { group in
+                group.addTask {
+                    let message = try await stream.readLengthPrefixedMessage(
+                        maxSize: UInt64(FetchProtocol.maxMessageSize)
+                    )
+                    return try FetchProtobuf.decodeResponse(message)
+                }
+
+                group.addTask {
+                    try await Task.sleep(for: self.configuration.timeout)
+                    throw FetchError.timeout
+                }
+
+                let result = try await group.next()!
+                group.cancelAll()
+                return result
+            }
+
+            switch response.status {
+            case .ok:
+                return response.data
+            case .notFound:
+                return nil
+            case .error:
+                throw FetchError.remoteError
+            }
+        } catch let error as FetchError {
+            emit(.error(peer, error))
+            throw error
+        } catch {
+            let fetchError = FetchError.streamClosed
+            emit(.error(peer, fetchError))
+            throw fetchError
+        }
+    }
+
+    // MARK: - Incoming Handler
+
+    /// Handles an incoming fetch request on a stream.
+    private func handleIncoming(context: StreamContext) async {
+        let stream = context.stream
+        let remotePeer = context.remotePeer
+
+        do {
+            let request = try await withThrowingTaskGroup(of: FetchRequest.self) { group in
+                group.addTask {
+                    let message = try await stream.readLengthPrefixedMessage(
+                        maxSize: UInt64(FetchProtocol.maxMessageSize)
+                    )
+                    return try FetchProtobuf.decodeRequest(message)
+                }
+
+                group.addTask {
+                    try await Task.sleep(for: self.configuration.timeout)
+                    throw FetchError.timeout
+                }
+
+                let result = try await group.next()!
+                group.cancelAll()
+                return result
+            }
+
+            emit(.requestReceived(remotePeer, request.identifier))
+
+            let response = await resolve(request.identifier, for: remotePeer)
+            try await stream.writeLengthPrefixedMessage(FetchProtobuf.encode(response))
+
+            emit(.responseSent(remotePeer, response.status))
+        } catch let error as FetchError {
+            emit(.error(remotePeer, error))
+        } catch {
+            emit(.error(remotePeer, .streamClosed))
+        }
+
+        do {
+            try await stream.close()
+        } catch {
+            logger.debug("Failed to close fetch handler stream: \(error)")
+        }
+    }
+
+    /// Runs the resolver for `key` and maps its outcome to a response.
+    private func resolve(_ key: String, for peer: PeerID) async -> FetchResponse {
+        guard let resolver = resolverState.withLock({ $0 }) else {
+            return FetchResponse(status: .notFound)
+        }
+        do {
+            guard let value = try await resolver(key) else {
+                return FetchResponse(status: .notFound)
+            }
+            return FetchResponse(status: .ok, data: value)
+        } catch {
+            logger.debug("Fetch resolver failed for \(peer): \(error)")
+            return FetchResponse(status: .error)
+        }
+    }
+
+    // MARK: - Event Emission
+
+    private func emit(_ event: Event) {
+        channel.yield(event)
+    }
+
+    // MARK: - Shutdown
+
+    /// Shuts down the service and finishes the event stream.
+    public func shutdown() async throws {
+        channel.finish()
+    }
+}
+
+// MARK: - StreamService
+
+extension FetchService: LifecycleService, StreamService {
+    public func handleInboundStream(_ context: StreamContext) async {
+        await handleIncoming(context: context)
+    }
+}
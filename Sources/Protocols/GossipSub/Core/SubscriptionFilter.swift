@@ -1,5 +1,6 @@
 /// SubscriptionFilter - Filters topic subscriptions (local and remote).
 import Foundation
+import Synchronization
 import P2PCore
 
 /// Filters topic subscriptions (local and remote).
@@ -50,6 +51,35 @@ public struct WhitelistSubscriptionFilter: TopicSubscriptionFilter {
     }
 }
 
+/// Only accept subscriptions for topics matching a regular expression.
+///
+/// Equivalent of go-libp2p's `NewRegexpSubscriptionFilter`: the pattern is
+/// matched anywhere in the topic string, so anchor it (`^...$`) to require a
+/// full match.
+public final class RegexSubscriptionFilter: TopicSubscriptionFilter, Sendable {
+    // `Regex` is not `Sendable`; the compiled pattern is only used under the lock.
+    private let regex: Mutex<Regex<AnyRegexOutput>>
+
+    /// Creates a filter from a regular expression pattern.
+    ///
+    /// - Parameter pattern: The pattern topics must match
+    /// - Throws: If the pattern is not a valid regular expression
+    public init(pattern: String) throws {
+        self.regex = Mutex(try Regex(pattern))
+    }
+
+    public func canSubscribe(to topic: Topic) -> Bool {
+        regex.withLock { topic.value.contains($0) }
+    }
+
+    public func filterIncomingSubscriptions(
+        _ subscriptions: [GossipSubRPC.SubscriptionOpt],
+        currentlySubscribed: Set<Topic>
+    ) throws -> [GossipSubRPC.SubscriptionOpt] {
+        subscriptions.filter { canSubscribe(to: $0.topic) }
+    }
+}
+
 /// Limits the maximum number of subscriptions per peer.
 public struct MaxCountSubscriptionFilter: TopicSubscriptionFilter {
     private let inner: any TopicSubscriptionFilter
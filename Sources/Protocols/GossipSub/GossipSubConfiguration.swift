@@ -209,6 +209,23 @@ public struct GossipSubConfiguration: Sendable {
     /// Key: topic, Value: set of peer IDs.
     public var directPeers: [Topic: Set<PeerID>]
 
+    /// Direct peers with dial addresses (go-libp2p `WithDirectPeers`).
+    ///
+    /// Unlike `directPeers`, these apply to every topic. They are grafted as
+    /// soon as they subscribe to one of our topics, never pruned, and redialed
+    /// with exponential backoff whenever their connection drops.
+    public var directConnectPeers: [AddrInfo]
+
+    /// Delay before the first dial to `directConnectPeers` after start.
+    public var directConnectInitialDelay: Duration
+
+    /// Initial backoff between reconnect attempts to a dropped direct peer.
+    /// Doubles after each failed attempt up to `directConnectMaxBackoff`.
+    public var directConnectBackoff: Duration
+
+    /// Upper bound for the reconnect backoff.
+    public var directConnectMaxBackoff: Duration
+
     // MARK: - Custom Message ID (A1)
 
     /// Custom function for computing message IDs.
@@ -362,6 +379,10 @@ public struct GossipSubConfiguration: Sendable {
         opportunisticGraftPeers: Int = 2,
         opportunisticGraftThreshold: Double = 1.0,
         directPeers: [Topic: Set<PeerID>] = [:],
+        directConnectPeers: [AddrInfo] = [],
+        directConnectInitialDelay: Duration = .seconds(1),
+        directConnectBackoff: Duration = .seconds(1),
+        directConnectMaxBackoff: Duration = .seconds(60),
         messageIDFunction: (@Sendable (GossipSubMessage) -> MessageID)? = nil,
         subscriptionFilter: (any TopicSubscriptionFilter)? = nil,
         enablePeerExchange: Bool = false,
@@ -420,6 +441,10 @@ public struct GossipSubConfiguration: Sendable {
         self.opportunisticGraftPeers = opportunisticGraftPeers
         self.opportunisticGraftThreshold = opportunisticGraftThreshold
         self.directPeers = directPeers
+        self.directConnectPeers = directConnectPeers
+        self.directConnectInitialDelay = directConnectInitialDelay
+        self.directConnectBackoff = directConnectBackoff
+        self.directConnectMaxBackoff = directConnectMaxBackoff
         self.messageIDFunction = messageIDFunction
         self.subscriptionFilter = subscriptionFilter
         self.enablePeerExchange = enablePeerExchange
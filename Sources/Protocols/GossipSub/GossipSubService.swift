@@ -6,7 +6,7 @@ import P2PMux
 import P2PProtocols
 import Synchronization
 
-private let logger = Logger(label: "p2p.gossipsub")
+private let logger = SubsystemLogger(.pubsub, label: "p2p.gossipsub")
 
 /// The main GossipSub service implementing the StreamService interface.
 ///
@@ -60,6 +60,10 @@ public final class GossipSubService: Sendable {
         var isStarted: Bool = false
         var peerStreams: [PeerID: MuxedStream] = [:]
         var opener: (any StreamOpener)?
+        /// Dialer used to (re)connect direct peers.
+        var dialer: (@Sendable (Multiaddr) async throws -> Void)?
+        /// Direct peers with a reconnect loop in flight.
+        var reconnectingPeers: Set<PeerID> = []
         /// Tracked unstructured tasks (e.g. subscription sends) so they can be
         /// cancelled on shutdown rather than leaking.
         var pendingTasks: [UUID: Task<Void, Never>] = [:]
@@ -147,6 +151,10 @@ public final class GossipSubService: Sendable {
         hb.start()
 
         serviceState.withLock { $0.isStarted = true }
+
+        for info in configuration.directConnectPeers {
+            scheduleDirectConnect(to: info, after: configuration.directConnectInitialDelay)
+        }
     }
 
     /// Shuts down the GossipSub service.
@@ -192,6 +200,7 @@ public final class GossipSubService: Sendable {
     /// - Returns: A subscription for receiving messages
     public func subscribe(to topic: Topic) async throws -> Subscription {
         let subscription = try router.subscribe(to: topic)
+        logger.debug("GossipSub subscribed", metadata: ["topic": "\(topic)"])
 
         // Notify all connected peers about our subscription
         await notifySubscription(topic: topic, subscribe: true)
@@ -213,6 +222,7 @@ public final class GossipSubService: Sendable {
     public func unsubscribe(from topic: Topic) async {
         // Unsubscribe and get mesh peers atomically
         let meshPeers = router.unsubscribe(from: topic)
+        logger.debug("GossipSub unsubscribed, pruning mesh", metadata: ["topic": "\(topic)", "mesh": "\(meshPeers.count)"])
 
         // Send PRUNE to former mesh peers and set local backoff
         for peer in meshPeers {
@@ -277,6 +287,10 @@ public final class GossipSubService: Sendable {
 
         // Get peers to send to
         let peers = router.peersForPublish(topic: topic)
+        logger.trace(
+            "GossipSub publishing",
+            metadata: ["topic": "\(topic)", "peers": "\(peers.count)", "bytes": "\(data.count)"]
+        )
 
         // Build RPC
         let rpc = GossipSubRPC(messages: [message])
@@ -355,6 +369,50 @@ public final class GossipSubService: Sendable {
         router.removeDirectPeer(peer, from: topic)
     }
 
+    // MARK: - Blacklist
+
+    /// Blacklists a peer.
+    ///
+    /// Drops all RPCs from the peer, removes it from every mesh, and refuses
+    /// to graft it. Only the GossipSub stream is closed; the connection stays
+    /// open for other protocols.
+    ///
+    /// - Parameter peer: The peer to blacklist
+    public func blacklistPeer(_ peer: PeerID) {
+        router.blacklistPeer(peer)
+
+        let stream = serviceState.withLock { $0.peerStreams.removeValue(forKey: peer) }
+        if let stream {
+            trackTask { [weak self] in
+                await self?.closeStreamBestEffort(stream, context: "peer \(peer) blacklisted")
+            }
+        }
+    }
+
+    /// Removes a peer from the blacklist.
+    ///
+    /// If the peer is still connected, a new GossipSub stream is opened to it.
+    ///
+    /// - Parameter peer: The peer to remove
+    public func removeFromBlacklist(_ peer: PeerID) {
+        router.removeFromBlacklist(peer)
+
+        guard isStarted, router.peerState.getPeer(peer) == nil else { return }
+        trackTask { [weak self] in
+            await self?.peerConnected(peer)
+        }
+    }
+
+    /// Sets the dialer used to (re)connect `directConnectPeers`.
+    ///
+    /// Injected after construction because the Node's dialing capability is
+    /// only available once the node has started.
+    ///
+    /// - Parameter dialer: Function that dials a given address.
+    public func setDialer(_ dialer: @escaping @Sendable (Multiaddr) async throws -> Void) {
+        serviceState.withLock { $0.dialer = dialer }
+    }
+
     // MARK: - Peer Management
 
     /// Handles a new peer connection.
@@ -394,6 +452,11 @@ public final class GossipSubService: Sendable {
         router.handlePeerDisconnected(peerID)
 
         serviceState.withLock { _ = $0.peerStreams.removeValue(forKey: peerID) }
+
+        if !router.isBlacklisted(peerID),
+           let info = configuration.directConnectPeers.first(where: { $0.peer == peerID }) {
+            scheduleDirectConnect(to: info, after: configuration.directConnectBackoff)
+        }
     }
 
     /// Returns connected peer count.
@@ -434,8 +497,14 @@ public final class GossipSubService: Sendable {
             return
         }
 
+        guard !router.isBlacklisted(peerID) else {
+            await closeStreamBestEffort(stream, context: "peer \(peerID) blacklisted")
+            return
+        }
+
         // Register peer if not already known
         let isNewPeer = router.peerState.getPeer(peerID) == nil
+        logger.debug("GossipSub inbound stream", peer: peerID, protocol: protocolID, metadata: ["newPeer": "\(isNewPeer)"])
         if isNewPeer {
             router.handlePeerConnected(peerID, version: version, direction: .inbound, stream: stream)
             serviceState.withLock { $0.peerStreams[peerID] = stream }
@@ -477,6 +546,15 @@ public final class GossipSubService: Sendable {
                         }
 
                         // Handle RPC and get result
+                        logger.trace(
+                            "GossipSub RPC received",
+                            peer: peerID,
+                            metadata: [
+                                "messages": "\(rpc.messages.count)",
+                                "subscriptions": "\(rpc.subscriptions.count)",
+                                "control": "\(rpc.control != nil)",
+                            ]
+                        )
                         let result = await router.handleRPC(rpc, from: peerID)
 
                         // Send response back to sender if present
@@ -612,6 +690,50 @@ public final class GossipSubService: Sendable {
         }
     }
 
+    /// Starts a reconnect loop for a direct peer unless one is already running.
+    private func scheduleDirectConnect(to info: AddrInfo, after delay: Duration) {
+        let shouldStart = serviceState.withLock { s -> Bool in
+            guard s.isStarted else { return false }
+            return s.reconnectingPeers.insert(info.peer).inserted
+        }
+        guard shouldStart else { return }
+
+        trackTask { [weak self] in
+            await self?.directConnectLoop(info, initialDelay: delay)
+            self?.serviceState.withLock { _ = $0.reconnectingPeers.remove(info.peer) }
+        }
+    }
+
+    /// Dials a direct peer with exponential backoff until it is connected
+    /// again or the service stops.
+    private func directConnectLoop(_ info: AddrInfo, initialDelay: Duration) async {
+        var delay = initialDelay
+        while !Task.isCancelled {
+            do {
+                try await Task.sleep(for: delay)
+            } catch {
+                return // Cancelled
+            }
+
+            guard isStarted, router.peerState.getPeer(info.peer) == nil else { return }
+            guard let dialer = serviceState.withLock({ $0.dialer }) else {
+                logger.debug("GossipSub direct peer \(info.peer) not redialed: no dialer configured")
+                return
+            }
+
+            for address in info.dialAddresses {
+                do {
+                    try await dialer(address)
+                    return
+                } catch {
+                    logger.debug("GossipSub direct peer dial to \(address) failed: \(error)")
+                }
+            }
+
+            delay = min(delay * 2, configuration.directConnectMaxBackoff)
+        }
+    }
+
     /// Sends our subscriptions to a peer.
     private func sendSubscriptions(to peerID: PeerID) async {
         let topics = router.meshState.subscribedTopics
@@ -646,6 +768,7 @@ extension GossipSubService: LifecycleService, StreamService, PeerObserver, Activ
     }
 
     public func peerConnected(_ peer: PeerID) async {
+        guard !router.isBlacklisted(peer) else { return }
         guard let opener = serviceState.withLock({ $0.opener }) else { return }
         do {
             let protocolID = protocolIDs[0]
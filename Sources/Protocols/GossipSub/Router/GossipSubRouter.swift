@@ -69,6 +69,12 @@ public final class GossipSubRouter: Sendable {
     /// Direct (explicit) peer state (v1.1).
     private let directPeerState: Mutex<[Topic: Set<PeerID>]>
 
+    /// Direct peers configured with dial addresses; direct for every topic.
+    private let directConnectPeerIDs: Set<PeerID>
+
+    /// Blacklisted peers; their RPCs and messages they originated are dropped.
+    private let blacklist: Mutex<Set<PeerID>>
+
     /// IWANT promise tracking (A5).
     let gossipPromises: GossipPromises
 
@@ -122,11 +128,13 @@ public final class GossipSubRouter: Sendable {
         )
         self.validators = Mutex([:])
         self.directPeerState = Mutex(configuration.directPeers)
+        self.directConnectPeerIDs = Set(configuration.directConnectPeers.map(\.peerID))
+        self.blacklist = Mutex([])
         self.gossipPromises = GossipPromises()
         self.iwantBudget = Mutex([:])
         // Sync protected peers from initial direct peer configuration
         let allDirectPeers = configuration.directPeers.values
-            .reduce(into: Set<PeerID>()) { $0.formUnion($1) }
+            .reduce(into: directConnectPeerIDs) { $0.formUnion($1) }
         if !allDirectPeers.isEmpty {
             peerScorer.setProtectedPeers(allDirectPeers)
         }
@@ -186,7 +194,8 @@ public final class GossipSubRouter: Sendable {
                 state[topic] = peers
             }
             if removed {
-                let stillDirect = state.values.contains { $0.contains(peer) }
+                let stillDirect = directConnectPeerIDs.contains(peer)
+                    || state.values.contains { $0.contains(peer) }
                 if !stillDirect {
                     peerScorer.removeProtectedPeer(peer)
                 }
@@ -200,14 +209,75 @@ public final class GossipSubRouter: Sendable {
 
     /// Returns whether a peer is a direct peer for any topic.
     func isDirectPeer(_ peer: PeerID) -> Bool {
-        directPeerState.withLock { state in
+        if directConnectPeerIDs.contains(peer) { return true }
+        return directPeerState.withLock { state in
             state.values.contains { $0.contains(peer) }
         }
     }
 
     /// Returns direct peers for a topic.
     func directPeers(for topic: Topic) -> Set<PeerID> {
-        directPeerState.withLock { $0[topic] ?? [] }
+        let perTopic = directPeerState.withLock { $0[topic] ?? [] }
+        return directConnectPeerIDs.isEmpty ? perTopic : perTopic.union(directConnectPeerIDs)
+    }
+
+    /// Grafts a direct peer into our mesh for a topic it subscribed to.
+    ///
+    /// Direct peers are meshed regardless of mesh degree or score, but a
+    /// backoff received from the peer's own PRUNE is honored so we do not
+    /// re-GRAFT into a remote that refuses to mesh with us.
+    ///
+    /// - Returns: `true` if the peer was added and a GRAFT should be sent
+    private func graftDirectPeerIfNeeded(_ peer: PeerID, topic: Topic) -> Bool {
+        guard meshState.isSubscribed(to: topic),
+              directPeers(for: topic).contains(peer),
+              let state = peerState.getPeer(peer),
+              state.version != .floodsub,
+              state.subscriptions.contains(topic),
+              !state.isBackedOff(for: topic),
+              meshState.addToMesh(peer, for: topic) else {
+            return false
+        }
+        peerScorer.peerJoinedMesh(peer, topic: topic)
+        emit(.peerJoinedMesh(peer: peer, topic: topic))
+        emit(.grafted(peer: peer, topic: topic))
+        return true
+    }
+
+    // MARK: - Blacklist
+
+    /// Blacklists a peer.
+    ///
+    /// All RPCs from the peer and all messages it originated are dropped.
+    /// The peer is removed from every mesh and from routing, so it is never
+    /// grafted or forwarded to. The underlying connection is left untouched.
+    ///
+    /// - Parameter peer: The peer to blacklist
+    public func blacklistPeer(_ peer: PeerID) {
+        let inserted = blacklist.withLock { $0.insert(peer).inserted }
+        guard inserted else { return }
+
+        let topics = meshState.topicsInMesh(for: peer)
+        meshState.removePeerFromAll(peer)
+        for topic in topics {
+            peerScorer.peerLeftMesh(peer, topic: topic)
+            emit(.peerLeftMesh(peer: peer, topic: topic))
+        }
+        peerState.removePeer(peer)
+    }
+
+    /// Removes a peer from the blacklist.
+    ///
+    /// The peer is routed to again once it re-establishes a GossipSub stream.
+    ///
+    /// - Parameter peer: The peer to remove
+    public func removeFromBlacklist(_ peer: PeerID) {
+        blacklist.withLock { _ = $0.remove(peer) }
+    }
+
+    /// Returns whether a peer is blacklisted.
+    public func isBlacklisted(_ peer: PeerID) -> Bool {
+        blacklist.withLock { $0.contains(peer) }
     }
 
     // MARK: - Event Stream
@@ -342,6 +412,9 @@ public final class GossipSubRouter: Sendable {
     ///   - from: The peer that sent it
     /// - Returns: Result containing response RPC and messages to forward
     public func handleRPC(_ rpc: GossipSubRPC, from peerID: PeerID) async -> RPCHandleResult {
+        // Drop everything from blacklisted peers before doing any work
+        guard !isBlacklisted(peerID) else { return RPCHandleResult() }
+
         var response = GossipSubRPC()
         var forwardMessages: [(peer: PeerID, rpc: GossipSubRPC)] = []
 
@@ -362,8 +435,13 @@ public final class GossipSubRouter: Sendable {
         }
 
         // Handle subscriptions
+        var directGrafts: [ControlMessage.Graft] = []
         for sub in filteredSubs {
             handleSubscription(sub, from: peerID)
+            // Direct peers are grafted as soon as they join one of our topics
+            if sub.subscribe && graftDirectPeerIfNeeded(peerID, topic: sub.topic) {
+                directGrafts.append(ControlMessage.Graft(topic: sub.topic))
+            }
         }
 
         // Handle messages and collect forwards
@@ -389,6 +467,12 @@ public final class GossipSubRouter: Sendable {
             }
         }
 
+        if !directGrafts.isEmpty {
+            var control = response.control ?? ControlMessageBatch()
+            control.grafts.append(contentsOf: directGrafts)
+            response.control = control
+        }
+
         return RPCHandleResult(
             response: response.isEmpty ? nil : response,
             forwardMessages: forwardMessages
@@ -437,6 +521,11 @@ public final class GossipSubRouter: Sendable {
             return []
         }
 
+        // Drop messages originated by blacklisted peers, whoever relays them
+        if let source = message.source, isBlacklisted(source) {
+            return []
+        }
+
         // Recompute message ID if custom function is set (A1)
         let effectiveMessage: GossipSubMessage
         if let idFn = configuration.messageIDFunction {
@@ -1210,6 +1299,14 @@ public final class GossipSubRouter: Sendable {
         var toSend: [(peer: PeerID, control: ControlMessageBatch)] = []
 
         for topic in meshState.subscribedTopics {
+            // Step 0: Keep connected direct peers meshed (v1.1 explicit peering)
+            for peer in directPeers(for: topic) {
+                guard graftDirectPeerIfNeeded(peer, topic: topic) else { continue }
+                var batch = ControlMessageBatch()
+                batch.grafts.append(ControlMessage.Graft(topic: topic))
+                toSend.append((peer, batch))
+            }
+
             let meshPeers = meshState.meshPeers(for: topic)
             let meshCount = meshPeers.count
             let D = configuration.meshDegree
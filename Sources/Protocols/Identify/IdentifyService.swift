@@ -3,11 +3,12 @@ import Foundation
 import NIOCore
 import P2PCore
 import P2PMux
+import P2PDiscovery
 import P2PProtocols
 import Synchronization
 
 /// Logger for IdentifyService operations.
-private let logger = Logger(label: "p2p.identify")
+private let logger = SubsystemLogger(.identify, label: "p2p.identify")
 
 /// Configuration for IdentifyService.
 public struct IdentifyConfiguration: Sendable {
@@ -189,6 +190,10 @@ public final class IdentifyService: EventEmitting, Sendable {
     /// Background cleanup task.
     private let cleanupTask: Mutex<Task<Void, Never>?>
 
+    /// Protocol book that identified peers' protocols are written to, so
+    /// stream opening can skip interactive negotiation for them.
+    private let protoBookState = Mutex<(any ProtoBook)?>(nil)
+
     /// Event stream for monitoring identify events.
     public var events: AsyncStream<IdentifyEvent> { channel.stream }
 
@@ -277,9 +282,21 @@ public final class IdentifyService: EventEmitting, Sendable {
 
         // Cache the info
         cacheInfo(info, for: peer)
+        await recordProtocols(info.protocols, for: peer)
 
         // Emit event
         emit(.received(peer: peer, info: info))
+        logger.debug(
+            "Identify received",
+            peer: peer,
+            protocol: ProtocolID.identify,
+            metadata: [
+                "agent": "\(info.agentVersion ?? "")",
+                "protocols": "\(info.protocols.count)",
+                "addresses": "\(info.listenAddresses.count)",
+                "signedRecord": "\(info.signedPeerRecord != nil)",
+            ]
+        )
 
         return info
     }
@@ -328,6 +345,7 @@ public final class IdentifyService: EventEmitting, Sendable {
         try await stream.write(data)
 
         emit(.sent(peer: peer))
+        logger.debug("Identify push sent", peer: peer, protocol: ProtocolID.identifyPush)
     }
 
     // MARK: - Peer Tracking for Auto-Push
@@ -592,6 +610,12 @@ public final class IdentifyService: EventEmitting, Sendable {
         }
     }
 
+    /// Replaces the peer's protocols in the attached protocol book, if any.
+    private func recordProtocols(_ protocols: [String], for peer: PeerID) async {
+        guard let protoBook = protoBookState.withLock({ $0 }) else { return }
+        await protoBook.setProtocols(protocols, for: peer)
+    }
+
     /// Evicts entries using strategy: expired first, then LRU.
     ///
     /// - Parameters:
@@ -830,8 +854,10 @@ public final class IdentifyService: EventEmitting, Sendable {
             try await context.stream.close()
 
             self.emit(.sent(peer: context.remotePeer))
+            logger.debug("Identify sent", peer: context.remotePeer, protocol: ProtocolID.identify)
         } catch {
             self.emit(.error(peer: context.remotePeer, .streamError(error.localizedDescription)))
+            logger.debug("Identify response failed: \(error)", peer: context.remotePeer, protocol: ProtocolID.identify)
             do {
                 try await context.stream.close()
             } catch {
@@ -862,10 +888,21 @@ public final class IdentifyService: EventEmitting, Sendable {
             let trustedInfo = try sanitizePushInfo(info, from: context.remotePeer)
 
             self.mergePushInfo(trustedInfo, for: context.remotePeer)
+            // An empty list leaves the known protocols as they are, as in the merge
+            if !trustedInfo.protocols.isEmpty {
+                await self.recordProtocols(trustedInfo.protocols, for: context.remotePeer)
+            }
 
             self.emit(.pushReceived(peer: context.remotePeer, info: trustedInfo))
+            logger.debug(
+                "Identify push received",
+                peer: context.remotePeer,
+                protocol: ProtocolID.identifyPush,
+                metadata: ["protocols": "\(trustedInfo.protocols.count)", "addresses": "\(trustedInfo.listenAddresses.count)"]
+            )
         } catch let identifyError as IdentifyError {
             self.emit(.error(peer: context.remotePeer, identifyError))
+            logger.debug("Identify push rejected: \(identifyError)", peer: context.remotePeer, protocol: ProtocolID.identifyPush)
         } catch {
             self.emit(.error(peer: context.remotePeer, .streamError(error.localizedDescription)))
         }
@@ -952,6 +989,7 @@ extension IdentifyService:
     LocalIdentityConsumer,
     ListenAddressConsumer,
     SupportedProtocolsConsumer,
+    PeerStoreConsumer,
     ActivatableService,
     StreamOpeningActivatable
 {
@@ -984,6 +1022,11 @@ extension IdentifyService:
         }
     }
 
+    public func attachPeerStoreContext(_ context: any PeerStoreContext) async {
+        let protoBook = await context.protoBook
+        protoBookState.withLock { $0 = protoBook }
+    }
+
     public func activate(using opener: any StreamOpener) async {
         if configuration.autoPush {
             autoPushState.withLock { state in
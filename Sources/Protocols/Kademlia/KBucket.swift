@@ -174,6 +174,23 @@ public struct KBucket: Sendable {
         return nil
     }
 
+    /// Removes a peer only if the bucket is full and a pending entry can take
+    /// its place; the oldest pending entry is promoted.
+    ///
+    /// - Parameter peerID: The peer to remove.
+    /// - Returns: The removed entry and its replacement, or nil if the peer is
+    ///   not in the bucket or there is nothing to replace it with.
+    public mutating func replace(_ peerID: PeerID) -> (removed: KBucketEntry, replacement: KBucketEntry)? {
+        guard isFull, !pending.isEmpty,
+              let index = entries.firstIndex(where: { $0.peerID == peerID }) else {
+            return nil
+        }
+        let removed = entries.remove(at: index)
+        let replacement = pending.removeFirst()
+        entries.append(replacement)
+        return (removed, replacement)
+    }
+
     /// Gets the oldest entry (candidate for eviction check).
     public var oldest: KBucketEntry? {
         entries.first
@@ -5,7 +5,15 @@ import Foundation
 /// Constants and defaults for Kademlia DHT protocol.
 public enum KademliaProtocol {
     /// Protocol ID for Kademlia DHT.
-    public static let protocolID = "/ipfs/kad/1.0.0"
+    public static let protocolID = protocolID(prefix: defaultProtocolPrefix)
+
+    /// Protocol prefix of the public IPFS DHT.
+    public static let defaultProtocolPrefix = "/ipfs"
+
+    /// Protocol ID for a DHT with the given prefix (`<prefix>/kad/1.0.0`).
+    public static func protocolID(prefix: String) -> String {
+        "\(prefix)/kad/1.0.0"
+    }
 
     /// Maximum message size (1MB).
     public static let maxMessageSize: Int = 1024 * 1024
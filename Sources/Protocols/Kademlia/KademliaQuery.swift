@@ -3,6 +3,8 @@
 import Foundation
 import P2PCore
 
+private let logger = SubsystemLogger(.dht, label: "p2p.kademlia.query")
+
 /// The type of Kademlia query.
 public enum KademliaQueryType: Sendable {
     /// Find nodes closest to a key.
@@ -145,6 +147,9 @@ public struct KademliaQuery: Sendable {
     /// `nil` if unknown (self is then only excluded by responder identity).
     public let localPeerID: PeerID?
 
+    /// Receives the query's per-hop events, or `nil` for an untraced query.
+    public let trace: KademliaQueryTraceHandler?
+
     /// Creates a query.
     ///
     /// - Parameters:
@@ -153,18 +158,21 @@ public struct KademliaQuery: Sendable {
     ///   - validator: Record validator for GET_VALUE selection (optional).
     ///   - skademliaConfig: S/Kademlia configuration (default: disabled).
     ///   - localPeerID: The local peer ID, to exclude self from results.
+    ///   - trace: Receives the query's per-hop events (optional).
     public init(
         type: KademliaQueryType,
         config: KademliaQueryConfig = .default,
         validator: (any RecordValidator)? = nil,
         skademliaConfig: SKademliaConfig = .disabled,
-        localPeerID: PeerID? = nil
+        localPeerID: PeerID? = nil,
+        trace: KademliaQueryTraceHandler? = nil
     ) {
         self.queryType = type
         self.config = config
         self.validator = validator
         self.skademliaConfig = skademliaConfig
         self.localPeerID = localPeerID
+        self.trace = trace
 
         switch type {
         case .findNode(let key):
@@ -201,7 +209,7 @@ public struct KademliaQuery: Sendable {
         }
 
         // Execute with timeout (single-path)
-        return try await withThrowingTaskGroup(of: KademliaQueryResult?.self) { group in
+        let result = try await withThrowingTaskGroup(of: KademliaQueryResult?.self) { group in
             // Add the main query task
             group.addTask {
                 try await self.executeInternal(initialPeers: initialPeers, delegate: delegate)
@@ -213,19 +221,24 @@ public struct KademliaQuery: Sendable {
                 return nil // Timeout sentinel
             }
 
-            // Wait for first completion
+            // Wait for first completion (nil when the timeout wins)
             for try await result in group {
                 group.cancelAll() // Cancel remaining tasks
-                if let queryResult = result {
-                    return queryResult
-                } else {
-                    // Timeout occurred
-                    throw KademliaError.timeout
-                }
+                return result
             }
+            return nil
+        }
+        return try timedOutUnlessFinished(result)
+    }
 
+    /// Throws `KademliaError.timeout` for the timeout sentinel. Called after
+    /// the task group has drained, so `.timedOut` is the last traced event.
+    private func timedOutUnlessFinished(_ result: KademliaQueryResult?) throws -> KademliaQueryResult {
+        guard let result else {
+            trace?(.timedOut)
             throw KademliaError.timeout
         }
+        return result
     }
 
     // MARK: - Disjoint Paths
@@ -262,7 +275,7 @@ public struct KademliaQuery: Sendable {
         }
 
         // Execute all paths in parallel with overall timeout
-        return try await withThrowingTaskGroup(of: KademliaQueryResult?.self) { group in
+        let result = try await withThrowingTaskGroup(of: KademliaQueryResult?.self) { group in
             // Timeout task
             group.addTask {
                 try await Task.sleep(for: self.config.timeout)
@@ -272,11 +285,12 @@ public struct KademliaQuery: Sendable {
             // Main execution task
             group.addTask {
                 let pathResults = await withTaskGroup(of: KademliaQueryResult?.self) { pathGroup in
-                    for partition in partitions {
+                    for (path, partition) in partitions.enumerated() {
                         pathGroup.addTask {
                             do {
                                 return try await self.executeInternal(
                                     initialPeers: partition,
+                                    path: path,
                                     delegate: delegate
                                 )
                             } catch {
@@ -303,15 +317,11 @@ public struct KademliaQuery: Sendable {
             // Wait for first completion (query or timeout)
             for try await result in group {
                 group.cancelAll()
-                if let queryResult = result {
-                    return queryResult
-                } else {
-                    throw KademliaError.timeout
-                }
+                return result
             }
-
-            throw KademliaError.timeout
+            return nil
         }
+        return try timedOutUnlessFinished(result)
     }
 
     /// Merges results from multiple disjoint path queries.
@@ -379,6 +389,7 @@ public struct KademliaQuery: Sendable {
     /// Internal query execution logic.
     private func executeInternal(
         initialPeers: [KademliaPeer],
+        path: Int = 0,
         delegate: any KademliaQueryDelegate
     ) async throws -> KademliaQueryResult {
         // Track all peers seen during the query
@@ -408,8 +419,13 @@ public struct KademliaQuery: Sendable {
         // maxIterations, which an adversary could otherwise steer).
         var closestRespondedDistance: KademliaKey?
 
+        let queryName = Self.name(of: queryType)
+        logger.debug("DHT query started", metadata: ["query": "\(queryName)", "seeds": "\(initialPeers.count)"])
+
         // Iterative query loop. `maxIterations` is only a safety cap.
-        for _ in 0..<config.maxIterations {
+        var termination = KademliaQueryTermination.maxIterations
+        var rounds = 0
+        for round in 0..<config.maxIterations {
             // Check for cancellation (timeout)
             try Task.checkCancellation()
             // Select ALPHA closest not-contacted peers
@@ -417,20 +433,33 @@ public struct KademliaQuery: Sendable {
 
             if candidates.isEmpty {
                 // No more peers to query
+                logger.debug("DHT query exhausted candidates", metadata: ["query": "\(queryName)", "round": "\(round)"])
+                termination = .exhausted
                 break
             }
+            rounds = round + 1
 
             // Mark selected peers as waiting
             for peer in candidates {
                 seenPeers[peer.peer.id]?.state = .waiting
+                trace?(.queried(KademliaQueryHop(path: path, round: round, peer: peer.peer.id, distance: peer.distance)))
             }
 
             // Query peers in parallel
             let results = await queryPeersParallel(candidates, delegate: delegate)
+            // A timed-out query stops here rather than tracing its cancelled
+            // requests as failures
+            try Task.checkCancellation()
 
             // Process results
             var discoveredCloser = false
-            for (peerID, result) in results {
+            for (peerID, result, rtt) in results {
+                let hop = KademliaQueryHop(
+                    path: path,
+                    round: round,
+                    peer: peerID,
+                    distance: KademliaKey(from: peerID).distance(to: targetKey)
+                )
                 switch result {
                 case .success(let response):
                     seenPeers[peerID]?.state = .succeeded
@@ -443,13 +472,12 @@ public struct KademliaQuery: Sendable {
                         closestRespondedDistance = respDistance
                     }
 
+                    let returnedPeers: [KademliaPeer]
+                    let accepted: [KademliaPeer]
                     switch response {
                     case .findNode(let closerPeers):
-                        let accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
-                        for newPeer in accepted {
-                            seenPeers[newPeer.id] = QueryPeer(peer: newPeer, target: targetKey)
-                            discoveredCloser = true
-                        }
+                        returnedPeers = closerPeers
+                        accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
 
                     case .getValue(let record, let closerPeers):
                         // Collect records for later selection, but only those
@@ -462,11 +490,8 @@ public struct KademliaQuery: Sendable {
                                 collectedRecords.append((record, peerID))
                             }
                         }
-                        let accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
-                        for newPeer in accepted {
-                            seenPeers[newPeer.id] = QueryPeer(peer: newPeer, target: targetKey)
-                            discoveredCloser = true
-                        }
+                        returnedPeers = closerPeers
+                        accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
 
                     case .getProviders(let providers, let closerPeers):
                         // Collect providers
@@ -475,17 +500,31 @@ public struct KademliaQuery: Sendable {
                                 foundProviders.append(provider)
                             }
                         }
-                        let accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
-                        for newPeer in accepted {
-                            seenPeers[newPeer.id] = QueryPeer(peer: newPeer, target: targetKey)
-                            discoveredCloser = true
-                        }
+                        returnedPeers = closerPeers
+                        accepted = acceptableCloserPeers(closerPeers, from: peerID, seenPeers: seenPeers)
+                    }
+                    for newPeer in accepted {
+                        seenPeers[newPeer.id] = QueryPeer(peer: newPeer, target: targetKey)
+                        discoveredCloser = true
                     }
+                    trace?(.responded(hop, closerPeers: returnedPeers, accepted: accepted.count, rtt: rtt))
 
-                case .failure:
+                case .failure(let error):
                     seenPeers[peerID]?.state = .failed
+                    logger.debug("DHT query peer failed: \(error)", peer: peerID, metadata: ["query": "\(queryName)"])
+                    trace?(.failed(hop, error: String(describing: error), rtt: rtt))
                 }
             }
+            logger.trace(
+                "DHT query round finished",
+                metadata: [
+                    "query": "\(queryName)",
+                    "round": "\(round)",
+                    "queried": "\(candidates.count)",
+                    "known": "\(seenPeers.count)",
+                    "discoveredCloser": "\(discoveredCloser)",
+                ]
+            )
 
             // Convergence test: if this round discovered no peer strictly closer
             // than what we've already seen, and there are no closer un-contacted
@@ -499,6 +538,8 @@ public struct KademliaQuery: Sendable {
                     hasCloserRemaining = !remaining.isEmpty
                 }
                 if !hasCloserRemaining {
+                    logger.debug("DHT query converged", metadata: ["query": "\(queryName)", "round": "\(round)"])
+                    termination = .converged
                     break
                 }
             }
@@ -506,6 +547,16 @@ public struct KademliaQuery: Sendable {
 
         // Build final result
         let closestPeers = getClosestSucceeded(from: seenPeers, count: config.k)
+        trace?(.finished(path: path, termination, rounds: rounds, closest: closestPeers))
+        logger.debug(
+            "DHT query finished",
+            metadata: [
+                "query": "\(queryName)",
+                "closest": "\(closestPeers.count)",
+                "records": "\(collectedRecords.count)",
+                "providers": "\(foundProviders.count)",
+            ]
+        )
 
         switch queryType {
         case .findNode:
@@ -525,6 +576,15 @@ public struct KademliaQuery: Sendable {
 
     // MARK: - Private Helpers
 
+    /// The query type's name for log metadata.
+    private static func name(of queryType: KademliaQueryType) -> String {
+        switch queryType {
+        case .findNode: return "FIND_NODE"
+        case .getValue: return "GET_VALUE"
+        case .getProviders: return "GET_PROVIDERS"
+        }
+    }
+
     /// Selects the best record from collected responses using the validator.
     private func selectBestRecord(
         from collectedRecords: [(record: KademliaRecord, from: PeerID)]
@@ -675,24 +735,16 @@ public struct KademliaQuery: Sendable {
     private func queryPeersParallel(
         _ peers: [QueryPeer],
         delegate: any KademliaQueryDelegate
-    ) async -> [(PeerID, Result<QueryResponse, Error>)] {
-        await withTaskGroup(of: (PeerID, Result<QueryResponse, Error>).self) { group in
+    ) async -> [(PeerID, Result<QueryResponse, Error>, Duration)] {
+        await withTaskGroup(of: (PeerID, Result<QueryResponse, Error>, Duration).self) { group in
             for queryPeer in peers {
                 group.addTask {
-                    let peerID = queryPeer.peer.id
-                    do {
-                        let response = try await self.querySinglePeer(
-                            queryPeer.peer.id,
-                            delegate: delegate
-                        )
-                        return (peerID, .success(response))
-                    } catch {
-                        return (peerID, .failure(error))
-                    }
+                    let (result, rtt) = await self.querySinglePeer(queryPeer.peer.id, delegate: delegate)
+                    return (queryPeer.peer.id, result, rtt)
                 }
             }
 
-            var results: [(PeerID, Result<QueryResponse, Error>)] = []
+            var results: [(PeerID, Result<QueryResponse, Error>, Duration)] = []
             for await result in group {
                 results.append(result)
             }
@@ -700,10 +752,11 @@ public struct KademliaQuery: Sendable {
         }
     }
 
+    /// Sends the query's request to one peer and measures the round trip.
     private func querySinglePeer(
         _ peerID: PeerID,
         delegate: any KademliaQueryDelegate
-    ) async throws -> QueryResponse {
+    ) async -> (Result<QueryResponse, Error>, Duration) {
         let start = ContinuousClock.now
         do {
             let response: QueryResponse
@@ -722,11 +775,11 @@ public struct KademliaQuery: Sendable {
             }
             let elapsed = ContinuousClock.now - start
             delegate.recordLatency(peer: peerID, latency: elapsed, success: true)
-            return response
+            return (.success(response), elapsed)
         } catch {
             let elapsed = ContinuousClock.now - start
             delegate.recordLatency(peer: peerID, latency: elapsed, success: false)
-            throw error
+            return (.failure(error), elapsed)
         }
     }
 }
@@ -0,0 +1,91 @@
+/// KademliaQueryTrace - Per-hop events of an iterative query.
+///
+/// A traced query reports every peer it contacts, what each one answered and
+/// how long it took, and why the lookup stopped, in the spirit of
+/// go-libp2p-kad-dht's routing query events (SendingQuery, PeerResponse,
+/// QueryError). It is off unless a handler is passed; the handler runs
+/// inline on the query's task, so it should hand events off rather than block.
+
+import Foundation
+import P2PCore
+
+/// Where in a query a peer was contacted.
+public struct KademliaQueryHop: Sendable, Equatable {
+    /// The disjoint path the peer was contacted on (0 for single-path queries).
+    public let path: Int
+
+    /// The query round, starting at 0.
+    public let round: Int
+
+    /// The contacted peer.
+    public let peer: PeerID
+
+    /// The peer's XOR distance from the target.
+    public let distance: KademliaKey
+
+    public init(path: Int, round: Int, peer: PeerID, distance: KademliaKey) {
+        self.path = path
+        self.round = round
+        self.peer = peer
+        self.distance = distance
+    }
+}
+
+/// Why an iterative query stopped.
+public enum KademliaQueryTermination: String, Sendable {
+    /// A round found no peer closer than the closest responder, and no closer
+    /// peer was left to contact.
+    case converged
+
+    /// Every known peer had been contacted.
+    case exhausted
+
+    /// The `maxIterations` safety cap was reached.
+    case maxIterations
+}
+
+/// One event of a traced query.
+public enum KademliaQueryTraceEvent: Sendable {
+    /// A request is being sent to a peer.
+    case queried(KademliaQueryHop)
+
+    /// A peer answered with `closerPeers`; `accepted` of them were new and
+    /// closer to the target than the peer itself, and will be contacted.
+    case responded(KademliaQueryHop, closerPeers: [KademliaPeer], accepted: Int, rtt: Duration)
+
+    /// A peer failed to answer.
+    case failed(KademliaQueryHop, error: String, rtt: Duration)
+
+    /// A path stopped after `rounds` rounds with the closest responders.
+    case finished(path: Int, KademliaQueryTermination, rounds: Int, closest: [KademliaPeer])
+
+    /// The query timeout elapsed before every path finished.
+    case timedOut
+}
+
+/// A callback receiving the events of a traced query.
+public typealias KademliaQueryTraceHandler = @Sendable (KademliaQueryTraceEvent) -> Void
+
+extension KademliaQueryTraceEvent: CustomStringConvertible {
+    public var description: String {
+        switch self {
+        case .queried(let hop):
+            return "\(hop) queried"
+        case .responded(let hop, let closerPeers, let accepted, let rtt):
+            return "\(hop) responded: \(closerPeers.count) peers, \(accepted) accepted, rtt \(rtt)"
+        case .failed(let hop, let error, let rtt):
+            return "\(hop) failed after \(rtt): \(error)"
+        case .finished(let path, let termination, let rounds, let closest):
+            return "path \(path) \(termination.rawValue) after \(rounds) rounds, \(closest.count) closest peers"
+        case .timedOut:
+            return "query timed out"
+        }
+    }
+}
+
+extension KademliaQueryHop: CustomStringConvertible {
+    /// The distance is shown as its common prefix length with the target.
+    public var description: String {
+        "path \(path) round \(round) \(peer) cpl=\(distance.leadingZeroBits)"
+    }
+}
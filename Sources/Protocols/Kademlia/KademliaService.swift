@@ -9,9 +9,10 @@ import Foundation
 import Synchronization
 import P2PCore
 import P2PMux
+import P2PDiscovery
 import P2PProtocols
 
-private let logger = Logger(label: "p2p.kademlia")
+private let logger = SubsystemLogger(.dht, label: "p2p.kademlia")
 
 /// Configuration for KademliaService.
 public struct KademliaConfiguration: Sendable {
@@ -55,6 +56,28 @@ public struct KademliaConfiguration: Sendable {
     /// Operating mode.
     public var mode: KademliaMode
 
+    /// How long to wait, after a peer connects, for Identify to report that
+    /// it speaks the DHT protocol before giving up on adding it to the
+    /// routing table.
+    public var identifyTimeout: Duration
+
+    /// Protocol prefix of the DHT to join; the protocol ID is
+    /// `<prefix>/kad/1.0.0`.
+    public var protocolPrefix: String
+
+    /// Further restricts which DHT peers may enter the routing table.
+    /// Set to nil to admit every peer that speaks the protocol.
+    public var routeTableFilter: RouteTableFilter?
+
+    /// Concurrency, rate and retry limits for queued provides and
+    /// provider republishing.
+    public var provideQueue: ProvideQueueConfiguration
+
+    /// The DHT protocol ID for `protocolPrefix`.
+    public var protocolID: String {
+        KademliaProtocol.protocolID(prefix: protocolPrefix)
+    }
+
     // MARK: - Record Validation
 
     /// Record validator for incoming PUT_VALUE requests.
@@ -128,7 +151,11 @@ public struct KademliaConfiguration: Sendable {
         enableDynamicAlpha: Bool = false,
         minAlpha: Int = 1,
         maxAlpha: Int = 10,
-        skademlia: SKademliaConfig = .disabled
+        skademlia: SKademliaConfig = .disabled,
+        identifyTimeout: Duration = .seconds(10),
+        protocolPrefix: String = KademliaProtocol.defaultProtocolPrefix,
+        routeTableFilter: RouteTableFilter? = nil,
+        provideQueue: ProvideQueueConfiguration = .default
     ) {
         self.kValue = kValue
         self.alphaValue = alphaValue
@@ -149,6 +176,10 @@ public struct KademliaConfiguration: Sendable {
         self.minAlpha = minAlpha
         self.maxAlpha = maxAlpha
         self.skademlia = skademlia
+        self.identifyTimeout = identifyTimeout
+        self.protocolPrefix = protocolPrefix
+        self.routeTableFilter = routeTableFilter
+        self.provideQueue = provideQueue
     }
 
     /// Default configuration.
@@ -185,7 +216,7 @@ public final class KademliaService: EventEmitting, Sendable {
     // MARK: - StreamService
 
     public var protocolIDs: [String] {
-        [KademliaProtocol.protocolID]
+        [configuration.protocolID]
     }
 
     // MARK: - Properties
@@ -205,6 +236,9 @@ public final class KademliaService: EventEmitting, Sendable {
     /// The provider store.
     public let providerStore: ProviderStore
 
+    /// Queue that announces provided keys within the configured limits.
+    public let provideQueue: ProvideQueue
+
     /// Event channel (dedicated).
     private let channel = EventChannel<KademliaEvent>()
 
@@ -214,6 +248,10 @@ public final class KademliaService: EventEmitting, Sendable {
     private struct ServiceState: Sendable {
         var mode: KademliaMode
         var opener: (any StreamOpener)?
+        var peerStore: (any PeerStore)?
+        var protoBook: (any ProtoBook)?
+        /// Connected peers waiting for Identify, by peer.
+        var identifyWaits: [PeerID: Task<Void, Never>] = [:]
     }
 
     /// Peer latency tracker.
@@ -245,6 +283,7 @@ public final class KademliaService: EventEmitting, Sendable {
         self.routingTable = RoutingTable(localPeerID: localPeerID, kValue: configuration.kValue)
         self.recordStore = RecordStore(defaultTTL: configuration.recordTTL)
         self.providerStore = ProviderStore(defaultTTL: configuration.providerTTL)
+        self.provideQueue = ProvideQueue(configuration: configuration.provideQueue)
         self.serviceState = Mutex(ServiceState(mode: configuration.mode, opener: opener))
         self.cleanupTask = Mutex(nil)
         self.refreshTask = Mutex(nil)
@@ -270,7 +309,14 @@ public final class KademliaService: EventEmitting, Sendable {
         stopMaintenance()
         stopRefresh()
         stopRepublish()
-        serviceState.withLock { $0.opener = nil }
+        stopProvideQueue()
+        serviceState.withLock { s in
+            s.opener = nil
+            for wait in s.identifyWaits.values {
+                wait.cancel()
+            }
+            s.identifyWaits = [:]
+        }
         channel.yield(.stopped)
         channel.finish()
     }
@@ -357,11 +403,14 @@ public final class KademliaService: EventEmitting, Sendable {
 
     /// Starts the background republish loop.
     ///
-    /// Periodically republishes locally stored records and provider announcements
-    /// to the closest peers, ensuring data persistence across the DHT.
+    /// Periodically republishes locally stored records to the closest peers,
+    /// and queues stale provider announcements on `provideQueue`, ensuring
+    /// data persistence across the DHT. Starts the provide queue if it is
+    /// not running.
     ///
     /// - Parameter opener: Stream opener for sending requests.
     public func startRepublish(using opener: any StreamOpener) {
+        startProvideQueue(using: opener)
         republishTask.withLock { task in
             task?.cancel()
             task = Task { [weak self] in
@@ -413,31 +462,15 @@ public final class KademliaService: EventEmitting, Sendable {
                 }
             }
 
-            // Republish stale provider announcements
+            // Queue stale provider announcements
             let staleProviderKeys = providerStore.keysNeedingRepublish(
                 localPeerID: localPeerID,
                 threshold: configuration.providerRepublishInterval
             )
             for key in staleProviderKeys {
-                guard !Task.isCancelled else { break }
-                let kadKey = KademliaKey(hashing: key)
-                let closestPeers = routingTable.closestPeers(to: kadKey)
-                    .map { KademliaPeer(id: $0.peerID, addresses: $0.addresses) }
                 let localAddresses = providerStore.getProviders(for: key)
                     .first(where: { $0.peerID == localPeerID })?.addresses ?? []
-                let localProvider = KademliaPeer(id: localPeerID, addresses: localAddresses)
-                var announced = 0
-                for peer in closestPeers {
-                    do {
-                        try await sendAddProvider(to: peer.id, key: key, provider: localProvider, opener: opener)
-                        announced += 1
-                    } catch {
-                        logger.debug("Provider republish to \(peer.id) failed: \(error)")
-                    }
-                }
-                if announced > 0 {
-                    emit(.providerAnnounced(key: key, toPeers: announced))
-                }
+                provideQueue.enqueue(key: key, addresses: localAddresses)
             }
 
             // Periodic latency tracker cleanup
@@ -445,6 +478,29 @@ public final class KademliaService: EventEmitting, Sendable {
         }
     }
 
+    // MARK: - Provide Queue
+
+    /// Starts announcing keys queued on `provideQueue`.
+    ///
+    /// Each key is announced like `provide(key:addresses:using:)`; an
+    /// announcement that reaches no peer counts as a failure and is retried.
+    ///
+    /// - Parameter opener: Stream opener for sending requests.
+    public func startProvideQueue(using opener: any StreamOpener) {
+        provideQueue.start { [weak self] key, addresses in
+            guard let self else { throw CancellationError() }
+            let announced = try await self.provide(key: key, addresses: addresses, using: opener)
+            if announced == 0 {
+                throw KademliaError.noPeersAvailable
+            }
+        }
+    }
+
+    /// Stops announcing queued keys. Pending keys stay queued.
+    public func stopProvideQueue() {
+        provideQueue.stop()
+    }
+
     /// Runs the refresh loop.
     private func runRefreshLoop(interval: Duration) async {
         while !Task.isCancelled {
@@ -604,6 +660,83 @@ public final class KademliaService: EventEmitting, Sendable {
         return false
     }
 
+    // MARK: - Connection Tracking
+
+    /// Interval between protocol book checks while waiting for Identify.
+    private static let identifyPollInterval: Duration = .milliseconds(100)
+
+    /// Adds a connected peer to the routing table once Identify reports that
+    /// it speaks the DHT protocol, with the addresses the peer store knows.
+    ///
+    /// DHT clients do not advertise the protocol, so only servers are added.
+    /// Gives up after `identifyTimeout`, or when the peer disconnects first.
+    private func addWhenIdentified(_ peer: PeerID, peerStore: any PeerStore, protoBook: any ProtoBook) async {
+        let protocolID = configuration.protocolID
+        let deadline = ContinuousClock.now + configuration.identifyTimeout
+        while await protoBook.firstSupportedProtocol([protocolID], for: peer) == nil {
+            guard ContinuousClock.now < deadline else {
+                logger.debug("Peer \(peer) did not advertise \(protocolID) within \(configuration.identifyTimeout)")
+                return
+            }
+            do {
+                try await Task.sleep(for: Self.identifyPollInterval)
+            } catch {
+                return
+            }
+        }
+        let addresses = await peerStore.addresses(for: peer)
+        guard !Task.isCancelled, passesFilter(peer, addresses: addresses) else { return }
+        addPeer(peer, addresses: addresses)
+    }
+
+    /// Adds an inbound requester or a FIND_NODE result if it is eligible.
+    ///
+    /// With a protocol book attached, the peer must already be listed as
+    /// speaking the DHT protocol, which DHT clients are not; without one there
+    /// is nothing to check against and the peer is taken on trust. The route
+    /// table filter applies either way.
+    private func addIfEligible(_ peer: PeerID, addresses: [Multiaddr]) async {
+        if let protoBook = serviceState.withLock({ $0.protoBook }),
+           await protoBook.firstSupportedProtocol([configuration.protocolID], for: peer) == nil {
+            return
+        }
+        guard passesFilter(peer, addresses: addresses) else { return }
+        addPeer(peer, addresses: addresses)
+    }
+
+    /// Adds a peer that answered a DHT request, filtered on the addresses the
+    /// routing table and peer store already hold for it.
+    private func addResponder(_ peer: PeerID) async {
+        var addresses = routingTable.entry(for: peer)?.addresses ?? []
+        if let peerStore = serviceState.withLock({ $0.peerStore }) {
+            for address in await peerStore.addresses(for: peer) where !addresses.contains(address) {
+                addresses.append(address)
+            }
+        }
+        guard passesFilter(peer, addresses: addresses) else { return }
+        addPeer(peer)
+    }
+
+    private func passesFilter(_ peer: PeerID, addresses: [Multiaddr]) -> Bool {
+        guard let filter = configuration.routeTableFilter, !filter(peer, addresses) else {
+            return true
+        }
+        logger.debug("Route table filter rejected \(peer)")
+        return false
+    }
+
+    /// Removes a disconnected peer if its bucket is full and a pending peer
+    /// can take its place; otherwise the peer stays, as it is still a valid
+    /// contact.
+    private func replaceIfFull(_ peer: PeerID) {
+        guard let replaced = routingTable.replacePeer(peer),
+              let index = routingTable.bucketIndex(for: peer) else {
+            return
+        }
+        emit(.peerRemoved(replaced.removed.peerID, bucket: index))
+        emit(.peerAdded(replaced.replacement.peerID, bucket: index))
+    }
+
     // MARK: - Stream Handling
 
     /// Whether inbound queries should be accepted based on the current mode.
@@ -629,8 +762,8 @@ public final class KademliaService: EventEmitting, Sendable {
             return
         }
 
-        // Add peer to routing table
-        addPeer(context.remotePeer, addresses: [context.remoteAddress])
+        // Add peer to routing table if it serves the DHT itself
+        await addIfEligible(context.remotePeer, addresses: [context.remoteAddress])
 
         do {
             // Apply per-peer timeout to server-side processing to prevent DoS
@@ -883,6 +1016,49 @@ public final class KademliaService: EventEmitting, Sendable {
     ///   - opener: Stream opener for sending requests.
     /// - Returns: The closest peers found.
     public func findNode(_ targetKey: KademliaKey, using opener: any StreamOpener) async throws -> [KademliaPeer] {
+        try await findNode(targetKey, trace: nil, using: opener)
+    }
+
+    /// Looks up a peer, reporting every hop of the lookup to `trace`.
+    ///
+    /// Runs FIND_NODE for the peer's key. The peer is found when a responder
+    /// returns it among its closer peers or it answers the query itself.
+    ///
+    /// - Parameters:
+    ///   - target: The peer to find.
+    ///   - trace: Receives the lookup's per-hop events.
+    ///   - opener: Stream opener for sending requests.
+    /// - Returns: The peer with the addresses it was returned with.
+    /// - Throws: `KademliaError.peerNotFound` if the lookup finished without it.
+    public func findPeer(
+        _ target: PeerID,
+        trace: @escaping KademliaQueryTraceHandler,
+        using opener: any StreamOpener
+    ) async throws -> KademliaPeer {
+        // Keep the first sighting that carries addresses
+        let sighting = Mutex<KademliaPeer?>(nil)
+        let closest = try await findNode(KademliaKey(from: target), trace: { event in
+            if case .responded(_, let closerPeers, _, _) = event,
+               let peer = closerPeers.first(where: { $0.id == target && !$0.addresses.isEmpty }) {
+                sighting.withLock { if $0 == nil { $0 = peer } }
+            }
+            trace(event)
+        }, using: opener)
+
+        if let peer = sighting.withLock({ $0 }) {
+            return peer
+        }
+        if let peer = closest.first(where: { $0.id == target }) {
+            return peer
+        }
+        throw KademliaError.peerNotFound(target)
+    }
+
+    private func findNode(
+        _ targetKey: KademliaKey,
+        trace: KademliaQueryTraceHandler?,
+        using opener: any StreamOpener
+    ) async throws -> [KademliaPeer] {
         let queryInfo = QueryInfo(type: .findNode, targetKey: targetKey)
         emit(.queryStarted(queryInfo))
 
@@ -915,7 +1091,8 @@ public final class KademliaService: EventEmitting, Sendable {
                 timeout: configuration.queryTimeout
             ),
             skademliaConfig: configuration.skademlia,
-            localPeerID: localPeerID
+            localPeerID: localPeerID,
+            trace: trace
         )
 
         let delegate = QueryDelegateImpl(service: self, opener: opener)
@@ -927,7 +1104,7 @@ public final class KademliaService: EventEmitting, Sendable {
             case .nodes(let peers):
                 // Add found peers to routing table
                 for peer in peers {
-                    addPeer(peer.id, addresses: peer.addresses)
+                    await addIfEligible(peer.id, addresses: peer.addresses)
                 }
                 emit(.querySucceeded(queryInfo, result: .peers(count: peers.count)))
                 return peers
@@ -1166,6 +1343,61 @@ public final class KademliaService: EventEmitting, Sendable {
         return announcedCount
     }
 
+    /// Gets providers for the content a CID names.
+    ///
+    /// The query key is the CID's multihash, so CIDv0, CIDv1 and a
+    /// raw-codec CID wrapping the same multihash find the same providers,
+    /// as with go-libp2p-kad-dht.
+    ///
+    /// - Parameters:
+    ///   - cid: The content identifier.
+    ///   - opener: Stream opener for sending requests.
+    /// - Returns: The providers found.
+    public func getProviders(for cid: CID, using opener: any StreamOpener) async throws -> [KademliaPeer] {
+        try await getProviders(for: Data(cid.routingKey), using: opener)
+    }
+
+    /// Announces as a provider for the content a CID names, keyed by the
+    /// CID's multihash like `getProviders(for:using:)`.
+    ///
+    /// - Parameters:
+    ///   - cid: The content identifier.
+    ///   - addresses: Local addresses to announce.
+    ///   - opener: Stream opener for sending requests.
+    /// - Returns: Number of peers the announcement was sent to.
+    @discardableResult
+    public func provide(_ cid: CID, addresses: [Multiaddr], using opener: any StreamOpener) async throws -> Int {
+        try await provide(key: Data(cid.routingKey), addresses: addresses, using: opener)
+    }
+
+    /// Becomes a provider for content locally and queues the announcement on
+    /// `provideQueue`, which sends it within the configured concurrency and
+    /// rate limits and retries it on failure.
+    ///
+    /// - Parameters:
+    ///   - key: The content key.
+    ///   - addresses: Local addresses to announce.
+    /// - Returns: Whether the key was newly queued; false if it was already
+    ///   pending or being announced.
+    @discardableResult
+    public func enqueueProvide(key: Data, addresses: [Multiaddr]) -> Bool {
+        providerStore.addProvider(for: key, peerID: localPeerID, addresses: addresses)
+        emit(.providerAdded(key: key))
+        return provideQueue.enqueue(key: key, addresses: addresses)
+    }
+
+    /// Queues the announcement for the content a CID names, keyed by the
+    /// CID's multihash like `provide(_:addresses:using:)`.
+    ///
+    /// - Parameters:
+    ///   - cid: The content identifier.
+    ///   - addresses: Local addresses to announce.
+    /// - Returns: Whether the key was newly queued.
+    @discardableResult
+    public func enqueueProvide(_ cid: CID, addresses: [Multiaddr]) -> Bool {
+        enqueueProvide(key: Data(cid.routingKey), addresses: addresses)
+    }
+
     // MARK: - Private Helpers
 
     private func storeOnPeers(
@@ -1230,7 +1462,7 @@ public final class KademliaService: EventEmitting, Sendable {
         let response = try await sendMessage(message, to: peer, opener: opener)
 
         // Add responding peer to routing table
-        addPeer(peer)
+        await addResponder(peer)
 
         return response.closerPeers
     }
@@ -1244,7 +1476,7 @@ public final class KademliaService: EventEmitting, Sendable {
         let response = try await sendMessage(message, to: peer, opener: opener)
 
         // Add responding peer to routing table
-        addPeer(peer)
+        await addResponder(peer)
 
         return (response.record, response.closerPeers)
     }
@@ -1258,7 +1490,7 @@ public final class KademliaService: EventEmitting, Sendable {
         _ = try await sendMessage(message, to: peer, opener: opener)
 
         // Add responding peer to routing table
-        addPeer(peer)
+        await addResponder(peer)
     }
 
     fileprivate func sendGetProviders(
@@ -1270,7 +1502,7 @@ public final class KademliaService: EventEmitting, Sendable {
         let response = try await sendMessage(message, to: peer, opener: opener)
 
         // Add responding peer to routing table
-        addPeer(peer)
+        await addResponder(peer)
 
         return (response.providerPeers, response.closerPeers)
     }
@@ -1285,7 +1517,7 @@ public final class KademliaService: EventEmitting, Sendable {
         _ = try await sendMessage(message, to: peer, opener: opener)
 
         // Add responding peer to routing table
-        addPeer(peer)
+        await addResponder(peer)
     }
 
     private func sendMessage(
@@ -1295,7 +1527,7 @@ public final class KademliaService: EventEmitting, Sendable {
     ) async throws -> KademliaMessage {
         // Open stream with timeout (phase 1)
         let stream = try await withPeerTimeout {
-            try await opener.newStream(to: peer, protocol: KademliaProtocol.protocolID)
+            try await opener.newStream(to: peer, protocol: configuration.protocolID)
         }
 
         do {
@@ -1386,11 +1618,51 @@ private final class QueryDelegateImpl: KademliaQueryDelegate, Sendable {
 
 // MARK: - StreamService
 
-extension KademliaService: LifecycleService, StreamService, ActivatableService, StreamOpeningActivatable {
+extension KademliaService:
+    LifecycleService,
+    StreamService,
+    PeerObserver,
+    PeerStoreConsumer,
+    ActivatableService,
+    StreamOpeningActivatable
+{
     public func handleInboundStream(_ context: StreamContext) async {
         await handleStream(context)
     }
 
+    public func attachPeerStoreContext(_ context: any PeerStoreContext) async {
+        let peerStore = await context.peerStore
+        let protoBook = await context.protoBook
+        serviceState.withLock { s in
+            s.peerStore = peerStore
+            s.protoBook = protoBook
+        }
+    }
+
+    public func peerConnected(_ peer: PeerID) async {
+        serviceState.withLock { s in
+            guard let peerStore = s.peerStore, let protoBook = s.protoBook else { return }
+            s.identifyWaits[peer]?.cancel()
+            s.identifyWaits[peer] = Task { [weak self] in
+                await self?.addWhenIdentified(peer, peerStore: peerStore, protoBook: protoBook)
+                // Waits are cancelled under the lock when they are replaced
+                // or dropped, so an uncancelled wait still owns its entry
+                self?.serviceState.withLock { s in
+                    if !Task.isCancelled {
+                        s.identifyWaits[peer] = nil
+                    }
+                }
+            }
+        }
+    }
+
+    public func peerDisconnected(_ peer: PeerID) async {
+        serviceState.withLock { s in
+            s.identifyWaits.removeValue(forKey: peer)?.cancel()
+        }
+        replaceIfFull(peer)
+    }
+
     public func activate(using opener: any StreamOpener) async {
         serviceState.withLock { $0.opener = opener }
         await activate()
@@ -0,0 +1,324 @@
+/// ProvideQueue - Serializes and rate-limits provider announcements.
+
+import Foundation
+import Synchronization
+import P2PCore
+
+private let logger = SubsystemLogger(.dht, label: "p2p.kademlia.provide")
+
+/// Limits for the provide queue.
+public struct ProvideQueueConfiguration: Sendable {
+    /// Maximum number of announcements in flight at once.
+    public var maxConcurrent: Int
+
+    /// Maximum number of announcements started per second.
+    /// Set to nil to start them as fast as `maxConcurrent` allows.
+    public var maxPerSecond: Double?
+
+    /// Attempts per key before it is dropped from the queue.
+    public var maxAttempts: Int
+
+    /// Delay before the first retry; it doubles with each further failure.
+    public var initialBackoff: Duration
+
+    /// Upper bound on the retry delay.
+    public var maxBackoff: Duration
+
+    /// Creates a new configuration.
+    public init(
+        maxConcurrent: Int = 4,
+        maxPerSecond: Double? = 10,
+        maxAttempts: Int = 5,
+        initialBackoff: Duration = .seconds(1),
+        maxBackoff: Duration = .seconds(60)
+    ) {
+        self.maxConcurrent = maxConcurrent
+        self.maxPerSecond = maxPerSecond
+        self.maxAttempts = maxAttempts
+        self.initialBackoff = initialBackoff
+        self.maxBackoff = maxBackoff
+    }
+
+    /// Default configuration.
+    public static let `default` = ProvideQueueConfiguration()
+}
+
+/// Queue of keys waiting to be announced as provided.
+///
+/// Keys are announced in the order they were queued, at most
+/// `maxConcurrent` at a time and `maxPerSecond` starts per second. A key
+/// that is already queued or being announced is not queued again. A failed
+/// announcement goes back to the end of the queue after an exponential
+/// backoff, until `maxAttempts` is reached.
+///
+/// ## Usage
+///
+/// ```swift
+/// let queue = ProvideQueue(configuration: .init(maxConcurrent: 2, maxPerSecond: 5))
+/// queue.start { key, addresses in
+///     try await announce(key, addresses)
+/// }
+/// queue.enqueue(key: key, addresses: addresses)
+/// ```
+public final class ProvideQueue: Sendable {
+
+    /// Announces `key` with `addresses`, throwing if no peer took it.
+    public typealias Provider = @Sendable (_ key: Data, _ addresses: [Multiaddr]) async throws -> Void
+
+    /// Where a key stands in the queue.
+    public struct KeyStatus: Sendable, Equatable {
+        /// Queue position of a key.
+        public enum State: Sendable, Equatable {
+            /// Waiting for its turn or for its retry delay to pass.
+            case pending
+
+            /// Being announced.
+            case inFlight
+
+            /// Not queued: announced, or dropped after `maxAttempts`.
+            case idle
+        }
+
+        /// Queue position.
+        public let state: State
+
+        /// Failed attempts since the key was queued.
+        public let failures: Int
+
+        /// When the key was last announced.
+        public let lastProvided: Date?
+
+        /// Description of the last failure, cleared by a success.
+        public let lastError: String?
+    }
+
+    private struct Entry: Sendable {
+        var addresses: [Multiaddr]
+        var notBefore: ContinuousClock.Instant
+    }
+
+    private struct State: Sendable {
+        /// Pending keys in queue order.
+        var order: [Data] = []
+        var pending: [Data: Entry] = [:]
+        var inFlight: Set<Data> = []
+        var failures: [Data: Int] = [:]
+        var lastProvided: [Data: Date] = [:]
+        var lastError: [Data: String] = [:]
+        /// Earliest time the rate limit allows the next start.
+        var nextStart: ContinuousClock.Instant = .now
+        var worker: Task<Void, Never>?
+        var wake: AsyncStream<Void>.Continuation?
+    }
+
+    private enum Step {
+        case start(Data, [Multiaddr])
+        case wait(Duration)
+        case idle
+    }
+
+    /// Longest a waiting worker sleeps before looking at the queue again.
+    private static let pollInterval: Duration = .milliseconds(100)
+
+    /// Queue configuration.
+    public let configuration: ProvideQueueConfiguration
+
+    private let state: Mutex<State>
+
+    /// Creates a new provide queue.
+    ///
+    /// - Parameter configuration: Concurrency, rate and retry limits.
+    public init(configuration: ProvideQueueConfiguration = .default) {
+        self.configuration = configuration
+        self.state = Mutex(State())
+    }
+
+    // MARK: - Queueing
+
+    /// Queues a key for announcement.
+    ///
+    /// A key that is already pending only has its addresses replaced; a key
+    /// that is being announced is left alone.
+    ///
+    /// - Parameters:
+    ///   - key: The content key.
+    ///   - addresses: Local addresses to announce.
+    /// - Returns: Whether the key was newly queued.
+    @discardableResult
+    public func enqueue(key: Data, addresses: [Multiaddr]) -> Bool {
+        let added = state.withLock { s -> Bool in
+            if s.inFlight.contains(key) {
+                return false
+            }
+            if s.pending[key] != nil {
+                s.pending[key]?.addresses = addresses
+                return false
+            }
+            s.pending[key] = Entry(addresses: addresses, notBefore: .now)
+            s.order.append(key)
+            s.failures[key] = 0
+            return true
+        }
+        if added {
+            wake()
+        }
+        return added
+    }
+
+    /// Number of keys waiting to be announced, including those waiting out
+    /// a retry delay.
+    public var depth: Int {
+        state.withLock { $0.order.count }
+    }
+
+    /// Number of keys being announced.
+    public var inFlightCount: Int {
+        state.withLock { $0.inFlight.count }
+    }
+
+    /// When the key was last announced, or nil if it never was.
+    public func lastProvided(for key: Data) -> Date? {
+        state.withLock { $0.lastProvided[key] }
+    }
+
+    /// The key's queue status, or nil if it was never queued.
+    public func status(for key: Data) -> KeyStatus? {
+        state.withLock { s in
+            guard let failures = s.failures[key] else { return nil }
+            let position: KeyStatus.State
+            if s.inFlight.contains(key) {
+                position = .inFlight
+            } else if s.pending[key] != nil {
+                position = .pending
+            } else {
+                position = .idle
+            }
+            return KeyStatus(
+                state: position,
+                failures: failures,
+                lastProvided: s.lastProvided[key],
+                lastError: s.lastError[key]
+            )
+        }
+    }
+
+    // MARK: - Worker
+
+    /// Starts announcing queued keys with `provider`.
+    ///
+    /// Does nothing if the queue is already running.
+    public func start(provider: @escaping Provider) {
+        state.withLock { s in
+            guard s.worker == nil else { return }
+            let (wakes, continuation) = AsyncStream.makeStream(
+                of: Void.self,
+                bufferingPolicy: .bufferingNewest(1)
+            )
+            s.wake = continuation
+            s.worker = Task { [weak self] in
+                await self?.run(provider: provider, wakes: wakes)
+            }
+        }
+    }
+
+    /// Stops announcing. Announcements in flight are cancelled and their
+    /// keys go back to the front of the queue.
+    public func stop() {
+        state.withLock { s in
+            s.worker?.cancel()
+            s.worker = nil
+            s.wake?.finish()
+            s.wake = nil
+        }
+    }
+
+    private func wake() {
+        _ = state.withLock { $0.wake }?.yield()
+    }
+
+    private func run(provider: @escaping Provider, wakes: AsyncStream<Void>) async {
+        await withDiscardingTaskGroup { group in
+            var iterator = wakes.makeAsyncIterator()
+            while !Task.isCancelled {
+                switch nextStep() {
+                case .start(let key, let addresses):
+                    group.addTask {
+                        await self.attempt(key, addresses: addresses, provider: provider)
+                    }
+                case .wait(let delay):
+                    try? await Task.sleep(for: min(delay, Self.pollInterval))
+                case .idle:
+                    guard await iterator.next() != nil else { return }
+                }
+            }
+        }
+    }
+
+    /// Takes the first key that is due, if the concurrency and rate limits
+    /// allow a start.
+    private func nextStep() -> Step {
+        state.withLock { s in
+            guard !s.order.isEmpty, s.inFlight.count < configuration.maxConcurrent else {
+                return .idle
+            }
+            let now = ContinuousClock.now
+            if now < s.nextStart {
+                return .wait(s.nextStart - now)
+            }
+            guard let index = s.order.firstIndex(where: { (s.pending[$0]?.notBefore ?? now) <= now }) else {
+                let soonest = s.order.compactMap { s.pending[$0]?.notBefore }.min() ?? now
+                return .wait(soonest - now)
+            }
+            let key = s.order.remove(at: index)
+            let addresses = s.pending.removeValue(forKey: key)?.addresses ?? []
+            s.inFlight.insert(key)
+            if let rate = configuration.maxPerSecond, rate > 0 {
+                s.nextStart = now + .seconds(1 / rate)
+            }
+            return .start(key, addresses)
+        }
+    }
+
+    private func attempt(_ key: Data, addresses: [Multiaddr], provider: Provider) async {
+        let failure: (any Error)?
+        do {
+            try await provider(key, addresses)
+            failure = nil
+        } catch {
+            failure = error
+        }
+        let cancelled = Task.isCancelled
+
+        state.withLock { s in
+            s.inFlight.remove(key)
+            guard let failure else {
+                s.failures[key] = 0
+                s.lastProvided[key] = Date()
+                s.lastError[key] = nil
+                return
+            }
+            if cancelled {
+                // Stopped, not failed: resume first on the next start
+                s.pending[key] = Entry(addresses: addresses, notBefore: .now)
+                s.order.insert(key, at: 0)
+                return
+            }
+            let failures = (s.failures[key] ?? 0) + 1
+            s.failures[key] = failures
+            s.lastError[key] = "\(failure)"
+            guard failures < configuration.maxAttempts else {
+                logger.debug("Giving up providing \(key.count)-byte key after \(failures) attempts: \(failure)")
+                return
+            }
+            s.pending[key] = Entry(addresses: addresses, notBefore: .now + backoff(afterFailures: failures))
+            s.order.append(key)
+        }
+        wake()
+    }
+
+    /// Retry delay after the given number of consecutive failures.
+    private func backoff(afterFailures failures: Int) -> Duration {
+        let factor = 1 << min(failures - 1, 30)
+        return min(configuration.initialBackoff * factor, configuration.maxBackoff)
+    }
+}
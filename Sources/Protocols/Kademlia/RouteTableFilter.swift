@@ -0,0 +1,29 @@
+/// RouteTableFilter - Restricts which peers may enter the routing table.
+
+import P2PCore
+
+/// Decides whether a peer that speaks the DHT protocol may enter the routing
+/// table, given the addresses known for it at that point.
+///
+/// Mirrors go-libp2p-kad-dht's `RoutingTableFilter` option. The filter runs
+/// on every insertion path (connected peers, inbound requesters, query
+/// responders and FIND_NODE results); explicit `addPeer` calls bypass it.
+public typealias RouteTableFilter = @Sendable (_ peer: PeerID, _ addresses: [Multiaddr]) -> Bool
+
+/// Ready-made route table filters.
+public enum RouteTableFilters {
+    /// Admits peers with at least one public IP address, like go's
+    /// `PublicRoutingTableFilter`. Peers known only by loopback, link-local,
+    /// private or unspecified addresses, or by no address at all, are left out.
+    public static let publicAddresses: RouteTableFilter = { _, addresses in
+        addresses.contains(where: isPublic)
+    }
+
+    private static func isPublic(_ address: Multiaddr) -> Bool {
+        address.ipAddress != nil
+            && !address.isLoopbackIP
+            && !address.isLinkLocalIP
+            && !address.isPrivateIP
+            && !address.isUnspecifiedIP
+    }
+}
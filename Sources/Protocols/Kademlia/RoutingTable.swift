@@ -88,6 +88,20 @@ public final class RoutingTable: Sendable {
         }
     }
 
+    /// Removes a peer if its bucket is full and has a pending peer to
+    /// replace it.
+    ///
+    /// - Parameter peerID: The peer to remove.
+    /// - Returns: The removed entry and its replacement, if the peer was removed.
+    @discardableResult
+    public func replacePeer(_ peerID: PeerID) -> (removed: KBucketEntry, replacement: KBucketEntry)? {
+        guard let index = bucketIndex(for: peerID) else { return nil }
+
+        return buckets.withLock { buckets in
+            buckets[index].replace(peerID)
+        }
+    }
+
     /// Gets an entry for a peer.
     ///
     /// - Parameter peerID: The peer ID.
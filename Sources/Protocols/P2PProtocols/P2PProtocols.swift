@@ -85,6 +85,9 @@ public protocol SupportedProtocolsContext: Sendable {
 public protocol PeerStoreContext: Sendable {
     /// ピアストア（アドレス管理、観測アドレス更新等）。
     var peerStore: any PeerStore { get async }
+
+    /// プロトコルブック（ピアがサポートするプロトコル。ストリーム開設時の交渉省略に使う）。
+    var protoBook: any ProtoBook { get async }
 }
 
 /// Address dialing capability exposed to services that need direct outbound dials.
@@ -130,6 +133,11 @@ public protocol SupportedProtocolsConsumer: Sendable {
     func attachSupportedProtocolsContext(_ context: any SupportedProtocolsContext) async
 }
 
+/// Capability protocol for services that record what they learn about peers in the peer store.
+public protocol PeerStoreConsumer: Sendable {
+    func attachPeerStoreContext(_ context: any PeerStoreContext) async
+}
+
 /// Capability protocol for services that expose a start-up activation phase.
 public protocol ActivatableService: Sendable {
     func activate() async
@@ -92,6 +92,7 @@ public struct ServiceRuntimeRequirements: Sendable {
     public var consumesLocalIdentity: Bool
     public var consumesListenAddresses: Bool
     public var consumesSupportedProtocols: Bool
+    public var consumesPeerStore: Bool
     public var activatesOnStart: Bool
 
     public static let empty = ServiceRuntimeRequirements()
@@ -101,12 +102,14 @@ public struct ServiceRuntimeRequirements: Sendable {
         consumesLocalIdentity: Bool = false,
         consumesListenAddresses: Bool = false,
         consumesSupportedProtocols: Bool = false,
+        consumesPeerStore: Bool = false,
         activatesOnStart: Bool = false
     ) {
         self.receivesStreamOpening = receivesStreamOpening
         self.consumesLocalIdentity = consumesLocalIdentity
         self.consumesListenAddresses = consumesListenAddresses
         self.consumesSupportedProtocols = consumesSupportedProtocols
+        self.consumesPeerStore = consumesPeerStore
         self.activatesOnStart = activatesOnStart
     }
 }
@@ -283,6 +286,16 @@ public struct ServiceRegistration<Service: LifecycleService>: Sendable {
                     await supportedProtocolsConsumer.attachSupportedProtocolsContext(context.supportedProtocols)
                 }
             }
+            if runtimeRequirements.consumesPeerStore {
+                let peerStoreConsumer = requireServiceCapability(
+                    service,
+                    as: (any PeerStoreConsumer).self,
+                    role: "consumes peer store"
+                )
+                preStartActions.append {
+                    await peerStoreConsumer.attachPeerStoreContext(context.peerStore)
+                }
+            }
             if let streamOpeningActivatable {
                 postStartActions.append {
                     await streamOpeningActivatable.activate(using: context.streamOpener)
@@ -468,6 +481,14 @@ public extension ServiceRegistration where Service: SupportedProtocolsConsumer {
     }
 }
 
+public extension ServiceRegistration where Service: PeerStoreConsumer {
+    mutating func consumesPeerStore() {
+        var requirements = runtimeRequirements
+        requirements.consumesPeerStore = true
+        self = updating(runtimeRequirements: requirements)
+    }
+}
+
 public extension ServiceRegistration {
     mutating func activatesOnStart() {
         var requirements = runtimeRequirements
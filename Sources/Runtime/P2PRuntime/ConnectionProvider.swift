@@ -28,10 +28,16 @@ public protocol InboundSessionCandidate: Sendable {
 
 public protocol ConnectionAcceptor: Sendable {
     var localAddress: Multiaddr { get }
+    /// Every address connections are accepted on (see `Listener.localAddresses`).
+    var localAddresses: [Multiaddr] { get }
     func accept() async throws -> any InboundSessionCandidate
     func close() async throws
 }
 
+public extension ConnectionAcceptor {
+    var localAddresses: [Multiaddr] { [localAddress] }
+}
+
 public protocol ConnectionProvider: Sendable {
     var pathKind: TransportPathKind { get }
     func canDial(_ address: Multiaddr) -> Bool
@@ -196,10 +202,11 @@ public struct PipelineConnectionProvider: ConnectionProvider {
         let rawConnection = try await transport.dial(address)
 
         do {
+            // A simultaneous-connect dial may be assigned the responder role
             let result = try await upgrader.upgrade(
                 rawConnection,
                 localKeyPair: identity.keyPair,
-                role: .initiator,
+                role: SimultaneousConnect.role ?? .initiator,
                 expectedPeer: address.peerID
             )
             return result.connection
@@ -286,6 +293,7 @@ private struct UpgradedInboundSessionCandidate: InboundSessionCandidate {
 
 private final class UpgradedConnectionAcceptor: ConnectionAcceptor {
     let localAddress: Multiaddr
+    let localAddresses: [Multiaddr]
 
     private let listener: any Listener
     private let upgrader: any ConnectionUpgrader
@@ -297,6 +305,7 @@ private final class UpgradedConnectionAcceptor: ConnectionAcceptor {
         identity: LocalIdentity
     ) {
         self.localAddress = listener.localAddress
+        self.localAddresses = listener.localAddresses
         self.listener = listener
         self.upgrader = upgrader
         self.identity = identity
@@ -9,6 +9,8 @@ import P2PMux
 import P2PNegotiation
 import Synchronization
 
+private let connectionUpgraderLogger = Logger(label: "p2p.runtime.connection-upgrader")
+
 public struct UpgradeResult: Sendable {
     public let connection: MuxedConnection
     public let securityProtocol: String
@@ -103,6 +105,38 @@ private final class BufferedSecuredConnection: SecuredConnection, Sendable {
     }
 }
 
+/// A muxed connection that reports the stack it was upgraded through.
+private final class NegotiatedMuxedConnection: MuxedConnection, Sendable {
+    private let underlying: any MuxedConnection
+    let negotiatedStack: ConnectionStack?
+
+    var localPeer: PeerID { underlying.localPeer }
+    var remotePeer: PeerID { underlying.remotePeer }
+    var localAddress: Multiaddr? { underlying.localAddress }
+    var remoteAddress: Multiaddr { underlying.remoteAddress }
+    var inboundStreams: AsyncStream<MuxedStream> { underlying.inboundStreams }
+    var hasActiveStreams: Bool { underlying.hasActiveStreams }
+    var keepAliveProbe: (any KeepAliveProbe)? { underlying.keepAliveProbe }
+    var muxerConnection: any MuxedConnection { underlying.muxerConnection }
+
+    init(underlying: any MuxedConnection, stack: ConnectionStack) {
+        self.underlying = underlying
+        self.negotiatedStack = stack
+    }
+
+    func newStream() async throws -> MuxedStream {
+        try await underlying.newStream()
+    }
+
+    func acceptStream() async throws -> MuxedStream {
+        try await underlying.acceptStream()
+    }
+
+    func close() async throws {
+        try await underlying.close()
+    }
+}
+
 public protocol ConnectionUpgrader: Sendable {
     func upgrade(
         _ raw: any RawConnection,
@@ -122,20 +156,58 @@ public protocol ConnectionProtector: Sendable {
     func protect(_ raw: any RawConnection) async throws -> any RawConnection
 }
 
+/// A stage of the upgrade pipeline, reported when it times out.
+public enum UpgradePhase: String, Sendable {
+    /// Pre-security protector (e.g. pnet nonce exchange).
+    case protection
+    /// multistream-select of the security protocol.
+    case securityNegotiation
+    /// Security handshake (including early muxer negotiation).
+    case securityHandshake
+    /// multistream-select of the muxer and muxer setup.
+    case muxerNegotiation
+}
+
+/// Whether a timed phase finished or was cut off first.
+private enum PhaseOutcome: Sendable {
+    case running
+    case finished
+    case timedOut
+}
+
 public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
     private let securityUpgraders: [any SecurityUpgrader]
     private let muxers: [any Muxer]
     private let protector: (any ConnectionProtector)?
+    /// Deadline for each multistream-select exchange.
+    private let negotiationTimeout: Duration
+    /// Deadline for the protector and the security handshake.
+    private let handshakeTimeout: Duration
     private static let maxMessageSize = 64 * 1024
 
+    /// Creates an upgrader.
+    ///
+    /// When a phase exceeds its timeout the raw connection is closed and
+    /// `UpgradeError.timeout(_:)` reports the phase.
+    ///
+    /// - Parameters:
+    ///   - security: Security upgraders in preference order.
+    ///   - muxers: Muxers in preference order.
+    ///   - protector: Optional pre-security protector.
+    ///   - negotiationTimeout: Deadline for security and muxer multistream-select.
+    ///   - handshakeTimeout: Deadline for the protector and the security handshake.
     public init(
         security: [any SecurityUpgrader],
         muxers: [any Muxer],
-        protector: (any ConnectionProtector)? = nil
+        protector: (any ConnectionProtector)? = nil,
+        negotiationTimeout: Duration = .seconds(10),
+        handshakeTimeout: Duration = .seconds(30)
     ) {
         self.securityUpgraders = security
         self.muxers = muxers
         self.protector = protector
+        self.negotiationTimeout = negotiationTimeout
+        self.handshakeTimeout = handshakeTimeout
     }
 
     public func upgrade(
@@ -149,7 +221,9 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
         // fails — there is no unprotected fallback.
         let base: any RawConnection
         if let protector {
-            base = try await protector.protect(raw)
+            base = try await withPhaseTimeout(handshakeTimeout, phase: .protection, closing: raw) {
+                try await protector.protect(raw)
+            }
         } else {
             base = raw
         }
@@ -158,6 +232,7 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
             let muxerProtocolIDs = muxers.map(\.protocolID)
             let (secured, securityProtocol, earlyMuxer) = try await upgradeToSecured(
                 base,
+                closing: raw,
                 localKeyPair: localKeyPair,
                 role: role,
                 expectedPeer: expectedPeer,
@@ -165,16 +240,29 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
             )
 
             let (muxed, muxerProtocol): (MuxedConnection, String)
+            let muxerSelection: ConnectionStack.MuxerSelection
             if let earlyMuxer,
                let muxer = muxers.first(where: { $0.protocolID == earlyMuxer }) {
-                let muxedConn = try await muxer.multiplex(secured, isInitiator: role == .initiator)
+                let muxedConn = try await withPhaseTimeout(negotiationTimeout, phase: .muxerNegotiation, closing: raw) {
+                    try await muxer.multiplex(secured, isInitiator: role == .initiator)
+                }
                 (muxed, muxerProtocol) = (muxedConn, earlyMuxer)
+                muxerSelection = .securityHandshake
             } else {
-                (muxed, muxerProtocol) = try await upgradeToMuxed(secured, role: role)
+                (muxed, muxerProtocol) = try await withPhaseTimeout(negotiationTimeout, phase: .muxerNegotiation, closing: raw) {
+                    try await self.upgradeToMuxed(secured, role: role)
+                }
+                muxerSelection = .multistreamSelect
             }
 
+            let stack = ConnectionStack(
+                transport: Self.transportName(of: raw.remoteAddress),
+                securityProtocol: securityProtocol,
+                muxerProtocol: muxerProtocol,
+                muxerSelection: muxerSelection
+            )
             return UpgradeResult(
-                connection: muxed,
+                connection: NegotiatedMuxedConnection(underlying: muxed, stack: stack),
                 securityProtocol: securityProtocol,
                 muxerProtocol: muxerProtocol
             )
@@ -195,8 +283,23 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
         }
     }
 
+    /// The outermost transport protocol of `address`, skipping the peer ID
+    /// and certificate hashes (e.g. "tcp" for /ip4/…/tcp/4001/p2p/…).
+    static func transportName(of address: Multiaddr) -> String {
+        let transportProtocol = address.protocols.last { proto in
+            switch proto {
+            case .p2p, .certhash:
+                return false
+            default:
+                return true
+            }
+        }
+        return transportProtocol?.name ?? "unknown"
+    }
+
     private func upgradeToSecured(
         _ raw: any RawConnection,
+        closing socket: any RawConnection,
         localKeyPair: KeyPair,
         role: SecurityRole,
         expectedPeer: PeerID?,
@@ -208,51 +311,60 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
             throw UpgradeError.noSecurityUpgraders
         }
 
-        var buffer = ByteBuffer()
-
-        let negotiatedProtocol: String
-        if role == .initiator {
-            let result = try await MultistreamSelect.negotiateLazy(
-                protocols: protocolIDs,
-                read: { try await self.readBuffered(from: raw, buffer: &buffer) },
-                write: { try await raw.write($0) }
-            )
-            negotiatedProtocol = result.protocolID
-        } else {
-            let result = try await MultistreamSelect.handle(
-                supported: protocolIDs,
-                read: { try await self.readBuffered(from: raw, buffer: &buffer) },
-                write: { try await raw.write($0) }
-            )
-            negotiatedProtocol = result.protocolID
+        let (negotiatedProtocol, remainder) = try await withPhaseTimeout(
+            negotiationTimeout,
+            phase: .securityNegotiation,
+            closing: socket
+        ) {
+            var buffer = ByteBuffer()
+
+            let negotiatedProtocol: String
+            if role == .initiator {
+                let result = try await MultistreamSelect.negotiateLazy(
+                    protocols: protocolIDs,
+                    read: { try await self.readBuffered(from: raw, buffer: &buffer) },
+                    write: { try await raw.write($0) }
+                )
+                negotiatedProtocol = result.protocolID
+            } else {
+                let result = try await MultistreamSelect.handle(
+                    supported: protocolIDs,
+                    read: { try await self.readBuffered(from: raw, buffer: &buffer) },
+                    write: { try await raw.write($0) }
+                )
+                negotiatedProtocol = result.protocolID
+            }
+            return (negotiatedProtocol, buffer)
         }
 
         guard let upgrader = securityUpgraders.first(where: { $0.protocolID == negotiatedProtocol }) else {
             throw UpgradeError.securityNegotiationFailed(negotiatedProtocol)
         }
 
-        let bufferedRaw = BufferedRawConnection(underlying: raw, initialBuffer: buffer)
+        let bufferedRaw = BufferedRawConnection(underlying: raw, initialBuffer: remainder)
+
+        return try await withPhaseTimeout(handshakeTimeout, phase: .securityHandshake, closing: socket) {
+            if let earlyMuxerUpgrader = upgrader as? EarlyMuxerNegotiating,
+               !muxerProtocols.isEmpty {
+                let (secured, negotiatedMuxer) = try await earlyMuxerUpgrader.secureWithEarlyMuxer(
+                    bufferedRaw,
+                    localKeyPair: localKeyPair,
+                    as: role,
+                    expectedPeer: expectedPeer,
+                    muxerProtocols: muxerProtocols
+                )
+                return (secured, negotiatedProtocol, negotiatedMuxer)
+            }
 
-        if let earlyMuxerUpgrader = upgrader as? EarlyMuxerNegotiating,
-           !muxerProtocols.isEmpty {
-            let (secured, negotiatedMuxer) = try await earlyMuxerUpgrader.secureWithEarlyMuxer(
+            let secured = try await upgrader.secure(
                 bufferedRaw,
                 localKeyPair: localKeyPair,
                 as: role,
-                expectedPeer: expectedPeer,
-                muxerProtocols: muxerProtocols
+                expectedPeer: expectedPeer
             )
-            return (secured, negotiatedProtocol, negotiatedMuxer)
-        }
 
-        let secured = try await upgrader.secure(
-            bufferedRaw,
-            localKeyPair: localKeyPair,
-            as: role,
-            expectedPeer: expectedPeer
-        )
-
-        return (secured, negotiatedProtocol, nil)
+            return (secured, negotiatedProtocol, nil)
+        }
     }
 
     private func upgradeToMuxed(
@@ -293,6 +405,68 @@ public final class NegotiatingUpgrader: ConnectionUpgrader, Sendable {
         return (muxed, negotiatedProtocol)
     }
 
+    /// Runs `operation`, closing `socket` and throwing `UpgradeError.timeout`
+    /// if it does not finish within `timeout`.
+    ///
+    /// Closing the socket unblocks reads that do not observe cancellation,
+    /// so the operation always winds down before this returns.
+    private func withPhaseTimeout<T: Sendable>(
+        _ timeout: Duration,
+        phase: UpgradePhase,
+        closing socket: any RawConnection,
+        operation: @escaping @Sendable () async throws -> T
+    ) async throws -> T {
+        let outcome = Mutex(PhaseOutcome.running)
+
+        let operationTask = Task {
+            try await operation()
+        }
+
+        let timeoutTask = Task {
+            do {
+                try await Task.sleep(for: timeout)
+            } catch {
+                return
+            }
+            let fired = outcome.withLock { state -> Bool in
+                guard state == .running else { return false }
+                state = .timedOut
+                return true
+            }
+            guard fired else { return }
+            operationTask.cancel()
+            do {
+                try await socket.close()
+            } catch {
+                connectionUpgraderLogger.debug("Failed to close connection after \(phase.rawValue) timeout: \(error)")
+            }
+        }
+
+        let result: Result<T, any Error>
+        do {
+            let value = try await withTaskCancellationHandler {
+                try await operationTask.value
+            } onCancel: {
+                operationTask.cancel()
+            }
+            result = .success(value)
+        } catch {
+            result = .failure(error)
+        }
+        timeoutTask.cancel()
+
+        let timedOut = outcome.withLock { state -> Bool in
+            if state == .running {
+                state = .finished
+            }
+            return state == .timedOut
+        }
+        if timedOut {
+            throw UpgradeError.timeout(phase)
+        }
+        return try result.get()
+    }
+
     private func readBuffered(
         from raw: any RawConnection,
         buffer: inout ByteBuffer
@@ -336,4 +510,6 @@ public enum UpgradeError: Error, Sendable {
     case connectionClosed
     case messageTooLarge(size: Int, max: Int)
     case invalidVarint
+    /// The given phase did not complete in time; the connection was closed.
+    case timeout(UpgradePhase)
 }
@@ -51,6 +51,9 @@ public enum DisconnectReason: Sendable, Equatable {
     /// Health check failed (ping failures exceeded threshold).
     case healthCheckFailed
 
+    /// The peer stopped answering keepalive probes on the connection.
+    case keepAliveTimeout
+
     /// Connection was closed due to connection limit exceeded.
     case connectionLimitExceeded
 
@@ -73,6 +76,7 @@ public enum DisconnectReason: Sendable, Equatable {
              (.timeout, .timeout),
              (.idleTimeout, .idleTimeout),
              (.healthCheckFailed, .healthCheckFailed),
+             (.keepAliveTimeout, .keepAliveTimeout),
              (.connectionLimitExceeded, .connectionLimitExceeded):
             return true
         case (.gated(let lStage), .gated(let rStage)):
@@ -101,6 +105,8 @@ extension DisconnectReason: CustomStringConvertible {
             return "idle timeout"
         case .healthCheckFailed:
             return "health check failed"
+        case .keepAliveTimeout:
+            return "keepalive timeout"
         case .connectionLimitExceeded:
             return "connection limit exceeded"
         case .gated(let stage):
@@ -0,0 +1,40 @@
+/// HandlerStreamLimit - Per-protocol cap on concurrent inbound stream handlers
+///
+/// Bounds how many inbound streams for one protocol run their handler at the
+/// same time, across all peers — the handler-side counterpart of go-libp2p's
+/// per-protocol resource scope. A stream that arrives while the handler is at
+/// its limit either waits for a running one to finish or is reset.
+
+/// Concurrency limit for one protocol's inbound stream handler.
+public struct HandlerStreamLimit: Sendable, Equatable {
+
+    /// What happens to a stream that arrives while the handler is at its limit.
+    public enum Overflow: Sendable, Equatable {
+        /// Reset the stream.
+        case reject
+
+        /// Wait for a running handler to finish. At most `maxQueued` streams
+        /// wait; past that, the stream is reset.
+        case queue(maxQueued: Int)
+    }
+
+    /// Maximum number of streams whose handler runs at the same time.
+    public var maxConcurrentStreams: Int
+
+    /// Policy for streams past `maxConcurrentStreams`.
+    public var overflow: Overflow
+
+    /// Creates a handler stream limit.
+    ///
+    /// - Parameters:
+    ///   - maxConcurrentStreams: Concurrent handler invocations allowed.
+    ///   - overflow: Policy past the limit. Default: `.reject`.
+    public init(maxConcurrentStreams: Int, overflow: Overflow = .reject) {
+        precondition(maxConcurrentStreams > 0, "maxConcurrentStreams must be positive")
+        if case .queue(let maxQueued) = overflow {
+            precondition(maxQueued >= 0, "maxQueued must not be negative")
+        }
+        self.maxConcurrentStreams = maxConcurrentStreams
+        self.overflow = overflow
+    }
+}
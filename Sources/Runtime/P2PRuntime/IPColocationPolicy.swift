@@ -0,0 +1,130 @@
+/// IPColocationPolicy - Inbound connection limits per remote IP and subnet
+///
+/// Many inbound connections from one host, or from one network, are the
+/// shape of a sybil attack or a single-host flood. The policy caps inbound
+/// connections per remote IP and per subnet (/24 for IPv4 and /48 for IPv6
+/// by default), checked when a connection is accepted, before the security
+/// handshake. Handshakes in progress count toward the limits.
+///
+/// Addresses without an IP component (memory transports) and relayed
+/// connections are not limited: a relayed address carries the relay's IP,
+/// not the remote peer's.
+
+import P2PCore
+
+/// The remote IP and subnet an inbound connection counts against.
+public struct IPColocationKey: Sendable, Hashable {
+    /// The remote IP address (e.g. "203.0.113.7", "2001:db8::1").
+    public let ip: String
+
+    /// The remote subnet in CIDR form (e.g. "203.0.113.0/24", "2001:db8::/48").
+    public let subnet: String
+
+    public init(ip: String, subnet: String) {
+        self.ip = ip
+        self.subnet = subnet
+    }
+}
+
+/// Configuration for per-IP and per-subnet inbound connection limits.
+public struct IPColocationPolicy: Sendable, Equatable {
+
+    /// Maximum inbound connections from one IP, or `nil` for no limit.
+    public var maxPerIP: Int?
+
+    /// Maximum inbound connections from one subnet, or `nil` for no limit.
+    public var maxPerSubnet: Int?
+
+    /// Prefix length of an IPv4 subnet.
+    public var ipv4PrefixLength: Int
+
+    /// Prefix length of an IPv6 subnet.
+    public var ipv6PrefixLength: Int
+
+    /// Creates an IP colocation policy.
+    ///
+    /// - Parameters:
+    ///   - maxPerIP: Inbound connections allowed per IP. Default: 8.
+    ///   - maxPerSubnet: Inbound connections allowed per subnet. Default: 32.
+    ///   - ipv4PrefixLength: IPv4 subnet size. Default: 24.
+    ///   - ipv6PrefixLength: IPv6 subnet size. Default: 48.
+    public init(
+        maxPerIP: Int? = 8,
+        maxPerSubnet: Int? = 32,
+        ipv4PrefixLength: Int = 24,
+        ipv6PrefixLength: Int = 48
+    ) {
+        precondition(maxPerIP.map { $0 > 0 } ?? true, "maxPerIP must be positive")
+        precondition(maxPerSubnet.map { $0 > 0 } ?? true, "maxPerSubnet must be positive")
+        precondition((0...32).contains(ipv4PrefixLength), "ipv4PrefixLength must be in 0...32")
+        precondition((0...128).contains(ipv6PrefixLength), "ipv6PrefixLength must be in 0...128")
+        self.maxPerIP = maxPerIP
+        self.maxPerSubnet = maxPerSubnet
+        self.ipv4PrefixLength = ipv4PrefixLength
+        self.ipv6PrefixLength = ipv6PrefixLength
+    }
+
+    /// The key `address` counts against, or `nil` when the address has no
+    /// IP component or is relayed.
+    public func key(for address: Multiaddr) -> IPColocationKey? {
+        var ipBytes: [UInt8]?
+        for proto in address.protocols {
+            switch proto {
+            case .p2pCircuit:
+                return nil
+            case .ip4, .ip6:
+                if ipBytes == nil {
+                    ipBytes = Array(proto.valueBytes)
+                }
+            default:
+                continue
+            }
+        }
+        guard let ipBytes else { return nil }
+
+        let prefixLength = ipBytes.count == 4 ? ipv4PrefixLength : ipv6PrefixLength
+        var masked = ipBytes
+        for index in masked.indices {
+            let bitsKept = min(max(prefixLength - index * 8, 0), 8)
+            masked[index] &= bitsKept == 0 ? 0 : UInt8(truncatingIfNeeded: 0xFF << (8 - bitsKept))
+        }
+        return IPColocationKey(
+            ip: Self.format(ipBytes),
+            subnet: "\(Self.format(masked))/\(prefixLength)"
+        )
+    }
+
+    /// Formats 4 bytes as dotted decimal, 16 as compressed IPv6.
+    private static func format(_ bytes: [UInt8]) -> String {
+        if bytes.count == 4 {
+            return bytes.map(String.init).joined(separator: ".")
+        }
+
+        let groups = stride(from: 0, to: 16, by: 2).map { UInt16(bytes[$0]) << 8 | UInt16(bytes[$0 + 1]) }
+
+        // Compress the longest run of two or more zero groups to "::"
+        var bestStart = -1
+        var bestLength = 0
+        var runStart = -1
+        for (index, group) in groups.enumerated() {
+            if group == 0 {
+                if runStart < 0 { runStart = index }
+                let length = index - runStart + 1
+                if length > bestLength {
+                    bestStart = runStart
+                    bestLength = length
+                }
+            } else {
+                runStart = -1
+            }
+        }
+
+        let hex = groups.map { String($0, radix: 16) }
+        guard bestLength >= 2 else {
+            return hex.joined(separator: ":")
+        }
+        let head = hex[..<bestStart].joined(separator: ":")
+        let tail = hex[(bestStart + bestLength)...].joined(separator: ":")
+        return "\(head)::\(tail)"
+    }
+}
@@ -0,0 +1,77 @@
+/// KeepAlivePolicy - Per-connection dead-peer detection
+///
+/// Each connection is probed with one mechanism, picked when it connects:
+/// its native probe (QUIC PING frames, or Yamux pings on TCP, WebSocket and
+/// memory connections) or a `/ipfs/ping/1.0.0` round trip when it has none.
+/// The chosen connection's own keepalive is switched off, so a Yamux session
+/// is never closed by both its keep-alive loop and the policy.
+///
+/// TCP's `SO_KEEPALIVE` (`TCPTransportConfiguration.keepAliveInterval`) runs
+/// in the kernel independently and cannot report to the node; it only bounds
+/// how long a half-open socket lingers.
+
+import P2PMux
+
+/// Configuration for per-connection keepalive probing.
+public struct KeepAlivePolicy: Sendable, Equatable {
+
+    /// Which mechanisms the policy may use.
+    public enum Mode: String, Sendable, Equatable {
+        /// The connection's native probe, or libp2p ping when it has none.
+        case automatic
+
+        /// The connection's native probe only; connections without one are
+        /// not probed.
+        case nativeOnly
+
+        /// libp2p ping on every connection. The remote peer must serve
+        /// `/ipfs/ping/1.0.0`.
+        case libp2pPing
+    }
+
+    /// How long a peer may stay unresponsive before its connection is
+    /// closed, measured from the first unanswered probe.
+    public var window: Duration
+
+    /// The number of consecutive failed probes that close the connection.
+    /// Probes are sent every `window / probeCount`, each with that timeout.
+    public var probeCount: Int
+
+    /// Which mechanisms may be used.
+    public var mode: Mode
+
+    /// Creates a keepalive policy.
+    ///
+    /// - Parameters:
+    ///   - window: Time to detect an unresponsive peer. Default: 30 seconds.
+    ///   - probeCount: Consecutive failures that close the connection. Default: 3.
+    ///   - mode: Mechanisms to use. Default: `.automatic`.
+    public init(
+        window: Duration = .seconds(30),
+        probeCount: Int = 3,
+        mode: Mode = .automatic
+    ) {
+        precondition(window > .zero, "window must be positive")
+        precondition(probeCount >= 1, "probeCount must be at least 1")
+        self.window = window
+        self.probeCount = probeCount
+        self.mode = mode
+    }
+
+    /// The interval between probes and each probe's timeout.
+    public var probeInterval: Duration {
+        window / probeCount
+    }
+
+    /// The mechanism used for `connection`, or `nil` if it is not probed.
+    public func mechanism(for connection: any MuxedConnection) -> KeepAliveMechanism? {
+        switch mode {
+        case .automatic:
+            return connection.keepAliveProbe?.mechanism ?? .libp2pPing
+        case .nativeOnly:
+            return connection.keepAliveProbe?.mechanism
+        case .libp2pPing:
+            return .libp2pPing
+        }
+    }
+}
@@ -0,0 +1,78 @@
+/// LivenessScoringPolicy - Ping-based connection scoring for trimming
+///
+/// Idle connections are pinged with `/ipfs/ping/1.0.0` on a fixed interval.
+/// Each result is turned into a sample between 0 and 1 — 1 for a round trip
+/// at or under `latencyTarget`, falling off as `latencyTarget / rtt` above
+/// it, and 0 for a failed or timed-out ping — and folded into the
+/// connection's liveness score as an exponentially weighted moving average.
+///
+/// When the pool must trim, connections with a lower effective score (tag
+/// count plus liveness score) go first, so a slow or failing peer is dropped
+/// before a responsive one with the same tags.
+
+/// Configuration for ping-based liveness scoring.
+public struct LivenessScoringPolicy: Sendable, Equatable {
+
+    /// How often idle connections are pinged.
+    public var probeInterval: Duration
+
+    /// How long a connection must go without activity before it is pinged.
+    public var idleThreshold: Duration
+
+    /// Round-trip times at or under this value score a full sample of 1.
+    public var latencyTarget: Duration
+
+    /// Weight of the previous score when folding in a new sample, in `0..<1`.
+    /// Lower values react faster to a peer becoming slow or unresponsive.
+    public var decay: Double
+
+    /// How long a single ping may take before it counts as a failure.
+    public var timeout: Duration
+
+    /// Creates a liveness scoring policy.
+    ///
+    /// - Parameters:
+    ///   - probeInterval: Time between ping rounds. Default: 30 seconds.
+    ///   - idleThreshold: Minimum idle time before pinging. Default: 10 seconds.
+    ///   - latencyTarget: RTT that still scores 1. Default: 250 ms.
+    ///   - decay: Weight of the previous score. Default: 0.5.
+    ///   - timeout: Ping timeout. Default: 10 seconds.
+    public init(
+        probeInterval: Duration = .seconds(30),
+        idleThreshold: Duration = .seconds(10),
+        latencyTarget: Duration = .milliseconds(250),
+        decay: Double = 0.5,
+        timeout: Duration = .seconds(10)
+    ) {
+        precondition(probeInterval > .zero, "probeInterval must be positive")
+        precondition(idleThreshold >= .zero, "idleThreshold must not be negative")
+        precondition(latencyTarget > .zero, "latencyTarget must be positive")
+        precondition(decay >= 0 && decay < 1, "decay must be in 0..<1")
+        precondition(timeout > .zero, "timeout must be positive")
+        self.probeInterval = probeInterval
+        self.idleThreshold = idleThreshold
+        self.latencyTarget = latencyTarget
+        self.decay = decay
+        self.timeout = timeout
+    }
+
+    /// The score of a connection that has not been pinged yet.
+    public static let initialScore: Double = 1.0
+
+    /// The sample for one ping, or 0 when `rtt` is `nil` (the ping failed).
+    public func sample(rtt: Duration?) -> Double {
+        guard let rtt else { return 0 }
+        guard rtt > latencyTarget else { return 1 }
+        return min(1, Self.seconds(latencyTarget) / Self.seconds(rtt))
+    }
+
+    /// Folds the result of one ping into `score`.
+    public func updated(score: Double, rtt: Duration?) -> Double {
+        decay * score + (1 - decay) * sample(rtt: rtt)
+    }
+
+    private static func seconds(_ duration: Duration) -> Double {
+        let components = duration.components
+        return Double(components.seconds) + Double(components.attoseconds) / 1e18
+    }
+}
@@ -14,16 +14,54 @@ public struct PoolConfiguration: Sendable {
     /// Optional connection gater.
     public var gater: (any ConnectionGater)?
 
+    /// Whether a peer may hold several open connections at once.
+    ///
+    /// When `false` (the default, matching go-libp2p), dialing a peer that
+    /// already has a direct connection reuses it, and duplicate connections
+    /// (e.g. from concurrent dials in both directions) are collapsed to one.
+    /// When `true`, every dial opens a new connection, up to
+    /// `limits.maxConnectionsPerPeer`. Intended for multi-connection tests.
+    public var allowMultipleConnectionsPerPeer: Bool
+
+    /// Per-connection keepalive probing, or `nil` to rely on each muxer's
+    /// own keepalive.
+    ///
+    /// Connections whose peer stops answering within the policy's window are
+    /// closed with `DisconnectReason.keepAliveTimeout`.
+    public var keepAlive: KeepAlivePolicy?
+
+    /// Ping-based liveness scoring of idle connections, or `nil` to trim on
+    /// tags and activity alone.
+    ///
+    /// Slow or unresponsive peers score lower and are trimmed first when the
+    /// high watermark is hit. Scored peers must serve `/ipfs/ping/1.0.0`.
+    public var livenessScoring: LivenessScoringPolicy?
+
+    /// Per-IP and per-subnet inbound connection limits, or `nil` to accept
+    /// any number of connections from one network.
+    ///
+    /// Connections past a limit are rejected at accept with
+    /// `ResourceError.limitExceeded`.
+    public var ipColocation: IPColocationPolicy?
+
     public init(
         limits: ConnectionLimits = .default,
         reconnectionPolicy: ReconnectionPolicy = .default,
         idleTimeout: Duration = .seconds(60),
-        gater: (any ConnectionGater)? = nil
+        gater: (any ConnectionGater)? = nil,
+        allowMultipleConnectionsPerPeer: Bool = false,
+        keepAlive: KeepAlivePolicy? = nil,
+        livenessScoring: LivenessScoringPolicy? = nil,
+        ipColocation: IPColocationPolicy? = nil
     ) {
         self.limits = limits
         self.reconnectionPolicy = reconnectionPolicy
         self.idleTimeout = idleTimeout
         self.gater = gater
+        self.allowMultipleConnectionsPerPeer = allowMultipleConnectionsPerPeer
+        self.keepAlive = keepAlive
+        self.livenessScoring = livenessScoring
+        self.ipColocation = ipColocation
     }
 
     /// Development-oriented defaults with looser limits and no auto-reconnect.
@@ -45,6 +83,10 @@ extension PoolConfiguration: Equatable {
     public static func == (lhs: PoolConfiguration, rhs: PoolConfiguration) -> Bool {
         lhs.limits == rhs.limits &&
         lhs.reconnectionPolicy == rhs.reconnectionPolicy &&
-        lhs.idleTimeout == rhs.idleTimeout
+        lhs.idleTimeout == rhs.idleTimeout &&
+        lhs.allowMultipleConnectionsPerPeer == rhs.allowMultipleConnectionsPerPeer &&
+        lhs.keepAlive == rhs.keepAlive &&
+        lhs.livenessScoring == rhs.livenessScoring &&
+        lhs.ipColocation == rhs.ipColocation
     }
 }
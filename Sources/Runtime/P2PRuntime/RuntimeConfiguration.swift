@@ -6,18 +6,28 @@ public struct RuntimeConfiguration: Sendable {
     public let connectionProviders: [any ConnectionProvider]
     public let pool: PoolConfiguration
     public let maxNegotiatingInboundStreams: Int
+    /// Limit applied to protocol handlers registered without their own.
+    /// `nil` leaves such handlers unlimited (resource scopes still apply).
+    public let handlerStreamLimit: HandlerStreamLimit?
+    /// Resolves DNS multiaddrs (`/dns*`, `/dnsaddr`) that no connection
+    /// provider dials directly. `nil` passes them to the providers unchanged.
+    public let dnsResolver: (any DNSResolver)?
 
     public init(
         keyPair: KeyPair = .generateEd25519(),
         listenAddresses: [Multiaddr] = [],
         connectionProviders: [any ConnectionProvider] = [],
         pool: PoolConfiguration = .init(),
-        maxNegotiatingInboundStreams: Int = 128
+        maxNegotiatingInboundStreams: Int = 128,
+        handlerStreamLimit: HandlerStreamLimit? = nil,
+        dnsResolver: (any DNSResolver)? = SystemDNSResolver()
     ) {
         self.keyPair = keyPair
         self.listenAddresses = listenAddresses
         self.connectionProviders = connectionProviders
         self.pool = pool
         self.maxNegotiatingInboundStreams = maxNegotiatingInboundStreams
+        self.handlerStreamLimit = handlerStreamLimit
+        self.dnsResolver = dnsResolver
     }
 }
@@ -40,6 +40,10 @@ public final class NoiseConnection: SecuredConnection, Sendable {
     public let localPeer: PeerID
     public let remotePeer: PeerID
 
+    /// Final Noise handshake hash (channel binding). Both peers compute the
+    /// same value; comparing it pinpoints prologue and message-order bugs.
+    public let handshakeHash: Data
+
     private let underlying: any RawConnection
 
     /// Send state - only accessed by write()
@@ -56,6 +60,7 @@ public final class NoiseConnection: SecuredConnection, Sendable {
     ///   - remotePeer: Remote peer ID
     ///   - sendCipher: Cipher state for sending
     ///   - recvCipher: Cipher state for receiving
+    ///   - handshakeHash: Final handshake hash
     ///   - initialBuffer: Any data already read during handshake
     init(
         underlying: any RawConnection,
@@ -63,11 +68,13 @@ public final class NoiseConnection: SecuredConnection, Sendable {
         remotePeer: PeerID,
         sendCipher: NoiseCipherState,
         recvCipher: NoiseCipherState,
+        handshakeHash: Data = Data(),
         initialBuffer: ByteBuffer = ByteBuffer()
     ) {
         self.underlying = underlying
         self.localPeer = localPeer
         self.remotePeer = remotePeer
+        self.handshakeHash = handshakeHash
         self.sendState = Mutex(SendState(cipher: sendCipher))
         self.recvState = Mutex(RecvState(cipher: recvCipher, buffer: initialBuffer))
     }
@@ -22,6 +22,9 @@ public enum NoiseError: Error, Sendable {
     case invalidSignature
 
     /// The remote peer ID doesn't match the expected peer.
+    ///
+    /// No longer thrown by `NoiseUpgrader`, which reports
+    /// ``identityMismatch(phase:expected:actual:)`` instead.
     case peerMismatch(expected: PeerID, actual: PeerID)
 
     /// A handshake message was received out of order.
@@ -38,6 +41,51 @@ public enum NoiseError: Error, Sendable {
 
     /// Nonce overflow - connection must be rekeyed or closed.
     case nonceOverflow
+
+    // MARK: Handshake failures
+
+    /// The identity signature over the remote's Noise static key does not
+    /// verify, or was made by a different key than the one in the payload.
+    case badSignature(phase: NoiseHandshakePhase)
+
+    /// A handshake message failed to decrypt (wrong key or corrupted tag).
+    case decryptFailed(phase: NoiseHandshakePhase)
+
+    /// A handshake message is malformed, truncated, carries an invalid key
+    /// or arrived out of order.
+    case unexpectedMessage(phase: NoiseHandshakePhase, reason: String)
+
+    /// The remote authenticated as a different peer than the one dialed.
+    case identityMismatch(phase: NoiseHandshakePhase, expected: PeerID, actual: PeerID)
+
+    /// A handshake message carries data where XX allows none, such as a
+    /// payload in the unencrypted Message A.
+    case earlyDataInvalid(phase: NoiseHandshakePhase, reason: String)
+
+    /// The handshake message a handshake failure belongs to, or `nil` for
+    /// the other cases.
+    public var handshakePhase: NoiseHandshakePhase? {
+        switch self {
+        case .badSignature(let phase),
+             .decryptFailed(let phase),
+             .unexpectedMessage(let phase, _),
+             .identityMismatch(let phase, _, _),
+             .earlyDataInvalid(let phase, _):
+            return phase
+        default:
+            return nil
+        }
+    }
+}
+
+/// A message of the XX handshake.
+public enum NoiseHandshakePhase: String, Sendable, Equatable {
+    /// `-> e`
+    case messageA = "message-a"
+    /// `<- e, ee, s, es`
+    case messageB = "message-b"
+    /// `-> s, se`
+    case messageC = "message-c"
 }
 
 // MARK: - Constants
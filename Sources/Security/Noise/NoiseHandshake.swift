@@ -98,7 +98,7 @@ struct NoiseHandshake: Sendable {
         do {
             return Data(try core.writeMessageA())
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageA)
         }
     }
 
@@ -110,7 +110,7 @@ struct NoiseHandshake: Sendable {
             let fields = try core.readMessageB([UInt8](message))
             return NoisePayload(fields: fields)
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageB)
         }
     }
 
@@ -124,7 +124,7 @@ struct NoiseHandshake: Sendable {
         do {
             return Data(try core.writeMessageC(payload: payloadBytes))
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageC)
         }
     }
 
@@ -134,10 +134,18 @@ struct NoiseHandshake: Sendable {
     mutating func readMessageA<Message: RandomAccessCollection & DataProtocol>(
         _ message: Message
     ) throws where Message.Element == UInt8, Message.SubSequence: DataProtocol {
+        // Message A is sent before any key exists, so a payload would travel
+        // unencrypted; libp2p peers send none
+        if message.count > noisePublicKeySize {
+            throw NoiseError.earlyDataInvalid(
+                phase: .messageA,
+                reason: "\(message.count - noisePublicKeySize) payload bytes after the ephemeral key"
+            )
+        }
         do {
             try core.readMessageA([UInt8](message))
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageA)
         }
     }
 
@@ -151,7 +159,7 @@ struct NoiseHandshake: Sendable {
         do {
             return Data(try core.writeMessageB(payload: payloadBytes))
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageB)
         }
     }
 
@@ -163,7 +171,7 @@ struct NoiseHandshake: Sendable {
             let fields = try core.readMessageC([UInt8](message))
             return NoisePayload(fields: fields)
         } catch {
-            throw mapHandshakeCoreError(error)
+            throw mapHandshakeCoreError(error, phase: .messageC)
         }
     }
 
@@ -173,6 +181,10 @@ struct NoiseHandshake: Sendable {
 
     // MARK: - Finalization
 
+    /// The handshake hash `h` (32 bytes). After Message C it is the channel
+    /// binding value both peers must agree on.
+    var handshakeHash: Data { Data(core.handshakeHash) }
+
     /// Splits the handshake state into transport cipher states `(send, recv)`.
     mutating func split() -> (send: NoiseCipherState, recv: NoiseCipherState) {
         let (send, recv) = core.split()
@@ -192,16 +204,16 @@ struct NoiseHandshake: Sendable {
 }
 
 /// Maps the core handshake error onto the adapter's public ``NoiseError``,
-/// preserving the out-of-order / too-short / decryption semantics callers expect.
-private func mapHandshakeCoreError(_ error: NoiseCryptoError) -> NoiseError {
+/// naming the handshake message it occurred in.
+private func mapHandshakeCoreError(_ error: NoiseCryptoError, phase: NoiseHandshakePhase) -> NoiseError {
     switch error {
-    case .decryptionFailed:   return .decryptionFailed
-    case .invalidPayload:     return .invalidPayload
-    case .invalidSignature:   return .invalidSignature
-    case .invalidKey:         return .invalidKey
-    case .messageOutOfOrder:  return .messageOutOfOrder
-    case .messageTooShort:    return .handshakeFailed("Noise message too short")
+    case .decryptionFailed:   return .decryptFailed(phase: phase)
+    case .invalidPayload:     return .unexpectedMessage(phase: phase, reason: "malformed handshake payload")
+    case .invalidSignature:   return .badSignature(phase: phase)
+    case .invalidKey:         return .unexpectedMessage(phase: phase, reason: "invalid X25519 public key")
+    case .messageOutOfOrder:  return .unexpectedMessage(phase: phase, reason: "message out of order")
+    case .messageTooShort:    return .unexpectedMessage(phase: phase, reason: "message too short")
     case .nonceOverflow:      return .nonceOverflow
-    case .cryptoFailure:      return .decryptionFailed
+    case .cryptoFailure:      return .decryptFailed(phase: phase)
     }
 }
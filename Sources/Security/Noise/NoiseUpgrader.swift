@@ -11,9 +11,22 @@ import Crypto
 /// ChaCha20-Poly1305 encryption.
 public final class NoiseUpgrader: SecurityUpgrader, Sendable {
 
+    /// Logger for handshake diagnostics.
+    private static let logger = SubsystemLogger(.noise, label: "p2p.security.noise")
+
     public var protocolID: String { "/noise" }
 
-    public init() {}
+    /// Receives the framed handshake messages, when set.
+    private let wireTracer: WireTracer?
+
+    /// Creates a Noise upgrader.
+    ///
+    /// - Parameter wireTracer: Called with the bytes of each handshake
+    ///   message sent or received, length prefix included. `nil` (the
+    ///   default) disables tracing.
+    public init(wireTracer: WireTracer? = nil) {
+        self.wireTracer = wireTracer
+    }
 
     /// Upgrades a raw connection to a secured connection using Noise protocol.
     ///
@@ -47,23 +60,45 @@ public final class NoiseUpgrader: SecurityUpgrader, Sendable {
 
         var readBuffer = initialBuffer
         let remotePeer: PeerID
-
-        if isInitiator {
-            remotePeer = try await performInitiatorHandshake(
-                handshake: &handshake,
-                connection: connection,
-                expectedPeer: expectedPeer,
-                readBuffer: &readBuffer
-            )
-        } else {
-            remotePeer = try await performResponderHandshake(
-                handshake: &handshake,
-                connection: connection,
-                expectedPeer: expectedPeer,
-                readBuffer: &readBuffer
-            )
+        let roleName = isInitiator ? "initiator" : "responder"
+        Self.logger.trace("Noise handshake started", peer: expectedPeer, metadata: ["role": "\(roleName)"])
+
+        do {
+            if isInitiator {
+                remotePeer = try await performInitiatorHandshake(
+                    handshake: &handshake,
+                    connection: connection,
+                    expectedPeer: expectedPeer,
+                    readBuffer: &readBuffer
+                )
+            } else {
+                remotePeer = try await performResponderHandshake(
+                    handshake: &handshake,
+                    connection: connection,
+                    expectedPeer: expectedPeer,
+                    readBuffer: &readBuffer
+                )
+            }
+        } catch {
+            var metadata: Logger.Metadata = ["role": "\(roleName)"]
+            if let phase = (error as? NoiseError)?.handshakePhase {
+                metadata["phase"] = "\(phase.rawValue)"
+            }
+            Self.logger.debug("Noise handshake failed: \(error)", peer: expectedPeer, metadata: metadata)
+            throw error
         }
 
+        // Logged so interop harnesses can compare it with the remote's value
+        let handshakeHash = handshake.handshakeHash
+        Self.logger.debug(
+            "Noise handshake complete",
+            peer: remotePeer,
+            metadata: [
+                "role": "\(roleName)",
+                "handshakeHash": "\(handshakeHash.map { String(format: "%02x", $0) }.joined())"
+            ]
+        )
+
         // Split cipher states for transport
         let (sendCipher, recvCipher) = handshake.split()
 
@@ -73,6 +108,7 @@ public final class NoiseUpgrader: SecurityUpgrader, Sendable {
             remotePeer: remotePeer,
             sendCipher: sendCipher,
             recvCipher: recvCipher,
+            handshakeHash: handshakeHash,
             initialBuffer: readBuffer
         )
     }
@@ -89,29 +125,25 @@ public final class NoiseUpgrader: SecurityUpgrader, Sendable {
         let messageA = try handshake.writeMessageA()
         var framedA = ByteBuffer()
         try encodeNoiseMessage(messageA, into: &framedA)
+        wireTracer?.trace(.outbound, .noiseMessageA, Array(framedA.readableBytesView))
         try await connection.write(framedA)
+        Self.logger.trace("Noise sent message A (-> e)", metadata: ["bytes": "\(messageA.count)"])
 
         // Read Message B: <- e, ee, s, es
         let messageB = try await readNoiseFrame(from: connection, buffer: &readBuffer)
+        wireTracer?.trace(.inbound, .noiseMessageB, framed(messageB))
         let payloadB = try handshake.readMessageB(messageB)
+        Self.logger.trace("Noise read message B (<- e, ee, s, es)", metadata: ["bytes": "\(messageB.readableBytes)"])
 
-        // Verify remote identity
-        guard let remoteStaticKey = handshake.remoteStaticKey else {
-            throw NoiseError.handshakeFailed("No remote static key after Message B")
-        }
-        let remoteStaticData = Data(remoteStaticKey.rawRepresentation)
-        let remotePeer = try payloadB.verify(noiseStaticPublicKey: remoteStaticData)
-
-        // Check expected peer if specified
-        if let expected = expectedPeer, expected != remotePeer {
-            throw SecurityError.peerMismatch(expected: expected, actual: remotePeer)
-        }
+        let remotePeer = try authenticate(payloadB, of: handshake, expectedPeer: expectedPeer, phase: .messageB)
 
         // Send Message C: -> s, se
         let messageC = try handshake.writeMessageC()
         var framedC = ByteBuffer()
         try encodeNoiseMessage(messageC, into: &framedC)
+        wireTracer?.trace(.outbound, .noiseMessageC, Array(framedC.readableBytesView))
         try await connection.write(framedC)
+        Self.logger.trace("Noise sent message C (-> s, se)", peer: remotePeer, metadata: ["bytes": "\(messageC.count)"])
 
         return remotePeer
     }
@@ -126,34 +158,63 @@ public final class NoiseUpgrader: SecurityUpgrader, Sendable {
     ) async throws -> PeerID {
         // Read Message A: -> e
         let messageA = try await readNoiseFrame(from: connection, buffer: &readBuffer)
+        wireTracer?.trace(.inbound, .noiseMessageA, framed(messageA))
         try handshake.readMessageA(messageA)
+        Self.logger.trace("Noise read message A (-> e)", metadata: ["bytes": "\(messageA.readableBytes)"])
 
         // Send Message B: <- e, ee, s, es
         let messageB = try handshake.writeMessageB()
         var framedB = ByteBuffer()
         try encodeNoiseMessage(messageB, into: &framedB)
+        wireTracer?.trace(.outbound, .noiseMessageB, Array(framedB.readableBytesView))
         try await connection.write(framedB)
+        Self.logger.trace("Noise sent message B (<- e, ee, s, es)", metadata: ["bytes": "\(messageB.count)"])
 
         // Read Message C: -> s, se
         let messageC = try await readNoiseFrame(from: connection, buffer: &readBuffer)
+        wireTracer?.trace(.inbound, .noiseMessageC, framed(messageC))
         let payloadC = try handshake.readMessageC(messageC)
+        Self.logger.trace("Noise read message C (-> s, se)", metadata: ["bytes": "\(messageC.readableBytes)"])
+
+        return try authenticate(payloadC, of: handshake, expectedPeer: expectedPeer, phase: .messageC)
+    }
+
+    // MARK: - Helpers
 
-        // Verify remote identity
+    /// Verifies the remote's identity signature over its Noise static key and
+    /// checks the resulting peer against `expectedPeer`.
+    private func authenticate(
+        _ payload: NoisePayload,
+        of handshake: NoiseHandshake,
+        expectedPeer: PeerID?,
+        phase: NoiseHandshakePhase
+    ) throws -> PeerID {
         guard let remoteStaticKey = handshake.remoteStaticKey else {
-            throw NoiseError.handshakeFailed("No remote static key after Message C")
+            throw NoiseError.unexpectedMessage(phase: phase, reason: "no remote static key")
+        }
+        let remotePeer: PeerID
+        do {
+            remotePeer = try payload.verify(noiseStaticPublicKey: Data(remoteStaticKey.rawRepresentation))
+        } catch NoiseError.invalidSignature {
+            throw NoiseError.badSignature(phase: phase)
+        } catch {
+            throw NoiseError.unexpectedMessage(phase: phase, reason: "undecodable identity key: \(error)")
         }
-        let remoteStaticData = Data(remoteStaticKey.rawRepresentation)
-        let remotePeer = try payloadC.verify(noiseStaticPublicKey: remoteStaticData)
 
-        // Check expected peer if specified
         if let expected = expectedPeer, expected != remotePeer {
-            throw SecurityError.peerMismatch(expected: expected, actual: remotePeer)
+            throw NoiseError.identityMismatch(phase: phase, expected: expected, actual: remotePeer)
         }
-
         return remotePeer
     }
 
-    // MARK: - Helpers
+    /// A received message as it was on the wire, with its length prefix.
+    private func framed(_ message: ByteBuffer) -> [UInt8] {
+        // readNoiseFrame only returns messages whose length fit the prefix
+        var buffer = ByteBuffer()
+        buffer.writeInteger(UInt16(message.readableBytes))
+        buffer.writeImmutableBuffer(message)
+        return Array(buffer.readableBytesView)
+    }
 
     /// Reads a complete Noise frame from the connection with buffering.
     private func readNoiseFrame(
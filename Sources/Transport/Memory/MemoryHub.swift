@@ -43,6 +43,9 @@ public final class MemoryHub: Sendable {
     /// Registered listeners by their memory address identifier.
     private let listeners: Mutex<[String: WeakListener]>
 
+    /// The next candidate for `registerAllocating(_:)`.
+    private let nextAllocatedID = Atomic<UInt64>(1)
+
     /// Wrapper to hold weak reference to listener.
     private struct WeakListener: Sendable {
         weak var listener: MemoryListener?
@@ -78,6 +81,26 @@ public final class MemoryHub: Sendable {
         }
     }
 
+    /// Registers a listener at an unused numeric address (`/memory/<n>`,
+    /// `n >= 1`), the way listening on port 0 picks a free port.
+    ///
+    /// - Parameter makeListener: Creates the listener for the chosen address
+    /// - Returns: The registered listener
+    internal func registerAllocating(_ makeListener: (Multiaddr) -> MemoryListener) -> MemoryListener {
+        listeners.withLock { listeners in
+            listeners = listeners.filter { $0.value.listener != nil }
+
+            var id: String
+            repeat {
+                id = String(nextAllocatedID.wrappingAdd(1, ordering: .relaxed).oldValue)
+            } while id == MemoryTransport.allocatingID || listeners[id]?.listener != nil
+
+            let listener = makeListener(.memory(id: id))
+            listeners[id] = WeakListener(listener: listener)
+            return listener
+        }
+    }
+
     /// Unregisters the listener at the given address.
     ///
     /// - Parameter address: The address to unregister
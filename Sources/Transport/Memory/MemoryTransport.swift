@@ -35,6 +35,14 @@ import P2PTransport
 /// let data = try await serverConn.read()
 /// ```
 ///
+/// Listening on `/memory/0` picks an unused numeric address, like port 0 on
+/// TCP; the listener's `localAddress` reports it:
+///
+/// ```swift
+/// let listener = try await transport1.listen(.memory(id: "0"))
+/// let connection = try await transport2.dial(listener.localAddress)  // e.g. /memory/1
+/// ```
+///
 /// ## Isolated Testing
 ///
 /// For test isolation, create a separate hub:
@@ -82,15 +90,22 @@ public final class MemoryTransport: Transport, Sendable {
 
     /// Listens on the given address.
     ///
-    /// - Parameter address: The memory address to listen on (e.g., `/memory/server`)
+    /// - Parameter address: The memory address to listen on (e.g., `/memory/server`),
+    ///   or `/memory/0` for an unused numeric address
     /// - Returns: A listener for incoming connections
     /// - Throws: `TransportError.addressInUse` if the address is already in use
     public func listen(_ address: Multiaddr) async throws -> any Listener {
+        if address.memoryID == Self.allocatingID {
+            return hub.registerAllocating { MemoryListener(address: $0, hub: hub) }
+        }
         let listener = MemoryListener(address: address, hub: hub)
         try hub.register(listener: listener, at: address)
         return listener
     }
 
+    /// The memory ID that asks `listen(_:)` to pick a free address.
+    static let allocatingID = "0"
+
     /// Checks if this transport can dial the given address.
     ///
     /// - Parameter address: The address to check
@@ -53,6 +53,12 @@ public protocol Listener: Sendable {
     /// The local address this listener is bound to.
     var localAddress: Multiaddr { get }
 
+    /// Every address this listener accepts connections on.
+    ///
+    /// A dual-stack `/ip6/::` listener also reports the IPv4 wildcard it
+    /// serves. Defaults to `[localAddress]`.
+    var localAddresses: [Multiaddr] { get }
+
     /// Accepts the next incoming connection.
     func accept() async throws -> any RawConnection
 
@@ -60,6 +66,10 @@ public protocol Listener: Sendable {
     func close() async throws
 }
 
+public extension Listener {
+    var localAddresses: [Multiaddr] { [localAddress] }
+}
+
 /// Errors that can occur during transport operations.
 public enum TransportError: Error, Sendable {
     case unsupportedAddress(Multiaddr)
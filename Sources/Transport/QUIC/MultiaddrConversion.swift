@@ -69,9 +69,15 @@ extension QUIC.SocketAddress {
     ///
     /// - Returns: The Multiaddr representation.
     public func toQUICMultiaddr() -> Multiaddr {
-        let ipProtocol: MultiaddrProtocol = ipAddress.contains(":")
-            ? .ip6(ipAddress)
-            : .ip4(ipAddress)
+        let ipProtocol: MultiaddrProtocol
+        if !ipAddress.contains(":") {
+            ipProtocol = .ip4(ipAddress)
+        } else if let ipv4 = LibP2PCore.IPAddress.unmappedIPv4(ipAddress) {
+            // IPv4 peers of a dual-stack socket arrive as ::ffff:a.b.c.d
+            ipProtocol = .ip4(ipv4)
+        } else {
+            ipProtocol = .ip6(ipAddress)
+        }
         return Multiaddr(uncheckedProtocols: [ipProtocol, .udp(port), .quicV1])
     }
 }
@@ -0,0 +1,112 @@
+/// QUICConnection - QUIC-specific connection features
+///
+/// Unreliable datagrams (RFC 9221) and stream priorities (RFC 9218 urgency /
+/// incremental) have no equivalent on TCP, WebSocket or WebRTC connections,
+/// so they stay off `MuxedConnection`. Applications that need them type-assert
+/// the connection they got from the node:
+///
+/// ```swift
+/// if let quic = connection as? any QUICConnection {
+///     try await quic.sendDatagram(ByteBuffer(bytes: [0x01]))
+/// }
+/// ```
+///
+/// The QUIC engine provides the wire support through `QUICDatagramCapable`
+/// and `QUICPrioritizableStream`; engines without it report
+/// `QUICTransportError.datagramsNotSupported` /
+/// `.streamPriorityNotSupported`. Engines adopting `QUICPingCapable` give the
+/// connection a PING-frame `keepAliveProbe`.
+
+import Foundation
+import NIOCore
+import P2PMux
+
+/// A QUIC connection's transport-specific features.
+public protocol QUICConnection: MuxedConnection {
+
+    /// The largest datagram payload the peer accepts, or `nil` when datagrams
+    /// were not negotiated (no `max_datagram_frame_size` transport parameter).
+    var maxDatagramPayloadSize: Int? { get }
+
+    /// Sends `data` as one unreliable QUIC datagram.
+    ///
+    /// Datagrams are not retransmitted, ordered, or flow controlled; they may
+    /// be dropped by the sender under congestion.
+    ///
+    /// - Throws: `QUICTransportError.datagramsNotSupported` if datagrams were
+    ///   not negotiated, `.datagramTooLarge` if `data` exceeds
+    ///   `maxDatagramPayloadSize`.
+    func sendDatagram(_ data: ByteBuffer) async throws
+
+    /// Datagrams received from the peer, finished when the connection closes.
+    ///
+    /// Single-consumer. Finishes immediately when datagrams were not
+    /// negotiated.
+    var incomingDatagrams: AsyncStream<ByteBuffer> { get }
+
+    /// Sets the send priority of an open stream on this connection.
+    ///
+    /// `stream` may be any wrapper the node hands out; it is matched by ID.
+    ///
+    /// - Throws: `QUICTransportError.unknownStream` if no open stream on this
+    ///   connection has the ID, `.streamPriorityNotSupported` if the engine
+    ///   does not schedule by priority.
+    func setPriority(_ priority: QUICStreamPriority, for stream: MuxedStream) async throws
+}
+
+/// A stream's send priority (RFC 9218 urgency and incremental).
+public struct QUICStreamPriority: Sendable, Hashable, CustomStringConvertible {
+
+    /// Urgency from 0 (most urgent) to 7. Default: 3.
+    public let urgency: UInt8
+
+    /// Whether the stream's data may be interleaved with other streams of the
+    /// same urgency rather than sent to completion first. Default: false.
+    public let incremental: Bool
+
+    /// The priority every stream starts with.
+    public static let `default` = QUICStreamPriority()
+
+    /// Creates a priority. `urgency` above 7 is clamped to 7.
+    public init(urgency: UInt8 = 3, incremental: Bool = false) {
+        self.urgency = min(urgency, 7)
+        self.incremental = incremental
+    }
+
+    public var description: String {
+        "u=\(urgency)\(incremental ? ", i" : "")"
+    }
+}
+
+// MARK: - Engine seams
+
+/// Adopted by QUIC engine connections that support RFC 9221 datagrams.
+public protocol QUICDatagramCapable: Sendable {
+
+    /// The peer's `max_datagram_frame_size` minus frame overhead, or `nil`
+    /// when the peer did not send the transport parameter.
+    var maxDatagramPayloadSize: Int? { get }
+
+    /// Sends one datagram frame.
+    func sendDatagram(_ data: Data) async throws
+
+    /// Datagram payloads received from the peer.
+    var incomingDatagrams: AsyncStream<Data> { get }
+}
+
+/// Adopted by QUIC engine streams whose sender schedules by priority.
+public protocol QUICPrioritizableStream: Sendable {
+
+    /// Applies `priority` to data not yet sent on the stream.
+    func setPriority(_ priority: QUICStreamPriority) async throws
+}
+
+/// Adopted by QUIC engine connections that can send a PING frame on demand.
+public protocol QUICPingCapable: Sendable {
+
+    /// Sends a PING frame and waits until the packet carrying it is
+    /// acknowledged.
+    ///
+    /// - Returns: The round-trip time.
+    func ping() async throws -> Duration
+}
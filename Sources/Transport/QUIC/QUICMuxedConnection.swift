@@ -2,6 +2,7 @@
 
 import Foundation
 import Synchronization
+import NIOCore
 import P2PCore
 import P2PMux
 import QUIC
@@ -116,7 +117,13 @@ private final class StreamChannel: Sendable {
 /// - Use `let stream = try await connection.acceptStream()` repeatedly
 ///
 /// Mixing these patterns will cause streams to be split between consumers.
-public final class QUICMuxedConnection: MuxedConnection, Sendable {
+///
+/// ## QUIC Features
+///
+/// Datagrams and stream priorities are reached through `QUICConnection` and
+/// are delegated to the engine connection and streams when they adopt
+/// `QUICDatagramCapable` / `QUICPrioritizableStream`.
+public final class QUICMuxedConnection: QUICConnection, Sendable {
 
     private let quicConnection: any QUICConnectionProtocol
     private let _localPeer: PeerID
@@ -131,6 +138,8 @@ public final class QUICMuxedConnection: MuxedConnection, Sendable {
     private struct ConnectionState: Sendable {
         var isClosed: Bool = false
         var openStreamCount: Int = 0
+        /// Open engine streams by ID, for `setPriority(_:for:)`.
+        var openStreams: [UInt64: any QUICStreamProtocol] = [:]
         var forwardingTask: Task<Void, Never>?
         /// Background task collecting TLS session tickets for 0-RTT. Owned here
         /// so it is cancelled on close rather than leaking.
@@ -220,10 +229,10 @@ public final class QUICMuxedConnection: MuxedConnection, Sendable {
                 let isClosed = self.state.withLock { $0.isClosed }
                 if isClosed { break }
 
-                let muxedStream = QUICMuxedStream(stream: quicStream) { [weak self] _ in
-                    self?.decrementOpenStreamCount()
+                let muxedStream = QUICMuxedStream(stream: quicStream) { [weak self] id in
+                    self?.decrementOpenStreamCount(id)
                 }
-                self.state.withLock { $0.openStreamCount += 1 }
+                self.registerOpenStream(quicStream)
                 self.streamChannel.send(muxedStream)
             }
 
@@ -241,9 +250,17 @@ public final class QUICMuxedConnection: MuxedConnection, Sendable {
     /// - Throws: Error if stream creation fails.
     public func newStream() async throws -> MuxedStream {
         let quicStream = try await quicConnection.openStream()
-        state.withLock { $0.openStreamCount += 1 }
-        return QUICMuxedStream(stream: quicStream) { [weak self] _ in
-            self?.decrementOpenStreamCount()
+        registerOpenStream(quicStream)
+        return QUICMuxedStream(stream: quicStream) { [weak self] id in
+            self?.decrementOpenStreamCount(id)
+        }
+    }
+
+    /// Counts a newly opened or accepted stream as open.
+    private func registerOpenStream(_ stream: any QUICStreamProtocol) {
+        state.withLock { s in
+            s.openStreamCount += 1
+            s.openStreams[stream.id] = stream
         }
     }
 
@@ -251,11 +268,12 @@ public final class QUICMuxedConnection: MuxedConnection, Sendable {
     /// connection (no active streams) becomes reclaimable. Clamped at zero as a
     /// defence against a double-fire (the stream's `terminateIfNeeded` already
     /// guarantees a single call, but the count must never go negative).
-    private func decrementOpenStreamCount() {
+    private func decrementOpenStreamCount(_ id: UInt64) {
         state.withLock { s in
             if s.openStreamCount > 0 {
                 s.openStreamCount -= 1
             }
+            s.openStreams[id] = nil
         }
     }
 
@@ -302,6 +320,81 @@ public final class QUICMuxedConnection: MuxedConnection, Sendable {
     }
 }
 
+// MARK: - QUICConnection
+
+extension QUICMuxedConnection {
+
+    public var maxDatagramPayloadSize: Int? {
+        (quicConnection as? any QUICDatagramCapable)?.maxDatagramPayloadSize
+    }
+
+    public func sendDatagram(_ data: ByteBuffer) async throws {
+        guard state.withLock({ !$0.isClosed }) else {
+            throw QUICTransportError.connectionClosed
+        }
+        guard let datagrams = quicConnection as? any QUICDatagramCapable,
+              let maximum = datagrams.maxDatagramPayloadSize else {
+            throw QUICTransportError.datagramsNotSupported
+        }
+        guard data.readableBytes <= maximum else {
+            throw QUICTransportError.datagramTooLarge(size: data.readableBytes, maximum: maximum)
+        }
+        try await datagrams.sendDatagram(Data(buffer: data))
+    }
+
+    public var incomingDatagrams: AsyncStream<ByteBuffer> {
+        guard let datagrams = quicConnection as? any QUICDatagramCapable else {
+            return AsyncStream { $0.finish() }
+        }
+        let source = datagrams.incomingDatagrams
+        return AsyncStream { continuation in
+            let task = Task {
+                for await datagram in source {
+                    continuation.yield(ByteBuffer(bytes: datagram))
+                }
+                continuation.finish()
+            }
+            continuation.onTermination = { _ in task.cancel() }
+        }
+    }
+
+    public func setPriority(_ priority: QUICStreamPriority, for stream: MuxedStream) async throws {
+        guard let quicStream = state.withLock({ $0.openStreams[stream.id] }) else {
+            throw QUICTransportError.unknownStream(stream.id)
+        }
+        guard let prioritizable = quicStream as? any QUICPrioritizableStream else {
+            throw QUICTransportError.streamPriorityNotSupported
+        }
+        try await prioritizable.setPriority(priority)
+    }
+}
+
+// MARK: - KeepAliveProbe
+
+extension QUICMuxedConnection {
+
+    /// A PING-frame probe when the engine supports `QUICPingCapable`.
+    public var keepAliveProbe: (any KeepAliveProbe)? {
+        guard let pingable = quicConnection as? any QUICPingCapable else { return nil }
+        return QUICPingProbe(connection: pingable)
+    }
+}
+
+/// Probes a QUIC connection with PING frames.
+private struct QUICPingProbe: KeepAliveProbe {
+    let connection: any QUICPingCapable
+
+    var mechanism: KeepAliveMechanism { .quicPing }
+
+    func probe() async throws -> Duration {
+        try await connection.ping()
+    }
+
+    /// QUIC has no keepalive that could close the connection on its own
+    /// schedule beyond the idle timeout, which a probe resets.
+    func disableBuiltInKeepAlive() {}
+}
+
 // MARK: - CustomStringConvertible
 
 extension QUICMuxedConnection: CustomStringConvertible {
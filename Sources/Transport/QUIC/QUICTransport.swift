@@ -100,7 +100,15 @@ public final class QUICTransport: SecuredTransport, Sendable {
             configuration: configuration
         )
 
-        return QUICListener(endpoint: endpoint, localAddress: address)
+        // Report the bound port rather than 0 when one was assigned
+        let actualAddress: Multiaddr
+        if let localAddr = await endpoint.localAddress {
+            actualAddress = localAddr.toQUICMultiaddr()
+        } else {
+            actualAddress = address
+        }
+
+        return QUICListener(endpoint: endpoint, localAddress: actualAddress)
     }
 
     /// Whether this transport can dial the given address.
@@ -428,4 +436,16 @@ public enum QUICTransportError: Error, Sendable {
 
     /// TLS handshake timed out.
     case handshakeTimeout
+
+    /// Datagrams were not negotiated on the connection.
+    case datagramsNotSupported
+
+    /// The datagram exceeds the peer's maximum payload size.
+    case datagramTooLarge(size: Int, maximum: Int)
+
+    /// The QUIC engine does not schedule streams by priority.
+    case streamPriorityNotSupported
+
+    /// No open stream on the connection has this ID.
+    case unknownStream(UInt64)
 }
@@ -418,6 +418,10 @@ extension SocketAddress {
             return Multiaddr(uncheckedProtocols: [.ip4(ip), .tcp(port)])
         case .v6(let addr):
             let ip = addr.host
+            // IPv4 peers of a dual-stack listener arrive as ::ffff:a.b.c.d
+            if let ipv4 = IPAddress.unmappedIPv4(ip) {
+                return Multiaddr(uncheckedProtocols: [.ip4(ipv4), .tcp(port)])
+            }
             // Safe: exactly 2 components
             return Multiaddr(uncheckedProtocols: [.ip6(ip), .tcp(port)])
         case .unixDomainSocket:
@@ -13,8 +13,13 @@ private let tcpListenerLogger = Logger(label: "swift-libp2p.TCPListener")
 /// A TCP listener wrapping a NIO ServerChannel.
 public final class TCPListener: Listener, Sendable {
 
-    private let serverChannel: Channel
+    private let serverChannels: [Channel]
     private let _localAddress: Multiaddr
+    private let _localAddresses: [Multiaddr]
+
+    /// The socket addresses connections are accepted on (both wildcards for
+    /// a dual-stack listener).
+    let boundAddresses: [SocketAddress]
 
     private let state: Mutex<ListenerState>
 
@@ -27,9 +32,18 @@ public final class TCPListener: Listener, Sendable {
 
     public var localAddress: Multiaddr { _localAddress }
 
-    private init(serverChannel: Channel, localAddress: Multiaddr) {
-        self.serverChannel = serverChannel
-        self._localAddress = localAddress
+    public var localAddresses: [Multiaddr] { _localAddresses }
+
+    /// Whether `close()` has been called.
+    var isClosed: Bool {
+        state.withLock { $0.isClosed }
+    }
+
+    private init(serverChannels: [Channel], boundAddresses: [SocketAddress], localAddresses: [Multiaddr]) {
+        self.serverChannels = serverChannels
+        self.boundAddresses = boundAddresses
+        self._localAddress = localAddresses[0]
+        self._localAddresses = localAddresses
         self.state = Mutex(ListenerState())
     }
 
@@ -40,39 +54,76 @@ public final class TCPListener: Listener, Sendable {
     /// 2. Start the server (may accept connections immediately)
     /// 3. Create the listener
     /// 4. Set the listener callback on all handlers (delivers any queued connections)
+    ///
+    /// With `reusePort`, the server socket sets `SO_REUSEPORT` (where available)
+    /// so outbound dials can bind to the same port. With `dualStack` (an IPv6
+    /// wildcard host), the listener also accepts IPv4 connections. Accepted
+    /// sockets get the socket options from `configuration`.
     static func bind(
         host: String,
         port: UInt16,
-        group: EventLoopGroup
+        group: EventLoopGroup,
+        reusePort: Bool = false,
+        configuration: TCPTransportConfiguration = .init(),
+        dualStack: Bool = false
     ) async throws -> TCPListener {
         tcpListenerLogger.debug("bind(): Binding to \(host):\(port)")
 
         // Collect handlers for late binding
         let handlerCollector = HandlerCollector()
 
-        let bootstrap = ServerBootstrap(group: group)
-            .serverChannelOption(.socketOption(.so_reuseaddr), value: 1)
-            .childChannelInitializer { [handlerCollector] channel in
-                // Create handler without callback - will be set after listener is ready
-                let handler = TCPReadHandler(onAccepted: nil)
-                handlerCollector.add(handler)
-                return channel.pipeline.addHandler(handler)
+        // All server sockets share the collector, so their connections land in one accept queue
+        func makeBootstrap(v6Only: Bool?) -> ServerBootstrap {
+            var bootstrap = ServerBootstrap(group: group)
+                .serverChannelOption(.socketOption(.so_reuseaddr), value: 1)
+                .childChannelInitializer { [handlerCollector] channel in
+                    // Create handler without callback - will be set after listener is ready
+                    let handler = TCPReadHandler(onAccepted: nil)
+                    handlerCollector.add(handler)
+                    return channel.pipeline.addHandler(handler)
+                }
+                .childChannelOption(.autoRead, value: true)
+
+            for (option, value) in configuration.socketOptions {
+                bootstrap = bootstrap.childChannelOption(option, value: value)
+            }
+
+            if reusePort, let reusePortOption = TCPTransport.reusePortOption {
+                bootstrap = bootstrap.serverChannelOption(reusePortOption, value: 1)
             }
-            .childChannelOption(.socketOption(.so_reuseaddr), value: 1)
-            .childChannelOption(.socketOption(.tcp_nodelay), value: 1)
-            .childChannelOption(.socketOption(.so_keepalive), value: 1)
-            .childChannelOption(.autoRead, value: true)
+            if let v6Only {
+                bootstrap = bootstrap.serverChannelOption(Self.ipv6OnlyOption, value: v6Only ? 1 : 0)
+            }
+            return bootstrap
+        }
 
-        let serverChannel = try await bootstrap.bind(host: host, port: Int(port)).get()
+        let serverChannels: [Channel]
+        if dualStack {
+            serverChannels = try await bindDualStack(host: host, port: port, makeBootstrap: makeBootstrap)
+        } else {
+            serverChannels = [try await makeBootstrap(v6Only: nil).bind(host: host, port: Int(port)).get()]
+        }
 
-        guard let socketAddress = serverChannel.localAddress,
-              let localAddr = socketAddress.toMultiaddr() else {
+        var boundAddresses = serverChannels.compactMap(\.localAddress)
+        guard let primary = boundAddresses.first, primary.toMultiaddr() != nil else {
+            for channel in serverChannels {
+                channel.close(promise: nil)
+            }
             throw TransportError.unsupportedAddress(Multiaddr.tcp(host: host, port: port))
         }
+        if dualStack, serverChannels.count == 1, let boundPort = primary.port {
+            // One socket serves both families
+            boundAddresses.append(try SocketAddress(ipAddress: "0.0.0.0", port: boundPort))
+        }
+        let localAddrs = boundAddresses.compactMap { $0.toMultiaddr() }
 
-        tcpListenerLogger.debug("bind(): Bound successfully to \(localAddr)")
+        tcpListenerLogger.debug("bind(): Bound successfully to \(localAddrs)")
 
-        let listener = TCPListener(serverChannel: serverChannel, localAddress: localAddr)
+        let listener = TCPListener(
+            serverChannels: serverChannels,
+            boundAddresses: boundAddresses,
+            localAddresses: localAddrs
+        )
 
         // Late binding: set the listener callback on all handlers
         // Any connections that arrived before this point are queued and will be delivered now
@@ -85,6 +136,39 @@ public final class TCPListener: Listener, Sendable {
         return listener
     }
 
+    /// `IPV6_V6ONLY`; cleared to let an IPv6 wildcard socket accept
+    /// v4-mapped connections.
+    private static let ipv6OnlyOption = ChannelOptions.Types.SocketOption(
+        level: .ipv6,
+        name: .ipv6_v6only
+    )
+
+    /// Binds the IPv6 wildcard so that it also accepts IPv4.
+    ///
+    /// Uses a single socket with `IPV6_V6ONLY` cleared where the OS allows it,
+    /// otherwise an IPv6-only socket plus an IPv4 wildcard socket on the same port.
+    private static func bindDualStack(
+        host: String,
+        port: UInt16,
+        makeBootstrap: (_ v6Only: Bool?) -> ServerBootstrap
+    ) async throws -> [Channel] {
+        do {
+            return [try await makeBootstrap(false).bind(host: host, port: Int(port)).get()]
+        } catch {
+            tcpListenerLogger.debug("bind(): Dual-stack socket unavailable, binding IPv4 separately: \(error)")
+        }
+
+        let v6Channel = try await makeBootstrap(true).bind(host: host, port: Int(port)).get()
+        do {
+            let boundPort = v6Channel.localAddress?.port ?? Int(port)
+            let v4Channel = try await makeBootstrap(nil).bind(host: "0.0.0.0", port: boundPort).get()
+            return [v6Channel, v4Channel]
+        } catch {
+            v6Channel.close(promise: nil)
+            throw error
+        }
+    }
+
     public func accept() async throws -> any RawConnection {
         let localAddr = self._localAddress
         tcpListenerLogger.debug("accept(): Waiting for connection on \(localAddr)")
@@ -153,7 +237,9 @@ public final class TCPListener: Listener, Sendable {
             }
         }
 
-        try await serverChannel.close()
+        for channel in serverChannels {
+            try await channel.close()
+        }
     }
 
     // Called by TCPAcceptHandler when a new connection is accepted
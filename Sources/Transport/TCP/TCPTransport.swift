@@ -4,16 +4,26 @@ import P2PCore
 import P2PTransport
 import NIOCore
 import NIOPosix
+import Synchronization
 import Logging
 
 /// Debug logger for TCP transport
 private let tcpTransportLogger = Logger(label: "swift-libp2p.TCPTransport")
 
 /// TCP transport using SwiftNIO.
+///
+/// With port reuse enabled (the default), listeners set `SO_REUSEPORT` and
+/// outbound dials are bound to the port of an active listener, so the remote
+/// observes a dialable address and NAT mappings match the listen port.
 public final class TCPTransport: Transport, Sendable {
 
     private let group: EventLoopGroup
     private let ownsGroup: Bool
+    private let portReuse: Bool
+    private let configuration: TCPTransportConfiguration
+
+    /// Listeners whose ports outbound dials may reuse.
+    private let listeners = Mutex<[TCPListener]>([])
 
     /// The protocols this transport supports.
     public var protocols: [[String]] {
@@ -23,15 +33,32 @@ public final class TCPTransport: Transport, Sendable {
     public var pathKind: TransportPathKind { .ip }
 
     /// Creates a TCPTransport with a new EventLoopGroup.
-    public init() {
+    ///
+    /// - Parameters:
+    ///   - portReuse: Whether to dial from the listen port when possible
+    ///   - configuration: Socket options for dialed and accepted connections
+    public init(portReuse: Bool = true, configuration: TCPTransportConfiguration = .init()) {
         self.group = MultiThreadedEventLoopGroup(numberOfThreads: System.coreCount)
         self.ownsGroup = true
+        self.portReuse = portReuse
+        self.configuration = configuration
     }
 
     /// Creates a TCPTransport with an existing EventLoopGroup.
-    public init(group: EventLoopGroup) {
+    ///
+    /// - Parameters:
+    ///   - group: The event loop group to run on (not shut down by the transport)
+    ///   - portReuse: Whether to dial from the listen port when possible
+    ///   - configuration: Socket options for dialed and accepted connections
+    public init(
+        group: EventLoopGroup,
+        portReuse: Bool = true,
+        configuration: TCPTransportConfiguration = .init()
+    ) {
         self.group = group
         self.ownsGroup = false
+        self.portReuse = portReuse
+        self.configuration = configuration
     }
 
     deinit {
@@ -49,22 +76,17 @@ public final class TCPTransport: Transport, Sendable {
             throw TransportError.unsupportedAddress(address)
         }
 
-        let bootstrap = ClientBootstrap(group: group)
-            .channelOption(.socketOption(.so_reuseaddr), value: 1)
-            .channelOption(.socketOption(.tcp_nodelay), value: 1)
-            .channelOption(.socketOption(.so_keepalive), value: 1)
-            .channelInitializer { channel in
-                channel.eventLoop.makeSucceededVoidFuture()
+        if let bindAddress = reuseBindAddress(forRemoteHost: host) {
+            do {
+                return try await connect(host: host, port: port, remoteAddress: address, bindingTo: bindAddress)
+            } catch where Self.shouldRetryWithoutReuse(error) {
+                // The 4-tuple may already be in use (a second connection to the
+                // same address) or the platform refused the reuse bind
+                tcpTransportLogger.debug("dial(): Port reuse from \(bindAddress) failed, using an ephemeral port: \(error)")
             }
+        }
 
-        let channel = try await bootstrap.connect(host: host, port: Int(port)).get()
-        let localAddr = channel.localAddress?.toMultiaddr()
-
-        return try await TCPConnection.create(
-            channel: channel,
-            localAddress: localAddr,
-            remoteAddress: address
-        )
+        return try await connect(host: host, port: port, remoteAddress: address, bindingTo: nil)
     }
 
     public func listen(_ address: Multiaddr) async throws -> any Listener {
@@ -75,9 +97,19 @@ public final class TCPTransport: Transport, Sendable {
         let listener = try await TCPListener.bind(
             host: host,
             port: port,
-            group: group
+            group: group,
+            reusePort: portReuse,
+            configuration: configuration,
+            dualStack: address.isUnspecifiedIP && host.contains(":")
         )
 
+        if portReuse {
+            listeners.withLock { listeners in
+                listeners.removeAll { $0.isClosed }
+                listeners.append(listener)
+            }
+        }
+
         return listener
     }
 
@@ -110,35 +142,101 @@ public final class TCPTransport: Transport, Sendable {
             throw TransportError.unsupportedAddress(address)
         }
 
-        let reusePortOption = ChannelOptions.Types.SocketOption(
+        let localAddress = try SocketAddress(ipAddress: "0.0.0.0", port: Int(localPort))
+        return try await connect(host: host, port: port, remoteAddress: address, bindingTo: localAddress)
+    }
+
+    // MARK: - Port Reuse
+
+    /// The `SO_REUSEPORT` socket option, or `nil` where the platform lacks it.
+    static var reusePortOption: ChannelOptions.Types.SocketOption? {
+        #if os(Windows)
+        return nil
+        #else
+        return ChannelOptions.Types.SocketOption(
             level: NIOBSDSocket.OptionLevel.socket,
             name: NIOBSDSocket.Option(rawValue: SO_REUSEPORT)
         )
+        #endif
+    }
+
+    /// The local address to bind an outbound dial to, or `nil` for an ephemeral port.
+    ///
+    /// Uses the first open listener of the remote host's address family. A
+    /// loopback listener is only used for loopback destinations, since packets
+    /// from it cannot leave the host.
+    private func reuseBindAddress(forRemoteHost host: String) -> SocketAddress? {
+        guard portReuse, Self.reusePortOption != nil else { return nil }
+
+        let family: NIOBSDSocket.ProtocolFamily = host.contains(":") ? .inet6 : .inet
+        let remoteIsLoopback = isLoopback(host)
+
+        return listeners.withLock { listeners in
+            listeners.removeAll { $0.isClosed }
+            return listeners.lazy
+                .flatMap(\.boundAddresses)
+                .first { address in
+                    guard address.protocol == family else { return false }
+                    return remoteIsLoopback || !isLoopback(address.ipAddress ?? "")
+                }
+        }
+    }
 
-        let bootstrap = ClientBootstrap(group: group)
-            .channelOption(.socketOption(.so_reuseaddr), value: 1)
-            .channelOption(reusePortOption, value: 1)
-            .channelOption(.socketOption(.tcp_nodelay), value: 1)
-            .channelOption(.socketOption(.so_keepalive), value: 1)
+    /// Whether a failed port-reuse dial should be retried from an ephemeral port.
+    ///
+    /// As in go-libp2p, a remote that refuses or does not answer fails the
+    /// dial; any other error (address in use, option unsupported) is treated
+    /// as a local reuse problem.
+    private static func shouldRetryWithoutReuse(_ error: any Error) -> Bool {
+        switch error {
+        case is CancellationError, ChannelError.connectTimeout:
+            return false
+        case let error as NIOConnectionError:
+            return error.connectionErrors.allSatisfy { shouldRetryWithoutReuse($0.error) }
+        case let error as IOError:
+            return error.errnoCode != ECONNREFUSED && error.errnoCode != ETIMEDOUT
+        default:
+            return true
+        }
+    }
+
+    // MARK: - Private helpers
+
+    private func connect(
+        host: String,
+        port: UInt16,
+        remoteAddress: Multiaddr,
+        bindingTo localAddress: SocketAddress?
+    ) async throws -> TCPConnection {
+        var bootstrap = ClientBootstrap(group: group)
+            .connectTimeout(configuration.connectTimeAmount)
             .channelInitializer { channel in
                 channel.eventLoop.makeSucceededVoidFuture()
             }
-            .bind(to: try SocketAddress(ipAddress: "0.0.0.0", port: Int(localPort)))
+        for (option, value) in configuration.socketOptions {
+            bootstrap = bootstrap.channelOption(option, value: value)
+        }
 
-        let channel = try await bootstrap
-            .connect(host: host, port: Int(port))
-            .get()
+        if let localAddress {
+            if let reusePortOption = Self.reusePortOption {
+                bootstrap = bootstrap.channelOption(reusePortOption, value: 1)
+            }
+            bootstrap = bootstrap.bind(to: localAddress)
+        }
 
+        let channel = try await bootstrap.connect(host: host, port: Int(port)).get()
         let localAddr = channel.localAddress?.toMultiaddr()
 
         return try await TCPConnection.create(
             channel: channel,
             localAddress: localAddr,
-            remoteAddress: address
+            remoteAddress: remoteAddress
         )
     }
 
-    // MARK: - Private helpers
+    private func isLoopback(_ ip: String) -> Bool {
+        ip.hasPrefix("127.") || ip == "::1"
+    }
 
     private func extractHostPort(from address: Multiaddr) -> (String, UInt16)? {
         guard let ip = address.ipAddress,
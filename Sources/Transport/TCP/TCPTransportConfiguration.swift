@@ -0,0 +1,83 @@
+/// Socket options for TCP connections.
+///
+/// Applied to dialed sockets and to sockets accepted by listeners. The
+/// defaults match go-libp2p: Nagle's algorithm off and 30-second keepalives.
+
+import Foundation
+import NIOCore
+
+/// Configuration for TCP transport sockets.
+public struct TCPTransportConfiguration: Sendable {
+
+    /// Whether to set `TCP_NODELAY`, sending small writes immediately
+    /// instead of waiting for outstanding ACKs (Nagle's algorithm).
+    ///
+    /// Leaving Nagle on can stall small request/response exchanges by up to
+    /// the peer's delayed-ACK timeout (~40ms on Linux).
+    public var noDelay: Bool
+
+    /// The keepalive idle time and probe interval, or `nil` to disable
+    /// `SO_KEEPALIVE`.
+    ///
+    /// Rounded to whole seconds, at least 1.
+    public var keepAliveInterval: Duration?
+
+    /// The timeout for establishing an outbound TCP connection.
+    public var connectTimeout: Duration
+
+    /// Creates a new TCP transport configuration.
+    ///
+    /// - Parameters:
+    ///   - noDelay: Set `TCP_NODELAY`. Default: true.
+    ///   - keepAliveInterval: Keepalive idle time and interval, `nil` disables. Default: 30 seconds.
+    ///   - connectTimeout: Outbound connect timeout. Default: 10 seconds.
+    public init(
+        noDelay: Bool = true,
+        keepAliveInterval: Duration? = .seconds(30),
+        connectTimeout: Duration = .seconds(10)
+    ) {
+        self.noDelay = noDelay
+        self.keepAliveInterval = keepAliveInterval
+        self.connectTimeout = connectTimeout
+    }
+
+    /// The socket options to set on each connection's socket.
+    var socketOptions: [(ChannelOptions.Types.SocketOption, SocketOptionValue)] {
+        var options: [(ChannelOptions.Types.SocketOption, SocketOptionValue)] = [
+            (.init(level: .socket, name: .so_reuseaddr), 1),
+            (.init(level: .tcp, name: .tcp_nodelay), noDelay ? 1 : 0),
+            (.init(level: .socket, name: .so_keepalive), keepAliveInterval == nil ? 0 : 1),
+        ]
+        if let keepAliveInterval {
+            let seconds = SocketOptionValue(clamping: max(1, keepAliveInterval.components.seconds))
+            for option in Self.keepAliveTimingOptions {
+                options.append((option, seconds))
+            }
+        }
+        return options
+    }
+
+    /// The connect timeout as a NIO `TimeAmount`.
+    var connectTimeAmount: TimeAmount {
+        let (seconds, attoseconds) = connectTimeout.components
+        return .nanoseconds(seconds * 1_000_000_000 + attoseconds / 1_000_000_000)
+    }
+
+    /// The keepalive idle-time and probe-interval options, where the
+    /// platform has them.
+    private static var keepAliveTimingOptions: [ChannelOptions.Types.SocketOption] {
+        #if os(Windows)
+        return []
+        #elseif canImport(Darwin)
+        return [
+            .init(level: .tcp, name: NIOBSDSocket.Option(rawValue: TCP_KEEPALIVE)),
+            .init(level: .tcp, name: NIOBSDSocket.Option(rawValue: TCP_KEEPINTVL)),
+        ]
+        #else
+        return [
+            .init(level: .tcp, name: NIOBSDSocket.Option(rawValue: TCP_KEEPIDLE)),
+            .init(level: .tcp, name: NIOBSDSocket.Option(rawValue: TCP_KEEPINTVL)),
+        ]
+        #endif
+    }
+}
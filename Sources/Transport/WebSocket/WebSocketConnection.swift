@@ -25,6 +25,7 @@ private struct WebSocketConnectionState: Sendable {
     /// Queue of waiters for concurrent read support.
     var readWaiters: [CheckedContinuation<ByteBuffer, Error>] = []
     var isClosed = false
+    var remoteCloseCode: UInt16?
 }
 
 /// A WebSocket connection wrapping a NIO Channel.
@@ -40,6 +41,14 @@ public final class WebSocketConnection: RawConnection, Sendable {
     public var localAddress: Multiaddr? { _localAddress }
     public var remoteAddress: Multiaddr { _remoteAddress }
 
+    /// The status code of the Close frame the peer sent (1005 if it carried
+    /// none), or `nil` if none has arrived. Non-`nil` after the connection
+    /// ends means the peer closed it in an orderly way rather than dropping
+    /// or resetting the TCP connection.
+    public var remoteCloseCode: UInt16? {
+        state.withLock { $0.remoteCloseCode }
+    }
+
     init(channel: Channel, isClient: Bool, localAddress: Multiaddr?, remoteAddress: Multiaddr) {
         self.channel = channel
         self.isClient = isClient
@@ -205,6 +214,11 @@ public final class WebSocketConnection: RawConnection, Sendable {
         }
     }
 
+    // Called by WebSocketFrameHandler when the peer sends a Close frame
+    fileprivate func closeFrameReceived(code: UInt16) {
+        state.withLock { $0.remoteCloseCode = code }
+    }
+
     // Called by WebSocketFrameHandler when channel becomes inactive
     fileprivate func channelInactive() {
         wsConnectionLogger.debug("channelInactive(): Channel became inactive")
@@ -240,6 +254,7 @@ final class WebSocketFrameHandler: ChannelInboundHandler, Sendable {
         var bufferedData: [ByteBuffer] = []
         var fragmentedMessage: ByteBuffer?
         var isInactive = false
+        var remoteCloseCode: UInt16?
     }
 
     init(isClient: Bool) {
@@ -252,12 +267,15 @@ final class WebSocketFrameHandler: ChannelInboundHandler, Sendable {
     /// Flushes any buffered data and propagates inactive state.
     func setConnection(_ connection: WebSocketConnection) {
         wsConnectionLogger.debug("WebSocketFrameHandler.setConnection: Setting connection")
-        let (buffered, inactive) = state.withLock { s -> ([ByteBuffer], Bool) in
+        let (buffered, inactive, closeCode) = state.withLock { s -> ([ByteBuffer], Bool, UInt16?) in
             s.connection = connection
             let b = s.bufferedData
             s.bufferedData.removeAll()
             let i = s.isInactive
-            return (b, i)
+            return (b, i, s.remoteCloseCode)
+        }
+        if let closeCode {
+            connection.closeFrameReceived(code: closeCode)
         }
         for data in buffered {
             wsConnectionLogger.debug("WebSocketFrameHandler: Flushing \(data.readableBytes) buffered bytes")
@@ -356,6 +374,13 @@ final class WebSocketFrameHandler: ChannelInboundHandler, Sendable {
             if let inboundMaskKey = frame.maskKey {
                 closeData.webSocketUnmask(inboundMaskKey)
             }
+            // 1005: no status code present (RFC 6455 section 7.1.5)
+            let statusCode = closeData.getInteger(at: closeData.readerIndex, as: UInt16.self) ?? 1005
+            let conn: WebSocketConnection? = state.withLock { s in
+                s.remoteCloseCode = statusCode
+                return s.connection
+            }
+            conn?.closeFrameReceived(code: statusCode)
             let closeCode = closeData.readSlice(length: 2) ?? context.channel.allocator.buffer(capacity: 0)
             let responseMaskKey: WebSocketMaskingKey? = isClient ? .random() : nil
             let responseFrame = WebSocketFrame(fin: true, opcode: .connectionClose, maskKey: responseMaskKey, data: closeCode)
@@ -467,6 +492,10 @@ extension SocketAddress {
         case .v6(let addr):
             let ip = addr.host
             let port = UInt16(self.port ?? 0)
+            // IPv4 peers of a dual-stack listener arrive as ::ffff:a.b.c.d
+            if let ipv4 = IPAddress.unmappedIPv4(ip) {
+                return Multiaddr(uncheckedProtocols: [.ip4(ipv4), .tcp(port), secure ? .wss : .ws])
+            }
             return Multiaddr(uncheckedProtocols: [.ip6(ip), .tcp(port), secure ? .wss : .ws])
         case .unixDomainSocket:
             return nil
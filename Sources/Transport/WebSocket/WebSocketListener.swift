@@ -22,6 +22,7 @@ public final class WebSocketListener: Listener, Sendable {
 
     private let serverChannel: Channel
     private let _localAddress: Multiaddr
+    private let _localAddresses: [Multiaddr]
 
     private let state: Mutex<ListenerState>
 
@@ -38,9 +39,12 @@ public final class WebSocketListener: Listener, Sendable {
 
     public var localAddress: Multiaddr { _localAddress }
 
-    private init(serverChannel: Channel, localAddress: Multiaddr) {
+    public var localAddresses: [Multiaddr] { _localAddresses }
+
+    private init(serverChannel: Channel, localAddresses: [Multiaddr]) {
         self.serverChannel = serverChannel
-        self._localAddress = localAddress
+        self._localAddress = localAddresses[0]
+        self._localAddresses = localAddresses
         self.state = Mutex(ListenerState())
         self.acceptTask = Mutex(nil)
     }
@@ -52,12 +56,17 @@ public final class WebSocketListener: Listener, Sendable {
     /// 2. Create the listener
     /// 3. Start background task iterating inbound connections
     /// 4. For each upgrade result, create `WebSocketConnection` and deliver to `accept()` waiters
+    ///
+    /// With `dualStack` (an IPv6 wildcard host), the socket also accepts IPv4
+    /// where the OS allows clearing `IPV6_V6ONLY`; otherwise it stays IPv6-only
+    /// and IPv4 needs a separate `/ip4` listener.
     static func bind(
         host: String,
         port: UInt16,
         group: EventLoopGroup,
         secure: Bool = false,
-        sslContext: NIOSSLContext? = nil
+        sslContext: NIOSSLContext? = nil,
+        dualStack: Bool = false
     ) async throws -> WebSocketListener {
         wsListenerLogger.debug("bind(): Binding to \(host):\(port)")
 
@@ -66,11 +75,18 @@ public final class WebSocketListener: Listener, Sendable {
         }
 
         // Async bind with typed WebSocket upgrade pipeline
-        let asyncServerChannel = try await ServerBootstrap(group: group)
-            .serverChannelOption(.socketOption(.so_reuseaddr), value: 1)
-            .childChannelOption(.socketOption(.so_reuseaddr), value: 1)
-            .childChannelOption(.autoRead, value: true)
-            .bind(host: host, port: Int(port)) { channel in
+        func bindServer(v6Only: Bool?) async throws -> NIOAsyncChannel<EventLoopFuture<WebSocketUpgradeResult>, Never> {
+            var bootstrap = ServerBootstrap(group: group)
+                .serverChannelOption(.socketOption(.so_reuseaddr), value: 1)
+                .childChannelOption(.socketOption(.so_reuseaddr), value: 1)
+                .childChannelOption(.autoRead, value: true)
+            if let v6Only {
+                bootstrap = bootstrap.serverChannelOption(
+                    ChannelOptions.Types.SocketOption(level: .ipv6, name: .ipv6_v6only),
+                    value: v6Only ? 1 : 0
+                )
+            }
+            return try await bootstrap.bind(host: host, port: Int(port)) { channel in
                 channel.eventLoop.makeCompletedFuture {
                     // TLS handler (secure only)
                     if let sslContext {
@@ -107,6 +123,21 @@ public final class WebSocketListener: Listener, Sendable {
                         )
                 }
             }
+        }
+
+        let asyncServerChannel: NIOAsyncChannel<EventLoopFuture<WebSocketUpgradeResult>, Never>
+        var acceptsIPv4 = false
+        if dualStack {
+            do {
+                asyncServerChannel = try await bindServer(v6Only: false)
+                acceptsIPv4 = true
+            } catch {
+                wsListenerLogger.debug("bind(): Dual-stack socket unavailable, listening on IPv6 only: \(error)")
+                asyncServerChannel = try await bindServer(v6Only: nil)
+            }
+        } else {
+            asyncServerChannel = try await bindServer(v6Only: nil)
+        }
 
         let serverChannel = asyncServerChannel.channel
         guard let socketAddress = serverChannel.localAddress,
@@ -116,9 +147,17 @@ public final class WebSocketListener: Listener, Sendable {
             )
         }
 
-        wsListenerLogger.debug("bind(): Bound successfully to \(localAddr)")
+        var localAddrs = [localAddr]
+        if acceptsIPv4, let boundPort = localAddr.tcpPort {
+            // One socket serves both families
+            localAddrs.append(
+                secure ? Multiaddr.wss(host: "0.0.0.0", port: boundPort) : Multiaddr.ws(host: "0.0.0.0", port: boundPort)
+            )
+        }
+
+        wsListenerLogger.debug("bind(): Bound successfully to \(localAddrs)")
 
-        let listener = WebSocketListener(serverChannel: serverChannel, localAddress: localAddr)
+        let listener = WebSocketListener(serverChannel: serverChannel, localAddresses: localAddrs)
 
         // Start background accept loop
         let task = Task<Void, Never> {
@@ -27,13 +27,20 @@ public struct WebSocketTLSConfiguration: Sendable {
     /// If nil, `listen(.wss(...))` fails with an explicit error.
     public var server: TLSConfiguration?
 
+    /// SHA-256 fingerprints (over the DER encoding) of the leaf certificates
+    /// a `/wss` dial accepts. Checked after chain and hostname verification,
+    /// so a pin narrows trust but never replaces it. Empty pins nothing.
+    public var pinnedCertificateSHA256: [[UInt8]]
+
     /// Creates a TLS configuration for WebSocket transport.
     public init(
         client: TLSConfiguration = WebSocketTLSConfiguration.defaultSecureClientConfiguration(),
-        server: TLSConfiguration? = nil
+        server: TLSConfiguration? = nil,
+        pinnedCertificateSHA256: [[UInt8]] = []
     ) {
         self.client = client
         self.server = server
+        self.pinnedCertificateSHA256 = pinnedCertificateSHA256
     }
 
     /// Secure default for `wss`: certificate chain + hostname verification.
@@ -48,10 +55,14 @@ public struct WebSocketTLSConfiguration: Sendable {
 internal enum WebSocketDetailError: Error, CustomStringConvertible, Sendable {
     case upgradeFailed
     case tlsConfigurationFailed(String)
+    case noAddressesResolved(String)
+    case certificateNotPinned(sha256: String)
     var description: String {
         switch self {
         case .upgradeFailed: return "WebSocket upgrade failed"
         case .tlsConfigurationFailed(let msg): return "TLS configuration failed: \(msg)"
+        case .noAddressesResolved(let host): return "No addresses resolved for \(host)"
+        case .certificateNotPinned(let sha256): return "Server certificate sha256=\(sha256) is not pinned"
         }
     }
 }
@@ -73,6 +84,7 @@ public final class WebSocketTransport: Transport, Sendable {
     private let group: EventLoopGroup
     private let ownsGroup: Bool
     private let tlsConfiguration: WebSocketTLSConfiguration
+    private let dnsLookup: any DNSLookup
 
     /// The protocols this transport supports.
     ///
@@ -100,13 +112,18 @@ public final class WebSocketTransport: Transport, Sendable {
         self.group = MultiThreadedEventLoopGroup(numberOfThreads: System.coreCount)
         self.ownsGroup = true
         self.tlsConfiguration = WebSocketTLSConfiguration()
+        self.dnsLookup = SystemDNSLookup()
     }
 
     /// Creates a WebSocketTransport with custom TLS configuration.
-    public init(tlsConfiguration: WebSocketTLSConfiguration) {
+    ///
+    /// `dnsLookup` resolves the hostnames of `/dns`, `/dns4` and `/dns6`
+    /// addresses at dial time.
+    public init(tlsConfiguration: WebSocketTLSConfiguration, dnsLookup: any DNSLookup = SystemDNSLookup()) {
         self.group = MultiThreadedEventLoopGroup(numberOfThreads: System.coreCount)
         self.ownsGroup = true
         self.tlsConfiguration = tlsConfiguration
+        self.dnsLookup = dnsLookup
     }
 
     /// Creates a WebSocketTransport with an existing EventLoopGroup.
@@ -114,13 +131,19 @@ public final class WebSocketTransport: Transport, Sendable {
         self.group = group
         self.ownsGroup = false
         self.tlsConfiguration = WebSocketTLSConfiguration()
+        self.dnsLookup = SystemDNSLookup()
     }
 
     /// Creates a WebSocketTransport with an existing EventLoopGroup and custom TLS settings.
-    public init(group: EventLoopGroup, tlsConfiguration: WebSocketTLSConfiguration) {
+    public init(
+        group: EventLoopGroup,
+        tlsConfiguration: WebSocketTLSConfiguration,
+        dnsLookup: any DNSLookup = SystemDNSLookup()
+    ) {
         self.group = group
         self.ownsGroup = false
         self.tlsConfiguration = tlsConfiguration
+        self.dnsLookup = dnsLookup
     }
 
     deinit {
@@ -153,19 +176,22 @@ public final class WebSocketTransport: Transport, Sendable {
     private func dialInsecure(host: String, port: UInt16, address: Multiaddr) async throws -> any RawConnection {
         wsTransportLogger.debug("dial(): Connecting to ws://\(host):\(port)")
 
-        let upgradeResult: EventLoopFuture<WebSocketUpgradeResult> = try await ClientBootstrap(group: group)
-            .channelOption(.socketOption(.so_reuseaddr), value: 1)
-            .connect(host: host, port: Int(port)) { channel in
-                channel.eventLoop.makeCompletedFuture {
-                    try self.configureWebSocketUpgrade(channel: channel, host: host, port: port)
+        let targets = try await connectTargets(for: address, host: host)
+        return try await connectEach(targets, address: address) { target in
+            let upgradeResult: EventLoopFuture<WebSocketUpgradeResult> = try await ClientBootstrap(group: self.group)
+                .channelOption(.socketOption(.so_reuseaddr), value: 1)
+                .connect(host: target, port: Int(port)) { channel in
+                    channel.eventLoop.makeCompletedFuture {
+                        try self.configureWebSocketUpgrade(channel: channel, host: host, port: port, isSecure: false)
+                    }
                 }
-            }
 
-        return try await completeUpgrade(
-            upgradeResult: upgradeResult,
-            address: address,
-            isSecure: false
-        )
+            return try await self.completeUpgrade(
+                upgradeResult: upgradeResult,
+                address: address,
+                isSecure: false
+            )
+        }
     }
 
     /// Dial secure WebSocket (wss://)
@@ -199,36 +225,105 @@ public final class WebSocketTransport: Transport, Sendable {
             throw TransportError.connectionFailed(underlying: WebSocketDetailError.tlsConfigurationFailed(String(describing: error)))
         }
 
-        let upgradeResult: EventLoopFuture<WebSocketUpgradeResult> = try await ClientBootstrap(group: group)
-            .channelOption(.socketOption(.so_reuseaddr), value: 1)
-            .connect(host: host, port: Int(port)) { channel in
-                channel.eventLoop.makeCompletedFuture {
-                    // Add TLS handler first
-                    let sslHandler: NIOSSLClientHandler
-                    do {
-                        sslHandler = try NIOSSLClientHandler(context: sslContext, serverHostname: host)
-                    } catch {
-                        throw TransportError.connectionFailed(underlying: WebSocketDetailError.tlsConfigurationFailed(String(describing: error)))
+        // Connect to the resolved IPs, but present the hostname: it is the
+        // TLS SNI, the name the certificate is verified against and the
+        // HTTP Host. The libp2p identity is still established by the
+        // security upgrade that runs over this connection.
+        let targets = try await connectTargets(for: address, host: host)
+        return try await connectEach(targets, address: address) { target in
+            let upgradeResult: EventLoopFuture<WebSocketUpgradeResult> = try await ClientBootstrap(group: self.group)
+                .channelOption(.socketOption(.so_reuseaddr), value: 1)
+                .connect(host: target, port: Int(port)) { channel in
+                    channel.eventLoop.makeCompletedFuture {
+                        // Add TLS handler first
+                        let sslHandler: NIOSSLClientHandler
+                        do {
+                            sslHandler = try NIOSSLClientHandler(context: sslContext, serverHostname: host)
+                        } catch {
+                            throw TransportError.connectionFailed(underlying: WebSocketDetailError.tlsConfigurationFailed(String(describing: error)))
+                        }
+                        try channel.pipeline.syncOperations.addHandler(sslHandler)
+
+                        // Then configure WebSocket upgrade
+                        return try self.configureWebSocketUpgrade(channel: channel, host: host, port: port, isSecure: true)
                     }
-                    try channel.pipeline.syncOperations.addHandler(sslHandler)
-
-                    // Then configure WebSocket upgrade
-                    return try self.configureWebSocketUpgrade(channel: channel, host: host, port: port)
                 }
+
+            return try await self.completeUpgrade(
+                upgradeResult: upgradeResult,
+                address: address,
+                isSecure: true
+            )
+        }
+    }
+
+    /// The IP addresses to connect to for `address`, in the order they are
+    /// tried. IP literals are used as-is; `/dns4` and `/dns6` look up A or
+    /// AAAA records, and `/dns` looks up both, IPv6 first.
+    func connectTargets(for address: Multiaddr, host: String) async throws -> [String] {
+        let families: [DNSAddressFamily]
+        switch address.protocols.first {
+        case .dns4:
+            families = [.ipv4]
+        case .dns6:
+            families = [.ipv6]
+        case .dns:
+            families = [.ipv6, .ipv4]
+        default:
+            return [host]
+        }
+
+        var targets: [String] = []
+        var lastError: (any Error)?
+        for family in families {
+            do {
+                targets += try await dnsLookup.addresses(of: host, family: family).values
+            } catch {
+                lastError = error
             }
+        }
+        if targets.isEmpty {
+            throw TransportError.connectionFailed(
+                underlying: lastError ?? WebSocketDetailError.noAddressesResolved(host)
+            )
+        }
+        return targets
+    }
 
-        return try await completeUpgrade(
-            upgradeResult: upgradeResult,
-            address: address,
-            isSecure: true
-        )
+    /// Runs `connect` against each target until one succeeds, and rethrows
+    /// the last failure if none does.
+    private func connectEach(
+        _ targets: [String],
+        address: Multiaddr,
+        _ connect: (String) async throws -> any RawConnection
+    ) async throws -> any RawConnection {
+        var lastError: any Error = TransportError.unsupportedAddress(address)
+        for target in targets {
+            try Task.checkCancellation()
+            do {
+                return try await connect(target)
+            } catch {
+                wsTransportLogger.debug("dial(): Connecting to \(target) for \(address) failed: \(error)")
+                lastError = error
+            }
+        }
+        throw lastError
+    }
+
+    /// The HTTP `Host` header for the upgrade request: the hostname, with the
+    /// port unless it is the scheme's default (80 for ws, 443 for wss).
+    static func hostHeader(host: String, port: UInt16, isSecure: Bool) -> String {
+        let name = host.contains(":") ? "[\(host)]" : host
+        let defaultPort: UInt16 = isSecure ? 443 : 80
+        return port == defaultPort ? name : "\(name):\(port)"
     }
 
     /// Configure WebSocket upgrade pipeline
     private func configureWebSocketUpgrade(
         channel: Channel,
         host: String,
-        port: UInt16
+        port: UInt16,
+        isSecure: Bool
     ) throws -> EventLoopFuture<WebSocketUpgradeResult> {
         let upgrader = NIOTypedWebSocketClientUpgrader<WebSocketUpgradeResult>(
             maxFrameSize: wsMaxFrameSize,
@@ -242,7 +337,7 @@ public final class WebSocketTransport: Transport, Sendable {
         )
 
         var headers = HTTPHeaders()
-        headers.add(name: "Host", value: "\(host):\(port)")
+        headers.add(name: "Host", value: Self.hostHeader(host: host, port: port, isSecure: isSecure))
         headers.add(name: "Content-Length", value: "0")
 
         let requestHead = HTTPRequestHead(
@@ -276,6 +371,9 @@ public final class WebSocketTransport: Transport, Sendable {
     ) async throws -> any RawConnection {
         switch try await upgradeResult.get() {
         case .websocket(let channel, let handler):
+            if isSecure && !tlsConfiguration.pinnedCertificateSHA256.isEmpty {
+                try await verifyPinnedCertificate(on: channel)
+            }
             let localAddr = channel.localAddress?.toWebSocketMultiaddr(secure: isSecure)
             let connection = WebSocketConnection(
                 channel: channel,
@@ -293,6 +391,33 @@ public final class WebSocketTransport: Transport, Sendable {
         }
     }
 
+    /// Closes the channel unless the server's leaf certificate is pinned.
+    private func verifyPinnedCertificate(on channel: Channel) async throws {
+        var sha256 = "unavailable"
+        do {
+            let certificate = try await channel.pipeline.handler(type: NIOSSLClientHandler.self)
+                .map { $0.peerCertificate }
+                .get()
+            if let certificate {
+                let fingerprint = Array(SHA256.hash(data: try certificate.toDERBytes()))
+                if tlsConfiguration.pinnedCertificateSHA256.contains(fingerprint) {
+                    return
+                }
+                sha256 = fingerprint.map { String(format: "%02x", $0) }.joined()
+            }
+        } catch {
+            wsTransportLogger.debug("dial(): Failed to read the server certificate: \(error)")
+        }
+
+        wsTransportLogger.error("dial(): Server certificate sha256=\(sha256) is not pinned")
+        do {
+            try await channel.close()
+        } catch {
+            wsTransportLogger.debug("dial(): Failed to close unpinned channel: \(error)")
+        }
+        throw TransportError.connectionFailed(underlying: WebSocketDetailError.certificateNotPinned(sha256: sha256))
+    }
+
     public func listen(_ address: Multiaddr) async throws -> any Listener {
         guard let (host, port, isSecure, _) = extractHostPort(
             from: address,
@@ -301,6 +426,7 @@ public final class WebSocketTransport: Transport, Sendable {
         ) else {
             throw TransportError.unsupportedAddress(address)
         }
+        let dualStack = address.isUnspecifiedIP && host.contains(":")
 
         if isSecure {
             guard let serverTLS = tlsConfiguration.server else {
@@ -319,7 +445,8 @@ public final class WebSocketTransport: Transport, Sendable {
                 port: port,
                 group: group,
                 secure: true,
-                sslContext: sslContext
+                sslContext: sslContext,
+                dualStack: dualStack
             )
         }
 
@@ -328,7 +455,8 @@ public final class WebSocketTransport: Transport, Sendable {
             port: port,
             group: group,
             secure: false,
-            sslContext: nil
+            sslContext: nil,
+            dualStack: dualStack
         )
 
         return listener
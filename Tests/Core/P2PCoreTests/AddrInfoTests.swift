@@ -0,0 +1,56 @@
+/// AddrInfoTests - Dial targets built from a peer and its addresses.
+
+import Testing
+import Foundation
+@testable import P2PCore
+
+@Suite("AddrInfo")
+struct AddrInfoTests {
+
+    @Test("Dial addresses end in the peer's /p2p component")
+    func dialAddressesAppendPeerID() throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let info = AddrInfo(peer: peer, addresses: [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            try Multiaddr("/ip4/127.0.0.1/tcp/4002/p2p/\(peer)"),
+        ])
+
+        #expect(info.dialAddresses == [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(peer)"),
+            try Multiaddr("/ip4/127.0.0.1/tcp/4002/p2p/\(peer)"),
+        ])
+    }
+
+    @Test("Addresses naming another peer are left out")
+    func otherPeerIsDropped() throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let other = KeyPair.generateEd25519().peerID
+        let info = AddrInfo(peer: peer, addresses: [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(other)"),
+            try Multiaddr("/ip4/127.0.0.1/tcp/4002"),
+        ])
+
+        #expect(info.dialAddresses == [try Multiaddr("/ip4/127.0.0.1/tcp/4002/p2p/\(peer)")])
+    }
+
+    @Test("The relay's peer ID does not name the dialed peer")
+    func relayAddress() throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let relay = KeyPair.generateEd25519().peerID
+        let info = AddrInfo(peer: peer, addresses: [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(relay)/p2p-circuit"),
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(relay)/p2p-circuit/p2p/\(peer)"),
+        ])
+
+        #expect(info.dialAddresses == [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(relay)/p2p-circuit/p2p/\(peer)"),
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001/p2p/\(relay)/p2p-circuit/p2p/\(peer)"),
+        ])
+    }
+
+    @Test("No addresses give no dial addresses")
+    func emptyAddresses() {
+        let info = AddrInfo(peer: KeyPair.generateEd25519().peerID)
+        #expect(info.dialAddresses.isEmpty)
+    }
+}
@@ -0,0 +1,108 @@
+import Testing
+import Foundation
+@testable import P2PCore
+
+/// Vectors produced with go-cid v0.4.1 for the SHA-256 of
+/// "swift-libp2p cid vector".
+@Suite("CID Tests")
+struct CIDTests {
+
+    static let multihashHex = "12206bafcec5c6f9aa80f9aa4b312ad27a8fa45dab066c49a80f92ce47b6a303ba54"
+    static let v0String = "QmVb1pRS1pRRwjY5xNozxg87RszPf9D93oy39a4Y2zvHQf"
+    static let dagPBBase32 = "bafybeidlv7hmlrxzvkaptkslgevne6upuro2wbtmjgua7ewoi63kga52kq"
+    static let dagPBBase58 = "zdj7WcgGBrVsHu2pSr1h7mcq3xg46BqNgdGv8NZpNTCrSVBab"
+    static let rawBase32 = "bafkreidlv7hmlrxzvkaptkslgevne6upuro2wbtmjgua7ewoi63kga52kq"
+    static let rawBase32Upper = "BAFKREIDLV7HMLRXZVKAPTKSLGEVNE6UPURO2WBTMJGUA7EWOI63KGA52KQ"
+
+    @Test("CIDv0 parses and prints as base58btc")
+    func cidV0() throws {
+        let cid = try CID(string: Self.v0String)
+        #expect(cid.version == .v0)
+        #expect(cid.codec == CID.Codec.dagPB)
+        #expect(cid.bytes == Hex.decode(Self.multihashHex))
+        #expect(cid.description == Self.v0String)
+        #expect(cid.string(base: .base32) == Self.v0String)
+        #expect(try CID(bytes: cid.bytes) == cid)
+    }
+
+    @Test("CIDv1 round-trips through base32 and base58btc")
+    func cidV1() throws {
+        let cid = try CID(string: Self.dagPBBase32)
+        #expect(cid.version == .v1)
+        #expect(cid.codec == CID.Codec.dagPB)
+        #expect(cid.bytes == Hex.decode("0170" + Self.multihashHex))
+        #expect(cid.description == Self.dagPBBase32)
+        #expect(cid.string(base: .base58btc) == Self.dagPBBase58)
+        #expect(try CID(string: Self.dagPBBase58) == cid)
+        #expect(try CID(bytes: cid.bytes) == cid)
+        #expect(try CID(string: Self.v0String).v1 == cid)
+    }
+
+    @Test("Uppercase base32 and base16 multibase prefixes decode")
+    func otherMultibasePrefixes() throws {
+        let cid = try CID(string: Self.rawBase32)
+        #expect(cid.codec == CID.Codec.raw)
+        #expect(try CID(string: Self.rawBase32Upper) == cid)
+        #expect(try CID(string: "f0155" + Self.multihashHex) == cid)
+    }
+
+    @Test("Bare multihashes become raw CIDv1s, as the go interop node does")
+    func cidOrMultihash() throws {
+        let rawCID = try CID(string: Self.rawBase32)
+
+        // A Qm string is a CIDv0 before it is a base58btc multihash
+        let fromBase58 = try CID(cidOrMultihash: Self.v0String)
+        #expect(fromBase58.version == .v0)
+
+        let identity = "0005" + Array("hello".utf8).map { String($0, radix: 16) }.joined()
+        let fromIdentityHex = try CID(cidOrMultihash: identity)
+        #expect(fromIdentityHex.description == "bafkqablimvwgy3y")
+
+        let fromHex = try CID(cidOrMultihash: Self.multihashHex)
+        #expect(fromHex == rawCID)
+        #expect(fromHex.routingKey == fromBase58.routingKey)
+        #expect(try CID(cidOrMultihash: Self.dagPBBase32).routingKey == rawCID.routingKey)
+    }
+
+    @Test("Malformed CIDs are rejected")
+    func malformed() throws {
+        #expect(throws: CIDError.invalidMultihash) {
+            _ = try CID(string: "bafyinvalid")
+        }
+        // go-cid: "expected 1 as the cid version number, got: 16"
+        #expect(throws: CIDError.unsupportedVersion(16)) {
+            _ = try CID(bytes: [0x10, 0x55, 0x00, 0x00])
+        }
+        let trailing = try #require(Hex.decode("0155" + Self.multihashHex + "00"))
+        #expect(throws: CIDError.trailingBytes) {
+            _ = try CID(bytes: trailing)
+        }
+        #expect(throws: CIDError.invalidV0Multihash) {
+            _ = try CID(v0: Multihash.identity([UInt8]([0x01])))
+        }
+        #expect(throws: CIDError.unsupportedMultibase("m")) {
+            _ = try CID(string: "mAXASIA")
+        }
+        // The CID error is reported when no form matches
+        #expect(throws: CIDError.unsupportedMultibase("n")) {
+            _ = try CID(cidOrMultihash: "not a cid")
+        }
+    }
+
+    @Test("Base32 encodes RFC 4648 vectors without padding")
+    func base32() throws {
+        let vectors = ["": "", "f": "my", "fo": "mzxq", "foo": "mzxw6", "foob": "mzxw6yq",
+                       "fooba": "mzxw6ytb", "foobar": "mzxw6ytboi"]
+        for (plain, encoded) in vectors {
+            #expect(Base32.encode(Array(plain.utf8)) == encoded)
+            #expect(try Base32.decode(encoded) == Array(plain.utf8))
+            #expect(try Base32.decode(encoded.uppercased()) == Array(plain.utf8))
+        }
+        #expect(throws: Base32Error.invalidCharacter("=")) {
+            _ = try Base32.decode("my======")
+        }
+        #expect(throws: Base32Error.invalidLength) {
+            _ = try Base32.decode("m")
+        }
+    }
+}
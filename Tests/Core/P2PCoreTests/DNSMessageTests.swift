@@ -0,0 +1,80 @@
+import Testing
+@testable import P2PCore
+
+@Suite("DNSMessage")
+struct DNSMessageTests {
+
+    @Test("TXT query encodes header, question and EDNS0 OPT record")
+    func encodeQuery() throws {
+        let query = try DNSMessage.query(id: 0xBEEF, name: "_dnsaddr.example.org.", type: DNSMessage.typeTXT)
+
+        #expect(Array(query[0..<12]) == [0xBE, 0xEF, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 1])
+        let question: [UInt8] = [8] + Array("_dnsaddr".utf8) + [7] + Array("example".utf8) + [3] + Array("org".utf8)
+            + [0, 0, 16, 0, 1]
+        #expect(Array(query[12..<(12 + question.count)]) == question)
+        #expect(Array(query[(12 + question.count)...]) == [0, 0, 41, 0x10, 0x00, 0, 0, 0, 0, 0, 0])
+    }
+
+    @Test("invalid names are rejected", arguments: ["", ".", "a..b", String(repeating: "x", count: 64) + ".org"])
+    func rejectInvalidName(name: String) {
+        #expect(throws: DNSMessage.ParseError.invalidName(name)) {
+            _ = try DNSMessage.query(id: 1, name: name, type: DNSMessage.typeTXT)
+        }
+    }
+
+    @Test("response answers are read past compressed names")
+    func parseResponse() throws {
+        let query = try DNSMessage.query(id: 7, name: "_dnsaddr.x", type: DNSMessage.typeTXT)
+        let questionEnd = 12 + 1 + 8 + 1 + 1 + 1 + 4
+        var response = Array(query[0..<questionEnd])
+        response[2] = 0x81  // QR, RD
+        response[3] = 0x80  // RA
+        response[7] = 2     // ANCOUNT
+        response[11] = 0    // ARCOUNT
+
+        let rdata: [UInt8] = [13] + Array("dnsaddr=/ip4/".utf8) + [13] + Array("192.0.2.1/tcp".utf8)
+        // TXT, TTL 300, name as a pointer to the question
+        response += [0xC0, 12, 0, 16, 0, 1, 0, 0, 0x01, 0x2C, 0, UInt8(rdata.count)] + rdata
+        // CNAME, TTL 60, name spelled out
+        response += [1, UInt8(ascii: "y"), 0, 0, 5, 0, 1, 0, 0, 0, 60, 0, 2, 0xC0, 12]
+
+        let parsed = try DNSMessage.parseResponse(response, id: 7)
+        #expect(parsed.rcode == 0)
+        #expect(parsed.answers.map(\.type) == [DNSMessage.typeTXT, 5])
+        #expect(parsed.answers.map(\.ttl) == [300, 60])
+        #expect(try DNSMessage.textValue(parsed.answers[0].data) == "dnsaddr=/ip4/192.0.2.1/tcp")
+    }
+
+    @Test("mismatched, truncated and non-response messages are rejected")
+    func rejectBadResponses() throws {
+        var response = try DNSMessage.query(id: 9, name: "example.org", type: DNSMessage.typeTXT)
+        #expect(throws: DNSMessage.ParseError.notAResponse) {
+            _ = try DNSMessage.parseResponse(response, id: 9)
+        }
+
+        response[2] |= 0x80
+        #expect(throws: DNSMessage.ParseError.idMismatch) {
+            _ = try DNSMessage.parseResponse(response, id: 10)
+        }
+
+        response[7] = 1  // claims an answer that is not there
+        response[11] = 0
+        #expect(throws: DNSMessage.ParseError.truncated) {
+            _ = try DNSMessage.parseResponse(Array(response.prefix(12 + 13 + 4)), id: 9)
+        }
+        #expect(throws: DNSMessage.ParseError.truncated) {
+            _ = try DNSMessage.textValue([5, 0x61])
+        }
+    }
+
+    @Test("NXDOMAIN rcode is reported")
+    func nameError() throws {
+        var response = try DNSMessage.query(id: 3, name: "missing.example", type: DNSMessage.typeTXT)
+        response[2] = 0x81
+        response[3] = 0x83
+        response[11] = 0
+        let parsed = try DNSMessage.parseResponse(Array(response.prefix(12 + 17 + 4)), id: 3)
+        #expect(parsed.rcode == DNSMessage.rcodeNameError)
+        #expect(parsed.answers.isEmpty)
+    }
+}
@@ -1,5 +1,6 @@
 import Testing
 import Foundation
+import Synchronization
 @testable import P2PCore
 
 @Suite("DNSResolver")
@@ -105,9 +106,9 @@ struct DNSResolverTests {
         }
     }
 
-    @Test("resolve dnsaddr throws dnsaddrLookupFailed", .timeLimit(.minutes(1)))
+    @Test("dnsaddr without TXT records throws dnsaddrLookupFailed")
     func resolveDNSAddrThrows() async throws {
-        let resolver = SystemDNSResolver()
+        let resolver = SystemDNSResolver(lookup: StubDNSLookup())
         let addr = try Multiaddr("/dnsaddr/bootstrap.libp2p.io")
         do {
             _ = try await resolver.resolve(addr)
@@ -146,4 +147,156 @@ struct DNSResolverTests {
             #expect(hasWS)
         }
     }
+
+    // MARK: - dnsaddr
+
+    private static let peerA = KeyPair.generateEd25519().peerID.description
+    private static let peerB = KeyPair.generateEd25519().peerID.description
+
+    @Test("dnsaddr expands TXT records, keeping only the requested peer")
+    func resolveDNSAddrFiltersByPeer() async throws {
+        let lookup = StubDNSLookup(text: [
+            "_dnsaddr.bootstrap.example": [
+                "dnsaddr=/ip4/192.0.2.1/tcp/4001/p2p/\(Self.peerA)",
+                "dnsaddr=/ip6/2001:db8::1/udp/4001/quic-v1/p2p/\(Self.peerA)",
+                "dnsaddr=/ip4/192.0.2.2/tcp/4001/p2p/\(Self.peerB)",
+                "unrelated=record",
+            ],
+        ])
+        let resolver = SystemDNSResolver(lookup: lookup)
+
+        let resolved = try await resolver.resolve(
+            try Multiaddr("/dnsaddr/bootstrap.example/p2p/\(Self.peerA)")
+        )
+        #expect(resolved.map(\.description) == [
+            "/ip4/192.0.2.1/tcp/4001/p2p/\(Self.peerA)",
+            "/ip6/2001:db8::1/udp/4001/quic-v1/p2p/\(Self.peerA)",
+        ])
+
+        // Without a /p2p suffix every entry matches
+        let all = try await resolver.resolve(try Multiaddr("/dnsaddr/bootstrap.example"))
+        #expect(all.count == 3)
+    }
+
+    @Test("dnsaddr follows nested dnsaddr and dns records")
+    func resolveNestedDNSAddr() async throws {
+        let lookup = StubDNSLookup(
+            addresses: ["node.example": ["198.51.100.7"]],
+            text: [
+                "_dnsaddr.bootstrap.example": ["dnsaddr=/dnsaddr/sjc.bootstrap.example/p2p/\(Self.peerA)"],
+                "_dnsaddr.sjc.bootstrap.example": ["dnsaddr=/dns4/node.example/tcp/4001/p2p/\(Self.peerA)"],
+            ]
+        )
+        let resolver = SystemDNSResolver(lookup: lookup)
+
+        let resolved = try await resolver.resolve(
+            try Multiaddr("/dnsaddr/bootstrap.example/p2p/\(Self.peerA)")
+        )
+        #expect(resolved.map(\.description) == ["/ip4/198.51.100.7/tcp/4001/p2p/\(Self.peerA)"])
+    }
+
+    @Test("a failing dnsaddr entry does not fail the others")
+    func resolveDNSAddrSkipsFailures() async throws {
+        let lookup = StubDNSLookup(text: [
+            "_dnsaddr.bootstrap.example": [
+                "dnsaddr=/dns4/missing.example/tcp/4001",
+                "dnsaddr=/dnsaddr/empty.example",
+                "dnsaddr=not-a-multiaddr",
+                "dnsaddr=/ip4/192.0.2.9/tcp/4001",
+            ],
+        ])
+        let resolver = SystemDNSResolver(lookup: lookup)
+
+        let resolved = try await resolver.resolve(try Multiaddr("/dnsaddr/bootstrap.example"))
+        #expect(resolved.map(\.description) == ["/ip4/192.0.2.9/tcp/4001"])
+    }
+
+    @Test("self-referencing dnsaddr stops at maxDNSAddrDepth")
+    func resolveDNSAddrLoop() async throws {
+        let lookup = StubDNSLookup(
+            text: ["_dnsaddr.loop.example": ["dnsaddr=/dnsaddr/loop.example"]],
+            ttl: ["_dnsaddr.loop.example": .zero]
+        )
+        let resolver = SystemDNSResolver(maxDNSAddrDepth: 3, lookup: lookup)
+
+        await #expect(throws: DNSResolverError.self) {
+            _ = try await resolver.resolve(try Multiaddr("/dnsaddr/loop.example"))
+        }
+        #expect(lookup.textLookups == 3)
+    }
+
+    // MARK: - Cache
+
+    @Test("answers are cached until their TTL expires")
+    func cacheHonorsTTL() async throws {
+        let lookup = StubDNSLookup(
+            addresses: ["cached.example": ["192.0.2.1"], "short.example": ["192.0.2.2"]],
+            ttl: ["cached.example": .seconds(60), "short.example": .milliseconds(50)]
+        )
+        let resolver = SystemDNSResolver(lookup: lookup)
+        let cached = try Multiaddr("/dns4/cached.example/tcp/4001")
+        let short = try Multiaddr("/dns4/short.example/tcp/4001")
+
+        _ = try await resolver.resolve(cached)
+        _ = try await resolver.resolve(cached)
+        #expect(lookup.addressLookups == 1)
+
+        _ = try await resolver.resolve(short)
+        try await Task.sleep(for: .milliseconds(100))
+        _ = try await resolver.resolve(short)
+        #expect(lookup.addressLookups == 3)
+    }
+
+    @Test("zero TTL answers and failures are not cached")
+    func cacheSkipsZeroTTLAndFailures() async throws {
+        let lookup = StubDNSLookup(
+            addresses: ["uncached.example": ["192.0.2.1"]],
+            ttl: ["uncached.example": .zero]
+        )
+        let resolver = SystemDNSResolver(lookup: lookup)
+
+        _ = try await resolver.resolve(try Multiaddr("/dns4/uncached.example/tcp/4001"))
+        _ = try await resolver.resolve(try Multiaddr("/dns4/uncached.example/tcp/4001"))
+        for _ in 0..<2 {
+            await #expect(throws: DNSResolverError.self) {
+                _ = try await resolver.resolve(try Multiaddr("/dns4/missing.example/tcp/4001"))
+            }
+        }
+        #expect(lookup.addressLookups == 4)
+    }
+}
+
+/// Canned `DNSLookup` answers keyed by name; unknown names fail (A/AAAA)
+/// or have no records (TXT).
+private final class StubDNSLookup: DNSLookup, Sendable {
+    private let addressRecords: [String: [String]]
+    private let textRecords: [String: [String]]
+    private let ttls: [String: Duration]
+    private let counts = Mutex<(addresses: Int, text: Int)>((0, 0))
+
+    init(
+        addresses: [String: [String]] = [:],
+        text: [String: [String]] = [:],
+        ttl: [String: Duration] = [:]
+    ) {
+        self.addressRecords = addresses
+        self.textRecords = text
+        self.ttls = ttl
+    }
+
+    var addressLookups: Int { counts.withLock { $0.addresses } }
+    var textLookups: Int { counts.withLock { $0.text } }
+
+    func addresses(of hostname: String, family: DNSAddressFamily) async throws -> DNSAnswer {
+        counts.withLock { $0.addresses += 1 }
+        guard let values = addressRecords[hostname] else {
+            throw DNSResolverError.noAddressesFound(hostname: hostname, family: "\(family)")
+        }
+        return DNSAnswer(values: values, ttl: ttls[hostname] ?? .seconds(60))
+    }
+
+    func textRecords(of name: String) async throws -> DNSAnswer {
+        counts.withLock { $0.text += 1 }
+        return DNSAnswer(values: textRecords[name] ?? [], ttl: ttls[name] ?? .seconds(60))
+    }
 }
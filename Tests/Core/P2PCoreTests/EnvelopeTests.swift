@@ -123,10 +123,8 @@ struct EnvelopeTests {
 
     @Test("Unmarshal rejects oversized public key field")
     func unmarshalOversizedPublicKey() throws {
-        // Construct data with an absurdly large public key length
-        var data = Data()
-        data.append(contentsOf: Varint.encode(UInt64(5000))) // > 4096
-        data.append(Data(repeating: 0, count: 5000))
+        // A public_key field far larger than any key (> 4096)
+        let data = encodeProtobufField(fieldNumber: 1, data: Data(repeating: 0, count: 5000))
 
         #expect(throws: EnvelopeError.self) {
             _ = try Envelope.unmarshal(data)
@@ -154,4 +152,74 @@ struct EnvelopeTests {
         #expect(envelope.peerID == keyPair1.peerID)
         #expect(envelope.peerID != keyPair2.peerID)
     }
+
+    // MARK: - go-libp2p vectors
+
+    /// `record.Seal` of a PeerRecord (seq 1700000000, /ip4/127.0.0.1/tcp/4001
+    /// and /ip6/::1/udp/4001/quic-v1) with the Ed25519 key whose seed is
+    /// 0x01...0x20, marshaled by go-libp2p v0.36.
+    static let goSeed = Data(1...32)
+    static let goPeerID = "12D3KooWJ1TsijH7H5F74hfAD5XishQz3sxrmAtVY37GtNd9CqYf"
+    static let goPayloadHex = "0a2600240801122079b5562e8fe654f94078b112e8a98ba7901f853ae695bed7e0e3910bad0496641080e2cfaa061a0a0a08047f000001060fa11a190a17290000000000000000000000000000000191020fa1cd03"
+    static let goEnvelopeHex = "0a240801122079b5562e8fe654f94078b112e8a98ba7901f853ae695bed7e0e3910bad049664120203011a55" + goPayloadHex + "2a4036ee28aa78379a6292fb2dd0cc6dfd3d3359132df46ad8aaabd2b9eee86ac2df8de1f1fe0c1fe5ab2af49d97813bbbd353d860a8dd894af6dc242081842ef009"
+
+    @Test("Consumes an envelope sealed by go-libp2p")
+    func consumeGoEnvelope() throws {
+        let data = try #require(Data(hexString: Self.goEnvelopeHex))
+        let addresses = [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            try Multiaddr("/ip6/::1/udp/4001/quic-v1"),
+        ]
+
+        let (envelope, record) = try Envelope.consume(data, as: PeerRecord.self)
+
+        #expect(envelope.peerID.description == Self.goPeerID)
+        #expect(record.peerID == envelope.peerID)
+        #expect(record.seq == 1_700_000_000)
+        #expect(record.addresses.map(\.multiaddr) == addresses)
+        // Re-marshaling reproduces go's bytes exactly
+        #expect(try envelope.marshal() == data)
+    }
+
+    @Test("A go-libp2p envelope does not verify under another domain")
+    func goEnvelopeWrongDomain() throws {
+        let envelope = try Envelope.unmarshal(try #require(Data(hexString: Self.goEnvelopeHex)))
+
+        #expect(try !envelope.verify(domain: "other-domain"))
+        #expect(throws: EnvelopeError.self) {
+            _ = try envelope.open(domain: "other-domain")
+        }
+    }
+
+    @Test("Swift seals the same record into go's wire layout")
+    func sealMatchesGoLayout() throws {
+        let keyPair = try KeyPair(keyType: .ed25519, rawBytes: Self.goSeed)
+        #expect(keyPair.peerID.description == Self.goPeerID)
+        let record = PeerRecord(peerID: keyPair.peerID, seq: 1_700_000_000, addresses: [
+            AddressInfo(multiaddr: try Multiaddr("/ip4/127.0.0.1/tcp/4001")),
+            AddressInfo(multiaddr: try Multiaddr("/ip6/::1/udp/4001/quic-v1")),
+        ])
+
+        let envelope = try Envelope.seal(record: record, with: keyPair)
+        let data = try envelope.marshal()
+
+        // Ed25519 signatures may be randomized, so compare everything up to
+        // the signature and check the signature verifies
+        let goData = try #require(Data(hexString: Self.goEnvelopeHex))
+        #expect(data.count == goData.count)
+        #expect(data.prefix(goData.count - 64) == goData.prefix(goData.count - 64))
+        #expect(try Envelope.consume(data, as: PeerRecord.self).record == record)
+    }
+
+    @Test("Tampered payload fails verification")
+    func tamperedGoEnvelope() throws {
+        var data = try #require(Data(hexString: Self.goEnvelopeHex))
+        // Last byte of the payload's quic-v1 address (0x03 -> 0x04)
+        let payloadEnd = data.count - 66
+        data[payloadEnd - 1] ^= 0x07
+
+        #expect(throws: EnvelopeError.self) {
+            _ = try Envelope.consume(data, as: PeerRecord.self)
+        }
+    }
 }
@@ -23,6 +23,15 @@ struct MultiaddrClassificationTests {
         #expect(try !Multiaddr("/ip4/10.0.0.1/tcp/1").isLinkLocalIP)
     }
 
+    @Test("IPv4-mapped IPv6 addresses are unmapped")
+    func unmappedIPv4() {
+        #expect(IPAddress.unmappedIPv4("::ffff:192.0.2.1") == "192.0.2.1")
+        #expect(IPAddress.unmappedIPv4("::FFFF:c000:0201") == "192.0.2.1")
+        #expect(IPAddress.unmappedIPv4("::1") == nil)
+        #expect(IPAddress.unmappedIPv4("2001:db8::1") == nil)
+        #expect(IPAddress.unmappedIPv4("192.0.2.1") == nil)
+    }
+
     @Test("Private IPs are classified")
     func privateClassification() throws {
         #expect(try Multiaddr("/ip4/10.0.0.1/tcp/1").isPrivateIP)
@@ -0,0 +1,148 @@
+import Testing
+import Foundation
+@testable import P2PCore
+
+/// Registrations go to the process-wide registry, so every test uses its own
+/// protocol names and codes.
+@Suite("MultiaddrProtocolRegistry")
+struct MultiaddrProtocolRegistryTests {
+
+    /// Hex-encoded fixed-size values, for exercising `.fixed` sizes.
+    private struct HexTranscoder: MultiaddrTranscoder {
+        func bytes(from string: String) throws -> Data {
+            var bytes = Data()
+            var hex = string[...]
+            while hex.count >= 2 {
+                guard let byte = UInt8(hex.prefix(2), radix: 16) else {
+                    throw MultiaddrError.invalidAddress
+                }
+                bytes.append(byte)
+                hex = hex.dropFirst(2)
+            }
+            guard hex.isEmpty else { throw MultiaddrError.invalidAddress }
+            return bytes
+        }
+
+        func string(from bytes: Data) throws -> String {
+            bytes.map { String(format: "%02x", $0) }.joined()
+        }
+    }
+
+    @Test("value-less protocol round-trips through string and bytes")
+    func valuelessProtocol() throws {
+        let codec = MultiaddrProtocolCodec(name: "webrtc", code: 0x0118, size: .none)
+        try MultiaddrProtocolRegistry.shared.register(codec)
+
+        let addr = try Multiaddr("/ip4/192.0.2.1/udp/9090/p2p-circuit/webrtc")
+        #expect(addr.protocols.last == .registered(codec, Data()))
+        #expect(addr.description == "/ip4/192.0.2.1/udp/9090/p2p-circuit/webrtc")
+        #expect(Array(addr.bytes.suffix(2)) == [0x98, 0x02])
+
+        let decoded = try Multiaddr(bytes: addr.bytes)
+        #expect(decoded == addr)
+        #expect(decoded.description == addr.description)
+        #expect(decoded.value(forProtocol: 0x0118) == "")
+    }
+
+    @Test("length-prefixed protocol supports value lookup and composition")
+    func lengthPrefixedProtocol() throws {
+        let codec = MultiaddrProtocolCodec(
+            name: "sni",
+            code: 0x01C1,
+            size: .lengthPrefixed,
+            transcoder: MultiaddrUTF8Transcoder()
+        )
+        try MultiaddrProtocolRegistry.shared.register(codec)
+
+        let base = try Multiaddr("/dns4/example.org/tcp/443")
+        let addr = try base.encapsulate(.registered(codec, Data("example.org".utf8)))
+        #expect(addr.description == "/dns4/example.org/tcp/443/sni/example.org")
+        #expect(try Multiaddr(addr.description) == addr)
+        #expect(try Multiaddr(bytes: addr.bytes) == addr)
+        #expect(addr.value(forProtocol: 0x01C1) == "example.org")
+        #expect(addr.value(forProtocol: MultiaddrProtocol.tcp(0).code) == "443")
+        #expect(addr.decapsulate(code: 0x01C1) == base)
+
+        #expect(throws: MultiaddrError.invalidAddress) {
+            _ = try base.encapsulate(.registered(codec, Data()))
+        }
+    }
+
+    @Test("fixed-size protocol validates value length")
+    func fixedSizeProtocol() throws {
+        let codec = MultiaddrProtocolCodec(
+            name: "x-test-fixed",
+            code: 0x30_0001,
+            size: .fixed(4),
+            transcoder: HexTranscoder()
+        )
+        try MultiaddrProtocolRegistry.shared.register(codec)
+
+        let addr = try Multiaddr("/ip4/192.0.2.1/x-test-fixed/deadbeef")
+        #expect(addr.value(forProtocol: 0x30_0001) == "deadbeef")
+        #expect(try Multiaddr(bytes: addr.bytes) == addr)
+
+        #expect(throws: MultiaddrError.invalidAddress) {
+            _ = try Multiaddr("/x-test-fixed/dead")
+        }
+        // Code varint, then only 3 of the 4 value bytes
+        let truncated = Data([0x81, 0x80, 0xC0, 0x01, 0xDE, 0xAD, 0xBE])
+        #expect(throws: MultiaddrError.invalidAddress) {
+            _ = try Multiaddr(bytes: truncated)
+        }
+    }
+
+    @Test("built-in and conflicting registrations are rejected")
+    func conflicts() throws {
+        let registry = MultiaddrProtocolRegistry()
+        let utf8 = MultiaddrUTF8Transcoder()
+
+        #expect(throws: MultiaddrError.protocolConflict(name: "tcp", code: 0x30_0002)) {
+            try registry.register(MultiaddrProtocolCodec(name: "tcp", code: 0x30_0002, size: .none))
+        }
+        #expect(throws: MultiaddrError.protocolConflict(name: "x-tcp", code: 6)) {
+            try registry.register(MultiaddrProtocolCodec(name: "x-tcp", code: 6, size: .none))
+        }
+
+        let codec = MultiaddrProtocolCodec(name: "x-test-a", code: 0x30_0003, size: .lengthPrefixed, transcoder: utf8)
+        try registry.register(codec)
+        // Registering the same codec again is a no-op
+        try registry.register(codec)
+
+        #expect(throws: MultiaddrError.protocolConflict(name: "x-test-a", code: 0x30_0004)) {
+            try registry.register(MultiaddrProtocolCodec(name: "x-test-a", code: 0x30_0004, size: .none))
+        }
+        #expect(throws: MultiaddrError.protocolConflict(name: "x-test-b", code: 0x30_0003)) {
+            try registry.register(MultiaddrProtocolCodec(name: "x-test-b", code: 0x30_0003, size: .none))
+        }
+        #expect(registry.codec(forName: "x-test-b") == nil)
+        #expect(registry.codec(forCode: 0x30_0003) == codec)
+    }
+
+    @Test("malformed codecs are rejected")
+    func malformedCodecs() {
+        let registry = MultiaddrProtocolRegistry()
+
+        #expect(throws: MultiaddrError.invalidProtocolCodec(name: "x/y")) {
+            try registry.register(MultiaddrProtocolCodec(name: "x/y", code: 0x30_0005, size: .none))
+        }
+        #expect(throws: MultiaddrError.invalidProtocolCodec(name: "x-no-transcoder")) {
+            try registry.register(MultiaddrProtocolCodec(name: "x-no-transcoder", code: 0x30_0006, size: .lengthPrefixed))
+        }
+        #expect(throws: MultiaddrError.invalidProtocolCodec(name: "x-zero")) {
+            try registry.register(
+                MultiaddrProtocolCodec(name: "x-zero", code: 0x30_0007, size: .fixed(0), transcoder: HexTranscoder())
+            )
+        }
+    }
+
+    @Test("unregistered protocols stay unknown")
+    func unregistered() {
+        #expect(throws: MultiaddrError.unknownProtocolName("x-never-registered")) {
+            _ = try Multiaddr("/x-never-registered")
+        }
+        #expect(throws: MultiaddrError.unknownProtocol(0x30_00FF)) {
+            _ = try Multiaddr(bytes: Data([0xFF, 0x81, 0xC0, 0x01]))
+        }
+    }
+}
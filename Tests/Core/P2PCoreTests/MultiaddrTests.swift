@@ -161,6 +161,41 @@ struct MultiaddrTests {
         #expect(base.peerID == nil)
     }
 
+    @Test("Relayed address: value lookups and decapsulation by code")
+    func relayedAddressLookups() throws {
+        let relay = KeyPair.generateEd25519().peerID
+        let target = KeyPair.generateEd25519().peerID
+        let addr = try Multiaddr("/ip4/192.0.2.1/tcp/4001/p2p/\(relay)/p2p-circuit/p2p/\(target)")
+
+        #expect(addr.value(forProtocol: 421) == relay.description)
+        #expect(addr.lastValue(forProtocol: 421) == target.description)
+        #expect(addr.value(forProtocol: 6) == "4001")
+        #expect(addr.value(forProtocol: 290) == "")
+        #expect(addr.value(forProtocol: 273) == nil)
+        #expect(addr.lastValue(forProtocol: 273) == nil)
+
+        let circuit = addr.decapsulate(code: 421)
+        #expect(circuit.description == "/ip4/192.0.2.1/tcp/4001/p2p/\(relay)/p2p-circuit")
+        #expect(circuit.decapsulate(code: 290).description == "/ip4/192.0.2.1/tcp/4001/p2p/\(relay)")
+        #expect(addr.decapsulate(code: 273) == addr)
+    }
+
+    @Test("Decapsulation by address removes the last occurrence")
+    func decapsulateAddress() throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let addr = try Multiaddr("/ip4/192.0.2.1/tcp/4001/p2p/\(peer)/p2p-circuit/p2p/\(peer)")
+
+        #expect(addr.decapsulate(try Multiaddr("/p2p/\(peer)")).description
+            == "/ip4/192.0.2.1/tcp/4001/p2p/\(peer)/p2p-circuit")
+        #expect(addr.decapsulate(try Multiaddr("/tcp/4001")).description == "/ip4/192.0.2.1")
+        #expect(addr.decapsulate(addr).protocols.isEmpty)
+
+        // Not present, or present but not contiguous
+        #expect(addr.decapsulate(try Multiaddr("/tcp/4002")) == addr)
+        #expect(addr.decapsulate(try Multiaddr("/ip4/192.0.2.1/p2p-circuit")) == addr)
+        #expect(addr.decapsulate(Multiaddr(uncheckedProtocols: [])) == addr)
+    }
+
     // MARK: - DoS Protection Tests
 
     @Test("Rejects input exceeding max string size")
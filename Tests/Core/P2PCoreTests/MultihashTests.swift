@@ -52,4 +52,73 @@ struct MultihashTests {
             #expect(error == .digestTooLarge(UInt64(Multihash.maxDigestLength + 1)))
         }
     }
+
+    // MARK: - go-multihash vectors (Sum("foo", code, -1))
+
+    @Test("Digest factories match go-multihash", arguments: [
+        (HashCode.identity, "0003666f6f", "163NSr"),
+        (HashCode.sha1, "11140beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", "5dqx43zNtUUbPj97vJhpHyUUPyrmXG"),
+        (HashCode.sha2_256, "12202c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+         "QmRJzsvyCQyizr73Gmms8ZRtvNxmgqumxc2KUp71dfEmoj"),
+        (HashCode.sha2_512, "1340f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7",
+         "8Vxk6coinP8fKbDk5tvth2zhP3i2pKQYyKcBjG9f63zMnsg34wXkZTVnPMGd1uaxy5uPxvLhaC6Vv8eRg6mmwGy3KC"),
+    ])
+    func goVectors(code: HashCode, hex: String, base58: String) throws {
+        let multihash = try Multihash.sum(Data("foo".utf8), code: code)
+
+        #expect(multihash.bytes == Hex.decode(hex))
+        #expect(multihash.base58String == base58)
+        #expect(try Multihash(base58: base58) == multihash)
+        #expect(try Multihash(hex: hex) == multihash)
+        #expect(try Multihash.cast(multihash.bytes) == multihash)
+    }
+
+    @Test("SHA-256 and SHA-512 factories agree with sum")
+    func factoriesAgreeWithSum() throws {
+        let data = Data("hello multihash".utf8)
+        #expect(try Multihash.sum(data, code: .sha2_256) == Multihash.sha256(data))
+        #expect(try Multihash.sum(data, code: .sha2_512) == Multihash.sha512(data))
+        #expect(Multihash.sha512(data).digest.count == 64)
+    }
+
+    @Test("Encode frames any code, including ones outside HashCode")
+    func encodeArbitraryCode() throws {
+        // go-multihash: Encode([]byte{1, 2, 3}, 0xb220)
+        #expect(Multihash.encode([1, 2, 3], code: HashCode.blake2b_256.rawValue) == [0xa0, 0xe4, 0x02, 0x03, 0x01, 0x02, 0x03])
+        #expect(Multihash.encode([0xff], code: 0x300000) == [0x80, 0x80, 0xc0, 0x01, 0x01, 0xff])
+    }
+
+    @Test("Cast rejects bytes after the digest, as go-multihash does")
+    func castRejectsTrailingBytes() throws {
+        let multihash = Multihash.sha256(Data("foo".utf8))
+
+        // Decoding a prefix stays lenient; casting requires an exact fit
+        #expect(try Multihash(bytes: multihash.bytes + [0x00]) == multihash)
+        #expect(throws: MultihashError.inconsistentLength) {
+            _ = try Multihash.cast(multihash.bytes + [0x00])
+        }
+        #expect(throws: MultihashError.insufficientData) {
+            _ = try Multihash.cast([])
+        }
+    }
+
+    @Test("String forms reject malformed input")
+    func malformedStrings() throws {
+        #expect(throws: MultihashError.invalidEncoding) {
+            _ = try Multihash(base58: "0OIl")
+        }
+        #expect(throws: MultihashError.invalidEncoding) {
+            _ = try Multihash(hex: "12z0")
+        }
+        #expect(throws: MultihashError.unknownCode(0x01)) {
+            _ = try Multihash(hex: "0100")
+        }
+    }
+
+    @Test("Hash functions without a Crypto implementation are refused")
+    func unsupportedSum() throws {
+        #expect(throws: MultihashError.unsupportedHashFunction(.blake3)) {
+            _ = try Multihash.sum(Data("foo".utf8), code: .blake3)
+        }
+    }
 }
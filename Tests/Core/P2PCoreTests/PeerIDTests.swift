@@ -137,6 +137,28 @@ struct KeyPairTests {
         #expect(keyPair.publicKey.rawBytes.count == 65)
     }
 
+    @Test("ECDSA P-256 key in PKIX DER form (go-libp2p encoding)")
+    func ecdsaPKIXPublicKey() throws {
+        let keyPair = KeyPair.generateECDSA()
+        let der = try keyPair.publicKey.ecdsaKey().derRepresentation
+        #expect(der.count == 91)
+
+        let publicKey = try PublicKey(keyType: .ecdsa, rawBytes: der)
+        #expect(publicKey.rawBytes == der)
+        #expect(try publicKey.ecdsaKey().x963Representation == keyPair.publicKey.rawBytes)
+
+        let message = Data("noise-libp2p-static-key:".utf8)
+        #expect(try publicKey.verify(signature: try keyPair.sign(message), for: message))
+
+        // The DER bytes are re-encoded verbatim, so the PeerID is the SHA-256
+        // multihash of the same protobuf the remote hashed
+        let decoded = try PublicKey(protobufEncoded: publicKey.protobufEncoded)
+        #expect(decoded.rawBytes == der)
+        #expect(decoded.peerID == publicKey.peerID)
+        #expect(publicKey.peerID.bytes.prefix(2) == Data([0x12, 0x20]))
+        #expect(publicKey.peerID != keyPair.peerID)
+    }
+
     @Test("Sign and verify ECDSA P-256")
     func signAndVerifyECDSA() throws {
         let keyPair = KeyPair.generateECDSA()
@@ -216,6 +238,241 @@ struct KeyPairTests {
         #expect(isValid)
     }
 
+    // MARK: - Secp256k1
+
+    /// Vector produced by go-libp2p (`crypto.UnmarshalSecp256k1PrivateKey`).
+    private static let secp256k1PrivateKey = "d02dfee63328e6849da0f347beaa2981cc50927f26fa30c9ba88f309826b2227"
+    private static let secp256k1PublicKey = "023aa24f1bd0c097a180a64420e1e790c4e064b7adb8dc0e3552666400dcba6207"
+    private static let secp256k1Signature =
+        "304402201e442511b0fc64fc76cd31bfa07b7bd5f285784810670b808a03c0c9f4ef59070220235e6b9e5c1de6853899" +
+        "bc47b60d65847ab49e42f011adc2bfd2640a5b0e5fa1"
+    private static let secp256k1PeerID = "16Uiu2HAkyNZB1Y6969Rd5D5acBJhdjfPXuhTwQrtXVZbG8EQn6Vc"
+
+    @Test("Secp256k1 key, signature and PeerID match go-libp2p")
+    func secp256k1GoVector() throws {
+        let keyPair = try KeyPair(
+            keyType: .secp256k1,
+            rawBytes: try #require(Data(hexString: Self.secp256k1PrivateKey))
+        )
+        #expect(keyPair.publicKey.rawBytes == Data(hexString: Self.secp256k1PublicKey))
+        #expect(keyPair.peerID.description == Self.secp256k1PeerID)
+        #expect(keyPair.peerID.description.hasPrefix("16Uiu2"))
+
+        // RFC 6979 nonces and low-S normalization make signatures deterministic
+        let message = Data("libp2p secp256k1 test vector".utf8)
+        let signature = try #require(Data(hexString: Self.secp256k1Signature))
+        #expect(try keyPair.sign(message) == signature)
+        #expect(try keyPair.verify(signature: signature, for: message))
+
+        // Identity-encoded: the public key is recoverable from the PeerID
+        let peerID = try PeerID(string: Self.secp256k1PeerID)
+        #expect(try peerID.extractPublicKey() == keyPair.publicKey)
+        #expect(peerID.matches(publicKey: keyPair.publicKey))
+    }
+
+    @Test("Generated secp256k1 key pair signs and verifies")
+    func secp256k1SignAndVerify() throws {
+        let keyPair = KeyPair.generateSecp256k1()
+        #expect(keyPair.keyType == .secp256k1)
+        #expect(keyPair.publicKey.rawBytes.count == 33)
+        #expect(keyPair.privateKey.rawBytes.count == 32)
+
+        let message = Data("Hello, secp256k1!".utf8)
+        let signature = try keyPair.sign(message)
+        #expect(try keyPair.verify(signature: signature, for: message))
+        #expect(try !keyPair.verify(signature: signature, for: Data("Other message".utf8)))
+
+        var tampered = signature
+        tampered[tampered.count - 1] ^= 0x01
+        #expect(try !keyPair.verify(signature: tampered, for: message))
+        #expect(try !keyPair.verify(signature: Data(repeating: 0, count: 64), for: message))
+
+        let restored = try KeyPair(keyType: .secp256k1, rawBytes: keyPair.privateKey.rawBytes)
+        #expect(restored.peerID == keyPair.peerID)
+        #expect(try restored.sign(message) == signature)
+    }
+
+    @Test("Uncompressed secp256k1 public key is accepted")
+    func secp256k1UncompressedPublicKey() throws {
+        // Generator point, privkey = 1
+        let compressed = try #require(Data(hexString:
+            "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+        ))
+        let uncompressed = try #require(Data(hexString:
+            "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" +
+            "483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+        ))
+        var one = Data(repeating: 0, count: 32)
+        one[31] = 1
+        let keyPair = try KeyPair(keyType: .secp256k1, rawBytes: one)
+        #expect(keyPair.publicKey.rawBytes == compressed)
+
+        let message = Data("generator".utf8)
+        let signature = try keyPair.sign(message)
+        let publicKey = try PublicKey(keyType: .secp256k1, rawBytes: uncompressed)
+        #expect(try publicKey.verify(signature: signature, for: message))
+    }
+
+    // MARK: - Seeded keys
+
+    /// Seed 0x01...0x20. The PeerIDs come from go-libp2p:
+    /// `crypto.GenerateEd25519Key(bytes.NewReader(seed))` and
+    /// `crypto.UnmarshalSecp256k1PrivateKey(seed)`.
+    private static let seed = Data((1...32).map { UInt8($0) })
+    private static let seededEd25519PeerID = "12D3KooWJ1TsijH7H5F74hfAD5XishQz3sxrmAtVY37GtNd9CqYf"
+    private static let seededSecp256k1PeerID = "16Uiu2HAm4Ms862Gnqafssgvik4JJ1LuqWMcKNipq4nm2UaoLRbeP"
+
+    @Test("A seed pins the Ed25519 PeerID to go-libp2p's")
+    func seededEd25519() throws {
+        let keyPair = try KeyPair.generateEd25519(fromSeed: Self.seed)
+        #expect(keyPair.peerID.description == Self.seededEd25519PeerID)
+        #expect(try KeyPair.generateEd25519(fromSeed: Self.seed).peerID == keyPair.peerID)
+        #expect(try KeyPair.generateEd25519(fromSeed: Data(repeating: 7, count: 32)).peerID != keyPair.peerID)
+    }
+
+    @Test("A seed pins the secp256k1 PeerID to go-libp2p's")
+    func seededSecp256k1() throws {
+        let keyPair = try KeyPair.generateSecp256k1(fromSeed: Self.seed)
+        #expect(keyPair.peerID.description == Self.seededSecp256k1PeerID)
+        #expect(try KeyPair.generateSecp256k1(fromSeed: Self.seed).peerID == keyPair.peerID)
+    }
+
+    @Test("Seeds of the wrong size or outside the secp256k1 order are rejected")
+    func invalidSeeds() {
+        #expect(throws: PrivateKeyError.invalidKeySize(expected: 32, actual: 31)) {
+            try KeyPair.generateEd25519(fromSeed: Data(repeating: 1, count: 31))
+        }
+        #expect(throws: PrivateKeyError.invalidKeySize(expected: 32, actual: 33)) {
+            try KeyPair.generateSecp256k1(fromSeed: Data(repeating: 1, count: 33))
+        }
+        #expect(throws: PrivateKeyError.invalidKeyData) {
+            try KeyPair.generateSecp256k1(fromSeed: Data(repeating: 0, count: 32))
+        }
+        #expect(throws: PrivateKeyError.invalidKeyData) {
+            try KeyPair.generateSecp256k1(fromSeed: Data(repeating: 0xFF, count: 32))
+        }
+    }
+
+    // MARK: - Private key protobuf
+
+    /// `crypto.MarshalPrivateKey` output for the seeded keys above, and for
+    /// the ECDSA P-256 key with scalar 0x01...0x20 (PeerID from go-libp2p).
+    private static let marshaledEd25519 =
+        "080112400102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20" +
+        "79b5562e8fe654f94078b112e8a98ba7901f853ae695bed7e0e3910bad049664"
+    private static let marshaledSecp256k1 =
+        "080212200102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"
+    private static let marshaledECDSA =
+        "08031279307702010104200102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20" +
+        "a00a06082a8648ce3d030107a14403420004515c3d6eb9e396b904d3feca7f54fdcd0cc1e997bf375dca515ad0a6c3b4" +
+        "035f4536be3a50f318fbf9a5475902a221502bef0d57e08c53b2cc0a56f17d9f9354"
+    private static let marshaledECDSAPeerID = "QmZoiMqf1VcrzjDSGE4o34K13XMZfBm6WeKr1pWixbFr5Z"
+
+    @Test("Private keys marshaled by go-libp2p load with go's PeerID")
+    func goMarshaledPrivateKeys() throws {
+        for (hex, peerID) in [
+            (Self.marshaledEd25519, Self.seededEd25519PeerID),
+            (Self.marshaledSecp256k1, Self.seededSecp256k1PeerID),
+            (Self.marshaledECDSA, Self.marshaledECDSAPeerID),
+        ] {
+            let encoded = try #require(Data(hexString: hex))
+            let keyPair = try KeyPair(protobufEncoded: encoded)
+            #expect(keyPair.peerID.description == peerID)
+            // Saving writes the same bytes go did
+            #expect(keyPair.protobufEncoded == encoded)
+            let signature = try keyPair.sign(Data("identity".utf8))
+            #expect(try keyPair.verify(signature: signature, for: Data("identity".utf8)))
+        }
+    }
+
+    @Test("Swift keys survive a protobuf round trip")
+    func privateKeyRoundTrip() throws {
+        for keyPair in [KeyPair.generateEd25519(), KeyPair.generateSecp256k1()] {
+            let decoded = try KeyPair(protobufEncoded: keyPair.protobufEncoded)
+            #expect(decoded.peerID == keyPair.peerID)
+            #expect(decoded.privateKey.rawBytes == keyPair.privateKey.rawBytes)
+        }
+        let ecdsa = KeyPair.generateECDSA()
+        let decoded = try KeyPair(protobufEncoded: ecdsa.protobufEncoded)
+        #expect(decoded.privateKey.rawBytes == ecdsa.privateKey.rawBytes)
+        #expect(decoded.publicKey.rawBytes.count == 91)
+    }
+
+    @Test("Ed25519 keys accept go's legacy 96-byte form and reject a mismatched public key")
+    func ed25519PrivateKeyForms() throws {
+        let seeded = try #require(Data(hexString: Self.marshaledEd25519))
+        let keyData = seeded.dropFirst(4)
+        let legacy = Data([0x08, 0x01, 0x12, 0x60]) + keyData + keyData.suffix(32)
+        #expect(try PrivateKey(protobufEncoded: legacy).publicKey.peerID.description == Self.seededEd25519PeerID)
+
+        var mismatched = seeded
+        mismatched[mismatched.endIndex - 1] ^= 0x01
+        #expect(throws: PrivateKeyError.invalidKeyData) {
+            _ = try PrivateKey(protobufEncoded: mismatched)
+        }
+        #expect(throws: PrivateKeyError.invalidKeySize(expected: 64, actual: 32)) {
+            _ = try PrivateKey(protobufEncoded: Data([0x08, 0x01, 0x12, 0x20]) + Self.seed)
+        }
+    }
+
+    @Test("Malformed private key protobufs are rejected")
+    func malformedPrivateKeyProtobuf() {
+        #expect(throws: PrivateKeyError.invalidProtobuf) {
+            _ = try PrivateKey(protobufEncoded: Data([0x08, 0x01, 0x12, 0x40, 0x01]))
+        }
+        #expect(throws: PrivateKeyError.unknownKeyType(9)) {
+            _ = try PrivateKey(protobufEncoded: Data([0x08, 0x09, 0x12, 0x01, 0x00]))
+        }
+        #expect(throws: PrivateKeyError.unsupportedKeyType(.rsa)) {
+            _ = try PrivateKey(protobufEncoded: Data([0x08, 0x00, 0x12, 0x01, 0x00]))
+        }
+    }
+
+    // MARK: - RSA
+
+    /// 2048-bit vector produced by go-libp2p (`crypto.GenerateRSAKeyPair`).
+    private static let rsaPublicKey =
+        "30820122300d06092a864886f70d01010105000382010f003082010a0282010100eb77a8b9436e13f7d10997c4f65f5d" +
+        "afa54de619800f7d3e2b4b195ddcb16a5c5c1f4faffe84f2a085a28e2176f1caff0e3974ded4dc2dbcce80dc34784a8e" +
+        "c2f3fd3eb7f5dacb07577db64df0b0f82d8e29fd10ff9eb8992aaedca936cb59a87c14a82fc40c122f793a9a6de364c2" +
+        "c4b79173d095b5c39875d3c232b0789a8fe6c5a7b03a8d038975d767b5432ed4f611426f60d20cbbc50b8125d845915c" +
+        "30a2dacfc58b9ab8be740cf78c738e49cbfbbc7ce7914442e306cdb425ad661b99b2d21173e8082326771ac5ea61b87e" +
+        "319e001d0ac359ca5a61fba31d6f06c4c8775659e15858f54c7e19b3750ae9ca8389a3a284162820c6aa2e53fa79a3e3" +
+        "690203010001"
+    private static let rsaSignature =
+        "852811cf7dfcaa635c3729f37a7068412ac621576de7dda87a6051ea9ba06a3e7c86db612e92ebe89ec7cf71e7f33fbf" +
+        "4312e0a47b2a204365a33a74145ffb5a1f02ad87915c1b705a5e689784cb323e13102a441c8b9267a7b926155f126d15" +
+        "4a789ac49aab9184b2fdeb13bd026fcf9fd5d120218c15e9defe2f25afa447715bf8c5c767d6b490bc2568a97cc93ece" +
+        "2eb649bc857001af19f4383204cabb6c0eb31e8ef071f2c4a7923999c53020e4b680c0002bea096d05a4bc98d23aedf5" +
+        "fba4e8643eee89427b57b5bfd54a1f43439740eb3de42cb4f0843b35cfa22b97842f5275d6f278441719a7b0b831cf7f" +
+        "d1e80386d4e5b7e0a082089574c7f416"
+    private static let rsaPeerID = "QmQdF4cBguWcCVj8HbJFWkJJEfK5nM44E2CCtT8FKaFuxz"
+
+    @Test("RSA public key, signature and PeerID match go-libp2p")
+    func rsaGoVector() throws {
+        let publicKey = try PublicKey(
+            keyType: .rsa,
+            rawBytes: try #require(Data(hexString: Self.rsaPublicKey))
+        )
+        #expect(publicKey.peerID.description == Self.rsaPeerID)
+
+        let message = Data("libp2p rsa test vector".utf8)
+        let signature = try #require(Data(hexString: Self.rsaSignature))
+        #expect(try publicKey.verify(signature: signature, for: message))
+        #expect(try !publicKey.verify(signature: signature, for: Data("other".utf8)))
+
+        let decoded = try PublicKey(protobufEncoded: publicKey.protobufEncoded)
+        #expect(decoded == publicKey)
+        #expect(try PeerID(string: Self.rsaPeerID).matches(publicKey: decoded))
+    }
+
+    @Test("RSA private keys are not supported")
+    func rsaPrivateKeyUnsupported() {
+        #expect(throws: PrivateKeyError.unsupportedKeyType(.rsa)) {
+            _ = try PrivateKey(keyType: .rsa, rawBytes: Data(repeating: 0x01, count: 128))
+        }
+    }
+
     // MARK: - Comparable Tests
 
     @Test("PeerID Comparable is deterministic")
@@ -256,19 +513,26 @@ struct KeyPairTests {
         }
     }
 
-    // MARK: - Unsupported key types fail fast at derivation
+    // MARK: - Malformed keys fail fast at derivation
 
-    @Test("Unsupported key types are rejected at PublicKey construction")
-    func unsupportedKeyTypesRejectedAtConstruction() {
-        // RSA / secp256k1 are unsupported by design: they fail at construction
-        // (the earliest point) rather than producing an unverifiable key whose
-        // PeerID can be derived but never validated.
-        #expect(throws: PublicKeyError.self) {
-            _ = try PublicKey(keyType: .secp256k1, rawBytes: Data(repeating: 0x02, count: 33))
+    @Test("Malformed secp256k1 / RSA keys are rejected at PublicKey construction")
+    func malformedKeysRejectedAtConstruction() {
+        // Keys are fully parsed at construction (the earliest point) rather
+        // than producing an unverifiable key whose PeerID can be derived but
+        // never validated.
+        #expect(throws: PublicKeyError.invalidKeyData) {
+            // x is not below the field prime
+            _ = try PublicKey(keyType: .secp256k1, rawBytes: Data([0x02]) + Data(repeating: 0xFF, count: 32))
+        }
+        #expect(throws: PublicKeyError.invalidKeySize(expected: 33, actual: 32)) {
+            _ = try PublicKey(keyType: .secp256k1, rawBytes: Data(repeating: 0x02, count: 32))
         }
-        #expect(throws: PublicKeyError.self) {
+        #expect(throws: PublicKeyError.invalidKeyData) {
             _ = try PublicKey(keyType: .rsa, rawBytes: Data(repeating: 0x01, count: 128))
         }
+        #expect(throws: PrivateKeyError.invalidKeyData) {
+            _ = try PrivateKey(keyType: .secp256k1, rawBytes: Data(repeating: 0, count: 32))
+        }
     }
 
     @Test("Supported key types still derive a PeerID")
@@ -278,6 +542,80 @@ struct KeyPairTests {
         // ECDSA P-256 also derives a PeerID.
         let ecdsa = KeyPair.generateECDSA().publicKey
         _ = PeerID(publicKey: ecdsa)
+        // secp256k1 derives an identity-encoded PeerID.
+        let secp256k1 = KeyPair.generateSecp256k1().publicKey
+        #expect(PeerID(publicKey: secp256k1).multihash.code == .identity)
+    }
+
+    // MARK: - Multihash form (go-libp2p vectors)
+
+    /// Ed25519 PeerID produced by go-libp2p (`peer.IDFromPublicKey`): the
+    /// 36-byte protobuf key is inlined with the identity multihash.
+    private static let goEd25519PublicKey = "dfa59c964f9687212405c63f20e2357e11b24bd5c418521234dece14bb8b4cf5"
+    private static let goEd25519PeerID = "12D3KooWQsPZ3FdanMVGJuCTS29GiV8ZCXHog6mGiBQTaaafYQ5z"
+    private static let goEd25519PeerIDBytes = "002408011220dfa59c964f9687212405c63f20e2357e11b24bd5c418521234dece14bb8b4cf5"
+
+    /// ECDSA P-256 PeerID produced by go-libp2p: the 95-byte protobuf key
+    /// (PKIX DER) is over the inline limit and hashed with SHA-256.
+    private static let goECDSAPublicKey =
+        "3059301306072a8648ce3d020106082a8648ce3d03010703420004a12392f35f83e4d0439caedc3098c32f4d6dd9e2af" +
+        "b83713463d27c534e1b141ebdcc0cd39473f5b44862d6e6d47e5dad0239c65d625d9b07ee44be84f17fb34"
+    private static let goECDSAPeerID = "QmXZt9NiqWeZpdDBBUaJyc2Kb6ot7hrrvAEsC5zKFb43QP"
+    private static let goECDSAPeerIDBytes = "1220891cecb362157af21b6914ae0a5d729c08906e7880b6ec31fdffb79e93aee1ec"
+
+    @Test("Ed25519 PeerID uses the identity multihash, as go-libp2p does")
+    func ed25519IdentityMultihashGoVector() throws {
+        let publicKey = try PublicKey(
+            keyType: .ed25519,
+            rawBytes: try #require(Data(hexString: Self.goEd25519PublicKey))
+        )
+        #expect(publicKey.protobufEncoded.count == 36)
+
+        let derived = publicKey.peerID
+        #expect(derived.multihash.code == .identity)
+        #expect(derived.bytes == Data(hexString: Self.goEd25519PeerIDBytes))
+        #expect(derived.description == Self.goEd25519PeerID)
+
+        let decoded = try PeerID(string: Self.goEd25519PeerID)
+        #expect(decoded == derived)
+        #expect(decoded.description == Self.goEd25519PeerID)
+        #expect(try decoded.extractPublicKey() == publicKey)
+        #expect(decoded.matches(publicKey: publicKey))
+    }
+
+    @Test("ECDSA PeerID uses the SHA-256 multihash, as go-libp2p does")
+    func ecdsaSHA256MultihashGoVector() throws {
+        let publicKey = try PublicKey(
+            keyType: .ecdsa,
+            rawBytes: try #require(Data(hexString: Self.goECDSAPublicKey))
+        )
+        #expect(publicKey.protobufEncoded.count == 95)
+
+        let derived = publicKey.peerID
+        #expect(derived.multihash.code == .sha2_256)
+        #expect(derived.bytes == Data(hexString: Self.goECDSAPeerIDBytes))
+        #expect(derived.description == Self.goECDSAPeerID)
+
+        let decoded = try PeerID(string: Self.goECDSAPeerID)
+        #expect(decoded == derived)
+        #expect(decoded.description == Self.goECDSAPeerID)
+        #expect(try decoded.extractPublicKey() == nil)
+        #expect(decoded.matches(publicKey: publicKey))
+    }
+
+    @Test("Identity encoding is chosen by encoded length at the 42-byte limit")
+    func identityEncodingLengthBoundary() {
+        #expect(PeerIDFraming.usesIdentityEncoding(encodedLength: 36))
+        #expect(PeerIDFraming.usesIdentityEncoding(encodedLength: 42))
+        #expect(!PeerIDFraming.usesIdentityEncoding(encodedLength: 43))
+    }
+
+    @Test("A SHA-256 PeerID does not match a key that go-libp2p would inline")
+    func hashedPeerIDRejectsInlineKey() {
+        let publicKey = KeyPair.generateEd25519().publicKey
+        let hashed = PeerID(multihash: Multihash.sha256(publicKey.protobufEncoded))
+        #expect(!hashed.matches(publicKey: publicKey))
+        #expect(publicKey.peerID.matches(publicKey: publicKey))
     }
 
     @Test("Multibase z-prefix is stripped for base58btc PeerIDs")
@@ -0,0 +1,111 @@
+import Testing
+import Synchronization
+@testable import P2PCore
+
+@Suite("SubsystemLogger", .serialized)
+struct SubsystemLoggerTests {
+
+    /// A swift-log backend that keeps every message it is handed.
+    private struct RecordingHandler: LogHandler {
+        struct Entry: Sendable {
+            let level: Logger.Level
+            let message: String
+            let metadata: Logger.Metadata
+        }
+
+        final class Storage: Sendable {
+            let entries = Mutex<[Entry]>([])
+        }
+
+        let storage: Storage
+        var metadata: Logger.Metadata = [:]
+        // The backend's own threshold; SubsystemLogger overrides it per message
+        var logLevel: Logger.Level = .critical
+
+        subscript(metadataKey key: String) -> Logger.Metadata.Value? {
+            get { metadata[key] }
+            set { metadata[key] = newValue }
+        }
+
+        func log(
+            level: Logger.Level,
+            message: Logger.Message,
+            metadata explicitMetadata: Logger.Metadata?,
+            source: String,
+            file: String,
+            function: String,
+            line: UInt
+        ) {
+            let merged = metadata.merging(explicitMetadata ?? [:]) { _, new in new }
+            storage.entries.withLock {
+                $0.append(Entry(level: level, message: "\(message)", metadata: merged))
+            }
+        }
+    }
+
+    private func makeLogger(_ subsystem: LogSubsystem) -> (SubsystemLogger, RecordingHandler.Storage) {
+        let storage = RecordingHandler.Storage()
+        let logger = Logger(label: subsystem.label) { _ in RecordingHandler(storage: storage) }
+        return (SubsystemLogger(subsystem, logger: logger), storage)
+    }
+
+    @Test("Levels are set per subsystem")
+    func independentLevels() {
+        defer { P2PLogging.resetLevels() }
+        let (yamux, yamuxLog) = makeLogger(.yamux)
+        let (noise, noiseLog) = makeLogger(.noise)
+
+        P2PLogging.setLevel(.trace, for: .yamux)
+        yamux.trace("frame")
+        noise.trace("handshake")
+        noise.info("handshake done")
+
+        #expect(yamuxLog.entries.withLock { $0.map(\.message) } == ["frame"])
+        #expect(noiseLog.entries.withLock { $0.map(\.message) } == ["handshake done"])
+        #expect(P2PLogging.level(for: .noise) == P2PLogging.defaultLevel)
+    }
+
+    @Test("Structured fields become metadata")
+    func structuredFields() throws {
+        defer { P2PLogging.resetLevels() }
+        P2PLogging.setLevel(.debug, for: .swarm)
+        let (swarm, log) = makeLogger(.swarm)
+        let peer = PeerID(publicKey: KeyPair.generateEd25519().publicKey)
+
+        swarm.debug("Inbound stream opened", peer: peer, protocol: "/ipfs/ping/1.0.0", connection: 7, metadata: ["stream": "3"])
+
+        let entry = try #require(log.entries.withLock { $0.first })
+        #expect(entry.level == .debug)
+        #expect(entry.metadata["subsystem"] == "swarm")
+        #expect(entry.metadata["peer"] == "\(peer)")
+        #expect(entry.metadata["protocol"] == "/ipfs/ping/1.0.0")
+        #expect(entry.metadata["conn"] == "7")
+        #expect(entry.metadata["stream"] == "3")
+    }
+
+    @Test("A spec sets a global level with per-subsystem overrides")
+    func configureFromSpec() throws {
+        defer { P2PLogging.resetLevels() }
+        try P2PLogging.configure("warning, yamux=trace,DHT=debug")
+
+        #expect(P2PLogging.level(for: .yamux) == .trace)
+        #expect(P2PLogging.level(for: .dht) == .debug)
+        #expect(P2PLogging.level(for: .swarm) == .warning)
+        #expect(P2PLogging.level(for: .identify) == .warning)
+    }
+
+    @Test("An invalid spec changes nothing")
+    func invalidSpec() {
+        defer { P2PLogging.resetLevels() }
+        P2PLogging.setLevel(.error, for: .relay)
+
+        #expect(throws: P2PLoggingError.unknownSubsystem("mdns")) {
+            try P2PLogging.configure("debug,mdns=trace")
+        }
+        #expect(throws: P2PLoggingError.unknownLevel("yamux=loud")) {
+            try P2PLogging.configure("yamux=loud")
+        }
+        #expect(P2PLogging.level(for: .relay) == .error)
+        #expect(P2PLogging.level(for: .yamux) == P2PLogging.defaultLevel)
+    }
+}
@@ -0,0 +1,120 @@
+import Testing
+import Foundation
+@testable import P2PDiscovery
+@testable import P2PCore
+
+@Suite("PeersManifest")
+struct PeersManifestTests {
+
+    private func makePeerID() -> PeerID {
+        KeyPair.generateEd25519().peerID
+    }
+
+    private func tempFile() -> URL {
+        FileManager.default.temporaryDirectory
+            .appendingPathComponent("PeersManifestTests-\(UUID().uuidString).json")
+    }
+
+    private func write(_ text: String) throws -> URL {
+        let url = tempFile()
+        try Data(text.utf8).write(to: url)
+        return url
+    }
+
+    @Test("imports a JSON array manifest")
+    func importArray() async throws {
+        let peer = makePeerID()
+        let url = try write("""
+            [{"peerID": "\(peer)", "addrs": ["/ip4/127.0.0.1/tcp/4001", "/ip4/127.0.0.1/udp/4001/quic-v1"]}]
+            """)
+        defer { try? FileManager.default.removeItem(at: url) }
+
+        let store = MemoryPeerStore()
+        let imported = try await store.importPeers(from: url)
+        let addresses = await store.addresses(for: peer)
+
+        #expect(imported == 1)
+        #expect(Set(addresses) == [
+            try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            try Multiaddr("/ip4/127.0.0.1/udp/4001/quic-v1"),
+        ])
+    }
+
+    @Test("imports JSON lines with the given TTL")
+    func importLines() async throws {
+        let peerA = makePeerID()
+        let peerB = makePeerID()
+        let url = try write("""
+            {"peerID": "\(peerA)", "addrs": ["/ip4/10.0.0.1/tcp/4001"]}
+
+            {"peerID": "\(peerB)", "addrs": ["/ip4/10.0.0.2/tcp/4001"]}
+            """)
+        defer { try? FileManager.default.removeItem(at: url) }
+
+        let store = MemoryPeerStore()
+        let imported = try await store.importPeers(from: url, ttl: .seconds(5))
+        #expect(imported == 2)
+
+        let addr = try Multiaddr("/ip4/10.0.0.2/tcp/4001")
+        let record = await store.addressRecord(addr, for: peerB)
+        let expiresAt = try #require(record?.expiresAt)
+        #expect(expiresAt <= ContinuousClock.now + .seconds(5))
+    }
+
+    @Test("reports the line of a malformed entry and adds nothing")
+    func malformedLine() async throws {
+        let peer = makePeerID()
+        let url = try write("""
+            {"peerID": "\(peer)", "addrs": ["/ip4/10.0.0.1/tcp/4001"]}
+            {"peerID": "\(peer)"
+            """)
+        defer { try? FileManager.default.removeItem(at: url) }
+
+        let store = MemoryPeerStore()
+        await #expect(throws: PeersManifestError.malformedEntry(line: 2)) {
+            try await store.importPeers(from: url)
+        }
+        let count = await store.peerCount()
+        #expect(count == 0)
+    }
+
+    @Test("rejects an invalid address")
+    func invalidAddress() async throws {
+        let peer = makePeerID()
+        let url = try write("""
+            [{"peerID": "\(peer)", "addrs": ["not-a-multiaddr"]}]
+            """)
+        defer { try? FileManager.default.removeItem(at: url) }
+
+        await #expect(throws: PeersManifestError.invalidAddress(peerID: peer.description, address: "not-a-multiaddr")) {
+            try await MemoryPeerStore().importPeers(from: url)
+        }
+    }
+
+    @Test("export round-trips through import")
+    func exportRoundTrip() async throws {
+        let url = tempFile()
+        defer { try? FileManager.default.removeItem(at: url) }
+
+        let peerA = makePeerID()
+        let peerB = makePeerID()
+        let addrA = try Multiaddr("/ip4/10.0.0.1/tcp/4001")
+        let addrB = try Multiaddr("/ip6/::1/tcp/4002")
+
+        let source = MemoryPeerStore()
+        await source.addAddresses([addrA], for: peerA, ttl: nil)
+        await source.addAddresses([addrB], for: peerB, ttl: nil)
+        try await source.exportPeers(to: url)
+
+        let entries = try JSONDecoder().decode([PeersManifestEntry].self, from: Data(contentsOf: url))
+        #expect(entries.map(\.peerID) == [peerA.description, peerB.description].sorted())
+
+        let restored = MemoryPeerStore()
+        let imported = try await restored.importPeers(from: url)
+        let restoredA = await restored.addresses(for: peerA)
+        let restoredB = await restored.addresses(for: peerB)
+        #expect(imported == 2)
+        #expect(restoredA == [addrA])
+        #expect(restoredB == [addrB])
+    }
+}
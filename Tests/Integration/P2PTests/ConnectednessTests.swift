@@ -0,0 +1,110 @@
+/// ConnectednessTests - Per-peer connectedness and its change events
+///
+/// Tests the full stack: MemoryTransport + Plaintext + Yamux + Node
+
+import Testing
+import Foundation
+import Synchronization
+@testable import P2P
+@testable import P2PCore
+@testable import P2PTransport
+@testable import P2PTransportMemory
+@testable import P2PSecurity
+@testable import P2PSecurityPlaintext
+@testable import P2PMux
+@testable import P2PMuxYamux
+
+@Suite("Connectedness Tests", .serialized)
+struct ConnectednessTests {
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: .init(limits: .development, reconnectionPolicy: .disabled, idleTimeout: .seconds(300)),
+            healthCheck: nil
+        ))
+    }
+
+    /// Collects the `peerConnectednessChanged` values emitted for `peer`.
+    private func recordChanges(of node: Node, for peer: PeerID) -> (Mutex<[Connectedness]>, Task<Void, Never>) {
+        let changes = Mutex<[Connectedness]>([])
+        let task = Task { @Sendable in
+            for await event in node.events {
+                if case .peerConnectednessChanged(let changed, let connectedness) = event, changed == peer {
+                    changes.withLock { $0.append(connectedness) }
+                }
+            }
+        }
+        return (changes, task)
+    }
+
+    private func waitFor(_ changes: Mutex<[Connectedness]>, count: Int) async throws {
+        for _ in 0..<100 where changes.withLock({ $0.count }) < count {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+    }
+
+    @Test("An unknown peer is notConnected")
+    func unknownPeer() async throws {
+        let node = makeNode(hub: MemoryHub())
+        try await node.start()
+
+        let stranger = KeyPair.generateEd25519().peerID
+        #expect(await node.connectedness(of: stranger) == .notConnected)
+
+        try await node.shutdown()
+    }
+
+    @Test("Connecting and disconnecting move a peer to connected, then canConnect", .timeLimit(.minutes(1)))
+    func connectThenDisconnect() async throws {
+        let hub = MemoryHub()
+        let serverAddr = Multiaddr.memory(id: "connectedness-server")
+        let server = makeNode(hub: hub, listenAddress: serverAddr)
+        let client = makeNode(hub: hub)
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+
+        let (changes, eventTask) = recordChanges(of: client, for: serverPeerID)
+        defer { eventTask.cancel() }
+
+        _ = try await client.connect(to: serverAddr)
+        try await waitFor(changes, count: 1)
+        #expect(await client.connectedness(of: serverPeerID) == .connected)
+        #expect(changes.withLock { $0 } == [.connected])
+
+        await client.disconnect(from: serverPeerID)
+        try await waitFor(changes, count: 2)
+        #expect(await client.connectedness(of: serverPeerID) == .canConnect)
+        #expect(changes.withLock { $0 } == [.connected, .canConnect])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A failed dial makes a peer cannotConnect while its backoff lasts", .timeLimit(.minutes(1)))
+    func failedDial() async throws {
+        let hub = MemoryHub()
+        let client = makeNode(hub: hub)
+        try await client.start()
+
+        let missing = KeyPair.generateEd25519().peerID
+        let (changes, eventTask) = recordChanges(of: client, for: missing)
+        defer { eventTask.cancel() }
+
+        await #expect(throws: (any Error).self) {
+            try await client.connect(to: try Multiaddr("/memory/connectedness-missing/p2p/\(missing)"))
+        }
+        try await waitFor(changes, count: 1)
+        #expect(await client.connectedness(of: missing) == .cannotConnect)
+        #expect(changes.withLock { $0 } == [.cannotConnect])
+
+        try await client.shutdown()
+        hub.reset()
+    }
+}
@@ -0,0 +1,159 @@
+/// ConnectionConflictResolverTests - Tests for duplicate connection resolution
+
+import Testing
+import Foundation
+import P2PCore
+@testable import P2P
+
+@Suite("ConnectionConflictResolver Tests")
+struct ConnectionConflictResolverTests {
+
+    private let resolver = DeterministicConnectionConflictResolver()
+
+    private func orderedPeers() -> (lower: PeerID, higher: PeerID) {
+        let a = PeerID(publicKey: KeyPair.generateEd25519().publicKey)
+        let b = PeerID(publicKey: KeyPair.generateEd25519().publicKey)
+        return a < b ? (a, b) : (b, a)
+    }
+
+    private func makeManaged(
+        peer: PeerID,
+        direction: ConnectionDirection,
+        securityRole: SecurityRole? = nil,
+        isLimited: Bool = false,
+        connectedAt: ContinuousClock.Instant = .now
+    ) -> ManagedConnection {
+        ManagedConnection(
+            id: ConnectionID(),
+            peer: peer,
+            address: try! Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            direction: direction,
+            assignedSecurityRole: securityRole,
+            connection: nil,
+            state: .connected,
+            retryCount: 0,
+            lastActivity: connectedAt,
+            connectedAt: connectedAt,
+            tags: [],
+            isProtected: false,
+            isLimited: isLimited
+        )
+    }
+
+    @Test("Lower peer keeps its outbound connection")
+    func lowerPeerKeepsOutbound() {
+        let (lower, higher) = orderedPeers()
+        let outbound = makeManaged(peer: higher, direction: .outbound)
+        let inbound = makeManaged(peer: higher, direction: .inbound)
+
+        let losers = resolver.duplicateConnections(
+            from: [inbound, outbound],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        #expect(losers.map(\.id) == [inbound.id])
+    }
+
+    @Test("Higher peer keeps its inbound connection")
+    func higherPeerKeepsInbound() {
+        let (lower, higher) = orderedPeers()
+        let outbound = makeManaged(peer: lower, direction: .outbound)
+        let inbound = makeManaged(peer: lower, direction: .inbound)
+
+        let losers = resolver.duplicateConnections(
+            from: [outbound, inbound],
+            localPeerID: higher,
+            remotePeerID: lower
+        )
+        #expect(losers.map(\.id) == [outbound.id])
+    }
+
+    @Test("Assigned role overrides direction")
+    func assignedRoleOverridesDirection() {
+        let outbound = makeManaged(peer: orderedPeers().lower, direction: .outbound, securityRole: .responder)
+        #expect(outbound.securityRole == .responder)
+        #expect(makeManaged(peer: outbound.peer, direction: .outbound).securityRole == .initiator)
+        #expect(makeManaged(peer: outbound.peer, direction: .inbound).securityRole == .responder)
+    }
+
+    @Test("Both peers keep the same simultaneous-connect connection")
+    func simultaneousConnectConverges() {
+        let (lower, higher) = orderedPeers()
+
+        // The hole punch made one TCP connection on which both sides dialed;
+        // the lower peer was assigned initiator, the higher peer responder.
+        // Each side also has an ordinary connection from the other.
+        let lowerSimOpen = makeManaged(peer: higher, direction: .outbound, securityRole: .initiator)
+        let lowerInbound = makeManaged(peer: higher, direction: .inbound)
+        let higherSimOpen = makeManaged(peer: lower, direction: .outbound, securityRole: .responder)
+        let higherOutbound = makeManaged(peer: lower, direction: .outbound)
+
+        let lowerLosers = resolver.duplicateConnections(
+            from: [lowerInbound, lowerSimOpen],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        let higherLosers = resolver.duplicateConnections(
+            from: [higherOutbound, higherSimOpen],
+            localPeerID: higher,
+            remotePeerID: lower
+        )
+
+        #expect(lowerLosers.map(\.id) == [lowerInbound.id])
+        #expect(higherLosers.map(\.id) == [higherOutbound.id])
+    }
+
+    @Test("Without a winning role the oldest connection survives")
+    func fallbackKeepsOldest() {
+        let (lower, higher) = orderedPeers()
+        let older = makeManaged(peer: higher, direction: .inbound, connectedAt: .now - .seconds(5))
+        let newer = makeManaged(peer: higher, direction: .inbound)
+
+        let losers = resolver.duplicateConnections(
+            from: [newer, older],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        #expect(losers.map(\.id) == [newer.id])
+    }
+
+    @Test("A direct connection replaces relay connections")
+    func directBeatsRelay() {
+        let (lower, higher) = orderedPeers()
+        // The relay connection has the winning direction; it still loses
+        let relay = makeManaged(peer: higher, direction: .outbound, isLimited: true)
+        let direct = makeManaged(peer: higher, direction: .inbound)
+
+        let losers = resolver.duplicateConnections(
+            from: [relay, direct],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        #expect(losers.map(\.id) == [relay.id])
+    }
+
+    @Test("Relay-only duplicates are resolved by role")
+    func relayOnlyDuplicates() {
+        let (lower, higher) = orderedPeers()
+        let outbound = makeManaged(peer: higher, direction: .outbound, isLimited: true)
+        let inbound = makeManaged(peer: higher, direction: .inbound, isLimited: true)
+
+        let losers = resolver.duplicateConnections(
+            from: [inbound, outbound],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        #expect(losers.map(\.id) == [inbound.id])
+    }
+
+    @Test("A single connection is never a duplicate")
+    func singleConnection() {
+        let (lower, higher) = orderedPeers()
+        let losers = resolver.duplicateConnections(
+            from: [makeManaged(peer: higher, direction: .inbound)],
+            localPeerID: lower,
+            remotePeerID: higher
+        )
+        #expect(losers.isEmpty)
+    }
+}
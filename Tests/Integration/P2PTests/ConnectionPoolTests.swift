@@ -532,6 +532,137 @@ struct ConnectionPoolTests {
         #expect(trimmed.first?.peer == oldPeer)
     }
 
+    // MARK: - Liveness Scoring
+
+    @Test("Liveness samples fall off above the latency target and fold in as an EWMA")
+    func livenessScoringMath() {
+        let policy = LivenessScoringPolicy(latencyTarget: .milliseconds(100), decay: 0.5)
+
+        #expect(policy.sample(rtt: .milliseconds(20)) == 1)
+        #expect(policy.sample(rtt: .milliseconds(400)) == 0.25)
+        #expect(policy.sample(rtt: nil) == 0)
+
+        #expect(policy.updated(score: 1, rtt: .milliseconds(50)) == 1)
+        #expect(policy.updated(score: 1, rtt: .milliseconds(400)) == 0.625)
+        #expect(policy.updated(score: 1, rtt: nil) == 0.5)
+        #expect(PoolConfiguration(livenessScoring: policy) != PoolConfiguration())
+    }
+
+    @Test("recordLivenessProbe updates the score and last RTT")
+    func recordLivenessProbeUpdatesScore() {
+        let pool = makePool()
+        let policy = LivenessScoringPolicy(latencyTarget: .milliseconds(100), decay: 0.5)
+        let (peer, addr, conn) = makeMockConnection()
+        let id = pool.add(conn, for: peer, address: addr, direction: .outbound)
+
+        #expect(pool.recordLivenessProbe(id, rtt: .milliseconds(50), policy: policy) == 1)
+        #expect(pool.managedConnection(id)?.lastPingRTT == .milliseconds(50))
+
+        #expect(pool.recordLivenessProbe(id, rtt: nil, policy: policy) == 0.5)
+        // A failed ping keeps the last successful RTT
+        #expect(pool.managedConnection(id)?.lastPingRTT == .milliseconds(50))
+
+        #expect(pool.recordLivenessProbe(ConnectionID(), rtt: nil, policy: policy) == nil)
+    }
+
+    @Test("A slow peer is trimmed before a fast one at the high watermark")
+    func trimPrefersSlowPeer() async throws {
+        let pool = makePool(highWatermark: 1, lowWatermark: 1, gracePeriod: .zero)
+        let policy = LivenessScoringPolicy(latencyTarget: .milliseconds(50))
+
+        // The fast peer has the older activity, so without scoring it would go first
+        let (fastPeer, fastAddr, fastConn) = makeMockConnection()
+        let fastID = pool.add(fastConn, for: fastPeer, address: fastAddr, direction: .outbound)
+        do {
+            try await Task.sleep(for: .milliseconds(5))
+        } catch {
+            Issue.record("Unexpected cancellation during liveness trim test sleep: \(error)")
+        }
+        let (slowPeer, slowAddr, slowConn) = makeMockConnection()
+        let slowID = pool.add(slowConn, for: slowPeer, address: slowAddr, direction: .outbound)
+
+        for _ in 0..<3 {
+            pool.recordLivenessProbe(fastID, rtt: .milliseconds(10), policy: policy)
+            pool.recordLivenessProbe(slowID, rtt: .milliseconds(800), policy: policy)
+        }
+
+        let report = pool.trimReport()
+        let byPeer = Dictionary(uniqueKeysWithValues: report.candidates.map { ($0.peer, $0) })
+        let fast = try #require(byPeer[fastPeer])
+        let slow = try #require(byPeer[slowPeer])
+        #expect(fast.effectiveScore == 1)
+        #expect(fast.lastPingRTT == .milliseconds(10))
+        #expect(slow.effectiveScore < fast.effectiveScore)
+        #expect(slow.lastPingRTT == .milliseconds(800))
+        #expect(slow.trimRank == 1)
+
+        let trimmed = pool.trimIfNeeded()
+        #expect(trimmed.map(\.peer) == [slowPeer])
+        #expect(pool.isConnected(to: fastPeer))
+    }
+
+    @Test("Tags still outweigh liveness when trimming")
+    func trimKeepsTaggedFailingPeerOverUntaggedFastPeer() {
+        let pool = makePool(highWatermark: 1, lowWatermark: 1, gracePeriod: .zero)
+        let policy = LivenessScoringPolicy()
+
+        let (taggedPeer, taggedAddr, taggedConn) = makeMockConnection()
+        let taggedID = pool.add(taggedConn, for: taggedPeer, address: taggedAddr, direction: .outbound)
+        pool.tag(taggedPeer, with: "relay")
+        pool.recordLivenessProbe(taggedID, rtt: nil, policy: policy)
+
+        let (plainPeer, plainAddr, plainConn) = makeMockConnection()
+        pool.add(plainConn, for: plainPeer, address: plainAddr, direction: .outbound)
+
+        let trimmed = pool.trimIfNeeded()
+        #expect(trimmed.map(\.peer) == [plainPeer])
+    }
+
+    // MARK: - Limited connections
+
+    @Test("connection(to:) prefers a direct connection over a relay one")
+    func connectionPrefersDirect() {
+        let pool = makePool(maxPerPeer: 3)
+        let (remotePeer, addr, relayConn) = makeMockConnection()
+        let directConn = MockMuxedConnection(localPeer: randomPeerID(), remotePeer: remotePeer, address: addr)
+
+        pool.add(relayConn, for: remotePeer, address: addr, direction: .outbound, isLimited: true)
+        #expect(pool.isLimitedConnection(to: remotePeer))
+        #expect(!pool.hasUnlimitedConnection(to: remotePeer))
+
+        pool.add(directConn, for: remotePeer, address: addr, direction: .inbound)
+        #expect((pool.connection(to: remotePeer) as? MockMuxedConnection) === directConn)
+        #expect(!pool.isLimitedConnection(to: remotePeer))
+        #expect(pool.hasUnlimitedConnection(to: remotePeer))
+    }
+
+    // MARK: - Draining connections
+
+    @Test("A draining connection takes no new streams and frees its per-peer slot")
+    func drainingConnectionIsSkipped() {
+        let pool = makePool(maxPerPeer: 1)
+        let (remotePeer, addr, drainingConn) = makeMockConnection()
+        let drainingID = pool.add(drainingConn, for: remotePeer, address: addr, direction: .outbound)
+        #expect(!pool.canConnectTo(peer: remotePeer))
+
+        let marked = pool.markDraining(drainingID)
+        #expect(marked?.id == drainingID)
+        #expect(pool.markDraining(drainingID) == nil)
+
+        // Still connected for its open streams, but not used or counted
+        #expect(pool.isConnected(to: remotePeer))
+        #expect(pool.connection(to: remotePeer) == nil)
+        #expect(!pool.hasUnlimitedConnection(to: remotePeer))
+        #expect(pool.connectedManagedConnections(for: remotePeer).isEmpty)
+        #expect(pool.canConnectTo(peer: remotePeer))
+
+        let replacement = MockMuxedConnection(localPeer: randomPeerID(), remotePeer: remotePeer, address: addr)
+        let replacementID = pool.addIfPermitted(replacement, for: remotePeer, address: addr, direction: .outbound)
+        #expect(replacementID != nil)
+        #expect((pool.connection(to: remotePeer) as? MockMuxedConnection) === replacement)
+        #expect(pool.hasUnlimitedConnection(to: remotePeer))
+    }
+
     // MARK: - connectedManagedConnections
 
     @Test("connectedManagedConnections returns only connected entries")
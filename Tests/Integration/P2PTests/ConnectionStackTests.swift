@@ -0,0 +1,158 @@
+/// ConnectionStackTests - Negotiated transport, security and muxer on connections
+///
+/// Tests that connections built by the upgrader report the stack both sides
+/// agreed on, keep the muxer's own connection reachable, and how the
+/// transport name is derived from the remote address.
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PRuntime
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Connection Stack Tests", .serialized)
+struct ConnectionStackTests {
+
+    @Test("Both sides report the negotiated stack", .timeLimit(.minutes(1)))
+    func bothSidesReportStack() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "connection-stack")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        try await server.start()
+        try await client.start()
+
+        let serverPeerID = try await client.connect(to: address)
+        let clientPeerID = await client.peerID
+        let expected = ConnectionStack(
+            transport: "memory",
+            securityProtocol: "/plaintext/2.0.0",
+            muxerProtocol: "/yamux/1.0.0",
+            muxerSelection: .multistreamSelect
+        )
+
+        let outbound = try #require(await client.connection(to: serverPeerID))
+        #expect(outbound.negotiatedStack == expected)
+        #expect(outbound.transport == "memory")
+        #expect(outbound.securityProtocol == "/plaintext/2.0.0")
+        #expect(outbound.muxerProtocol == "/yamux/1.0.0")
+
+        var inbound: (any MuxedConnection)?
+        for _ in 0..<50 {
+            inbound = await server.connection(to: clientPeerID)
+            if inbound != nil { break }
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        let inboundConnection = try #require(inbound)
+        #expect(inboundConnection.negotiatedStack == expected)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Yamux session API is reachable through the upgraded connection", .timeLimit(.minutes(1)))
+    func yamuxSessionThroughWrapper() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "connection-stack-yamux")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        try await server.start()
+        try await client.start()
+
+        let serverPeerID = try await client.connect(to: address)
+        let clientPeerID = await client.peerID
+
+        let outbound = try #require(await client.connection(to: serverPeerID))
+        #expect(outbound.negotiatedStack != nil)
+        let outboundSession = try #require(outbound.yamuxConnection)
+        #expect(outboundSession.pendingInboundStreamCount == 0)
+        #expect(outboundSession.remoteGoAwayReason == nil)
+
+        var inboundSession: YamuxConnection?
+        for _ in 0..<50 {
+            inboundSession = await server.connection(to: clientPeerID)?.yamuxConnection
+            if inboundSession != nil { break }
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        let serverSession = try #require(inboundSession)
+
+        try await outboundSession.goAway(reason: .normal)
+        for _ in 0..<50 where serverSession.remoteGoAwayReason == nil {
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        #expect(serverSession.remoteGoAwayReason == .normal)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Transport name is the outermost protocol before the peer ID")
+    func transportName() throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let cases: [(String, String)] = [
+            ("/ip4/127.0.0.1/tcp/4001", "tcp"),
+            ("/ip4/127.0.0.1/tcp/4001/p2p/\(peer)", "tcp"),
+            ("/dns4/example.com/tcp/443/wss", "wss"),
+            ("/memory/node-a", "memory"),
+        ]
+        for (address, name) in cases {
+            #expect(NegotiatingUpgrader.transportName(of: try Multiaddr(address)) == name)
+        }
+    }
+
+    @Test("Connections outside the upgrader report no stack")
+    func defaultStackIsNil() {
+        let connection: any MuxedConnection = StacklessMuxedConnection()
+        #expect(connection.muxerConnection is StacklessMuxedConnection)
+        #expect(connection.yamuxConnection == nil)
+        #expect(connection.negotiatedStack == nil)
+        #expect(connection.transport == nil)
+        #expect(connection.securityProtocol == nil)
+        #expect(connection.muxerProtocol == nil)
+    }
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+}
+
+/// Muxed connection that relies on the protocol's default stack.
+private final class StacklessMuxedConnection: MuxedConnection, Sendable {
+    let localPeer = KeyPair.generateEd25519().peerID
+    let remotePeer = KeyPair.generateEd25519().peerID
+    let localAddress: Multiaddr? = nil
+    let remoteAddress = Multiaddr.memory(id: "stackless")
+    let hasActiveStreams = false
+
+    var inboundStreams: AsyncStream<MuxedStream> {
+        AsyncStream { $0.finish() }
+    }
+
+    func newStream() async throws -> MuxedStream {
+        throw CancellationError()
+    }
+
+    func acceptStream() async throws -> MuxedStream {
+        throw CancellationError()
+    }
+
+    func close() async throws {}
+}
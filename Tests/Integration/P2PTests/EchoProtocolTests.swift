@@ -0,0 +1,109 @@
+/// EchoProtocolTests - EchoProtocol server and client between two nodes
+///
+/// Tests payloads from empty to larger than the Yamux window, a custom
+/// protocol ID, and the overrun check against a responder that answers more
+/// than it was sent.
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PCore
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Echo Protocol Tests", .serialized)
+struct EchoProtocolTests {
+
+    @Test("Payloads round-trip over /test/echo/1.0.0", .timeLimit(.minutes(1)))
+    func echoRoundTrip() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "echo-protocol-round-trip")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        // 1MB exceeds the default Yamux window in both directions
+        for size in [0, 1, 4096, 1 << 20] {
+            let payload = Data((0..<size).map { UInt8($0 % 251) })
+            let echoed = try await EchoProtocol.echo(peer: serverPeerID, data: payload, on: client)
+            #expect(echoed == payload)
+        }
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("The protocol ID is configurable", .timeLimit(.minutes(1)))
+    func customProtocolID() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "echo-protocol-custom-id")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server, protocol: "/app/echo/2.0.0")
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let payload = Data("custom".utf8)
+        #expect(try await EchoProtocol.echo(peer: serverPeerID, protocol: "/app/echo/2.0.0", data: payload, on: client) == payload)
+        await #expect(throws: (any Error).self) {
+            _ = try await EchoProtocol.echo(peer: serverPeerID, data: payload, on: client)
+        }
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A responder that answers more than it was sent is an overrun", .timeLimit(.minutes(1)))
+    func overrun() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "echo-protocol-overrun")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await server.handleRaw(EchoProtocol.interopProtocolID) { raw in
+            do {
+                let chunk = try await raw.read()
+                try await raw.write(chunk + chunk)
+                try await raw.close()
+            } catch {
+                // The client reset the stream
+            }
+        }
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        await #expect(throws: EchoError.overrun(sent: 4, received: 8)) {
+            _ = try await EchoProtocol.echo(peer: serverPeerID, data: Data([1, 2, 3, 4]), on: client)
+        }
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+}
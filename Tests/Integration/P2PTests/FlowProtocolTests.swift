@@ -0,0 +1,99 @@
+/// FlowProtocolTests - FlowProtocol slow consumer and measuring client
+///
+/// Tests that a payload larger than the Yamux window comes back intact
+/// through the slow consumer, that throughput is bounded by its processing
+/// rate, and that the sender's writes stall on the window.
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PCore
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Flow Protocol Tests", .serialized)
+struct FlowProtocolTests {
+
+    /// A small fixed window so a few KB are enough to exhaust it.
+    static let yamux = YamuxConfiguration(
+        initialWindowSize: 4096,
+        enableKeepAlive: false,
+        enableWindowAutoTuning: false
+    )
+
+    @Test("A slow consumer throttles the sender through the window", .timeLimit(.minutes(1)))
+    func slowConsumerThrottlesSender() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "flow-protocol-throttle")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await FlowProtocol.registerSlowConsumer(on: server, chunkSize: 1024, processingDelay: .milliseconds(5))
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        // 32KB at 1KB per 5ms cannot finish in less than 160ms
+        let report = try await FlowProtocol.measure(
+            peer: serverPeerID,
+            bytes: 32 * 1024,
+            chunkSize: 1024,
+            stallThreshold: .milliseconds(4),
+            on: client
+        )
+        #expect(report.bytesSent == 32 * 1024)
+        #expect(report.duration >= .milliseconds(160))
+        #expect(report.throughput > 0)
+        #expect(report.throughput <= Double(32 * 1024) / 0.16)
+        #expect(report.stalledWrites > 0)
+        #expect(report.longestWriteWait >= .milliseconds(4))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A payload within the window does not stall", .timeLimit(.minutes(1)))
+    func smallPayloadDoesNotStall() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "flow-protocol-no-stall")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await FlowProtocol.registerSlowConsumer(on: server, protocol: "/app/flow/1.0.0", processingDelay: .milliseconds(1))
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let report = try await FlowProtocol.measure(
+            peer: serverPeerID,
+            protocol: "/app/flow/1.0.0",
+            bytes: 2048,
+            stallThreshold: .seconds(1),
+            on: client
+        )
+        #expect(report.bytesSent == 2048)
+        #expect(report.stalledWrites == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer(configuration: Self.yamux)],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+}
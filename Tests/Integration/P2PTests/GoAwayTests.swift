@@ -0,0 +1,104 @@
+/// GoAwayTests - Draining a connection after the peer's Yamux GoAway
+///
+/// Tests the full stack: MemoryTransport + Plaintext + Yamux + Node. The
+/// node that receives GoAway reports it, stops opening streams on the
+/// draining connection and dials a replacement.
+
+import Testing
+import Foundation
+import Synchronization
+@testable import P2P
+@testable import P2PCore
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMux
+@testable import P2PMuxYamux
+
+@Suite("GoAway Tests", .serialized)
+struct GoAwayTests {
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil, allowMultiple: Bool = false) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: .init(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300),
+                allowMultipleConnectionsPerPeer: allowMultiple
+            ),
+            healthCheck: nil
+        ))
+    }
+
+    /// Collects the GoAway reasons `node` reports for `peer`.
+    private func recordGoAways(of node: Node, for peer: PeerID) -> (Mutex<[YamuxGoAwayReason]>, Task<Void, Never>) {
+        let reasons = Mutex<[YamuxGoAwayReason]>([])
+        let task = Task { @Sendable in
+            for await event in node.events {
+                if case .connection(.goAway(let sender, let reason)) = event, sender == peer {
+                    reasons.withLock { $0.append(reason) }
+                }
+            }
+        }
+        return (reasons, task)
+    }
+
+    @Test("GoAway drains the connection, is reported and a replacement is dialed", .timeLimit(.minutes(1)))
+    func goAwayDialsReplacement() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "goaway-server")
+        // The server keeps the connection it sent GoAway on next to the new one
+        let server = makeNode(hub: hub, listenAddress: address, allowMultiple: true)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+        let clientPeerID = await client.peerID
+
+        let (reasons, eventTask) = recordGoAways(of: client, for: serverPeerID)
+        defer { eventTask.cancel() }
+
+        _ = try await client.connect(to: address)
+        let original = try #require(await client.connection(to: serverPeerID)?.yamuxConnection)
+
+        var serverSession: YamuxConnection?
+        for _ in 0..<50 where serverSession == nil {
+            serverSession = await server.connection(to: clientPeerID)?.yamuxConnection
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        let sender = try #require(serverSession)
+        try await sender.goAway(reason: .normal)
+
+        // The client reports the GoAway and moves to a fresh connection
+        var replacement: YamuxConnection?
+        for _ in 0..<100 {
+            if let current = await client.connection(to: serverPeerID)?.yamuxConnection,
+               current !== original {
+                replacement = current
+                break
+            }
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        for _ in 0..<50 where reasons.withLock({ $0.isEmpty }) {
+            try await Task.sleep(for: .milliseconds(20))
+        }
+        #expect(reasons.withLock { $0 } == [.normal])
+        let fresh = try #require(replacement)
+        #expect(fresh.remoteGoAwayReason == nil)
+        #expect(original.remoteGoAwayReason == .normal)
+
+        // New streams go over the replacement
+        let echoed = try await EchoProtocol.echo(peer: serverPeerID, data: Data("after goaway".utf8), on: client)
+        #expect(echoed == Data("after goaway".utf8))
+        #expect(await client.connectedPeers == [serverPeerID])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+}
@@ -0,0 +1,217 @@
+/// HandlerStreamLimitTests - Per-protocol inbound handler concurrency
+///
+/// Tests ProtocolStreamLimiter admission (reject, queue, drain) and the
+/// node-level behavior: streams past a handler's limit are reset and counted.
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Handler Stream Limit Tests", .serialized)
+struct HandlerStreamLimitTests {
+
+    static let echo = "/test/echo/1.0.0"
+
+    // MARK: - ProtocolStreamLimiter
+
+    @Test("Streams past the limit are refused and counted")
+    func rejectPastLimit() async {
+        let limiter = ProtocolStreamLimiter(defaultLimit: nil)
+        limiter.setLimit(HandlerStreamLimit(maxConcurrentStreams: 2), for: Self.echo)
+
+        #expect(await limiter.acquire(Self.echo))
+        #expect(await limiter.acquire(Self.echo))
+        #expect(await limiter.acquire(Self.echo) == false)
+        #expect(limiter.stats[Self.echo] == ProtocolStreamStats(active: 2, queued: 0, rejected: 1))
+
+        limiter.release(Self.echo)
+        #expect(await limiter.acquire(Self.echo))
+        #expect(limiter.stats[Self.echo] == ProtocolStreamStats(active: 2, queued: 0, rejected: 1))
+    }
+
+    @Test("Queued streams take freed slots in order and a full queue rejects", .timeLimit(.minutes(1)))
+    func queueHandsOverSlots() async throws {
+        let limiter = ProtocolStreamLimiter(defaultLimit: nil)
+        limiter.setLimit(HandlerStreamLimit(maxConcurrentStreams: 1, overflow: .queue(maxQueued: 1)), for: Self.echo)
+
+        #expect(await limiter.acquire(Self.echo))
+        let waiting = Task { await limiter.acquire(Self.echo) }
+        while limiter.stats[Self.echo]?.queued != 1 {
+            try await Task.sleep(for: .milliseconds(5))
+        }
+        #expect(await limiter.acquire(Self.echo) == false)
+
+        limiter.release(Self.echo)
+        #expect(await waiting.value)
+        #expect(limiter.stats[Self.echo] == ProtocolStreamStats(active: 1, queued: 0, rejected: 1))
+    }
+
+    @Test("Draining refuses waiting streams", .timeLimit(.minutes(1)))
+    func drainRefusesWaiters() async throws {
+        let limiter = ProtocolStreamLimiter(defaultLimit: HandlerStreamLimit(maxConcurrentStreams: 1, overflow: .queue(maxQueued: 4)))
+
+        #expect(await limiter.acquire(Self.echo))
+        let waiting = Task { await limiter.acquire(Self.echo) }
+        while limiter.stats[Self.echo]?.queued != 1 {
+            try await Task.sleep(for: .milliseconds(5))
+        }
+
+        limiter.drain()
+        #expect(await waiting.value == false)
+        #expect(limiter.stats[Self.echo]?.queued == 0)
+    }
+
+    @Test("The default applies only to protocols without their own limit")
+    func defaultLimitFallback() {
+        let limiter = ProtocolStreamLimiter(defaultLimit: HandlerStreamLimit(maxConcurrentStreams: 8))
+        limiter.setLimit(HandlerStreamLimit(maxConcurrentStreams: 1), for: Self.echo)
+
+        #expect(limiter.limit(for: Self.echo)?.maxConcurrentStreams == 1)
+        #expect(limiter.limit(for: "/other/1.0.0")?.maxConcurrentStreams == 8)
+    }
+
+    // MARK: - Node
+
+    @Test("A node resets inbound streams past the handler's limit", .timeLimit(.minutes(1)))
+    func nodeResetsStreamsPastLimit() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "handler-stream-limit-reject")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+
+        await server.handleStream(Self.echo, limit: HandlerStreamLimit(maxConcurrentStreams: 2)) { stream in
+            await Self.drain(stream)
+        }
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        var streams: [MuxedStream] = []
+        for _ in 0..<5 {
+            streams.append(try await client.newStream(to: serverPeerID, protocol: Self.echo))
+        }
+        let stats = try await Self.waitForStats(server, protocolID: Self.echo) { $0.active + $0.rejected == 5 }
+        #expect(stats == ProtocolStreamStats(active: 2, queued: 0, rejected: 3))
+
+        // Refused streams were reset: reading them fails or ends at once
+        var ended = 0
+        for stream in streams {
+            try await stream.write(ByteBuffer(bytes: [1]))
+        }
+        for stream in streams {
+            do {
+                let chunk = try await stream.read()
+                if chunk.readableBytes == 0 { ended += 1 }
+            } catch {
+                ended += 1
+            }
+            if ended == 3 { break }
+        }
+        #expect(ended == 3)
+
+        for stream in streams {
+            do { try await stream.close() } catch { }
+        }
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("The configured default limits handlers registered without one", .timeLimit(.minutes(1)))
+    func nodeAppliesDefaultLimit() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "handler-stream-limit-default")
+        let server = makeNode(
+            hub: hub,
+            listenAddress: address,
+            handlerStreamLimit: HandlerStreamLimit(maxConcurrentStreams: 1)
+        )
+        let client = makeNode(hub: hub)
+
+        await server.handleStream(Self.echo) { stream in
+            await Self.drain(stream)
+        }
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let first = try await client.newStream(to: serverPeerID, protocol: Self.echo)
+        let second = try await client.newStream(to: serverPeerID, protocol: Self.echo)
+        let stats = try await Self.waitForStats(server, protocolID: Self.echo) { $0.active + $0.rejected == 2 }
+        #expect(stats == ProtocolStreamStats(active: 1, queued: 0, rejected: 1))
+
+        // Closing the admitted stream frees its slot for the next one
+        try await first.close()
+        _ = try await Self.waitForStats(server, protocolID: Self.echo) { $0.active == 0 }
+        let third = try await client.newStream(to: serverPeerID, protocol: Self.echo)
+        let after = try await Self.waitForStats(server, protocolID: Self.echo) { $0.active == 1 }
+        #expect(after.rejected == 1)
+
+        do { try await second.close() } catch { }
+        try await third.close()
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    // MARK: - Helpers
+
+    /// Reads until the remote closes, then closes the stream.
+    private static func drain(_ stream: MuxedStream) async {
+        do {
+            while try await stream.read().readableBytes > 0 {}
+        } catch {
+            // Reset or closed by the remote
+        }
+        do {
+            try await stream.close()
+        } catch {
+            // Ignore close failures in test handler cleanup.
+        }
+    }
+
+    /// Polls the node's handler stats for `protocolID` until `condition` holds.
+    private static func waitForStats(
+        _ node: Node,
+        protocolID: String,
+        until condition: (ProtocolStreamStats) -> Bool
+    ) async throws -> ProtocolStreamStats {
+        var stats = ProtocolStreamStats()
+        for _ in 0..<200 {
+            stats = await node.handlerStreamStats()[protocolID] ?? ProtocolStreamStats()
+            if condition(stats) {
+                return stats
+            }
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        Issue.record("Handler stats for \(protocolID) never matched: \(stats)")
+        return stats
+    }
+
+    private func makeNode(
+        hub: MemoryHub,
+        listenAddress: Multiaddr? = nil,
+        handlerStreamLimit: HandlerStreamLimit? = nil
+    ) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            handlerStreamLimit: handlerStreamLimit
+        ))
+    }
+}
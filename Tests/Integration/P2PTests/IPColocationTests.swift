@@ -0,0 +1,138 @@
+/// IPColocationTests - Inbound connection limits per IP and subnet
+///
+/// Tests IPColocationPolicy keys, IPColocationLimiter admission and the
+/// node-level behavior: a TCP listener rejects inbound connections from one
+/// IP past the limit and reports the per-IP and per-subnet counts.
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PCore
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("IP Colocation Tests", .serialized)
+struct IPColocationTests {
+
+    // MARK: - IPColocationPolicy
+
+    @Test("Keys mask the IP to the subnet prefix")
+    func keys() throws {
+        let policy = IPColocationPolicy()
+
+        let v4 = policy.key(for: try Multiaddr("/ip4/203.0.113.77/tcp/4001"))
+        #expect(v4 == IPColocationKey(ip: "203.0.113.77", subnet: "203.0.113.0/24"))
+
+        let v6 = policy.key(for: try Multiaddr("/ip6/2001:db8:aa:1::7/udp/4001/quic-v1"))
+        #expect(v6 == IPColocationKey(ip: "2001:db8:aa:1::7", subnet: "2001:db8:aa::/48"))
+
+        let wide = IPColocationPolicy(ipv4PrefixLength: 20)
+        #expect(wide.key(for: try Multiaddr("/ip4/10.1.47.9/tcp/1"))?.subnet == "10.1.32.0/20")
+    }
+
+    @Test("Memory and relayed addresses are not limited")
+    func unlimitedAddresses() throws {
+        let policy = IPColocationPolicy()
+        #expect(policy.key(for: try Multiaddr("/memory/a")) == nil)
+        let relayed = try Multiaddr(
+            "/ip4/203.0.113.1/tcp/4001/p2p/\(KeyPair.generateEd25519().peerID)/p2p-circuit"
+        )
+        #expect(policy.key(for: relayed) == nil)
+    }
+
+    // MARK: - IPColocationLimiter
+
+    @Test("Connections past the per-IP limit are rejected and counted")
+    func perIPLimit() throws {
+        let limiter = IPColocationLimiter(policy: IPColocationPolicy(maxPerIP: 2, maxPerSubnet: nil))
+        let address = try Multiaddr("/ip4/198.51.100.4/tcp/5000")
+        let established = [try Multiaddr("/ip4/198.51.100.4/tcp/5001")]
+
+        let key = try #require(try limiter.reserve(address, established: established))
+        #expect(throws: ResourceError.limitExceeded(scope: "ip:198.51.100.4", resource: "inboundConnections")) {
+            try limiter.reserve(address, established: established)
+        }
+        #expect(limiter.stats(established: established) == IPColocationStats(
+            perIP: ["198.51.100.4": 2],
+            perSubnet: ["198.51.100.0/24": 2],
+            rejected: 1
+        ))
+
+        limiter.release(key)
+        #expect(try limiter.reserve(address, established: established) != nil)
+    }
+
+    @Test("Distinct IPs in one subnet share the subnet limit")
+    func perSubnetLimit() throws {
+        let limiter = IPColocationLimiter(policy: IPColocationPolicy(maxPerIP: nil, maxPerSubnet: 2))
+        #expect(try limiter.reserve(try Multiaddr("/ip6/2001:db8:1:2::1/tcp/1"), established: []) != nil)
+        #expect(try limiter.reserve(try Multiaddr("/ip6/2001:db8:1:3::1/tcp/1"), established: []) != nil)
+        #expect(throws: ResourceError.limitExceeded(scope: "subnet:2001:db8:1::/48", resource: "inboundConnections")) {
+            try limiter.reserve(try Multiaddr("/ip6/2001:db8:1:4::1/tcp/1"), established: [])
+        }
+        #expect(try limiter.reserve(try Multiaddr("/ip6/2001:db8:2::1/tcp/1"), established: []) != nil)
+        #expect(limiter.stats(established: []).perSubnet == ["2001:db8:1::/48": 2, "2001:db8:2::/48": 1])
+    }
+
+    // MARK: - Node
+
+    @Test("A TCP listener accepts at most maxPerIP connections from one host", .timeLimit(.minutes(1)))
+    func nodeRejectsPastLimit() async throws {
+        let server = Self.makeNode(
+            listenAddresses: [try Multiaddr("/ip4/127.0.0.1/tcp/0")],
+            ipColocation: IPColocationPolicy(maxPerIP: 2, maxPerSubnet: nil)
+        )
+        try await server.start()
+        defer { Task { do { try await server.shutdown() } catch { } } }
+
+        let address = try #require(await server.listenAddresses().first)
+        let serverAddress = try Multiaddr("\(address)/p2p/\(await server.peerID)")
+
+        var clients: [Node] = []
+        for _ in 0..<3 {
+            let client = Self.makeNode(listenAddresses: [], ipColocation: nil)
+            try await client.start()
+            clients.append(client)
+        }
+        defer {
+            for client in clients {
+                Task { do { try await client.shutdown() } catch { } }
+            }
+        }
+
+        _ = try await clients[0].connect(to: serverAddress)
+        _ = try await clients[1].connect(to: serverAddress)
+        while await server.connectedPeers.count < 2 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+
+        await #expect(throws: (any Error).self) {
+            try await clients[2].connect(to: serverAddress)
+        }
+
+        let stats = await server.ipColocationStats()
+        #expect(stats.perIP == ["127.0.0.1": 2])
+        #expect(stats.perSubnet == ["127.0.0.0/24": 2])
+        #expect(stats.rejected == 1)
+        #expect(await server.connectedPeers.count == 2)
+    }
+
+    // MARK: - Helpers
+
+    private static func makeNode(listenAddresses: [Multiaddr], ipColocation: IPColocationPolicy?) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddresses,
+            transports: [TCPTransport()],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300),
+                ipColocation: ipColocation
+            ),
+            healthCheck: nil
+        ))
+    }
+}
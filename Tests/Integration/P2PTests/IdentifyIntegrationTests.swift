@@ -19,6 +19,7 @@ struct IdentifyIntegrationTests {
                 component.consumesLocalIdentity()
                 component.consumesListenAddresses()
                 component.consumesSupportedProtocols()
+                component.consumesPeerStore()
                 component.activatesWithStreamOpening()
             }
         }
@@ -285,6 +286,71 @@ struct IdentifyIntegrationTests {
         try await server.shutdown()
         hub.reset()
     }
+
+    @Test("Identified protocols make later streams negotiate optimistically", .timeLimit(.minutes(1)))
+    func identifiedProtocolsSkipNegotiationRoundTrip() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "identify-protobook")
+
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let serverIdentify = IdentifyService(configuration: .init(
+            agentVersion: "server/1.0.0",
+            cleanupInterval: nil
+        ))
+        let clientIdentify = IdentifyService(configuration: .init(
+            agentVersion: "client/1.0.0",
+            cleanupInterval: nil
+        ))
+
+        let pool = PoolConfiguration(
+            limits: .development,
+            reconnectionPolicy: .disabled,
+            idleTimeout: .seconds(300)
+        )
+
+        let server = Node(configuration: NodeConfiguration(
+            keyPair: serverKeyPair,
+            listenAddresses: [serverAddress],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: pool,
+            healthCheck: nil,
+            services: identifyServices(serverIdentify)
+        ))
+        let client = Node(configuration: NodeConfiguration(
+            keyPair: clientKeyPair,
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: pool,
+            healthCheck: nil,
+            services: identifyServices(clientIdentify)
+        ))
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        let info = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+
+        // Identify records the server's protocols in the client's protocol book
+        let recorded = await client.protoBook.protocols(for: serverKeyPair.peerID)
+        #expect(Set(recorded) == Set(info.protocols))
+        #expect(recorded.contains(ProtocolID.identify))
+
+        let before = await client.streamNegotiationStats()
+        _ = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        let after = await client.streamNegotiationStats()
+        #expect(after.optimisticNegotiations - before.optimisticNegotiations >= 1)
+        #expect(after.optimisticRejections == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
 }
 
 // MARK: - Helpers
@@ -0,0 +1,299 @@
+import Foundation
+import Synchronization
+import Testing
+@testable import P2P
+@testable import P2PCore
+@testable import P2PIdentify
+@testable import P2PKademlia
+@testable import P2PMuxYamux
+@testable import P2PSecurityPlaintext
+@testable import P2PTransportMemory
+
+@Suite("Kademlia Routing Integration Tests", .serialized)
+struct KademliaRoutingIntegrationTests {
+
+    /// A node running Identify and, unless `kademlia` is nil, Kademlia.
+    private func makeNode(
+        hub: MemoryHub,
+        keyPair: KeyPair,
+        address: Multiaddr? = nil,
+        identify: IdentifyService,
+        kademlia: KademliaService?
+    ) throws -> Node {
+        try Node(
+            keyPair: keyPair,
+            listenAddresses: address.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ) {
+            Identify(identify)
+            if let kademlia {
+                Kademlia(kademlia)
+            }
+        }
+    }
+
+    private func makeKademlia(
+        _ keyPair: KeyPair,
+        kValue: Int = KademliaProtocol.kValue,
+        protocolPrefix: String = KademliaProtocol.defaultProtocolPrefix,
+        routeTableFilter: RouteTableFilter? = nil
+    ) -> KademliaService {
+        KademliaService(
+            localPeerID: keyPair.peerID,
+            configuration: KademliaConfiguration(
+                kValue: kValue,
+                cleanupInterval: nil,
+                identifyTimeout: .seconds(2),
+                protocolPrefix: protocolPrefix,
+                routeTableFilter: routeTableFilter
+            )
+        )
+    }
+
+    @Test("An identified DHT peer is added on connect and kept on disconnect while its bucket has room", .timeLimit(.minutes(1)))
+    func identifiedPeerIsAdded() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-server")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: makeKademlia(serverKeyPair)
+        )
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair)
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        _ = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        try await waitUntil(timeout: .seconds(5)) { clientKad.routingTable.contains(serverKeyPair.peerID) }
+
+        await client.disconnect(from: serverKeyPair.peerID)
+        try await waitUntil(timeout: .seconds(5)) { await client.connectedPeers.isEmpty }
+        #expect(clientKad.routingTable.contains(serverKeyPair.peerID))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A peer that does not speak the DHT protocol is not added", .timeLimit(.minutes(1)))
+    func nonDHTPeerIsNotAdded() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-plain")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: nil
+        )
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair)
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        let info = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        #expect(!info.protocols.contains(KademliaProtocol.protocolID))
+
+        // Outlast identifyTimeout
+        try await Task.sleep(for: .seconds(3))
+        #expect(clientKad.routingTable.count == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A peer serving the DHT under another prefix is not added", .timeLimit(.minutes(1)))
+    func otherPrefixIsNotAdded() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-prefix")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: makeKademlia(serverKeyPair, protocolPrefix: "/lan")
+        )
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair)
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        let info = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        #expect(info.protocols.contains("/lan/kad/1.0.0"))
+        #expect(!info.protocols.contains(KademliaProtocol.protocolID))
+
+        // Outlast identifyTimeout
+        try await Task.sleep(for: .seconds(3))
+        #expect(clientKad.routingTable.count == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("The route table filter keeps an identified DHT peer out", .timeLimit(.minutes(1)))
+    func routeTableFilterRejects() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-filter")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: makeKademlia(serverKeyPair)
+        )
+        let consulted = Mutex<[PeerID]>([])
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair, routeTableFilter: { peer, _ in
+            consulted.withLock { $0.append(peer) }
+            return false
+        })
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        _ = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        try await waitUntil(timeout: .seconds(5)) { consulted.withLock { $0.contains(serverKeyPair.peerID) } }
+        #expect(!clientKad.routingTable.contains(serverKeyPair.peerID))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("An inbound requester is added only once Identify lists the DHT protocol for it", .timeLimit(.minutes(1)))
+    func inboundRequesterNeedsIdentify() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-inbound")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let serverKad = makeKademlia(serverKeyPair)
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: serverKad
+        )
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair)
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        _ = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        try await waitUntil(timeout: .seconds(5)) { clientKad.routingTable.contains(serverKeyPair.peerID) }
+
+        // The server has not identified the client, so its FIND_NODE does
+        // not vouch for it
+        _ = try await clientKad.findNode(KeyPair.generateEd25519().peerID, using: client)
+        #expect(!serverKad.routingTable.contains(clientKeyPair.peerID))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A disconnected peer in a full bucket is replaced by a pending peer", .timeLimit(.minutes(1)))
+    func disconnectedPeerIsReplaced() async throws {
+        let hub = MemoryHub()
+        let serverAddress = Multiaddr.memory(id: "kad-routing-replace")
+        let serverKeyPair = KeyPair.generateEd25519()
+        let clientKeyPair = KeyPair.generateEd25519()
+
+        let server = try makeNode(
+            hub: hub,
+            keyPair: serverKeyPair,
+            address: serverAddress,
+            identify: IdentifyService(configuration: .init(cleanupInterval: nil)),
+            kademlia: makeKademlia(serverKeyPair)
+        )
+        let clientIdentify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let clientKad = makeKademlia(clientKeyPair, kValue: 1)
+        let client = try makeNode(hub: hub, keyPair: clientKeyPair, identify: clientIdentify, kademlia: clientKad)
+
+        try await server.start()
+        try await client.start()
+
+        _ = try await client.connect(to: serverAddress)
+        _ = try await clientIdentify.identify(serverKeyPair.peerID, using: client)
+        try await waitUntil(timeout: .seconds(5)) { clientKad.routingTable.contains(serverKeyPair.peerID) }
+
+        // With k = 1 the server fills its bucket; a second peer there waits
+        let bucket = try #require(clientKad.routingTable.bucketIndex(for: serverKeyPair.peerID))
+        let pending = try #require((0..<1000).lazy
+            .map { _ in KeyPair.generateEd25519().peerID }
+            .first { clientKad.routingTable.bucketIndex(for: $0) == bucket })
+        guard case .pending = clientKad.addPeer(pending) else {
+            Issue.record("Expected the second peer to be pending")
+            return
+        }
+
+        await client.disconnect(from: serverKeyPair.peerID)
+        try await waitUntil(timeout: .seconds(5)) { !clientKad.routingTable.contains(serverKeyPair.peerID) }
+        #expect(clientKad.routingTable.peersInBucket(bucket).map(\.peerID) == [pending])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+}
+
+// MARK: - Helpers
+
+private enum WaitTimeoutError: Error {
+    case timedOut
+}
+
+private func waitUntil(
+    timeout: Duration,
+    pollInterval: Duration = .milliseconds(20),
+    condition: @escaping @Sendable () async -> Bool
+) async throws {
+    let start = ContinuousClock.now
+    while ContinuousClock.now - start < timeout {
+        if await condition() {
+            return
+        }
+        try await Task.sleep(for: pollInterval)
+    }
+    throw WaitTimeoutError.timedOut
+}
@@ -0,0 +1,203 @@
+/// KeepAliveTests - Per-connection keepalive policy
+///
+/// Tests which mechanism the policy picks for a connection, and that nodes
+/// keep answering connections open and close the ones whose probes fail.
+
+import Testing
+import Foundation
+import Synchronization
+@testable import P2P
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PRuntime
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+@testable import P2PPing
+
+@Suite("Keep-Alive Tests", .serialized)
+struct KeepAliveTests {
+
+    // MARK: - Mechanism Selection
+
+    @Test("Automatic mode prefers the native probe and falls back to libp2p ping")
+    func automaticMode() {
+        let policy = KeepAlivePolicy(mode: .automatic)
+        #expect(policy.mechanism(for: ProbeStubConnection(probe: StubProbe(mechanism: .quicPing))) == .quicPing)
+        #expect(policy.mechanism(for: ProbeStubConnection(probe: nil)) == .libp2pPing)
+    }
+
+    @Test("Native-only mode skips connections without a probe")
+    func nativeOnlyMode() {
+        let policy = KeepAlivePolicy(mode: .nativeOnly)
+        #expect(policy.mechanism(for: ProbeStubConnection(probe: StubProbe(mechanism: .muxerPing))) == .muxerPing)
+        #expect(policy.mechanism(for: ProbeStubConnection(probe: nil)) == nil)
+    }
+
+    @Test("libp2p ping mode ignores the native probe")
+    func libp2pPingMode() {
+        let policy = KeepAlivePolicy(mode: .libp2pPing)
+        #expect(policy.mechanism(for: ProbeStubConnection(probe: StubProbe(mechanism: .quicPing))) == .libp2pPing)
+    }
+
+    @Test("Probes are spread evenly over the window")
+    func probeInterval() {
+        let policy = KeepAlivePolicy(window: .seconds(30), probeCount: 3)
+        #expect(policy.probeInterval == .seconds(10))
+        #expect(PoolConfiguration(keepAlive: policy) != PoolConfiguration())
+    }
+
+    // MARK: - Node
+
+    @Test("A responsive peer keeps its Yamux connection", .timeLimit(.minutes(1)))
+    func responsivePeerStaysConnected() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "keepalive-responsive")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub, keepAlive: KeepAlivePolicy(window: .milliseconds(300), probeCount: 3))
+        try await server.start()
+        try await client.start()
+
+        let serverPeerID = try await client.connect(to: address)
+        let connection = try #require(await client.connection(to: serverPeerID))
+        #expect(connection.keepAliveProbe?.mechanism == .muxerPing)
+
+        try await Task.sleep(for: .seconds(1))
+        let connected = await client.connectedPeers
+        #expect(connected.contains(serverPeerID))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A peer that answers libp2p ping keeps its connection", .timeLimit(.minutes(1)))
+    func libp2pPingPeerStaysConnected() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "keepalive-ping")
+        let pingService = PingService()
+        let server = makeNode(
+            hub: hub,
+            listenAddress: address,
+            services: ServicePipeline {
+                service(pingService) { component in
+                    component.handlesInboundStreams()
+                }
+            }
+        )
+        let client = makeNode(
+            hub: hub,
+            keepAlive: KeepAlivePolicy(window: .milliseconds(600), probeCount: 3, mode: .libp2pPing)
+        )
+        try await server.start()
+        try await client.start()
+
+        let serverPeerID = try await client.connect(to: address)
+        try await Task.sleep(for: .seconds(1))
+        let connected = await client.connectedPeers
+        #expect(connected.contains(serverPeerID))
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Failing probes close the connection with keepAliveTimeout", .timeLimit(.minutes(1)))
+    func failingProbesDisconnect() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "keepalive-unanswered")
+        // The server does not serve /ipfs/ping/1.0.0, so every probe fails
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(
+            hub: hub,
+            keepAlive: KeepAlivePolicy(window: .milliseconds(300), probeCount: 3, mode: .libp2pPing)
+        )
+        try await server.start()
+        try await client.start()
+
+        let reasons = Mutex<[DisconnectReason]>([])
+        let events = client.events
+        let eventTask = Task {
+            for await event in events {
+                if case .connection(.disconnected(_, let reason)) = event {
+                    reasons.withLock { $0.append(reason) }
+                }
+            }
+        }
+
+        let serverPeerID = try await client.connect(to: address)
+        for _ in 0..<50 {
+            if !reasons.withLock({ $0.isEmpty }) { break }
+            try await Task.sleep(for: .milliseconds(50))
+        }
+
+        #expect(reasons.withLock { $0 } == [.keepAliveTimeout])
+        let connected = await client.connectedPeers
+        #expect(!connected.contains(serverPeerID))
+
+        eventTask.cancel()
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    private func makeNode(
+        hub: MemoryHub,
+        listenAddress: Multiaddr? = nil,
+        keepAlive: KeepAlivePolicy? = nil,
+        services: ServicePipeline = .empty
+    ) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300),
+                keepAlive: keepAlive
+            ),
+            healthCheck: nil,
+            services: services
+        ))
+    }
+}
+
+// MARK: - Stubs
+
+private struct StubProbe: KeepAliveProbe {
+    let mechanism: KeepAliveMechanism
+
+    func probe() async throws -> Duration { .zero }
+    func disableBuiltInKeepAlive() {}
+}
+
+/// Muxed connection exposing a fixed keepalive probe.
+private final class ProbeStubConnection: MuxedConnection, Sendable {
+    let localPeer = KeyPair.generateEd25519().peerID
+    let remotePeer = KeyPair.generateEd25519().peerID
+    let localAddress: Multiaddr? = nil
+    let remoteAddress = Multiaddr.memory(id: "probe-stub")
+    let hasActiveStreams = false
+    let keepAliveProbe: (any KeepAliveProbe)?
+
+    init(probe: (any KeepAliveProbe)?) {
+        self.keepAliveProbe = probe
+    }
+
+    var inboundStreams: AsyncStream<MuxedStream> {
+        AsyncStream { $0.finish() }
+    }
+
+    func newStream() async throws -> MuxedStream {
+        throw CancellationError()
+    }
+
+    func acceptStream() async throws -> MuxedStream {
+        throw CancellationError()
+    }
+
+    func close() async throws {}
+}
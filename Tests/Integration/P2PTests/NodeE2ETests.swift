@@ -28,6 +28,7 @@ struct NodeE2ETests {
         hub: MemoryHub,
         keyPair: KeyPair = .generateEd25519(),
         listenAddress: Multiaddr? = nil,
+        security: [any SecurityUpgrader] = [PlaintextUpgrader()],
         pool: PoolConfiguration = .init(
             limits: .development,
             reconnectionPolicy: .disabled,
@@ -45,7 +46,7 @@ struct NodeE2ETests {
             keyPair: keyPair,
             listenAddresses: listenAddresses,
             transports: [MemoryTransport(hub: hub)],
-            security: [PlaintextUpgrader()],
+            security: security,
             muxers: [YamuxMuxer()],
             pool: pool,
             healthCheck: healthCheck,
@@ -56,6 +57,61 @@ struct NodeE2ETests {
 
     // MARK: - Basic Connection Tests
 
+    @Test("A node listening on /memory/0 is dialable at its assigned address", .timeLimit(.minutes(1)))
+    func testAllocatedMemoryAddress() async throws {
+        let hub = MemoryHub()
+        let server = makeNode(name: "server", hub: hub, listenAddress: .memory(id: "0"))
+        let client = makeNode(name: "client", hub: hub)
+        try await server.start()
+        try await client.start()
+
+        let listenAddresses = await server.listenAddresses()
+        let address = try #require(listenAddresses.first)
+        #expect(address.memoryID != "0")
+
+        let connectedPeer = try await client.connect(to: address)
+        let serverPeerID = await server.peerID
+        #expect(connectedPeer == serverPeerID)
+        let connection = try #require(await client.connection(to: serverPeerID))
+        #expect(connection.securityProtocol == "/plaintext/2.0.0")
+        #expect(connection.muxerProtocol == "/yamux/1.0.0")
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A node listening on /tcp/0 reports and accepts on the assigned port", .timeLimit(.minutes(1)))
+    func testEphemeralTCPPort() async throws {
+        func makeTCPNode(listenAddresses: [Multiaddr]) -> Node {
+            Node(configuration: NodeConfiguration(
+                keyPair: .generateEd25519(),
+                listenAddresses: listenAddresses,
+                transports: [TCPTransport()],
+                security: [PlaintextUpgrader()],
+                muxers: [YamuxMuxer()],
+                pool: .init(limits: .development, reconnectionPolicy: .disabled, idleTimeout: .seconds(300)),
+                healthCheck: nil
+            ))
+        }
+        let server = makeTCPNode(listenAddresses: [try Multiaddr("/ip4/127.0.0.1/tcp/0")])
+        let client = makeTCPNode(listenAddresses: [])
+        try await server.start()
+        try await client.start()
+
+        let address = try #require(await server.listenAddresses().first)
+        let port = try #require(address.tcpPort)
+        #expect(port != 0)
+        #expect(address.ipAddress == "127.0.0.1")
+
+        let serverPeerID = await server.peerID
+        let connectedPeer = try await client.connect(to: try Multiaddr("\(address)/p2p/\(serverPeerID)"))
+        #expect(connectedPeer == serverPeerID)
+
+        try await client.shutdown()
+        try await server.shutdown()
+    }
+
     @Test("Two nodes can connect via MemoryTransport")
     func testBasicNodeConnection() async throws {
         let hub = MemoryHub()
@@ -117,6 +173,101 @@ struct NodeE2ETests {
         hub.reset()
     }
 
+    @Test("Dialing an address with the wrong /p2p/ suffix is rejected", .timeLimit(.minutes(1)))
+    func testConnectWithWrongPeerID() async throws {
+        let hub = MemoryHub()
+        let serverKeyPair = KeyPair.generateEd25519()
+        let wrongPeerID = KeyPair.generateEd25519().peerID
+        let serverAddr = Multiaddr.memory(id: "server-wrong-peer")
+
+        let server = makeNode(name: "server", hub: hub, keyPair: serverKeyPair, listenAddress: serverAddr, security: [NoiseUpgrader()])
+        let client = makeNode(name: "client", hub: hub, security: [NoiseUpgrader()])
+        try await server.start()
+        try await client.start()
+
+        do {
+            _ = try await client.connect(to: try Multiaddr("\(serverAddr)/p2p/\(wrongPeerID)"))
+            Issue.record("Expected the dial to be rejected")
+        } catch {
+            if case NoiseError.identityMismatch(let phase, let expected, let actual) = error {
+                #expect(phase == .messageB)
+                #expect(expected == wrongPeerID)
+                #expect(actual == serverKeyPair.peerID)
+            } else {
+                Issue.record("Expected identityMismatch, got \(error)")
+            }
+        }
+        #expect(await client.connectionCount == 0)
+        #expect(await client.connection(to: serverKeyPair.peerID) == nil)
+
+        // The server is not penalised: the correct address still connects
+        let connectedPeer = try await client.connect(to: try Multiaddr("\(serverAddr)/p2p/\(serverKeyPair.peerID)"))
+        #expect(connectedPeer == serverKeyPair.peerID)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Node rejects a mismatched peer even if the upgrader does not check", .timeLimit(.minutes(1)))
+    func testConnectIdentityBackstop() async throws {
+        let hub = MemoryHub()
+        let serverKeyPair = KeyPair.generateEd25519()
+        let wrongPeerID = KeyPair.generateEd25519().peerID
+        let serverAddr = Multiaddr.memory(id: "server-unchecked-peer")
+
+        let server = makeNode(name: "server", hub: hub, keyPair: serverKeyPair, listenAddress: serverAddr)
+        let client = makeNode(name: "client", hub: hub, security: [UncheckedPeerUpgrader()])
+        try await server.start()
+        try await client.start()
+
+        do {
+            _ = try await client.connect(to: try Multiaddr("\(serverAddr)/p2p/\(wrongPeerID)"))
+            Issue.record("Expected the dial to be rejected")
+        } catch NodeError.identityMismatch(let expected, let actual) {
+            #expect(expected == wrongPeerID)
+            #expect(actual == serverKeyPair.peerID)
+        }
+        #expect(await client.connectionCount == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Node resolves a dnsaddr address and skips unreachable results")
+    func testConnectViaDNSAddr() async throws {
+        let hub = MemoryHub()
+        let serverKeyPair = KeyPair.generateEd25519()
+        let serverAddr = Multiaddr.memory(id: "dnsaddr-server")
+        let server = makeNode(name: "server", hub: hub, keyPair: serverKeyPair, listenAddress: serverAddr)
+
+        let peer = serverKeyPair.peerID
+        let resolver = StaticDNSResolver(results: [
+            try Multiaddr("/memory/dnsaddr-missing/p2p/\(peer)"),
+            try Multiaddr("\(serverAddr)/p2p/\(peer)"),
+        ])
+        let client = Node(configuration: NodeConfiguration(
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(limits: .development, reconnectionPolicy: .disabled),
+            healthCheck: nil,
+            dnsResolver: resolver
+        ))
+
+        try await server.start()
+        try await client.start()
+
+        let connectedPeer = try await client.connect(to: try Multiaddr("/dnsaddr/bootstrap.example/p2p/\(peer)"))
+        #expect(connectedPeer == peer)
+        #expect(resolver.resolvedAddresses.map(\.description) == ["/dnsaddr/bootstrap.example/p2p/\(peer)"])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
     @Test("Connection emits events")
     func testConnectionEvents() async throws {
         let hub = MemoryHub()
@@ -981,6 +1132,67 @@ struct NodeE2ETests {
         hub.reset()
     }
 
+    @Test("Dialing a connected peer reuses the connection", .timeLimit(.minutes(1)))
+    func testDialReusesExistingConnection() async throws {
+        let hub = MemoryHub()
+        let serverKeyPair = KeyPair.generateEd25519()
+        let serverAddr = Multiaddr.memory(id: "reuse-server")
+
+        let server = makeNode(name: "server", hub: hub, keyPair: serverKeyPair, listenAddress: serverAddr)
+        let client = makeNode(name: "client", hub: hub)
+
+        try await server.start()
+        try await client.start()
+
+        let addrWithPeerID = try Multiaddr("\(serverAddr)/p2p/\(serverKeyPair.peerID)")
+
+        // Concurrent dials collapse into one, and a later dial reuses it
+        async let first: PeerID = client.connect(to: addrWithPeerID)
+        async let second: PeerID = client.connect(to: addrWithPeerID)
+        _ = try await (first, second)
+        let third = try await client.connect(to: addrWithPeerID)
+        #expect(third == serverKeyPair.peerID)
+
+        try await Task.sleep(for: .milliseconds(100))
+        #expect(await client.connectionCount == 1)
+        #expect(await server.connectionCount == 1)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("allowMultipleConnectionsPerPeer opens a connection per dial", .timeLimit(.minutes(1)))
+    func testMultipleConnectionsWhenAllowed() async throws {
+        let hub = MemoryHub()
+        let serverKeyPair = KeyPair.generateEd25519()
+        let serverAddr = Multiaddr.memory(id: "multi-server")
+        let pool = PoolConfiguration(
+            limits: .development,
+            reconnectionPolicy: .disabled,
+            idleTimeout: .seconds(300),
+            allowMultipleConnectionsPerPeer: true
+        )
+
+        let server = makeNode(name: "server", hub: hub, keyPair: serverKeyPair, listenAddress: serverAddr, pool: pool)
+        let client = makeNode(name: "client", hub: hub, pool: pool)
+
+        try await server.start()
+        try await client.start()
+
+        let addrWithPeerID = try Multiaddr("\(serverAddr)/p2p/\(serverKeyPair.peerID)")
+        _ = try await client.connect(to: addrWithPeerID)
+        _ = try await client.connect(to: addrWithPeerID)
+
+        try await Task.sleep(for: .milliseconds(100))
+        #expect(await client.connectionCount == 2)
+        #expect(await server.connectionCount == 2)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
     @Test("peerConnected emits only once for simultaneous connect", .timeLimit(.minutes(1)))
     func testPeerConnectedEmitsOnce() async throws {
         let hub = MemoryHub()
@@ -1095,6 +1307,36 @@ struct NodeE2ETests {
         #expect(resolved[0].tcpPort == 9000)
     }
 
+    @Test("resolveUnspecifiedAddresses reports both families of a dual-stack listener")
+    func resolveDualStackAddresses() throws {
+        let bound = [
+            try Multiaddr("/ip6/::/tcp/4001"),
+            try Multiaddr("/ip4/0.0.0.0/tcp/4001"),
+        ]
+        let resolved = Swarm.resolveUnspecifiedAddresses(
+            bound,
+            interfaceIPs: ["192.168.1.5", "2001:db8::5", "fe80::1", "127.0.0.1", "::1"]
+        )
+
+        #expect(resolved.map(\.description) == [
+            "/ip6/2001:db8::5/tcp/4001",
+            "/ip6/::1/tcp/4001",
+            "/ip4/192.168.1.5/tcp/4001",
+            "/ip4/127.0.0.1/tcp/4001",
+        ])
+    }
+
+    @Test("resolveUnspecifiedAddresses skips link-local-only IPv6 interfaces")
+    func resolveLinkLocalOnlyIPv6() throws {
+        let resolved = Swarm.resolveUnspecifiedAddresses(
+            [try Multiaddr("/ip6/::/tcp/4001"), try Multiaddr("/ip4/0.0.0.0/tcp/4001")],
+            interfaceIPs: ["10.0.0.2", "fe80::1c2:3ff:fe4d:5e6f", "127.0.0.1"]
+        )
+
+        #expect(resolved.allSatisfy { $0.ipAddress?.contains(":") == false })
+        #expect(resolved.map(\.ipAddress) == ["10.0.0.2", "127.0.0.1"])
+    }
+
     @Test("Node advertises resolved addresses after start", .timeLimit(.minutes(1)))
     func nodeAdvertisesResolvedAddresses() async throws {
         let hub = MemoryHub()
@@ -1171,3 +1413,36 @@ struct NodeE2ETests {
         #expect(validation.isValid)
     }
 }
+
+/// Resolves every address to a fixed list, recording what it was asked.
+private final class StaticDNSResolver: DNSResolver, Sendable {
+    private let results: [Multiaddr]
+    private let requests = Mutex<[Multiaddr]>([])
+
+    init(results: [Multiaddr]) {
+        self.results = results
+    }
+
+    var resolvedAddresses: [Multiaddr] { requests.withLock { $0 } }
+
+    func resolve(_ address: Multiaddr) async throws -> [Multiaddr] {
+        requests.withLock { $0.append(address) }
+        return results
+    }
+}
+
+/// Plaintext that ignores the expected peer, as a third-party upgrader might.
+private struct UncheckedPeerUpgrader: SecurityUpgrader {
+    private let plaintext = PlaintextUpgrader()
+
+    var protocolID: String { plaintext.protocolID }
+
+    func secure(
+        _ connection: any RawConnection,
+        localKeyPair: KeyPair,
+        as role: SecurityRole,
+        expectedPeer: PeerID?
+    ) async throws -> any SecuredConnection {
+        try await plaintext.secure(connection, localKeyPair: localKeyPair, as: role, expectedPeer: nil)
+    }
+}
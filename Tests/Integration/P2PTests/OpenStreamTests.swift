@@ -0,0 +1,230 @@
+/// OpenStreamTests - Node.openStream(to:protocol:retry:)
+///
+/// Tests that the call dials when there is no connection, keeps retrying
+/// until a late listener appears, gives up after the attempt limit, does
+/// not retry a protocol the peer refuses, redials when the connection it
+/// found is dead, and does not dial addresses that name another peer.
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PCore
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Open Stream Tests", .serialized)
+struct OpenStreamTests {
+
+    static let quickRetry = RetryPolicy(maxAttempts: 20, backoff: BackoffStrategy(kind: .constant(.milliseconds(100)), jitter: 0))
+
+    @Test("Dials, negotiates and returns a ready stream", .timeLimit(.minutes(1)))
+    func dialsAndNegotiates() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "open-stream-dial")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+
+        let stream = try await client.openStream(
+            to: AddrInfo(peer: serverPeerID, addresses: [address]),
+            protocol: EchoProtocol.interopProtocolID
+        )
+        #expect(await client.connectedPeers == [serverPeerID])
+
+        try await stream.write(ByteBuffer(bytes: Array("ping".utf8)))
+        try await stream.closeWrite()
+        var echoed = ByteBuffer()
+        while true {
+            var data = try await stream.read()
+            if data.readableBytes == 0 { break }
+            echoed.writeBuffer(&data)
+        }
+        #expect(String(buffer: echoed) == "ping")
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Refused dials are retried until the listener appears", .timeLimit(.minutes(1)))
+    func retriesUntilListening() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "open-stream-late-listener")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+        try await client.start()
+        let serverPeerID = await server.peerID
+
+        let lateStart = Task {
+            try await Task.sleep(for: .milliseconds(300))
+            try await server.start()
+        }
+        let stream = try await client.openStream(
+            to: AddrInfo(peer: serverPeerID, addresses: [address]),
+            protocol: EchoProtocol.interopProtocolID,
+            retry: Self.quickRetry
+        )
+        try await lateStart.value
+        try await stream.close()
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("The last error is thrown once the attempts run out", .timeLimit(.minutes(1)))
+    func givesUpAfterMaxAttempts() async throws {
+        let hub = MemoryHub()
+        let client = makeNode(hub: hub)
+        try await client.start()
+        let absent = KeyPair.generateEd25519().peerID
+
+        let clock = ContinuousClock()
+        let start = clock.now
+        await #expect(throws: (any Error).self) {
+            _ = try await client.openStream(
+                to: AddrInfo(peer: absent, addresses: [Multiaddr.memory(id: "open-stream-nobody")]),
+                protocol: EchoProtocol.interopProtocolID,
+                retry: RetryPolicy(maxAttempts: 3, backoff: BackoffStrategy(kind: .constant(.milliseconds(50)), jitter: 0))
+            )
+        }
+        // Two waits between three attempts
+        #expect(clock.now - start >= .milliseconds(100))
+
+        try await client.shutdown()
+        hub.reset()
+    }
+
+    @Test("A protocol the peer does not support is not retried", .timeLimit(.minutes(1)))
+    func unsupportedProtocolIsFinal() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "open-stream-unsupported")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+
+        let clock = ContinuousClock()
+        let start = clock.now
+        await #expect(throws: NegotiationError.noAgreement) {
+            _ = try await client.openStream(
+                to: AddrInfo(peer: serverPeerID, addresses: [address]),
+                protocol: "/app/missing/1.0.0",
+                retry: RetryPolicy(maxAttempts: 5, backoff: BackoffStrategy(kind: .constant(.seconds(5)), jitter: 0))
+            )
+        }
+        #expect(clock.now - start < .seconds(5))
+        // The connection is kept; only the stream was refused
+        #expect(await client.connectedPeers == [serverPeerID])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A dead existing connection is closed and redialed", .timeLimit(.minutes(1)))
+    func redialsDeadConnection() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "open-stream-dead")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+
+        _ = try await client.connect(to: address)
+        let original = try #require(await client.connection(to: serverPeerID)?.yamuxConnection)
+        try await original.close()
+
+        let stream = try await client.openStream(
+            to: AddrInfo(peer: serverPeerID, addresses: [address]),
+            protocol: EchoProtocol.interopProtocolID,
+            retry: Self.quickRetry
+        )
+        let current = try #require(await client.connection(to: serverPeerID)?.yamuxConnection)
+        #expect(current !== original)
+
+        try await stream.write(ByteBuffer(bytes: Array("again".utf8)))
+        try await stream.closeWrite()
+        var echoed = ByteBuffer()
+        while true {
+            var data = try await stream.read()
+            if data.readableBytes == 0 { break }
+            echoed.writeBuffer(&data)
+        }
+        #expect(String(buffer: echoed) == "again")
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Only connection-level failures retire the connection")
+    func connectionFailureClassification() {
+        let peer = KeyPair.generateEd25519().peerID
+        #expect(RetryPolicy.isConnectionFailure(NodeError.notConnected(peer)))
+        #expect(RetryPolicy.isConnectionFailure(NodeError.streamClosed))
+        #expect(RetryPolicy.isConnectionFailure(NegotiationError.negotiationTimeout))
+        #expect(RetryPolicy.isConnectionFailure(YamuxError.connectionClosed))
+        #expect(!RetryPolicy.isConnectionFailure(NodeError.resourceLimitExceeded(scope: "peer", resource: "streams")))
+        #expect(!RetryPolicy.isConnectionFailure(NegotiationError.noAgreement))
+        #expect(!RetryPolicy.isConnectionFailure(CancellationError()))
+    }
+
+    @Test("Addresses naming a different peer are not dialed", .timeLimit(.minutes(1)))
+    func mismatchedPeerIDIsRejected() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "open-stream-mismatch")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+        await EchoProtocol.registerEcho(on: server)
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = await server.peerID
+        let wanted = KeyPair.generateEd25519().peerID
+
+        do {
+            _ = try await client.openStream(
+                to: AddrInfo(peer: wanted, addresses: [try address.appending(.p2p(serverPeerID))]),
+                protocol: EchoProtocol.interopProtocolID,
+                retry: Self.quickRetry
+            )
+            Issue.record("Expected the address to be rejected")
+        } catch NodeError.noAddressesKnown(let peer) {
+            #expect(peer == wanted)
+        }
+        #expect(await client.connectedPeers.isEmpty)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+}
@@ -61,6 +61,22 @@ struct P2PTests {
         #expect(config.healthCheck == nil)
     }
 
+    @Test("NodeConfiguration metrics reach Yamux muxers without their own exporter")
+    func testNodeConfigurationInstrumentsYamux() throws {
+        let nodeMetrics = PrometheusExporter()
+        let ownMetrics = PrometheusExporter()
+        let muxers = NodeConfiguration.instrument(
+            [YamuxMuxer(), YamuxMuxer(configuration: YamuxConfiguration(metrics: ownMetrics))],
+            metrics: nodeMetrics
+        )
+
+        let instrumented = try #require(muxers[0] as? YamuxMuxer)
+        #expect(instrumented.configuration.metrics as? PrometheusExporter === nodeMetrics)
+        let own = try #require(muxers[1] as? YamuxMuxer)
+        #expect(own.configuration.metrics as? PrometheusExporter === ownMetrics)
+        #expect((NodeConfiguration.instrument([YamuxMuxer()], metrics: nil)[0] as? YamuxMuxer)?.configuration.metrics == nil)
+    }
+
     // MARK: - Node Initialization Tests
 
     @Test("Node initializes with configuration")
@@ -398,6 +414,95 @@ struct UpgradeAndNodeErrorTests {
         }
     }
 
+    @Test("NegotiatingUpgrader times out a stalled security negotiation and closes the connection")
+    func upgraderSecurityNegotiationTimeout() async throws {
+        let raw = StallingRawConnection(reads: [])
+        let upgrader = NegotiatingUpgrader(
+            security: [PassthroughSecurityUpgrader(id: "/mock-security/1.0.0")],
+            muxers: [MockMuxer(id: "/yamux/1.0.0")],
+            negotiationTimeout: .milliseconds(50)
+        )
+
+        do {
+            _ = try await upgrader.upgrade(
+                raw,
+                localKeyPair: .generateEd25519(),
+                role: .initiator,
+                expectedPeer: nil
+            )
+            Issue.record("Expected timeout")
+        } catch let error as UpgradeError {
+            guard case .timeout(let phase) = error else {
+                Issue.record("Expected timeout but got \(error)")
+                return
+            }
+            #expect(phase == .securityNegotiation)
+        }
+        #expect(raw.isClosed)
+    }
+
+    @Test("NegotiatingUpgrader times out a stalled security handshake")
+    func upgraderSecurityHandshakeTimeout() async throws {
+        let securityProtocol = "/mock-security/1.0.0"
+        var negotiation = MultistreamSelect.encode(MultistreamSelect.protocolID)
+        var negotiatedSecurity = MultistreamSelect.encode(securityProtocol)
+        negotiation.writeBuffer(&negotiatedSecurity)
+        let raw = StallingRawConnection(reads: [negotiation])
+        let upgrader = NegotiatingUpgrader(
+            security: [StallingSecurityUpgrader(id: securityProtocol)],
+            muxers: [MockMuxer(id: "/yamux/1.0.0")],
+            handshakeTimeout: .milliseconds(50)
+        )
+
+        do {
+            _ = try await upgrader.upgrade(
+                raw,
+                localKeyPair: .generateEd25519(),
+                role: .initiator,
+                expectedPeer: nil
+            )
+            Issue.record("Expected timeout")
+        } catch let error as UpgradeError {
+            guard case .timeout(let phase) = error else {
+                Issue.record("Expected timeout but got \(error)")
+                return
+            }
+            #expect(phase == .securityHandshake)
+        }
+        #expect(raw.isClosed)
+    }
+
+    @Test("NegotiatingUpgrader times out a stalled muxer negotiation")
+    func upgraderMuxerNegotiationTimeout() async throws {
+        let securityProtocol = "/mock-security/1.0.0"
+        var negotiation = MultistreamSelect.encode(MultistreamSelect.protocolID)
+        var negotiatedSecurity = MultistreamSelect.encode(securityProtocol)
+        negotiation.writeBuffer(&negotiatedSecurity)
+        let raw = StallingRawConnection(reads: [negotiation])
+        let upgrader = NegotiatingUpgrader(
+            security: [PassthroughSecurityUpgrader(id: securityProtocol)],
+            muxers: [MockMuxer(id: "/yamux/1.0.0")],
+            negotiationTimeout: .milliseconds(50)
+        )
+
+        do {
+            _ = try await upgrader.upgrade(
+                raw,
+                localKeyPair: .generateEd25519(),
+                role: .initiator,
+                expectedPeer: nil
+            )
+            Issue.record("Expected timeout")
+        } catch let error as UpgradeError {
+            guard case .timeout(let phase) = error else {
+                Issue.record("Expected timeout but got \(error)")
+                return
+            }
+            #expect(phase == .muxerNegotiation)
+        }
+        #expect(raw.isClosed)
+    }
+
     @Test("Node.connect throws noSuitableTransport when no transport can dial")
     func nodeNoSuitableTransport() async throws {
         let node = Node(configuration: .init(transports: [], security: [], muxers: []))
@@ -598,6 +703,75 @@ private final class ScriptedRawConnection: RawConnection, Sendable {
     func close() async throws {}
 }
 
+/// Raw connection that serves scripted reads, then blocks until closed —
+/// a peer that starts the upgrade and goes silent.
+private final class StallingRawConnection: RawConnection, Sendable {
+    private struct State: Sendable {
+        var reads: [ByteBuffer]
+        var waiter: CheckedContinuation<ByteBuffer, Error>?
+        var isClosed = false
+    }
+
+    var localAddress: Multiaddr? { nil }
+    var remoteAddress: Multiaddr { Multiaddr.tcp(host: "127.0.0.1", port: 4001) }
+    private let state: Mutex<State>
+
+    var isClosed: Bool { state.withLock { $0.isClosed } }
+
+    init(reads: [ByteBuffer]) {
+        self.state = Mutex(State(reads: reads))
+    }
+
+    func read() async throws -> ByteBuffer {
+        try await withCheckedThrowingContinuation { continuation in
+            let immediate = state.withLock { s -> Result<ByteBuffer, Error>? in
+                if s.isClosed {
+                    return .failure(UpgradeError.connectionClosed)
+                }
+                if !s.reads.isEmpty {
+                    return .success(s.reads.removeFirst())
+                }
+                s.waiter = continuation
+                return nil
+            }
+            if let immediate {
+                continuation.resume(with: immediate)
+            }
+        }
+    }
+
+    func write(_ data: ByteBuffer) async throws {}
+
+    func close() async throws {
+        let waiter = state.withLock { s -> CheckedContinuation<ByteBuffer, Error>? in
+            s.isClosed = true
+            let waiter = s.waiter
+            s.waiter = nil
+            return waiter
+        }
+        waiter?.resume(throwing: UpgradeError.connectionClosed)
+    }
+}
+
+/// SecurityUpgrader whose handshake waits for a message that never arrives.
+private struct StallingSecurityUpgrader: SecurityUpgrader {
+    let protocolID: String
+
+    init(id: String) {
+        self.protocolID = id
+    }
+
+    func secure(
+        _ connection: any RawConnection,
+        localKeyPair: KeyPair,
+        as role: SecurityRole,
+        expectedPeer: PeerID?
+    ) async throws -> any SecuredConnection {
+        _ = try await connection.read()
+        throw UpgradeError.connectionClosed
+    }
+}
+
 /// SecurityUpgrader that returns a static secured connection for upgrader pipeline tests.
 private struct PassthroughSecurityUpgrader: SecurityUpgrader {
     let protocolID: String
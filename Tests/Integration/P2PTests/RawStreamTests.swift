@@ -0,0 +1,217 @@
+/// RawStreamTests - Raw byte access to negotiated streams
+///
+/// Tests exact reads across chunk boundaries, ad-hoc framing between two
+/// nodes over newRawStream / handleRaw, and multistream-select nested inside
+/// an already negotiated stream.
+
+import Testing
+import Foundation
+import NIOCore
+import Synchronization
+@testable import P2P
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PNegotiation
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Raw Stream Tests", .serialized)
+struct RawStreamTests {
+
+    static let framedEcho = "/test/framed-echo/1.0.0"
+    static let router = "/test/router/1.0.0"
+
+    @Test("readExactly spans chunks and keeps the rest for the next read")
+    func readExactlyAcrossChunks() async throws {
+        let stream = ChunkedMuxedStream(reads: [[1, 2], [3, 4, 5], [6]])
+        let raw = RawStream(stream: stream, protocolID: "/test/raw/1.0.0", remotePeer: KeyPair.generateEd25519().peerID)
+
+        #expect(try await raw.readExactly(4) == [1, 2, 3, 4])
+        #expect(try await raw.read() == [5])
+        #expect(try await raw.read() == [6])
+        #expect(try await raw.read() == [])
+    }
+
+    @Test("readExactly reports how much arrived before end-of-stream")
+    func readExactlyAtEndOfStream() async throws {
+        let stream = ChunkedMuxedStream(reads: [[1, 2, 3]])
+        let raw = RawStream(stream: stream, protocolID: "/test/raw/1.0.0", remotePeer: KeyPair.generateEd25519().peerID)
+
+        await #expect(throws: RawStreamError.endOfStream(received: 3, expected: 8)) {
+            _ = try await raw.readExactly(8)
+        }
+        #expect(try await raw.read() == [1, 2, 3])
+    }
+
+    @Test("Length-prefixed frames round-trip between raw streams", .timeLimit(.minutes(1)))
+    func framedEchoBetweenNodes() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "raw-stream-framed-echo")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+
+        let served = Mutex<[PeerID]>([])
+        await server.handleRaw(Self.framedEcho) { raw in
+            do {
+                served.withLock { $0.append(raw.remotePeer) }
+                // 2-byte big-endian length, then the frame, echoed back as is
+                while true {
+                    let header = try await raw.readExactly(2)
+                    let length = Int(header[0]) << 8 | Int(header[1])
+                    let frame = try await raw.readExactly(length)
+                    try await raw.write(header + frame)
+                }
+            } catch {
+                // The client closed the stream
+            }
+            do {
+                try await raw.close()
+            } catch {
+                // Ignore close failures in test handler cleanup.
+            }
+        }
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let raw = try await client.newRawStream(to: serverPeerID, protocol: Self.framedEcho)
+        #expect(raw.protocolID == Self.framedEcho)
+        #expect(raw.remotePeer == serverPeerID)
+
+        for size in [0, 1, 300, 4096] {
+            let frame = (0..<size).map { UInt8($0 % 251) }
+            let header: [UInt8] = [UInt8(size >> 8), UInt8(size & 0xFF)]
+            try await raw.write(header + frame)
+
+            let echoedHeader = try await raw.readExactly(2)
+            #expect(echoedHeader == header)
+            #expect(try await raw.readExactly(size) == frame)
+        }
+        try await raw.close()
+
+        let clientPeerID = await client.peerID
+        #expect(served.withLock { $0 } == [clientPeerID])
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A handler negotiates a sub-protocol over the stream it was given", .timeLimit(.minutes(1)))
+    func nestedNegotiationBetweenNodes() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "raw-stream-nested")
+        let server = makeNode(hub: hub, listenAddress: address)
+        let client = makeNode(hub: hub)
+
+        // The router answers with the sub-protocol it picked, then closes
+        await server.handleRaw(Self.router) { raw in
+            do {
+                let inner = try await MultistreamSelect.negotiate(
+                    on: raw,
+                    protocols: ["/test/sub-a/1.0.0", "/test/sub-b/1.0.0"],
+                    role: .responder
+                )
+                try await inner.write(Array(inner.protocolID.utf8))
+                try await inner.closeWrite()
+            } catch {
+                do {
+                    try await raw.reset()
+                } catch {
+                    // Ignore reset failures in test handler cleanup.
+                }
+            }
+        }
+
+        try await server.start()
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let raw = try await client.newRawStream(to: serverPeerID, protocol: Self.router)
+        let inner = try await MultistreamSelect.negotiate(
+            on: raw,
+            protocols: ["/test/sub-c/1.0.0", "/test/sub-b/1.0.0"],
+            role: .initiator
+        )
+        #expect(inner.protocolID == "/test/sub-b/1.0.0")
+        #expect(inner.remotePeer == serverPeerID)
+        var reply: [UInt8] = []
+        while true {
+            let chunk = try await inner.read()
+            if chunk.isEmpty { break }
+            reply += chunk
+        }
+        #expect(String(decoding: reply, as: UTF8.self) == "/test/sub-b/1.0.0")
+
+        // No common sub-protocol
+        let refused = try await client.newRawStream(to: serverPeerID, protocol: Self.router)
+        await #expect(throws: NegotiationError.noAgreement) {
+            _ = try await MultistreamSelect.negotiate(on: refused, protocols: ["/test/sub-x/1.0.0"], role: .initiator)
+        }
+        try await refused.close()
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Bytes coalesced with the nested agreement are read first")
+    func nestedNegotiationKeepsRemainder() async throws {
+        var reply = MultistreamSelect.encode(MultistreamSelect.protocolID)
+        var agreed = MultistreamSelect.encode("/test/sub/1.0.0")
+        reply.writeBuffer(&agreed)
+        let stream = ChunkedMuxedStream(reads: [Array(reply.readableBytesView) + [7, 8], [9]])
+        let raw = RawStream(stream: stream, protocolID: Self.router, remotePeer: KeyPair.generateEd25519().peerID)
+
+        let inner = try await MultistreamSelect.negotiate(on: raw, protocols: ["/test/sub/1.0.0"], role: .initiator)
+        #expect(inner.protocolID == "/test/sub/1.0.0")
+        #expect(try await inner.readExactly(3) == [7, 8, 9])
+        #expect(try await inner.read() == [])
+    }
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+}
+
+/// Muxed stream that returns scripted chunks, then end-of-stream.
+private final class ChunkedMuxedStream: MuxedStream, Sendable {
+    let id: UInt64 = 1
+    let protocolID: String? = nil
+    private let queuedReads: Mutex<[[UInt8]]>
+
+    init(reads: [[UInt8]]) {
+        self.queuedReads = Mutex(reads)
+    }
+
+    func read() async throws -> ByteBuffer {
+        queuedReads.withLock { reads in
+            guard !reads.isEmpty else { return ByteBuffer() }
+            return ByteBuffer(bytes: reads.removeFirst())
+        }
+    }
+
+    func write(_ data: ByteBuffer) async throws {}
+
+    func closeWrite() async throws {}
+
+    func closeRead() async throws {}
+
+    func close() async throws {}
+
+    func reset() async throws {}
+}
@@ -318,14 +318,14 @@ struct SecurityHardeningTests {
         #expect(gater.interceptDial(peer: nil, address: allowed))
     }
 
-    // MARK: - Finding #10: PeerID rejects unsupported key type at derivation
+    // MARK: - Finding #10: PeerID rejects malformed keys at derivation
 
-    @Test("Unsupported key types are rejected at PublicKey derivation", .timeLimit(.minutes(1)))
-    func unsupportedKeyTypeRejectedAtDerivation() throws {
-        // secp256k1 / RSA are unsupported by design: construction fails fast,
-        // so a PeerID can never be derived from an unverifiable key.
+    @Test("Malformed keys are rejected at PublicKey derivation", .timeLimit(.minutes(1)))
+    func malformedKeyRejectedAtDerivation() throws {
+        // Construction fails fast, so a PeerID can never be derived from an
+        // unverifiable key.
         #expect(throws: PublicKeyError.self) {
-            _ = try PublicKey(keyType: .secp256k1, rawBytes: Data(repeating: 0x02, count: 33))
+            _ = try PublicKey(keyType: .secp256k1, rawBytes: Data([0x02]) + Data(repeating: 0xFF, count: 32))
         }
         #expect(throws: PublicKeyError.self) {
             _ = try PublicKey(keyType: .rsa, rawBytes: Data(repeating: 0x01, count: 64))
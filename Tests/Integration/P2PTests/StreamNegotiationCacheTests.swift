@@ -0,0 +1,163 @@
+/// StreamNegotiationCacheTests - Optimistic protocol negotiation on stream open
+///
+/// Tests that outbound streams skip the interactive multistream-select round
+/// trip for protocols the peer is known to support, and fall back when that
+/// knowledge turns out to be wrong.
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PCore
+@testable import P2PNegotiation
+@testable import P2PTransportMemory
+@testable import P2PSecurityPlaintext
+@testable import P2PMuxYamux
+
+@Suite("Stream Negotiation Cache Tests", .serialized)
+struct StreamNegotiationCacheTests {
+
+    static let echo = "/test/echo/1.0.0"
+
+    private func makeNode(hub: MemoryHub, listenAddress: Multiaddr? = nil) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddress.map { [$0] } ?? [],
+            transports: [MemoryTransport(hub: hub)],
+            security: [PlaintextUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+
+    private func startEchoServer(hub: MemoryHub, address: Multiaddr) async throws -> Node {
+        let server = makeNode(hub: hub, listenAddress: address)
+        await server.handle(Self.echo) { context in
+            do {
+                let data = try await context.stream.read()
+                try await context.stream.write(data)
+                try await context.stream.close()
+            } catch {
+                // Stream closed
+            }
+        }
+        try await server.start()
+        return server
+    }
+
+    private func echoRoundTrip(_ client: Node, to peer: PeerID, message: String) async throws {
+        let stream = try await client.newStream(to: peer, protocol: Self.echo)
+        try await stream.write(ByteBuffer(string: message))
+        let response = try await stream.read()
+        #expect(String(buffer: response) == message)
+        try await stream.close()
+    }
+
+    @Test("Second stream for a negotiated protocol takes one round trip", .timeLimit(.minutes(1)))
+    func repeatedProtocolIsOptimistic() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "negotiation-cache-repeat")
+        let server = try await startEchoServer(hub: hub, address: address)
+        let client = makeNode(hub: hub)
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        try await echoRoundTrip(client, to: serverPeerID, message: "first")
+        var stats = await client.streamNegotiationStats()
+        #expect(stats.negotiations == 1)
+        #expect(stats.optimisticNegotiations == 0)
+        #expect(stats.roundTrips == 2)
+
+        try await echoRoundTrip(client, to: serverPeerID, message: "second")
+        stats = await client.streamNegotiationStats()
+        #expect(stats.negotiations == 2)
+        #expect(stats.optimisticNegotiations == 1)
+        #expect(stats.roundTrips == 3)
+        #expect(stats.averageRoundTrips == 1.5)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Protocols in the protocol book are negotiated optimistically", .timeLimit(.minutes(1)))
+    func protoBookProtocolIsOptimistic() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "negotiation-cache-protobook")
+        let server = try await startEchoServer(hub: hub, address: address)
+        let client = makeNode(hub: hub)
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        await client.protoBook.addProtocols([Self.echo], for: serverPeerID)
+        try await echoRoundTrip(client, to: serverPeerID, message: "hello")
+
+        let stats = await client.streamNegotiationStats()
+        #expect(stats.negotiations == 1)
+        #expect(stats.optimisticNegotiations == 1)
+        #expect(stats.roundTrips == 1)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("A rejected optimistic proposal removes the protocol from the protocol book", .timeLimit(.minutes(1)))
+    func rejectedProposalEvictsProtocol() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "negotiation-cache-rejected")
+        let server = try await startEchoServer(hub: hub, address: address)
+        let client = makeNode(hub: hub)
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        let missing = "/test/missing/1.0.0"
+        await client.protoBook.addProtocols([missing], for: serverPeerID)
+        await #expect(throws: NegotiationError.noAgreement) {
+            _ = try await client.newStream(to: serverPeerID, protocol: missing)
+        }
+
+        let stats = await client.streamNegotiationStats()
+        #expect(stats.optimisticNegotiations == 1)
+        #expect(stats.optimisticRejections == 1)
+        #expect(await client.protoBook.protocols(for: serverPeerID).contains(missing) == false)
+
+        // The next attempt goes back to interactive negotiation
+        await #expect(throws: NegotiationError.noAgreement) {
+            _ = try await client.newStream(to: serverPeerID, protocol: missing)
+        }
+        #expect(await client.streamNegotiationStats().optimisticNegotiations == 1)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+
+    @Test("Negotiated protocols are forgotten when the peer disconnects", .timeLimit(.minutes(1)))
+    func disconnectForgetsPeer() async throws {
+        let hub = MemoryHub()
+        let address = Multiaddr.memory(id: "negotiation-cache-disconnect")
+        let server = try await startEchoServer(hub: hub, address: address)
+        let client = makeNode(hub: hub)
+        try await client.start()
+        let serverPeerID = try await client.connect(to: address)
+
+        try await echoRoundTrip(client, to: serverPeerID, message: "before")
+        await client.disconnect(from: serverPeerID)
+        _ = try await client.connect(to: address)
+        try await echoRoundTrip(client, to: serverPeerID, message: "after")
+
+        let stats = await client.streamNegotiationStats()
+        #expect(stats.negotiations == 2)
+        #expect(stats.optimisticNegotiations == 0)
+
+        try await client.shutdown()
+        try await server.shutdown()
+        hub.reset()
+    }
+}
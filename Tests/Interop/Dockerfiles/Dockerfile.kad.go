@@ -29,5 +29,6 @@ FROM alpine:3.19
 COPY --from=builder /app/go-libp2p-kad-test /usr/local/bin/go-libp2p-kad-test
 
 EXPOSE 4001/udp
+EXPOSE 4001/tcp
 
 ENTRYPOINT ["/usr/local/bin/go-libp2p-kad-test"]
@@ -2,6 +2,84 @@
 # Debug Dockerfile for go-libp2p Noise handshake
 #
 # This creates a go-libp2p node with debug logging for Noise handshake
+#
+# SUPPORTED_SECURITY (comma-separated, default /noise) sets the security
+# protocols the responder advertises and accepts during multistream-select.
+# FAULT (corrupt-mac, wrong-key, truncate-b, oversize, stall, bad-signature)
+# makes the responder misbehave during the handshake; truncate-b keeps
+# FAULT_TRUNCATE_BYTES bytes of Message B (default 40).
+# SEND_EXTENSIONS (comma-separated stream muxers, e.g. /yamux/1.0.0) makes the
+# responder add a NoiseExtensions field to Message B's payload. Received
+# extensions are logged as "EXT: muxers=[...] certhashes=N"; unknown extension
+# fields are listed, not rejected.
+#
+# After every handshake, successful or not, the node logs one TRANSCRIPT: line
+# with a JSON record of the messages, remote identity and handshake hash.
+#
+# Each accepted connection gets an increasing ID and its log lines are tagged
+# "conn=<n>". When its handler returns the node logs
+# "CONN_DONE: conn=<n> outcome=... bytes_in=N bytes_out=N remote=...". MAX_CONNS
+# (default unlimited) caps concurrent connections; extra ones are closed and
+# logged as CONN_REJECTED. A panic in one handler is logged as PANIC and does
+# not stop the accept loop.
+#
+# EXPECTED_REMOTE_PEER (peer ID) and EXPECTED_REMOTE_STATIC (hex Noise static
+# key) are compared with the initiator's verified identity from Message C and
+# logged as "PEER_CHECK: expected=... actual=... match=<bool>" and
+# "STATIC_CHECK: ...". With STRICT=1 a mismatch fails the handshake and the
+# node exits with status 1.
+#
+# Every received handshake payload is checked and logged as
+# "PAYLOAD_VERDICT: identity=<peerID|-> sig=valid|invalid reason=<reason>",
+# reason one of ok, malformed-payload, missing-identity-key, bad-identity-key,
+# missing-signature, bad-signature. An invalid Message C is only reported
+# unless STRICT=1, which closes the connection right after Message C
+# ("CONN_DONE: ... outcome=invalid_payload") without exiting.
+#
+# Transport frames are numbered per direction in the SEND/RECV lines
+# ("RECV cs1 (...) frame=<n> len=<ciphertext bytes> nonce=a->b ..."). A frame
+# declaring more than 65535 bytes is logged as "FRAME_TOO_LARGE: len=<n>" and
+# closes the connection (outcome frame_too_large); the 2-byte prefix cannot
+# declare more, so the check only makes the limit explicit. The stdin command
+# "SEND_LARGE <bytes>" sends a plaintext of that size (byte i is i%251) split
+# into maximum-size frames and logs
+# "SEND_LARGE: bytes=N frames=N max_frame=N nonce=<first>-><next>".
+#
+# WRITE_CHUNK_SIZE splits every frame the responder writes (multistream
+# messages, Message B, transport frames) into writes of at most that many
+# bytes, WRITE_DELAY_MS apart; with 1 even the 2-byte Noise length prefix is
+# split. Each frame's plan is logged as "WRITE_CHUNKS: frame=<n> bytes=N ...".
+#
+# RESPONSE_DELAY_MS sleeps that long before every frame the responder writes,
+# and PAUSE_AFTER (multistream, message-a) holds each connection after its
+# multistream-select response or after reading Message A until the stdin
+# command RESUME, which logs "RESUME: released=N". Every artificial wait is
+# bracketed by "DELAY_START: kind=response|pause ... at=<RFC3339Nano>" and
+# "DELAY_END: ... waited_ms=N at=<RFC3339Nano>". Both combine with FAULT.
+#
+# CAPTURE_DIR records every connection's raw bytes, multistream through
+# transport frames, to <dir>/conn-<n>-<role>.cap (timestamped, direction- and
+# phase-tagged length-prefixed records) plus a conn-<n>-<role>.json index, and
+# logs "CAPTURE: file=... records=N bytes_in=N bytes_out=N". The format is
+# documented next to captureConn in main.go. "noise-debug --replay <file>
+# [host:port]" replays a capture's initiator side against a live responder
+# (default DIAL_ADDR, else 127.0.0.1:4001), logging REPLAY: per record and
+# REPLAY_DONE: ... diverged_at=<seq> stopped_at=<seq>.
+#
+# COMPAT=strict makes the responder behave like a go-libp2p v0.36 listener:
+# it writes the multistream header before reading the dialer's, answers ls
+# and protocols other than /noise with na, closes on a non-minimal varint
+# length prefix (with RST when the dialer had already sent more, as
+# go-multistream leaves those bytes unread), closes after an invalid Message
+# C payload whatever STRICT says, and offers /yamux/1.0.0 as early data
+# unless SEND_EXTENSIONS is set. It cannot be combined with FAULT. The
+# default, COMPAT=lenient, accepts all of that input. Either way each case is
+# logged as "COMPAT: rule=<rule> mode=... granted=<bool> go-libp2p=na|close"
+# and listed in the TRANSCRIPT line's "leniencies" next to "compat"; a
+# connection that ends during multistream-select also gets a TRANSCRIPT line.
+#
+# The builder stage runs the node's own checks (*_test.go, build tag
+# framingtest), so an image only builds when they pass.
 
 FROM golang:1.23-alpine AS builder
 
@@ -18,7 +96,9 @@ RUN go get github.com/libp2p/go-libp2p/p2p/muxer/yamux@v0.36
 RUN go get github.com/flynn/noise
 
 COPY Dockerfiles/generated/Dockerfile.noise.debug.go/main.go main.go
+COPY Dockerfiles/generated/Dockerfile.noise.debug.go/*_test.go ./
 RUN go build -o noise-debug main.go
+RUN go test -tags framingtest .
 
 FROM alpine:3.19
 COPY --from=builder /app/noise-debug /usr/local/bin/noise-debug
@@ -1,8 +1,10 @@
 # syntax=docker/dockerfile:1.7
 # Dockerfile for go-libp2p Noise-only test node
 #
-# This creates a go-libp2p node that uses TCP + Noise
-# specifically for testing Noise protocol handshake.
+# A go-libp2p node on TCP + Noise for testing the Noise handshake, security
+# and muxer ordering, identity key types and ping/bulk transfers. Its
+# environment variables, stdin commands and log lines are documented in
+# main.go and in the shared/*.go files copied below.
 
 FROM golang:1.23-alpine AS builder
 
@@ -17,13 +19,19 @@ RUN go mod init go-libp2p-noise-test
 # Add dependencies
 RUN go get github.com/libp2p/go-libp2p@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/security/noise@v0.36
+RUN go get github.com/libp2p/go-libp2p/p2p/security/tls@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/transport/tcp@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/muxer/yamux@v0.36
+# mplex is no longer part of go-libp2p; the standalone module still builds against it
+RUN go get github.com/libp2p/go-libp2p-mplex@v0.9.0
 
 # Create the test server
 COPY Dockerfiles/generated/Dockerfile.noise.go/main.go main.go
+COPY Dockerfiles/generated/shared/eventlog.go eventlog.go
+COPY Dockerfiles/generated/shared/perf.go perf.go
+COPY Dockerfiles/generated/shared/echo.go echo.go
 # Build the application
-RUN go build -o go-libp2p-noise-test main.go
+RUN go build -o go-libp2p-noise-test .
 
 # Final image
 FROM alpine:3.19
@@ -0,0 +1,33 @@
+# syntax=docker/dockerfile:1.7
+# Dockerfile for a minimal SOCKS5 proxy
+#
+# This creates a standard-library-only SOCKS5 proxy (CONNECT only) that sits
+# between two nodes so tests can check that a dial went through it. It
+# listens on $LISTEN_PORT (default 1080); PROXY_USER and PROXY_PASS require
+# username/password authentication. It prints "SOCKS5_LISTEN: addr=<host:port>
+# auth=<none|userpass>" at startup, "SOCKS5_CONNECT: client=<addr>
+# user=<name|-> target=<host:port> result=<ok|err>" for every CONNECT,
+# "SOCKS5_AUTH_FAILED: client=<addr> user=<name>" for rejected credentials
+# and "SOCKS5_CLOSE: client=<addr> target=<host:port> up_bytes=<n>
+# down_bytes=<n>" when a tunnel ends.
+
+FROM golang:1.23-alpine AS builder
+
+WORKDIR /app
+
+# Initialize Go module
+RUN go mod init socks5-proxy-test
+
+# Create the proxy
+COPY Dockerfiles/generated/Dockerfile.socks5.go/main.go main.go
+# Build the application
+RUN go build -o socks5-proxy-test main.go
+
+# Final image
+FROM alpine:3.19
+
+COPY --from=builder /app/socks5-proxy-test /usr/local/bin/socks5-proxy-test
+
+EXPOSE 1080/tcp
+
+ENTRYPOINT ["/usr/local/bin/socks5-proxy-test"]
@@ -3,6 +3,14 @@
 #
 # This creates a go-libp2p node that listens on TCP with Noise security
 # and supports Identify and Ping protocols.
+#
+# /test/echo/1.0.0 echoes through an ECHO_BUF_BYTES buffer (default 32768)
+# and prints ECHO_DONE: peer=<id> bytes=<n> duration_ms=<d> once the stream
+# closes.
+#
+# PERF=1 also serves the libp2p perf protocol (/perf/1.0.0) and prints
+# PERF: peer=<id> up_bytes=<a> down_bytes=<b> up_mbps=<x> down_mbps=<y>
+# duration_ms=<d> per stream.
 
 FROM golang:1.23-alpine AS builder
 
@@ -22,8 +30,10 @@ RUN go get github.com/libp2p/go-libp2p/p2p/muxer/yamux@v0.36
 
 # Create the test server
 COPY Dockerfiles/generated/Dockerfile.tcp.go/main.go main.go
+COPY Dockerfiles/generated/shared/perf.go perf.go
+COPY Dockerfiles/generated/shared/echo.go echo.go
 # Build the application
-RUN go build -o go-libp2p-tcp-test main.go
+RUN go build -o go-libp2p-tcp-test .
 
 # Final image
 FROM alpine:3.19
@@ -1,8 +1,10 @@
 # syntax=docker/dockerfile:1.7
 # Dockerfile for go-libp2p WebSocket test node
 #
-# This creates a go-libp2p node that listens on WebSocket with Noise security
-# and supports Identify and Ping protocols.
+# A go-libp2p node listening on WebSocket with Noise security, serving
+# Identify, Ping, /test/echo, /test/frag and /perf. Its environment
+# variables, stdin commands and log lines are documented in main.go and in
+# the shared/*.go files copied below.
 
 FROM golang:1.23-alpine AS builder
 
@@ -19,11 +21,26 @@ RUN go get github.com/libp2p/go-libp2p@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/security/noise@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/transport/websocket@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/muxer/yamux@v0.36
+# mplex is no longer part of go-libp2p; the standalone module still builds against it
+RUN go get github.com/libp2p/go-libp2p-mplex@v0.9.0
+RUN go get github.com/gorilla/websocket
 
 # Create the test server
 COPY Dockerfiles/generated/Dockerfile.ws.go/main.go main.go
+COPY Dockerfiles/generated/shared/wsdial.go wsdial.go
+COPY Dockerfiles/generated/shared/perf.go perf.go
+COPY Dockerfiles/generated/shared/httpfront.go httpfront.go
+COPY Dockerfiles/generated/shared/listen.go listen.go
+COPY Dockerfiles/generated/shared/muxers.go muxers.go
+COPY Dockerfiles/generated/shared/drain.go drain.go
+COPY Dockerfiles/generated/shared/proxy.go proxy.go
+COPY Dockerfiles/generated/shared/wsbuffers.go wsbuffers.go
+COPY Dockerfiles/generated/shared/frag.go frag.go
+COPY Dockerfiles/generated/shared/observed.go observed.go
+COPY Dockerfiles/generated/shared/bandwidth.go bandwidth.go
+COPY Dockerfiles/generated/shared/echo.go echo.go
 # Build the application
-RUN go build -o go-libp2p-ws-test main.go
+RUN go build -o go-libp2p-ws-test .
 
 # Final image
 FROM alpine:3.19
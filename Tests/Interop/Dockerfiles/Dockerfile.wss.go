@@ -1,8 +1,10 @@
 # syntax=docker/dockerfile:1.7
 # Dockerfile for go-libp2p WSS (Secure WebSocket) test node
 #
-# This creates a go-libp2p node that listens on WSS (TLS + WebSocket)
-# with Noise security and supports Identify and Ping protocols.
+# A go-libp2p node listening on WSS (TLS + WebSocket) with Noise security.
+# Certificate rotation, pinning, client auth and TLS policy are documented in
+# main.go, the WebSocket features it shares with the ws node in the
+# shared/*.go files copied below. certs_test.go runs in the builder stage.
 
 FROM golang:1.23-alpine AS builder
 
@@ -14,6 +16,19 @@ RUN apk add --no-cache git openssl
 # Generate self-signed certificate
 RUN openssl req -x509 -newkey rsa:2048 -keyout key.pem -out cert.pem \
     -days 365 -nodes -subj "/CN=localhost"
+RUN openssl req -x509 -newkey rsa:2048 -keyout key2.pem -out cert2.pem \
+    -days 730 -nodes -subj "/CN=localhost"
+
+# Generate a client CA, a client certificate it signs, and an untrusted one
+RUN openssl req -x509 -newkey rsa:2048 -keyout client-ca-key.pem -out client-ca.pem \
+    -days 365 -nodes -subj "/CN=client-ca"
+RUN printf 'extendedKeyUsage=clientAuth\n' > client.ext && \
+    openssl req -newkey rsa:2048 -keyout client-key.pem -out client.csr \
+    -nodes -subj "/CN=swift-client" && \
+    openssl x509 -req -in client.csr -CA client-ca.pem -CAkey client-ca-key.pem \
+    -CAcreateserial -out client-cert.pem -days 365 -extfile client.ext
+RUN openssl req -x509 -newkey rsa:2048 -keyout client-untrusted-key.pem \
+    -out client-untrusted-cert.pem -days 365 -nodes -subj "/CN=stranger"
 
 # Initialize Go module
 RUN go mod init go-libp2p-wss-test
@@ -23,11 +38,22 @@ RUN go get github.com/libp2p/go-libp2p@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/security/noise@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/transport/websocket@v0.36
 RUN go get github.com/libp2p/go-libp2p/p2p/muxer/yamux@v0.36
+# mplex is no longer part of go-libp2p; the standalone module still builds against it
+RUN go get github.com/libp2p/go-libp2p-mplex@v0.9.0
 
 # Create the test server
 COPY Dockerfiles/generated/Dockerfile.wss.go/main.go main.go
+COPY Dockerfiles/generated/shared/wsdial.go wsdial.go
+COPY Dockerfiles/generated/shared/perf.go perf.go
+COPY Dockerfiles/generated/shared/httpfront.go httpfront.go
+COPY Dockerfiles/generated/shared/listen.go listen.go
+COPY Dockerfiles/generated/shared/muxers.go muxers.go
+COPY Dockerfiles/generated/shared/bandwidth.go bandwidth.go
+COPY Dockerfiles/generated/shared/echo.go echo.go
+COPY Dockerfiles/generated/Dockerfile.wss.go/certs_test.go certs_test.go
 # Build the application
-RUN go build -o go-libp2p-wss-test main.go
+RUN go build -o go-libp2p-wss-test .
+RUN go test -tags certtest .
 
 # Final image
 FROM alpine:3.19
@@ -35,10 +61,17 @@ FROM alpine:3.19
 COPY --from=builder /app/go-libp2p-wss-test /usr/local/bin/go-libp2p-wss-test
 COPY --from=builder /app/cert.pem /cert.pem
 COPY --from=builder /app/key.pem /key.pem
+COPY --from=builder /app/cert2.pem /cert2.pem
+COPY --from=builder /app/key2.pem /key2.pem
+COPY --from=builder /app/client-ca.pem /client-ca.pem
+COPY --from=builder /app/client-cert.pem /client-cert.pem
+COPY --from=builder /app/client-key.pem /client-key.pem
+COPY --from=builder /app/client-untrusted-cert.pem /client-untrusted-cert.pem
+COPY --from=builder /app/client-untrusted-key.pem /client-untrusted-key.pem
 
 EXPOSE 4001/tcp
 
-ENV CERT_FILE=/cert.pem
-ENV KEY_FILE=/key.pem
+# CERT_FILE and KEY_FILE default to /cert.pem and /key.pem; they are left
+# unset so CERT_ALGO can ask for a generated certificate.
 
 ENTRYPOINT ["/usr/local/bin/go-libp2p-wss-test"]
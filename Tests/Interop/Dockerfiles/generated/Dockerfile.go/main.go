@@ -21,6 +21,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid port: %v", err)
 	}
+	echoBuf, err := echoBufferSize()
+	if err != nil {
+		log.Fatalf("Invalid echo buffer: %v", err)
+	}
 
 	// Create a new libp2p host with QUIC transport
 	h, err := libp2p.New(
@@ -48,22 +52,13 @@ func main() {
 	// Set up stream handler for custom protocols
 	h.SetStreamHandler("/test/echo/1.0.0", func(s network.Stream) {
 		log.Printf("Received stream from %s", s.Conn().RemotePeer())
-		defer s.Close()
-
-		// Echo back whatever is received
-		buf := make([]byte, 1024)
-		for {
-			n, err := s.Read(buf)
-			if err != nil {
-				return
-			}
-			if n > 0 {
-				log.Printf("Echo: %d bytes", n)
-				s.Write(buf[:n])
-			}
-		}
+		echoStream(s, echoBuf, nil)
 	})
 
+	if os.Getenv("PERF") == "1" {
+		h.SetStreamHandler(perfProtocol, handlePerf)
+	}
+
 	// Keep the process running
 	select {}
 }
@@ -40,10 +40,12 @@ func main() {
 		dhtMode = "server"
 	}
 
-	// Create libp2p host with QUIC transport
+	// Create libp2p host with QUIC and TCP transports (TCP on the same port
+	// number, for full Swift nodes that do not dial QUIC)
 	h, err := libp2p.New(
 		libp2p.ListenAddrStrings(
 			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", port),
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
 		),
 		libp2p.Ping(true),
 	)
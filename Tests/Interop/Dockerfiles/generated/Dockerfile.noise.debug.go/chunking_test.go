@@ -0,0 +1,118 @@
+//go:build framingtest
+
+// Chunked-write checks for the debug node. Run inside the builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingWriter keeps each Write call's bytes separately.
+type recordingWriter struct {
+	writes [][]byte
+}
+
+func (r *recordingWriter) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, bytes.Clone(p))
+	return len(p), nil
+}
+
+func TestChunkedWriterSplitsFrames(t *testing.T) {
+	rec := &recordingWriter{}
+	var logs strings.Builder
+	w := writeChunking{size: 1}.wrap(rec, log.New(&logs, "", 0))
+
+	if err := writeFrame(w, []byte{0xaa, 0xbb, 0xcc}); err != nil {
+		t.Fatal(err)
+	}
+	// The 2-byte length prefix goes out in two separate writes
+	want := [][]byte{{0x00}, {0x03}, {0xaa}, {0xbb}, {0xcc}}
+	if len(rec.writes) != len(want) {
+		t.Fatalf("got %d writes, want %d: %x", len(rec.writes), len(want), rec.writes)
+	}
+	for i := range want {
+		if !bytes.Equal(rec.writes[i], want[i]) {
+			t.Errorf("write %d = %x, want %x", i, rec.writes[i], want[i])
+		}
+	}
+	if want := "WRITE_CHUNKS: frame=1 bytes=5 chunk_size=1 chunks=5 delay_ms=0\n"; logs.String() != want {
+		t.Errorf("logs = %q, want %q", logs.String(), want)
+	}
+
+	rec.writes = nil
+	w = writeChunking{size: 4, delay: time.Millisecond}.wrap(rec, log.New(&logs, "", 0))
+	start := time.Now()
+	if _, err := w.Write(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.writes) != 3 || len(rec.writes[2]) != 2 {
+		t.Errorf("10 bytes in chunks of 4 gave writes of %d bytes each", lengths(rec.writes))
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Millisecond {
+		t.Errorf("writes took %s, want at least two delays", elapsed)
+	}
+}
+
+func TestWriteChunkingDisabledByDefault(t *testing.T) {
+	rec := &recordingWriter{}
+	if w := (writeChunking{}).wrap(rec, nil); w != rec {
+		t.Errorf("zero chunk size wrapped the writer: %T", w)
+	}
+}
+
+func TestLoadWriteChunking(t *testing.T) {
+	t.Setenv("WRITE_CHUNK_SIZE", "1")
+	t.Setenv("WRITE_DELAY_MS", "5")
+	c, err := loadWriteChunking()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.size != 1 || c.delay != 5*time.Millisecond {
+		t.Errorf("loadWriteChunking() = %+v", c)
+	}
+
+	t.Setenv("WRITE_CHUNK_SIZE", "")
+	if _, err := loadWriteChunking(); err == nil {
+		t.Error("WRITE_DELAY_MS without WRITE_CHUNK_SIZE accepted")
+	}
+	t.Setenv("WRITE_CHUNK_SIZE", "0")
+	if _, err := loadWriteChunking(); err == nil {
+		t.Error("WRITE_CHUNK_SIZE=0 accepted")
+	}
+}
+
+// Byte-at-a-time negotiation, handshake and echo against the Go initiator.
+func TestHandshakeCompletesWithByteChunks(t *testing.T) {
+	acceptor, listener, logs := startAcceptor(t, 0, func(a *connAcceptor) {
+		a.cfg.chunking = writeChunking{size: 1}
+	})
+
+	conn, err := dialHandshake(listener.Addr().String(), newHandshakeResult(t).key)
+	if err != nil {
+		t.Fatalf("handshake with chunked responder: %v", err)
+	}
+	conn.Close()
+	acceptor.wait()
+
+	output := logs.String()
+	if !strings.Contains(output, "CONN_DONE: conn=1 outcome=handshake_ok") {
+		t.Fatalf("handshake did not complete:\n%s", output)
+	}
+	if !strings.Contains(output, "WRITE_CHUNKS: frame=1 bytes=20 chunk_size=1 chunks=20") {
+		t.Errorf("multistream header was not chunked:\n%s", output)
+	}
+}
+
+func lengths(writes [][]byte) []int {
+	out := make([]int, len(writes))
+	for i, w := range writes {
+		out[i] = len(w)
+	}
+	return out
+}
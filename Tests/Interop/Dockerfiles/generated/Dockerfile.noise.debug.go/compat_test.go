@@ -0,0 +1,197 @@
+//go:build framingtest
+
+// COMPAT=strict checks for the debug node. Run inside the builder image
+// with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+)
+
+func negotiateCompat(t *testing.T, mode compatMode, supported []string, stream []byte) (string, *frameReader, *compatReport, error) {
+	t.Helper()
+	var out bytes.Buffer
+	report := newCompatReport(mode)
+	selected, err := negotiateSecurity(newFrameReader(bytes.NewReader(stream)), &out, supported, report,
+		log.New(io.Discard, "", 0))
+	return selected, newFrameReader(&out), report, err
+}
+
+func expectLeniencies(t *testing.T, report *compatReport, granted bool, rules ...compatRule) {
+	t.Helper()
+	if len(report.leniencies) != len(rules) {
+		t.Fatalf("got leniencies %+v, want rules %v", report.leniencies, rules)
+	}
+	for i, rule := range rules {
+		l := report.leniencies[i]
+		if l.Rule != rule || l.Granted != granted || l.GoLibp2p != rule.goOutcome() {
+			t.Errorf("leniency %d = %+v, want rule %s granted=%t", i, l, rule, granted)
+		}
+	}
+}
+
+func TestStrictWritesHeaderBeforeReading(t *testing.T) {
+	// The dialer has sent nothing yet
+	_, replies, _, err := negotiateCompat(t, compatStrict, []string{noiseProtocol}, nil)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n")
+
+	_, replies, _, _ = negotiateCompat(t, compatLenient, []string{noiseProtocol}, nil)
+	expectReplies(t, replies)
+}
+
+func TestStrictAnswersListWithNA(t *testing.T) {
+	supported := []string{noiseProtocol}
+	selected, replies, report, err := negotiateCompat(t, compatStrict, supported, proposals("ls", noiseProtocol))
+	if err != nil || selected != noiseProtocol {
+		t.Fatalf("selected %q, err %v", selected, err)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "na\n", noiseProtocol+"\n")
+	expectLeniencies(t, report, false, ruleListProtocols)
+
+	_, replies, report, err = negotiateCompat(t, compatLenient, supported, proposals("ls", noiseProtocol))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "/noise\n\n", noiseProtocol+"\n")
+	expectLeniencies(t, report, true, ruleListProtocols)
+}
+
+func TestStrictRefusesUnimplementedProtocol(t *testing.T) {
+	supported := []string{"/tls/1.0.0", noiseProtocol}
+	selected, replies, report, err := negotiateCompat(t, compatStrict, supported, proposals("/tls/1.0.0", noiseProtocol))
+	if err != nil || selected != noiseProtocol {
+		t.Fatalf("selected %q, err %v", selected, err)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "na\n", noiseProtocol+"\n")
+	expectLeniencies(t, report, false, ruleUnimplementedProtocol)
+	if report.leniencies[0].Detail != "/tls/1.0.0" {
+		t.Errorf("detail = %q", report.leniencies[0].Detail)
+	}
+}
+
+func TestStrictRejectsNonMinimalVarint(t *testing.T) {
+	// 0x93 0x00 encodes 19, the header's length, in two bytes
+	header := multistreamProtocol + "\n"
+	stream := append([]byte{0x80 | byte(len(header)), 0x00}, header...)
+	stream = append(stream, multistreamFrame(noiseProtocol+"\n")...)
+
+	_, _, report, err := negotiateCompat(t, compatStrict, []string{noiseProtocol}, stream)
+	if err == nil || !strings.Contains(err.Error(), "non-minimal") {
+		t.Fatalf("err = %v, want non-minimal length prefix", err)
+	}
+	expectLeniencies(t, report, false, ruleNonMinimalVarint)
+
+	selected, _, report, err := negotiateCompat(t, compatLenient, []string{noiseProtocol}, stream)
+	if err != nil || selected != noiseProtocol {
+		t.Fatalf("selected %q, err %v", selected, err)
+	}
+	expectLeniencies(t, report, true, ruleNonMinimalVarint)
+}
+
+func TestStrictHasNoProposalLimit(t *testing.T) {
+	lines := make([]string, maxNegotiationAttempts+1)
+	for i := range lines {
+		lines[i] = "/unknown/1.0.0"
+	}
+	lines[maxNegotiationAttempts] = noiseProtocol
+
+	if _, _, _, err := negotiateCompat(t, compatLenient, []string{noiseProtocol}, proposals(lines...)); err == nil {
+		t.Fatal("lenient negotiation should give up")
+	}
+	selected, _, report, err := negotiateCompat(t, compatStrict, []string{noiseProtocol}, proposals(lines...))
+	if err != nil || selected != noiseProtocol {
+		t.Fatalf("selected %q, err %v", selected, err)
+	}
+	expectLeniencies(t, report, false)
+}
+
+// respondToEmptyPayload runs the responder against a hand-rolled initiator
+// whose Message C carries an empty payload, and returns the responder's
+// transcript and error.
+func respondToEmptyPayload(t *testing.T, mode compatMode) (*handshakeTranscript, error) {
+	t.Helper()
+	initConn, respConn := net.Pipe()
+	defer initConn.Close()
+	discard := log.New(io.Discard, "", 0)
+	tr := newHandshakeTranscript("responder")
+	tr.compat = newCompatReport(mode)
+
+	respErr := make(chan error, 1)
+	go func() {
+		defer respConn.Close()
+		_, _, err := respondNoiseHandshake(newFrameReader(respConn), respConn, newHandshakeResult(t).key,
+			responderConfig{}, tr, discard)
+		respErr <- err
+	}()
+
+	_, hs, err := newHandshakeState(true, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgA, _, _, err := hs.WriteMessage(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFrame(initConn, msgA); err != nil {
+		t.Fatal(err)
+	}
+	msgB, err := newFrameReader(initConn).readNoiseFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := hs.ReadMessage(nil, msgB); err != nil {
+		t.Fatal(err)
+	}
+	msgC, _, _, err := hs.WriteMessage(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFrame(initConn, msgC); err != nil {
+		t.Fatal(err)
+	}
+	err = <-respErr
+	return tr, err
+}
+
+func TestStrictClosesOnInvalidPayload(t *testing.T) {
+	tr, err := respondToEmptyPayload(t, compatStrict)
+	if !errors.Is(err, errInvalidPayload) {
+		t.Fatalf("responder error = %v, want invalid payload", err)
+	}
+	expectLeniencies(t, tr.compat, false, ruleInvalidPayload)
+
+	tr, err = respondToEmptyPayload(t, compatLenient)
+	if err != nil {
+		t.Fatalf("lenient responder failed: %v", err)
+	}
+	expectLeniencies(t, tr.compat, true, ruleInvalidPayload)
+}
+
+func TestTranscriptSummarizesLeniencies(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	report := newCompatReport(compatLenient)
+	report.allow(logger, ruleListProtocols, "")
+	tr := newHandshakeTranscript("responder")
+	tr.compat = report
+	tr.emit(logger, nil)
+
+	parsed := parseTranscript(t, logs.String())
+	if parsed.Compat != compatLenient || len(parsed.Leniencies) != 1 || parsed.Leniencies[0].Rule != ruleListProtocols {
+		t.Fatalf("transcript compat=%q leniencies=%+v", parsed.Compat, parsed.Leniencies)
+	}
+	if !strings.Contains(logs.String(), "COMPAT: rule=ls mode=lenient granted=true go-libp2p=na") {
+		t.Errorf("COMPAT line missing:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,234 @@
+//go:build framingtest
+
+// Concurrent connection handling checks for the debug node. Run inside the
+// builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// syncBuffer is a log destination shared by the per-connection loggers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// panicConn panics on the first read, standing in for a handler bug.
+type panicConn struct {
+	net.Conn
+}
+
+func (panicConn) Read([]byte) (int, error) {
+	panic("injected read panic")
+}
+
+// startAcceptor serves a loopback listener with a connAcceptor whose logs go
+// to the returned buffer. Each option adjusts the acceptor before it serves.
+func startAcceptor(t *testing.T, maxConns int, options ...func(*connAcceptor)) (*connAcceptor, net.Listener, *syncBuffer) {
+	t.Helper()
+	logs := &syncBuffer{}
+	acceptor := newConnAcceptor(newHandshakeResult(t).key, responderConfig{supported: []string{noiseProtocol}},
+		maxConns, log.New(logs, "[responder] ", log.Lmsgprefix))
+	for _, option := range options {
+		option(acceptor)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			acceptor.accept(conn)
+		}
+	}()
+	return acceptor, listener, logs
+}
+
+// dialHandshake negotiates /noise and completes a handshake as initiator,
+// leaving the connection open for the caller to close.
+func dialHandshake(addr string, key crypto.PrivKey) (net.Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(proposals(noiseProtocol)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fr := newFrameReader(conn)
+	for _, want := range []string{multistreamProtocol + "\n", noiseProtocol + "\n"} {
+		got, err := fr.readMultistreamMessage()
+		if err != nil || string(got) != want {
+			conn.Close()
+			return nil, fmt.Errorf("negotiation reply %q, %v; want %q", got, err, want)
+		}
+	}
+	logger := log.New(io.Discard, "", 0)
+	if _, _, _, err := initiateNoiseHandshake(fr, conn, key, newHandshakeTranscript("initiator"), logger); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+var connDonePattern = regexp.MustCompile(`CONN_DONE: conn=(\d+) outcome=(\w+) bytes_in=(\d+) bytes_out=(\d+) remote=`)
+
+func TestConcurrentConnectionsAreTagged(t *testing.T) {
+	const n = 10
+	acceptor, listener, logs := startAcceptor(t, 0)
+
+	keys := make([]crypto.PrivKey, n)
+	for i := range keys {
+		keys[i] = newHandshakeResult(t).key
+	}
+	conns := make(chan net.Conn, n)
+	errs := make(chan error, n)
+	for _, key := range keys {
+		go func() {
+			conn, err := dialHandshake(listener.Addr().String(), key)
+			if err != nil {
+				errs <- err
+				return
+			}
+			conns <- conn
+		}()
+	}
+	var open []net.Conn
+	for range n {
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		case conn := <-conns:
+			open = append(open, conn)
+		}
+	}
+	// Closing the initiators ends the responders' echo sessions
+	for _, conn := range open {
+		conn.Close()
+	}
+	acceptor.wait()
+
+	output := logs.String()
+	done := connDonePattern.FindAllStringSubmatch(output, -1)
+	if len(done) != n {
+		t.Fatalf("got %d CONN_DONE lines, want %d:\n%s", len(done), n, output)
+	}
+	seen := map[string]bool{}
+	for _, m := range done {
+		id, outcome := m[1], m[2]
+		seen[id] = true
+		if outcome != outcomeHandshakeOK || m[3] == "0" || m[4] == "0" {
+			t.Errorf("conn=%s: outcome=%s bytes_in=%s bytes_out=%s", id, outcome, m[3], m[4])
+		}
+		// Every connection logged its own transcript
+		if !strings.Contains(output, "[responder] conn="+id+" TRANSCRIPT: ") {
+			t.Errorf("conn=%s has no tagged TRANSCRIPT line", id)
+		}
+	}
+	for id := 1; id <= n; id++ {
+		if !seen[fmt.Sprint(id)] {
+			t.Errorf("no CONN_DONE for conn=%d", id)
+		}
+	}
+}
+
+func TestMaxConnsRejectsExtraConnections(t *testing.T) {
+	acceptor, listener, logs := startAcceptor(t, 1)
+
+	// The first connection holds the only slot while negotiation waits
+	held, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if _, err := held.Write(multistreamFrame(multistreamProtocol + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFrameReader(held).readMultistreamMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rejected.Close()
+	if _, err := rejected.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("rejected connection read: %v, want EOF", err)
+	}
+	if !strings.Contains(logs.String(), "CONN_REJECTED: conn=2 ") {
+		t.Fatalf("no CONN_REJECTED line:\n%s", logs)
+	}
+
+	// Releasing the slot admits the next connection
+	held.Close()
+	acceptor.wait()
+	conn, err := dialHandshake(listener.Addr().String(), newHandshakeResult(t).key)
+	if err != nil {
+		t.Fatalf("connection after release: %v", err)
+	}
+	conn.Close()
+	acceptor.wait()
+	if !strings.Contains(logs.String(), "CONN_DONE: conn=3 outcome=handshake_ok") {
+		t.Fatalf("conn=3 did not complete:\n%s", logs)
+	}
+}
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	acceptor, listener, logs := startAcceptor(t, 1)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	acceptor.accept(panicConn{server})
+	acceptor.wait()
+
+	output := logs.String()
+	if !strings.Contains(output, "[responder] conn=1 PANIC: injected read panic") {
+		t.Fatalf("panic not logged:\n%s", output)
+	}
+	if !strings.Contains(output, "CONN_DONE: conn=1 outcome=panic") {
+		t.Fatalf("panic outcome not logged:\n%s", output)
+	}
+
+	// The slot is released and later connections are served
+	conn, err := dialHandshake(listener.Addr().String(), newHandshakeResult(t).key)
+	if err != nil {
+		t.Fatalf("connection after panic: %v", err)
+	}
+	conn.Close()
+	acceptor.wait()
+	if !strings.Contains(logs.String(), "CONN_DONE: conn=2 outcome=handshake_ok") {
+		t.Fatalf("conn=2 did not complete:\n%s", logs)
+	}
+}
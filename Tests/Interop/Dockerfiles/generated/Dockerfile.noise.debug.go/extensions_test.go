@@ -0,0 +1,88 @@
+//go:build framingtest
+
+// Noise extensions checks for the debug node. Run inside the builder image
+// with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtensionsRoundTrip(t *testing.T) {
+	ext := &noiseExtensions{
+		certHashes:   [][]byte{{0x12, 0x20, 0xaa}},
+		streamMuxers: []string{"/yamux/1.0.0", "/mplex/6.7.0"},
+	}
+	got, err := parseNoiseExtensions(ext.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.streamMuxers, ext.streamMuxers) {
+		t.Errorf("muxers = %v, want %v", got.streamMuxers, ext.streamMuxers)
+	}
+	if len(got.certHashes) != 1 || !bytes.Equal(got.certHashes[0], ext.certHashes[0]) {
+		t.Errorf("certhashes = %x", got.certHashes)
+	}
+	if want := "muxers=[/yamux/1.0.0 /mplex/6.7.0] certhashes=1"; got.String() != want {
+		t.Errorf("String() = %q, want %q", got.String(), want)
+	}
+}
+
+func TestExtensionsReportUnknownFields(t *testing.T) {
+	var b []byte
+	b = appendBytesField(b, extensionsFieldStreamMuxers, []byte("/yamux/1.0.0"))
+	b = appendBytesField(b, 7, []byte("future"))
+	b = append(b, 9<<3|0, 0x01) // varint field 9
+
+	ext, err := parseNoiseExtensions(b)
+	if err != nil {
+		t.Fatalf("unknown fields must not be rejected: %v", err)
+	}
+	if !slices.Equal(ext.unknownFields, []uint64{7, 9}) {
+		t.Errorf("unknown fields = %v, want [7 9]", ext.unknownFields)
+	}
+	if want := "muxers=[/yamux/1.0.0] certhashes=0 unknown_fields=[7 9]"; ext.String() != want {
+		t.Errorf("String() = %q, want %q", ext.String(), want)
+	}
+}
+
+func TestPayloadWithoutExtensions(t *testing.T) {
+	var b []byte
+	b = appendBytesField(b, payloadFieldIdentityKey, []byte{1})
+	b = appendBytesField(b, payloadFieldIdentitySig, []byte{2})
+
+	p, err := parseHandshakePayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.extensions != nil {
+		t.Errorf("extensions = %v, want nil", p.extensions)
+	}
+}
+
+func TestResponderSendsExtensions(t *testing.T) {
+	initiator, _ := runHandshakePair(t, responderConfig{
+		extensions: &noiseExtensions{streamMuxers: []string{"/yamux/1.0.0"}},
+	})
+	if !initiator.transcript.Success {
+		t.Fatalf("handshake failed: %s", initiator.transcript.Error)
+	}
+	if !strings.Contains(initiator.logs, "EXT: muxers=[/yamux/1.0.0] certhashes=0\n") {
+		t.Errorf("initiator did not report the responder's extensions:\n%s", initiator.logs)
+	}
+}
+
+func TestBadSignatureFaultWithExtensions(t *testing.T) {
+	initiator, _ := runHandshakePair(t, responderConfig{
+		fault:      faultConfig{mode: faultBadSignature},
+		extensions: &noiseExtensions{streamMuxers: []string{"/yamux/1.0.0"}},
+	})
+	if !strings.Contains(initiator.logs, "sig_valid=false") {
+		t.Errorf("signature fault did not survive appended extensions:\n%s", initiator.logs)
+	}
+}
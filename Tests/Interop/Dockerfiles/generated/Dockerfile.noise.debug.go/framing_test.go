@@ -0,0 +1,125 @@
+//go:build framingtest
+
+// Framing checks for the debug node. Run inside the builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// chunkingReader returns at most size bytes per Read, simulating a peer whose
+// frames arrive split across many TCP segments.
+type chunkingReader struct {
+	data []byte
+	size int
+}
+
+func (c *chunkingReader) Read(p []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+	n := min(c.size, len(p), len(c.data))
+	copy(p, c.data[:n])
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func noiseFrame(payload []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(payload))), payload...)
+}
+
+func multistreamFrame(msg string) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(msg))), msg...)
+}
+
+// transcript is a full initiator-side byte stream: multistream negotiation,
+// a handshake message and a transport frame larger than a bufio buffer.
+func transcript() ([]byte, [][]byte) {
+	handshake := bytes.Repeat([]byte{0xab}, 32)
+	transport := bytes.Repeat([]byte{0xcd}, 10_000)
+
+	var stream []byte
+	stream = append(stream, multistreamFrame(multistreamProtocol+"\n")...)
+	stream = append(stream, multistreamFrame(noiseProtocol+"\n")...)
+	stream = append(stream, noiseFrame(handshake)...)
+	stream = append(stream, noiseFrame(nil)...)
+	stream = append(stream, noiseFrame(transport)...)
+	return stream, [][]byte{[]byte(multistreamProtocol + "\n"), []byte(noiseProtocol + "\n"), handshake, {}, transport}
+}
+
+func readTranscript(t *testing.T, fr *frameReader, want [][]byte) {
+	t.Helper()
+	for i, expected := range want {
+		var got []byte
+		var err error
+		if i < 2 {
+			got, err = fr.readMultistreamMessage()
+		} else {
+			got, err = fr.readNoiseFrame()
+		}
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("frame %d: got %d bytes, want %d", i, len(got), len(expected))
+		}
+	}
+	if _, err := fr.readNoiseFrame(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF after last frame, got %v", err)
+	}
+}
+
+func TestFrameReaderSplitSegments(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 7, 4096} {
+		stream, want := transcript()
+		readTranscript(t, newFrameReader(&chunkingReader{data: stream, size: size}), want)
+	}
+}
+
+func TestFrameReaderCoalescedSegments(t *testing.T) {
+	stream, want := transcript()
+	readTranscript(t, newFrameReader(bytes.NewReader(stream)), want)
+}
+
+func TestFrameReaderSeparateLengthAndBodyWrites(t *testing.T) {
+	// The Swift side writes the length prefix and the body separately.
+	pr, pw := io.Pipe()
+	payload := bytes.Repeat([]byte{0x42}, 300)
+	go func() {
+		frame := noiseFrame(payload)
+		pw.Write(frame[:1])
+		pw.Write(frame[1:2])
+		pw.Write(frame[2:100])
+		pw.Write(frame[100:])
+		pw.Close()
+	}()
+
+	got, err := newFrameReader(pr).readNoiseFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(payload))
+	}
+}
+
+func TestFrameReaderTruncatedBody(t *testing.T) {
+	frame := noiseFrame([]byte("truncated"))
+	fr := newFrameReader(&chunkingReader{data: frame[:len(frame)-1], size: 1})
+	if _, err := fr.readNoiseFrame(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFrameReaderRejectsOversizedMultistreamMessage(t *testing.T) {
+	stream := binary.AppendUvarint(nil, maxMultistreamMessageSize+1)
+	if _, err := newFrameReader(bytes.NewReader(stream)).readMultistreamMessage(); err == nil {
+		t.Fatal("expected oversized multistream message to be rejected")
+	}
+}
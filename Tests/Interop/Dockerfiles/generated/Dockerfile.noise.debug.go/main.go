@@ -1,196 +1,2337 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/flynn/noise"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// Prefix of the message signed by the identity key over the Noise static key
+// (libp2p noise spec, "Static Key Authentication").
+const staticKeySignaturePrefix = "noise-libp2p-static-key:"
+
+const (
+	multistreamProtocol = "/multistream/1.0.0"
+	noiseProtocol       = "/noise"
+
+	maxMultistreamMessageSize = 1024
+	// Proposals (including ls) accepted before the responder gives up.
+	maxNegotiationAttempts = 16
+)
+
+// Plaintext of the encrypted frame sent after the handshake in initiator mode.
+var echoTestPayload = []byte("noise-debug-echo")
+
+// Largest Noise transport message (2-byte length prefix), including the
+// 16-byte ChaChaPoly tag; plaintext is chunked to fit.
+const (
+	maxNoiseMessageSize = 65535
+	noiseTagSize        = 16
+	maxPlaintextChunk   = maxNoiseMessageSize - noiseTagSize
+)
+
+// faultMode selects deliberate responder misbehaviour (FAULT env var) so the
+// dialer's handshake error handling can be conformance-tested.
+type faultMode string
+
+const (
+	faultNone         faultMode = ""
+	faultCorruptMAC   faultMode = "corrupt-mac"   // flip a bit in Message B's payload tag
+	faultWrongKey     faultMode = "wrong-key"     // sign a static key other than the one sent
+	faultTruncateB    faultMode = "truncate-b"    // send only the first N bytes of Message B
+	faultOversize     faultMode = "oversize"      // claim a 70000-byte Message B
+	faultStall        faultMode = "stall"         // read Message A, never answer
+	faultBadSignature faultMode = "bad-signature" // corrupt the identity signature
+)
+
+var faultModes = []faultMode{
+	faultCorruptMAC, faultWrongKey, faultTruncateB, faultOversize, faultStall, faultBadSignature,
+}
+
+const (
+	// Default byte count kept by truncate-b (FAULT_TRUNCATE_BYTES overrides);
+	// shorter than ephemeral + encrypted static key.
+	defaultTruncateBytes = 40
+	// Length the oversize fault claims for Message B.
+	oversizeFrameLength = 70000
+)
+
+type faultConfig struct {
+	mode          faultMode
+	truncateBytes int
+}
+
+// responderConfig is the listener's per-connection behaviour.
+type responderConfig struct {
+	supported []string
+	fault     faultConfig
+	// Sent in Message B's payload when non-nil (SEND_EXTENSIONS).
+	extensions *noiseExtensions
+	expect     expectedPeer
+	chunking   writeChunking
+	// Directory for per-connection wire captures (CAPTURE_DIR); empty disables.
+	captureDir string
+	compat     compatMode
+	pacing     responsePacing
+}
+
+// writeChunking splits every frame the responder writes into small, delayed
+// writes (WRITE_CHUNK_SIZE, WRITE_DELAY_MS) to exercise the dialer's
+// reassembly. A zero size disables it.
+type writeChunking struct {
+	size  int
+	delay time.Duration
+}
+
+// wrap returns w, or a chunkedWriter over it when chunking is enabled.
+func (c writeChunking) wrap(w io.Writer, logger *log.Logger) io.Writer {
+	if c.size == 0 {
+		return w
+	}
+	return &chunkedWriter{w: w, plan: c, logger: logger}
+}
+
+// chunkedWriter writes each frame passed to Write (every caller writes one
+// whole frame per call) in chunks of at most plan.size bytes, sleeping
+// plan.delay between chunks. With a size of 1 even length prefixes are
+// split across writes.
+type chunkedWriter struct {
+	w      io.Writer
+	plan   writeChunking
+	logger *log.Logger
+
+	mu     sync.Mutex // keeps concurrent frames from interleaving
+	frames int
+}
+
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frames++
+	chunks := (len(p) + c.plan.size - 1) / c.plan.size
+	c.logger.Printf("WRITE_CHUNKS: frame=%d bytes=%d chunk_size=%d chunks=%d delay_ms=%d",
+		c.frames, len(p), c.plan.size, chunks, c.plan.delay.Milliseconds())
+
+	written := 0
+	for written < len(p) {
+		if written > 0 && c.plan.delay > 0 {
+			time.Sleep(c.plan.delay)
+		}
+		n, err := c.w.Write(p[written:min(written+c.plan.size, len(p))])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// responsePacing slows the responder down (RESPONSE_DELAY_MS, PAUSE_AFTER)
+// so dialer timeouts can be tested without shaping the network. Every
+// artificial wait is logged as a DELAY_START/DELAY_END pair carrying the
+// wall-clock time it began and ended.
+type responsePacing struct {
+	// Slept before every frame the responder writes.
+	delay time.Duration
+	// Where the handshake is held until the stdin command RESUME.
+	pauseAfter pausePoint
+}
+
+// pausePoint is a place in the responder's handshake where PAUSE_AFTER can
+// hold it.
+type pausePoint string
+
+const (
+	pauseNone pausePoint = ""
+	// After the multistream-select response, before Message A is read
+	pauseAfterMultistream pausePoint = "multistream"
+	// After Message A is read, before Message B is sent
+	pauseAfterMessageA pausePoint = "message-a"
+)
+
+var pausePoints = []pausePoint{pauseAfterMultistream, pauseAfterMessageA}
+
+// wrap returns w, or a delayedWriter over it when RESPONSE_DELAY_MS is set.
+func (p responsePacing) wrap(w io.Writer, logger *log.Logger) io.Writer {
+	if p.delay == 0 {
+		return w
+	}
+	return &delayedWriter{w: w, delay: p.delay, logger: logger}
+}
+
+// pause holds the handshake at point until RESUME when PAUSE_AFTER names it.
+func (p responsePacing) pause(point pausePoint, logger *log.Logger) {
+	if p.pauseAfter == point {
+		resumeGate.hold(point, logger)
+	}
+}
+
+// delayedWriter sleeps before each frame passed to Write (every caller
+// writes one whole frame per call).
+type delayedWriter struct {
+	w      io.Writer
+	delay  time.Duration
+	logger *log.Logger
+
+	mu     sync.Mutex // keeps concurrent frames from interleaving
+	frames int
+}
+
+func (d *delayedWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.frames++
+	start := time.Now()
+	d.logger.Printf("DELAY_START: kind=response frame=%d bytes=%d delay_ms=%d at=%s",
+		d.frames, len(p), d.delay.Milliseconds(), wallClock(start))
+	time.Sleep(d.delay)
+	end := time.Now()
+	d.logger.Printf("DELAY_END: kind=response frame=%d waited_ms=%.1f at=%s",
+		d.frames, millisSince(start, end), wallClock(end))
+	return d.w.Write(p)
+}
+
+// resumeGate holds every connection paused by PAUSE_AFTER until RESUME.
+var resumeGate = newPauseGate()
+
+// pauseGate releases all connections waiting in hold at once.
+type pauseGate struct {
+	mu      sync.Mutex
+	resume  chan struct{}
+	waiting int
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{resume: make(chan struct{})}
+}
+
+// hold blocks until the next release. A RESUME sent before a connection
+// reaches its pause point does not carry over to it.
+func (g *pauseGate) hold(point pausePoint, logger *log.Logger) {
+	g.mu.Lock()
+	resume := g.resume
+	g.waiting++
+	g.mu.Unlock()
+
+	start := time.Now()
+	logger.Printf("DELAY_START: kind=pause after=%s at=%s (send RESUME to continue)", point, wallClock(start))
+	<-resume
+	end := time.Now()
+	logger.Printf("DELAY_END: kind=pause after=%s waited_ms=%.1f at=%s", point, millisSince(start, end), wallClock(end))
+}
+
+// release resumes every held connection and returns how many there were.
+func (g *pauseGate) release() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	released := g.waiting
+	close(g.resume)
+	g.resume = make(chan struct{})
+	g.waiting = 0
+	return released
+}
+
+// wallClock formats t for the DELAY_START/DELAY_END lines, which need more
+// precision than the logger's timestamps.
+func wallClock(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// expectedPeer is the initiator identity the responder checks Message C
+// against (EXPECTED_REMOTE_PEER, EXPECTED_REMOTE_STATIC). Empty fields are
+// not checked.
+type expectedPeer struct {
+	peerID string
+	static string // lowercase hex
+	// A mismatch fails the handshake and exits the process, and an invalid
+	// Message C payload fails the handshake (STRICT=1).
+	strict bool
+}
+
+// errPeerMismatch is returned by the responder handshake when the remote
+// identity differs from the expected one under STRICT=1.
+var errPeerMismatch = errors.New("remote identity does not match the expected peer")
+
+// errInvalidPayload is returned when the remote handshake payload fails its
+// PAYLOAD_VERDICT check.
+var errInvalidPayload = errors.New("invalid remote handshake payload")
+
+// errFrameTooLarge matches frameTooLargeError.
+var errFrameTooLarge = errors.New("noise frame exceeds 65535 bytes")
+
+// frameTooLargeError reports a Noise frame longer than maxNoiseMessageSize.
+type frameTooLargeError struct {
+	length uint64
+}
+
+func (e *frameTooLargeError) Error() string {
+	return fmt.Sprintf("%v: len=%d", errFrameTooLarge, e.length)
+}
+
+func (e *frameTooLargeError) Is(target error) bool {
+	return target == errFrameTooLarge
+}
+
+// loadFaultConfig reads FAULT and FAULT_TRUNCATE_BYTES.
+func loadFaultConfig() (faultConfig, error) {
+	cfg := faultConfig{mode: faultMode(os.Getenv("FAULT")), truncateBytes: defaultTruncateBytes}
+	if cfg.mode != faultNone && !slices.Contains(faultModes, cfg.mode) {
+		return cfg, fmt.Errorf("unknown FAULT %q (want one of %v)", cfg.mode, faultModes)
+	}
+	if v := os.Getenv("FAULT_TRUNCATE_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid FAULT_TRUNCATE_BYTES %q", v)
+		}
+		cfg.truncateBytes = n
+	}
+	return cfg, nil
+}
+
+// loadExpectedPeer reads EXPECTED_REMOTE_PEER, EXPECTED_REMOTE_STATIC and
+// STRICT.
+func loadExpectedPeer() (expectedPeer, error) {
+	var e expectedPeer
+	if v := os.Getenv("EXPECTED_REMOTE_PEER"); v != "" {
+		id, err := peer.Decode(v)
+		if err != nil {
+			return e, fmt.Errorf("invalid EXPECTED_REMOTE_PEER %q: %w", v, err)
+		}
+		e.peerID = id.String()
+	}
+	if v := os.Getenv("EXPECTED_REMOTE_STATIC"); v != "" {
+		key, err := hex.DecodeString(v)
+		if err != nil || len(key) != noise.DH25519.DHLen() {
+			return e, fmt.Errorf("invalid EXPECTED_REMOTE_STATIC %q (want %d hex-encoded bytes)", v, noise.DH25519.DHLen())
+		}
+		e.static = hex.EncodeToString(key)
+	}
+	switch v := os.Getenv("STRICT"); v {
+	case "", "0":
+	case "1":
+		e.strict = true
+	default:
+		return e, fmt.Errorf("invalid STRICT %q (want 0 or 1)", v)
+	}
+	return e, nil
+}
+
+// loadWriteChunking reads WRITE_CHUNK_SIZE and WRITE_DELAY_MS.
+func loadWriteChunking() (writeChunking, error) {
+	var c writeChunking
+	if v := os.Getenv("WRITE_CHUNK_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c, fmt.Errorf("invalid WRITE_CHUNK_SIZE %q (want a positive integer)", v)
+		}
+		c.size = n
+	}
+	if v := os.Getenv("WRITE_DELAY_MS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid WRITE_DELAY_MS %q (want a non-negative integer)", v)
+		}
+		if c.size == 0 {
+			return c, errors.New("WRITE_DELAY_MS requires WRITE_CHUNK_SIZE")
+		}
+		c.delay = time.Duration(n) * time.Millisecond
+	}
+	return c, nil
+}
+
+// loadResponsePacing reads RESPONSE_DELAY_MS and PAUSE_AFTER.
+func loadResponsePacing() (responsePacing, error) {
+	var p responsePacing
+	if v := os.Getenv("RESPONSE_DELAY_MS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid RESPONSE_DELAY_MS %q (want a non-negative integer)", v)
+		}
+		p.delay = time.Duration(n) * time.Millisecond
+	}
+	p.pauseAfter = pausePoint(os.Getenv("PAUSE_AFTER"))
+	if p.pauseAfter != pauseNone && !slices.Contains(pausePoints, p.pauseAfter) {
+		return p, fmt.Errorf("unknown PAUSE_AFTER %q (want one of %v)", p.pauseAfter, pausePoints)
+	}
+	return p, nil
+}
+
+// compatMode selects how closely the responder follows go-libp2p (COMPAT).
+// The default, lenient, accepts input go-libp2p would refuse so that
+// dialers can be debugged past their first mistake; strict answers and
+// closes exactly where go-libp2p v0.36 would.
+type compatMode string
+
+const (
+	compatLenient compatMode = "lenient"
+	compatStrict  compatMode = "strict"
+)
+
+// Stream muxers go-libp2p's Noise responder offers as early data in
+// Message B; strict mode sends them unless SEND_EXTENSIONS is set.
+var goDefaultExtensions = []string{"/yamux/1.0.0"}
+
+// compatRule names input the lenient responder accepts and go-libp2p does
+// not.
+type compatRule string
+
+const (
+	// go-multistream has no ls handler and answers it with na.
+	ruleListProtocols compatRule = "ls"
+	// go-varint rejects a length prefix with redundant continuation bytes.
+	ruleNonMinimalVarint compatRule = "non-minimal-varint"
+	// go-libp2p only selects security protocols it has a transport for.
+	ruleUnimplementedProtocol compatRule = "unimplemented-protocol"
+	// go-libp2p's Noise transport fails the handshake on a Message C
+	// payload it cannot verify.
+	ruleInvalidPayload compatRule = "invalid-payload"
+)
+
+// goOutcome is what go-libp2p does with input breaking the rule: reply na
+// and keep negotiating, or close the connection.
+func (r compatRule) goOutcome() string {
+	switch r {
+	case ruleListProtocols, ruleUnimplementedProtocol:
+		return "na"
+	default:
+		return "close"
+	}
+}
+
+// compatReport collects the leniencies one connection's dialer relied on.
+// The responder emits it in the TRANSCRIPT line, so a lenient run shows
+// where go-libp2p would have behaved differently and a strict run shows
+// where the connection ended.
+type compatReport struct {
+	mode       compatMode
+	leniencies []compatLeniency
+}
+
+type compatLeniency struct {
+	Rule   compatRule `json:"rule"`
+	Detail string     `json:"detail,omitempty"`
+	// What go-libp2p does instead: na or close.
+	GoLibp2p string `json:"go_libp2p"`
+	// Whether this node accepted the input anyway (lenient mode).
+	Granted bool `json:"granted"`
+}
+
+func newCompatReport(mode compatMode) *compatReport {
+	return &compatReport{mode: mode}
+}
+
+// allow records that the dialer broke rule and reports whether the responder
+// carries on as if it had not, which it does unless COMPAT=strict. A nil
+// report (the initiator) always allows.
+func (r *compatReport) allow(logger *log.Logger, rule compatRule, detail string) bool {
+	if r == nil {
+		return true
+	}
+	granted := r.mode != compatStrict
+	r.leniencies = append(r.leniencies, compatLeniency{Rule: rule, Detail: detail, GoLibp2p: rule.goOutcome(), Granted: granted})
+	logger.Printf("COMPAT: rule=%s mode=%s granted=%t go-libp2p=%s detail=%q", rule, r.mode, granted, rule.goOutcome(), detail)
+	return granted
+}
+
+func (r *compatReport) strict() bool {
+	return r != nil && r.mode == compatStrict
+}
+
+// loadCompatMode reads COMPAT: lenient (the default) or strict.
+func loadCompatMode() (compatMode, error) {
+	switch v := compatMode(os.Getenv("COMPAT")); v {
+	case "", compatLenient:
+		return compatLenient, nil
+	case compatStrict:
+		return compatStrict, nil
+	default:
+		return "", fmt.Errorf("invalid COMPAT %q (want %s or %s)", v, compatLenient, compatStrict)
+	}
+}
+
+// resetOnClose makes closing conn send RST instead of FIN by setting a zero
+// linger on the TCP connection beneath the node's wrappers.
+func resetOnClose(conn net.Conn) bool {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c.SetLinger(0) == nil
+		case *countingConn:
+			conn = c.Conn
+		case *captureConn:
+			conn = c.Conn
+		default:
+			return false
+		}
+	}
+}
+
 func main() {
+	// Generate Ed25519 identity key
+	privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	pubBytes, _ := privKey.GetPublic().Raw()
+	captureDir := os.Getenv("CAPTURE_DIR")
+
+	// --replay <file> [host:port] replays a capture's initiator side against
+	// a live responder (default DIAL_ADDR, else the local listener)
+	if len(os.Args) >= 3 && os.Args[1] == "--replay" {
+		addr := os.Getenv("DIAL_ADDR")
+		if len(os.Args) >= 4 {
+			addr = os.Args[3]
+		}
+		if addr == "" {
+			addr = "127.0.0.1:4001"
+		}
+		if err := replayCapture(os.Args[2], addr, newRoleLogger("replay")); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if dialAddr := os.Getenv("DIAL_ADDR"); dialAddr != "" {
+		logger := newRoleLogger("initiator")
+		logger.Printf("Identity public key: %s", hex.EncodeToString(pubBytes))
+		go readCommands(os.Stdin, logger)
+
+		if stage, err := runInitiator(dialAddr, privKey, captureDir, logger); err != nil {
+			logger.Printf("SECURE_ECHO_FAILED stage=%s: %v", stage, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger := newRoleLogger("responder")
+	logger.Printf("Identity public key: %s", hex.EncodeToString(pubBytes))
+	supported := supportedSecurityProtocols()
+	logger.Printf("Supported security protocols: %s", strings.Join(supported, ", "))
+	fault, err := loadFaultConfig()
+	if err != nil {
+		logger.Fatalf("Invalid fault configuration: %v", err)
+	}
+	if fault.mode != faultNone {
+		logger.Printf("Fault mode: %s", fault.mode)
+	}
+	compat, err := loadCompatMode()
+	if err != nil {
+		logger.Fatalf("Invalid compat mode: %v", err)
+	}
+	if compat == compatStrict && fault.mode != faultNone {
+		logger.Fatalf("COMPAT=strict cannot be combined with FAULT (go-libp2p has no faults)")
+	}
+	logger.Printf("Compat mode: %s", compat)
+	extensions := sendExtensions()
+	if extensions == nil && compat == compatStrict {
+		extensions = &noiseExtensions{streamMuxers: goDefaultExtensions}
+	}
+	if extensions != nil {
+		logger.Printf("Sending extensions: %s", extensions)
+	}
+	expect, err := loadExpectedPeer()
+	if err != nil {
+		logger.Fatalf("Invalid expected peer: %v", err)
+	}
+	if expect.peerID != "" || expect.static != "" {
+		logger.Printf("Expecting remote peer=%q static=%q strict=%t", expect.peerID, expect.static, expect.strict)
+	}
+	chunking, err := loadWriteChunking()
+	if err != nil {
+		logger.Fatalf("Invalid write chunking: %v", err)
+	}
+	if chunking.size > 0 {
+		logger.Printf("Chunking writes: size=%d delay=%s", chunking.size, chunking.delay)
+	}
+	pacing, err := loadResponsePacing()
+	if err != nil {
+		logger.Fatalf("Invalid response pacing: %v", err)
+	}
+	if pacing.delay > 0 || pacing.pauseAfter != pauseNone {
+		logger.Printf("Response pacing: delay=%s pause_after=%q", pacing.delay, pacing.pauseAfter)
+	}
+	if captureDir != "" {
+		logger.Printf("Capturing connections to %s", captureDir)
+	}
+	cfg := responderConfig{supported: supported, fault: fault, extensions: extensions, expect: expect, chunking: chunking, captureDir: captureDir, compat: compat, pacing: pacing}
+	maxConns, err := loadMaxConns()
+	if err != nil {
+		logger.Fatalf("Invalid MAX_CONNS: %v", err)
+	}
+	if maxConns > 0 {
+		logger.Printf("Max concurrent connections: %d", maxConns)
+	}
+	acceptor := newConnAcceptor(privKey, cfg, maxConns, logger)
+	go readCommands(os.Stdin, logger)
+
 	portStr := os.Getenv("LISTEN_PORT")
 	if portStr == "" {
 		portStr = "4001"
 	}
 
-	// Generate Ed25519 identity key
-	privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
-	if err != nil {
-		log.Fatalf("Failed to generate key: %v", err)
+	// Listen on TCP
+	listener, err := net.Listen("tcp", ":"+portStr)
+	if err != nil {
+		logger.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	logger.Printf("Listening on TCP port %s", portStr)
+	if id, err := peer.IDFromPrivateKey(privKey); err == nil {
+		// Harnesses extract the peer ID from this line
+		logger.Printf("Listen: /ip4/0.0.0.0/tcp/%s/p2p/%s", portStr, id)
+	}
+	logger.Println("Ready to accept connections")
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			logger.Printf("Accept error: %v", err)
+			continue
+		}
+		acceptor.accept(conn)
+	}
+}
+
+// newRoleLogger returns a logger that tags every line with the node's role.
+func newRoleLogger(role string) *log.Logger {
+	return log.New(os.Stderr, "["+role+"] ", log.LstdFlags|log.Lmsgprefix)
+}
+
+// supportedSecurityProtocols returns the security protocols the responder
+// advertises, from the comma-separated SUPPORTED_SECURITY env var (default
+// /noise). Only /noise has a handshake implementation; other entries can be
+// listed and accepted to exercise the dialer's negotiation, after which the
+// connection is closed.
+func supportedSecurityProtocols() []string {
+	env := os.Getenv("SUPPORTED_SECURITY")
+	if env == "" {
+		return []string{noiseProtocol}
+	}
+	var protocols []string
+	for _, p := range strings.Split(env, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			protocols = append(protocols, p)
+		}
+	}
+	return protocols
+}
+
+// sendExtensions returns the extensions the responder puts in Message B,
+// from the comma-separated stream muxer list in SEND_EXTENSIONS, or nil when
+// it is unset.
+func sendExtensions() *noiseExtensions {
+	env, ok := os.LookupEnv("SEND_EXTENSIONS")
+	if !ok {
+		return nil
+	}
+	ext := &noiseExtensions{}
+	for _, m := range strings.Split(env, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			ext.streamMuxers = append(ext.streamMuxers, m)
+		}
+	}
+	return ext
+}
+
+// loadMaxConns reads MAX_CONNS, the limit on concurrently handled
+// connections (0 or unset means unlimited).
+func loadMaxConns() (int, error) {
+	v := os.Getenv("MAX_CONNS")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a non-negative integer", v)
+	}
+	return n, nil
+}
+
+// Outcomes reported in CONN_DONE lines.
+const (
+	outcomeNegotiationFailed   = "negotiation_failed"
+	outcomeUnsupportedProtocol = "unsupported_protocol"
+	outcomeHandshakeFailed     = "handshake_failed"
+	outcomeHandshakeOK         = "handshake_ok"
+	outcomePeerMismatch        = "peer_mismatch"
+	outcomeInvalidPayload      = "invalid_payload"
+	outcomeFrameTooLarge       = "frame_too_large"
+	outcomePanic               = "panic"
+)
+
+// connAcceptor runs each accepted connection in its own goroutine, tagged with
+// a monotonically increasing ID, and enforces MAX_CONNS.
+type connAcceptor struct {
+	identity crypto.PrivKey
+	cfg      responderConfig
+	maxConns int
+	logger   *log.Logger
+	// Called after a STRICT=1 peer mismatch; os.Exit outside tests.
+	exit func(code int)
+
+	mu     sync.Mutex
+	idle   *sync.Cond // signalled when active drops to zero
+	nextID uint64
+	active int
+}
+
+func newConnAcceptor(identity crypto.PrivKey, cfg responderConfig, maxConns int, logger *log.Logger) *connAcceptor {
+	a := &connAcceptor{identity: identity, cfg: cfg, maxConns: maxConns, logger: logger, exit: os.Exit}
+	a.idle = sync.NewCond(&a.mu)
+	return a
+}
+
+// accept assigns conn an ID and starts its handler, or closes it when
+// MAX_CONNS handlers are already running.
+func (a *connAcceptor) accept(conn net.Conn) {
+	a.mu.Lock()
+	a.nextID++
+	id, active := a.nextID, a.active
+	admitted := a.maxConns == 0 || active < a.maxConns
+	if admitted {
+		a.active++
+	}
+	a.mu.Unlock()
+
+	if !admitted {
+		a.logger.Printf("CONN_REJECTED: conn=%d remote=%s active=%d max=%d", id, conn.RemoteAddr(), active, a.maxConns)
+		conn.Close()
+		return
+	}
+	go a.handle(id, conn)
+}
+
+// wait blocks until no handlers are running.
+func (a *connAcceptor) wait() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.active > 0 {
+		a.idle.Wait()
+	}
+}
+
+// release frees the handler slot taken in accept.
+func (a *connAcceptor) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active--; a.active == 0 {
+		a.idle.Broadcast()
+	}
+}
+
+// handle serves one connection and logs its CONN_DONE line. A panic is
+// recovered and reported so the other connections keep running. Under
+// STRICT=1 a peer mismatch exits the process so the test harness notices.
+func (a *connAcceptor) handle(id uint64, conn net.Conn) {
+	start := time.Now()
+	counted := &countingConn{Conn: conn}
+	logger := log.New(a.logger.Writer(), fmt.Sprintf("%sconn=%d ", a.logger.Prefix(), id), a.logger.Flags())
+	outcome := outcomePanic
+
+	var wire net.Conn = counted
+	var capture *captureConn
+	if a.cfg.captureDir != "" {
+		c, err := openCapture(a.cfg.captureDir, id, "responder", counted)
+		if err != nil {
+			logger.Printf("CAPTURE_FAILED: %v", err)
+		} else {
+			capture, wire = c, c
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Printf("PANIC: %v\n%s", r, debug.Stack())
+		}
+		conn.Close()
+		if capture != nil {
+			capture.finish(logger)
+		}
+		a.logger.Printf("CONN_DONE: conn=%d outcome=%s bytes_in=%d bytes_out=%d remote=%s duration_ms=%.1f",
+			id, outcome, counted.in.Load(), counted.out.Load(), conn.RemoteAddr(), millisSince(start, time.Now()))
+		if outcome == outcomePeerMismatch && a.cfg.expect.strict {
+			a.logger.Printf("STRICT: exiting after conn=%d peer check failure", id)
+			a.exit(1)
+		}
+		a.release()
+	}()
+
+	outcome = handleConnection(wire, a.identity, a.cfg, logger)
+}
+
+// countingConn counts the bytes read from and written to a connection.
+type countingConn struct {
+	net.Conn
+	in, out atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.in.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.out.Add(int64(n))
+	return n, err
+}
+
+// Wire captures (CAPTURE_DIR)
+//
+// Every accepted connection, or the dialled one in initiator mode, is
+// recorded to <CAPTURE_DIR>/conn-<n>-<role>.cap, one record per socket Read
+// or Write, so the file also shows how bytes were split on the wire. The
+// file is self-describing; all integers are big-endian:
+//
+//	header  "NDCAP" (5 bytes) | version = 1 (1 byte)
+//	        | role (1 byte: 0 initiator, 1 responder)
+//	record  dir (1 byte: 0 in, 1 out) | phase (1 byte: 0 multistream,
+//	        1 handshake, 2 transport) | time (8 bytes, nanoseconds since the
+//	        capture started) | length (4 bytes) | data (length bytes)
+//
+// dir is from the capturing node's side: in was read from the socket, out
+// written to it. phase is the stage the node was in when the bytes crossed
+// the socket; a read can run ahead into the next stage's bytes when the
+// remote pipelines them. When the connection ends, conn-<n>-<role>.json is
+// written next to it:
+//
+//	{"version":1,"role":"responder","conn":1,"local":"...","remote":"...",
+//	 "started":"<RFC 3339>","records":[{"seq":0,"dir":"in",
+//	 "phase":"multistream","offset":21,"length":20,"time_us":153},...]}
+//
+// where offset is the position of the record's data in the .cap file.
+const (
+	captureMagic            = "NDCAP"
+	captureVersion          = 1
+	captureHeaderSize       = len(captureMagic) + 2
+	captureRecordHeaderSize = 14
+)
+
+type capturePhase byte
+
+const (
+	phaseMultistream capturePhase = iota
+	phaseHandshake
+	phaseTransport
+)
+
+func (p capturePhase) String() string {
+	switch p {
+	case phaseMultistream:
+		return "multistream"
+	case phaseHandshake:
+		return "handshake"
+	case phaseTransport:
+		return "transport"
+	}
+	return fmt.Sprintf("phase-%d", byte(p))
+}
+
+const (
+	captureDirIn  byte = 0
+	captureDirOut byte = 1
+)
+
+func captureDirName(dir byte) string {
+	if dir == captureDirOut {
+		return "out"
+	}
+	return "in"
+}
+
+type captureIndex struct {
+	Version int             `json:"version"`
+	Role    string          `json:"role"`
+	Conn    uint64          `json:"conn"`
+	Local   string          `json:"local"`
+	Remote  string          `json:"remote"`
+	Started string          `json:"started"`
+	Records []captureRecord `json:"records"`
+}
+
+type captureRecord struct {
+	Seq    int    `json:"seq"`
+	Dir    string `json:"dir"`
+	Phase  string `json:"phase"`
+	Offset int64  `json:"offset"`
+	Length int    `json:"length"`
+	TimeUS int64  `json:"time_us"`
+}
+
+// captureConn records the bytes crossing a connection. Reads and writes may
+// come from different goroutines (the session reader and SEND), so records
+// are appended under mu. Records go straight to the file so a capture
+// survives the process being killed mid-connection; only the index needs
+// finish. A failed file write stops the capture, not the connection.
+type captureConn struct {
+	net.Conn
+	path  string
+	start time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	offset int64
+	phase  capturePhase
+	index  captureIndex
+	err    error
+}
+
+func openCapture(dir string, id uint64, role string, conn net.Conn) (*captureConn, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/conn-%d-%s.cap", strings.TrimRight(dir, "/"), id, role)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	roleByte := byte(1)
+	if role == "initiator" {
+		roleByte = 0
+	}
+	if _, err := file.Write(append([]byte(captureMagic), captureVersion, roleByte)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	start := time.Now()
+	return &captureConn{
+		Conn:   conn,
+		path:   path,
+		start:  start,
+		file:   file,
+		offset: int64(captureHeaderSize),
+		index: captureIndex{
+			Version: captureVersion,
+			Role:    role,
+			Conn:    id,
+			Local:   conn.LocalAddr().String(),
+			Remote:  conn.RemoteAddr().String(),
+			Started: start.UTC().Format(time.RFC3339Nano),
+			Records: []captureRecord{},
+		},
+	}, nil
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.record(captureDirIn, p[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.record(captureDirOut, p[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) record(dir byte, data []byte) {
+	elapsed := time.Since(c.start)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.file == nil {
+		return
+	}
+	record := make([]byte, 2, captureRecordHeaderSize+len(data))
+	record[0], record[1] = dir, byte(c.phase)
+	record = binary.BigEndian.AppendUint64(record, uint64(elapsed.Nanoseconds()))
+	record = binary.BigEndian.AppendUint32(record, uint32(len(data)))
+	if _, err := c.file.Write(append(record, data...)); err != nil {
+		c.err = err
+		return
+	}
+	c.index.Records = append(c.index.Records, captureRecord{
+		Seq:    len(c.index.Records),
+		Dir:    captureDirName(dir),
+		Phase:  c.phase.String(),
+		Offset: c.offset + captureRecordHeaderSize,
+		Length: len(data),
+		TimeUS: elapsed.Microseconds(),
+	})
+	c.offset += int64(captureRecordHeaderSize + len(data))
+}
+
+// setCapturePhase tags the records that follow when conn is being captured.
+func setCapturePhase(conn net.Conn, phase capturePhase) {
+	if c, ok := conn.(*captureConn); ok {
+		c.mu.Lock()
+		c.phase = phase
+		c.mu.Unlock()
+	}
+}
+
+// finish closes the capture, writes its JSON index and logs
+// "CAPTURE: file=... records=N bytes_in=N bytes_out=N".
+func (c *captureConn) finish(logger *log.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+	c.file = nil
+
+	index, err := json.Marshal(c.index)
+	if err == nil {
+		err = os.WriteFile(strings.TrimSuffix(c.path, ".cap")+".json", index, 0o644)
+	}
+	if c.err == nil {
+		c.err = err
+	}
+	if c.err != nil {
+		logger.Printf("CAPTURE_FAILED: file=%s err=%v", c.path, c.err)
+		return
+	}
+	var in, out int
+	for _, r := range c.index.Records {
+		if r.Dir == "out" {
+			out += r.Length
+		} else {
+			in += r.Length
+		}
+	}
+	logger.Printf("CAPTURE: file=%s records=%d bytes_in=%d bytes_out=%d", c.path, len(c.index.Records), in, out)
+}
+
+// capturedRecord is one record read back from a .cap file.
+type capturedRecord struct {
+	dir   byte
+	phase capturePhase
+	data  []byte
+}
+
+// readCapture parses a .cap file and returns its role and records.
+func readCapture(path string) (string, []capturedRecord, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(raw) < captureHeaderSize || string(raw[:len(captureMagic)]) != captureMagic {
+		return "", nil, errors.New("not a capture file")
+	}
+	if v := raw[len(captureMagic)]; v != captureVersion {
+		return "", nil, fmt.Errorf("unsupported capture version %d", v)
+	}
+	role := "responder"
+	if raw[len(captureMagic)+1] == 0 {
+		role = "initiator"
+	}
+
+	var records []capturedRecord
+	for rest := raw[captureHeaderSize:]; len(rest) > 0; {
+		if len(rest) < captureRecordHeaderSize {
+			return "", nil, fmt.Errorf("record %d: truncated header", len(records))
+		}
+		length := binary.BigEndian.Uint32(rest[10:14])
+		if uint64(len(rest)-captureRecordHeaderSize) < uint64(length) {
+			return "", nil, fmt.Errorf("record %d: truncated data", len(records))
+		}
+		records = append(records, capturedRecord{
+			dir:   rest[0],
+			phase: capturePhase(rest[1]),
+			data:  rest[captureRecordHeaderSize : captureRecordHeaderSize+int(length)],
+		})
+		rest = rest[captureRecordHeaderSize+int(length):]
+	}
+	return role, records, nil
+}
+
+// replayReadTimeout bounds the wait for each expected responder record.
+const replayReadTimeout = 5 * time.Second
+
+// replayCapture dials addr and replays the initiator side of the capture at
+// path: initiator records are written as captured, and for each responder
+// record the same number of bytes is read and compared. A live responder
+// picks fresh Noise keys, so the handshake diverges after Message A; what
+// the replay reproduces is the exact byte sequence and write split the
+// initiator sent, and where the responder gives up on it. Each step logs
+// "REPLAY: seq=<n> phase=<p> send bytes=N" or "REPLAY: seq=<n> phase=<p>
+// expect bytes=N match=<bool>"; a read or write error stops the replay at
+// that record. The run ends with "REPLAY_DONE: sent=N expected=N matched=N
+// diverged_at=<seq|-1> stopped_at=<seq|-1>", or "REPLAY_FAILED:
+// stage=load|dial" when it cannot start.
+func replayCapture(path, addr string, logger *log.Logger) error {
+	role, records, err := readCapture(path)
+	if err != nil {
+		logger.Printf("REPLAY_FAILED: stage=load err=%v", err)
+		return err
+	}
+	// The initiator's bytes are what the capturing node sent or received
+	initiatorDir := captureDirIn
+	if role == "initiator" {
+		initiatorDir = captureDirOut
+	}
+	logger.Printf("Replaying %d records captured by the %s against %s", len(records), role, addr)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		logger.Printf("REPLAY_FAILED: stage=dial err=%v", err)
+		return err
+	}
+	defer conn.Close()
+
+	sent, expected, matched, diverged, stopped := 0, 0, 0, -1, -1
+	for seq, r := range records {
+		if r.dir == initiatorDir {
+			if _, err := conn.Write(r.data); err != nil {
+				logger.Printf("REPLAY: seq=%d phase=%s stop stage=write err=%v", seq, r.phase, err)
+				stopped = seq
+				break
+			}
+			sent++
+			logger.Printf("REPLAY: seq=%d phase=%s send bytes=%d", seq, r.phase, len(r.data))
+			continue
+		}
+
+		got := make([]byte, len(r.data))
+		conn.SetReadDeadline(time.Now().Add(replayReadTimeout))
+		n, err := io.ReadFull(conn, got)
+		if err != nil {
+			logger.Printf("REPLAY: seq=%d phase=%s stop stage=read got=%d want=%d err=%v", seq, r.phase, n, len(r.data), err)
+			stopped = seq
+			break
+		}
+		expected++
+		match := bytes.Equal(got, r.data)
+		if match {
+			matched++
+		} else if diverged < 0 {
+			diverged = seq
+		}
+		logger.Printf("REPLAY: seq=%d phase=%s expect bytes=%d match=%t", seq, r.phase, len(r.data), match)
+	}
+	logger.Printf("REPLAY_DONE: sent=%d expected=%d matched=%d diverged_at=%d stopped_at=%d",
+		sent, expected, matched, diverged, stopped)
+	return nil
+}
+
+// handleConnection runs negotiation, the Noise handshake and the echo session
+// on conn and returns the connection's outcome.
+func handleConnection(conn net.Conn, identity crypto.PrivKey, cfg responderConfig, logger *log.Logger) string {
+	logger.Printf("New connection from %s", conn.RemoteAddr())
+
+	fr := newFrameReader(conn)
+	w := cfg.pacing.wrap(cfg.chunking.wrap(conn, logger), logger)
+	report := newCompatReport(cfg.compat)
+
+	selected, err := negotiateSecurity(fr, w, cfg.supported, report, logger)
+	if err != nil {
+		logger.Printf("Multistream negotiation failed: %v", err)
+		// go-multistream reads byte by byte, so whatever the dialer sent
+		// after the offending message would still be unread in go-libp2p's
+		// socket, and closing it would send RST rather than FIN
+		if unread := fr.r.Buffered(); report.strict() && unread > 0 && resetOnClose(conn) {
+			logger.Printf("COMPAT: closing with RST, %d bytes unread", unread)
+		}
+		transcript := newHandshakeTranscript("responder")
+		transcript.compat = report
+		transcript.emit(logger, fmt.Errorf("multistream: %w", err))
+		return outcomeNegotiationFailed
+	}
+	logger.Printf("Negotiated security protocol %s", selected)
+	if selected != noiseProtocol {
+		logger.Printf("No handshake implementation for %s; closing", selected)
+		transcript := newHandshakeTranscript("responder")
+		transcript.compat = report
+		transcript.emit(logger, fmt.Errorf("no handshake implementation for %s", selected))
+		return outcomeUnsupportedProtocol
+	}
+	cfg.pacing.pause(pauseAfterMultistream, logger)
+	setCapturePhase(conn, phaseHandshake)
+
+	// Now start Noise handshake
+	transcript := newHandshakeTranscript("responder")
+	transcript.compat = report
+	cs1, cs2, err := respondNoiseHandshake(fr, w, identity, cfg, transcript, logger)
+	transcript.emit(logger, err)
+	if err != nil {
+		logger.Printf("Noise handshake failed: %v", err)
+		if errors.Is(err, errPeerMismatch) {
+			return outcomePeerMismatch
+		}
+		if errors.Is(err, errInvalidPayload) {
+			return outcomeInvalidPayload
+		}
+		return outcomeHandshakeFailed
+	}
+	logger.Printf("Noise handshake test complete")
+	setCapturePhase(conn, phaseTransport)
+
+	session := newSecureSession(false, cs1, cs2, fr, w, logger)
+	activeSession.set(session)
+	defer activeSession.clear(session)
+
+	// Echo transport messages back until the initiator hangs up
+	if err := session.serve(true); err != nil {
+		logger.Printf("Transport closed: %v", err)
+		if errors.Is(err, errFrameTooLarge) {
+			return outcomeFrameTooLarge
+		}
+	}
+	return outcomeHandshakeOK
+}
+
+// negotiateSecurity runs the listener side of multistream-select 1.0.0:
+// it answers ls with the supported list, rejects unsupported proposals with
+// na and returns the first supported protocol the remote proposes.
+//
+// Under COMPAT=strict it behaves like go-multistream's Negotiate instead:
+// the header goes out before the dialer's is read, ls and protocols without
+// a handshake implementation get na, a non-minimal length prefix ends the
+// negotiation and there is no limit on proposals. Input only the lenient
+// listener accepts is recorded in report.
+func negotiateSecurity(fr *frameReader, w io.Writer, supported []string, report *compatReport, logger *log.Logger) (string, error) {
+	if report.strict() {
+		if err := writeMultistreamLine(w, multistreamProtocol, logger); err != nil {
+			return "", err
+		}
+	}
+	header, err := readMultistreamLine(fr, report, logger)
+	if err != nil {
+		return "", err
+	}
+	if header != multistreamProtocol {
+		return "", fmt.Errorf("unexpected multistream header %q", header)
+	}
+	if !report.strict() {
+		if err := writeMultistreamLine(w, multistreamProtocol, logger); err != nil {
+			return "", err
+		}
+	}
+
+	for attempt := 0; report.strict() || attempt < maxNegotiationAttempts; attempt++ {
+		proposal, err := readMultistreamLine(fr, report, logger)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case proposal == "ls" && report.allow(logger, ruleListProtocols, ""):
+			if err := writeListResponse(w, supported, logger); err != nil {
+				return "", err
+			}
+		case slices.Contains(supported, proposal) &&
+			(proposal == noiseProtocol || report.allow(logger, ruleUnimplementedProtocol, proposal)):
+			if err := writeMultistreamLine(w, proposal, logger); err != nil {
+				return "", err
+			}
+			return proposal, nil
+		default:
+			if err := writeMultistreamLine(w, "na", logger); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "", fmt.Errorf("no protocol agreed after %d proposals", maxNegotiationAttempts)
+}
+
+// respondNoiseHandshake runs XX as the responder and returns the cipher
+// states cs1 (initiator -> responder) and cs2 (responder -> initiator).
+// A configured fault is applied to Message B (or replaces it). Progress is
+// recorded in transcript.
+func respondNoiseHandshake(fr *frameReader, w io.Writer, identity crypto.PrivKey, cfg responderConfig, transcript *handshakeTranscript, logger *log.Logger) (*noise.CipherState, *noise.CipherState, error) {
+	fault := cfg.fault
+	staticKP, hs, err := newHandshakeState(false, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	transcript.hs = hs
+
+	// Read Message A (initiator's ephemeral)
+	recA := transcript.begin("A", "initiator")
+	messageA, err := fr.readNoiseFrame()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read Message A: %w", err)
+	}
+	logger.Printf("Message A length: %d", len(messageA))
+	logger.Printf("Message A content: %s", hex.EncodeToString(messageA))
+
+	// Process Message A
+	payload, cs1, cs2, err := hs.ReadMessage(nil, messageA)
+	recA.received(messageA, err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("process Message A: %w", err)
+	}
+	logger.Printf("Message A payload: %s", hex.EncodeToString(payload))
+	logger.Printf("Remote ephemeral: %s", hex.EncodeToString(hs.PeerEphemeral()))
+
+	// Check if handshake complete (shouldn't be after just Message A in XX)
+	if cs1 != nil || cs2 != nil {
+		return nil, nil, errors.New("unexpected: handshake complete after Message A")
+	}
+	cfg.pacing.pause(pauseAfterMessageA, logger)
+
+	if fault.mode == faultStall {
+		logger.Printf("FAULT_ACTIVE: %s: read Message A, withholding Message B until the initiator hangs up", fault.mode)
+		n, _ := io.Copy(io.Discard, fr.r)
+		return nil, nil, fmt.Errorf("stalled after Message A (discarded %d further bytes)", n)
+	}
+
+	// Generate Message B carrying our signed identity
+	recB := transcript.begin("B", "responder")
+	signedKey := staticKP.Public
+	if fault.mode == faultWrongKey {
+		decoy, err := noise.DH25519.GenerateKeypair(rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate decoy static key: %w", err)
+		}
+		signedKey = decoy.Public
+		logger.Printf("FAULT_ACTIVE: %s: payload signs static key %s, Message B carries %s",
+			fault.mode, hex.EncodeToString(signedKey), hex.EncodeToString(staticKP.Public))
+	}
+	localPayload, err := createHandshakePayload(identity, signedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create handshake payload: %w", err)
+	}
+	if fault.mode == faultBadSignature {
+		// The signature is the last payload field, so the last byte is in it
+		localPayload[len(localPayload)-1] ^= 0x01
+		logger.Printf("FAULT_ACTIVE: %s: flipped bit 0 of the last identity signature byte", fault.mode)
+	}
+	// Appended after the fault so it still hits the signature
+	if cfg.extensions != nil {
+		localPayload = appendBytesField(localPayload, payloadFieldExtensions, cfg.extensions.marshal())
+	}
+	logger.Printf("Message B payload (%d bytes): %s", len(localPayload), hex.EncodeToString(localPayload))
+
+	msgB, cs1, cs2, err := hs.WriteMessage(nil, localPayload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate Message B: %w", err)
+	}
+	logMessageB(logger, msgB)
+
+	// Send Message B with length prefix
+	if err := sendMessageB(w, msgB, fault, logger); err != nil {
+		return nil, nil, fmt.Errorf("send Message B: %w", err)
+	}
+	recB.sent(msgB)
+
+	if cs1 != nil || cs2 != nil {
+		return nil, nil, errors.New("unexpected: handshake complete after Message B")
+	}
+
+	// Read Message C
+	recC := transcript.begin("C", "initiator")
+	messageC, err := fr.readNoiseFrame()
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, errors.New("connection closed before Message C")
+		}
+		return nil, nil, fmt.Errorf("read Message C: %w", err)
+	}
+	logger.Printf("Received Message C (%d bytes): %s", len(messageC), hex.EncodeToString(messageC))
+
+	// Process Message C (initiator's static key + payload)
+	remotePayload, cs1, cs2, err := hs.ReadMessage(nil, messageC)
+	recC.received(messageC, err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("process Message C: %w", err)
+	}
+	logger.Printf("Message C payload (%d bytes): %s", len(remotePayload), hex.EncodeToString(remotePayload))
+	logger.Printf("Remote static: %s", hex.EncodeToString(hs.PeerStatic()))
+
+	// The signature is checked against PeerStatic, the key the se DH used.
+	// Outside STRICT=1 and COMPAT=strict an invalid payload is reported and
+	// the session continues, so tests can inspect what the initiator sends
+	// next.
+	if err := logRemoteIdentity(logger, transcript, remotePayload, hs.PeerStatic()); err != nil {
+		if cfg.expect.strict || !errors.Is(err, errInvalidPayload) ||
+			!transcript.compat.allow(logger, ruleInvalidPayload, err.Error()) {
+			return nil, nil, err
+		}
+		logger.Printf("Continuing after %v (STRICT=0)", err)
+	}
+	if err := checkExpectedPeer(logger, cfg.expect, transcript); err != nil {
+		return nil, nil, err
+	}
+
+	if cs1 == nil || cs2 == nil {
+		return nil, nil, errors.New("unexpected: handshake incomplete after Message C")
+	}
+	return cs1, cs2, nil
+}
+
+// sendMessageB frames and writes Message B, applying any wire-level fault.
+func sendMessageB(w io.Writer, msgB []byte, fault faultConfig, logger *log.Logger) error {
+	switch fault.mode {
+	case faultCorruptMAC:
+		// The final 16 bytes are the payload's ChaChaPoly tag
+		msgB[len(msgB)-1] ^= 0x01
+		logger.Printf("FAULT_ACTIVE: %s: flipped bit 0 of byte %d (payload auth tag) of Message B", fault.mode, len(msgB)-1)
+	case faultTruncateB:
+		if fault.truncateBytes < len(msgB) {
+			logger.Printf("FAULT_ACTIVE: %s: sending first %d of %d bytes of Message B as a complete frame",
+				fault.mode, fault.truncateBytes, len(msgB))
+			msgB = msgB[:fault.truncateBytes]
+		}
+	case faultOversize:
+		// The 2-byte Noise prefix cannot express 70000, so declare the maximum
+		// and stream the full oversized body after it.
+		body := make([]byte, oversizeFrameLength)
+		copy(body, msgB)
+		frame := binary.BigEndian.AppendUint16(nil, maxNoiseMessageSize)
+		if _, err := w.Write(append(frame, body...)); err != nil {
+			return err
+		}
+		logger.Printf("FAULT_ACTIVE: %s: declared length %d (%d does not fit the 2-byte prefix), wrote %d-byte body (Message B zero-padded)",
+			fault.mode, maxNoiseMessageSize, oversizeFrameLength, len(body))
+		return nil
+	}
+
+	if err := writeFrame(w, msgB); err != nil {
+		return err
 	}
-	pubBytes, _ := privKey.GetPublic().Raw()
-	log.Printf("Identity public key: %s", hex.EncodeToString(pubBytes))
+	logger.Printf("Sent Message B frame (%d bytes)", 2+len(msgB))
+	return nil
+}
 
-	// Listen on TCP
-	listener, err := net.Listen("tcp", ":"+portStr)
+// runInitiator dials addr, negotiates /noise, completes the handshake and
+// round-trips one encrypted frame. On failure it returns the stage that failed.
+func runInitiator(addr string, identity crypto.PrivKey, captureDir string, logger *log.Logger) (string, error) {
+	conn, err := net.Dial("tcp", addr)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		return "dial", err
 	}
-	defer listener.Close()
-
-	log.Printf("Listening on TCP port %s", portStr)
-	log.Println("Ready to accept connections")
+	defer conn.Close()
+	logger.Printf("Connected to %s", conn.RemoteAddr())
 
-	for {
-		conn, err := listener.Accept()
+	if captureDir != "" {
+		capture, err := openCapture(captureDir, 1, "initiator", conn)
 		if err != nil {
-			log.Printf("Accept error: %v", err)
-			continue
+			logger.Printf("CAPTURE_FAILED: %v", err)
+		} else {
+			defer capture.finish(logger)
+			conn = capture
 		}
-		go handleConnection(conn)
 	}
+
+	fr := newFrameReader(conn)
+
+	// Propose multistream header and /noise together (pipelined, as go-libp2p does)
+	if err := writeMultistreamLine(conn, multistreamProtocol, logger); err != nil {
+		return "multistream", err
+	}
+	if err := writeMultistreamLine(conn, noiseProtocol, logger); err != nil {
+		return "multistream", err
+	}
+
+	msg, err := readMultistreamLine(fr, nil, logger)
+	if err != nil {
+		return "multistream", err
+	}
+	if msg != multistreamProtocol {
+		return "multistream", fmt.Errorf("unexpected header %q", msg)
+	}
+
+	msg, err = readMultistreamLine(fr, nil, logger)
+	if err != nil {
+		return "multistream", err
+	}
+	if msg != noiseProtocol {
+		return "multistream", fmt.Errorf("remote rejected %s: %q", noiseProtocol, msg)
+	}
+	setCapturePhase(conn, phaseHandshake)
+
+	transcript := newHandshakeTranscript("initiator")
+	cs1, cs2, stage, err := initiateNoiseHandshake(fr, conn, identity, transcript, logger)
+	transcript.emit(logger, err)
+	if err != nil {
+		return stage, err
+	}
+	logger.Printf("Noise handshake test complete")
+	setCapturePhase(conn, phaseTransport)
+
+	session := newSecureSession(true, cs1, cs2, fr, conn, logger)
+	activeSession.set(session)
+	defer activeSession.clear(session)
+
+	// Round-trip one encrypted message
+	if err := session.writeMessage(echoTestPayload); err != nil {
+		return "echo-send", err
+	}
+	reply, err := session.readMessage()
+	if err != nil {
+		return "echo-read", err
+	}
+	if !bytes.Equal(reply, echoTestPayload) {
+		return "echo-compare", fmt.Errorf("echo mismatch: got %s", hex.EncodeToString(reply))
+	}
+	logger.Printf("SECURE_ECHO_OK")
+
+	// Keep printing inbound messages (and serving SEND) until the remote hangs up
+	if err := session.serve(false); err != nil {
+		logger.Printf("Transport closed: %v", err)
+	}
+	return "", nil
 }
 
-func handleConnection(conn net.Conn) {
-	defer conn.Close()
-	log.Printf("New connection from %s", conn.RemoteAddr())
+// initiateNoiseHandshake runs XX as the initiator and returns the cipher
+// states cs1 (initiator -> responder) and cs2 (responder -> initiator).
+// On failure it returns the failing stage. Progress is recorded in transcript.
+func initiateNoiseHandshake(fr *frameReader, w io.Writer, identity crypto.PrivKey, transcript *handshakeTranscript, logger *log.Logger) (*noise.CipherState, *noise.CipherState, string, error) {
+	staticKP, hs, err := newHandshakeState(true, logger)
+	if err != nil {
+		return nil, nil, "handshake-setup", err
+	}
+	transcript.hs = hs
 
-	// Read multistream header
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
+	// Generate and send Message A (our ephemeral)
+	recA := transcript.begin("A", "initiator")
+	msgA, _, _, err := hs.WriteMessage(nil, nil)
 	if err != nil {
-		log.Printf("Read error: %v", err)
-		return
+		return nil, nil, "message-a", err
+	}
+	logger.Printf("Message A length: %d", len(msgA))
+	logger.Printf("Message A content: %s", hex.EncodeToString(msgA))
+	if err := writeFrame(w, msgA); err != nil {
+		return nil, nil, "message-a", err
+	}
+	logger.Printf("Sent Message A frame (%d bytes)", 2+len(msgA))
+	recA.sent(msgA)
+
+	// Read and process Message B (responder's ephemeral, static and payload)
+	recB := transcript.begin("B", "responder")
+	msgB, err := fr.readNoiseFrame()
+	if err != nil {
+		return nil, nil, "message-b", err
+	}
+	logMessageB(logger, msgB)
+
+	remotePayload, _, _, err := hs.ReadMessage(nil, msgB)
+	recB.received(msgB, err)
+	if err != nil {
+		return nil, nil, "message-b", err
 	}
-	log.Printf("Received multistream header (%d bytes): %s", n, hex.EncodeToString(buf[:n]))
+	logger.Printf("Message B payload (%d bytes): %s", len(remotePayload), hex.EncodeToString(remotePayload))
+	logger.Printf("Remote ephemeral: %s", hex.EncodeToString(hs.PeerEphemeral()))
+	logger.Printf("Remote static: %s", hex.EncodeToString(hs.PeerStatic()))
 
-	// Send multistream header response
-	header := "\x13/multistream/1.0.0\n"
-	conn.Write([]byte(header))
-	log.Printf("Sent multistream header")
+	if err := logRemoteIdentity(logger, transcript, remotePayload, hs.PeerStatic()); err != nil {
+		return nil, nil, "message-b-payload", err
+	}
 
-	// Read protocol request
-	n, err = conn.Read(buf)
+	// Generate and send Message C carrying our signed identity
+	recC := transcript.begin("C", "initiator")
+	localPayload, err := createHandshakePayload(identity, staticKP.Public)
 	if err != nil {
-		log.Printf("Read error: %v", err)
-		return
+		return nil, nil, "message-c", err
 	}
-	log.Printf("Received protocol request (%d bytes): %s", n, hex.EncodeToString(buf[:n]))
+	logger.Printf("Message C payload (%d bytes): %s", len(localPayload), hex.EncodeToString(localPayload))
 
-	// Send protocol confirmation
-	noiseProto := "\x07/noise\n"
-	conn.Write([]byte(noiseProto))
-	log.Printf("Sent /noise confirmation")
+	msgC, cs1, cs2, err := hs.WriteMessage(nil, localPayload)
+	if err != nil {
+		return nil, nil, "message-c", err
+	}
+	logger.Printf("Message C content (%d bytes): %s", len(msgC), hex.EncodeToString(msgC))
+	if err := writeFrame(w, msgC); err != nil {
+		return nil, nil, "message-c", err
+	}
+	logger.Printf("Sent Message C frame (%d bytes)", 2+len(msgC))
+	recC.sent(msgC)
 
-	// Now start Noise handshake
-	performNoiseHandshake(conn)
+	if cs1 == nil || cs2 == nil {
+		return nil, nil, "message-c", errors.New("handshake incomplete after Message C")
+	}
+	return cs1, cs2, "", nil
 }
 
-func performNoiseHandshake(conn net.Conn) {
+func newHandshakeState(initiator bool, logger *log.Logger) (noise.DHKey, *noise.HandshakeState, error) {
 	// Generate static keypair for Noise
 	staticKP, err := noise.DH25519.GenerateKeypair(rand.Reader)
 	if err != nil {
-		log.Printf("Failed to generate static keypair: %v", err)
-		return
+		return noise.DHKey{}, nil, fmt.Errorf("generate static keypair: %w", err)
 	}
-	log.Printf("Noise static public key: %s", hex.EncodeToString(staticKP.Public))
+	logger.Printf("Noise static public key: %s", hex.EncodeToString(staticKP.Public))
 
 	// Configure Noise handshake
 	cs := noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashSHA256)
-	cfg := noise.Config{
+	hs, err := noise.NewHandshakeState(noise.Config{
 		CipherSuite:   cs,
 		Pattern:       noise.HandshakeXX,
-		Initiator:     false, // We are responder
+		Initiator:     initiator,
 		StaticKeypair: staticKP,
+	})
+	if err != nil {
+		return noise.DHKey{}, nil, fmt.Errorf("create handshake state: %w", err)
+	}
+	return staticKP, hs, nil
+}
+
+func logMessageB(logger *log.Logger, msgB []byte) {
+	logger.Printf("Message B content (%d bytes): %s", len(msgB), hex.EncodeToString(msgB))
+
+	// Extract parts of Message B for debugging
+	if len(msgB) >= 32 {
+		logger.Printf("Message B ephemeral: %s", hex.EncodeToString(msgB[:32]))
+	}
+	if len(msgB) >= 80 {
+		logger.Printf("Message B encrypted static: %s", hex.EncodeToString(msgB[32:80]))
 	}
+}
 
-	hs, err := noise.NewHandshakeState(cfg)
+// logRemoteIdentity decodes and verifies the remote handshake payload and
+// logs its PAYLOAD_VERDICT line. An invalid payload returns an error wrapping
+// errInvalidPayload.
+func logRemoteIdentity(logger *log.Logger, transcript *handshakeTranscript, payload []byte, remoteStatic []byte) error {
+	transcript.RemoteStatic = hex.EncodeToString(remoteStatic)
+	var verdict payloadVerdict
+	parsed, err := parseHandshakePayload(payload)
 	if err != nil {
-		log.Printf("Failed to create handshake state: %v", err)
-		return
+		logger.Printf("Remote payload does not parse: %v", err)
+		verdict = payloadVerdict{reason: reasonMalformedPayload}
+	} else {
+		if parsed.extensions != nil {
+			logger.Printf("EXT: %s", parsed.extensions)
+		} else {
+			logger.Printf("EXT: none")
+		}
+		verdict = verifyHandshakePayload(parsed, remoteStatic)
+	}
+	if verdict.identity != "" {
+		transcript.RemotePeerID = verdict.identity.String()
+		logger.Printf("REMOTE_IDENTITY: %s sig_valid=%t", verdict.identity, verdict.valid)
 	}
+	logger.Printf("PAYLOAD_VERDICT: %s", verdict)
+	if !verdict.valid {
+		return fmt.Errorf("%w: %s", errInvalidPayload, verdict.reason)
+	}
+	return nil
+}
 
-	// Read Message A (initiator's ephemeral)
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
+// checkExpectedPeer compares the verified remote identity recorded in
+// transcript with the expected one, logging a PEER_CHECK and STATIC_CHECK
+// line for each configured field. A mismatch is an error only when strict.
+func checkExpectedPeer(logger *log.Logger, expect expectedPeer, transcript *handshakeTranscript) error {
+	match := true
+	if expect.peerID != "" {
+		ok := transcript.RemotePeerID == expect.peerID
+		logger.Printf("PEER_CHECK: expected=%s actual=%s match=%t", expect.peerID, transcript.RemotePeerID, ok)
+		match = match && ok
+	}
+	if expect.static != "" {
+		ok := transcript.RemoteStatic == expect.static
+		logger.Printf("STATIC_CHECK: expected=%s actual=%s match=%t", expect.static, transcript.RemoteStatic, ok)
+		match = match && ok
+	}
+	if !match && expect.strict {
+		return errPeerMismatch
+	}
+	return nil
+}
+
+// handshakeTranscript is the machine-readable record of one handshake,
+// logged as a single TRANSCRIPT: JSON line whether or not it succeeded.
+type handshakeTranscript struct {
+	Role         string               `json:"role"`
+	Success      bool                 `json:"success"`
+	Error        string               `json:"error,omitempty"`
+	Messages     []*transcriptMessage `json:"messages"`
+	RemoteStatic string               `json:"remote_static,omitempty"`
+	RemotePeerID string               `json:"remote_peer_id,omitempty"`
+	// Final handshake hash (channel binding) on success; on failure, the
+	// hash as far as the handshake got.
+	HandshakeHash string  `json:"handshake_hash,omitempty"`
+	DurationMs    float64 `json:"duration_ms"`
+	// Responder only: the COMPAT mode and every input the dialer sent that
+	// go-libp2p would have answered differently, from multistream on.
+	Compat     compatMode       `json:"compat,omitempty"`
+	Leniencies []compatLeniency `json:"leniencies,omitempty"`
+
+	start  time.Time
+	hs     *noise.HandshakeState
+	compat *compatReport
+}
+
+// transcriptMessage records one XX message as seen by this node.
+type transcriptMessage struct {
+	Message string `json:"message"` // A, B or C
+	Sender  string `json:"sender"`  // initiator or responder
+	Length  int    `json:"length"`
+	// Ephemeral key carried by Messages A and B.
+	Ephemeral string `json:"ephemeral,omitempty"`
+	// Set for received messages only.
+	PayloadDecrypted *bool `json:"payload_decrypted,omitempty"`
+	// Offset from the handshake start when this node began building or
+	// waiting for the message, and how long until it was sent or processed.
+	StartMs    float64 `json:"start_ms"`
+	DurationMs float64 `json:"duration_ms"`
+
+	began time.Time
+}
+
+func newHandshakeTranscript(role string) *handshakeTranscript {
+	return &handshakeTranscript{Role: role, Messages: []*transcriptMessage{}, start: time.Now()}
+}
+
+// begin starts timing a message.
+func (t *handshakeTranscript) begin(name, sender string) *transcriptMessage {
+	now := time.Now()
+	m := &transcriptMessage{Message: name, Sender: sender, StartMs: millisSince(t.start, now), began: now}
+	t.Messages = append(t.Messages, m)
+	return m
+}
+
+func (m *transcriptMessage) sent(msg []byte) {
+	m.record(msg)
+}
+
+// received records an inbound message and whether ReadMessage accepted it.
+func (m *transcriptMessage) received(msg []byte, err error) {
+	ok := err == nil
+	m.PayloadDecrypted = &ok
+	m.record(msg)
+}
+
+func (m *transcriptMessage) record(msg []byte) {
+	m.Length = len(msg)
+	if m.Message != "C" && len(msg) >= noise.DH25519.DHLen() {
+		m.Ephemeral = hex.EncodeToString(msg[:noise.DH25519.DHLen()])
+	}
+	m.DurationMs = millisSince(m.began, time.Now())
+}
+
+// emit logs the transcript as one TRANSCRIPT: line.
+func (t *handshakeTranscript) emit(logger *log.Logger, err error) {
+	t.Success = err == nil
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if t.hs != nil {
+		t.HandshakeHash = hex.EncodeToString(t.hs.ChannelBinding())
+	}
+	t.DurationMs = millisSince(t.start, time.Now())
+	if t.compat != nil {
+		t.Compat, t.Leniencies = t.compat.mode, t.compat.leniencies
+	}
+	data, err := json.Marshal(t)
 	if err != nil {
-		log.Printf("Failed to read Message A: %v", err)
+		logger.Printf("Encode transcript: %v", err)
 		return
 	}
-	log.Printf("Received Message A frame (%d bytes): %s", n, hex.EncodeToString(buf[:n]))
+	logger.Printf("TRANSCRIPT: %s", data)
+}
 
-	// Parse length prefix
-	if n < 2 {
-		log.Printf("Message A too short")
-		return
+func millisSince(from, to time.Time) float64 {
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+// secureSession is the post-handshake libp2p Noise transport: messages are
+// split into chunks that fit one Noise message and sent as 2-byte length +
+// ciphertext frames.
+type secureSession struct {
+	fr     *frameReader
+	w      io.Writer
+	logger *log.Logger
+
+	// Sends may come from the echo loop and the stdin command reader at once.
+	sendMu   sync.Mutex
+	send     *noise.CipherState
+	sendName string
+
+	recv     *noise.CipherState
+	recvName string
+
+	// Transport frames sent and received so far, numbering the SEND and
+	// RECV lines.
+	sentFrames int
+	recvFrames int
+}
+
+// sendStats describes the frames one writeFrames call produced.
+type sendStats struct {
+	frames     int
+	maxFrame   int
+	firstNonce uint64
+	nextNonce  uint64
+}
+
+func newSecureSession(initiator bool, cs1, cs2 *noise.CipherState, fr *frameReader, w io.Writer, logger *log.Logger) *secureSession {
+	s := &secureSession{fr: fr, w: w, logger: logger}
+	if initiator {
+		s.send, s.sendName = cs1, "cs1 (initiator->responder)"
+		s.recv, s.recvName = cs2, "cs2 (responder->initiator)"
+	} else {
+		s.send, s.sendName = cs2, "cs2 (responder->initiator)"
+		s.recv, s.recvName = cs1, "cs1 (initiator->responder)"
 	}
-	msgLen := int(buf[0])<<8 | int(buf[1])
-	log.Printf("Message A length: %d", msgLen)
+	logger.Printf("Transport ready: send=%s recv=%s", s.sendName, s.recvName)
+	return s
+}
+
+// writeMessage encrypts plaintext with the sending cipher state, one frame per
+// chunk, and logs each frame.
+func (s *secureSession) writeMessage(plaintext []byte) error {
+	_, err := s.writeFrames(plaintext, true)
+	return err
+}
 
-	messageA := buf[2 : 2+msgLen]
-	log.Printf("Message A content: %s", hex.EncodeToString(messageA))
+// writeFrames splits plaintext into chunks of at most maxPlaintextChunk bytes,
+// so every ciphertext frame fits the 65535-byte Noise message limit. With
+// logFrames unset only the returned stats describe the frames.
+func (s *secureSession) writeFrames(plaintext []byte, logFrames bool) (sendStats, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
 
-	// Process Message A
-	payload, cs1, cs2, err := hs.ReadMessage(nil, messageA)
+	stats := sendStats{firstNonce: s.send.Nonce()}
+	for {
+		chunk := plaintext[:min(len(plaintext), maxPlaintextChunk)]
+		plaintext = plaintext[len(chunk):]
+
+		nonce := s.send.Nonce()
+		ciphertext, err := s.send.Encrypt(nil, nil, chunk)
+		if err != nil {
+			return stats, fmt.Errorf("encrypt with %s: %w", s.sendName, err)
+		}
+		if err := writeFrame(s.w, ciphertext); err != nil {
+			return stats, err
+		}
+		s.sentFrames++
+		stats.frames++
+		stats.maxFrame = max(stats.maxFrame, len(ciphertext))
+		stats.nextNonce = s.send.Nonce()
+		if logFrames {
+			s.logger.Printf("SEND %s frame=%d len=%d nonce=%d->%d plaintext(%d)=%s",
+				s.sendName, s.sentFrames, len(ciphertext), nonce, s.send.Nonce(), len(chunk), hex.EncodeToString(chunk))
+		}
+
+		if len(plaintext) == 0 {
+			return stats, nil
+		}
+	}
+}
+
+// readMessage reads and decrypts one transport frame with the receiving cipher state.
+func (s *secureSession) readMessage() ([]byte, error) {
+	frame, err := s.fr.readNoiseFrame()
 	if err != nil {
-		log.Printf("Failed to process Message A: %v", err)
-		return
+		var tooLarge *frameTooLargeError
+		if errors.As(err, &tooLarge) {
+			s.logger.Printf("FRAME_TOO_LARGE: len=%d", tooLarge.length)
+		}
+		return nil, err
 	}
-	log.Printf("Message A payload: %s", hex.EncodeToString(payload))
-	log.Printf("Remote ephemeral: %s", hex.EncodeToString(hs.PeerEphemeral()))
+	s.recvFrames++
+	nonce := s.recv.Nonce()
+	plaintext, err := s.recv.Decrypt(nil, nil, frame)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with %s at nonce %d (swapped cipher states?): %w", s.recvName, nonce, err)
+	}
+	s.logger.Printf("RECV %s frame=%d len=%d nonce=%d->%d plaintext(%d)=%s",
+		s.recvName, s.recvFrames, len(frame), nonce, s.recv.Nonce(), len(plaintext), hex.EncodeToString(plaintext))
+	return plaintext, nil
+}
 
-	// Check if handshake complete (shouldn't be after just Message A in XX)
-	if cs1 != nil || cs2 != nil {
-		log.Printf("Unexpected: handshake complete after Message A")
-		return
+// serve reads transport messages until the connection closes, echoing each
+// one back when echo is set. A clean EOF returns nil.
+func (s *secureSession) serve(echo bool) error {
+	for {
+		plaintext, err := s.readMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if echo {
+			if err := s.writeMessage(plaintext); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// activeSession is the most recently established session, the target of
+// stdin SEND commands.
+var activeSession sessionSlot
+
+type sessionSlot struct {
+	mu      sync.Mutex
+	current *secureSession
+}
+
+func (s *sessionSlot) set(session *secureSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = session
+}
+
+func (s *sessionSlot) clear(session *secureSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == session {
+		s.current = nil
+	}
+}
+
+func (s *sessionSlot) get() *secureSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Largest SEND_LARGE payload.
+const maxSendLargeBytes = 64 << 20
+
+// readCommands handles harness commands on stdin:
+//
+//	SEND <hex>         encrypt and send the bytes on the active session
+//	SEND_LARGE <bytes> send a <bytes>-long plaintext (byte i is i%251) on the
+//	                   active session, split into maximum-size frames, and log
+//	                   "SEND_LARGE: bytes=N frames=N max_frame=N nonce=a->b"
+//	RESUME             release the connections held by PAUSE_AFTER and log
+//	                   "RESUME: released=N"
+func readCommands(r io.Reader, logger *log.Logger) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "SEND":
+			payload, err := hex.DecodeString(strings.TrimSpace(arg))
+			if err != nil {
+				logger.Printf("SEND: invalid hex: %v", err)
+				continue
+			}
+			session := activeSession.get()
+			if session == nil {
+				logger.Printf("SEND: no active session")
+				continue
+			}
+			if err := session.writeMessage(payload); err != nil {
+				logger.Printf("SEND failed: %v", err)
+			}
+		case "SEND_LARGE":
+			size, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || size <= 0 || size > maxSendLargeBytes {
+				logger.Printf("SEND_LARGE: invalid size %q (1..%d)", arg, maxSendLargeBytes)
+				continue
+			}
+			session := activeSession.get()
+			if session == nil {
+				logger.Printf("SEND_LARGE: no active session")
+				continue
+			}
+			payload := make([]byte, size)
+			for i := range payload {
+				payload[i] = byte(i % 251)
+			}
+			stats, err := session.writeFrames(payload, false)
+			if err != nil {
+				logger.Printf("SEND_LARGE failed after %d frames: %v", stats.frames, err)
+				continue
+			}
+			logger.Printf("SEND_LARGE: bytes=%d frames=%d max_frame=%d nonce=%d->%d",
+				size, stats.frames, stats.maxFrame, stats.firstNonce, stats.nextNonce)
+		case "RESUME":
+			logger.Printf("RESUME: released=%d", resumeGate.release())
+		default:
+			logger.Printf("Unknown command %q", cmd)
+		}
 	}
+}
 
-	// Generate Message B
-	msgB, cs1, cs2, err := hs.WriteMessage(nil, createDummyPayload())
+// NoiseHandshakePayload field numbers.
+const (
+	payloadFieldIdentityKey = 1
+	payloadFieldIdentitySig = 2
+	payloadFieldExtensions  = 4
+)
+
+// NoiseExtensions field numbers.
+const (
+	extensionsFieldCertHashes   = 1
+	extensionsFieldStreamMuxers = 2
+)
+
+// createHandshakePayload builds the NoiseHandshakePayload protobuf:
+//
+//	message NoiseHandshakePayload {
+//	  bytes identity_key = 1;
+//	  bytes identity_sig = 2;
+//	  NoiseExtensions extensions = 4;
+//	}
+//
+// Extensions are appended by the caller.
+func createHandshakePayload(identity crypto.PrivKey, staticKey []byte) ([]byte, error) {
+	identityKey, err := crypto.MarshalPublicKey(identity.GetPublic())
 	if err != nil {
-		log.Printf("Failed to generate Message B: %v", err)
-		return
+		return nil, err
+	}
+	sig, err := identity.Sign(append([]byte(staticKeySignaturePrefix), staticKey...))
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Message B content (%d bytes): %s", len(msgB), hex.EncodeToString(msgB))
 
-	// Extract parts of Message B for debugging
-	if len(msgB) >= 32 {
-		log.Printf("Message B ephemeral: %s", hex.EncodeToString(msgB[:32]))
+	var payload []byte
+	payload = appendBytesField(payload, payloadFieldIdentityKey, identityKey)
+	payload = appendBytesField(payload, payloadFieldIdentitySig, sig)
+	return payload, nil
+}
+
+// handshakePayload is a decoded NoiseHandshakePayload.
+type handshakePayload struct {
+	identityKey []byte
+	identitySig []byte
+	extensions  *noiseExtensions
+}
+
+// noiseExtensions is a decoded NoiseExtensions message:
+//
+//	message NoiseExtensions {
+//	  repeated bytes webtransport_certhashes = 1;
+//	  repeated string stream_muxers = 2;
+//	}
+type noiseExtensions struct {
+	certHashes   [][]byte
+	streamMuxers []string
+	// Field numbers this node does not know; reported, never rejected.
+	unknownFields []uint64
+}
+
+func (e *noiseExtensions) String() string {
+	out := fmt.Sprintf("muxers=[%s] certhashes=%d", strings.Join(e.streamMuxers, " "), len(e.certHashes))
+	if len(e.unknownFields) > 0 {
+		out += fmt.Sprintf(" unknown_fields=%v", e.unknownFields)
 	}
-	if len(msgB) >= 80 {
-		log.Printf("Message B encrypted static: %s", hex.EncodeToString(msgB[32:80]))
+	return out
+}
+
+func (e *noiseExtensions) marshal() []byte {
+	var buf []byte
+	for _, h := range e.certHashes {
+		buf = appendBytesField(buf, extensionsFieldCertHashes, h)
 	}
+	for _, m := range e.streamMuxers {
+		buf = appendBytesField(buf, extensionsFieldStreamMuxers, []byte(m))
+	}
+	return buf
+}
 
-	// Send Message B with length prefix
-	frameBuf := make([]byte, 2+len(msgB))
-	frameBuf[0] = byte(len(msgB) >> 8)
-	frameBuf[1] = byte(len(msgB) & 0xff)
-	copy(frameBuf[2:], msgB)
-	_, err = conn.Write(frameBuf)
+// parseHandshakePayload decodes the remote NoiseHandshakePayload.
+func parseHandshakePayload(payload []byte) (handshakePayload, error) {
+	var p handshakePayload
+	err := walkProtobuf(payload, func(field, wireType uint64, value []byte) error {
+		if wireType != 2 {
+			return nil
+		}
+		switch field {
+		case payloadFieldIdentityKey:
+			p.identityKey = value
+		case payloadFieldIdentitySig:
+			p.identitySig = value
+		case payloadFieldExtensions:
+			ext, err := parseNoiseExtensions(value)
+			if err != nil {
+				return fmt.Errorf("extensions: %w", err)
+			}
+			p.extensions = ext
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseNoiseExtensions(b []byte) (*noiseExtensions, error) {
+	ext := &noiseExtensions{}
+	err := walkProtobuf(b, func(field, wireType uint64, value []byte) error {
+		switch {
+		case field == extensionsFieldCertHashes && wireType == 2:
+			ext.certHashes = append(ext.certHashes, value)
+		case field == extensionsFieldStreamMuxers && wireType == 2:
+			ext.streamMuxers = append(ext.streamMuxers, string(value))
+		default:
+			ext.unknownFields = append(ext.unknownFields, field)
+		}
+		return nil
+	})
+	return ext, err
+}
+
+// walkProtobuf calls fn for each field in a protobuf message. value is the
+// field body for length-delimited fields and nil for scalar ones.
+func walkProtobuf(b []byte, fn func(field, wireType uint64, value []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("malformed field key")
+		}
+		b = b[n:]
+
+		field, wireType := key>>3, key&0x7
+		var value []byte
+		switch wireType {
+		case 0: // varint
+			_, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("malformed varint field")
+			}
+			b = b[n:]
+		case 1: // 64-bit
+			if len(b) < 8 {
+				return errors.New("truncated 64-bit field")
+			}
+			b = b[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return errors.New("truncated length-delimited field")
+			}
+			value = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case 5: // 32-bit
+			if len(b) < 4 {
+				return errors.New("truncated 32-bit field")
+			}
+			b = b[4:]
+		default:
+			return errors.New("unsupported wire type")
+		}
+		if err := fn(field, wireType, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reasons reported in PAYLOAD_VERDICT lines.
+const (
+	reasonOK                 = "ok"
+	reasonMalformedPayload   = "malformed-payload"
+	reasonMissingIdentityKey = "missing-identity-key"
+	reasonBadIdentityKey     = "bad-identity-key"
+	reasonMissingSignature   = "missing-signature"
+	reasonBadSignature       = "bad-signature"
+)
+
+// payloadVerdict is the result of checking a remote handshake payload.
+type payloadVerdict struct {
+	// Empty when the identity key is missing or does not decode.
+	identity peer.ID
+	valid    bool
+	reason   string
+}
+
+// String formats the verdict as logged after "PAYLOAD_VERDICT: ".
+func (v payloadVerdict) String() string {
+	identity, sig := "-", "invalid"
+	if v.identity != "" {
+		identity = v.identity.String()
+	}
+	if v.valid {
+		sig = "valid"
+	}
+	return fmt.Sprintf("identity=%s sig=%s reason=%s", identity, sig, v.reason)
+}
+
+// verifyHandshakePayload derives the peer ID from the payload's identity key
+// and checks the signature over the remote Noise static key. remoteStatic must
+// be the handshake state's PeerStatic, the key used in the DH, so a payload
+// signing any other key is rejected.
+func verifyHandshakePayload(p handshakePayload, remoteStatic []byte) payloadVerdict {
+	if len(p.identityKey) == 0 {
+		return payloadVerdict{reason: reasonMissingIdentityKey}
+	}
+
+	pubKey, err := crypto.UnmarshalPublicKey(p.identityKey)
 	if err != nil {
-		log.Printf("Failed to send Message B: %v", err)
-		return
+		return payloadVerdict{reason: reasonBadIdentityKey}
+	}
+	id, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		return payloadVerdict{reason: reasonBadIdentityKey}
+	}
+	if len(p.identitySig) == 0 {
+		return payloadVerdict{identity: id, reason: reasonMissingSignature}
 	}
-	log.Printf("Sent Message B frame (%d bytes)", len(frameBuf))
 
-	// Check if handshake complete
-	if cs1 != nil && cs2 != nil {
-		log.Printf("Handshake complete after Message B")
-		return
+	valid, err := pubKey.Verify(append([]byte(staticKeySignaturePrefix), remoteStatic...), p.identitySig)
+	if err != nil || !valid {
+		return payloadVerdict{identity: id, reason: reasonBadSignature}
 	}
+	return payloadVerdict{identity: id, valid: true, reason: reasonOK}
+}
 
-	// Read Message C
-	n, err = conn.Read(buf)
+func appendBytesField(buf []byte, field uint64, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// frameReader reads length-prefixed frames from a byte stream. Frames may be
+// split across any number of TCP segments or coalesced into one; bytes past
+// the current frame stay buffered for the next read. It is used for the
+// multistream phase, the Noise handshake and post-handshake transport frames
+// so no bytes are lost when switching between them.
+type frameReader struct {
+	r *bufio.Reader
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReader(r)}
+}
+
+// readNoiseFrame reads one 2-byte big-endian length-prefixed Noise message,
+// rejecting a declared length over maxNoiseMessageSize before reading the body.
+func (f *frameReader) readNoiseFrame() ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(f.r, header[:]); err != nil {
+		return nil, err
+	}
+	length := uint64(binary.BigEndian.Uint16(header[:]))
+	if length > maxNoiseMessageSize {
+		return nil, &frameTooLargeError{length: length}
+	}
+	return f.readExactly(length)
+}
+
+// readMultistreamMessage reads one uvarint length-prefixed multistream-select message.
+func (f *frameReader) readMultistreamMessage() ([]byte, error) {
+	length, _, err := f.readMultistreamLength()
 	if err != nil {
-		if err == io.EOF {
-			log.Printf("Connection closed before Message C")
-		} else {
-			log.Printf("Failed to read Message C: %v", err)
+		return nil, err
+	}
+	return f.readMultistreamBody(length)
+}
+
+// readMultistreamLength reads a multistream-select length prefix and reports
+// whether it was minimally encoded, which go-varint requires.
+func (f *frameReader) readMultistreamLength() (uint64, bool, error) {
+	counter := &countingByteReader{r: f.r}
+	length, err := binary.ReadUvarint(counter)
+	if err != nil {
+		return 0, false, err
+	}
+	return length, counter.n == len(binary.AppendUvarint(nil, length)), nil
+}
+
+func (f *frameReader) readMultistreamBody(length uint64) ([]byte, error) {
+	if length > maxMultistreamMessageSize {
+		return nil, fmt.Errorf("multistream message too large: %d", length)
+	}
+	return f.readExactly(length)
+}
+
+type countingByteReader struct {
+	r io.ByteReader
+	n int
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func (f *frameReader) readExactly(n uint64) ([]byte, error) {
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(f.r, msg); err != nil {
+		if errors.Is(err, io.EOF) {
+			// The length prefix was read, so a missing body is a truncation
+			err = io.ErrUnexpectedEOF
 		}
-		return
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeFrame writes msg with a 2-byte length prefix. A message over
+// maxNoiseMessageSize is refused rather than sent with a wrapped prefix.
+func writeFrame(w io.Writer, msg []byte) error {
+	if len(msg) > maxNoiseMessageSize {
+		return &frameTooLargeError{length: uint64(len(msg))}
+	}
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// readMultistreamLine reads one multistream-select message, checks its
+// newline terminator and logs the decoded string. A non-minimal length
+// prefix is recorded in report, and under COMPAT=strict refused before the
+// body is read.
+func readMultistreamLine(fr *frameReader, report *compatReport, logger *log.Logger) (string, error) {
+	length, minimal, err := fr.readMultistreamLength()
+	if err != nil {
+		return "", err
+	}
+	if !minimal && !report.allow(logger, ruleNonMinimalVarint, fmt.Sprintf("length %d", length)) {
+		return "", fmt.Errorf("non-minimal multistream length prefix (%d)", length)
+	}
+	msg, err := fr.readMultistreamBody(length)
+	if err != nil {
+		return "", err
+	}
+	line, ok := strings.CutSuffix(string(msg), "\n")
+	if !ok {
+		return "", fmt.Errorf("multistream message without newline: %s", hex.EncodeToString(msg))
+	}
+	logger.Printf("MSS <- %q (%d bytes)", line, len(msg))
+	return line, nil
+}
+
+// writeMultistreamLine sends line with its newline terminator and length
+// prefix, logging the decoded string.
+func writeMultistreamLine(w io.Writer, line string, logger *log.Logger) error {
+	if err := writeMultistreamMessage(w, []byte(line+"\n")); err != nil {
+		return err
 	}
-	log.Printf("Received Message C frame (%d bytes): %s", n, hex.EncodeToString(buf[:n]))
+	logger.Printf("MSS -> %q", line)
+	return nil
+}
 
-	log.Printf("Noise handshake test complete")
+// writeListResponse answers ls: newline-terminated protocol IDs followed by
+// a blank line, all inside a single length prefix (matching the Swift
+// listener's encoding).
+func writeListResponse(w io.Writer, protocols []string, logger *log.Logger) error {
+	var body []byte
+	for _, p := range protocols {
+		body = append(body, p...)
+		body = append(body, '\n')
+	}
+	body = append(body, '\n')
+	if err := writeMultistreamMessage(w, body); err != nil {
+		return err
+	}
+	logger.Printf("MSS -> ls response %q", protocols)
+	return nil
 }
 
-func createDummyPayload() []byte {
-	// Create a minimal libp2p noise payload
-	// This is just for testing - a real implementation would sign the static key
-	return []byte{}
+func writeMultistreamMessage(w io.Writer, msg []byte) error {
+	buf := binary.AppendUvarint(nil, uint64(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
 }
@@ -0,0 +1,107 @@
+//go:build framingtest
+
+// Multistream-select responder checks for the debug node. Run inside the
+// builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+// proposals encodes the dialer side of a negotiation: the header followed by
+// each proposal.
+func proposals(lines ...string) []byte {
+	stream := multistreamFrame(multistreamProtocol + "\n")
+	for _, line := range lines {
+		stream = append(stream, multistreamFrame(line+"\n")...)
+	}
+	return stream
+}
+
+func negotiate(t *testing.T, supported []string, lines ...string) (string, *frameReader, error) {
+	t.Helper()
+	var out bytes.Buffer
+	logger := log.New(io.Discard, "", 0)
+	selected, err := negotiateSecurity(newFrameReader(bytes.NewReader(proposals(lines...))), &out, supported, newCompatReport(compatLenient), logger)
+	return selected, newFrameReader(&out), err
+}
+
+func expectReplies(t *testing.T, fr *frameReader, want ...string) {
+	t.Helper()
+	for i, expected := range want {
+		got, err := fr.readMultistreamMessage()
+		if err != nil {
+			t.Fatalf("reply %d: %v", i, err)
+		}
+		if string(got) != expected {
+			t.Fatalf("reply %d: got %q, want %q", i, got, expected)
+		}
+	}
+	if _, err := fr.readMultistreamMessage(); err != io.EOF {
+		t.Fatalf("expected no more replies, got %v", err)
+	}
+}
+
+func TestNegotiateAcceptsSupportedProtocol(t *testing.T) {
+	selected, replies, err := negotiate(t, []string{noiseProtocol}, noiseProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != noiseProtocol {
+		t.Fatalf("selected %q", selected)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", noiseProtocol+"\n")
+}
+
+func TestNegotiateRejectsUnsupportedThenAccepts(t *testing.T) {
+	selected, replies, err := negotiate(t, []string{noiseProtocol}, "/tls/1.0.0", noiseProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != noiseProtocol {
+		t.Fatalf("selected %q", selected)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "na\n", noiseProtocol+"\n")
+}
+
+func TestNegotiateListsSupportedProtocols(t *testing.T) {
+	supported := []string{"/tls/1.0.0", noiseProtocol}
+	selected, replies, err := negotiate(t, supported, "ls", "/tls/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != "/tls/1.0.0" {
+		t.Fatalf("selected %q", selected)
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "/tls/1.0.0\n/noise\n\n", "/tls/1.0.0\n")
+}
+
+func TestNegotiateRefusesNoiseWhenNotSupported(t *testing.T) {
+	_, replies, err := negotiate(t, []string{"/tls/1.0.0"}, noiseProtocol)
+	if err == nil {
+		t.Fatal("expected negotiation to fail when the dialer hangs up")
+	}
+	expectReplies(t, replies, multistreamProtocol+"\n", "na\n")
+}
+
+func TestNegotiateRejectsBadHeader(t *testing.T) {
+	fr := newFrameReader(bytes.NewReader(multistreamFrame("/multistream/2.0.0\n")))
+	_, err := negotiateSecurity(fr, io.Discard, []string{noiseProtocol}, newCompatReport(compatLenient), log.New(io.Discard, "", 0))
+	if err == nil || !strings.Contains(err.Error(), "unexpected multistream header") {
+		t.Fatalf("expected header error, got %v", err)
+	}
+}
+
+func TestNegotiateRequiresNewline(t *testing.T) {
+	stream := append(multistreamFrame(multistreamProtocol+"\n"), multistreamFrame(noiseProtocol)...)
+	_, err := negotiateSecurity(newFrameReader(bytes.NewReader(stream)), io.Discard, []string{noiseProtocol}, newCompatReport(compatLenient), log.New(io.Discard, "", 0))
+	if err == nil || !strings.Contains(err.Error(), "without newline") {
+		t.Fatalf("expected newline error, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build framingtest
+
+// RESPONSE_DELAY_MS and PAUSE_AFTER checks for the debug node. Run inside
+// the builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseDelayPrecedesEachFrame(t *testing.T) {
+	rec := &recordingWriter{}
+	var logs strings.Builder
+	w := responsePacing{delay: 20 * time.Millisecond}.wrap(rec, log.New(&logs, "", 0))
+
+	start := time.Now()
+	for range 2 {
+		if err := writeFrame(w, []byte{0xaa}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("two frames took %s, want at least two delays", elapsed)
+	}
+	// Frames are delayed, not split
+	if len(rec.writes) != 2 || len(rec.writes[0]) != 3 {
+		t.Fatalf("writes = %x", rec.writes)
+	}
+	for _, want := range []string{
+		"DELAY_START: kind=response frame=1 bytes=3 delay_ms=20 at=",
+		"DELAY_END: kind=response frame=1 waited_ms=",
+		"DELAY_START: kind=response frame=2 ",
+		"DELAY_END: kind=response frame=2 ",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs.String())
+		}
+	}
+
+	if w := (responsePacing{}).wrap(rec, log.New(&logs, "", 0)); w != rec {
+		t.Error("a zero delay should leave the writer unwrapped")
+	}
+}
+
+func TestPauseGateHoldsUntilRelease(t *testing.T) {
+	gate := newPauseGate()
+	var logs strings.Builder
+	logger := log.New(&logs, "", 0)
+	if n := gate.release(); n != 0 {
+		t.Fatalf("released %d connections before any paused", n)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		gate.hold(pauseAfterMultistream, logger)
+		close(done)
+	}()
+	// An earlier release must not let the new holder through
+	select {
+	case <-done:
+		t.Fatal("hold returned before release")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := gate.release(); n != 1 {
+		t.Errorf("released %d connections, want 1", n)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hold did not return after release")
+	}
+
+	for _, want := range []string{
+		"DELAY_START: kind=pause after=multistream at=",
+		"DELAY_END: kind=pause after=multistream waited_ms=",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestLoadResponsePacing(t *testing.T) {
+	t.Setenv("RESPONSE_DELAY_MS", "250")
+	t.Setenv("PAUSE_AFTER", "message-a")
+	p, err := loadResponsePacing()
+	if err != nil || p.delay != 250*time.Millisecond || p.pauseAfter != pauseAfterMessageA {
+		t.Fatalf("pacing = %+v, err %v", p, err)
+	}
+
+	t.Setenv("PAUSE_AFTER", "message-c")
+	if _, err := loadResponsePacing(); err == nil {
+		t.Error("unknown PAUSE_AFTER accepted")
+	}
+	t.Setenv("PAUSE_AFTER", "")
+	t.Setenv("RESPONSE_DELAY_MS", "-1")
+	if _, err := loadResponsePacing(); err == nil {
+		t.Error("negative RESPONSE_DELAY_MS accepted")
+	}
+}
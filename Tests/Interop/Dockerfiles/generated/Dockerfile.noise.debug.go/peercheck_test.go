@@ -0,0 +1,181 @@
+//go:build framingtest
+
+// Expected-peer verification checks for the debug node. Run inside the
+// builder image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flynn/noise"
+)
+
+// otherPeer returns an expectedPeer naming an identity and static key no
+// test handshake uses.
+func otherPeer(t *testing.T, strict bool) expectedPeer {
+	t.Helper()
+	return expectedPeer{
+		peerID: newHandshakeResult(t).id.String(),
+		static: strings.Repeat("ab", noise.DH25519.DHLen()),
+		strict: strict,
+	}
+}
+
+func TestLoadExpectedPeer(t *testing.T) {
+	id := newHandshakeResult(t).id
+	t.Setenv("EXPECTED_REMOTE_PEER", id.String())
+	t.Setenv("EXPECTED_REMOTE_STATIC", strings.Repeat("AB", 32))
+	t.Setenv("STRICT", "1")
+
+	e, err := loadExpectedPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.peerID != id.String() || e.static != strings.Repeat("ab", 32) || !e.strict {
+		t.Errorf("loadExpectedPeer() = %+v", e)
+	}
+
+	for name, value := range map[string]string{
+		"EXPECTED_REMOTE_PEER":   "not-a-peer-id",
+		"EXPECTED_REMOTE_STATIC": "abcd",
+		"STRICT":                 "yes",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := loadExpectedPeer(); err == nil {
+				t.Errorf("%s=%q accepted", name, value)
+			}
+		})
+	}
+}
+
+func TestPeerCheckMatches(t *testing.T) {
+	transcript := &handshakeTranscript{RemotePeerID: "12D3KooWExample", RemoteStatic: "abcd"}
+	var logs strings.Builder
+	err := checkExpectedPeer(log.New(&logs, "", 0), expectedPeer{peerID: "12D3KooWExample", static: "abcd", strict: true}, transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "PEER_CHECK: expected=12D3KooWExample actual=12D3KooWExample match=true\n" +
+		"STATIC_CHECK: expected=abcd actual=abcd match=true\n"
+	if logs.String() != want {
+		t.Errorf("logs = %q, want %q", logs.String(), want)
+	}
+}
+
+func TestPeerCheckMismatchIsReportedWithoutStrict(t *testing.T) {
+	expect := otherPeer(t, false)
+	initiator, responder := runHandshakePair(t, responderConfig{expect: expect})
+
+	if !responder.transcript.Success {
+		t.Fatalf("handshake failed without STRICT: %s", responder.transcript.Error)
+	}
+	for _, want := range []string{
+		"PEER_CHECK: expected=" + expect.peerID + " actual=" + initiator.id.String() + " match=false\n",
+		"STATIC_CHECK: expected=" + expect.static + " actual=" + responder.transcript.RemoteStatic + " match=false\n",
+	} {
+		if !strings.Contains(responder.logs, want) {
+			t.Errorf("missing %q in:\n%s", want, responder.logs)
+		}
+	}
+}
+
+func TestPeerCheckMismatchFailsHandshakeWhenStrict(t *testing.T) {
+	_, responder := runHandshakePair(t, responderConfig{expect: otherPeer(t, true)})
+
+	if responder.transcript.Success || responder.transcript.Error != errPeerMismatch.Error() {
+		t.Fatalf("responder transcript: success=%t error=%q", responder.transcript.Success, responder.transcript.Error)
+	}
+}
+
+func TestStrictPeerMismatchExits(t *testing.T) {
+	var exitCode atomic.Int32
+	exitCode.Store(-1)
+	acceptor, listener, logs := startAcceptor(t, 0, func(a *connAcceptor) {
+		a.cfg.expect = otherPeer(t, true)
+		a.exit = func(code int) { exitCode.Store(int32(code)) }
+	})
+
+	conn, err := dialHandshake(listener.Addr().String(), newHandshakeResult(t).key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The responder closes the connection instead of echoing
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read after mismatch: %v, want EOF", err)
+	}
+	conn.Close()
+	acceptor.wait()
+
+	output := logs.String()
+	if !strings.Contains(output, "CONN_DONE: conn=1 outcome=peer_mismatch") {
+		t.Errorf("peer_mismatch outcome not logged:\n%s", output)
+	}
+	if code := exitCode.Load(); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestResponderRejectsSignatureOverOtherStaticKey(t *testing.T) {
+	initConn, respConn := net.Pipe()
+	defer initConn.Close()
+	responderKey, initiatorKey := newHandshakeResult(t).key, newHandshakeResult(t).key
+	discard := log.New(io.Discard, "", 0)
+
+	respErr := make(chan error, 1)
+	go func() {
+		defer respConn.Close()
+		_, _, err := respondNoiseHandshake(newFrameReader(respConn), respConn, responderKey,
+			responderConfig{expect: expectedPeer{strict: true}}, newHandshakeTranscript("responder"), discard)
+		respErr <- err
+	}()
+
+	// A hand-rolled initiator whose payload signs a key other than the static
+	// key carried (and used for DH) in Message C
+	_, hs, err := newHandshakeState(true, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgA, _, _, err := hs.WriteMessage(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFrame(initConn, msgA); err != nil {
+		t.Fatal(err)
+	}
+	msgB, err := newFrameReader(initConn).readNoiseFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := hs.ReadMessage(nil, msgB); err != nil {
+		t.Fatal(err)
+	}
+	decoy, err := noise.DH25519.GenerateKeypair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := createHandshakePayload(initiatorKey, decoy.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgC, _, _, err := hs.WriteMessage(nil, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFrame(initConn, msgC); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-respErr; !errors.Is(err, errInvalidPayload) || !strings.Contains(err.Error(), string(reasonBadSignature)) {
+		t.Fatalf("responder error = %v, want %s", err, reasonBadSignature)
+	}
+}
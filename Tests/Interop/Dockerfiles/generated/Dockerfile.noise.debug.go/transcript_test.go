@@ -0,0 +1,166 @@
+//go:build framingtest
+
+// Handshake transcript checks for the debug node. Run inside the builder
+// image with:
+//
+//	go test -tags framingtest .
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type handshakeResult struct {
+	transcript handshakeTranscript
+	logs       string
+	key        crypto.PrivKey
+	id         peer.ID
+}
+
+// runHandshakePair runs both debug-node handshake roles against each other
+// over an in-memory pipe and returns the emitted transcripts.
+func runHandshakePair(t *testing.T, cfg responderConfig) (initiator, responder handshakeResult) {
+	t.Helper()
+	initConn, respConn := net.Pipe()
+
+	initiator, responder = newHandshakeResult(t), newHandshakeResult(t)
+
+	respLogs := make(chan string)
+	go func() {
+		defer respConn.Close()
+		var logs bytes.Buffer
+		logger := log.New(&logs, "", 0)
+		tr := newHandshakeTranscript("responder")
+		_, _, err := respondNoiseHandshake(newFrameReader(respConn), respConn, responder.key, cfg, tr, logger)
+		tr.emit(logger, err)
+		respLogs <- logs.String()
+	}()
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	tr := newHandshakeTranscript("initiator")
+	_, _, _, err := initiateNoiseHandshake(newFrameReader(initConn), initConn, initiator.key, tr, logger)
+	tr.emit(logger, err)
+	initConn.Close()
+
+	initiator.logs, responder.logs = logs.String(), <-respLogs
+	initiator.transcript = parseTranscript(t, initiator.logs)
+	responder.transcript = parseTranscript(t, responder.logs)
+	return initiator, responder
+}
+
+// newHandshakeResult generates the identity for one side of the pair.
+func newHandshakeResult(t *testing.T) handshakeResult {
+	t.Helper()
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handshakeResult{key: key, id: id}
+}
+
+// parseTranscript decodes the single TRANSCRIPT: line in logs.
+func parseTranscript(t *testing.T, logs string) handshakeTranscript {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if rest, ok := strings.CutPrefix(line, "TRANSCRIPT: "); ok {
+			lines = append(lines, rest)
+		}
+	}
+	if len(lines) != 1 {
+		t.Fatalf("got %d TRANSCRIPT lines, want 1", len(lines))
+	}
+	var tr handshakeTranscript
+	if err := json.Unmarshal([]byte(lines[0]), &tr); err != nil {
+		t.Fatalf("decode transcript: %v", err)
+	}
+	return tr
+}
+
+func TestTranscriptSuccessMatchesOnBothSides(t *testing.T) {
+	initiator, responder := runHandshakePair(t, responderConfig{})
+
+	for _, r := range []handshakeResult{initiator, responder} {
+		if !r.transcript.Success || r.transcript.Error != "" {
+			t.Fatalf("%s transcript: success=%t error=%q", r.transcript.Role, r.transcript.Success, r.transcript.Error)
+		}
+		if len(r.transcript.Messages) != 3 {
+			t.Fatalf("%s transcript has %d messages, want 3", r.transcript.Role, len(r.transcript.Messages))
+		}
+		if len(r.transcript.HandshakeHash) != 64 {
+			t.Errorf("%s handshake hash %q is not 32 bytes", r.transcript.Role, r.transcript.HandshakeHash)
+		}
+	}
+	if initiator.transcript.HandshakeHash != responder.transcript.HandshakeHash {
+		t.Errorf("handshake hash differs: initiator %s, responder %s",
+			initiator.transcript.HandshakeHash, responder.transcript.HandshakeHash)
+	}
+	if initiator.transcript.RemotePeerID != responder.id.String() {
+		t.Errorf("initiator saw remote %s, want %s", initiator.transcript.RemotePeerID, responder.id)
+	}
+	if responder.transcript.RemotePeerID != initiator.id.String() {
+		t.Errorf("responder saw remote %s, want %s", responder.transcript.RemotePeerID, initiator.id)
+	}
+
+	// Both sides describe the same messages
+	for i, want := range []struct {
+		message, sender string
+		ephemeral       bool
+	}{
+		{"A", "initiator", true},
+		{"B", "responder", true},
+		{"C", "initiator", false},
+	} {
+		in, out := initiator.transcript.Messages[i], responder.transcript.Messages[i]
+		for _, m := range []*transcriptMessage{in, out} {
+			if m.Message != want.message || m.Sender != want.sender {
+				t.Errorf("message %d = %s from %s, want %s from %s", i, m.Message, m.Sender, want.message, want.sender)
+			}
+			if (m.Ephemeral != "") != want.ephemeral {
+				t.Errorf("message %s ephemeral = %q", m.Message, m.Ephemeral)
+			}
+		}
+		if in.Length != out.Length || in.Ephemeral != out.Ephemeral {
+			t.Errorf("message %s differs: initiator %+v, responder %+v", want.message, in, out)
+		}
+		received := in
+		if want.sender == "initiator" {
+			received = out
+		}
+		if received.PayloadDecrypted == nil || !*received.PayloadDecrypted {
+			t.Errorf("message %s: receiver did not record a decrypted payload", want.message)
+		}
+	}
+}
+
+func TestTranscriptRecordsDecryptionFailure(t *testing.T) {
+	initiator, responder := runHandshakePair(t, responderConfig{fault: faultConfig{mode: faultCorruptMAC}})
+
+	if initiator.transcript.Success || initiator.transcript.Error == "" {
+		t.Fatalf("initiator transcript should record the failure: %+v", initiator.transcript)
+	}
+	if len(initiator.transcript.Messages) != 2 {
+		t.Fatalf("initiator transcript has %d messages, want 2", len(initiator.transcript.Messages))
+	}
+	msgB := initiator.transcript.Messages[1]
+	if msgB.PayloadDecrypted == nil || *msgB.PayloadDecrypted {
+		t.Errorf("Message B payload_decrypted = %v, want false", msgB.PayloadDecrypted)
+	}
+	if responder.transcript.Success {
+		t.Error("responder transcript should record the failure")
+	}
+}
@@ -1,17 +1,150 @@
 package main
 
+// The go-libp2p Noise test node: TCP with Noise, Identify and Ping, for
+// exercising the Noise handshake. The echo (echo.go), PERF=1 (perf.go) and
+// EVENTS=1 (eventlog.go; muxer_negotiated carries via=early-data|multistream,
+// and the echo, bulk and ping streams are reported) are the shared ones.
+//
+// SECURITY (ordered list of noise, tls, plaintext; default noise) sets the
+// security protocols offered, printed as SECURITY_ORDER: <ids>; plaintext is
+// refused unless ALLOW_INSECURE=1. MUXERS (yamux, mplex; default yamux) does
+// the same for muxers, printed as MUXER_ORDER: <ids>, and also orders early
+// muxer negotiation inside the Noise or TLS handshake.
+//
+// The yamux session takes YAMUX_WINDOW (initial stream receive window,
+// default 262144), YAMUX_MAX_WINDOW (auto-tuning limit, equal to
+// YAMUX_WINDOW turns it off; default 16777216), YAMUX_KEEPALIVE_S (0
+// disables; default 30), YAMUX_MAX_STREAMS (inbound streams per session, 0
+// leaves it to the resource manager) and YAMUX_WRITE_TIMEOUT_MS (default
+// 10000), printed as
+//
+//	YAMUX_CONFIG: window=<n> max_window=<n> autotune=<b> keepalive_s=<n> max_streams=<n|unlimited> write_timeout_ms=<n>
+//
+// Yamux streams always start with a 256 KiB window, so go-yamux refuses a
+// smaller YAMUX_WINDOW; YAMUX_WINDOW=262144 YAMUX_MAX_WINDOW=262144 is the
+// tightest setting and makes a Swift sender wait for a window update every
+// 256 KiB.
+//
+// Each upgraded connection prints
+//
+//	CONN_STATE: peer=<id> security=<proto> muxer=<proto> transport=<proto> muxer_via=early-data|multistream key_type=<type>
+//	IDENTIFY_TIME: peer=<id> duration_ms=<n>
+//
+// the second once the first identify round completes, timed from the
+// Connected notification. A failed upgrade prints UPGRADE_FAILED:
+// stage=security|muxer, and the CONNS command prints CONN_STATE for every
+// open connection. Inbound upgrades are timed from TCP accept as
+// "HANDSHAKE: peer=<id> dir=inbound noise_ms=<a> muxer_ms=<b> total_ms=<c>",
+// or "HANDSHAKE_FAIL: stage=tcp|multistream|noise|muxer err=<e>" (tls or
+// plaintext instead of noise when SECURITY picks them); HANDSHAKE_STATS
+// prints the count, failures and min/avg/p95 of total_ms.
+//
+// KEY_TYPE (ed25519, secp256k1, ecdsa, rsa2048, rsa4096; default ed25519)
+// selects the identity key, printed as KEY_TYPE: type=<name>
+// pubkey_len=<n> peer=<id>; CONN_STATE's key_type names the remote's key the
+// same way. EXPECT_REMOTE_KEY_TYPE checks each inbound peer's key once its
+// first identify round completes and prints REMOTE_KEY: peer=<id>
+// type=<type> match=<bool>. "PUBKEY <peerID>" prints the stored key as
+// PUBKEY: peer=<id> type=<type> raw=<hex> protobuf=<hex>, or PUBKEY_FAILED:
+// peer=<id> err=<e>.
+//
+// "DIAL <multiaddr>" connects, waits for identify, echoes 4KB over
+// /test/echo/1.0.0 and prints DIAL_ECHO_OK: peer=<id> rtt_ms=<x>, or
+// DIAL_FAILED: stage=dial|negotiate|stream-open|echo-io|echo-mismatch.
+// "PING <multiaddr|peerID> [count]" (default 3) prints PING_RTT: peer=<id>
+// seq=<n> rtt_ms=<x> or PING_FAILED per probe, then PING_DONE: sent=<n>
+// ok=<n>. "PINGSTATS <peerID> <count> <interval_ms> [parallel]" opens
+// parallel ping streams, each sending count probes interval_ms apart, and
+// prints a PINGSTATS_PROBE line per probe (rtt_ms, or
+// error=reset|timeout|eof|mismatch|unsupported|error) and a PINGSTATS
+// summary with loss and min/avg/p95/max. go-libp2p allows 2 inbound and 3
+// outbound ping streams per peer; PING_PEER_STREAMS=<n> raises both
+// (PING_LIMITS: peer_streams=<n>). All of these run in the background with
+// timeouts.
+//
+// TCP_REUSEPORT (default 1), TCP_NODELAY (default 1) and TCP_KEEPALIVE_S (0
+// disables; default 30) set the socket options, printed as TCP_OPTIONS:
+// reuseport=<b> nodelay=<b> keepalive_s=<n>. "DIAL_FROM_LISTEN_PORT
+// <multiaddr> [initiator|responder]" dials from the listen port as a
+// simultaneous connect with a fixed handshake role (default initiator),
+// printing SIMOPEN when the TCP connection is up, then
+// DIAL_FROM_LISTEN_PORT_OK: peer= role= local= remote= dir=, or
+// DIAL_FROM_LISTEN_PORT_FAILED: stage=dial|port.
+//
+// Each connected peer's identify is printed once as IDENTIFY: peer=<id>
+// agent=<a> proto_version=<v> listen_addrs=[..] protocols=[..]
+// observed=<maddr>, and every later push as IDENTIFY_PUSH: peer=<id>
+// changed=[protocols,addrs] added=[..] removed=[..]. AGENT_VERSION and
+// PROTOCOL_VERSION set this node's own values.
+//
+// /test/bulk/1.0.0 reads an 8-byte big-endian length and that many bytes
+// and replies with their SHA-256 and the count; /test/bulk-down/1.0.0 reads
+// the same header and sends that many bytes of byte(i % 251). Both print
+// BULK_PROGRESS: peer=<id> dir=up|down bytes=<n> every 10MB and end with
+// BULK_DONE: ... bytes=<n> sha256=<hex> duration_ms=<d> mb_per_s=<r>, or
+// BULK_FAILED.
+
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p"
+	mplex "github.com/libp2p/go-libp2p-mplex"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/sec"
+	"github.com/libp2p/go-libp2p/core/sec/insecure"
+	"github.com/libp2p/go-libp2p/core/transport"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	"github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
-	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/multiformats/go-multistream"
+)
+
+const (
+	echoProtocol     = "/test/echo/1.0.0"
+	bulkProtocol     = "/test/bulk/1.0.0"
+	bulkDownProtocol = "/test/bulk-down/1.0.0"
+
+	// dialTimeout bounds a whole DIAL command; pingTimeout bounds one probe.
+	dialTimeout = 30 * time.Second
+	pingTimeout = 10 * time.Second
+
+	echoPayloadSize = 4096
+
+	// Bulk transfers print BULK_PROGRESS every bulkProgressInterval bytes
+	// and move data in bulkChunkSize writes.
+	bulkProgressInterval = 10 << 20
+	bulkChunkSize        = 256 << 10
 )
 
 func main() {
@@ -24,21 +157,78 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid port: %v", err)
 	}
+	echoBuf, err := echoBufferSize()
+	if err != nil {
+		log.Fatalf("Invalid echo buffer: %v", err)
+	}
+
+	identity, keyType, err := loadIdentity()
+	if err != nil {
+		log.Fatalf("Invalid identity key: %v", err)
+	}
+
+	expectedKeyType, err := loadExpectedRemoteKeyType()
+	if err != nil {
+		log.Fatalf("Invalid remote key expectation: %v", err)
+	}
+
+	securityOptions, securityIDs, err := loadSecurityStack()
+	if err != nil {
+		log.Fatalf("Invalid security stack: %v", err)
+	}
+
+	yamuxOpts, err := loadYamuxOptions()
+	if err != nil {
+		log.Fatalf("Invalid yamux options: %v", err)
+	}
+
+	muxerOptions, muxerIDs, err := loadMuxers(yamuxOpts.transport())
+	if err != nil {
+		log.Fatalf("Invalid muxers: %v", err)
+	}
+
+	socket, err := loadSocketOptions()
+	if err != nil {
+		log.Fatalf("Invalid TCP options: %v", err)
+	}
+	tcpSocket = socket
 
-	// Create a new libp2p host with TCP + Noise (no TLS)
-	h, err := libp2p.New(
+	pingStreams, err := loadPingPeerStreams()
+	if err != nil {
+		log.Fatalf("Invalid ping limits: %v", err)
+	}
+
+	// Set before the host exists so upgrade stages of the first
+	// connections are reported too
+	events = newEventLog()
+
+	// Create a new libp2p host with TCP + the configured security stack
+	options := []libp2p.Option{
+		libp2p.Identity(identity),
 		libp2p.ListenAddrStrings(
 			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
 		),
 		// Disable default transports
 		libp2p.NoTransports,
-		libp2p.Transport(tcp.NewTCPTransport),
-		// Noise only - no TLS
-		libp2p.Security(noise.ID, noise.New),
-		// Yamux muxer
-		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
+		libp2p.Transport(newTimedTCPTransport, socket.transportOptions()...),
 		libp2p.Ping(true),
-	)
+	}
+	if pingStreams > 0 {
+		mgr, err := newPingLimitedResourceManager(pingStreams)
+		if err != nil {
+			log.Fatalf("Failed to create resource manager: %v", err)
+		}
+		options = append(options, libp2p.ResourceManager(mgr))
+	}
+	// AGENT_VERSION and PROTOCOL_VERSION replace go-libp2p's identify defaults
+	if agent := os.Getenv("AGENT_VERSION"); agent != "" {
+		options = append(options, libp2p.UserAgent(agent))
+	}
+	if version := os.Getenv("PROTOCOL_VERSION"); version != "" {
+		options = append(options, libp2p.ProtocolVersion(version))
+	}
+	options = append(options, securityOptions...)
+	h, err := libp2p.New(append(options, muxerOptions...)...)
 	if err != nil {
 		log.Fatalf("Failed to create host: %v", err)
 	}
@@ -46,7 +236,72 @@ func main() {
 
 	peerID := h.ID()
 	log.Printf("Local peer id: %s", peerID.String())
-	log.Printf("Security: Noise (XX pattern)")
+	rawKey, err := identity.GetPublic().Raw()
+	if err != nil {
+		log.Fatalf("Failed to read identity public key: %v", err)
+	}
+	fmt.Printf("KEY_TYPE: type=%s pubkey_len=%d peer=%s\n", keyType, len(rawKey), peerID)
+	fmt.Printf("SECURITY_ORDER: %s\n", strings.Join(securityIDs, ","))
+	fmt.Printf("MUXER_ORDER: %s\n", strings.Join(muxerIDs, ","))
+	fmt.Printf("YAMUX_CONFIG: %s\n", yamuxOpts)
+	fmt.Printf("TCP_OPTIONS: reuseport=%t nodelay=%t keepalive_s=%d\n",
+		socket.reuseport, socket.noDelay, int(socket.keepAlive/time.Second))
+	if pingStreams > 0 {
+		fmt.Printf("PING_LIMITS: peer_streams=%d\n", pingStreams)
+	}
+	if expectedKeyType != "" {
+		fmt.Printf("EXPECT_REMOTE_KEY_TYPE: type=%s\n", expectedKeyType)
+	}
+
+	// Report what each connection negotiated, how long identify took and
+	// what each peer identified as
+	tracker := newConnTracker()
+	identities := newIdentifyLog()
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(n network.Network, c network.Conn) {
+			tracker.connected(c)
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			tracker.disconnected(c)
+			if n.Connectedness(c.RemotePeer()) != network.Connected {
+				identities.forget(c.RemotePeer())
+			}
+		},
+	})
+	identifySub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerIdentificationFailed),
+	})
+	if err != nil {
+		log.Fatalf("Failed to subscribe to identify events: %v", err)
+	}
+	defer identifySub.Close()
+	go func() {
+		for e := range identifySub.Out() {
+			switch evt := e.(type) {
+			case event.EvtPeerIdentificationCompleted:
+				first := tracker.identified(evt.Conn)
+				identities.record(evt)
+				if first && expectedKeyType != "" && evt.Conn.Stat().Direction == network.DirInbound {
+					checkRemoteKey(h, evt.Peer, expectedKeyType)
+				}
+			case event.EvtPeerIdentificationFailed:
+				fmt.Printf("IDENTIFY_FAILED: peer=%s err=%v\n", evt.Peer, evt.Reason)
+			}
+		}
+	}()
+
+	if events != nil {
+		eventSub, err := events.attach(h)
+		if err != nil {
+			log.Fatalf("Failed to subscribe to connectivity events: %v", err)
+		}
+		defer eventSub.Close()
+		// Serve ping through the event log; libp2p.Ping registered the
+		// same handler unwrapped
+		pinger := &ping.PingService{Host: h}
+		h.SetStreamHandler(ping.ID, events.handler(pinger.PingHandler))
+	}
 
 	// Print listen addresses
 	for _, addr := range h.Addrs() {
@@ -56,22 +311,1463 @@ func main() {
 	fmt.Println("Ready to accept connections")
 
 	// Echo handler for testing encrypted communication
-	h.SetStreamHandler("/test/echo/1.0.0", func(s network.Stream) {
+	h.SetStreamHandler(echoProtocol, events.handler(func(s network.Stream) {
 		log.Printf("Received encrypted stream from %s", s.Conn().RemotePeer())
-		defer s.Close()
+		echoStream(s, echoBuf, nil)
+	}))
+
+	h.SetStreamHandler(bulkProtocol, events.handler(handleBulkUpload))
+	h.SetStreamHandler(bulkDownProtocol, events.handler(handleBulkDownload))
+	if os.Getenv("PERF") == "1" {
+		h.SetStreamHandler(perfProtocol, events.handler(handlePerf))
+	}
+
+	go handleCommands(h, tracker)
 
-		buf := make([]byte, 1024)
-		for {
-			n, err := s.Read(buf)
+	select {}
+}
+
+// handleCommands reads commands from stdin. DIAL, PING, PINGSTATS and
+// DIAL_FROM_LISTEN_PORT run in their own goroutines so a slow peer never blocks the command loop.
+func handleCommands(h host.Host, tracker *connTracker) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "CONNS":
+			conns := h.Network().Conns()
+			fmt.Printf("CONNS: count=%d\n", len(conns))
+			for _, c := range conns {
+				fmt.Println(tracker.describe(c))
+			}
+		case "HANDSHAKE_STATS":
+			fmt.Println(handshakes.summary())
+		case "PUBKEY":
+			if len(fields) != 2 {
+				fmt.Println("PUBKEY_FAILED: err=usage: PUBKEY <peerID>")
+				continue
+			}
+			printPublicKey(h, fields[1])
+		case "DIAL":
+			if len(fields) != 2 {
+				fmt.Println("DIAL_FAILED: stage=dial err=usage: DIAL <multiaddr>")
+				continue
+			}
+			go dialEcho(h, fields[1])
+		case "PING":
+			if len(fields) < 2 || len(fields) > 3 {
+				fmt.Println("PING_FAILED: err=usage: PING <multiaddr|peerID> [count]")
+				continue
+			}
+			count := 3
+			if len(fields) == 3 {
+				n, err := strconv.Atoi(fields[2])
+				if err != nil || n < 1 {
+					fmt.Printf("PING_FAILED: err=invalid count %q\n", fields[2])
+					continue
+				}
+				count = n
+			}
+			go pingPeer(h, fields[1], count)
+		case "PINGSTATS":
+			if len(fields) < 4 || len(fields) > 5 {
+				fmt.Println("PINGSTATS_FAILED: err=usage: PINGSTATS <peerID> <count> <interval_ms> [parallel]")
+				continue
+			}
+			p, err := peer.Decode(fields[1])
 			if err != nil {
+				fmt.Printf("PINGSTATS_FAILED: target=%s err=%v\n", fields[1], err)
+				continue
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 1 {
+				fmt.Printf("PINGSTATS_FAILED: err=invalid count %q\n", fields[2])
+				continue
+			}
+			intervalMs, err := strconv.Atoi(fields[3])
+			if err != nil || intervalMs < 0 {
+				fmt.Printf("PINGSTATS_FAILED: err=invalid interval_ms %q\n", fields[3])
+				continue
+			}
+			parallel := 1
+			if len(fields) == 5 {
+				parallel, err = strconv.Atoi(fields[4])
+				if err != nil || parallel < 1 {
+					fmt.Printf("PINGSTATS_FAILED: err=invalid parallel %q\n", fields[4])
+					continue
+				}
+			}
+			go pingStats(h, p, count, time.Duration(intervalMs)*time.Millisecond, parallel)
+		case "DIAL_FROM_LISTEN_PORT":
+			if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && fields[2] != "initiator" && fields[2] != "responder") {
+				fmt.Println("DIAL_FROM_LISTEN_PORT_FAILED: err=usage: DIAL_FROM_LISTEN_PORT <multiaddr> [initiator|responder]")
+				continue
+			}
+			initiator := len(fields) == 2 || fields[2] == "initiator"
+			go dialFromListenPort(h, fields[1], initiator)
+		}
+	}
+}
+
+// dialEcho connects to addr, waits for identify, then sends a deterministic
+// payload over the echo protocol and checks that the same bytes come back.
+// The outcome is one DIAL_ECHO_OK line or a DIAL_FAILED line naming the
+// stage that failed: dial, negotiate, stream-open, echo-io or echo-mismatch.
+func dialEcho(h host.Host, addr string) {
+	fail := func(stage string, err error) {
+		fmt.Printf("DIAL_FAILED: stage=%s addr=%s err=%v\n", stage, addr, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		fail("dial", err)
+		return
+	}
+	if err := h.Connect(ctx, *info); err != nil {
+		fail("dial", err)
+		return
+	}
+	if ids, ok := h.(interface{ IDService() identify.IDService }); ok {
+		for _, c := range h.Network().ConnsToPeer(info.ID) {
+			select {
+			case <-ids.IDService().IdentifyWait(c):
+			case <-ctx.Done():
+				fail("dial", fmt.Errorf("identify: %w", ctx.Err()))
 				return
 			}
-			if n > 0 {
-				log.Printf("Echo (encrypted): %d bytes", n)
-				s.Write(buf[:n])
+		}
+	}
+
+	start := time.Now()
+	s, err := h.NewStream(ctx, info.ID, echoProtocol)
+	if err != nil {
+		fail(streamStage(err), err)
+		return
+	}
+	s = events.stream(s)
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	payload := make([]byte, echoPayloadSize)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	// Protocol negotiation may be deferred to the first write or read, so an
+	// unsupported protocol can surface here rather than from NewStream
+	if _, err := s.Write(payload); err != nil {
+		fail(streamStage(err), err)
+		return
+	}
+	if err := s.CloseWrite(); err != nil {
+		fail("echo-io", err)
+		return
+	}
+	echoed := make([]byte, len(payload))
+	if _, err := io.ReadFull(s, echoed); err != nil {
+		if stage := streamStage(err); stage == "negotiate" {
+			fail(stage, err)
+		} else {
+			fail("echo-io", err)
+		}
+		return
+	}
+	rtt := time.Since(start)
+
+	if !bytes.Equal(payload, echoed) {
+		offset := 0
+		for offset < len(payload) && payload[offset] == echoed[offset] {
+			offset++
+		}
+		fail("echo-mismatch", fmt.Errorf("first differing byte at offset %d", offset))
+		return
+	}
+	fmt.Printf("DIAL_ECHO_OK: peer=%s rtt_ms=%.3f\n", info.ID, milliseconds(rtt))
+}
+
+// streamStage attributes a stream error to protocol negotiation when the
+// remote rejected the echo protocol, and to stream-open otherwise.
+func streamStage(err error) string {
+	if errors.Is(err, multistream.ErrNotSupported[protocol.ID]{}) {
+		return "negotiate"
+	}
+	return "stream-open"
+}
+
+// pingPeer sends count ping probes to target, a multiaddr with a /p2p
+// component (connected first) or the peer ID of an existing connection.
+// Each probe prints PING_RTT or PING_FAILED, followed by one PING_DONE.
+func pingPeer(h host.Host, target string, count int) {
+	var p peer.ID
+	if strings.HasPrefix(target, "/") {
+		info, err := peer.AddrInfoFromString(target)
+		if err != nil {
+			fmt.Printf("PING_FAILED: target=%s err=%v\n", target, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		err = h.Connect(ctx, *info)
+		cancel()
+		if err != nil {
+			fmt.Printf("PING_FAILED: peer=%s err=%v\n", info.ID, err)
+			return
+		}
+		p = info.ID
+	} else {
+		id, err := peer.Decode(target)
+		if err != nil {
+			fmt.Printf("PING_FAILED: target=%s err=%v\n", target, err)
+			return
+		}
+		p = id
+	}
+
+	ok := 0
+	for seq := 1; seq <= count; seq++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		var res ping.Result
+		select {
+		case r, open := <-ping.Ping(ctx, h, p):
+			if open {
+				res = r
+			} else {
+				res.Error = ctx.Err()
+			}
+		case <-ctx.Done():
+			res.Error = ctx.Err()
+		}
+		cancel()
+
+		if res.Error != nil {
+			fmt.Printf("PING_FAILED: peer=%s seq=%d err=%v\n", p, seq, res.Error)
+			continue
+		}
+		ok++
+		fmt.Printf("PING_RTT: peer=%s seq=%d rtt_ms=%.3f\n", p, seq, milliseconds(res.RTT))
+	}
+	fmt.Printf("PING_DONE: peer=%s sent=%d ok=%d\n", p, count, ok)
+}
+
+// errPingMismatch reports a ping reply that differs from the probe, as when
+// frames from concurrent streams are interleaved.
+var errPingMismatch = errors.New("ping reply does not match probe")
+
+// pingStats runs PINGSTATS: parallel ping streams to p at once, each sending
+// count probes spaced by interval. It speaks /ipfs/ping/1.0.0 itself rather
+// than through ping.Ping, which sends the next probe as soon as the previous
+// one returns, so interval spaces probes on the wire. Each probe prints
+// "PINGSTATS_PROBE: peer=<id> stream=<n> seq=<n> rtt_ms=<x>" or, on failure,
+// "... error=<kind> err=<msg>" (see pingErrorKind); a failed stream is
+// replaced for its remaining probes. The run ends with one PINGSTATS line:
+// probes, ok, lost, loss_pct, min/avg/p95/max of the successful RTTs and
+// the failures by kind.
+func pingStats(h host.Host, p peer.ID, count int, interval time.Duration, parallel int) {
+	var (
+		mu     sync.Mutex
+		rtts   []time.Duration
+		failed = map[string]int{}
+		wg     sync.WaitGroup
+	)
+	for stream := 1; stream <= parallel; stream++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s network.Stream
+			defer func() {
+				if s != nil {
+					s.Close()
+				}
+			}()
+			for seq := 1; seq <= count; seq++ {
+				if seq > 1 {
+					time.Sleep(interval)
+				}
+				rtt, err := pingProbe(h, p, &s)
+				mu.Lock()
+				if err != nil {
+					kind := pingErrorKind(err)
+					failed[kind]++
+					fmt.Printf("PINGSTATS_PROBE: peer=%s stream=%d seq=%d error=%s err=%v\n", p, stream, seq, kind, err)
+				} else {
+					rtts = append(rtts, rtt)
+					fmt.Printf("PINGSTATS_PROBE: peer=%s stream=%d seq=%d rtt_ms=%.3f\n", p, stream, seq, milliseconds(rtt))
+				}
+				mu.Unlock()
 			}
+		}()
+	}
+	wg.Wait()
+
+	probes := count * parallel
+	lost := probes - len(rtts)
+	summary := fmt.Sprintf("PINGSTATS: peer=%s streams=%d probes=%d ok=%d lost=%d loss_pct=%.1f",
+		p, parallel, probes, len(rtts), lost, 100*float64(lost)/float64(probes))
+	if len(rtts) > 0 {
+		slices.Sort(rtts)
+		var sum time.Duration
+		for _, d := range rtts {
+			sum += d
+		}
+		p95 := rtts[int(math.Ceil(0.95*float64(len(rtts))))-1]
+		summary += fmt.Sprintf(" min_ms=%.3f avg_ms=%.3f p95_ms=%.3f max_ms=%.3f",
+			milliseconds(rtts[0]), milliseconds(sum/time.Duration(len(rtts))),
+			milliseconds(p95), milliseconds(rtts[len(rtts)-1]))
+	}
+	kinds := make([]string, 0, len(failed))
+	for kind, n := range failed {
+		kinds = append(kinds, fmt.Sprintf("%s:%d", kind, n))
+	}
+	slices.Sort(kinds)
+	fmt.Printf("%s errors=[%s]\n", summary, strings.Join(kinds, ","))
+}
+
+// pingProbe sends one 32-byte probe on *s, opening a ping stream first if
+// *s is nil, and returns the round-trip time. On failure the stream is
+// reset and *s cleared so the next probe opens a fresh one.
+func pingProbe(h host.Host, p peer.ID, s *network.Stream) (time.Duration, error) {
+	if *s == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		stream, err := h.NewStream(ctx, p, ping.ID)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		*s = events.stream(stream)
+	}
+
+	probe := make([]byte, ping.PingSize)
+	if _, err := rand.Read(probe); err != nil {
+		return 0, err
+	}
+	reply := make([]byte, ping.PingSize)
+	(*s).SetDeadline(time.Now().Add(pingTimeout))
+	start := time.Now()
+	_, err := (*s).Write(probe)
+	if err == nil {
+		_, err = io.ReadFull(*s, reply)
+	}
+	if err == nil && !bytes.Equal(probe, reply) {
+		err = errPingMismatch
+	}
+	if err != nil {
+		(*s).Reset()
+		*s = nil
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// pingErrorKind classifies a probe failure: reset (the stream was reset),
+// timeout (deadline exceeded), eof (the remote closed the stream), mismatch
+// (the reply differs from the probe), unsupported (the remote does not speak
+// ping) or error.
+func pingErrorKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, network.ErrReset):
+		return "reset"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	case errors.Is(err, errPingMismatch):
+		return "mismatch"
+	case streamStage(err) == "negotiate":
+		return "unsupported"
+	}
+	return "error"
+}
+
+// dialFromListenPort connects to addr from the TCP port the node listens on,
+// which needs reuseport. The dial is marked as a simultaneous connect, so
+// the upgrade takes the given role whichever side's SYN arrived first: the
+// initiator runs multistream-select and the security handshake as the
+// dialer, the responder as the listener. With each side dialing the other's
+// listen port, the harness can stage a TCP simultaneous open without racing
+// over which side initiates the handshake. The outcome is one
+// DIAL_FROM_LISTEN_PORT_OK line or a DIAL_FROM_LISTEN_PORT_FAILED line
+// naming the stage that failed: dial, or port when the connection did not
+// come from a listen port.
+func dialFromListenPort(h host.Host, addr string, initiator bool) {
+	fail := func(stage string, err error) {
+		fmt.Printf("DIAL_FROM_LISTEN_PORT_FAILED: stage=%s addr=%s err=%v\n", stage, addr, err)
+	}
+	if !tcpSocket.reuseport {
+		fail("dial", errors.New("reuseport is disabled or unavailable"))
+		return
+	}
+
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		fail("dial", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	ctx = network.WithForceDirectDial(ctx, "DIAL_FROM_LISTEN_PORT")
+	ctx = network.WithSimultaneousConnect(ctx, initiator, "DIAL_FROM_LISTEN_PORT")
+	if err := h.Connect(ctx, *info); err != nil {
+		fail("dial", err)
+		return
+	}
+
+	listenPorts := make(map[string]bool)
+	for _, a := range h.Network().ListenAddresses() {
+		if port, err := a.ValueForProtocol(multiaddr.P_TCP); err == nil {
+			listenPorts[port] = true
+		}
+	}
+	for _, c := range h.Network().ConnsToPeer(info.ID) {
+		port, err := c.LocalMultiaddr().ValueForProtocol(multiaddr.P_TCP)
+		if err != nil || !listenPorts[port] {
+			continue
+		}
+		fmt.Printf("DIAL_FROM_LISTEN_PORT_OK: peer=%s role=%s local=%s remote=%s dir=%s\n",
+			info.ID, simultaneousRole(initiator), c.LocalMultiaddr(), c.RemoteMultiaddr(),
+			strings.ToLower(c.Stat().Direction.String()))
+		return
+	}
+	fail("port", fmt.Errorf("no connection to %s from a listen port", info.ID))
+}
+
+func simultaneousRole(initiator bool) string {
+	if initiator {
+		return "initiator"
+	}
+	return "responder"
+}
+
+// handleBulkUpload serves /test/bulk/1.0.0: the client sends an 8-byte
+// big-endian length and then that many bytes. The reply is the SHA-256 of
+// what was received followed by the received byte count (8 bytes, big-endian).
+func handleBulkUpload(s network.Stream) {
+	defer s.Close()
+	progress := newBulkProgress(s.Conn().RemotePeer(), "up")
+
+	length, err := readBulkLength(s)
+	if err != nil {
+		progress.fail(s, err)
+		return
+	}
+
+	hasher := sha256.New()
+	body := io.LimitReader(s, int64(length))
+	n, err := io.CopyBuffer(io.MultiWriter(hasher, progress), body, make([]byte, bulkChunkSize))
+	if err != nil {
+		progress.fail(s, err)
+		return
+	}
+	if uint64(n) != length {
+		progress.fail(s, fmt.Errorf("stream ended after %d of %d bytes", n, length))
+		return
+	}
+
+	digest := hasher.Sum(nil)
+	reply := binary.BigEndian.AppendUint64(append([]byte(nil), digest...), uint64(n))
+	if _, err := s.Write(reply); err != nil {
+		progress.fail(s, err)
+		return
+	}
+	progress.done(digest)
+}
+
+// handleBulkDownload serves /test/bulk-down/1.0.0: the client sends an 8-byte
+// big-endian length and the node answers with that many bytes of the
+// byte(i % 251) pattern, then closes its write side.
+func handleBulkDownload(s network.Stream) {
+	defer s.Close()
+	progress := newBulkProgress(s.Conn().RemotePeer(), "down")
+
+	length, err := readBulkLength(s)
+	if err != nil {
+		progress.fail(s, err)
+		return
+	}
+
+	hasher := sha256.New()
+	buf := make([]byte, bulkChunkSize)
+	for sent := uint64(0); sent < length; {
+		chunk := buf[:min(uint64(len(buf)), length-sent)]
+		for i := range chunk {
+			chunk[i] = byte((sent + uint64(i)) % 251)
+		}
+		if _, err := s.Write(chunk); err != nil {
+			progress.fail(s, err)
+			return
 		}
+		hasher.Write(chunk)
+		progress.Write(chunk)
+		sent += uint64(len(chunk))
+	}
+	if err := s.CloseWrite(); err != nil {
+		progress.fail(s, err)
+		return
+	}
+	progress.done(hasher.Sum(nil))
+}
+
+func readBulkLength(r io.Reader) (uint64, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, fmt.Errorf("length header: %w", err)
+	}
+	length := binary.BigEndian.Uint64(header[:])
+	if length > math.MaxInt64 {
+		return 0, fmt.Errorf("length %d too large", length)
+	}
+	return length, nil
+}
+
+// bulkProgress counts the bytes written to it, printing BULK_PROGRESS each
+// time another bulkProgressInterval bytes have passed, and reports the
+// transfer's outcome with its throughput.
+type bulkProgress struct {
+	peer  peer.ID
+	dir   string
+	start time.Time
+	bytes uint64
+	next  uint64
+}
+
+func newBulkProgress(p peer.ID, dir string) *bulkProgress {
+	return &bulkProgress{peer: p, dir: dir, start: time.Now(), next: bulkProgressInterval}
+}
+
+func (b *bulkProgress) Write(p []byte) (int, error) {
+	b.bytes += uint64(len(p))
+	if b.bytes >= b.next {
+		fmt.Printf("BULK_PROGRESS: peer=%s dir=%s bytes=%d\n", b.peer, b.dir, b.bytes)
+		b.next = (b.bytes/bulkProgressInterval + 1) * bulkProgressInterval
+	}
+	return len(p), nil
+}
+
+func (b *bulkProgress) done(digest []byte) {
+	elapsed := time.Since(b.start)
+	fmt.Printf("BULK_DONE: peer=%s dir=%s bytes=%d sha256=%x duration_ms=%.3f mb_per_s=%.2f\n",
+		b.peer, b.dir, b.bytes, digest, milliseconds(elapsed), float64(b.bytes)/1e6/elapsed.Seconds())
+}
+
+func (b *bulkProgress) fail(s network.Stream, err error) {
+	fmt.Printf("BULK_FAILED: peer=%s dir=%s bytes=%d err=%v\n", b.peer, b.dir, b.bytes, err)
+	s.Reset()
+}
+
+// connTracker remembers when each connection came up and when its first
+// identify round completed. Identify can finish before the Connected
+// notification reaches us, so either side may record first.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[network.Conn]*trackedConn
+}
+
+type trackedConn struct {
+	connectedAt  time.Time
+	identifiedAt time.Time
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[network.Conn]*trackedConn)}
+}
+
+func (t *connTracker) connected(c network.Conn) {
+	t.mu.Lock()
+	tc := t.entry(c)
+	tc.connectedAt = time.Now()
+	identifiedFirst := !tc.identifiedAt.IsZero()
+	if identifiedFirst {
+		tc.identifiedAt = tc.connectedAt
+	}
+	t.mu.Unlock()
+
+	fmt.Println(t.describe(c))
+	if identifiedFirst {
+		fmt.Printf("IDENTIFY_TIME: peer=%s duration_ms=0\n", c.RemotePeer())
+	}
+}
+
+// identified records the first identify round on c and reports whether
+// this was it.
+func (t *connTracker) identified(c network.Conn) bool {
+	if c == nil {
+		return false
+	}
+	t.mu.Lock()
+	tc := t.entry(c)
+	if !tc.identifiedAt.IsZero() {
+		t.mu.Unlock()
+		return false
+	}
+	identifiedAt := time.Now()
+	tc.identifiedAt = identifiedAt
+	connectedAt := tc.connectedAt
+	t.mu.Unlock()
+
+	// Logged by connected() once the notification arrives
+	if connectedAt.IsZero() {
+		return true
+	}
+	fmt.Printf("IDENTIFY_TIME: peer=%s duration_ms=%d\n",
+		c.RemotePeer(), identifiedAt.Sub(connectedAt).Milliseconds())
+	return true
+}
+
+func (t *connTracker) disconnected(c network.Conn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+	log.Printf("Disconnected: %s", c.RemotePeer())
+}
+
+// describe formats the negotiated protocols of c, whether the muxer was
+// chosen inside the security handshake (early-data) or by multistream-select
+// afterwards and the type of the key the remote authenticated with, plus the
+// time from the Connected notification to identify completing once it has.
+func (t *connTracker) describe(c network.Conn) string {
+	state := c.ConnState()
+	via := "multistream"
+	if state.UsedEarlyMuxerNegotiation {
+		via = "early-data"
+	}
+	keyType := "none"
+	if pub := c.RemotePublicKey(); pub != nil {
+		keyType = keyTypeName(pub)
+	}
+	line := fmt.Sprintf("CONN_STATE: peer=%s security=%s muxer=%s transport=%s muxer_via=%s key_type=%s",
+		c.RemotePeer(), state.Security, state.StreamMultiplexer, state.Transport, via, keyType)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tc, ok := t.conns[c]; ok && !tc.connectedAt.IsZero() && !tc.identifiedAt.IsZero() {
+		line += fmt.Sprintf(" identify_ms=%d", tc.identifiedAt.Sub(tc.connectedAt).Milliseconds())
+	}
+	return line
+}
+
+// entry must be called with mu held.
+func (t *connTracker) entry(c network.Conn) *trackedConn {
+	tc, ok := t.conns[c]
+	if !ok {
+		tc = &trackedConn{}
+		t.conns[c] = tc
+	}
+	return tc
+}
+
+// identifyLog remembers what each connected peer last identified as. go-libp2p
+// reports every identify message it consumes, pushes included, as
+// EvtPeerIdentificationCompleted, so the first one for a peer is printed as
+// IDENTIFY and later ones as IDENTIFY_PUSH with the difference.
+type identifyLog struct {
+	mu    sync.Mutex
+	peers map[peer.ID]identifyState
+}
+
+type identifyState struct {
+	addrs     []string
+	protocols []string
+}
+
+func newIdentifyLog() *identifyLog {
+	return &identifyLog{peers: make(map[peer.ID]identifyState)}
+}
+
+func (l *identifyLog) record(evt event.EvtPeerIdentificationCompleted) {
+	state := identifyState{
+		addrs:     make([]string, 0, len(evt.ListenAddrs)),
+		protocols: make([]string, 0, len(evt.Protocols)),
+	}
+	for _, addr := range evt.ListenAddrs {
+		state.addrs = append(state.addrs, addr.String())
+	}
+	for _, proto := range evt.Protocols {
+		state.protocols = append(state.protocols, string(proto))
+	}
+	sort.Strings(state.addrs)
+	sort.Strings(state.protocols)
+
+	l.mu.Lock()
+	previous, known := l.peers[evt.Peer]
+	l.peers[evt.Peer] = state
+	l.mu.Unlock()
+
+	if !known {
+		observed := "none"
+		if evt.ObservedAddr != nil {
+			observed = evt.ObservedAddr.String()
+		}
+		fmt.Printf("IDENTIFY: peer=%s agent=%s proto_version=%s listen_addrs=[%s] protocols=[%s] observed=%s\n",
+			evt.Peer, evt.AgentVersion, evt.ProtocolVersion,
+			strings.Join(state.addrs, ","), strings.Join(state.protocols, ","), observed)
+		return
+	}
+
+	// A second connection to the same peer also lands here, with nothing changed
+	var changed []string
+	if !slices.Equal(previous.protocols, state.protocols) {
+		changed = append(changed, "protocols")
+	}
+	if !slices.Equal(previous.addrs, state.addrs) {
+		changed = append(changed, "addrs")
+	}
+	fmt.Printf("IDENTIFY_PUSH: peer=%s changed=[%s] added=[%s] removed=[%s]\n",
+		evt.Peer, strings.Join(changed, ","),
+		strings.Join(missingFrom(previous.protocols, state.protocols), ","),
+		strings.Join(missingFrom(state.protocols, previous.protocols), ","))
+}
+
+func (l *identifyLog) forget(p peer.ID) {
+	l.mu.Lock()
+	delete(l.peers, p)
+	l.mu.Unlock()
+}
+
+// missingFrom returns the entries of values that base does not contain.
+func missingFrom(base, values []string) []string {
+	var missing []string
+	for _, v := range values {
+		if !slices.Contains(base, v) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// loadIdentity generates the host's identity key of the type named by
+// KEY_TYPE: ed25519 (default), secp256k1, ecdsa, rsa2048 or rsa4096.
+func loadIdentity() (crypto.PrivKey, string, error) {
+	name := os.Getenv("KEY_TYPE")
+	if name == "" {
+		name = "ed25519"
+	}
+
+	var keyType, bits int
+	switch name {
+	case "ed25519":
+		keyType, bits = crypto.Ed25519, -1
+	case "secp256k1":
+		keyType, bits = crypto.Secp256k1, -1
+	case "ecdsa":
+		keyType, bits = crypto.ECDSA, -1
+	case "rsa2048":
+		keyType, bits = crypto.RSA, 2048
+	case "rsa4096":
+		keyType, bits = crypto.RSA, 4096
+	default:
+		return nil, "", fmt.Errorf("KEY_TYPE: unknown key type %q (want ed25519, secp256k1, ecdsa, rsa2048 or rsa4096)", name)
+	}
+
+	priv, _, err := crypto.GenerateKeyPair(keyType, bits)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate %s key: %w", name, err)
+	}
+	return priv, name, nil
+}
+
+// identityKeyTypes are the KEY_TYPE and EXPECT_REMOTE_KEY_TYPE names.
+var identityKeyTypes = []string{"ed25519", "secp256k1", "ecdsa", "rsa2048", "rsa4096"}
+
+// loadExpectedRemoteKeyType reads EXPECT_REMOTE_KEY_TYPE, the key type
+// inbound peers must identify with (one of the KEY_TYPE names); empty
+// disables the check.
+func loadExpectedRemoteKeyType() (string, error) {
+	name := os.Getenv("EXPECT_REMOTE_KEY_TYPE")
+	if name != "" && !slices.Contains(identityKeyTypes, name) {
+		return "", fmt.Errorf("EXPECT_REMOTE_KEY_TYPE: unknown key type %q (want one of %s)",
+			name, strings.Join(identityKeyTypes, ", "))
+	}
+	return name, nil
+}
+
+// keyTypeName names pub the way KEY_TYPE does, with RSA keys named by their
+// modulus size.
+func keyTypeName(pub crypto.PubKey) string {
+	if std, err := crypto.PubKeyToStdKey(pub); err == nil {
+		if key, ok := std.(*rsa.PublicKey); ok {
+			return fmt.Sprintf("rsa%d", key.N.BitLen())
+		}
+	}
+	return strings.ToLower(pub.Type().String())
+}
+
+// checkRemoteKey compares the type of the key the peerstore holds for p
+// with EXPECT_REMOTE_KEY_TYPE and prints REMOTE_KEY.
+func checkRemoteKey(h host.Host, p peer.ID, expected string) {
+	actual := "none"
+	if pub := h.Peerstore().PubKey(p); pub != nil {
+		actual = keyTypeName(pub)
+	}
+	fmt.Printf("REMOTE_KEY: peer=%s type=%s match=%t\n", p, actual, actual == expected)
+}
+
+// printPublicKey prints the public key the peerstore holds for target, raw
+// and in its protobuf envelope (what a Noise payload carries), so it can be
+// compared byte for byte with what the peer sent.
+func printPublicKey(h host.Host, target string) {
+	p, err := peer.Decode(target)
+	if err != nil {
+		fmt.Printf("PUBKEY_FAILED: peer=%s err=%v\n", target, err)
+		return
+	}
+	pub := h.Peerstore().PubKey(p)
+	if pub == nil {
+		fmt.Printf("PUBKEY_FAILED: peer=%s err=no public key stored\n", p)
+		return
+	}
+	raw, err := pub.Raw()
+	if err != nil {
+		fmt.Printf("PUBKEY_FAILED: peer=%s err=%v\n", p, err)
+		return
+	}
+	envelope, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		fmt.Printf("PUBKEY_FAILED: peer=%s err=%v\n", p, err)
+		return
+	}
+	fmt.Printf("PUBKEY: peer=%s type=%s raw=%x protobuf=%x\n", p, keyTypeName(pub), raw, envelope)
+}
+
+// securityProtocols maps SECURITY entries to their protocol ID and a
+// constructor for the logged transport.
+var securityProtocols = map[string]struct {
+	id          protocol.ID
+	constructor interface{}
+}{
+	"noise":     {noise.ID, newLoggedNoise},
+	"tls":       {libp2ptls.ID, newLoggedTLS},
+	"plaintext": {insecure.ID, newLoggedPlaintext},
+}
+
+// loadSecurityStack reads SECURITY, an ordered comma-separated list of
+// noise, tls and plaintext (default noise). The order is the order in which
+// the protocols are offered. plaintext also requires ALLOW_INSECURE=1.
+func loadSecurityStack() ([]libp2p.Option, []string, error) {
+	value := os.Getenv("SECURITY")
+	if value == "" {
+		value = "noise"
+	}
+
+	var options []libp2p.Option
+	var ids []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		proto, ok := securityProtocols[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("SECURITY: unknown protocol %q (want noise, tls or plaintext)", name)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("SECURITY: %s listed twice", name)
+		}
+		if name == "plaintext" && os.Getenv("ALLOW_INSECURE") != "1" {
+			return nil, nil, fmt.Errorf("SECURITY: plaintext requires ALLOW_INSECURE=1")
+		}
+		seen[name] = true
+		options = append(options, libp2p.Security(string(proto.id), proto.constructor))
+		ids = append(ids, string(proto.id))
+	}
+	return options, ids, nil
+}
+
+// muxerProtocols maps MUXERS entries to their protocol ID and transport.
+// The yamux transport is replaced by the YAMUX_* configuration.
+var muxerProtocols = map[string]struct {
+	id        protocol.ID
+	transport network.Multiplexer
+}{
+	"yamux": {yamux.ID, yamux.DefaultTransport},
+	"mplex": {mplex.ID, mplex.DefaultTransport},
+}
+
+// loadMuxers reads MUXERS, an ordered comma-separated list of yamux and
+// mplex (default yamux). The order is the order in which the muxers are
+// offered, both over multistream-select and in the security handshake's
+// early muxer negotiation. yamuxTransport is used for yamux.
+func loadMuxers(yamuxTransport network.Multiplexer) ([]libp2p.Option, []string, error) {
+	value := os.Getenv("MUXERS")
+	if value == "" {
+		value = "yamux"
+	}
+
+	var options []libp2p.Option
+	var ids []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		muxer, ok := muxerProtocols[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("MUXERS: unknown muxer %q (want yamux or mplex)", name)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("MUXERS: %s listed twice", name)
+		}
+		seen[name] = true
+		if name == "yamux" {
+			muxer.transport = yamuxTransport
+		}
+		options = append(options, libp2p.Muxer(string(muxer.id), loggedMuxer{muxer.transport, muxer.id}))
+		ids = append(ids, string(muxer.id))
+	}
+	return options, ids, nil
+}
+
+func logUpgradeFailure(stage string, remote net.Addr, err error) {
+	fmt.Printf("UPGRADE_FAILED: stage=%s remote=%s err=%v\n", stage, remote, err)
+	events.emit("upgrade_failed", eventFields{"stage": stage, "remote": addrString(remote), "err": err.Error()})
+}
+
+// loggedSecurity is a security transport with handshake failures reported
+// as UPGRADE_FAILED at the security stage. The secured connection it returns
+// reports a muxer stage failure if it is closed before a muxer takes it over.
+// Inbound handshakes on a timedConn also record their security stage.
+// Successful handshakes are reported as security_negotiated events.
+type loggedSecurity struct {
+	sec.SecureTransport
+	id   protocol.ID
+	name string
+}
+
+func newLoggedNoise(id protocol.ID, privkey crypto.PrivKey, muxers []upgrader.StreamMuxer) (*loggedSecurity, error) {
+	t, err := noise.New(id, privkey, muxers)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedSecurity{t, id, "noise"}, nil
+}
+
+func newLoggedTLS(id protocol.ID, privkey crypto.PrivKey, muxers []upgrader.StreamMuxer) (*loggedSecurity, error) {
+	t, err := libp2ptls.New(id, privkey, muxers)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedSecurity{t, id, "tls"}, nil
+}
+
+func newLoggedPlaintext(id protocol.ID, self peer.ID, privkey crypto.PrivKey) *loggedSecurity {
+	return &loggedSecurity{insecure.NewWithIdentity(id, self, privkey), id, "plaintext"}
+}
+
+func (t *loggedSecurity) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
+	timed, _ := insecure.(*timedConn)
+	if timed != nil {
+		timed.securing(t.name)
+	}
+	start := time.Now()
+	c, err := t.SecureTransport.SecureInbound(ctx, insecure, p)
+	if err != nil {
+		logUpgradeFailure("security", insecure.RemoteAddr(), err)
+		if timed != nil {
+			timed.fail(t.name, err)
+		}
+		return nil, err
+	}
+	if timed != nil {
+		timed.secured()
+	}
+	t.secured(c, network.DirInbound, time.Since(start))
+	return &upgradingConn{SecureConn: c, timed: timed}, nil
+}
+
+func (t *loggedSecurity) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
+	start := time.Now()
+	c, err := t.SecureTransport.SecureOutbound(ctx, insecure, p)
+	if err != nil {
+		logUpgradeFailure("security", insecure.RemoteAddr(), err)
+		return nil, err
+	}
+	t.secured(c, network.DirOutbound, time.Since(start))
+	return &upgradingConn{SecureConn: c}, nil
+}
+
+func (t *loggedSecurity) secured(c sec.SecureConn, dir network.Direction, took time.Duration) {
+	events.emit("security_negotiated", eventFields{
+		"peer":        c.RemotePeer().String(),
+		"dir":         direction(dir),
+		"protocol":    string(t.id),
+		"remote":      addrString(c.RemoteAddr()),
+		"duration_ms": milliseconds(took),
 	})
+}
 
-	select {}
+// upgradingConn is a secured connection that has not yet been handed to a
+// muxer. The upgrader closes it when muxer negotiation fails.
+type upgradingConn struct {
+	sec.SecureConn
+
+	// timed is the underlying accepted connection, nil for outbound ones
+	timed *timedConn
+
+	mu    sync.Mutex
+	muxed bool
+}
+
+func (c *upgradingConn) markMuxed() {
+	c.mu.Lock()
+	c.muxed = true
+	c.mu.Unlock()
+}
+
+func (c *upgradingConn) Close() error {
+	c.mu.Lock()
+	muxed := c.muxed
+	c.mu.Unlock()
+	if !muxed {
+		logUpgradeFailure("muxer", c.RemoteAddr(), fmt.Errorf("closed before muxer setup"))
+	}
+	return c.SecureConn.Close()
+}
+
+// loggedMuxer reports muxer setup failures as UPGRADE_FAILED and successful
+// setups as muxer_negotiated events, with the connection wrapped so the
+// event log learns why it closed.
+type loggedMuxer struct {
+	network.Multiplexer
+	id protocol.ID
+}
+
+func (m loggedMuxer) NewConn(c net.Conn, isServer bool, scope network.PeerScope) (network.MuxedConn, error) {
+	uc, _ := c.(*upgradingConn)
+	if uc != nil {
+		uc.markMuxed()
+	}
+	mc, err := m.Multiplexer.NewConn(c, isServer, scope)
+	if err != nil {
+		logUpgradeFailure("muxer", c.RemoteAddr(), err)
+		if uc != nil && uc.timed != nil {
+			uc.timed.fail("muxer", err)
+		}
+		return mc, err
+	}
+	if uc != nil && uc.timed != nil {
+		uc.timed.muxed(uc.RemotePeer())
+	}
+	if events != nil {
+		fields := eventFields{
+			"dir":      direction(network.DirOutbound),
+			"protocol": string(m.id),
+			"remote":   addrString(c.RemoteAddr()),
+		}
+		if isServer {
+			fields["dir"] = direction(network.DirInbound)
+		}
+		if uc != nil {
+			fields["peer"] = uc.RemotePeer().String()
+			fields["via"] = "multistream"
+			if uc.ConnState().UsedEarlyMuxerNegotiation {
+				fields["via"] = "early-data"
+			}
+		}
+		events.emit("muxer_negotiated", fields)
+	}
+	return events.muxedConn(mc, c), nil
+}
+
+// socketOptions are the TCP settings read from the environment. NoDelay
+// and keepalives are applied to each connection, inbound and outbound, over
+// the transport's defaults.
+type socketOptions struct {
+	reuseport bool
+	noDelay   bool
+	// keepAlive is the keepalive period; zero disables keepalives
+	keepAlive time.Duration
+}
+
+var tcpSocket socketOptions
+
+// loadSocketOptions reads TCP_REUSEPORT (default on), TCP_NODELAY (default
+// on) and TCP_KEEPALIVE_S (keepalive period in seconds, 0 disables; default
+// 30, the transport's own period). reuseport is what is in effect, so it is
+// false where the platform lacks SO_REUSEPORT.
+func loadSocketOptions() (socketOptions, error) {
+	o := socketOptions{reuseport: true, noDelay: true, keepAlive: 30 * time.Second}
+	for name, target := range map[string]*bool{"TCP_REUSEPORT": &o.reuseport, "TCP_NODELAY": &o.noDelay} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return o, fmt.Errorf("%s: want 1/0 or true/false, got %q", name, value)
+		}
+		*target = enabled
+	}
+	if value := os.Getenv("TCP_KEEPALIVE_S"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return o, fmt.Errorf("TCP_KEEPALIVE_S: want a non-negative number of seconds, got %q", value)
+		}
+		o.keepAlive = time.Duration(seconds) * time.Second
+	}
+	o.reuseport = o.reuseport && tcp.ReuseportIsAvailable()
+	return o, nil
+}
+
+// yamuxMinWindow is the yamux initial stream window. Every stream starts
+// with it (the spec has no way to advertise less), so go-yamux refuses
+// smaller windows.
+const yamuxMinWindow = 256 << 10
+
+// yamuxOptions is the yamux session configuration. The zero values of
+// maxStreams and keepAlive mean unlimited and disabled.
+type yamuxOptions struct {
+	window       uint32
+	maxWindow    uint32
+	keepAlive    time.Duration
+	maxStreams   uint32
+	writeTimeout time.Duration
+}
+
+// loadYamuxOptions reads YAMUX_WINDOW (initial stream receive window in
+// bytes, at least 256 KiB; default 256 KiB), YAMUX_MAX_WINDOW (the window
+// auto-tuning may grow to; equal to YAMUX_WINDOW turns auto-tuning off;
+// default 16 MiB), YAMUX_KEEPALIVE_S (0 disables; default 30),
+// YAMUX_MAX_STREAMS (inbound streams per session, 0 for no limit beyond
+// the resource manager; default 0) and YAMUX_WRITE_TIMEOUT_MS (default
+// 10000). The defaults are go-libp2p's DefaultTransport.
+func loadYamuxOptions() (yamuxOptions, error) {
+	def := yamux.DefaultTransport
+	o := yamuxOptions{
+		window:       def.InitialStreamWindowSize,
+		maxWindow:    def.MaxStreamWindowSize,
+		writeTimeout: def.ConnectionWriteTimeout,
+	}
+	if def.EnableKeepAlive {
+		o.keepAlive = def.KeepAliveInterval
+	}
+	if def.MaxIncomingStreams != math.MaxUint32 {
+		o.maxStreams = def.MaxIncomingStreams
+	}
+
+	readUint32 := func(name string, target *uint32) error {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: want a non-negative number, got %q", name, value)
+		}
+		*target = uint32(n)
+		return nil
+	}
+	readDuration := func(name string, unit time.Duration, target *time.Duration) error {
+		var n uint32
+		if err := readUint32(name, &n); err != nil {
+			return err
+		}
+		if os.Getenv(name) != "" {
+			*target = time.Duration(n) * unit
+		}
+		return nil
+	}
+
+	window := os.Getenv("YAMUX_WINDOW")
+	if err := readUint32("YAMUX_WINDOW", &o.window); err != nil {
+		return o, err
+	}
+	if err := readUint32("YAMUX_MAX_WINDOW", &o.maxWindow); err != nil {
+		return o, err
+	}
+	if err := readDuration("YAMUX_KEEPALIVE_S", time.Second, &o.keepAlive); err != nil {
+		return o, err
+	}
+	if err := readUint32("YAMUX_MAX_STREAMS", &o.maxStreams); err != nil {
+		return o, err
+	}
+	if err := readDuration("YAMUX_WRITE_TIMEOUT_MS", time.Millisecond, &o.writeTimeout); err != nil {
+		return o, err
+	}
+
+	if o.window < yamuxMinWindow {
+		return o, fmt.Errorf("YAMUX_WINDOW: yamux streams start with a %d-byte window that cannot be advertised smaller, got %q; "+
+			"set YAMUX_MAX_WINDOW=%d for the tightest flow control", yamuxMinWindow, window, yamuxMinWindow)
+	}
+	if os.Getenv("YAMUX_MAX_WINDOW") == "" && o.maxWindow < o.window {
+		o.maxWindow = o.window
+	}
+	if o.maxWindow < o.window {
+		return o, fmt.Errorf("YAMUX_MAX_WINDOW: %d is below YAMUX_WINDOW %d", o.maxWindow, o.window)
+	}
+	if o.writeTimeout == 0 {
+		return o, errors.New("YAMUX_WRITE_TIMEOUT_MS: want a positive number of milliseconds")
+	}
+	return o, nil
+}
+
+// transport builds a yamux transport from go-libp2p's DefaultTransport with
+// these options applied.
+func (o yamuxOptions) transport() *yamux.Transport {
+	t := *yamux.DefaultTransport
+	t.InitialStreamWindowSize = o.window
+	t.MaxStreamWindowSize = o.maxWindow
+	t.EnableKeepAlive = o.keepAlive > 0
+	if o.keepAlive > 0 {
+		t.KeepAliveInterval = o.keepAlive
+	}
+	t.MaxIncomingStreams = math.MaxUint32
+	if o.maxStreams > 0 {
+		t.MaxIncomingStreams = o.maxStreams
+	}
+	t.ConnectionWriteTimeout = o.writeTimeout
+	return &t
+}
+
+// String formats the options as printed after "YAMUX_CONFIG: ".
+func (o yamuxOptions) String() string {
+	maxStreams := "unlimited"
+	if o.maxStreams > 0 {
+		maxStreams = strconv.FormatUint(uint64(o.maxStreams), 10)
+	}
+	return fmt.Sprintf("window=%d max_window=%d autotune=%t keepalive_s=%d max_streams=%s write_timeout_ms=%d",
+		o.window, o.maxWindow, o.maxWindow > o.window, int(o.keepAlive/time.Second), maxStreams,
+		o.writeTimeout.Milliseconds())
+}
+
+// loadPingPeerStreams reads PING_PEER_STREAMS, the number of ping streams
+// allowed per peer in each direction; 0 (the default) keeps go-libp2p's
+// limits of 2 inbound and 3 outbound, too few for parallel PINGSTATS runs.
+func loadPingPeerStreams() (int, error) {
+	value := os.Getenv("PING_PEER_STREAMS")
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("PING_PEER_STREAMS: want a non-negative number, got %q", value)
+	}
+	return n, nil
+}
+
+// newPingLimitedResourceManager is go-libp2p's default resource manager
+// with the per-peer ping service and protocol limits raised to streams in
+// each direction.
+func newPingLimitedResourceManager(streams int) (network.ResourceManager, error) {
+	limits := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&limits)
+	peerLimit := rcmgr.BaseLimit{
+		StreamsInbound:  streams,
+		StreamsOutbound: streams,
+		Streams:         2 * streams,
+		Memory:          32 * (256<<20 + 16<<10),
+	}
+	limits.AddServicePeerLimit(ping.ServiceName, peerLimit, rcmgr.BaseLimitIncrease{})
+	limits.AddProtocolPeerLimit(ping.ID, peerLimit, rcmgr.BaseLimitIncrease{})
+	return rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.AutoScale()))
+}
+
+// transportOptions are the arguments for the TCP transport constructor.
+func (o socketOptions) transportOptions() []interface{} {
+	if o.reuseport {
+		return nil
+	}
+	return []interface{}{tcp.DisableReuseport()}
+}
+
+// apply sets TCP_NODELAY and keepalives on c. Go enables TCP_NODELAY on
+// every TCP socket, and the transport enables 30s keepalives on dials.
+func (o socketOptions) apply(c net.Conn) {
+	tc, ok := c.(interface {
+		SetNoDelay(bool) error
+		SetKeepAlive(bool) error
+		SetKeepAlivePeriod(time.Duration) error
+	})
+	if !ok {
+		return
+	}
+	if err := tc.SetNoDelay(o.noDelay); err != nil {
+		log.Printf("TCP_NODELAY on %s: %v", c.RemoteAddr(), err)
+	}
+	if err := tc.SetKeepAlive(o.keepAlive > 0); err != nil {
+		log.Printf("SO_KEEPALIVE on %s: %v", c.RemoteAddr(), err)
+		return
+	}
+	if o.keepAlive > 0 {
+		if err := tc.SetKeepAlivePeriod(o.keepAlive); err != nil {
+			log.Printf("TCP keepalive period on %s: %v", c.RemoteAddr(), err)
+		}
+	}
+}
+
+// newTimedTCPTransport is the TCP transport with inbound connections timed
+// from accept until the muxer is up.
+func newTimedTCPTransport(u transport.Upgrader, rcmgr network.ResourceManager, opts ...tcp.Option) (*tcp.TcpTransport, error) {
+	return tcp.NewTCPTransport(timedUpgrader{u}, rcmgr, opts...)
+}
+
+// timedUpgrader hands the upgrader a listener whose connections are
+// timedConns. Both it and Upgrade apply the socket options.
+type timedUpgrader struct {
+	transport.Upgrader
+}
+
+func (u timedUpgrader) UpgradeListener(t transport.Transport, l manet.Listener) transport.Listener {
+	return u.Upgrader.UpgradeListener(t, &timedListener{l})
+}
+
+// Upgrade upgrades a dialed connection. A dial marked as a simultaneous
+// connect is reported as SIMOPEN with the handshake role it was given. The
+// kernel reports a TCP simultaneous open as an ordinary successful connect,
+// so that mark is all the transport can go by. An accepted connection is
+// never one: in a simultaneous open neither side accepts.
+func (u timedUpgrader) Upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, scope network.ConnManagementScope) (transport.CapableConn, error) {
+	tcpSocket.apply(maconn)
+	if ok, isClient, reason := network.GetSimultaneousConnect(ctx); ok {
+		fmt.Printf("SIMOPEN: peer=%s role=%s local=%s remote=%s reason=%s\n",
+			p, simultaneousRole(isClient), maconn.LocalMultiaddr(), maconn.RemoteMultiaddr(), reason)
+	}
+	return u.Upgrader.Upgrade(ctx, t, maconn, dir, p, scope)
+}
+
+type timedListener struct {
+	manet.Listener
+}
+
+func (l *timedListener) Accept() (manet.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			handshakes.failed("tcp", err)
+		}
+		return nil, err
+	}
+	tcpSocket.apply(c)
+	events.emit("inbound_connection", eventFields{
+		"local":  c.LocalMultiaddr().String(),
+		"remote": c.RemoteMultiaddr().String(),
+	})
+	return &timedConn{Conn: c, acceptedAt: time.Now()}, nil
+}
+
+// timedConn is an accepted connection being upgraded. Security and muxer
+// wrappers record when each stage finishes; the first of muxed or fail
+// reports the outcome as HANDSHAKE or HANDSHAKE_FAIL.
+type timedConn struct {
+	manet.Conn
+
+	mu         sync.Mutex
+	acceptedAt time.Time
+	security   string
+	securingAt time.Time
+	securedAt  time.Time
+	reported   bool
+}
+
+// securing is called once multistream has selected the security protocol.
+func (c *timedConn) securing(name string) {
+	c.mu.Lock()
+	c.security = name
+	c.securingAt = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *timedConn) secured() {
+	c.mu.Lock()
+	c.securedAt = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *timedConn) muxed(p peer.ID) {
+	now := time.Now()
+	c.mu.Lock()
+	if c.reported {
+		c.mu.Unlock()
+		return
+	}
+	c.reported = true
+	noiseTime := c.securedAt.Sub(c.securingAt)
+	muxerTime := now.Sub(c.securedAt)
+	total := now.Sub(c.acceptedAt)
+	c.mu.Unlock()
+
+	handshakes.succeeded(total)
+	fmt.Printf("HANDSHAKE: peer=%s dir=inbound noise_ms=%.3f muxer_ms=%.3f total_ms=%.3f\n",
+		p, milliseconds(noiseTime), milliseconds(muxerTime), milliseconds(total))
+}
+
+func (c *timedConn) fail(stage string, err error) {
+	c.mu.Lock()
+	if c.reported {
+		c.mu.Unlock()
+		return
+	}
+	c.reported = true
+	c.mu.Unlock()
+
+	handshakes.failed(stage, err)
+}
+
+// Close attributes an upgrade abandoned by the upgrader to the stage it had
+// reached. A failed security handshake is left to SecureInbound, which has
+// the actual error, and closing a reported connection adds nothing.
+func (c *timedConn) Close() error {
+	c.mu.Lock()
+	stage := "multistream"
+	if !c.securedAt.IsZero() {
+		stage = "muxer"
+	} else if !c.securingAt.IsZero() {
+		stage = ""
+	}
+	c.mu.Unlock()
+
+	if stage != "" {
+		c.fail(stage, fmt.Errorf("closed during upgrade"))
+	}
+	return c.Conn.Close()
+}
+
+// handshakeStats accumulates inbound upgrade outcomes for HANDSHAKE_STATS.
+type handshakeStats struct {
+	mu       sync.Mutex
+	totals   []time.Duration
+	failures int
+}
+
+var handshakes = &handshakeStats{}
+
+func (s *handshakeStats) succeeded(total time.Duration) {
+	s.mu.Lock()
+	s.totals = append(s.totals, total)
+	s.mu.Unlock()
+}
+
+func (s *handshakeStats) failed(stage string, err error) {
+	s.mu.Lock()
+	s.failures++
+	s.mu.Unlock()
+	fmt.Printf("HANDSHAKE_FAIL: stage=%s err=%v\n", stage, err)
+}
+
+// summary formats count/min/avg/p95 of successful total_ms values. p95 is
+// the nearest-rank percentile.
+func (s *handshakeStats) summary() string {
+	s.mu.Lock()
+	totals := append([]time.Duration(nil), s.totals...)
+	failures := s.failures
+	s.mu.Unlock()
+
+	if len(totals) == 0 {
+		return fmt.Sprintf("HANDSHAKE_STATS: count=0 failures=%d", failures)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	var sum time.Duration
+	for _, d := range totals {
+		sum += d
+	}
+	p95 := totals[int(math.Ceil(0.95*float64(len(totals))))-1]
+	return fmt.Sprintf("HANDSHAKE_STATS: count=%d failures=%d min_ms=%.3f avg_ms=%.3f p95_ms=%.3f",
+		len(totals), failures, milliseconds(totals[0]),
+		milliseconds(sum/time.Duration(len(totals))), milliseconds(p95))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
 }
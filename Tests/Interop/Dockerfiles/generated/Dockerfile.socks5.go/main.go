@@ -0,0 +1,204 @@
+package main
+
+// A minimal SOCKS5 proxy (RFC 1928, CONNECT only) that logs every tunnel it
+// opens, for tests that need to see a dial pass through a proxy.
+//
+// PROXY_USER and PROXY_PASS, when set, require RFC 1929 username/password
+// authentication; otherwise only "no authentication" is offered. The proxy
+// prints
+//
+//	SOCKS5_LISTEN: addr=<host:port> auth=<none|userpass>
+//	SOCKS5_AUTH_FAILED: client=<addr> user=<name>
+//	SOCKS5_CONNECT: client=<addr> user=<name|-> target=<host:port> result=<ok|err> [err=<error>]
+//	SOCKS5_CLOSE: client=<addr> target=<host:port> up_bytes=<n> down_bytes=<n>
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const connectTimeout = 10 * time.Second
+
+// SOCKS5 reply codes (RFC 1928 section 6).
+const (
+	replySucceeded          = 0x00
+	replyGeneralFailure     = 0x01
+	replyHostUnreachable    = 0x04
+	replyCommandUnsupported = 0x07
+	replyAddressUnsupported = 0x08
+)
+
+func main() {
+	port := os.Getenv("LISTEN_PORT")
+	if port == "" {
+		port = "1080"
+	}
+	user, pass := os.Getenv("PROXY_USER"), os.Getenv("PROXY_PASS")
+
+	l, err := net.Listen("tcp", net.JoinHostPort("0.0.0.0", port))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	auth := "none"
+	if user != "" {
+		auth = "userpass"
+	}
+	fmt.Printf("SOCKS5_LISTEN: addr=%s auth=%s\n", l.Addr(), auth)
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			log.Fatalf("Accept failed: %v", err)
+		}
+		go serve(c, user, pass)
+	}
+}
+
+func serve(c net.Conn, user, pass string) {
+	defer c.Close()
+	client := c.RemoteAddr().String()
+	c.SetDeadline(time.Now().Add(connectTimeout))
+
+	// Greeting: version, method count, methods
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c, head); err != nil || head[0] != 0x05 {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return
+	}
+	want := byte(0x00)
+	if user != "" {
+		want = 0x02
+	}
+	offered := false
+	for _, m := range methods {
+		offered = offered || m == want
+	}
+	if !offered {
+		c.Write([]byte{0x05, 0xFF})
+		return
+	}
+	c.Write([]byte{0x05, want})
+
+	name := "-"
+	if user != "" {
+		var ok bool
+		if name, ok = authenticate(c, user, pass); !ok {
+			fmt.Printf("SOCKS5_AUTH_FAILED: client=%s user=%s\n", client, name)
+			return
+		}
+	}
+
+	target, code := readRequest(c)
+	if code != replySucceeded {
+		reply(c, code)
+		fmt.Printf("SOCKS5_CONNECT: client=%s user=%s target=%s result=err err=reply %d\n", client, name, target, code)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", target, connectTimeout)
+	if err != nil {
+		reply(c, replyHostUnreachable)
+		fmt.Printf("SOCKS5_CONNECT: client=%s user=%s target=%s result=err err=%v\n", client, name, target, err)
+		return
+	}
+	defer upstream.Close()
+	reply(c, replySucceeded)
+	fmt.Printf("SOCKS5_CONNECT: client=%s user=%s target=%s result=ok\n", client, name, target)
+	c.SetDeadline(time.Time{})
+
+	var up, down int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		up, _ = io.Copy(upstream, c)
+		upstream.(*net.TCPConn).CloseWrite()
+	}()
+	down, _ = io.Copy(c, upstream)
+	c.(*net.TCPConn).CloseWrite()
+	wg.Wait()
+	fmt.Printf("SOCKS5_CLOSE: client=%s target=%s up_bytes=%d down_bytes=%d\n", client, target, up, down)
+}
+
+// authenticate runs the RFC 1929 subnegotiation and returns the username
+// the client sent.
+func authenticate(c net.Conn, user, pass string) (string, bool) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c, head); err != nil || head[0] != 0x01 {
+		return "-", false
+	}
+	name := make([]byte, head[1])
+	if _, err := io.ReadFull(c, name); err != nil {
+		return "-", false
+	}
+	n := make([]byte, 1)
+	if _, err := io.ReadFull(c, n); err != nil {
+		return string(name), false
+	}
+	password := make([]byte, n[0])
+	if _, err := io.ReadFull(c, password); err != nil {
+		return string(name), false
+	}
+	if string(name) != user || string(password) != pass {
+		c.Write([]byte{0x01, 0x01})
+		return string(name), false
+	}
+	c.Write([]byte{0x01, 0x00})
+	return string(name), true
+}
+
+// readRequest reads a CONNECT request and returns its target, or a failure
+// reply code.
+func readRequest(c net.Conn) (string, byte) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(c, head); err != nil || head[0] != 0x05 {
+		return "-", replyGeneralFailure
+	}
+	if head[1] != 0x01 {
+		return "-", replyCommandUnsupported
+	}
+
+	var host string
+	switch head[3] {
+	case 0x01, 0x04:
+		ip := make(net.IP, net.IPv4len)
+		if head[3] == 0x04 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "-", replyGeneralFailure
+		}
+		host = ip.String()
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(c, n); err != nil {
+			return "-", replyGeneralFailure
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return "-", replyGeneralFailure
+		}
+		host = string(name)
+	default:
+		return "-", replyAddressUnsupported
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return "-", replyGeneralFailure
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), replySucceeded
+}
+
+// reply sends a response with an all-zero bound address.
+func reply(c net.Conn, code byte) {
+	c.Write([]byte{0x05, code, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+}
@@ -1,16 +1,34 @@
 package main
 
+// The go-libp2p WebSocket test node: WebSocket with Noise, Identify and Ping.
+// It listens on /ip4/0.0.0.0/tcp/$LISTEN_PORT/ws unless LISTEN_ADDRS says
+// otherwise, and every feature beyond that lives in a shared file:
+//
+//	wsdial.go     DIAL / PING with staged reporting, INSECURE_SKIP_VERIFY
+//	echo.go       /test/echo/1.0.0, ECHO_BUF_BYTES, ECHO_DONE
+//	perf.go       /perf/1.0.0 server and the PERF command
+//	httpfront.go  HTTP_FRONT, MAX_WS_CONNS, WS_UPGRADE logging
+//	listen.go     LISTEN_ADDRS, Bound:, CONN: / DISCONN:
+//	drain.go      graceful SIGTERM / SHUTDOWN, DRAIN_TIMEOUT_S
+//	proxy.go      PROXY_URL for outbound dials
+//	muxers.go     MUXERS, MUXER:, the STREAMS command
+//	wsbuffers.go  WS_READ_BUFFER_BYTES / WS_WRITE_BUFFER_BYTES
+//	frag.go       /test/frag/1.0.0 and FRAG_SEND
+//	observed.go   OBSERVED_SENT / OBSERVED_RECV, the OBSERVED command
+//	bandwidth.go  BANDWIDTH, BANDWIDTH_RESET, BW_INTERVAL_S
+
 import (
+	"bufio"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
-	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
-	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -24,59 +42,206 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid port: %v", err)
 	}
+	echoBuf, err := echoBufferSize()
+	if err != nil {
+		log.Fatalf("Invalid echo buffer: %v", err)
+	}
+	drain, err := newDrainer()
+	if err != nil {
+		log.Fatalf("Invalid drain timeout: %v", err)
+	}
+	// BW_INTERVAL_S prints a BW: summary periodically (see bandwidth.go)
+	bw, err := newBandwidth()
+	if err != nil {
+		log.Fatalf("Invalid bandwidth interval: %v", err)
+	}
+	// Outbound websocket dials go through PROXY_URL when set (see proxy.go)
+	if err := configureProxy(); err != nil {
+		log.Fatalf("Invalid proxy: %v", err)
+	}
+
+	// With HTTP_FRONT=1 or MAX_WS_CONNS an HTTP server owns the port and libp2p
+	// listens behind it on loopback (see httpfront.go)
+	front := frontEnabled()
+	listen, err := listenAddrs(port, "/ws")
+	if err != nil {
+		log.Fatalf("Invalid listen addresses: %v", err)
+	}
+	if front {
+		if os.Getenv("LISTEN_ADDRS") != "" {
+			log.Fatalf("LISTEN_ADDRS cannot be combined with the HTTP front")
+		}
+		listen = []string{frontListenAddr}
+	}
+
+	// MUXERS picks yamux and/or mplex and their order (see muxers.go)
+	muxerOptions, muxerIDs, err := loadMuxers()
+	if err != nil {
+		log.Fatalf("Invalid muxers: %v", err)
+	}
+
+	// WS_READ_BUFFER_BYTES / WS_WRITE_BUFFER_BYTES size the websocket
+	// dialer's buffers (see wsbuffers.go)
+	wsTransport, err := newBufferedWSTransport(dialTLSConfig())
+	if err != nil {
+		log.Fatalf("Invalid websocket buffers: %v", err)
+	}
 
 	// Create a new libp2p host with WebSocket transport and Noise security
-	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(
-			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", port),
-		),
+	opts := []libp2p.Option{
+		libp2p.ListenAddrStrings(listen...),
 		// Disable default transports, use only WebSocket
 		libp2p.NoTransports,
-		libp2p.Transport(websocket.New),
+		libp2p.Transport(wsTransport),
 		// Use Noise for security
 		libp2p.Security(noise.ID, noise.New),
-		// Use Yamux for muxing
-		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
 		libp2p.Ping(true), // Enable ping protocol
-	)
+		// Count every stream's bytes for BANDWIDTH
+		bw.option(),
+	}
+	opts = append(opts, muxerOptions...)
+	if front {
+		opts = append(opts, libp2p.AddrsFactory(func([]multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			return frontAddrs(port, "/ws")
+		}))
+	}
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		log.Fatalf("Failed to create host: %v", err)
 	}
 	defer h.Close()
+	logConnections(h)
+	logMuxers(h, muxerIDs)
+	// OBSERVED_SENT / OBSERVED_RECV per identify (see observed.go)
+	observed, err := logObserved(h)
+	if err != nil {
+		log.Fatalf("Failed to watch identify: %v", err)
+	}
+
+	if front {
+		backend, err := frontBackend(h)
+		if err != nil {
+			log.Fatalf("Failed to find the libp2p listener: %v", err)
+		}
+		if err := serveHTTPFront(port, backend, nil); err != nil {
+			log.Fatalf("Failed to start the HTTP front: %v", err)
+		}
+		log.Printf("HTTP front: :%d -> %s", port, backend)
+	}
 
 	// Get the host's peer ID
 	peerID := h.ID()
 	log.Printf("Local peer id: %s", peerID.String())
 	log.Printf("Transport: WebSocket")
 	log.Printf("Security: Noise")
-	log.Printf("Muxer: Yamux")
+	log.Printf("Muxer: %s", strings.Join(muxerIDs, ", "))
 
 	// Print listen addresses
 	for _, addr := range h.Addrs() {
 		fullAddr := addr.Encapsulate(multiaddr.StringCast("/p2p/" + peerID.String()))
 		fmt.Printf("Listen: %s\n", fullAddr.String())
 	}
+	printBoundAddrs(h)
 	fmt.Println("Ready to accept connections")
 
-	// Set up stream handler for custom protocols
-	h.SetStreamHandler("/test/echo/1.0.0", func(s network.Stream) {
+	// Set up stream handlers for custom protocols; shutdown waits for them
+	h.SetStreamHandler(echoProtocol, drain.handler(func(s network.Stream) {
 		log.Printf("Received stream from %s", s.Conn().RemotePeer())
-		defer s.Close()
+		// On shutdown the write side is closed early, ending the echo
+		stop := make(chan struct{})
+		defer close(stop)
+		drain.wrapUpOnShutdown(s, stop)
+		echoStream(s, echoBuf, drain.wrapUp)
+	}))
+	h.SetStreamHandler(perfProtocol, drain.handler(handlePerf))
+	h.SetStreamHandler(fragProtocol, drain.handler(handleFrag))
+
+	bw.run()
+	go handleCommands(h, drain, observed, bw)
 
-		// Echo back whatever is received
-		buf := make([]byte, 1024)
-		for {
-			n, err := s.Read(buf)
+	// Run until SIGTERM or SHUTDOWN, then drain and exit (see drain.go)
+	drain.run(h)
+}
+
+// handleCommands reads commands from stdin. DIAL, PING, PERF and FRAG_SEND
+// run in their own goroutines so a slow peer never blocks the command loop;
+// STREAMS lists a peer's open streams, OBSERVED the self addresses peers
+// reported, BANDWIDTH the byte counters (BANDWIDTH_RESET zeroes them), and
+// SHUTDOWN starts a graceful shutdown.
+func handleCommands(h host.Host, drain *drainer, observed *observedAddrs, bw *bandwidth) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "DIAL":
+			if len(fields) != 2 {
+				fmt.Println("DIAL_FAILED: err=usage: DIAL <multiaddr>")
+				continue
+			}
+			go dialEcho(h, fields[1])
+		case "PING":
+			if len(fields) < 2 || len(fields) > 3 {
+				fmt.Println("PING_FAILED: err=usage: PING <multiaddr> [count]")
+				continue
+			}
+			count := 3
+			if len(fields) == 3 {
+				n, err := strconv.Atoi(fields[2])
+				if err != nil || n < 1 {
+					fmt.Printf("PING_FAILED: err=invalid count %q\n", fields[2])
+					continue
+				}
+				count = n
+			}
+			go pingPeer(h, fields[1], count)
+		case "PERF":
+			if len(fields) != 4 {
+				fmt.Println("PERF_FAILED: err=usage: PERF <multiaddr> <upload_bytes> <download_bytes>")
+				continue
+			}
+			up, down, err := parsePerfArgs(fields[2], fields[3])
+			if err != nil {
+				fmt.Printf("PERF_FAILED: err=%v\n", err)
+				continue
+			}
+			go perfClient(h, fields[1], up, down)
+		case "FRAG_SEND":
+			if len(fields) != 4 {
+				fmt.Println("FRAG_SEND_FAILED: err=usage: FRAG_SEND <peerID> <total_bytes> <write_size>")
+				continue
+			}
+			total, writeSize, err := parseFragArgs(fields[2], fields[3])
 			if err != nil {
-				return
+				fmt.Printf("FRAG_SEND_FAILED: peer=%s err=%v\n", fields[1], err)
+				continue
+			}
+			go fragSend(h, fields[1], total, writeSize)
+		case "STREAMS":
+			if len(fields) != 2 {
+				fmt.Println("STREAMS_FAILED: err=usage: STREAMS <peerID>")
+				continue
+			}
+			listStreams(h, fields[1])
+		case "OBSERVED":
+			observed.printObserved(h)
+		case "BANDWIDTH":
+			if len(fields) > 2 {
+				fmt.Println("BANDWIDTH_FAILED: err=usage: BANDWIDTH [peerID]")
+				continue
 			}
-			if n > 0 {
-				log.Printf("Echo: %d bytes", n)
-				s.Write(buf[:n])
+			peerID := ""
+			if len(fields) == 2 {
+				peerID = fields[1]
 			}
+			bw.print(peerID)
+		case "BANDWIDTH_RESET":
+			bw.reset()
+		case "SHUTDOWN":
+			drain.request("command")
 		}
-	})
-
-	// Keep the process running
-	select {}
+	}
 }
@@ -0,0 +1,857 @@
+//go:build certtest
+
+// Certificate rotation checks for the WSS node. The builder stage of
+// Dockerfile.wss.go runs them, so a failing check fails the image build:
+//
+//	go test -tags certtest .
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCertPair writes a fresh self-signed localhost certificate and its key
+// to dir and returns the file paths and the leaf's DER bytes.
+func writeCertPair(t *testing.T, dir, name string, notAfter time.Time) (certFile, keyFile string, der []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+"-cert.pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, der
+}
+
+func TestDescribeReportsExpiryAndFingerprint(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	certFile, keyFile, der := writeCertPair(t, t.TempDir(), "a", notAfter)
+
+	loaded, err := (&certHolder{}).load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	want := "notAfter=2030-01-02T03:04:05Z sha256=" + hex.EncodeToString(sum[:])
+	if got := loaded.describe(); got != want {
+		t.Errorf("describe() = %q, want %q", got, want)
+	}
+}
+
+func TestFailedLoadKeepsServingOldCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, der := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := certs.load(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Fatal("loading a missing certificate succeeded")
+	}
+	// A key that does not match the certificate is rejected as well.
+	_, otherKey, _ := writeCertPair(t, dir, "b", time.Now().Add(time.Hour))
+	if _, err := certs.load(certFile, otherKey); err == nil {
+		t.Fatal("loading a mismatched key succeeded")
+	}
+
+	served, err := certs.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.Certificate[0], der) {
+		t.Error("a failed load replaced the served certificate")
+	}
+}
+
+func TestReloadRereadsCurrentFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite the same paths, as a certificate renewal would.
+	newCert, newKey, der := writeCertPair(t, dir, "b", time.Now().Add(2*time.Hour))
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := certs.reload(); err != nil {
+		t.Fatal(err)
+	}
+	served, _ := certs.getCertificate(nil)
+	if !bytes.Equal(served.Certificate[0], der) {
+		t.Error("reload did not pick up the rewritten files")
+	}
+}
+
+func TestRotationKeepsEstablishedConnections(t *testing.T) {
+	dir := t.TempDir()
+	certA, keyA, derA := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certB, keyB, derB := writeCertPair(t, dir, "b", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certA, keyA); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: certs.getCertificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+
+	dial := func() (*tls.Conn, []byte) {
+		t.Helper()
+		c, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c, c.ConnectionState().PeerCertificates[0].Raw
+	}
+	echo := func(c *tls.Conn, msg string) {
+		t.Helper()
+		if _, err := c.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != msg {
+			t.Fatalf("echo = %q, want %q", buf, msg)
+		}
+	}
+
+	old, seen := dial()
+	defer old.Close()
+	if !bytes.Equal(seen, derA) {
+		t.Fatal("first connection did not see the initial certificate")
+	}
+	echo(old, "before")
+
+	if _, err := certs.load(certB, keyB); err != nil {
+		t.Fatal(err)
+	}
+
+	echo(old, "after")
+	fresh, seen := dial()
+	defer fresh.Close()
+	if !bytes.Equal(seen, derB) {
+		t.Error("new connection did not see the rotated certificate")
+	}
+	echo(fresh, "fresh")
+}
+
+func TestEnsureDomainCertKeepsCoveringCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, der := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	loaded, err := certs.load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := ensureDomainCert(certs, loaded, "localhost", "", filepath.Join(dir, "domain-cert.pem"), filepath.Join(dir, "domain-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kept.leaf.Raw, der) || kept.certFile != certFile {
+		t.Error("a certificate that covers the domain was replaced")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "domain-cert.pem")); !os.IsNotExist(err) {
+		t.Error("a domain certificate was written although none was needed")
+	}
+}
+
+func TestEnsureDomainCertGeneratesForUncoveredDomain(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	loaded, err := certs.load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domainCert := filepath.Join(dir, "domain-cert.pem")
+	generated, err := ensureDomainCert(certs, loaded, "wss-node.test", "", domainCert, filepath.Join(dir, "domain-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"wss-node.test", "localhost"} {
+		if err := generated.leaf.VerifyHostname(name); err != nil {
+			t.Errorf("generated certificate does not cover %s: %v", name, err)
+		}
+	}
+	served, _ := certs.getCertificate(nil)
+	if !bytes.Equal(served.Certificate[0], generated.leaf.Raw) {
+		t.Error("the generated certificate is not served")
+	}
+	// SIGHUP reloads the generated files, not the original pair
+	if reloaded, err := certs.reload(); err != nil || reloaded.certFile != domainCert {
+		t.Errorf("reload() = %v, %v; want the generated files", reloaded, err)
+	}
+}
+
+func TestUncoveredSNIStillCompletesHandshake(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	loaded, err := certs.load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(loaded.leaf)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate:     certs.getCertificate,
+		GetConfigForClient: certs.configForClient,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				c.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	// The server only logs the mismatch; a client that trusts the
+	// certificate still refuses it for the name...
+	if _, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: "other.test", RootCAs: roots}); err == nil {
+		t.Error("a verifying client accepted a certificate that does not cover its SNI")
+	}
+	// ...and one that skips verification completes the handshake.
+	c, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: "other.test", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("handshake with an uncovered SNI failed: %v", err)
+	}
+	c.Close()
+}
+
+// issueClientCert creates a CA and a client certificate it signed, returning
+// the CA pool and the client's tls.Certificate.
+func issueClientCert(t *testing.T, commonName string) (*x509.CertPool, tls.Certificate) {
+	t.Helper()
+	ca, clientCert := issueClientCertByCA(t, commonName)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, clientCert
+}
+
+// issueClientCertByCA is issueClientCert returning the CA certificate itself.
+func issueClientCertByCA(t *testing.T, commonName string) (*x509.Certificate, tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshakeWithClientAuth runs one handshake against a listener configured
+// for mode and returns the client's error.
+func handshakeWithClientAuth(t *testing.T, mode string, cas *x509.CertPool, clientCerts []tls.Certificate) error {
+	t.Helper()
+	certFile, keyFile, _ := writeCertPair(t, t.TempDir(), "server", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{GetCertificate: certs.getCertificate}
+	(&clientAuthConfig{mode: mode, cas: cas}).apply(config)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if c.(*tls.Conn).Handshake() == nil {
+			io.Copy(c, c)
+		}
+	}()
+
+	c, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: clientCerts})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	// TLS 1.3 reports a rejected client certificate on the first read
+	if _, err := c.Write([]byte("x")); err != nil {
+		return err
+	}
+	_, err = io.ReadFull(c, make([]byte, 1))
+	return err
+}
+
+func TestLoadClientAuthRejectsUnknownMode(t *testing.T) {
+	if _, err := loadClientAuth("sometimes", "", nil); err == nil {
+		t.Error("an unknown CLIENT_AUTH mode was accepted")
+	}
+	config, err := loadClientAuth("", "", nil)
+	if err != nil || config.mode != "none" {
+		t.Errorf("loadClientAuth(\"\") = %v, %v; want mode none", config, err)
+	}
+}
+
+func TestRequireAcceptsTrustedClientCertificate(t *testing.T) {
+	cas, clientCert := issueClientCert(t, "swift-client")
+	if err := handshakeWithClientAuth(t, "require", cas, []tls.Certificate{clientCert}); err != nil {
+		t.Errorf("trusted client certificate was rejected: %v", err)
+	}
+}
+
+func TestRequireRejectsMissingOrUntrustedCertificate(t *testing.T) {
+	cas, _ := issueClientCert(t, "swift-client")
+	_, untrusted := issueClientCert(t, "stranger")
+
+	for name, certs := range map[string][]tls.Certificate{"missing": nil, "untrusted": {untrusted}} {
+		err := handshakeWithClientAuth(t, "require", cas, certs)
+		if err == nil || !strings.Contains(err.Error(), "bad certificate") {
+			t.Errorf("%s client certificate: err = %v, want a bad certificate alert", name, err)
+		}
+	}
+}
+
+func TestRequestAcceptsUnverifiedCertificate(t *testing.T) {
+	cas, _ := issueClientCert(t, "swift-client")
+	_, untrusted := issueClientCert(t, "stranger")
+	if err := handshakeWithClientAuth(t, "request", cas, []tls.Certificate{untrusted}); err != nil {
+		t.Errorf("request mode rejected an unverified certificate: %v", err)
+	}
+	if err := handshakeWithClientAuth(t, "request", cas, nil); err != nil {
+		t.Errorf("request mode rejected a client without a certificate: %v", err)
+	}
+}
+
+// policyServer starts a listener that applies policy and records handshakes
+// into the returned stats.
+func policyServer(t *testing.T, policy *tlsPolicy) (addr string, stats *handshakeStats) {
+	t.Helper()
+	certFile, keyFile, _ := writeCertPair(t, t.TempDir(), "server", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{GetCertificate: certs.getCertificate}
+	policy.apply(config)
+	stats = &handshakeStats{}
+	observeHandshakes(config, policy, stats)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				if c.(*tls.Conn).Handshake() == nil {
+					io.Copy(c, c)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), stats
+}
+
+// dialPolicyServer completes one round trip so a TLS 1.3 rejection surfaces.
+func dialPolicyServer(addr string, config *tls.Config) (tls.ConnectionState, error) {
+	c, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("x")); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if _, err := io.ReadFull(c, make([]byte, 1)); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return c.ConnectionState(), nil
+}
+
+func TestLoadTLSPolicyRejectsUnknownValues(t *testing.T) {
+	for _, args := range [][3]string{
+		{"1.4", "", ""},
+		{"", "tls12", ""},
+		{"1.3", "1.2", ""},
+		{"", "", "TLS_AES_128_GCM_SHA256,TLS_NOPE"},
+	} {
+		if _, err := loadTLSPolicy(args[0], args[1], args[2]); err == nil {
+			t.Errorf("loadTLSPolicy(%q, %q, %q) was accepted", args[0], args[1], args[2])
+		}
+	}
+	policy, err := loadTLSPolicy("", "", "")
+	if err != nil || policy.isSet() {
+		t.Errorf("loadTLSPolicy with no knobs = %v, %v; want an unset policy", policy, err)
+	}
+}
+
+func TestTLS12OnlyPolicyDowngradesAndCountsResumption(t *testing.T) {
+	policy, err := loadTLSPolicy("1.2", "1.2", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, stats := policyServer(t, policy)
+
+	client := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+	for i, wantResumed := range []bool{false, true} {
+		state, err := dialPolicyServer(addr, client)
+		if err != nil {
+			t.Fatalf("handshake %d failed: %v", i, err)
+		}
+		if state.Version != tls.VersionTLS12 || state.CipherSuite != tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 {
+			t.Errorf("handshake %d negotiated %s %s", i, versionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+		}
+		if state.DidResume != wantResumed {
+			t.Errorf("handshake %d resumed = %t, want %t", i, state.DidResume, wantResumed)
+		}
+	}
+
+	want := []string{
+		"TLS_STATS: version=1.2 cipher=TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 count=2",
+		"TLS_STATS_END: handshakes=2",
+	}
+	if got := stats.summary(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestTLS13SuiteOutsidePolicyIsRejected(t *testing.T) {
+	// crypto/tls picks the TLS 1.3 suite itself; learn which one it picks here
+	open, _ := policyServer(t, &tlsPolicy{})
+	state, err := dialPolicyServer(open, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	negotiated := tls.CipherSuiteName(state.CipherSuite)
+	other := "TLS_CHACHA20_POLY1305_SHA256"
+	if negotiated == other {
+		other = "TLS_AES_128_GCM_SHA256"
+	}
+
+	allowed, err := loadTLSPolicy("1.3", "", negotiated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := policyServer(t, allowed)
+	if _, err := dialPolicyServer(addr, &tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("%s was rejected although it is listed: %v", negotiated, err)
+	}
+
+	restricted, err := loadTLSPolicy("1.3", "", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, stats := policyServer(t, restricted)
+	if _, err := dialPolicyServer(addr, &tls.Config{InsecureSkipVerify: true}); err == nil {
+		t.Errorf("%s was accepted although only %s is listed", negotiated, other)
+	}
+	if got := stats.summary(); len(got) != 1 || got[0] != "TLS_STATS_END: handshakes=0" {
+		t.Errorf("a rejected handshake was counted: %q", got)
+	}
+}
+
+func TestCheckCertAlgoRejectsUnknownAlgorithm(t *testing.T) {
+	for _, algo := range []string{"rsa4096", "ECDSA-P256", "ecdsa"} {
+		if err := checkCertAlgo(algo); err == nil {
+			t.Errorf("checkCertAlgo(%q) was accepted", algo)
+		}
+	}
+}
+
+func TestGeneratedCertificateMatchesCertAlgoAndServes(t *testing.T) {
+	for _, algo := range certAlgorithms {
+		t.Run(algo, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+			certs := &certHolder{}
+			generated, err := writeSelfSigned(certs, algo, []string{"localhost"}, certFile, keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := certAlgorithm(generated.leaf); got != algo {
+				t.Errorf("certAlgorithm = %q, want %q", got, algo)
+			}
+			info := generated.info()
+			if !strings.HasPrefix(info, "algo="+algo+" ") || !strings.HasSuffix(info, " file="+certFile+" source=generated") {
+				t.Errorf("info() = %q", info)
+			}
+
+			// Loading the same files reports the same line, bar the source
+			loaded, err := (&certHolder{}).load(certFile, keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.TrimSuffix(info, "generated") + "file"; loaded.info() != want {
+				t.Errorf("info() of the loaded files = %q, want %q", loaded.info(), want)
+			}
+
+			// A client that trusts the certificate completes a handshake
+			roots := x509.NewCertPool()
+			roots.AddCert(generated.leaf)
+			ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: certs.getCertificate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go func() {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+				c.(*tls.Conn).Handshake()
+			}()
+			c, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: "localhost", RootCAs: roots})
+			if err != nil {
+				t.Fatalf("handshake with a %s certificate failed: %v", algo, err)
+			}
+			c.Close()
+		})
+	}
+}
+
+func TestEnsureDomainCertUsesCertAlgo(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	loaded, err := certs.load(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ensureDomainCert(certs, loaded, "wss-node.test", "ed25519",
+		filepath.Join(dir, "domain-cert.pem"), filepath.Join(dir, "domain-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := certAlgorithm(generated.leaf); got != "ed25519" {
+		t.Errorf("domain certificate algorithm = %q, want ed25519", got)
+	}
+}
+
+func TestInlinePairIsWrittenAndReportedAsEnv(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, der := writeCertPair(t, dir, "src", time.Now().Add(time.Hour))
+	certPEM, _ := os.ReadFile(certFile)
+	keyPEM, _ := os.ReadFile(keyFile)
+	t.Setenv("CERT_PEM", strings.ReplaceAll(string(certPEM), "\n", `\n`))
+
+	inlineCert, inlineKey := filepath.Join(dir, "inline-cert.pem"), filepath.Join(dir, "inline-key.pem")
+	certs := &certHolder{}
+	loaded, err := writeInline(certs, inlinePEM("CERT_PEM"), keyPEM, inlineCert, inlineKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.leaf.Raw, der) {
+		t.Error("the inline pair does not serve the given certificate")
+	}
+	if info := loaded.info(); !strings.HasSuffix(info, " file="+inlineCert+" source=env") {
+		t.Errorf("info() = %q", info)
+	}
+	if written, _ := os.ReadFile(inlineCert); !bytes.Equal(written, certPEM) {
+		t.Error("CERT_PEM with literal \\n was not written as PEM")
+	}
+}
+
+func TestInlinePairTellsMalformedFromMismatched(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertPair(t, dir, "a", time.Now().Add(time.Hour))
+	_, otherKeyFile, _ := writeCertPair(t, dir, "b", time.Now().Add(time.Hour))
+	certPEM, _ := os.ReadFile(certFile)
+	keyPEM, _ := os.ReadFile(keyFile)
+	otherKeyPEM, _ := os.ReadFile(otherKeyFile)
+	garbled := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not DER")})
+
+	for name, tc := range map[string]struct {
+		cert, key []byte
+		want      string
+	}{
+		"cert only":       {certPEM, nil, "must be set together"},
+		"key as cert":     {keyPEM, keyPEM, "malformed CERT_PEM: no CERTIFICATE block"},
+		"garbled cert":    {garbled, keyPEM, "malformed CERT_PEM: "},
+		"cert as key":     {certPEM, certPEM, "malformed KEY_PEM: no PRIVATE KEY block"},
+		"mismatched pair": {certPEM, otherKeyPEM, "CERT_PEM and KEY_PEM do not match"},
+	} {
+		err := checkInlinePair(tc.cert, tc.key)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+	if err := checkInlinePair(certPEM, keyPEM); err != nil {
+		t.Errorf("matching pair: %v", err)
+	}
+}
+
+func TestCAPEMPopulatesClientCAs(t *testing.T) {
+	ca, clientCert := issueClientCertByCA(t, "swift-client")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+	config, err := loadClientAuth("require", "/does/not/exist", caPEM)
+	if err != nil {
+		t.Fatalf("CA_PEM did not take precedence over CLIENT_CA_FILE: %v", err)
+	}
+	if err := handshakeWithClientAuth(t, config.mode, config.cas, []tls.Certificate{clientCert}); err != nil {
+		t.Errorf("client certificate issued by the CA_PEM authority was rejected: %v", err)
+	}
+
+	garbled := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not DER")})
+	for _, data := range [][]byte{[]byte("junk"), append(caPEM, garbled...)} {
+		if _, err := loadClientAuth("require", "", data); err == nil || !strings.Contains(err.Error(), "malformed CA_PEM") {
+			t.Errorf("CA_PEM %q: err = %v, want malformed CA_PEM", data, err)
+		}
+	}
+}
+
+func TestReportJoinsTheClientHello(t *testing.T) {
+	f := &tlsFailures{stats: &handshakeStats{}, next: io.Discard}
+	f.rememberHello("10.0.0.1:5000", &tls.ClientHelloInfo{
+		ServerName:        "localhost",
+		SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+		CipherSuites:      []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		SupportedProtos:   []string{"http/1.1"},
+	})
+
+	got := f.report("10.0.0.1:5000", "remote error: tls: bad certificate")
+	want := tlsFailure{
+		Remote:       "10.0.0.1:5000",
+		Category:     "cert",
+		Error:        "remote error: tls: bad certificate",
+		Hello:        true,
+		ServerName:   "localhost",
+		Versions:     []string{"1.3", "1.2"},
+		CipherSuites: []string{"TLS_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		ALPN:         []string{"http/1.1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %+v, want %+v", got, want)
+	}
+
+	// The hello is used once; a second failure from the address has none
+	if again := f.report("10.0.0.1:5000", "EOF"); again.Hello || again.Category != "tcp" {
+		t.Errorf("second report = %+v, want hello=false category=tcp", again)
+	}
+}
+
+func TestHandshakeErrorLinesAreReportedAndPassedOn(t *testing.T) {
+	var passed bytes.Buffer
+	stats := &handshakeStats{}
+	f := &tlsFailures{stats: stats, next: &passed}
+	f.rememberHello("[::1]:5001", &tls.ClientHelloInfo{SupportedVersions: []uint16{tls.VersionTLS12}})
+
+	logger := log.New(f, "", log.LstdFlags)
+	logger.Printf("http: TLS handshake error from [::1]:5001: tls: client offered only unsupported versions: [303]")
+	logger.Printf("unrelated line")
+
+	if !strings.Contains(passed.String(), "unrelated line") || !strings.Contains(passed.String(), "TLS handshake error") {
+		t.Errorf("log lines were not passed on: %q", passed.String())
+	}
+	want := []string{
+		"TLS_FAIL_STATS: category=version count=1",
+		"TLS_STATS_END: handshakes=0",
+	}
+	if got := stats.summary(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestFailedHandshakesThroughNetHTTPAreCategorized(t *testing.T) {
+	certFile, keyFile, _ := writeCertPair(t, t.TempDir(), "server", time.Now().Add(time.Hour))
+	certs := &certHolder{}
+	if _, err := certs.load(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := loadTLSPolicy("1.3", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{GetCertificate: certs.getCertificate, GetConfigForClient: certs.configForClient}
+	policy.apply(config)
+	stats := &handshakeStats{}
+	observeHandshakes(config, policy, stats)
+	certs.base = config
+	certs.failures = &tlsFailures{stats: stats, next: io.Discard}
+
+	// Served like the websocket listener: net/http reports failed handshakes
+	// to its error log
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler(), TLSConfig: config, ErrorLog: log.New(certs.failures, "", 0)}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+	addr := ln.Addr().String()
+
+	// The client does not trust the self-signed certificate
+	if c, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", NextProtos: []string{"http/1.1"}}); err == nil {
+		c.Close()
+		t.Error("an untrusted certificate was accepted")
+	}
+	// The client stops at TLS 1.2; the node requires 1.3
+	if c, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}); err == nil {
+		c.Close()
+		t.Error("a TLS 1.2 client got through a 1.3-only node")
+	}
+	// The connection closes before any ClientHello
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// The untrusted client's handshake completed on the node's side before
+	// the client rejected the certificate
+	want := strings.Join([]string{
+		"TLS_STATS: version=1.3 cipher=TLS_AES_128_GCM_SHA256 count=1",
+		"TLS_FAIL_STATS: category=cert count=1",
+		"TLS_FAIL_STATS: category=tcp count=1",
+		"TLS_FAIL_STATS: category=version count=1",
+		"TLS_STATS_END: handshakes=1",
+	}, "\n")
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if got = strings.Join(stats.summary(), "\n"); got == want {
+			return
+		}
+	}
+	t.Errorf("summary = %q, want %q", got, want)
+}
+
+func TestFailureCategory(t *testing.T) {
+	for _, tc := range []struct {
+		reason       string
+		hello        bool
+		verifyFailed bool
+		want         string
+	}{
+		{"EOF", false, false, "tcp"},
+		{"read tcp 172.17.0.2:4001->172.17.0.1:50000: read: connection reset by peer", false, false, "tcp"},
+		{"EOF", true, false, "aborted"},
+		{"tls: first record does not look like a TLS handshake", false, false, "not_tls"},
+		{"tls: client offered only unsupported versions: [303 302]", true, false, "version"},
+		{"tls: no cipher suite supported by both client and server", true, false, "cipher"},
+		{"remote error: tls: handshake failure", true, false, "cipher"},
+		{"tls: client requested unsupported application protocols ([h3])", true, false, "alpn"},
+		{"remote error: tls: unknown certificate authority", true, false, "cert"},
+		{"x509: certificate signed by unknown authority", true, true, "client_cert"},
+		{"cipher suite TLS_AES_128_GCM_SHA256 is not in TLS_CIPHER_SUITES", true, true, "cipher"},
+		{"tls: unexpected message", true, false, "other"},
+	} {
+		if got := failureCategory(tc.reason, tc.hello, tc.verifyFailed); got != tc.want {
+			t.Errorf("failureCategory(%q, hello=%t, verifyFailed=%t) = %s, want %s", tc.reason, tc.hello, tc.verifyFailed, got, tc.want)
+		}
+	}
+}
@@ -1,18 +1,118 @@
 package main
 
+// The go-libp2p WSS test node: TLS + WebSocket with Noise, Identify and
+// Ping. DIAL / PING (wsdial.go), the echo (echo.go), PERF=1 (perf.go),
+// HTTP_FRONT (httpfront.go), LISTEN_ADDRS (listen.go, default
+// /ip4/0.0.0.0/tcp/$LISTEN_PORT/wss), MUXERS (muxers.go) and BANDWIDTH
+// (bandwidth.go) work as on the ws node. With HTTP_FRONT=1 the front
+// terminates TLS with this node's certificate and policy, libp2p behind it
+// listens on plain /ws and the advertised addresses stay /tls/ws on the
+// public port.
+//
+// The certificate is served through tls.Config.GetCertificate from an
+// atomically swapped holder, so it can be rotated without a restart: SIGHUP
+// re-reads the current certificate and key files, and the stdin command
+// "ROTATE_CERT <certfile> <keyfile>" switches to other files. A swap logs
+// "CERT_ROTATED: notAfter=<RFC3339> sha256=<hex DER fingerprint>" (the
+// startup certificate is logged the same way as CERT_LOADED:); a file that
+// fails to load logs "CERT_ROTATE_FAILED: source=sighup|command err=..." and
+// the old certificate stays in use. Established connections keep the session
+// they were handshaken with. /cert2.pem and /key2.pem hold a second
+// self-signed localhost pair to rotate to.
+//
+// For pinning, the fingerprint is printed as "CERT_SHA256: <hex>" at startup
+// and after every rotation, followed by one "PinnedAddr: <multiaddr>" per
+// listen address with /certhash/<sha2-256 multihash, base64url> appended.
+// Each handshake is bound to the certificate current when its ClientHello
+// arrived and logs "CERT_PRESENTED: sha256=<hex> current=<bool>";
+// current=false means a rotation landed mid-handshake.
+//
+// CERT_ALGO=rsa2048|ecdsa-p256|ecdsa-p384|ed25519 without CERT_FILE
+// generates a self-signed localhost certificate of that type at startup
+// (/generated-cert.pem, /generated-key.pem); it is also the type generated
+// for DOMAIN (default ecdsa-p256). CERT_PEM and KEY_PEM carry the pair as PEM
+// text (a literal \n stands for a newline) and take precedence over
+// CERT_FILE, KEY_FILE and CERT_ALGO; startup fails with "malformed
+// CERT_PEM|KEY_PEM: ..." or "CERT_PEM and KEY_PEM do not match: ...", and a
+// good pair is written to /inline-cert.pem and /inline-key.pem so SIGHUP
+// rereads it. The served certificate is reported as
+//
+//	CERT_INFO: algo=<CERT_ALGO spelling> sha256=<hex> notBefore=<RFC3339> notAfter=<RFC3339> file=<path> source=generated|file|env
+//
+// DOMAIN=<name> serves that name: a certificate that does not cover it is
+// replaced by a self-signed one for <name> and localhost (/domain-cert.pem,
+// "CERT_DOMAIN: domain=<name> source=generated|<file>"), and
+// /dns4/<name>/tcp/<port>/wss is advertised next to the IP addresses. Every
+// handshake logs "TLS_SNI: <name>" (or "(none)"); a name the certificate does
+// not cover also logs "TLS_SNI_MISMATCH: name=<name> err=<error>", leaving
+// the refusal to the client.
+//
+// CLIENT_AUTH=require|request|none (default none) with CLIENT_CA_FILE, or
+// CA_PEM holding the CAs inline, turns on client certificates. Every
+// handshake logs "TLS_CLIENT_CERT: present=<bool> subject=<dn>
+// verified=<bool>"; with require a missing or unverified certificate fails
+// with a bad_certificate alert, logged as "TLS_CLIENT_AUTH_FAILED:
+// alert=bad_certificate err=...". /client-ca.pem signs /client-cert.pem and
+// /client-key.pem (CN=swift-client); /client-untrusted-cert.pem and
+// /client-untrusted-key.pem are self-signed (CN=stranger).
+//
+// TLS_MIN_VERSION / TLS_MAX_VERSION (1.0-1.3) and TLS_CIPHER_SUITES
+// (comma-separated IANA names) restrict the handshake, printed as
+// "TLS_POLICY: min= max= ciphers=". crypto/tls cannot restrict TLS 1.3
+// suites, so a TLS 1.3 handshake on an unlisted one is failed afterwards and
+// logged as "TLS_CIPHER_REJECTED: version=1.3 err=...". Every completed
+// handshake logs "TLS_STATE: version=<v> cipher=<name> alpn=<proto>
+// resumed=<bool>"; the stdin command TLS_STATS prints
+//
+//	TLS_STATS: version=<v> cipher=<name> count=<n>
+//	TLS_FAIL_STATS: category=<c> count=<n>
+//	TLS_STATS_END: handshakes=<n>
+//
+// A failed handshake logs "TLS_FAIL: <json>" with remote, category, error,
+// hello (whether a ClientHello was read) and what the client offered: sni,
+// versions, cipher_suites, alpn. Categories: tcp (dropped before a
+// ClientHello), not_tls, version, cipher, alpn, cert (the client rejected
+// this node's certificate), client_cert, aborted (dropped mid-handshake),
+// other.
+
 import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
-	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-multihash"
 )
 
 func main() {
@@ -25,10 +125,28 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid port: %v", err)
 	}
+	echoBuf, err := echoBufferSize()
+	if err != nil {
+		log.Fatalf("Invalid echo buffer: %v", err)
+	}
+	// BW_INTERVAL_S prints a BW: summary periodically (see bandwidth.go)
+	bw, err := newBandwidth()
+	if err != nil {
+		log.Fatalf("Invalid bandwidth interval: %v", err)
+	}
 
-	// Get certificate files
+	// Get certificate files. CERT_PEM and KEY_PEM carry the pair inline and
+	// win over them; CERT_ALGO without either generates a self-signed
+	// certificate of that type instead.
 	certFile := os.Getenv("CERT_FILE")
 	keyFile := os.Getenv("KEY_FILE")
+	certPEM, keyPEM := inlinePEM("CERT_PEM"), inlinePEM("KEY_PEM")
+	inline := len(certPEM) > 0 || len(keyPEM) > 0
+	certAlgo := os.Getenv("CERT_ALGO")
+	if err := checkCertAlgo(certAlgo); err != nil {
+		log.Fatalf("Invalid certificate algorithm: %v", err)
+	}
+	generate := !inline && certFile == "" && certAlgo != ""
 	if certFile == "" {
 		certFile = "/cert.pem"
 	}
@@ -36,68 +154,1154 @@ func main() {
 		keyFile = "/key.pem"
 	}
 
-	// Load TLS certificate
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	// Optional DNS name to serve and advertise as /dns4/<domain>/tcp/<port>/wss
+	domain := os.Getenv("DOMAIN")
+
+	// Optional client certificate authentication (mutual TLS)
+	clientAuth, err := loadClientAuth(os.Getenv("CLIENT_AUTH"), os.Getenv("CLIENT_CA_FILE"), inlinePEM("CA_PEM"))
+	if err != nil {
+		log.Fatalf("Invalid client auth configuration: %v", err)
+	}
+
+	// Optional restrictions on the negotiated TLS version and cipher suite
+	policy, err := loadTLSPolicy(os.Getenv("TLS_MIN_VERSION"), os.Getenv("TLS_MAX_VERSION"), os.Getenv("TLS_CIPHER_SUITES"))
+	if err != nil {
+		log.Fatalf("Invalid TLS policy: %v", err)
+	}
+
+	// Load TLS certificate. The listener asks the holder for it on every
+	// handshake, so a rotation only affects connections accepted afterwards.
+	certs := &certHolder{}
+	var loaded *loadedCert
+	switch {
+	case inline:
+		loaded, err = writeInline(certs, certPEM, keyPEM, inlineCertFile, inlineKeyFile)
+	case generate:
+		loaded, err = writeSelfSigned(certs, certAlgo, []string{"localhost"}, generatedCertFile, generatedKeyFile)
+	default:
+		loaded, err = certs.load(certFile, keyFile)
+	}
 	if err != nil {
 		log.Fatalf("Failed to load certificate: %v", err)
 	}
+	if domain != "" {
+		loaded, err = ensureDomainCert(certs, loaded, domain, certAlgo, domainCertFile, domainKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to prepare certificate for %s: %v", domain, err)
+		}
+	}
+	fmt.Printf("CERT_LOADED: %s\n", loaded.describe())
+	fmt.Printf("CERT_INFO: %s\n", loaded.info())
+	fmt.Printf("CERT_SHA256: %s\n", loaded.fingerprint())
 
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
+		GetCertificate:     certs.getCertificate,
+		GetConfigForClient: certs.configForClient,
+	}
+	clientAuth.apply(tlsConfig)
+	policy.apply(tlsConfig)
+	if policy.isSet() {
+		fmt.Printf("TLS_POLICY: %s\n", policy.describe())
+	}
+	stats := &handshakeStats{}
+	observeHandshakes(tlsConfig, policy, stats)
+	certs.base = tlsConfig
+
+	// Failed handshakes surface only as http.Server log lines; route the
+	// standard logger through the TLS_FAIL reporter
+	certs.failures = &tlsFailures{stats: stats, next: os.Stderr}
+	log.SetOutput(certs.failures)
+
+	// With HTTP_FRONT=1 or MAX_WS_CONNS an HTTP server owns the port and
+	// terminates TLS; libp2p listens behind it on plain loopback /ws (see
+	// httpfront.go)
+	front := frontEnabled()
+	listen, err := listenAddrs(port, "/wss")
+	if err != nil {
+		log.Fatalf("Invalid listen addresses: %v", err)
+	}
+	if front {
+		if os.Getenv("LISTEN_ADDRS") != "" {
+			log.Fatalf("LISTEN_ADDRS cannot be combined with the HTTP front")
+		}
+		listen = []string{frontListenAddr}
+	}
+
+	// MUXERS picks yamux and/or mplex and their order (see muxers.go)
+	muxerOptions, muxerIDs, err := loadMuxers()
+	if err != nil {
+		log.Fatalf("Invalid muxers: %v", err)
 	}
 
 	// Create a new libp2p host with WSS transport and Noise security
-	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(
-			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/wss", port),
-		),
+	opts := []libp2p.Option{
+		libp2p.ListenAddrStrings(listen...),
 		// Disable default transports, use only WebSocket with TLS
 		libp2p.NoTransports,
-		libp2p.Transport(websocket.New, websocket.WithTLSConfig(tlsConfig)),
+		libp2p.Transport(websocket.New,
+			websocket.WithTLSConfig(tlsConfig),
+			websocket.WithTLSClientConfig(dialTLSConfig()),
+		),
 		// Use Noise for security
 		libp2p.Security(noise.ID, noise.New),
-		// Use Yamux for muxing
-		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
 		libp2p.Ping(true), // Enable ping protocol
-	)
+		// Count every stream's bytes for BANDWIDTH
+		bw.option(),
+	}
+	opts = append(opts, muxerOptions...)
+	if domain != "" || front {
+		opts = append(opts, libp2p.AddrsFactory(func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			if front {
+				addrs = frontAddrs(port, "/tls/ws")
+			}
+			if domain != "" {
+				addrs = withDomainAddrs(addrs, domain)
+			}
+			return addrs
+		}))
+	}
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		log.Fatalf("Failed to create host: %v", err)
 	}
 	defer h.Close()
+	logConnections(h)
+	logMuxers(h, muxerIDs)
+
+	if front {
+		backend, err := frontBackend(h)
+		if err != nil {
+			log.Fatalf("Failed to find the libp2p listener: %v", err)
+		}
+		if err := serveHTTPFront(port, backend, tlsConfig); err != nil {
+			log.Fatalf("Failed to start the HTTP front: %v", err)
+		}
+		log.Printf("HTTP front: :%d (TLS) -> %s", port, backend)
+	}
 
 	// Get the host's peer ID
 	peerID := h.ID()
 	log.Printf("Local peer id: %s", peerID.String())
 	log.Printf("Transport: WSS (TLS + WebSocket)")
 	log.Printf("Security: Noise")
-	log.Printf("Muxer: Yamux")
+	log.Printf("Muxer: %s", strings.Join(muxerIDs, ", "))
 
 	// Print listen addresses
 	for _, addr := range h.Addrs() {
 		fullAddr := addr.Encapsulate(multiaddr.StringCast("/p2p/" + peerID.String()))
 		fmt.Printf("Listen: %s\n", fullAddr.String())
 	}
+	printBoundAddrs(h)
+	printPinnedAddrs(h, loaded)
 	fmt.Println("Ready to accept connections")
 
 	// Set up stream handler for custom protocols
-	h.SetStreamHandler("/test/echo/1.0.0", func(s network.Stream) {
+	h.SetStreamHandler(echoProtocol, func(s network.Stream) {
 		log.Printf("Received stream from %s", s.Conn().RemotePeer())
-		defer s.Close()
+		echoStream(s, echoBuf, nil)
+	})
 
-		// Echo back whatever is received
-		buf := make([]byte, 1024)
-		for {
-			n, err := s.Read(buf)
-			if err != nil {
-				return
+	if os.Getenv("PERF") == "1" {
+		h.SetStreamHandler(perfProtocol, handlePerf)
+	}
+
+	go handleReloadSignals(h, certs)
+	bw.run()
+	go handleCommands(h, certs, stats, bw)
+
+	// Keep the process running
+	select {}
+}
+
+// loadedCert is a certificate/key pair together with the files it came from.
+type loadedCert struct {
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	certFile string
+	keyFile  string
+	// source is where the pair came from: "file", "generated" when the node
+	// created the files itself, or "env" when written from CERT_PEM/KEY_PEM
+	source string
+}
+
+// fingerprint is the SHA-256 of the leaf's DER encoding, in hex.
+func (c *loadedCert) fingerprint() string {
+	sum := sha256.Sum256(c.leaf.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certhash is the same fingerprint as a /certhash/ multiaddr component: a
+// sha2-256 multihash, multibase base64url encoded.
+func (c *loadedCert) certhash() (multiaddr.Multiaddr, error) {
+	mh, err := multihash.Sum(c.leaf.Raw, multihash.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := multibase.Encode(multibase.Base64url, mh)
+	if err != nil {
+		return nil, err
+	}
+	return multiaddr.NewMultiaddr("/certhash/" + encoded)
+}
+
+// describe formats the leaf's expiry and fingerprint for the CERT_* log
+// lines.
+func (c *loadedCert) describe() string {
+	return fmt.Sprintf("notAfter=%s sha256=%s", c.leaf.NotAfter.UTC().Format(time.RFC3339), c.fingerprint())
+}
+
+// info formats the CERT_INFO line: the key algorithm (spelled like
+// CERT_ALGO), the fingerprint and validity window, the file the harness can
+// read the certificate from, and where the pair came from.
+func (c *loadedCert) info() string {
+	return fmt.Sprintf("algo=%s sha256=%s notBefore=%s notAfter=%s file=%s source=%s",
+		certAlgorithm(c.leaf), c.fingerprint(),
+		c.leaf.NotBefore.UTC().Format(time.RFC3339), c.leaf.NotAfter.UTC().Format(time.RFC3339),
+		c.certFile, c.source)
+}
+
+// certHolder serves the current certificate to tls.Config.GetCertificate and
+// lets it be swapped atomically. Established TLS sessions keep the
+// certificate they were handshaken with; only new handshakes see a swap.
+type certHolder struct {
+	current atomic.Pointer[loadedCert]
+	// base is the listener's configuration, cloned for each handshake by
+	// configForClient
+	base *tls.Config
+	// reloadMu serialises reloads so SIGHUP and ROTATE_CERT cannot interleave
+	// their reads of the current file paths.
+	reloadMu sync.Mutex
+	// failures keeps each ClientHello until its handshake completes or fails
+	failures *tlsFailures
+}
+
+// load reads and parses a certificate/key pair and, only if both succeed,
+// makes it the served certificate. On error the previous one stays in place.
+func (h *certHolder) load(certFile, keyFile string) (*loadedCert, error) {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+	return h.loadLocked(certFile, keyFile)
+}
+
+// reload re-reads the files the current certificate came from.
+func (h *certHolder) reload() (*loadedCert, error) {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+	cur := h.current.Load()
+	return h.loadLocked(cur.certFile, cur.keyFile)
+}
+
+func (h *certHolder) loadLocked(certFile, keyFile string) (*loadedCert, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse leaf certificate: %w", err)
+	}
+	cert.Leaf = leaf
+
+	loaded := &loadedCert{cert: &cert, leaf: leaf, certFile: certFile, keyFile: keyFile, source: "file"}
+	h.current.Store(loaded)
+	return loaded, nil
+}
+
+func (h *certHolder) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return h.current.Load().cert, nil
+}
+
+// configForClient fixes the certificate a handshake presents and, once the
+// handshake completes, logs CERT_PRESENTED with its fingerprint and whether
+// it is still the current one; current=false means a rotation landed
+// mid-handshake. Resumed sessions present no certificate and log nothing.
+//
+// It also logs the server name as TLS_SNI, and as TLS_SNI_MISMATCH with the
+// verification error when the certificate does not cover it. It never
+// rejects the handshake, so a covered name completes and an uncovered one is
+// left for the client to refuse.
+func (h *certHolder) configForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	remote := hello.Conn.RemoteAddr().String()
+	h.failures.rememberHello(remote, hello)
+	presented := h.current.Load()
+	if hello.ServerName == "" {
+		fmt.Println("TLS_SNI: (none)")
+	} else {
+		fmt.Printf("TLS_SNI: %s\n", hello.ServerName)
+		if err := presented.leaf.VerifyHostname(hello.ServerName); err != nil {
+			fmt.Printf("TLS_SNI_MISMATCH: name=%s err=%v\n", hello.ServerName, err)
+		}
+	}
+	if h.base == nil {
+		return nil, nil
+	}
+
+	config := h.base.Clone()
+	config.GetConfigForClient = nil
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return presented.cert, nil
+	}
+	next := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if next != nil {
+			if err := next(state); err != nil {
+				h.failures.verifyFailed(remote)
+				return err
 			}
-			if n > 0 {
-				log.Printf("Echo: %d bytes", n)
-				s.Write(buf[:n])
+		}
+		if !state.DidResume {
+			fmt.Printf("CERT_PRESENTED: sha256=%s current=%t\n", presented.fingerprint(), presented == h.current.Load())
+		}
+		return nil
+	}
+	return config, nil
+}
+
+// Where a certificate generated for CERT_ALGO or DOMAIN, or given inline as
+// CERT_PEM/KEY_PEM, is written.
+const (
+	generatedCertFile = "/generated-cert.pem"
+	generatedKeyFile  = "/generated-key.pem"
+	domainCertFile    = "/domain-cert.pem"
+	domainKeyFile     = "/domain-key.pem"
+	inlineCertFile    = "/inline-cert.pem"
+	inlineKeyFile     = "/inline-key.pem"
+)
+
+// ensureDomainCert keeps loaded if it already covers domain. Otherwise it
+// generates a self-signed certificate of type algo (ecdsa-p256 when empty)
+// for domain and localhost, writes it to certFile/keyFile and serves that
+// instead, so SIGHUP reloads it too.
+func ensureDomainCert(certs *certHolder, loaded *loadedCert, domain, algo, certFile, keyFile string) (*loadedCert, error) {
+	if loaded.leaf.VerifyHostname(domain) == nil {
+		fmt.Printf("CERT_DOMAIN: domain=%s source=%s\n", domain, loaded.certFile)
+		return loaded, nil
+	}
+
+	generated, err := writeSelfSigned(certs, algo, []string{domain, "localhost"}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("CERT_DOMAIN: domain=%s source=generated file=%s\n", domain, certFile)
+	return generated, nil
+}
+
+// writeSelfSigned generates a self-signed certificate of type algo for
+// dnsNames, writes it to certFile/keyFile and serves it from there.
+func writeSelfSigned(certs *certHolder, algo string, dnsNames []string, certFile, keyFile string) (*loadedCert, error) {
+	certPEM, keyPEM, err := generateSelfSigned(algo, dnsNames, 365*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	generated, err := certs.load(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	generated.source = "generated"
+	return generated, nil
+}
+
+// inlinePEM reads PEM text from the environment variable name. A literal
+// \n stands for a newline, for runners that cannot pass multi-line values.
+func inlinePEM(name string) []byte {
+	return []byte(strings.ReplaceAll(os.Getenv(name), `\n`, "\n"))
+}
+
+// checkInlinePair validates CERT_PEM and KEY_PEM, telling malformed PEM
+// apart from a well-formed pair whose key does not match the certificate.
+func checkInlinePair(certPEM, keyPEM []byte) error {
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return fmt.Errorf("CERT_PEM and KEY_PEM must be set together")
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("malformed CERT_PEM: no CERTIFICATE block")
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return fmt.Errorf("malformed CERT_PEM: %w", err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return fmt.Errorf("malformed KEY_PEM: no PRIVATE KEY block")
+	}
+	if err := parsePrivateKey(block.Bytes); err != nil {
+		return fmt.Errorf("malformed KEY_PEM: %w", err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("CERT_PEM and KEY_PEM do not match: %w", err)
+	}
+	return nil
+}
+
+// parsePrivateKey accepts the key encodings crypto/tls does: PKCS #8,
+// PKCS #1 and SEC 1.
+func parsePrivateKey(der []byte) error {
+	if _, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return nil
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return nil
+	}
+	if _, err := x509.ParseECPrivateKey(der); err == nil {
+		return nil
+	}
+	return fmt.Errorf("not a PKCS #8, PKCS #1 or EC private key")
+}
+
+// writeInline validates CERT_PEM and KEY_PEM, writes them to
+// certFile/keyFile and serves them from there, so CERT_INFO names a file like
+// any other source and SIGHUP rereads it.
+func writeInline(certs *certHolder, certPEM, keyPEM []byte, certFile, keyFile string) (*loadedCert, error) {
+	if err := checkInlinePair(certPEM, keyPEM); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	loaded, err := certs.load(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	loaded.source = "env"
+	return loaded, nil
+}
+
+// certAlgorithms are the CERT_ALGO values, in the spelling CERT_INFO uses.
+var certAlgorithms = []string{"rsa2048", "ecdsa-p256", "ecdsa-p384", "ed25519"}
+
+// checkCertAlgo accepts an empty CERT_ALGO or one of certAlgorithms.
+func checkCertAlgo(algo string) error {
+	if algo == "" || slices.Contains(certAlgorithms, algo) {
+		return nil
+	}
+	return fmt.Errorf("CERT_ALGO must be one of %s, got %q", strings.Join(certAlgorithms, ", "), algo)
+}
+
+// generateKey creates a private key for a CERT_ALGO value.
+func generateKey(algo string) (crypto.Signer, error) {
+	switch algo {
+	case "rsa2048":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "", "ecdsa-p256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, checkCertAlgo(algo)
+}
+
+// certAlgorithm names the leaf's key type the way CERT_ALGO spells it
+// (rsa2048, ecdsa-p256, ...), or Go's name for any other type.
+func certAlgorithm(leaf *x509.Certificate) string {
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ecdsa-" + strings.ToLower(strings.ReplaceAll(key.Curve.Params().Name, "-", ""))
+	case ed25519.PublicKey:
+		return "ed25519"
+	}
+	return strings.ToLower(leaf.PublicKeyAlgorithm.String())
+}
+
+// generateSelfSigned creates a PEM-encoded self-signed certificate and
+// PKCS #8 key of type algo (ecdsa-p256 when empty) whose subject alternative
+// names are dnsNames.
+func generateSelfSigned(algo string, dnsNames []string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := generateKey(algo)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		// TLS 1.2 RSA key exchange encrypts the premaster secret to the key
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// withDomainAddrs appends /dns4/<domain>/tcp/<port>/wss for every TCP port
+// the host listens on, keeping the IP addresses.
+func withDomainAddrs(addrs []multiaddr.Multiaddr, domain string) []multiaddr.Multiaddr {
+	seen := make(map[string]bool)
+	result := append([]multiaddr.Multiaddr{}, addrs...)
+	for _, addr := range addrs {
+		port, err := addr.ValueForProtocol(multiaddr.P_TCP)
+		if err != nil || seen[port] {
+			continue
+		}
+		seen[port] = true
+		dnsAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/dns4/%s/tcp/%s/wss", domain, port))
+		if err != nil {
+			log.Printf("Invalid DOMAIN %q: %v", domain, err)
+			continue
+		}
+		result = append(result, dnsAddr)
+	}
+	return result
+}
+
+// clientAuthConfig is the CLIENT_AUTH mode and the CAs client certificates
+// are verified against.
+type clientAuthConfig struct {
+	mode string // "none", "request" or "require"
+	cas  *x509.CertPool
+}
+
+// loadClientAuth parses CLIENT_AUTH (none when empty) and the client CAs,
+// taken from CA_PEM text when given and from CLIENT_CA_FILE otherwise.
+func loadClientAuth(mode, caFile string, caPEM []byte) (*clientAuthConfig, error) {
+	if mode == "" {
+		mode = "none"
+	}
+	switch mode {
+	case "none", "request", "require":
+	default:
+		return nil, fmt.Errorf("CLIENT_AUTH must be require, request or none, got %q", mode)
+	}
+
+	config := &clientAuthConfig{mode: mode}
+	switch {
+	case len(caPEM) > 0:
+		cas, err := parseCAPEM(caPEM)
+		if err != nil {
+			return nil, fmt.Errorf("malformed CA_PEM: %w", err)
+		}
+		config.cas = cas
+	case caFile != "":
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.cas = x509.NewCertPool()
+		if !config.cas.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	return config, nil
+}
+
+// parseCAPEM parses every CERTIFICATE block of CA_PEM. Unlike
+// AppendCertsFromPEM it fails on a block that does not parse instead of
+// skipping it.
+func parseCAPEM(data []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", count+1, err)
+		}
+		pool.AddCert(cert)
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no CERTIFICATE block")
+	}
+	return pool, nil
+}
+
+// apply sets ClientAuth and ClientCAs on config. With client auth on, the
+// certificate is requested but not checked by crypto/tls: Go's own check
+// fails the handshake before any callback runs, so nothing could be logged.
+// verifyConnection checks it against ClientCAs instead, logs the outcome and,
+// in require mode, fails the handshake, which makes crypto/tls send a
+// bad_certificate alert.
+func (c *clientAuthConfig) apply(config *tls.Config) {
+	if c.mode == "none" {
+		config.ClientAuth = tls.NoClientCert
+		return
+	}
+	config.ClientAuth = tls.RequestClientCert
+	config.ClientCAs = c.cas
+	config.VerifyConnection = c.verifyConnection
+}
+
+// verifyConnection logs TLS_CLIENT_CERT for every handshake and, in require
+// mode, rejects a missing or unverified certificate with TLS_CLIENT_AUTH_FAILED.
+func (c *clientAuthConfig) verifyConnection(state tls.ConnectionState) error {
+	present := len(state.PeerCertificates) > 0
+	subject := ""
+	var verifyErr error
+	if present {
+		subject = state.PeerCertificates[0].Subject.String()
+		verifyErr = c.verify(state.PeerCertificates)
+	} else {
+		verifyErr = fmt.Errorf("no client certificate presented")
+	}
+	fmt.Printf("TLS_CLIENT_CERT: present=%t subject=%s verified=%t\n", present, subject, verifyErr == nil)
+
+	if c.mode == "require" && verifyErr != nil {
+		fmt.Printf("TLS_CLIENT_AUTH_FAILED: alert=bad_certificate err=%v\n", verifyErr)
+		return verifyErr
+	}
+	return nil
+}
+
+// verify checks a client chain (leaf first) against ClientCAs for client
+// authentication.
+func (c *clientAuthConfig) verify(chain []*x509.Certificate) error {
+	if c.cas == nil {
+		return fmt.Errorf("no CLIENT_CA_FILE or CA_PEM to verify against")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         c.cas,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// tlsVersions maps the TLS_*_VERSION values to crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// versionName formats a negotiated version the way TLS_*_VERSION spells it.
+func versionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// tlsPolicy restricts the TLS versions and cipher suites the node negotiates.
+type tlsPolicy struct {
+	minVersion string
+	maxVersion string
+	suiteNames []string
+	// suites holds the TLS 1.0-1.2 suites, enforced by crypto/tls itself
+	suites []uint16
+	// tls13Suites holds the allowed TLS 1.3 suites. crypto/tls does not let
+	// them be configured, so check rejects any other after negotiation.
+	tls13Suites map[uint16]bool
+}
+
+// loadTLSPolicy parses TLS_MIN_VERSION, TLS_MAX_VERSION (1.0 to 1.3) and
+// TLS_CIPHER_SUITES (comma-separated IANA names). Empty values keep the
+// crypto/tls defaults.
+func loadTLSPolicy(minVersion, maxVersion, suites string) (*tlsPolicy, error) {
+	policy := &tlsPolicy{minVersion: minVersion, maxVersion: maxVersion}
+	for _, version := range []string{minVersion, maxVersion} {
+		if _, ok := tlsVersions[version]; version != "" && !ok {
+			return nil, fmt.Errorf("TLS version must be 1.0, 1.1, 1.2 or 1.3, got %q", version)
+		}
+	}
+	if minVersion != "" && maxVersion != "" && tlsVersions[minVersion] > tlsVersions[maxVersion] {
+		return nil, fmt.Errorf("TLS_MIN_VERSION %s is above TLS_MAX_VERSION %s", minVersion, maxVersion)
+	}
+	if suites == "" {
+		return policy, nil
+	}
+
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite
+	}
+	for _, name := range strings.Split(suites, ",") {
+		name = strings.TrimSpace(name)
+		suite, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		policy.suiteNames = append(policy.suiteNames, name)
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			if policy.tls13Suites == nil {
+				policy.tls13Suites = make(map[uint16]bool)
 			}
+			policy.tls13Suites[suite.ID] = true
+		} else {
+			policy.suites = append(policy.suites, suite.ID)
 		}
+	}
+	return policy, nil
+}
+
+// isSet reports whether any knob was given.
+func (p *tlsPolicy) isSet() bool {
+	return p.minVersion != "" || p.maxVersion != "" || len(p.suiteNames) > 0
+}
+
+// describe formats the policy for the TLS_POLICY log line.
+func (p *tlsPolicy) describe() string {
+	orDefault := func(value string) string {
+		if value == "" {
+			return "default"
+		}
+		return value
+	}
+	return fmt.Sprintf("min=%s max=%s ciphers=%s",
+		orDefault(p.minVersion), orDefault(p.maxVersion), orDefault(strings.Join(p.suiteNames, ",")))
+}
+
+// apply sets the version bounds and TLS 1.0-1.2 suites on config. Listing
+// only TLS 1.3 suites leaves the older suites at their defaults.
+func (p *tlsPolicy) apply(config *tls.Config) {
+	config.MinVersion = tlsVersions[p.minVersion]
+	config.MaxVersion = tlsVersions[p.maxVersion]
+	if len(p.suites) > 0 {
+		config.CipherSuites = p.suites
+	}
+}
+
+// check fails a TLS 1.3 handshake that negotiated a suite outside the list.
+func (p *tlsPolicy) check(state tls.ConnectionState) error {
+	if state.Version != tls.VersionTLS13 || p.tls13Suites == nil || p.tls13Suites[state.CipherSuite] {
+		return nil
+	}
+	return fmt.Errorf("cipher suite %s is not in TLS_CIPHER_SUITES", tls.CipherSuiteName(state.CipherSuite))
+}
+
+// handshakeKey is one version/cipher combination counted by handshakeStats.
+type handshakeKey struct {
+	version string
+	cipher  string
+}
+
+// handshakeStats counts completed handshakes by version and cipher suite,
+// and failed ones by category.
+type handshakeStats struct {
+	mu       sync.Mutex
+	counts   map[handshakeKey]int
+	failures map[string]int
+}
+
+// record logs TLS_STATE for a handshake and counts it.
+func (s *handshakeStats) record(state tls.ConnectionState) {
+	key := handshakeKey{version: versionName(state.Version), cipher: tls.CipherSuiteName(state.CipherSuite)}
+	fmt.Printf("TLS_STATE: version=%s cipher=%s alpn=%s resumed=%t\n",
+		key.version, key.cipher, state.NegotiatedProtocol, state.DidResume)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[handshakeKey]int)
+	}
+	s.counts[key]++
+}
+
+// recordFailure counts a failed handshake under category.
+func (s *handshakeStats) recordFailure(category string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures == nil {
+		s.failures = make(map[string]int)
+	}
+	s.failures[category]++
+}
+
+// summary formats the TLS_STATS lines, one per version/cipher combination
+// (newest version first), then one TLS_FAIL_STATS line per failure category,
+// followed by TLS_STATS_END with the number of completed handshakes.
+func (s *handshakeStats) summary() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]handshakeKey, 0, len(s.counts))
+	total := 0
+	for key, count := range s.counts {
+		keys = append(keys, key)
+		total += count
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].version != keys[j].version {
+			return keys[i].version > keys[j].version
+		}
+		return keys[i].cipher < keys[j].cipher
 	})
 
-	// Keep the process running
-	select {}
+	categories := make([]string, 0, len(s.failures))
+	for category := range s.failures {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	lines := make([]string, 0, len(keys)+len(categories)+1)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("TLS_STATS: version=%s cipher=%s count=%d", key.version, key.cipher, s.counts[key]))
+	}
+	for _, category := range categories {
+		lines = append(lines, fmt.Sprintf("TLS_FAIL_STATS: category=%s count=%d", category, s.failures[category]))
+	}
+	return append(lines, fmt.Sprintf("TLS_STATS_END: handshakes=%d", total))
+}
+
+// helloTTL bounds how long a ClientHello waits for its handshake outcome.
+const helloTTL = time.Minute
+
+// handshakeErrorMarker starts the line net/http logs for a failed handshake,
+// followed by "<remote addr>: <error>".
+const handshakeErrorMarker = "http: TLS handshake error from "
+
+// offeredHello is what a client offered in its ClientHello.
+type offeredHello struct {
+	at           time.Time
+	serverName   string
+	versions     []string
+	cipherSuites []string
+	alpn         []string
+	// verifyFailed is set when the node's own checks (cipher policy, client
+	// authentication) rejected the handshake
+	verifyFailed bool
+}
+
+// tlsFailure is the TLS_FAIL: JSON line.
+type tlsFailure struct {
+	Remote       string   `json:"remote"`
+	Category     string   `json:"category"`
+	Error        string   `json:"error"`
+	Hello        bool     `json:"hello"`
+	ServerName   string   `json:"sni"`
+	Versions     []string `json:"versions"`
+	CipherSuites []string `json:"cipher_suites"`
+	ALPN         []string `json:"alpn"`
+}
+
+// tlsFailures reports failed handshakes. crypto/tls has no failure hook and
+// net/http only logs "http: TLS handshake error from <addr>: <err>" through
+// the standard logger, so tlsFailures is that logger's output: it passes
+// every line on to next and turns handshake errors into TLS_FAIL lines,
+// joined by remote address with the ClientHello kept by configForClient.
+// A handshake that fails before its ClientHello is read reports hello=false
+// with empty offers.
+type tlsFailures struct {
+	stats *handshakeStats
+	next  io.Writer
+
+	mu     sync.Mutex
+	hellos map[string]*offeredHello
+}
+
+// rememberHello keeps what the client at remote offered until its handshake
+// fails or helloTTL passes. A hello is kept past VerifyConnection: in TLS 1.3
+// the client checks the node's certificate after the node has verified the
+// connection, so a certificate failure is logged later.
+func (f *tlsFailures) rememberHello(remote string, hello *tls.ClientHelloInfo) {
+	if f == nil {
+		return
+	}
+	offered := &offeredHello{
+		at:           time.Now(),
+		serverName:   hello.ServerName,
+		versions:     make([]string, 0, len(hello.SupportedVersions)),
+		cipherSuites: make([]string, 0, len(hello.CipherSuites)),
+		alpn:         append([]string{}, hello.SupportedProtos...),
+	}
+	for _, version := range hello.SupportedVersions {
+		offered.versions = append(offered.versions, versionName(version))
+	}
+	for _, suite := range hello.CipherSuites {
+		offered.cipherSuites = append(offered.cipherSuites, tls.CipherSuiteName(suite))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hellos == nil {
+		f.hellos = make(map[string]*offeredHello)
+	}
+	for addr, old := range f.hellos {
+		if time.Since(old.at) > helloTTL {
+			delete(f.hellos, addr)
+		}
+	}
+	f.hellos[remote] = offered
+}
+
+// verifyFailed marks the handshake from remote as rejected by the node.
+func (f *tlsFailures) verifyFailed(remote string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offered, ok := f.hellos[remote]; ok {
+		offered.verifyFailed = true
+	}
+}
+
+// Write passes p on and reports it if it is a handshake error.
+func (f *tlsFailures) Write(p []byte) (int, error) {
+	n, err := f.next.Write(p)
+	line := strings.TrimRight(string(p), "\n")
+	if i := strings.Index(line, handshakeErrorMarker); i >= 0 {
+		rest := line[i+len(handshakeErrorMarker):]
+		// The address may be IPv6 ("[::1]:4001"), so split at the first ": "
+		if remote, reason, ok := strings.Cut(rest, ": "); ok {
+			f.report(remote, reason)
+		}
+	}
+	return n, err
+}
+
+// report prints the TLS_FAIL line for remote, counts it and returns it.
+func (f *tlsFailures) report(remote, reason string) tlsFailure {
+	f.mu.Lock()
+	offered := f.hellos[remote]
+	delete(f.hellos, remote)
+	f.mu.Unlock()
+
+	failure := tlsFailure{
+		Remote:       remote,
+		Error:        reason,
+		Versions:     []string{},
+		CipherSuites: []string{},
+		ALPN:         []string{},
+	}
+	verifyFailed := false
+	if offered != nil {
+		failure.Hello = true
+		failure.ServerName = offered.serverName
+		failure.Versions = offered.versions
+		failure.CipherSuites = offered.cipherSuites
+		failure.ALPN = offered.alpn
+		verifyFailed = offered.verifyFailed
+	}
+	failure.Category = failureCategory(reason, failure.Hello, verifyFailed)
+	f.stats.recordFailure(failure.Category)
+
+	line, err := json.Marshal(failure)
+	if err != nil {
+		fmt.Printf("TLS_FAIL: {\"remote\":%q,\"error\":%q}\n", remote, err.Error())
+		return failure
+	}
+	fmt.Printf("TLS_FAIL: %s\n", line)
+	return failure
+}
+
+// failureCategory sorts a handshake error into tcp (the connection dropped
+// before a ClientHello), not_tls, version, cipher, alpn, client_cert (the
+// node rejected the client's certificate), cert (the client rejected the
+// node's), aborted (the client hung up mid-handshake) or other.
+func failureCategory(reason string, hello, verifyFailed bool) string {
+	lower := strings.ToLower(reason)
+	containsAny := func(parts ...string) bool {
+		for _, part := range parts {
+			if strings.Contains(lower, part) {
+				return true
+			}
+		}
+		return false
+	}
+	dropped := containsAny("eof", "connection reset", "broken pipe", "i/o timeout")
+
+	switch {
+	case verifyFailed && containsAny("tls_cipher_suites"):
+		return "cipher"
+	case verifyFailed:
+		return "client_cert"
+	case !hello && dropped:
+		return "tcp"
+	case containsAny("does not look like a tls handshake"):
+		return "not_tls"
+	case containsAny("unsupported versions", "protocol version"):
+		return "version"
+	case containsAny("cipher suite", "handshake failure", "insufficient security"):
+		return "cipher"
+	case containsAny("application protocol"):
+		return "alpn"
+	case containsAny("certificate", "unknown authority"):
+		return "cert"
+	case dropped:
+		return "aborted"
+	}
+	return "other"
+}
+
+// observeHandshakes wraps config.VerifyConnection, which crypto/tls calls
+// for full and resumed handshakes alike: the policy check runs first, then
+// any client authentication, and a handshake that passes both is recorded.
+// A rejected TLS 1.3 suite logs TLS_CIPHER_REJECTED.
+func observeHandshakes(config *tls.Config, policy *tlsPolicy, stats *handshakeStats) {
+	next := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if err := policy.check(state); err != nil {
+			fmt.Printf("TLS_CIPHER_REJECTED: version=%s err=%v\n", versionName(state.Version), err)
+			return err
+		}
+		if next != nil {
+			if err := next(state); err != nil {
+				return err
+			}
+		}
+		stats.record(state)
+		return nil
+	}
+}
+
+// rotate runs one certificate load and reports the outcome as CERT_ROTATED
+// (followed by the new CERT_SHA256 and PinnedAddr lines) or
+// CERT_ROTATE_FAILED. A failed load keeps serving the old certificate.
+func rotate(h host.Host, source string, load func() (*loadedCert, error)) {
+	loaded, err := load()
+	if err != nil {
+		fmt.Printf("CERT_ROTATE_FAILED: source=%s err=%v\n", source, err)
+		return
+	}
+	fmt.Printf("CERT_ROTATED: %s\n", loaded.describe())
+	fmt.Printf("CERT_SHA256: %s\n", loaded.fingerprint())
+	printPinnedAddrs(h, loaded)
+}
+
+// printPinnedAddrs prints every listen address with the certificate's
+// /certhash/ component as a PinnedAddr line.
+func printPinnedAddrs(h host.Host, loaded *loadedCert) {
+	certhash, err := loaded.certhash()
+	if err != nil {
+		fmt.Printf("PinnedAddr: err=%v\n", err)
+		return
+	}
+	p2p := multiaddr.StringCast("/p2p/" + h.ID().String())
+	for _, addr := range h.Addrs() {
+		fmt.Printf("PinnedAddr: %s\n", addr.Encapsulate(certhash).Encapsulate(p2p))
+	}
+}
+
+// handleReloadSignals reloads the certificate files on every SIGHUP.
+func handleReloadSignals(h host.Host, certs *certHolder) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		rotate(h, "sighup", certs.reload)
+	}
+}
+
+// handleCommands reads commands from stdin. DIAL, PING and PERF run in
+// their own goroutines so a slow peer never blocks the command loop; STREAMS
+// lists a peer's open streams and BANDWIDTH the byte counters
+// (BANDWIDTH_RESET zeroes them).
+func handleCommands(h host.Host, certs *certHolder, stats *handshakeStats, bw *bandwidth) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "ROTATE_CERT":
+			if len(fields) != 3 {
+				fmt.Println("CERT_ROTATE_FAILED: source=command err=usage: ROTATE_CERT <certfile> <keyfile>")
+				continue
+			}
+			certFile, keyFile := fields[1], fields[2]
+			rotate(h, "command", func() (*loadedCert, error) {
+				return certs.load(certFile, keyFile)
+			})
+
+		case "TLS_STATS":
+			for _, line := range stats.summary() {
+				fmt.Println(line)
+			}
+
+		case "DIAL":
+			if len(fields) != 2 {
+				fmt.Println("DIAL_FAILED: err=usage: DIAL <multiaddr>")
+				continue
+			}
+			go dialEcho(h, fields[1])
+
+		case "PING":
+			if len(fields) < 2 || len(fields) > 3 {
+				fmt.Println("PING_FAILED: err=usage: PING <multiaddr> [count]")
+				continue
+			}
+			count := 3
+			if len(fields) == 3 {
+				n, err := strconv.Atoi(fields[2])
+				if err != nil || n < 1 {
+					fmt.Printf("PING_FAILED: err=invalid count %q\n", fields[2])
+					continue
+				}
+				count = n
+			}
+			go pingPeer(h, fields[1], count)
+
+		case "PERF":
+			if len(fields) != 4 {
+				fmt.Println("PERF_FAILED: err=usage: PERF <multiaddr> <upload_bytes> <download_bytes>")
+				continue
+			}
+			up, down, err := parsePerfArgs(fields[2], fields[3])
+			if err != nil {
+				fmt.Printf("PERF_FAILED: err=%v\n", err)
+				continue
+			}
+			go perfClient(h, fields[1], up, down)
+
+		case "STREAMS":
+			if len(fields) != 2 {
+				fmt.Println("STREAMS_FAILED: err=usage: STREAMS <peerID>")
+				continue
+			}
+			listStreams(h, fields[1])
+
+		case "BANDWIDTH":
+			if len(fields) > 2 {
+				fmt.Println("BANDWIDTH_FAILED: err=usage: BANDWIDTH [peerID]")
+				continue
+			}
+			peerID := ""
+			if len(fields) == 2 {
+				peerID = fields[1]
+			}
+			bw.print(peerID)
+
+		case "BANDWIDTH_RESET":
+			bw.reset()
+		}
+	}
 }
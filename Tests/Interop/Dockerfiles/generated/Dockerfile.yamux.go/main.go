@@ -1,23 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/multiformats/go-multiaddr"
 )
 
-var streamCount int64
-
 func main() {
 	// Get port from environment
 	portStr := os.Getenv("LISTEN_PORT")
@@ -28,6 +36,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid port: %v", err)
 	}
+	echoBuf, err := echoBufferSize()
+	if err != nil {
+		log.Fatalf("Invalid echo buffer: %v", err)
+	}
 
 	// Create a new libp2p host with TCP + Noise + Yamux
 	h, err := libp2p.New(
@@ -42,6 +54,8 @@ func main() {
 		// Yamux muxer with custom config for testing
 		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
 		libp2p.Ping(true),
+		// The stream flood must hit the muxer, not the resource manager
+		libp2p.ResourceManager(&network.NullResourceManager{}),
 	)
 	if err != nil {
 		log.Fatalf("Failed to create host: %v", err)
@@ -59,47 +73,268 @@ func main() {
 	}
 	fmt.Println("Ready to accept connections")
 
+	stats := newStreamStats()
+
 	// Echo handler for testing multiplexed streams
-	h.SetStreamHandler("/test/echo/1.0.0", func(s network.Stream) {
-		count := atomic.AddInt64(&streamCount, 1)
-		log.Printf("Stream #%d opened from %s", count, s.Conn().RemotePeer())
-		defer func() {
-			s.Close()
-			log.Printf("Stream #%d closed", count)
-		}()
+	h.SetStreamHandler("/test/echo/1.0.0", stats.track(func(s network.Stream) {
+		ts := s.(*trackedStream)
+		log.Printf("Stream #%d opened from %s", ts.seq, s.Conn().RemotePeer())
+		defer log.Printf("Stream #%d closed", ts.seq)
+		stats.addEchoed(echoStream(s, echoBuf, nil))
+	}))
 
-		buf := make([]byte, 4096)
-		for {
-			n, err := s.Read(buf)
-			if err != nil {
-				return
-			}
-			if n > 0 {
-				log.Printf("Stream #%d: echo %d bytes", count, n)
-				s.Write(buf[:n])
-			}
-		}
-	})
+	if os.Getenv("PERF") == "1" {
+		h.SetStreamHandler(perfProtocol, stats.track(handlePerf))
+	}
 
 	// Flow control test handler
-	h.SetStreamHandler("/test/flow/1.0.0", func(s network.Stream) {
+	h.SetStreamHandler("/test/flow/1.0.0", stats.track(func(s network.Stream) {
 		log.Printf("Flow control test from %s", s.Conn().RemotePeer())
+		// Echo 1KB per 10ms so a fast sender fills the receive window
+		echoStream(slowReadStream{s}, flowChunkSize, nil)
+	}))
+
+	// Stream flood handler: reads a stream count line, opens that many raw
+	// muxed streams back over the same connection and reports how many the
+	// dialer reset. Logged as FLOOD: requested=<n> opened=<n> reset=<n>.
+	h.SetStreamHandler("/test/flood/1.0.0", func(s network.Stream) {
 		defer s.Close()
 
-		// Read data and respond slowly to test flow control
-		buf := make([]byte, 1024)
-		for {
-			n, err := s.Read(buf)
-			if err != nil {
-				return
-			}
-			// Simulate slow processing
-			time.Sleep(10 * time.Millisecond)
-			if n > 0 {
-				s.Write(buf[:n])
-			}
+		line, err := bufio.NewReader(s).ReadString('\n')
+		if err != nil {
+			log.Printf("FLOOD_ERROR: %v", err)
+			return
+		}
+		requested, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			log.Printf("FLOOD_ERROR: %v", err)
+			return
 		}
+
+		opened, reset := flood(s.Conn(), requested)
+		summary := fmt.Sprintf("FLOOD: requested=%d opened=%d reset=%d", requested, opened, reset)
+		log.Print(summary)
+		fmt.Fprintln(s, summary)
 	})
 
+	handleCommands(h, stats)
 	select {}
 }
+
+// handleCommands runs the stdin commands. STATS prints the session state as
+// one JSON line, STREAMS lists a peer's open handler streams and STATS_RESET
+// zeroes the cumulative counters.
+func handleCommands(h host.Host, stats *streamStats) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "STATS":
+			stats.print(h)
+		case "STREAMS":
+			if len(fields) != 2 {
+				fmt.Println("STREAMS_FAILED: err=usage: STREAMS <peerID>")
+				continue
+			}
+			stats.list(fields[1])
+		case "STATS_RESET":
+			stats.reset()
+		}
+	}
+}
+
+// streamStats counts the streams the test handlers serve. Streams the node
+// did not hand to a handler (identify, ping, flood) show up only in the
+// per-connection counts, which come from the muxer.
+type streamStats struct {
+	mu         sync.Mutex
+	open       map[*trackedStream]struct{}
+	cumulative int64
+	echoed     int64
+	seq        int64
+}
+
+func newStreamStats() *streamStats {
+	return &streamStats{open: make(map[*trackedStream]struct{})}
+}
+
+// trackedStream counts the bytes read from and written to a handler stream.
+type trackedStream struct {
+	network.Stream
+	seq     int64
+	opened  time.Time
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+func (s *trackedStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	s.read.Add(int64(n))
+	return n, err
+}
+
+func (s *trackedStream) Write(p []byte) (int, error) {
+	n, err := s.Stream.Write(p)
+	s.written.Add(int64(n))
+	return n, err
+}
+
+// flowChunkSize is what the /test/flow/1.0.0 handler reads per step.
+const flowChunkSize = 1024
+
+// slowReadStream sleeps 10ms after every read that returned data, the slow
+// consumer of the flow control test.
+type slowReadStream struct {
+	network.Stream
+}
+
+func (s slowReadStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	if n > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return n, err
+}
+
+// track wraps a stream handler so the stream is listed by STREAMS while the
+// handler runs and counted in the cumulative total.
+func (st *streamStats) track(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		ts := &trackedStream{Stream: s, opened: time.Now()}
+		st.mu.Lock()
+		st.seq++
+		ts.seq = st.seq
+		st.cumulative++
+		st.open[ts] = struct{}{}
+		st.mu.Unlock()
+		defer func() {
+			st.mu.Lock()
+			delete(st.open, ts)
+			st.mu.Unlock()
+		}()
+		handler(ts)
+	}
+}
+
+func (st *streamStats) addEchoed(n int64) {
+	st.mu.Lock()
+	st.echoed += n
+	st.mu.Unlock()
+}
+
+// connStats is one connection's entry in the STATS line.
+type connStats struct {
+	Peer    string `json:"peer"`
+	Remote  string `json:"remote"`
+	Streams int    `json:"streams"`
+}
+
+// print runs STATS:
+//
+//	STATS: {"open_streams":<n>,"cumulative_streams":<n>,"conns":[{"peer":..,"remote":..,"streams":<n>}],"echoed_bytes":<n>,"goroutines":<n>}
+//
+// open_streams is the number of handler streams still running; each conns
+// entry counts every stream the muxer has open on that connection.
+func (st *streamStats) print(h host.Host) {
+	conns := []connStats{}
+	for _, c := range h.Network().Conns() {
+		conns = append(conns, connStats{
+			Peer:    c.RemotePeer().String(),
+			Remote:  c.RemoteMultiaddr().String(),
+			Streams: len(c.GetStreams()),
+		})
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].Peer != conns[j].Peer {
+			return conns[i].Peer < conns[j].Peer
+		}
+		return conns[i].Remote < conns[j].Remote
+	})
+
+	st.mu.Lock()
+	line, err := json.Marshal(struct {
+		OpenStreams       int         `json:"open_streams"`
+		CumulativeStreams int64       `json:"cumulative_streams"`
+		Conns             []connStats `json:"conns"`
+		EchoedBytes       int64       `json:"echoed_bytes"`
+		Goroutines        int         `json:"goroutines"`
+	}{len(st.open), st.cumulative, conns, st.echoed, runtime.NumGoroutine()})
+	st.mu.Unlock()
+	if err != nil {
+		fmt.Printf("STATS_FAILED: err=%v\n", err)
+		return
+	}
+	fmt.Printf("STATS: %s\n", line)
+}
+
+// list runs STREAMS <peerID>, one line per open handler stream:
+//
+//	STREAM: peer=<id> protocol=<id> dir=inbound|outbound age_ms=<n> in=<bytes> out=<bytes>
+//	STREAMS_END: peer=<id> count=<n>
+func (st *streamStats) list(arg string) {
+	p, err := peer.Decode(arg)
+	if err != nil {
+		fmt.Printf("STREAMS_FAILED: peer=%s err=%v\n", arg, err)
+		return
+	}
+
+	st.mu.Lock()
+	streams := make([]*trackedStream, 0, len(st.open))
+	for ts := range st.open {
+		if ts.Conn().RemotePeer() == p {
+			streams = append(streams, ts)
+		}
+	}
+	st.mu.Unlock()
+	sort.Slice(streams, func(i, j int) bool { return streams[i].seq < streams[j].seq })
+
+	for _, ts := range streams {
+		dir := "inbound"
+		if ts.Stat().Direction == network.DirOutbound {
+			dir = "outbound"
+		}
+		fmt.Printf("STREAM: peer=%s protocol=%s dir=%s age_ms=%d in=%d out=%d\n",
+			p, ts.Protocol(), dir, time.Since(ts.opened).Milliseconds(), ts.read.Load(), ts.written.Load())
+	}
+	fmt.Printf("STREAMS_END: peer=%s count=%d\n", p, len(streams))
+}
+
+// reset runs STATS_RESET. Open streams stay listed.
+func (st *streamStats) reset() {
+	st.mu.Lock()
+	st.cumulative = 0
+	st.echoed = 0
+	st.mu.Unlock()
+	fmt.Println("STATS_RESET: ok")
+}
+
+// flood opens n raw muxed streams on conn and counts those the remote resets.
+// Accepted streams are left open so they keep counting against its limit.
+func flood(conn network.Conn, n int) (opened, reset int64) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		st, err := conn.NewStream(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("FLOOD_OPEN_FAILED: index=%d err=%v", i, err)
+			break
+		}
+		opened++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err := st.Read(make([]byte, 1))
+			if errors.Is(err, network.ErrReset) {
+				atomic.AddInt64(&reset, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	return opened, atomic.LoadInt64(&reset)
+}
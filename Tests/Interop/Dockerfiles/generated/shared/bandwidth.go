@@ -0,0 +1,130 @@
+package main
+
+// Bandwidth accounting. A node copies this file next to its main.go, adds
+// bw.option() to its libp2p options, calls bw.run once the host is up and
+// routes the BANDWIDTH and BANDWIDTH_RESET commands to bw.print and bw.reset.
+//
+// The counter is the host's metrics.Reporter, so the swarm logs every byte
+// read from or written to any stream: echo, perf, ping, identify, and streams
+// over relayed connections alike. Transport framing (TLS, websocket, noise,
+// muxer headers) is below the streams and not counted. Bytes sent before a
+// stream's protocol is negotiated count under protocol=none. BANDWIDTH
+// [peerID] prints
+//
+//	BANDWIDTH: scope=total in=<bytes> out=<bytes> rate_in=<B/s> rate_out=<B/s>
+//	BANDWIDTH: scope=peer peer=<id> in=... out=... rate_in=... rate_out=...
+//	BANDWIDTH: scope=protocol protocol=<id> in=... out=... rate_in=... rate_out=...
+//	BANDWIDTH_END: peers=<n> protocols=<n>
+//
+// with one peer line per peer seen, or only the given peer's, and one
+// protocol line per protocol. Rates are go-libp2p's one-second moving
+// averages. BANDWIDTH_RESET zeroes every counter and prints
+// "BANDWIDTH_RESET: ok". BW_INTERVAL_S=<n> also prints
+//
+//	BW: t_ms=<since start> in=<bytes> out=<bytes> rate_in=<B/s> rate_out=<B/s> peers=<n>
+//
+// every n seconds.
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// bandwidth holds the host's bandwidth counter and the BW: interval.
+type bandwidth struct {
+	counter  *metrics.BandwidthCounter
+	interval time.Duration
+	started  time.Time
+}
+
+// newBandwidth reads BW_INTERVAL_S; unset or 0 turns the BW: lines off.
+func newBandwidth() (*bandwidth, error) {
+	bw := &bandwidth{counter: metrics.NewBandwidthCounter(), started: time.Now()}
+	v := os.Getenv("BW_INTERVAL_S")
+	if v == "" {
+		return bw, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("BW_INTERVAL_S must be a non-negative integer, got %q", v)
+	}
+	bw.interval = time.Duration(n) * time.Second
+	return bw, nil
+}
+
+// option registers the counter as the host's bandwidth reporter.
+func (bw *bandwidth) option() libp2p.Option {
+	return libp2p.BandwidthReporter(bw.counter)
+}
+
+// run prints a BW: line every interval, if one is set.
+func (bw *bandwidth) run() {
+	if bw.interval == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(bw.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			total := bw.counter.GetBandwidthTotals()
+			fmt.Printf("BW: t_ms=%d %s peers=%d\n",
+				time.Since(bw.started).Milliseconds(), formatStats(total), len(bw.counter.GetBandwidthByPeer()))
+		}
+	}()
+}
+
+// print runs BANDWIDTH; a non-empty peerID narrows the peer lines to it.
+func (bw *bandwidth) print(peerID string) {
+	byPeer := bw.counter.GetBandwidthByPeer()
+	if peerID != "" {
+		p, err := peer.Decode(peerID)
+		if err != nil {
+			fmt.Printf("BANDWIDTH_FAILED: err=invalid peer ID %q: %v\n", peerID, err)
+			return
+		}
+		byPeer = map[peer.ID]metrics.Stats{p: bw.counter.GetBandwidthForPeer(p)}
+	}
+
+	fmt.Printf("BANDWIDTH: scope=total %s\n", formatStats(bw.counter.GetBandwidthTotals()))
+	peers := make([]peer.ID, 0, len(byPeer))
+	for p := range byPeer {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	for _, p := range peers {
+		fmt.Printf("BANDWIDTH: scope=peer peer=%s %s\n", p, formatStats(byPeer[p]))
+	}
+
+	byProtocol := bw.counter.GetBandwidthByProtocol()
+	protocols := make([]protocol.ID, 0, len(byProtocol))
+	for proto := range byProtocol {
+		protocols = append(protocols, proto)
+	}
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i] < protocols[j] })
+	for _, proto := range protocols {
+		name := string(proto)
+		if name == "" {
+			name = "none"
+		}
+		fmt.Printf("BANDWIDTH: scope=protocol protocol=%s %s\n", name, formatStats(byProtocol[proto]))
+	}
+	fmt.Printf("BANDWIDTH_END: peers=%d protocols=%d\n", len(peers), len(protocols))
+}
+
+// reset runs BANDWIDTH_RESET.
+func (bw *bandwidth) reset() {
+	bw.counter.Reset()
+	fmt.Println("BANDWIDTH_RESET: ok")
+}
+
+func formatStats(s metrics.Stats) string {
+	return fmt.Sprintf("in=%d out=%d rate_in=%.0f rate_out=%.0f", s.TotalIn, s.TotalOut, s.RateIn, s.RateOut)
+}
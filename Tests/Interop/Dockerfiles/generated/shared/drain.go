@@ -0,0 +1,198 @@
+package main
+
+// Graceful shutdown for the ws node. A node copies this file next
+// to its main.go, wraps the stream handlers it wants waited for with
+// drain.handler, and calls drain.run once the host exists.
+//
+// SIGTERM, or the stdin command SHUTDOWN, stops the listeners so no new
+// connection arrives, refuses new streams, and tells the active ones to wrap
+// up: an echo stream closes its write side, which its peer sees as EOF, and
+// a perf stream simply keeps going. Streams still running after
+// DRAIN_TIMEOUT_S seconds (default 5) are reset. Every connection is then
+// closed through the host, which sends a Yamux GoAway and, on WebSocket, a
+// close frame before the TCP FIN, and the node exits 0. It prints
+//
+//	SHUTDOWN: reason=<signal|command> conns=<n> streams=<n> timeout_ms=<d>
+//	DRAIN: peer=<id> remote=<multiaddr> streams=<n> drained=<n> cut=<n> close=<ok|err>
+//	SHUTDOWN_DONE: conns=<n> drained=<n> cut=<n> duration_ms=<d>
+//
+// with one DRAIN line per connection; drained streams ended on their own
+// within the window, cut ones were reset at its end.
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// defaultDrainTimeout is the drain window when DRAIN_TIMEOUT_S is unset.
+const defaultDrainTimeout = 5 * time.Second
+
+// drainTimeout reads DRAIN_TIMEOUT_S.
+func drainTimeout() (time.Duration, error) {
+	v := os.Getenv("DRAIN_TIMEOUT_S")
+	if v == "" {
+		return defaultDrainTimeout, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("DRAIN_TIMEOUT_S=%q is not a number of seconds", v)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// drainer tracks the streams a shutdown waits for.
+type drainer struct {
+	timeout time.Duration
+	// wrapUp is closed when shutdown starts
+	wrapUp chan struct{}
+	// requested receives the shutdown reason
+	requested chan string
+
+	mu       sync.Mutex
+	stopping bool
+	active   map[network.Stream]struct{}
+	// drained counts streams that ended during the drain, by conn ID
+	drained map[string]int
+	done    chan struct{}
+}
+
+func newDrainer() (*drainer, error) {
+	timeout, err := drainTimeout()
+	if err != nil {
+		return nil, err
+	}
+	return &drainer{
+		timeout:   timeout,
+		wrapUp:    make(chan struct{}),
+		requested: make(chan string, 1),
+		active:    make(map[network.Stream]struct{}),
+		drained:   make(map[string]int),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// handler wraps fn so shutdown waits for its streams. Streams opened after
+// shutdown started are reset.
+func (d *drainer) handler(fn network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		d.mu.Lock()
+		if d.stopping {
+			d.mu.Unlock()
+			s.Reset()
+			return
+		}
+		d.active[s] = struct{}{}
+		d.mu.Unlock()
+
+		defer d.finished(s)
+		fn(s)
+	}
+}
+
+func (d *drainer) finished(s network.Stream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.active[s]; !ok {
+		// Already cut
+		return
+	}
+	delete(d.active, s)
+	if d.stopping {
+		d.drained[s.Conn().ID()]++
+		if len(d.active) == 0 {
+			close(d.done)
+		}
+	}
+}
+
+// wrapUpOnShutdown closes s's write side when shutdown starts, unless stop
+// is closed first.
+func (d *drainer) wrapUpOnShutdown(s network.Stream, stop <-chan struct{}) {
+	go func() {
+		select {
+		case <-d.wrapUp:
+			s.CloseWrite()
+		case <-stop:
+		}
+	}()
+}
+
+// request starts a shutdown for reason, unless one is already pending.
+func (d *drainer) request(reason string) {
+	select {
+	case d.requested <- reason:
+	default:
+	}
+}
+
+// run waits for SIGTERM or request, then drains h and exits 0.
+func (d *drainer) run(h host.Host) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-sigs
+		d.request("signal")
+	}()
+
+	reason := <-d.requested
+	start := time.Now()
+
+	if lc, ok := h.Network().(interface{ ListenClose(...multiaddr.Multiaddr) }); ok {
+		lc.ListenClose(h.Network().ListenAddresses()...)
+	}
+	if frontServer != nil {
+		frontServer.Close()
+	}
+
+	d.mu.Lock()
+	d.stopping = true
+	streams := len(d.active)
+	if streams == 0 {
+		close(d.done)
+	}
+	d.mu.Unlock()
+	conns := h.Network().Conns()
+	fmt.Printf("SHUTDOWN: reason=%s conns=%d streams=%d timeout_ms=%d\n", reason, len(conns), streams, d.timeout.Milliseconds())
+	close(d.wrapUp)
+
+	select {
+	case <-d.done:
+	case <-time.After(d.timeout):
+	}
+
+	d.mu.Lock()
+	cut := make(map[string]int)
+	for s := range d.active {
+		cut[s.Conn().ID()]++
+		s.Reset()
+	}
+	d.active = map[network.Stream]struct{}{}
+	d.mu.Unlock()
+
+	var totalDrained, totalCut int
+	for _, c := range conns {
+		d.mu.Lock()
+		drained := d.drained[c.ID()]
+		d.mu.Unlock()
+		result := "ok"
+		if err := c.Close(); err != nil {
+			result = "err"
+		}
+		fmt.Printf("DRAIN: peer=%s remote=%s streams=%d drained=%d cut=%d close=%s\n",
+			c.RemotePeer(), c.RemoteMultiaddr(), drained+cut[c.ID()], drained, cut[c.ID()], result)
+		totalDrained += drained
+		totalCut += cut[c.ID()]
+	}
+	fmt.Printf("SHUTDOWN_DONE: conns=%d drained=%d cut=%d duration_ms=%d\n", len(conns), totalDrained, totalCut, time.Since(start).Milliseconds())
+	h.Close()
+	os.Exit(0)
+}
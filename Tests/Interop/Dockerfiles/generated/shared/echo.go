@@ -0,0 +1,65 @@
+package main
+
+// The /test/echo/1.0.0 handler body shared by the go nodes. A node copies
+// this file next to its main.go, reads the buffer size once with
+// echoBufferSize at startup and calls echoStream from its handler.
+//
+// ECHO_BUF_BYTES sets the buffer the echo copies through (default 32KiB).
+// Each echo prints
+//
+//	ECHO_DONE: peer=<id> bytes=<n> duration_ms=<d>
+//
+// once the remote has closed its write side and everything it sent has
+// been written back.
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// defaultEchoBufferSize is the echo copy buffer when ECHO_BUF_BYTES is unset.
+const defaultEchoBufferSize = 32 << 10
+
+// echoBufferSize reads ECHO_BUF_BYTES, the size of the buffer the echo
+// handler copies through.
+func echoBufferSize() (int, error) {
+	v := os.Getenv("ECHO_BUF_BYTES")
+	if v == "" {
+		return defaultEchoBufferSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("ECHO_BUF_BYTES=%q is not a positive byte count", v)
+	}
+	return n, nil
+}
+
+// echoStream writes back everything read from s until the remote closes its
+// write side, then closes s, prints ECHO_DONE and returns the bytes echoed.
+// io.CopyBuffer turns a short write into an error rather than dropping the
+// rest of the chunk.
+//
+// wrapUp may be nil. Once it is closed the caller has closed s's write side
+// to end the echo early, so the copy error is expected and the rest of the
+// remote's data is read and dropped until it closes too.
+func echoStream(s network.Stream, bufSize int, wrapUp <-chan struct{}) int64 {
+	defer s.Close()
+	start := time.Now()
+	n, err := io.CopyBuffer(s, s, make([]byte, bufSize))
+	select {
+	case <-wrapUp:
+		io.Copy(io.Discard, s)
+	default:
+		if err != nil {
+			log.Printf("Echo to %s stopped after %d bytes: %v", s.Conn().RemotePeer(), n, err)
+		}
+	}
+	fmt.Printf("ECHO_DONE: peer=%s bytes=%d duration_ms=%d\n", s.Conn().RemotePeer(), n, time.Since(start).Milliseconds())
+	return n
+}
@@ -0,0 +1,366 @@
+package main
+
+// Connectivity event log shared by the go-libp2p test nodes. A node copies
+// this file next to its main.go, sets events = newEventLog() and, when that
+// is non-nil, calls events.attach(h) once the host exists. With EVENTS=1
+// every event is printed as one line:
+//
+//	EVENT: {"seq":<n>,"time":"<RFC3339>","uptime_ms":<x>,"event":"<kind>",...}
+//
+// seq increases by one per line and uptime_ms is read from the monotonic
+// clock, so lines can be ordered even if the wall clock jumps. attach reports
+// listen_started, listen_closed, connection_opened, connection_closed,
+// identify_completed and identify_failed. Nodes report the upgrade stages
+// they can see (inbound_connection, security_negotiated, muxer_negotiated,
+// upgrade_failed) themselves, and wrap their stream handlers and outbound
+// streams with handler and stream for stream_opened and stream_closed.
+// Streams go-libp2p opens and serves internally, such as identify, are not
+// reported.
+//
+// Every method is a no-op on a nil *eventLog, which is what newEventLog
+// returns unless EVENTS=1.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// events is the node's event log, nil unless EVENTS=1.
+var events *eventLog
+
+// eventFields are the event-specific members of an EVENT line.
+type eventFields map[string]any
+
+type eventLog struct {
+	startedAt time.Time
+
+	mu  sync.Mutex
+	seq uint64
+	// openedAt holds when each open connection was reported, by conn ID
+	openedAt map[string]time.Time
+	// closeReasons holds why a muxed connection closed, by connKey, until
+	// its connection_closed event picks it up
+	closeReasons map[string]string
+}
+
+func newEventLog() *eventLog {
+	if os.Getenv("EVENTS") != "1" {
+		return nil
+	}
+	return &eventLog{
+		startedAt:    time.Now(),
+		openedAt:     make(map[string]time.Time),
+		closeReasons: make(map[string]string),
+	}
+}
+
+// emit prints one EVENT line. The sequence number is taken and the line
+// written under the same lock, so lines appear in sequence order.
+func (l *eventLog) emit(kind string, fields eventFields) {
+	if l == nil {
+		return
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		body = []byte(fmt.Sprintf(`{"marshal_error":%q}`, err.Error()))
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	line := fmt.Sprintf(`{"seq":%d,"time":%q,"uptime_ms":%.3f,"event":%q`,
+		l.seq, now.UTC().Format(time.RFC3339Nano), eventMillis(now.Sub(l.startedAt)), kind)
+	if len(body) > 2 {
+		line += "," + string(body[1:])
+	} else {
+		line += "}"
+	}
+	fmt.Printf("EVENT: %s\n", line)
+}
+
+// attach reports the host's listen addresses, connections and identify
+// results. The returned subscription delivers the identify events and
+// should be closed with the host.
+func (l *eventLog) attach(h host.Host) (event.Subscription, error) {
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerIdentificationFailed),
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for e := range sub.Out() {
+			switch evt := e.(type) {
+			case event.EvtPeerIdentificationCompleted:
+				fields := eventFields{
+					"peer":             evt.Peer.String(),
+					"agent":            evt.AgentVersion,
+					"protocol_version": evt.ProtocolVersion,
+					"protocols":        len(evt.Protocols),
+					"listen_addrs":     len(evt.ListenAddrs),
+				}
+				if evt.Conn != nil {
+					fields["conn"] = evt.Conn.ID()
+				}
+				l.emit("identify_completed", fields)
+			case event.EvtPeerIdentificationFailed:
+				l.emit("identify_failed", eventFields{"peer": evt.Peer.String(), "err": fmt.Sprint(evt.Reason)})
+			}
+		}
+	}()
+
+	// The host is already listening, so ListenF only sees later addresses
+	for _, addr := range h.Network().ListenAddresses() {
+		l.emit("listen_started", eventFields{"addr": addr.String()})
+	}
+	h.Network().Notify(&network.NotifyBundle{
+		ListenF: func(n network.Network, addr multiaddr.Multiaddr) {
+			l.emit("listen_started", eventFields{"addr": addr.String()})
+		},
+		ListenCloseF: func(n network.Network, addr multiaddr.Multiaddr) {
+			l.emit("listen_closed", eventFields{"addr": addr.String()})
+		},
+		ConnectedF: func(n network.Network, c network.Conn) {
+			l.connectionOpened(c)
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			l.connectionClosed(c)
+		},
+	})
+	return sub, nil
+}
+
+func (l *eventLog) connectionOpened(c network.Conn) {
+	l.mu.Lock()
+	l.openedAt[c.ID()] = time.Now()
+	l.mu.Unlock()
+
+	state := c.ConnState()
+	l.emit("connection_opened", eventFields{
+		"conn":      c.ID(),
+		"peer":      c.RemotePeer().String(),
+		"dir":       direction(c.Stat().Direction),
+		"local":     c.LocalMultiaddr().String(),
+		"remote":    c.RemoteMultiaddr().String(),
+		"security":  string(state.Security),
+		"muxer":     string(state.StreamMultiplexer),
+		"transport": state.Transport,
+	})
+}
+
+// connectionClosed reports c with its lifetime and, when its muxed
+// connection was wrapped by muxedConn, who closed it.
+func (l *eventLog) connectionClosed(c network.Conn) {
+	key := connKeyFromMultiaddrs(c.LocalMultiaddr(), c.RemoteMultiaddr())
+	l.mu.Lock()
+	openedAt, opened := l.openedAt[c.ID()]
+	delete(l.openedAt, c.ID())
+	reason, hasReason := l.closeReasons[key]
+	delete(l.closeReasons, key)
+	l.mu.Unlock()
+
+	fields := eventFields{
+		"conn":    c.ID(),
+		"peer":    c.RemotePeer().String(),
+		"dir":     direction(c.Stat().Direction),
+		"remote":  c.RemoteMultiaddr().String(),
+		"streams": len(c.GetStreams()),
+	}
+	if opened {
+		fields["duration_ms"] = eventMillis(time.Since(openedAt))
+	}
+	if hasReason {
+		fields["reason"] = reason
+	}
+	l.emit("connection_closed", fields)
+}
+
+// muxedConn wraps a muxed connection built over raw so connection_closed
+// can say whether the remote end went away (reason=remote: <err>) or this
+// node closed it (reason=local).
+func (l *eventLog) muxedConn(mc network.MuxedConn, raw net.Conn) network.MuxedConn {
+	if l == nil || mc == nil {
+		return mc
+	}
+	return &eventMuxedConn{MuxedConn: mc, log: l, key: connKey(raw.LocalAddr(), raw.RemoteAddr())}
+}
+
+type eventMuxedConn struct {
+	network.MuxedConn
+	log *eventLog
+	key string
+
+	mu sync.Mutex
+	// acceptErr is the error that ended the accept loop, which the
+	// session reports before anyone closes it when the remote goes away
+	acceptErr error
+	closed    bool
+}
+
+func (c *eventMuxedConn) AcceptStream() (network.MuxedStream, error) {
+	s, err := c.MuxedConn.AcceptStream()
+	if err != nil {
+		c.mu.Lock()
+		if c.acceptErr == nil && !c.closed {
+			c.acceptErr = err
+		}
+		c.mu.Unlock()
+	}
+	return s, err
+}
+
+func (c *eventMuxedConn) Close() error {
+	c.mu.Lock()
+	first := !c.closed
+	c.closed = true
+	acceptErr := c.acceptErr
+	c.mu.Unlock()
+
+	if first {
+		reason := "local"
+		if acceptErr != nil {
+			reason = "remote: " + acceptErr.Error()
+		}
+		c.log.mu.Lock()
+		c.log.closeReasons[c.key] = reason
+		c.log.mu.Unlock()
+	}
+	return c.MuxedConn.Close()
+}
+
+// addrString formats a net.Addr as a multiaddr when it can be expressed as
+// one, to match the addresses in the other events.
+func addrString(a net.Addr) string {
+	if ma, err := manet.FromNetAddr(a); err == nil {
+		return ma.String()
+	}
+	return a.String()
+}
+
+func connKey(local, remote net.Addr) string {
+	return local.String() + "|" + remote.String()
+}
+
+func connKeyFromMultiaddrs(local, remote multiaddr.Multiaddr) string {
+	localAddr, err := manet.ToNetAddr(local)
+	if err != nil {
+		return ""
+	}
+	remoteAddr, err := manet.ToNetAddr(remote)
+	if err != nil {
+		return ""
+	}
+	return connKey(localAddr, remoteAddr)
+}
+
+// handler wraps a stream handler so each stream it serves is reported.
+func (l *eventLog) handler(h network.StreamHandler) network.StreamHandler {
+	if l == nil {
+		return h
+	}
+	return func(s network.Stream) {
+		ts := l.stream(s).(*eventStream)
+		defer ts.finish("handler_return")
+		h(ts)
+	}
+}
+
+// stream reports s as opened and returns it wrapped so that its byte counts
+// and lifetime are reported when it is closed or reset.
+func (l *eventLog) stream(s network.Stream) network.Stream {
+	if l == nil {
+		return s
+	}
+	ts := &eventStream{Stream: s, log: l, openedAt: time.Now()}
+	l.emit("stream_opened", ts.fields())
+	return ts
+}
+
+type eventStream struct {
+	network.Stream
+	log      *eventLog
+	openedAt time.Time
+
+	mu       sync.Mutex
+	bytesIn  int64
+	bytesOut int64
+	finished bool
+}
+
+func (s *eventStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	s.mu.Lock()
+	s.bytesIn += int64(n)
+	s.mu.Unlock()
+	return n, err
+}
+
+func (s *eventStream) Write(p []byte) (int, error) {
+	n, err := s.Stream.Write(p)
+	s.mu.Lock()
+	s.bytesOut += int64(n)
+	s.mu.Unlock()
+	return n, err
+}
+
+func (s *eventStream) Close() error {
+	err := s.Stream.Close()
+	s.finish("close")
+	return err
+}
+
+func (s *eventStream) Reset() error {
+	err := s.Stream.Reset()
+	s.finish("reset")
+	return err
+}
+
+// finish reports stream_closed the first time it is called.
+func (s *eventStream) finish(end string) {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	bytesIn, bytesOut := s.bytesIn, s.bytesOut
+	s.mu.Unlock()
+
+	fields := s.fields()
+	fields["end"] = end
+	fields["bytes_in"] = bytesIn
+	fields["bytes_out"] = bytesOut
+	fields["duration_ms"] = eventMillis(time.Since(s.openedAt))
+	s.log.emit("stream_closed", fields)
+}
+
+func (s *eventStream) fields() eventFields {
+	return eventFields{
+		"conn":     s.Conn().ID(),
+		"peer":     s.Conn().RemotePeer().String(),
+		"stream":   s.ID(),
+		"protocol": string(s.Protocol()),
+		"dir":      direction(s.Stat().Direction),
+	}
+}
+
+func eventMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func direction(d network.Direction) string {
+	return strings.ToLower(d.String())
+}
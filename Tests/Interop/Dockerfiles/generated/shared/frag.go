@@ -0,0 +1,154 @@
+package main
+
+// /test/frag/1.0.0: a payload written in many small writes and closed by its
+// SHA-256, to exercise websocket framing and reassembly. A node copies this
+// file next to its main.go, registers handleFrag and runs fragSend for the
+// FRAG_SEND command.
+//
+// The sender writes the payload write_size bytes at a time, then the 32-byte
+// SHA-256 of the payload, and closes its write side. Each write travels as
+// its own muxer frame, Noise message and websocket message; with a small
+// WS_WRITE_BUFFER_BYTES (see wsbuffers.go) the larger ones are fragmented
+// further into continuation frames. The receiver reads to EOF, checks the
+// trailing digest against everything before it and answers "OK\n" or
+// "BAD\n".
+//
+//	FRAG_SEND <peerID> <total_bytes> <write_size>
+//
+// sends random bytes to a connected peer and prints
+//
+//	FRAG_SEND_OK: peer=<id> bytes=<n> write_size=<n> writes=<n> duration_ms=<d>
+//	FRAG_SEND_FAILED: peer=<id> err=<error>
+//
+// and every received stream prints
+//
+//	FRAG_RECV: peer=<id> bytes=<n> sha256_ok=<bool> duration_ms=<d>
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	fragProtocol = "/test/frag/1.0.0"
+
+	// maxFragBytes bounds a payload in either direction.
+	maxFragBytes = 64 << 20
+
+	// fragTimeout bounds one transfer; 1-byte writes of a large payload are
+	// slow by design.
+	fragTimeout = 5 * time.Minute
+)
+
+// parseFragArgs validates FRAG_SEND's byte counts.
+func parseFragArgs(totalArg, writeArg string) (int, int, error) {
+	total, err := strconv.Atoi(totalArg)
+	if err != nil || total < 0 || total > maxFragBytes {
+		return 0, 0, fmt.Errorf("total_bytes %q is not in 0...%d", totalArg, maxFragBytes)
+	}
+	writeSize, err := strconv.Atoi(writeArg)
+	if err != nil || writeSize <= 0 {
+		return 0, 0, fmt.Errorf("write_size %q is not a positive byte count", writeArg)
+	}
+	return total, writeSize, nil
+}
+
+// fragSend runs FRAG_SEND.
+func fragSend(h host.Host, arg string, total, writeSize int) {
+	p, err := peer.Decode(arg)
+	if err != nil {
+		fmt.Printf("FRAG_SEND_FAILED: peer=%s err=%v\n", arg, err)
+		return
+	}
+	fail := func(err error) {
+		fmt.Printf("FRAG_SEND_FAILED: peer=%s err=%v\n", p, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fragTimeout)
+	defer cancel()
+	s, err := h.NewStream(ctx, p, fragProtocol)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(fragTimeout))
+
+	payload := make([]byte, total)
+	rand.Read(payload)
+	sum := sha256.Sum256(payload)
+
+	start := time.Now()
+	writes := 0
+	for off := 0; off < total; off += writeSize {
+		if _, err := s.Write(payload[off:min(off+writeSize, total)]); err != nil {
+			s.Reset()
+			fail(fmt.Errorf("write %d: %w", writes, err))
+			return
+		}
+		writes++
+	}
+	if _, err := s.Write(sum[:]); err != nil {
+		s.Reset()
+		fail(fmt.Errorf("digest: %w", err))
+		return
+	}
+	if err := s.CloseWrite(); err != nil {
+		fail(err)
+		return
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(s, 16))
+	if err != nil {
+		fail(fmt.Errorf("reply: %w", err))
+		return
+	}
+	if string(reply) != "OK\n" {
+		fail(fmt.Errorf("receiver answered %q", reply))
+		return
+	}
+	fmt.Printf("FRAG_SEND_OK: peer=%s bytes=%d write_size=%d writes=%d duration_ms=%d\n",
+		p, total, writeSize, writes, time.Since(start).Milliseconds())
+}
+
+// handleFrag receives one payload and checks its trailing digest.
+func handleFrag(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(fragTimeout))
+	remote := s.Conn().RemotePeer()
+
+	start := time.Now()
+	data, err := io.ReadAll(io.LimitReader(s, maxFragBytes+sha256.Size+1))
+	if err != nil {
+		fmt.Printf("FRAG_RECV: peer=%s bytes=%d sha256_ok=false duration_ms=%d err=%v\n",
+			remote, len(data), time.Since(start).Milliseconds(), err)
+		s.Reset()
+		return
+	}
+
+	ok := false
+	payload := 0
+	if len(data) >= sha256.Size && len(data) <= maxFragBytes+sha256.Size {
+		payload = len(data) - sha256.Size
+		sum := sha256.Sum256(data[:payload])
+		ok = bytes.Equal(sum[:], data[payload:])
+	}
+	fmt.Printf("FRAG_RECV: peer=%s bytes=%d sha256_ok=%t duration_ms=%d\n",
+		remote, payload, ok, time.Since(start).Milliseconds())
+
+	if ok {
+		s.Write([]byte("OK\n"))
+	} else {
+		s.Write([]byte("BAD\n"))
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+// Plain HTTP and the libp2p WebSocket upgrade on one port, the way a load
+// balancer sees a real deployment. The ws and wss nodes copy this file next
+// to their main.go and use it when HTTP_FRONT=1.
+//
+// go-libp2p's websocket listener answers every request itself, so it cannot
+// share its port. Instead the libp2p listener moves to a loopback port and
+// an HTTP server takes the public one (terminating TLS for wss). WebSocket
+// upgrade requests are hijacked and replayed, request line and headers
+// included, to the libp2p listener; everything else is answered here: 200 on
+// /healthz, 404 elsewhere, each logged as
+//
+//	HTTP: <method> <path> <status>
+//
+// Inbound libp2p connections arrive from the front, so the node sees a
+// loopback remote address for them.
+//
+// The front also makes WebSocket handshakes visible. A request carrying any
+// WebSocket header is checked against RFC 6455 before it is forwarded; a bad
+// one is answered here and logged as
+//
+//	WS_UPGRADE_FAILED: remote=<addr> path=<path> status=<code> err=<reason>
+//
+// (bad method, bad Connection header, bad Upgrade header, bad version,
+// missing key, bad key, or the libp2p listener's own refusal). A completed
+// upgrade logs WS_UPGRADE: remote= path= subprotocol=<name|none> active=<n>.
+// With MAX_WS_CONNS=<n> (which turns the front on by itself) an upgrade past
+// n concurrent connections is refused with 503 and
+// WS_REJECTED: reason=limit remote= active= max=.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	// frontListenAddr is where the libp2p listener moves behind the front.
+	frontListenAddr = "/ip4/127.0.0.1/tcp/0/ws"
+
+	healthPath = "/healthz"
+)
+
+// frontServer is the running front, nil until serveHTTPFront succeeds.
+// Closing it stops new connections; forwarded ones are not affected.
+var frontServer *http.Server
+
+// httpFront serves the public port, forwarding upgrades to backend.
+type httpFront struct {
+	backend string
+	// maxConns caps concurrent forwarded connections; 0 is unlimited
+	maxConns int64
+	active   atomic.Int64
+}
+
+// frontEnabled reports whether the node should put the front on its port:
+// HTTP_FRONT=1, or a MAX_WS_CONNS limit that only the front can enforce.
+func frontEnabled() bool {
+	return os.Getenv("HTTP_FRONT") == "1" || os.Getenv("MAX_WS_CONNS") != ""
+}
+
+// maxWSConns reads MAX_WS_CONNS, 0 when unset.
+func maxWSConns() (int64, error) {
+	v := os.Getenv("MAX_WS_CONNS")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("MAX_WS_CONNS=%q is not a positive count", v)
+	}
+	return n, nil
+}
+
+func (f *httpFront) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebSocketAttempt(r) {
+		if status, err := checkUpgrade(r); err != nil {
+			if status == http.StatusUpgradeRequired {
+				w.Header().Set("Sec-WebSocket-Version", "13")
+			}
+			http.Error(w, err.Error(), status)
+			f.logFailed(r, status, err)
+			return
+		}
+		if !f.acquire() {
+			http.Error(w, "too many websocket connections", http.StatusServiceUnavailable)
+			fmt.Printf("WS_REJECTED: reason=limit remote=%s active=%d max=%d\n", r.RemoteAddr, f.active.Load(), f.maxConns)
+			return
+		}
+		defer f.active.Add(-1)
+		f.forward(w, r)
+		return
+	}
+
+	status := http.StatusNotFound
+	if r.URL.Path == healthPath {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, http.StatusText(status))
+	fmt.Printf("HTTP: %s %s %d\n", r.Method, r.URL.Path, status)
+}
+
+// acquire takes a connection slot, false when MAX_WS_CONNS are in use.
+func (f *httpFront) acquire() bool {
+	for {
+		n := f.active.Load()
+		if f.maxConns > 0 && n >= f.maxConns {
+			return false
+		}
+		if f.active.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (f *httpFront) logFailed(r *http.Request, status int, err error) {
+	fmt.Printf("WS_UPGRADE_FAILED: remote=%s path=%s status=%d err=%v\n", r.RemoteAddr, r.URL.Path, status, err)
+}
+
+// forward hands the connection to the libp2p listener: the request is
+// written to it as received, its response is relayed, then bytes are copied
+// both ways until either side closes.
+func (f *httpFront) forward(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "upgrade not supported", http.StatusInternalServerError)
+		f.logFailed(r, http.StatusInternalServerError, errors.New("connection cannot be hijacked"))
+		return
+	}
+	backend, err := net.Dial("tcp", f.backend)
+	if err != nil {
+		http.Error(w, "libp2p listener unavailable", http.StatusBadGateway)
+		f.logFailed(r, http.StatusBadGateway, err)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		fmt.Printf("HTTP_FRONT_FAILED: err=%v\n", err)
+		backend.Close()
+		return
+	}
+	defer conn.Close()
+	defer backend.Close()
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		r.Header.Set("X-Forwarded-For", host)
+	}
+	if err := r.Write(backend); err != nil {
+		f.logFailed(r, http.StatusBadGateway, err)
+		return
+	}
+
+	// Relay the listener's response head verbatim, reading it to learn the
+	// status and the subprotocol it selected
+	reply := bufio.NewReader(backend)
+	head, err := readResponseHead(reply)
+	if err != nil {
+		f.logFailed(r, http.StatusBadGateway, err)
+		return
+	}
+	if _, err := conn.Write(head); err != nil {
+		return
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(head)), r)
+	if err != nil {
+		f.logFailed(r, http.StatusBadGateway, err)
+		return
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		f.logFailed(r, resp.StatusCode, errors.New("refused by the libp2p listener"))
+		io.Copy(conn, reply)
+		return
+	}
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol == "" {
+		subprotocol = "none"
+	}
+	fmt.Printf("WS_UPGRADE: remote=%s path=%s subprotocol=%s active=%d\n", r.RemoteAddr, r.URL.Path, subprotocol, f.active.Load())
+
+	// The reader may already hold bytes the client sent after its request
+	go func() {
+		io.Copy(backend, io.MultiReader(buffered.Reader, conn))
+		if tcp, ok := backend.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}()
+	io.Copy(conn, reply)
+}
+
+// readResponseHead reads a response's status line and headers, up to and
+// including the blank line.
+func readResponseHead(r *bufio.Reader) ([]byte, error) {
+	var head []byte
+	for len(head) < 64<<10 {
+		line, err := r.ReadSlice('\n')
+		head = append(head, line...)
+		if err != nil {
+			return nil, fmt.Errorf("reading response head: %w", err)
+		}
+		if len(line) <= 2 && strings.TrimRight(string(line), "\r\n") == "" {
+			return head, nil
+		}
+	}
+	return nil, errors.New("response head too large")
+}
+
+// isWebSocketAttempt reports whether r means to open a WebSocket, valid or
+// not: anything carrying an Upgrade or Sec-WebSocket-* header.
+func isWebSocketAttempt(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" {
+		return true
+	}
+	for name := range r.Header {
+		if strings.HasPrefix(name, "Sec-Websocket-") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUpgrade validates an opening handshake (RFC 6455 section 4.2.1) and
+// returns the status to refuse it with.
+func checkUpgrade(r *http.Request) (int, error) {
+	if r.Method != http.MethodGet {
+		return http.StatusMethodNotAllowed, fmt.Errorf("bad method %s", r.Method)
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") {
+		return http.StatusBadRequest, fmt.Errorf("bad Connection header %q", r.Header.Get("Connection"))
+	}
+	if !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return http.StatusBadRequest, fmt.Errorf("bad Upgrade header %q", r.Header.Get("Upgrade"))
+	}
+	if v := r.Header.Get("Sec-WebSocket-Version"); v != "13" {
+		return http.StatusUpgradeRequired, fmt.Errorf("bad version %q", v)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return http.StatusBadRequest, errors.New("missing key")
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return http.StatusBadRequest, fmt.Errorf("bad key %q", key)
+	}
+	return 0, nil
+}
+
+// headerHasToken reports whether any comma-separated value of header name
+// equals token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveHTTPFront starts the front on port, serving TLS when tlsConfig is
+// set and limiting upgrades to MAX_WS_CONNS. It returns once the port is
+// bound.
+func serveHTTPFront(port int, backend string, tlsConfig *tls.Config) error {
+	maxConns, err := maxWSConns()
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:   &httpFront{backend: backend, maxConns: maxConns},
+		TLSConfig: tlsConfig,
+		// WebSocket upgrades need HTTP/1.1, so never offer h2
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+	}
+	go func() {
+		var serveErr error
+		if tlsConfig != nil {
+			serveErr = srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr = srv.Serve(ln)
+		}
+		if !errors.Is(serveErr, http.ErrServerClosed) {
+			fmt.Printf("HTTP_FRONT_FAILED: err=%v\n", serveErr)
+		}
+	}()
+	frontServer = srv
+	return nil
+}
+
+// frontBackend is the host:port of the loopback libp2p listener.
+func frontBackend(h host.Host) (string, error) {
+	for _, addr := range h.Network().ListenAddresses() {
+		port, err := addr.ValueForProtocol(multiaddr.P_TCP)
+		if err == nil {
+			return net.JoinHostPort("127.0.0.1", port), nil
+		}
+	}
+	return "", fmt.Errorf("no tcp listen address in %v", h.Network().ListenAddresses())
+}
+
+// frontAddrs are the addresses to advertise instead of the loopback
+// listener: every IPv4 interface on the public port, ending in suffix
+// (/ws or /tls/ws).
+func frontAddrs(port int, suffix string) []multiaddr.Multiaddr {
+	ifaces, err := manet.InterfaceMultiaddrs()
+	if err != nil {
+		fmt.Printf("HTTP_FRONT_FAILED: err=%v\n", err)
+		return nil
+	}
+	var addrs []multiaddr.Multiaddr
+	for _, iface := range ifaces {
+		ip, err := iface.ValueForProtocol(multiaddr.P_IP4)
+		if err != nil {
+			continue
+		}
+		addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d%s", ip, port, suffix))
+		if err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
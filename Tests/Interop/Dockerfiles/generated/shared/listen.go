@@ -0,0 +1,96 @@
+package main
+
+// Listen address selection and connection logging for the ws and wss nodes.
+// A node copies this file next to its main.go.
+//
+// LISTEN_ADDRS is a comma-separated list of multiaddrs to listen on, e.g.
+// "/ip6/::/tcp/4001/ws,/ip4/0.0.0.0/tcp/4001/ws"; unset, the node listens on
+// /ip4/0.0.0.0/tcp/$LISTEN_PORT/<ws|wss> as before. After startup every
+// socket the node actually bound is printed as
+//
+//	Bound: <multiaddr> family=<ip4|ip6>
+//
+// with the concrete port when /tcp/0 was requested. Every connection opened
+// or closed is printed as
+//
+//	CONN: dir=<inbound|outbound> family=<ip4|ip6|dns> remote=<multiaddr> peer=<id>
+//	DISCONN: dir=<inbound|outbound> family=<ip4|ip6|dns> remote=<multiaddr> peer=<id>
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// listenAddrs returns LISTEN_ADDRS, or the v4 wildcard on port with the
+// given websocket suffix (/ws or /wss) when it is unset.
+func listenAddrs(port int, suffix string) ([]string, error) {
+	v := os.Getenv("LISTEN_ADDRS")
+	if v == "" {
+		return []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d%s", port, suffix)}, nil
+	}
+	var addrs []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := multiaddr.NewMultiaddr(s); err != nil {
+			return nil, fmt.Errorf("LISTEN_ADDRS entry %q: %w", s, err)
+		}
+		addrs = append(addrs, s)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("LISTEN_ADDRS=%q names no address", v)
+	}
+	return addrs, nil
+}
+
+// printBoundAddrs prints one Bound line per listener.
+func printBoundAddrs(h host.Host) {
+	for _, addr := range h.Network().ListenAddresses() {
+		fmt.Printf("Bound: %s family=%s\n", addr, addrFamily(addr))
+	}
+}
+
+// logConnections prints CONN and DISCONN lines for every connection.
+func logConnections(h host.Host) {
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			printConn("CONN", c)
+		},
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			printConn("DISCONN", c)
+		},
+	})
+}
+
+func printConn(key string, c network.Conn) {
+	dir := "inbound"
+	if c.Stat().Direction == network.DirOutbound {
+		dir = "outbound"
+	}
+	remote := c.RemoteMultiaddr()
+	fmt.Printf("%s: dir=%s family=%s remote=%s peer=%s\n", key, dir, addrFamily(remote), remote, c.RemotePeer())
+}
+
+// addrFamily names the network-layer protocol addr starts with.
+func addrFamily(addr multiaddr.Multiaddr) string {
+	protocols := addr.Protocols()
+	if len(protocols) == 0 {
+		return "unknown"
+	}
+	switch protocols[0].Code {
+	case multiaddr.P_IP4:
+		return "ip4"
+	case multiaddr.P_IP6:
+		return "ip6"
+	case multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6:
+		return "dns"
+	}
+	return "unknown"
+}
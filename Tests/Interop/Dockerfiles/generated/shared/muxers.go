@@ -0,0 +1,116 @@
+package main
+
+// Muxer selection and stream listing for the ws and wss nodes. A node copies
+// this file next to its main.go, adds the options from loadMuxers to its host
+// and calls logMuxers once the host exists.
+//
+// MUXERS is an ordered comma-separated list of yamux and mplex (default
+// yamux), the same convention as the noise node; the muxers are offered in
+// that order and printed at startup as
+//
+//	MUXER_ORDER: <ids>
+//
+// Every connection prints the muxer it negotiated,
+//
+//	MUXER: peer=<id> remote=<multiaddr> muxer=<id>
+//
+// and the stdin command "STREAMS <peerID>" lists the streams currently open
+// to that peer, one line each, then a summary:
+//
+//	STREAM: peer=<id> muxer=<id> dir=<inbound|outbound> protocol=<id|none> opened_ms=<n>
+//	STREAMS_END: peer=<id> conns=<n> count=<n>
+//
+// opened_ms is the stream's age.
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+
+	mplex "github.com/libp2p/go-libp2p-mplex"
+)
+
+// wsMuxers maps MUXERS entries to their protocol ID and transport.
+var wsMuxers = map[string]struct {
+	id        protocol.ID
+	transport network.Multiplexer
+}{
+	"yamux": {yamux.ID, yamux.DefaultTransport},
+	"mplex": {mplex.ID, mplex.DefaultTransport},
+}
+
+// loadMuxers reads MUXERS and returns the host options and protocol IDs in
+// offer order.
+func loadMuxers() ([]libp2p.Option, []string, error) {
+	value := os.Getenv("MUXERS")
+	if value == "" {
+		value = "yamux"
+	}
+
+	var options []libp2p.Option
+	var ids []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		muxer, ok := wsMuxers[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("MUXERS: unknown muxer %q (want yamux or mplex)", name)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("MUXERS: %s listed twice", name)
+		}
+		seen[name] = true
+		options = append(options, libp2p.Muxer(string(muxer.id), muxer.transport))
+		ids = append(ids, string(muxer.id))
+	}
+	return options, ids, nil
+}
+
+// logMuxers prints MUXER_ORDER and a MUXER line for every connection.
+func logMuxers(h host.Host, ids []string) {
+	fmt.Printf("MUXER_ORDER: %s\n", strings.Join(ids, ","))
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			fmt.Printf("MUXER: peer=%s remote=%s muxer=%s\n",
+				c.RemotePeer(), c.RemoteMultiaddr(), c.ConnState().StreamMultiplexer)
+		},
+	})
+}
+
+// listStreams runs STREAMS for the peer ID in arg.
+func listStreams(h host.Host, arg string) {
+	p, err := peer.Decode(arg)
+	if err != nil {
+		fmt.Printf("STREAMS_FAILED: peer=%s err=%v\n", arg, err)
+		return
+	}
+
+	conns := h.Network().ConnsToPeer(p)
+	count := 0
+	for _, c := range conns {
+		muxer := c.ConnState().StreamMultiplexer
+		for _, s := range c.GetStreams() {
+			stat := s.Stat()
+			dir := "inbound"
+			if stat.Direction == network.DirOutbound {
+				dir = "outbound"
+			}
+			proto := string(s.Protocol())
+			if proto == "" {
+				proto = "none"
+			}
+			fmt.Printf("STREAM: peer=%s muxer=%s dir=%s protocol=%s opened_ms=%d\n",
+				p, muxer, dir, proto, time.Since(stat.Opened).Milliseconds())
+			count++
+		}
+	}
+	fmt.Printf("STREAMS_END: peer=%s conns=%d count=%d\n", p, len(conns), count)
+}
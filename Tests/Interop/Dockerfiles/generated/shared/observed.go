@@ -0,0 +1,113 @@
+package main
+
+// Identify observed-address reporting. A node copies this file next to its
+// main.go, calls logObserved once the host is up and runs printObserved for
+// the OBSERVED command.
+//
+// Identify tells each peer the address its connection came from, so a peer
+// behind NAT learns its public endpoint, and each peer tells this node the
+// same. Once identify with a peer completes, the node prints
+//
+//	OBSERVED_SENT: peer=<id> addr=<multiaddr>
+//	OBSERVED_RECV: addr=<multiaddr> peer=<id>
+//
+// SENT is the connection's remote address, which go-libp2p puts in every
+// identify response and push on it; RECV is what the peer reported for this
+// node, and is skipped when the peer sent none. The OBSERVED command lists
+// the addresses reported over connections that are still open as
+//
+//	OBSERVED: addr=<multiaddr> peers=<n> active=<bool>
+//	OBSERVED_END: count=<n>
+//
+// active is whether go-libp2p's observed address manager, which wants
+// several distinct observers, has promoted the address yet.
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// observedAddrs holds the self address each open connection's peer reported.
+type observedAddrs struct {
+	mu     sync.Mutex
+	byConn map[network.Conn]ma.Multiaddr
+}
+
+// logObserved prints OBSERVED_SENT / OBSERVED_RECV for every completed
+// identify and returns the set the OBSERVED command reads.
+func logObserved(h host.Host) (*observedAddrs, error) {
+	sub, err := h.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return nil, err
+	}
+	o := &observedAddrs{byConn: make(map[network.Conn]ma.Multiaddr)}
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			o.mu.Lock()
+			delete(o.byConn, c)
+			o.mu.Unlock()
+		},
+	})
+	go func() {
+		for e := range sub.Out() {
+			evt := e.(event.EvtPeerIdentificationCompleted)
+			if evt.Conn == nil {
+				continue
+			}
+			fmt.Printf("OBSERVED_SENT: peer=%s addr=%s\n", evt.Peer, evt.Conn.RemoteMultiaddr())
+			if evt.ObservedAddr == nil {
+				continue
+			}
+			fmt.Printf("OBSERVED_RECV: addr=%s peer=%s\n", evt.ObservedAddr, evt.Peer)
+			if evt.Conn.IsClosed() {
+				continue
+			}
+			o.mu.Lock()
+			o.byConn[evt.Conn] = evt.ObservedAddr
+			o.mu.Unlock()
+		}
+	}()
+	return o, nil
+}
+
+// printObserved runs OBSERVED.
+func (o *observedAddrs) printObserved(h host.Host) {
+	peers := make(map[string]map[peer.ID]struct{})
+	o.mu.Lock()
+	for c, addr := range o.byConn {
+		key := addr.String()
+		if peers[key] == nil {
+			peers[key] = make(map[peer.ID]struct{})
+		}
+		peers[key][c.RemotePeer()] = struct{}{}
+	}
+	o.mu.Unlock()
+
+	active := make(map[string]bool)
+	if ids, ok := h.(interface{ IDService() identify.IDService }); ok {
+		for _, addr := range ids.IDService().OwnObservedAddrs() {
+			active[addr.String()] = true
+		}
+	} else {
+		log.Printf("host has no identify service; active is always false")
+	}
+
+	addrs := make([]string, 0, len(peers))
+	for addr := range peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Printf("OBSERVED: addr=%s peers=%d active=%t\n", addr, len(peers[addr]), active[addr])
+	}
+	fmt.Printf("OBSERVED_END: count=%d\n", len(addrs))
+}
@@ -0,0 +1,171 @@
+package main
+
+// The libp2p perf protocol (/perf/1.0.0, see libp2p/specs perf/perf.md),
+// shared by the go test nodes. A node copies this file next to its main.go;
+// the ws node always serves it, the others when PERF=1.
+//
+// The client opens a stream, sends the number of bytes it wants back as an
+// 8-byte big-endian integer, uploads its payload and closes its write side.
+// The server reads until EOF, then sends the requested number of bytes and
+// closes. Each served stream prints
+//
+//	PERF: peer=<id> up_bytes=<a> down_bytes=<b> up_mbps=<x> down_mbps=<y> duration_ms=<d>
+//
+// where up is client-to-server. The stdin command
+// "PERF <multiaddr> <upload_bytes> <download_bytes>" runs the client side
+// through perfClient and prints PERF_RESULT with the same fields, or
+// PERF_FAILED: stage=connect|stream-open|upload|download addr=<multiaddr>
+// err=<error>.
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	perfProtocol = "/perf/1.0.0"
+
+	// perfBlockSize is the write and read chunk on both sides.
+	perfBlockSize = 64 << 10
+
+	// perfTimeout bounds one client run, connect included.
+	perfTimeout = 5 * time.Minute
+)
+
+// handlePerf serves one perf stream.
+func handlePerf(s network.Stream) {
+	defer s.Close()
+	p := s.Conn().RemotePeer()
+	fail := func(err error) {
+		fmt.Printf("PERF_FAILED: stage=serve peer=%s err=%v\n", p, err)
+		s.Reset()
+	}
+
+	start := time.Now()
+	var header [8]byte
+	if _, err := io.ReadFull(s, header[:]); err != nil {
+		fail(fmt.Errorf("length header: %w", err))
+		return
+	}
+	down := binary.BigEndian.Uint64(header[:])
+	up, err := io.CopyBuffer(io.Discard, s, make([]byte, perfBlockSize))
+	if err != nil {
+		fail(err)
+		return
+	}
+	uploaded := time.Now()
+
+	if err := writePerfBytes(s, down); err != nil {
+		fail(err)
+		return
+	}
+	if err := s.CloseWrite(); err != nil {
+		fail(err)
+		return
+	}
+	end := time.Now()
+	printPerf("PERF", p, uint64(up), down, uploaded.Sub(start), end.Sub(uploaded), end.Sub(start))
+}
+
+// perfClient runs the perf protocol against addr, uploading upload bytes and
+// asking for download bytes back.
+func perfClient(h host.Host, addr string, upload, download uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), perfTimeout)
+	defer cancel()
+	fail := func(stage string, err error) {
+		fmt.Printf("PERF_FAILED: stage=%s addr=%s err=%v\n", stage, addr, err)
+	}
+
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		fail("connect", err)
+		return
+	}
+	if err := h.Connect(ctx, *info); err != nil {
+		fail("connect", err)
+		return
+	}
+	s, err := h.NewStream(ctx, info.ID, perfProtocol)
+	if err != nil {
+		fail("stream-open", err)
+		return
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	start := time.Now()
+	if _, err := s.Write(binary.BigEndian.AppendUint64(nil, download)); err != nil {
+		fail("upload", err)
+		return
+	}
+	if err := writePerfBytes(s, upload); err != nil {
+		fail("upload", err)
+		return
+	}
+	if err := s.CloseWrite(); err != nil {
+		fail("upload", err)
+		return
+	}
+	uploaded := time.Now()
+
+	n, err := io.CopyBuffer(io.Discard, s, make([]byte, perfBlockSize))
+	if err != nil {
+		fail("download", err)
+		return
+	}
+	if uint64(n) != download {
+		fail("download", fmt.Errorf("received %d of %d bytes", n, download))
+		return
+	}
+	end := time.Now()
+	printPerf("PERF_RESULT", info.ID, upload, download, uploaded.Sub(start), end.Sub(uploaded), end.Sub(start))
+}
+
+// parsePerfArgs reads the byte counts of a PERF command.
+func parsePerfArgs(upload, download string) (uint64, uint64, error) {
+	up, err := strconv.ParseUint(upload, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("upload_bytes: %w", err)
+	}
+	down, err := strconv.ParseUint(download, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("download_bytes: %w", err)
+	}
+	return up, down, nil
+}
+
+// writePerfBytes sends n zero bytes in perfBlockSize writes.
+func writePerfBytes(w io.Writer, n uint64) error {
+	block := make([]byte, perfBlockSize)
+	for n > 0 {
+		chunk := block[:min(uint64(len(block)), n)]
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		n -= uint64(len(chunk))
+	}
+	return nil
+}
+
+func printPerf(key string, p peer.ID, up, down uint64, upTime, downTime, total time.Duration) {
+	fmt.Printf("%s: peer=%s up_bytes=%d down_bytes=%d up_mbps=%.2f down_mbps=%.2f duration_ms=%d\n",
+		key, p, up, down, perfMbps(up, upTime), perfMbps(down, downTime), total.Milliseconds())
+}
+
+// perfMbps is the throughput in megabits per second.
+func perfMbps(bytes uint64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / 1e6 / d.Seconds()
+}
@@ -0,0 +1,282 @@
+package main
+
+// Outbound proxying for the ws node's websocket dials. A node copies this
+// file next to its main.go and calls configureProxy before it dials.
+//
+// PROXY_URL=http://[user:pass@]host:port or socks5://[user:pass@]host:port
+// sends every outbound websocket dial (DIAL, PING, PERF) through that proxy,
+// authenticating with the userinfo if there is one (Basic for http,
+// username/password for socks5). The node's websocket dialer (wsbuffers.go)
+// takes its proxy from gorilla's websocket.DefaultDialer.Proxy, so the node
+// points that at a loopback HTTP CONNECT relay of its own, which does the handshake with
+// the configured proxy, times it and then splices the two connections. Each
+// dial prints
+//
+//	PROXY_DIAL: used=true scheme=<http|socks5> proxy=<host:port> target=<host:port> handshake_ms=<x> result=<ok|err> [err=<error>]
+//
+// where handshake_ms covers the TCP connection to the proxy, authentication
+// and the proxy's own connection to the target. Without PROXY_URL dials go
+// direct and print PROXY_DIAL: used=false target=<host:port>.
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// proxyHandshakeTimeout bounds the connection to the proxy and its handshake.
+const proxyHandshakeTimeout = 10 * time.Second
+
+// configureProxy reads PROXY_URL and installs the dial hook.
+func configureProxy() error {
+	v := os.Getenv("PROXY_URL")
+	if v == "" {
+		ws.DefaultDialer.Proxy = func(req *http.Request) (*url.URL, error) {
+			fmt.Printf("PROXY_DIAL: used=false target=%s\n", req.URL.Host)
+			return nil, nil
+		}
+		return nil
+	}
+
+	proxyURL, err := url.Parse(v)
+	if err != nil {
+		return fmt.Errorf("PROXY_URL: %w", err)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "socks5" {
+		return fmt.Errorf("PROXY_URL scheme %q is not http or socks5", proxyURL.Scheme)
+	}
+	if proxyURL.Port() == "" {
+		return fmt.Errorf("PROXY_URL=%q has no port", proxyURL.Redacted())
+	}
+
+	relay, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("proxy relay: %w", err)
+	}
+	go serveProxyRelay(relay, proxyURL)
+	relayURL := &url.URL{Scheme: "http", Host: relay.Addr().String()}
+	ws.DefaultDialer.Proxy = func(*http.Request) (*url.URL, error) {
+		return relayURL, nil
+	}
+	log.Printf("Proxy: %s", proxyURL.Redacted())
+	return nil
+}
+
+// serveProxyRelay answers the websocket dialer's CONNECT requests by
+// connecting through proxyURL.
+func serveProxyRelay(l net.Listener, proxyURL *url.URL) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go relayConnect(c, proxyURL)
+	}
+}
+
+func relayConnect(c net.Conn, proxyURL *url.URL) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		io.WriteString(c, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+
+	target := req.Host
+	start := time.Now()
+	upstream, err := dialThroughProxy(proxyURL, target)
+	elapsed := milliseconds(time.Since(start))
+	if err != nil {
+		fmt.Printf("PROXY_DIAL: used=true scheme=%s proxy=%s target=%s handshake_ms=%.3f result=err err=%v\n",
+			proxyURL.Scheme, proxyURL.Host, target, elapsed, err)
+		io.WriteString(c, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	fmt.Printf("PROXY_DIAL: used=true scheme=%s proxy=%s target=%s handshake_ms=%.3f result=ok\n",
+		proxyURL.Scheme, proxyURL.Host, target, elapsed)
+
+	if _, err := io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, br)
+		if tc, ok := upstream.(interface{ CloseWrite() error }); ok {
+			tc.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(c, upstream)
+	c.Close()
+	<-done
+}
+
+// dialThroughProxy opens a connection to target through proxyURL.
+func dialThroughProxy(proxyURL *url.URL, target string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", proxyURL.Host, proxyHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(proxyHandshakeTimeout))
+
+	var tunnel net.Conn
+	switch proxyURL.Scheme {
+	case "socks5":
+		tunnel, err = socks5Connect(conn, proxyURL.User, target)
+	default:
+		tunnel, err = httpConnect(conn, proxyURL.User, target)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// httpConnect asks an HTTP proxy for a tunnel to target.
+func httpConnect(conn net.Conn, user *url.Userinfo, target string) (net.Conn, error) {
+	req := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy answered %s", resp.Status)
+	}
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// socks5Connect runs the SOCKS5 handshake (RFC 1928, with RFC 1929
+// username/password authentication when user is set) for a CONNECT to target.
+func socks5Connect(conn net.Conn, user *url.Userinfo, target string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("target port %q: %w", portStr, err)
+	}
+
+	method := byte(0x00)
+	if user != nil {
+		method = 0x02
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != 0x05 || reply[1] != method {
+		return nil, fmt.Errorf("socks5 proxy refused auth method %d", method)
+	}
+
+	if user != nil {
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return nil, errors.New("socks5 credentials longer than 255 bytes")
+		}
+		auth := []byte{0x01, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0x00 {
+			return nil, errors.New("socks5 authentication failed")
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name %q too long", host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+	if head[1] != 0x00 {
+		return nil, fmt.Errorf("socks5 connect failed with reply %d", head[1])
+	}
+	var bound int
+	switch head[3] {
+	case 0x01:
+		bound = net.IPv4len
+	case 0x04:
+		bound = net.IPv6len
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return nil, err
+		}
+		bound = int(n[0])
+	default:
+		return nil, fmt.Errorf("socks5 reply address type %d", head[3])
+	}
+	// Bound address and port, unused
+	if _, err := io.ReadFull(conn, make([]byte, bound+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// bufferedConn reads through r, which may hold bytes read past a proxy's
+// response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
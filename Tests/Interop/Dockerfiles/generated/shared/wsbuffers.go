@@ -0,0 +1,177 @@
+package main
+
+// A websocket transport whose outbound connections use a gorilla dialer the
+// node configures. A node copies this file next to its main.go and passes
+// newBufferedWSTransport to libp2p.Transport in place of websocket.New.
+//
+// go-libp2p's transport builds its dialer internally, with gorilla's default
+// 4 KiB buffers and no proxy. This one embeds it for listening and resolving
+// and replaces Dial. WS_READ_BUFFER_BYTES and WS_WRITE_BUFFER_BYTES set the
+// dialer's buffers, printed at startup as
+//
+//	WS_BUFFERS: read=<n> write=<n>
+//
+// The write buffer bounds a frame: gorilla writes a message larger than it as
+// a first frame plus continuation frames, so a small value fragments every
+// libp2p write on dialed connections. Accepted connections keep go-libp2p's
+// upgrader, and as a server gorilla writes each message as a single frame
+// whatever its buffer, so the settings only shape what this node sends as a
+// dialer. Dials take their proxy from gorilla's DefaultDialer.Proxy (see
+// proxy.go).
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// defaultWSBufferSize is gorilla's own default for both buffers.
+const defaultWSBufferSize = 4096
+
+// bufferedWSTransport is go-libp2p's websocket transport with its own dialer.
+type bufferedWSTransport struct {
+	*websocket.WebsocketTransport
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+	dialer   ws.Dialer
+}
+
+var _ transport.Transport = (*bufferedWSTransport)(nil)
+
+// newBufferedWSTransport reads WS_READ_BUFFER_BYTES and WS_WRITE_BUFFER_BYTES
+// and returns a transport constructor for libp2p.Transport.
+func newBufferedWSTransport(tlsClient *tls.Config) (func(transport.Upgrader, network.ResourceManager) (*bufferedWSTransport, error), error) {
+	read, err := wsBufferSize("WS_READ_BUFFER_BYTES")
+	if err != nil {
+		return nil, err
+	}
+	write, err := wsBufferSize("WS_WRITE_BUFFER_BYTES")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("WS_BUFFERS: read=%d write=%d\n", read, write)
+
+	return func(u transport.Upgrader, rcmgr network.ResourceManager) (*bufferedWSTransport, error) {
+		inner, err := websocket.New(u, rcmgr, websocket.WithTLSClientConfig(tlsClient))
+		if err != nil {
+			return nil, err
+		}
+		if rcmgr == nil {
+			rcmgr = &network.NullResourceManager{}
+		}
+		return &bufferedWSTransport{
+			WebsocketTransport: inner,
+			upgrader:           u,
+			rcmgr:              rcmgr,
+			dialer: ws.Dialer{
+				HandshakeTimeout: 30 * time.Second,
+				TLSClientConfig:  tlsClient,
+				ReadBufferSize:   read,
+				WriteBufferSize:  write,
+			},
+		}, nil
+	}, nil
+}
+
+// wsBufferSize reads a positive byte count from the environment variable
+// name, defaulting to gorilla's 4096.
+func wsBufferSize(name string) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return defaultWSBufferSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s=%q is not a positive byte count", name, v)
+	}
+	return n, nil
+}
+
+// Dial connects with the configured dialer and upgrades the connection.
+func (t *bufferedWSTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	scope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.dial(ctx, raddr, p, scope)
+	if err != nil {
+		scope.Done()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *bufferedWSTransport) dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID, scope network.ConnManagementScope) (transport.CapableConn, error) {
+	u, sni, err := wsDialURL(raddr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := t.dialer
+	dialer.Proxy = ws.DefaultDialer.Proxy
+	if sni != "" {
+		// Resolved /dns addresses keep their name as /sni; dial the IP and
+		// present the name
+		config := &tls.Config{}
+		if dialer.TLSClientConfig != nil {
+			config = dialer.TLSClientConfig.Clone()
+		}
+		config.ServerName = sni
+		dialer.TLSClientConfig = config
+	}
+
+	raw, _, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := manet.WrapNetConn(websocket.NewConn(raw, u.Scheme == "wss"))
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return t.upgrader.Upgrade(ctx, t, conn, network.DirOutbound, p, scope)
+}
+
+// wsDialURL turns .../ws, .../wss or .../tls[/sni/<name>]/ws into the URL
+// to dial and the TLS server name, if the address carries one.
+func wsDialURL(a ma.Multiaddr) (*url.URL, string, error) {
+	rest, last := ma.SplitLast(a)
+	if last == nil {
+		return nil, "", fmt.Errorf("%s is not a websocket address", a)
+	}
+	scheme := "ws"
+	switch last.Protocol().Code {
+	case ma.P_WS:
+	case ma.P_WSS:
+		scheme = "wss"
+	default:
+		return nil, "", fmt.Errorf("%s is not a websocket address", a)
+	}
+
+	sni := ""
+	if before, c := ma.SplitLast(rest); c != nil && c.Protocol().Code == ma.P_SNI {
+		sni = c.Value()
+		rest = before
+	}
+	if before, c := ma.SplitLast(rest); c != nil && c.Protocol().Code == ma.P_TLS {
+		scheme = "wss"
+		rest = before
+	}
+
+	_, host, err := manet.DialArgs(rest)
+	if err != nil {
+		return nil, "", err
+	}
+	return &url.URL{Scheme: scheme, Host: host}, sni, nil
+}
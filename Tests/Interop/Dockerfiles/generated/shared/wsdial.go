@@ -0,0 +1,221 @@
+package main
+
+// Outbound checks shared by the go-libp2p WebSocket test nodes (ws and wss).
+// A node copies this file next to its main.go, builds its websocket
+// transport with websocket.WithTLSClientConfig(dialTLSConfig()) and passes
+// the stdin commands "DIAL <multiaddr>" and "PING <multiaddr> [count]" to
+// dialEcho and pingPeer.
+//
+// Both commands take a /ws or /wss multiaddr with a /p2p component and
+// connect through the same stages, each printed as it completes:
+//
+//	<CMD>_STAGE: stage=ws-connect|security|muxer|identify result=ok
+//
+// The first stage that fails prints
+//
+//	<CMD>_FAILED: stage=<stage> addr=<multiaddr> err=<error>
+//
+// and nothing after it runs. ws-connect covers TCP, TLS for /wss and the
+// HTTP upgrade; security and muxer are the libp2p upgrade steps. DIAL then
+// round-trips a 64KB payload over /test/echo/1.0.0 and prints
+// "DIAL_STAGE: stage=echo result=ok" and "DIAL_ECHO_OK: peer=<id>
+// bytes=<n> rtt_ms=<x>", or a DIAL_FAILED line with stage=echo. PING sends
+// count probes (default 3), printing PING_RTT or PING_FAILED per probe and
+// one PING_DONE.
+//
+// INSECURE_SKIP_VERIFY=1 turns off verification of /wss certificates so
+// peers with self-signed ones can be dialed.
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+const (
+	echoProtocol = "/test/echo/1.0.0"
+
+	// dialTimeout bounds a whole DIAL command; pingTimeout bounds one probe.
+	dialTimeout = 30 * time.Second
+	pingTimeout = 10 * time.Second
+
+	echoPayloadSize = 64 << 10
+)
+
+// dialTLSConfig is the client TLS configuration for /wss dials.
+func dialTLSConfig() *tls.Config {
+	return &tls.Config{InsecureSkipVerify: os.Getenv("INSECURE_SKIP_VERIFY") == "1"}
+}
+
+// connectStaged connects to addr and waits for identify, reporting each
+// stage under command (DIAL or PING). It returns false once a stage failed.
+func connectStaged(ctx context.Context, h host.Host, command, addr string) (peer.ID, bool) {
+	fail := func(stage string, err error) {
+		fmt.Printf("%s_FAILED: stage=%s addr=%s err=%v\n", command, stage, addr, err)
+	}
+	passed := func(stages ...string) {
+		for _, stage := range stages {
+			fmt.Printf("%s_STAGE: stage=%s result=ok\n", command, stage)
+		}
+	}
+
+	info, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		fail("ws-connect", err)
+		return "", false
+	}
+	if err := h.Connect(ctx, *info); err != nil {
+		stage := connectStage(err)
+		switch stage {
+		case "security":
+			passed("ws-connect")
+		case "muxer":
+			passed("ws-connect", "security")
+		}
+		fail(stage, err)
+		return "", false
+	}
+	passed("ws-connect", "security", "muxer")
+
+	if ids, ok := h.(interface{ IDService() identify.IDService }); ok {
+		for _, c := range h.Network().ConnsToPeer(info.ID) {
+			select {
+			case <-ids.IDService().IdentifyWait(c):
+			case <-ctx.Done():
+				fail("identify", ctx.Err())
+				return "", false
+			}
+		}
+	}
+	// IdentifyWait also returns when identify failed, which leaves the
+	// peer without protocols
+	if protocols, err := h.Peerstore().GetProtocols(info.ID); err != nil || len(protocols) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no protocols identified")
+		}
+		fail("identify", err)
+		return "", false
+	}
+	passed("identify")
+	return info.ID, true
+}
+
+// connectStage attributes a failed connect to the layer that produced it.
+// go-libp2p's upgrader wraps security and muxer failures in fixed messages;
+// everything before them (address, TCP, TLS, HTTP upgrade) is ws-connect.
+func connectStage(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "failed to negotiate stream multiplexer"):
+		return "muxer"
+	case strings.Contains(msg, "failed to negotiate security protocol"):
+		return "security"
+	default:
+		return "ws-connect"
+	}
+}
+
+// dialEcho runs DIAL: it connects to addr, then sends a deterministic 64KB
+// payload over the echo protocol and checks that the same bytes come back.
+func dialEcho(h host.Host, addr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	p, ok := connectStaged(ctx, h, "DIAL", addr)
+	if !ok {
+		return
+	}
+	fail := func(err error) {
+		fmt.Printf("DIAL_FAILED: stage=echo addr=%s err=%v\n", addr, err)
+	}
+
+	start := time.Now()
+	s, err := h.NewStream(ctx, p, echoProtocol)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	payload := make([]byte, echoPayloadSize)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	if _, err := s.Write(payload); err != nil {
+		fail(err)
+		return
+	}
+	if err := s.CloseWrite(); err != nil {
+		fail(err)
+		return
+	}
+	echoed := make([]byte, len(payload))
+	if n, err := io.ReadFull(s, echoed); err != nil {
+		fail(fmt.Errorf("read %d of %d bytes: %w", n, len(payload), err))
+		return
+	}
+	rtt := time.Since(start)
+
+	if !bytes.Equal(payload, echoed) {
+		offset := 0
+		for offset < len(payload) && payload[offset] == echoed[offset] {
+			offset++
+		}
+		fail(fmt.Errorf("first differing byte at offset %d", offset))
+		return
+	}
+	fmt.Println("DIAL_STAGE: stage=echo result=ok")
+	fmt.Printf("DIAL_ECHO_OK: peer=%s bytes=%d rtt_ms=%.3f\n", p, len(payload), milliseconds(rtt))
+}
+
+// pingPeer runs PING: it connects to addr, then sends count ping probes.
+func pingPeer(h host.Host, addr string, count int) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	p, ok := connectStaged(ctx, h, "PING", addr)
+	cancel()
+	if !ok {
+		return
+	}
+
+	succeeded := 0
+	for seq := 1; seq <= count; seq++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		var res ping.Result
+		select {
+		case r, open := <-ping.Ping(ctx, h, p):
+			if open {
+				res = r
+			} else {
+				res.Error = ctx.Err()
+			}
+		case <-ctx.Done():
+			res.Error = ctx.Err()
+		}
+		cancel()
+
+		if res.Error != nil {
+			fmt.Printf("PING_FAILED: peer=%s seq=%d err=%v\n", p, seq, res.Error)
+			continue
+		}
+		succeeded++
+		fmt.Printf("PING_RTT: peer=%s seq=%d rtt_ms=%.3f\n", p, seq, milliseconds(res.RTT))
+	}
+	fmt.Printf("PING_DONE: peer=%s sent=%d ok=%d\n", p, count, succeeded)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -11,6 +11,9 @@ public final class GoProtocolHarness: Sendable {
         public let address: String
         public let peerID: String
         public let protocolID: String
+        /// The node's TCP address, for protocols whose node also listens on
+        /// TCP (Kademlia).
+        public let tcpAddress: String
     }
 
     private let containerName: String
@@ -152,6 +155,7 @@ public final class GoProtocolHarness: Sendable {
             "-d",
             "--name", containerName,
             "-p", "\(actualPort):4001/udp",
+            "-p", "\(actualPort):4001/tcp",
             "-e", "LISTEN_PORT=4001",
         ]
         runArgs.insert(contentsOf: interopHarnessRunLabelArguments(), at: 6)
@@ -200,7 +204,8 @@ public final class GoProtocolHarness: Sendable {
                     nodeInfo = NodeInfo(
                         address: address,
                         peerID: peerID,
-                        protocolID: protocolType.protocolName
+                        protocolID: protocolType.protocolName,
+                        tcpAddress: "/ip4/127.0.0.1/tcp/\(actualPort)/p2p/\(peerID)"
                     )
                     print("\(protocolType.imageName) node ready: \(address)")
                     break
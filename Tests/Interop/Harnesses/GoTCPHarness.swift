@@ -66,11 +66,16 @@ public final class GoTCPHarness: Sendable {
     ///   - port: Port to expose (0 for random)
     ///   - dockerfile: Dockerfile to use (default: Dockerfile.tcp.go)
     ///   - imageName: Docker image name (default: go-libp2p-tcp-test)
+    ///   - environment: Extra environment variables for the container
+    ///   - interactive: Keeps the node's stdin open for `sendCommand(_:)` and
+    ///     maps host.docker.internal to the host so the node can dial back
     /// - Returns: A harness managing the container
     public static func start(
         port: UInt16 = 0,
         dockerfile: String = "Dockerfiles/Dockerfile.tcp.go",
-        imageName: String = "go-libp2p-tcp-test"
+        imageName: String = "go-libp2p-tcp-test",
+        environment: [String: String] = [:],
+        interactive: Bool = false
     ) async throws -> GoTCPHarness {
         let leaseID = await acquireInteropHarnessLease()
         var shouldReleaseLease = true
@@ -119,13 +124,17 @@ public final class GoTCPHarness: Sendable {
         }
 
         // Start container (TCP uses tcp port mapping)
+        let interactiveArguments = interactive
+            ? ["-i", "--add-host=host.docker.internal:host-gateway"]
+            : []
         let runResult = try runDockerCommand([
             "run",
             "-d",
             "--name", containerName,
-        ] + interopHarnessRunLabelArguments() + [
+        ] + interactiveArguments + interopHarnessRunLabelArguments() + [
             "-p", "\(actualPort):4001/tcp",
             "-e", "LISTEN_PORT=4001",
+        ] + environment.sorted(by: { $0.key < $1.key }).flatMap { ["-e", "\($0.key)=\($0.value)"] } + [
             imageName
         ])
 
@@ -169,7 +178,7 @@ public final class GoTCPHarness: Sendable {
 
                 // Extract peer ID from the line
                 if let peerIdMatch = listenLine.range(
-                    of: "(12D3KooW[a-zA-Z0-9]+|Qm[a-zA-Z0-9]+)",
+                    of: "(12D3KooW[a-zA-Z0-9]+|16Uiu2[a-zA-Z0-9]+|Qm[a-zA-Z0-9]+)",
                     options: .regularExpression
                 ) {
                     let peerID = String(listenLine[peerIdMatch])
@@ -234,6 +243,33 @@ public final class GoTCPHarness: Sendable {
         }
     }
 
+    /// Writes one command line to the node's stdin.
+    ///
+    /// Requires a harness started with `interactive: true`. The command is
+    /// passed as an argument rather than spliced into the shell script, so
+    /// it needs no quoting.
+    public func sendCommand(_ command: String) async throws {
+        let result = try Self.runDockerCommand([
+            "exec", containerName,
+            "sh", "-c", "printf '%s\\n' \"$0\" > /proc/1/fd/0", command,
+        ])
+        guard result.status == 0 else {
+            throw TCPHarnessError.commandFailed(result.output.trimmingCharacters(in: .whitespacesAndNewlines))
+        }
+    }
+
+    /// Runs a program inside the container and returns its combined output.
+    ///
+    /// Used to read files the node writes (e.g. wire captures) or to run the
+    /// node binary in another mode next to the running one.
+    public func exec(_ arguments: [String]) async throws -> String {
+        let result = try Self.runDockerCommand(["exec", containerName] + arguments)
+        guard result.status == 0 else {
+            throw TCPHarnessError.commandFailed(result.output.trimmingCharacters(in: .whitespacesAndNewlines))
+        }
+        return result.output
+    }
+
     deinit {
         let leaseID = self.leaseID
         Task {
@@ -254,4 +290,5 @@ public enum TCPHarnessError: Error {
     case dockerRunFailed
     case nodeNotReady
     case nodeExited(String)
+    case commandFailed(String)
 }
@@ -15,24 +15,44 @@ public final class GoWSSHarness: Sendable {
         public let serverHostname: String
     }
 
+    /// The served certificate as reported by the node's `CERT_INFO:` line.
+    public struct CertificateInfo: Sendable, Equatable {
+        /// Key type, spelled like `CERT_ALGO` (e.g. `"ecdsa-p256"`, `"rsa2048"`)
+        public let algorithm: String
+        /// Hex SHA-256 fingerprint of the DER encoding
+        public let sha256: String
+        public let notBefore: Date
+        public let notAfter: Date
+        /// Path of the certificate file inside the container
+        public let file: String
+        /// Whether the node generated the certificate rather than loading it
+        public let generated: Bool
+    }
+
     private let containerName: String
+    private let networkName: String?
     private let port: UInt16
     private let leaseID: UUID
     public let nodeInfo: NodeInfo
     public let serverCertificatePEM: String
+    public let certificateInfo: CertificateInfo
 
     private init(
         containerName: String,
+        networkName: String?,
         port: UInt16,
         leaseID: UUID,
         nodeInfo: NodeInfo,
-        serverCertificatePEM: String
+        serverCertificatePEM: String,
+        certificateInfo: CertificateInfo
     ) {
         self.containerName = containerName
+        self.networkName = networkName
         self.port = port
         self.leaseID = leaseID
         self.nodeInfo = nodeInfo
         self.serverCertificatePEM = serverCertificatePEM
+        self.certificateInfo = certificateInfo
     }
 
     private static func runDockerCommand(
@@ -60,11 +80,40 @@ public final class GoWSSHarness: Sendable {
     ///   - port: Port to expose (0 for random)
     ///   - dockerfile: Dockerfile to use (default: Dockerfile.wss.go)
     ///   - imageName: Docker image name (default: go-libp2p-wss-test)
+    ///   - interactive: Keep the node's stdin open for `sendCommand(_:)` and
+    ///     map host.docker.internal to the host so DIAL and PING can reach
+    ///     a Swift listener
+    ///   - domain: DNS name for the node to serve (`DOMAIN`). The container
+    ///     joins its own network under this alias, serves a certificate for
+    ///     it, and `nodeInfo.address` dials it by name. The name must also
+    ///     resolve to the loopback address on the host running the tests
+    ///     (any `*.localhost` name does).
+    ///   - clientAuth: `CLIENT_AUTH` mode (`"require"`, `"request"` or
+    ///     `"none"`), verified against the image's `/client-ca.pem`. `nil`
+    ///     leaves client authentication off.
+    ///   - tlsMinVersion: `TLS_MIN_VERSION` (`"1.0"` to `"1.3"`)
+    ///   - tlsMaxVersion: `TLS_MAX_VERSION` (`"1.0"` to `"1.3"`)
+    ///   - tlsCipherSuites: `TLS_CIPHER_SUITES`, comma-separated IANA names
+    ///   - certAlgorithm: `CERT_ALGO` (`"rsa2048"`, `"ecdsa-p256"`,
+    ///     `"ecdsa-p384"` or `"ed25519"`). The node generates a certificate
+    ///     of that type instead of serving the baked-in RSA one.
+    ///   - insecureSkipVerify: Sets `INSECURE_SKIP_VERIFY=1`, so DIAL and
+    ///     PING accept a self-signed certificate from the dialed peer.
+    ///   - environment: Extra environment variables for the container
     /// - Returns: A harness managing the container
     public static func start(
         port: UInt16 = 0,
         dockerfile: String = "Dockerfiles/Dockerfile.wss.go",
-        imageName: String = "go-libp2p-wss-test"
+        imageName: String = "go-libp2p-wss-test",
+        interactive: Bool = false,
+        domain: String? = nil,
+        clientAuth: String? = nil,
+        tlsMinVersion: String? = nil,
+        tlsMaxVersion: String? = nil,
+        tlsCipherSuites: String? = nil,
+        certAlgorithm: String? = nil,
+        insecureSkipVerify: Bool = false,
+        environment: [String: String] = [:]
     ) async throws -> GoWSSHarness {
         let leaseID = await acquireInteropHarnessLease()
         var shouldReleaseLease = true
@@ -109,19 +158,52 @@ public final class GoWSSHarness: Sendable {
             // Best effort cleanup only.
         }
 
+        // A domain needs a user-defined network for the alias to resolve
+        var networkArguments: [String] = []
+        let networkName = domain.map { _ in "\(containerName)-net" }
+        if let domain, let networkName {
+            do {
+                _ = try runDockerCommand(["network", "rm", networkName])
+            } catch {
+                // Best effort cleanup only.
+            }
+            let networkResult = try runDockerCommand(["network", "create", networkName])
+            guard networkResult.status == 0 else {
+                throw WSSHarnessError.dockerRunFailed
+            }
+            networkArguments = [
+                "--network", networkName,
+                "--network-alias", domain,
+                "-e", "DOMAIN=\(domain)",
+            ]
+        }
+
+        let tlsPolicyArguments = [
+            ("TLS_MIN_VERSION", tlsMinVersion),
+            ("TLS_MAX_VERSION", tlsMaxVersion),
+            ("TLS_CIPHER_SUITES", tlsCipherSuites),
+            ("CERT_ALGO", certAlgorithm),
+            ("INSECURE_SKIP_VERIFY", insecureSkipVerify ? "1" : nil),
+        ].flatMap { name, value in value.map { ["-e", "\(name)=\($0)"] } ?? [] }
+
         // Start container (WSS uses tcp port mapping)
         let runResult = try runDockerCommand([
             "run",
             "--rm",
             "-d",
             "--name", containerName,
-        ] + interopHarnessRunLabelArguments() + [
+        ] + (interactive ? ["-i", "--add-host=host.docker.internal:host-gateway"] : []) + interopHarnessRunLabelArguments() + [
             "-p", "\(actualPort):4001/tcp",
             "-e", "LISTEN_PORT=4001",
+        ] + networkArguments + (clientAuth.map { [
+            "-e", "CLIENT_AUTH=\($0)",
+            "-e", "CLIENT_CA_FILE=/client-ca.pem",
+        ] } ?? []) + tlsPolicyArguments + environment.sorted(by: { $0.key < $1.key }).flatMap { ["-e", "\($0.key)=\($0.value)"] } + [
             imageName
         ])
 
         guard runResult.status == 0 else {
+            removeNetwork(networkName)
             throw WSSHarnessError.dockerRunFailed
         }
 
@@ -163,8 +245,9 @@ public final class GoWSSHarness: Sendable {
                     let peerID = String(listenLine[peerIdMatch])
 
                     // Build WSS address with actual exposed port
-                    // Use dns4 localhost so client can perform hostname verification.
-                    let address = "/dns4/localhost/tcp/\(actualPort)/wss/p2p/\(peerID)"
+                    // Use a dns4 name so client can perform hostname verification.
+                    let hostname = domain ?? "localhost"
+                    let address = "/dns4/\(hostname)/tcp/\(actualPort)/wss/p2p/\(peerID)"
 
                     nodeInfo = NodeInfo(
                         address: address,
@@ -172,7 +255,7 @@ public final class GoWSSHarness: Sendable {
                         transport: "wss",
                         security: "noise",
                         muxer: "yamux",
-                        serverHostname: "localhost"
+                        serverHostname: hostname
                     )
                     print("go-libp2p WSS node ready: \(address)")
                     break
@@ -193,13 +276,17 @@ public final class GoWSSHarness: Sendable {
             } catch {
                 // Best effort cleanup only.
             }
+            removeNetwork(networkName)
 
             throw WSSHarnessError.nodeNotReady
         }
 
+        // CERT_INFO names the served certificate, generated or loaded
         let certificatePEM: String
+        let certificateInfo: CertificateInfo
         do {
-            certificatePEM = try readContainerFile(containerName: containerName, filePath: "/cert.pem")
+            certificateInfo = try parseCertificateInfo(in: runDockerCommand(["logs", containerName]).output)
+            certificatePEM = try readContainerFile(containerName: containerName, filePath: certificateInfo.file)
         } catch {
             let stopProcess = Process()
             stopProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
@@ -210,6 +297,7 @@ public final class GoWSSHarness: Sendable {
             } catch {
                 // Best effort cleanup only.
             }
+            removeNetwork(networkName)
             throw WSSHarnessError.certificateReadFailed
         }
 
@@ -221,13 +309,56 @@ public final class GoWSSHarness: Sendable {
         shouldReleaseLease = false
         return GoWSSHarness(
             containerName: containerName,
+            networkName: networkName,
             port: actualPort,
             leaseID: leaseID,
             nodeInfo: info,
-            serverCertificatePEM: certificatePEM
+            serverCertificatePEM: certificatePEM,
+            certificateInfo: certificateInfo
+        )
+    }
+
+    /// Parses the node's `CERT_INFO:` log line.
+    private static func parseCertificateInfo(in logs: String) throws -> CertificateInfo {
+        guard let line = logs.split(separator: "\n").first(where: { $0.hasPrefix("CERT_INFO: ") }) else {
+            throw WSSHarnessError.certificateReadFailed
+        }
+        var fields: [Substring: Substring] = [:]
+        for field in line.dropFirst("CERT_INFO: ".count).split(separator: " ") {
+            let parts = field.split(separator: "=", maxSplits: 1)
+            if parts.count == 2 {
+                fields[parts[0]] = parts[1]
+            }
+        }
+        let dates = ISO8601DateFormatter()
+        guard let algorithm = fields["algo"],
+              let sha256 = fields["sha256"],
+              let notBefore = fields["notBefore"].flatMap({ dates.date(from: String($0)) }),
+              let notAfter = fields["notAfter"].flatMap({ dates.date(from: String($0)) }),
+              let file = fields["file"],
+              let source = fields["source"] else {
+            throw WSSHarnessError.certificateReadFailed
+        }
+        return CertificateInfo(
+            algorithm: String(algorithm),
+            sha256: String(sha256),
+            notBefore: notBefore,
+            notAfter: notAfter,
+            file: String(file),
+            generated: source == "generated"
         )
     }
 
+    /// Removes the harness network, if any.
+    private static func removeNetwork(_ networkName: String?) {
+        guard let networkName else { return }
+        do {
+            _ = try runDockerCommand(["network", "rm", networkName])
+        } catch {
+            // Best effort cleanup only.
+        }
+    }
+
     private static func readContainerFile(containerName: String, filePath: String) throws -> String {
         let result = try runDockerCommand(["exec", containerName, "cat", filePath])
 
@@ -244,6 +375,82 @@ public final class GoWSSHarness: Sendable {
         return pem
     }
 
+    /// Reads current container logs for diagnostics.
+    public func logs() async -> String {
+        do {
+            let result = try Self.runDockerCommand(["logs", containerName])
+            return result.output
+        } catch {
+            return "Failed to read logs: \(error)"
+        }
+    }
+
+    /// Runs the node in the foreground with `environment` and returns its
+    /// output once it exits, for configurations that should fail at startup.
+    ///
+    /// `start` cannot report these: the container is run with `--rm`, so a
+    /// node that exits at once takes its logs with it. Throws if the node is
+    /// still running after the process timeout.
+    public static func startupOutput(
+        imageName: String = "go-libp2p-wss-test",
+        environment: [String: String]
+    ) throws -> String {
+        let containerName = "\(imageName)-startup-\(UInt16.random(in: 10000..<60000))"
+        defer {
+            do {
+                _ = try runDockerCommand(["rm", "-f", containerName])
+            } catch {
+                // Best effort cleanup only.
+            }
+        }
+        return try runDockerCommand([
+            "run",
+            "--rm",
+            "--name", containerName,
+        ] + interopHarnessRunLabelArguments() + [
+            "-e", "LISTEN_PORT=4001",
+        ] + environment.sorted(by: { $0.key < $1.key }).flatMap { ["-e", "\($0.key)=\($0.value)"] } + [
+            imageName,
+        ]).output
+    }
+
+    /// Writes one command line to the node's stdin.
+    ///
+    /// Requires a harness started with `interactive: true`. The command is
+    /// passed as an argument rather than spliced into the shell script, so
+    /// it needs no quoting.
+    public func sendCommand(_ command: String) async throws {
+        let result = try Self.runDockerCommand([
+            "exec", containerName,
+            "sh", "-c", "printf '%s\\n' \"$0\" > /proc/1/fd/0", command,
+        ])
+        guard result.status == 0 else {
+            throw WSSHarnessError.commandFailed(result.output.trimmingCharacters(in: .whitespacesAndNewlines))
+        }
+    }
+
+    /// Sends a signal (e.g. `HUP`) to the node process.
+    public func signal(_ name: String) async throws {
+        let result = try Self.runDockerCommand(["kill", "-s", name, containerName])
+        guard result.status == 0 else {
+            throw WSSHarnessError.commandFailed(result.output.trimmingCharacters(in: .whitespacesAndNewlines))
+        }
+    }
+
+    /// Reads a PEM certificate file from the container (e.g. `/cert2.pem`).
+    public func certificatePEM(at filePath: String) throws -> String {
+        try Self.readContainerFile(containerName: containerName, filePath: filePath)
+    }
+
+    /// Reads a PEM private key file from the container (e.g. `/client-key.pem`).
+    public func privateKeyPEM(at filePath: String) throws -> String {
+        let result = try Self.runDockerCommand(["exec", containerName, "cat", filePath])
+        guard result.status == 0, result.output.contains("PRIVATE KEY") else {
+            throw WSSHarnessError.certificateReadFailed
+        }
+        return result.output
+    }
+
     /// Stops the container
     public func stop() async throws {
         do {
@@ -252,6 +459,7 @@ public final class GoWSSHarness: Sendable {
             await releaseInteropHarnessLease(leaseID)
             throw error
         }
+        Self.removeNetwork(networkName)
         await releaseInteropHarnessLease(leaseID)
     }
 
@@ -267,6 +475,7 @@ public final class GoWSSHarness: Sendable {
         } catch {
             // Best effort cleanup only.
         }
+        Self.removeNetwork(networkName)
     }
 }
 
@@ -276,4 +485,5 @@ public enum WSSHarnessError: Error {
     case nodeNotReady
     case nodeExited(String)
     case certificateReadFailed
+    case commandFailed(String)
 }
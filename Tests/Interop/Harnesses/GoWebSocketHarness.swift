@@ -31,11 +31,16 @@ public final class GoWebSocketHarness: Sendable {
     ///   - port: Port to expose (0 for random)
     ///   - dockerfile: Dockerfile to use (default: Dockerfile.ws.go)
     ///   - imageName: Docker image name (default: go-libp2p-ws-test)
+    ///   - environment: Extra environment variables for the container
+    ///   - interactive: Keeps the node's stdin open for `sendCommand(_:)` and
+    ///     maps host.docker.internal to the host so the node can dial back
     /// - Returns: A harness managing the container
     public static func start(
         port: UInt16 = 0,
         dockerfile: String = "Dockerfiles/Dockerfile.ws.go",
-        imageName: String = "go-libp2p-ws-test"
+        imageName: String = "go-libp2p-ws-test",
+        environment: [String: String] = [:],
+        interactive: Bool = false
     ) async throws -> GoWebSocketHarness {
         let leaseID = await acquireInteropHarnessLease()
         var shouldReleaseLease = true
@@ -103,9 +108,10 @@ public final class GoWebSocketHarness: Sendable {
             "--rm",
             "-d",
             "--name", containerName,
-        ] + interopHarnessRunLabelArguments() + [
+        ] + (interactive ? ["-i", "--add-host=host.docker.internal:host-gateway"] : []) + interopHarnessRunLabelArguments() + [
             "-p", "\(actualPort):4001/tcp",
             "-e", "LISTEN_PORT=4001",
+        ] + environment.sorted(by: { $0.key < $1.key }).flatMap { ["-e", "\($0.key)=\($0.value)"] } + [
             imageName
         ]
         discardProcessOutput(runProcess)
@@ -181,6 +187,51 @@ public final class GoWebSocketHarness: Sendable {
         return GoWebSocketHarness(containerName: containerName, port: actualPort, leaseID: leaseID, nodeInfo: info)
     }
 
+    /// Returns the container's combined stdout and stderr.
+    public func logs() async -> String {
+        let process = Process()
+        process.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        process.arguments = ["docker", "logs", containerName]
+
+        let pipe = Pipe()
+        process.standardOutput = pipe
+        process.standardError = pipe
+
+        do {
+            try runProcessWithTimeout(process)
+        } catch {
+            return "Failed to read logs: \(error)"
+        }
+        let data = pipe.fileHandleForReading.readDataToEndOfFile()
+        return String(data: data, encoding: .utf8) ?? ""
+    }
+
+    /// Writes one command line to the node's stdin.
+    ///
+    /// Requires a harness started with `interactive: true`. The command is
+    /// passed as an argument rather than spliced into the shell script, so
+    /// it needs no quoting.
+    public func sendCommand(_ command: String) async throws {
+        let process = Process()
+        process.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        process.arguments = [
+            "docker", "exec", containerName,
+            "sh", "-c", "printf '%s\\n' \"$0\" > /proc/1/fd/0", command,
+        ]
+
+        let pipe = Pipe()
+        process.standardOutput = pipe
+        process.standardError = pipe
+
+        try runProcessWithTimeout(process)
+
+        guard process.terminationStatus == 0 else {
+            let data = pipe.fileHandleForReading.readDataToEndOfFile()
+            let output = String(data: data, encoding: .utf8) ?? ""
+            throw WebSocketHarnessError.commandFailed(output.trimmingCharacters(in: .whitespacesAndNewlines))
+        }
+    }
+
     /// Stops the container
     public func stop() async throws {
         let process = Process()
@@ -220,4 +271,5 @@ public enum WebSocketHarnessError: Error {
     case dockerBuildFailed
     case dockerRunFailed
     case nodeNotReady
+    case commandFailed(String)
 }
@@ -0,0 +1,193 @@
+/// SOCKS5ProxyHarness
+///
+/// Manages a minimal SOCKS5 proxy Docker container that logs every CONNECT,
+/// for tests that route a node's dials through a proxy
+
+import Foundation
+
+/// Harness for the SOCKS5 proxy container (Dockerfile.socks5.go)
+public final class SOCKS5ProxyHarness: Sendable {
+
+    private let containerName: String
+    private let leaseID: UUID
+
+    /// The host port the proxy is published on.
+    public let port: UInt16
+
+    private init(containerName: String, port: UInt16, leaseID: UUID) {
+        self.containerName = containerName
+        self.port = port
+        self.leaseID = leaseID
+    }
+
+    /// Starts the proxy in Docker
+    /// - Parameters:
+    ///   - port: Host port to publish (0 for random)
+    ///   - username: Required username, or nil for no authentication
+    ///   - password: Required password when `username` is set
+    /// - Returns: A harness managing the container
+    public static func start(
+        port: UInt16 = 0,
+        username: String? = nil,
+        password: String? = nil
+    ) async throws -> SOCKS5ProxyHarness {
+        let leaseID = await acquireInteropHarnessLease()
+        var shouldReleaseLease = true
+
+        defer {
+            if shouldReleaseLease {
+                Task { await releaseInteropHarnessLease(leaseID) }
+            }
+        }
+
+        let imageName = "socks5-proxy-test"
+        let actualPort = port == 0 ? UInt16.random(in: 10000..<60000) : port
+        let containerName = "\(imageName)-\(actualPort)"
+
+        // Build the image only if it doesn't exist
+        let checkProcess = Process()
+        checkProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        checkProcess.arguments = ["docker", "images", "-q", imageName]
+        let checkPipe = Pipe()
+        checkProcess.standardOutput = checkPipe
+        checkProcess.standardError = Pipe()
+        try runProcessWithTimeout(checkProcess)
+
+        if checkPipe.fileHandleForReading.readDataToEndOfFile().isEmpty {
+            let buildProcess = Process()
+            buildProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+            buildProcess.arguments = [
+                "docker", "build",
+                "-t", imageName,
+                "-f", "Dockerfiles/Dockerfile.socks5.go",
+                "."
+            ]
+            // Go up from Harnesses/ to Interop/
+            buildProcess.currentDirectoryURL = URL(fileURLWithPath: #filePath)
+                .deletingLastPathComponent()  // Harnesses/
+                .deletingLastPathComponent()  // Interop/
+
+            try runProcessWithTimeout(buildProcess)
+
+            guard buildProcess.terminationStatus == 0 else {
+                throw SOCKS5ProxyHarnessError.dockerBuildFailed
+            }
+        }
+
+        let rmProcess = Process()
+        rmProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        rmProcess.arguments = ["docker", "rm", "-f", containerName]
+        discardProcessOutput(rmProcess)
+        do {
+            try runProcessWithTimeout(rmProcess)
+        } catch {
+            // Best effort cleanup only.
+        }
+
+        // host.docker.internal lets the proxy reach listeners on the host
+        var environment: [String] = []
+        if let username {
+            environment += ["-e", "PROXY_USER=\(username)", "-e", "PROXY_PASS=\(password ?? "")"]
+        }
+        let runProcess = Process()
+        runProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        runProcess.arguments = [
+            "docker", "run",
+            "--rm",
+            "-d",
+            "--name", containerName,
+            "--add-host=host.docker.internal:host-gateway",
+        ] + interopHarnessRunLabelArguments() + [
+            "-p", "\(actualPort):1080/tcp",
+        ] + environment + [
+            imageName
+        ]
+        discardProcessOutput(runProcess)
+
+        try runProcessWithTimeout(runProcess)
+
+        guard runProcess.terminationStatus == 0 else {
+            throw SOCKS5ProxyHarnessError.dockerRunFailed
+        }
+
+        let harness = SOCKS5ProxyHarness(containerName: containerName, port: actualPort, leaseID: leaseID)
+        for _ in 0..<60 {
+            try await Task.sleep(for: .milliseconds(500))
+            if await harness.logs().contains("SOCKS5_LISTEN: ") {
+                shouldReleaseLease = false
+                print("SOCKS5 proxy ready on port \(actualPort)")
+                return harness
+            }
+        }
+
+        let stopProcess = Process()
+        stopProcess.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        stopProcess.arguments = ["docker", "stop", containerName]
+        discardProcessOutput(stopProcess)
+        do {
+            try runProcessWithTimeout(stopProcess)
+        } catch {
+            // Best effort cleanup only.
+        }
+        throw SOCKS5ProxyHarnessError.proxyNotReady
+    }
+
+    /// Returns the container's combined stdout and stderr.
+    public func logs() async -> String {
+        let process = Process()
+        process.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        process.arguments = ["docker", "logs", containerName]
+
+        let pipe = Pipe()
+        process.standardOutput = pipe
+        process.standardError = pipe
+
+        do {
+            try runProcessWithTimeout(process)
+        } catch {
+            return "Failed to read logs: \(error)"
+        }
+        let data = pipe.fileHandleForReading.readDataToEndOfFile()
+        return String(data: data, encoding: .utf8) ?? ""
+    }
+
+    /// Stops the container
+    public func stop() async throws {
+        let process = Process()
+        process.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        process.arguments = ["docker", "stop", containerName]
+        discardProcessOutput(process)
+
+        do {
+            try runProcessWithTimeout(process)
+        } catch {
+            await releaseInteropHarnessLease(leaseID)
+            throw error
+        }
+        await releaseInteropHarnessLease(leaseID)
+    }
+
+    deinit {
+        let leaseID = self.leaseID
+        Task {
+            await releaseInteropHarnessLease(leaseID)
+        }
+
+        // Best effort cleanup
+        let process = Process()
+        process.executableURL = URL(fileURLWithPath: "/usr/bin/env")
+        process.arguments = ["docker", "stop", containerName]
+        discardProcessOutput(process)
+        do {
+            try process.run()
+        } catch {
+            // Best effort cleanup only.
+        }
+    }
+}
+
+public enum SOCKS5ProxyHarnessError: Error {
+    case dockerBuildFailed
+    case dockerRunFailed
+    case proxyNotReady
+}
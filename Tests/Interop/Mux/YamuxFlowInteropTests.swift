@@ -0,0 +1,57 @@
+/// YamuxFlowInteropTests - Swift yamux flow control against the go slow consumer
+///
+/// The go yamux node's /test/flow/1.0.0 handler reads 1KB, sleeps 10ms and
+/// echoes, so a Swift sender pushing more than go's 256KB receive window has
+/// to wait for window updates. `FlowProtocol.measure` reports whether it did
+/// and at what rate the echo came back.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter YamuxFlowInteropTests
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+
+@Suite("Yamux Flow Control Interop Tests", .serialized)
+struct YamuxFlowInteropTests {
+
+    @Test("A Swift sender is held back by the go slow consumer", .timeLimit(.minutes(2)))
+    func swiftSenderAgainstGoSlowConsumer() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.yamux.go",
+            imageName: "go-libp2p-yamux-test"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let node = Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [],
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+
+        // 384KB overruns the 256KB window; at 1KB per 10ms it needs ~3.8s
+        let bytes = 384 * 1024
+        let report = try await FlowProtocol.measure(peer: peer, bytes: bytes, on: node)
+        #expect(report.bytesSent == bytes)
+        #expect(report.duration >= .seconds(3))
+        #expect(report.stalledWrites > 0)
+        print("[Yamux flow] \(Int(report.throughput)) B/s, longest write wait \(report.longestWriteWait), \(report.stalledWrites) stalled writes")
+    }
+}
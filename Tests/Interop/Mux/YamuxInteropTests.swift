@@ -60,7 +60,9 @@ struct YamuxInteropTests {
         }
     }
 
-    private func establishConnection() async throws -> EstablishedYamuxSession {
+    private func establishConnection(
+        configuration: YamuxConfiguration = .default
+    ) async throws -> EstablishedYamuxSession {
         let harness = try await GoTCPHarness.start(
             dockerfile: "Dockerfiles/Dockerfile.yamux.go",
             imageName: "go-libp2p-yamux-test"
@@ -104,7 +106,7 @@ struct YamuxInteropTests {
         }
 
         // Step 4: Yamux muxing
-        let yamuxMuxer = YamuxMuxer()
+        let yamuxMuxer = YamuxMuxer(configuration: configuration)
         let muxedConnection = try await yamuxMuxer.multiplex(
             securedConnection,
             isInitiator: true
@@ -365,4 +367,46 @@ struct YamuxInteropTests {
         try await closeStream(stream, operation: "close large payload stream")
         try await connection.close()
     }
+
+    // MARK: - Stream Limit Tests
+
+    @Test("A go peer opening 10k streams is held to maxIncomingStreams", .timeLimit(.minutes(3)))
+    func yamuxIncomingStreamFlood() async throws {
+        let limit = 64
+        let requested = 10_000
+        let session = try await establishConnection(
+            configuration: YamuxConfiguration(maxIncomingStreams: limit)
+        )
+        let connection = session.connection
+        let harness = session.harness
+        defer { Task { do { try await harness.stop() } catch { } } }
+        _ = session.transport
+
+        // The node opens `requested` streams back over this connection
+        let control = try await openStream(on: connection, operation: "open flood stream")
+        try await negotiateProtocol("/test/flood/1.0.0", on: control, operation: "flood negotiation")
+        try await writePayload(Data("\(requested)\n".utf8), to: control, operation: "flood request")
+
+        let summary = try await withTimeout(seconds: 120, operation: "flood summary") {
+            var line = ""
+            while !line.hasSuffix("\n") {
+                let chunk = try await control.read()
+                if chunk.readableBytes == 0 { break }
+                line += String(buffer: chunk)
+            }
+            return line.trimmingCharacters(in: .whitespacesAndNewlines)
+        }
+        print("[Yamux] \(summary)")
+        #expect(summary == "FLOOD: requested=\(requested) opened=\(requested) reset=\(requested - limit)")
+
+        // The connection survives the flood and still carries new streams
+        let stream = try await openStream(on: connection, operation: "open stream after flood")
+        try await negotiateProtocol(echoProtocolID, on: stream, operation: "echo negotiation after flood")
+        let payload = Data("still alive".utf8)
+        try await writePayload(payload, to: stream, operation: "echo write after flood")
+        let response = try await readBuffer(from: stream, operation: "echo response after flood")
+        #expect(Data(buffer: response) == payload)
+
+        try await connection.close()
+    }
 }
@@ -0,0 +1,187 @@
+/// YamuxStatsInteropTests - Stream and session state of the go yamux node
+///
+/// The go yamux node tracks the streams its echo, flow and perf handlers
+/// serve. The stdin command `STATS` prints `STATS: <json>` with open and
+/// cumulative handler streams, per-connection muxed stream counts, bytes
+/// echoed and the goroutine count; `STREAMS <peerID>` lists each open handler
+/// stream as `STREAM: peer= protocol= dir= age_ms= in= out=` ending with
+/// `STREAMS_END: peer= count=`; `STATS_RESET` zeroes the cumulative counters.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter YamuxStatsInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+
+@Suite("Yamux Stats Interop Tests", .serialized)
+struct YamuxStatsInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    /// The fields of a `STATS:` line.
+    private struct Stats: Decodable {
+        struct Conn: Decodable {
+            let peer: String
+            let remote: String
+            let streams: Int
+        }
+
+        let openStreams: Int
+        let cumulativeStreams: Int
+        let conns: [Conn]
+        let echoedBytes: Int
+        let goroutines: Int
+
+        enum CodingKeys: String, CodingKey {
+            case openStreams = "open_streams"
+            case cumulativeStreams = "cumulative_streams"
+            case conns
+            case echoedBytes = "echoed_bytes"
+            case goroutines
+        }
+    }
+
+    @Test("STATS and STREAMS report open and finished echo streams", .timeLimit(.minutes(2)))
+    func statsAndStreams() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+        let node = try await Self.startNode()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        let localPeer = await node.peerID
+
+        // Three finished echo streams and one held open
+        for _ in 0..<3 {
+            try await Self.echo(Array(repeating: 0x61, count: 1000), to: peer, on: node, close: true)
+        }
+        let held = try await Self.echo(Array(repeating: 0x62, count: 300), to: peer, on: node, close: false)
+
+        try await harness.sendCommand("STATS")
+        let stats = try await Self.waitForStats(harness, matching: { $0.echoedBytes >= 3000 && $0.openStreams <= 1 })
+        #expect(stats.openStreams == 1)
+        #expect(stats.cumulativeStreams == 4)
+        #expect(stats.echoedBytes == 3000)
+        #expect(stats.goroutines > 0)
+        let conn = try #require(stats.conns.first { $0.peer == localPeer.description })
+        #expect(conn.streams >= 1)
+
+        try await harness.sendCommand("STREAMS \(localPeer)")
+        let logs = try await Self.waitForLog(harness.logs, containing: "STREAMS_END: peer=\(localPeer) ")
+        #expect(logs.contains("STREAMS_END: peer=\(localPeer) count=1"))
+        let line = try #require(logs.split(separator: "\n").first { $0.hasPrefix("STREAM: peer=\(localPeer) ") })
+        #expect(line.contains(" protocol=\(Self.echoProtocol) dir=inbound "))
+        #expect(line.contains(" in=300 out=300"))
+
+        try await held.close()
+    }
+
+    @Test("STATS_RESET zeroes the cumulative counters", .timeLimit(.minutes(2)))
+    func resetZeroesCounters() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+        let node = try await Self.startNode()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        try await Self.echo(Array(repeating: 0x63, count: 500), to: peer, on: node, close: true)
+
+        try await harness.sendCommand("STATS_RESET")
+        _ = try await Self.waitForLog(harness.logs, containing: "STATS_RESET: ok")
+        try await harness.sendCommand("STATS")
+        let stats = try await Self.waitForStats(harness, matching: { $0.cumulativeStreams == 0 })
+        #expect(stats.echoedBytes == 0)
+        #expect(stats.openStreams == 0)
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness() async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.yamux.go",
+            imageName: "go-libp2p-yamux-test",
+            interactive: true
+        )
+    }
+
+    private static func startNode() async throws -> Node {
+        let node = Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [],
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+        try await node.start()
+        return node
+    }
+
+    /// Echoes `payload` on a new stream. With `close` the write side is
+    /// closed so the go handler returns; otherwise the stream stays open.
+    @discardableResult
+    private static func echo(_ payload: [UInt8], to peer: PeerID, on node: Node, close: Bool) async throws -> MuxedStream {
+        let stream = try await node.newStream(to: peer, protocol: echoProtocol)
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = 0
+        while echoed < payload.count {
+            let chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed += chunk.readableBytes
+        }
+        #expect(echoed == payload.count)
+        if close {
+            try await stream.closeWrite()
+            while let chunk = try? await stream.read(), chunk.readableBytes > 0 {}
+        }
+        return stream
+    }
+
+    /// Polls for the last `STATS:` line and decodes it once it satisfies
+    /// `predicate`; the go handler may still be counting the last echo.
+    private static func waitForStats(
+        _ harness: GoTCPHarness,
+        matching predicate: (Stats) -> Bool
+    ) async throws -> Stats {
+        var last: Stats?
+        for _ in 0..<50 {
+            let line = await harness.logs().split(separator: "\n").last { $0.hasPrefix("STATS: ") }
+            if let line {
+                last = try JSONDecoder().decode(Stats.self, from: Data(line.dropFirst("STATS: ".count).utf8))
+                if let last, predicate(last) {
+                    return last
+                }
+            }
+            try await harness.sendCommand("STATS")
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        return try #require(last)
+    }
+
+    /// Polls the node's logs until marker appears.
+    private static func waitForLog(_ logs: () async -> String, containing marker: String) async throws -> String {
+        var output = ""
+        for _ in 0..<50 {
+            output = await logs()
+            if output.contains(marker) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(output)")
+        return output
+    }
+}
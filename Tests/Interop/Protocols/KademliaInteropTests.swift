@@ -10,6 +10,7 @@
 import Testing
 import Foundation
 import NIOCore
+import Synchronization
 @testable import P2PTransportQUIC
 @testable import P2PKademlia
 @testable import P2PTransport
@@ -17,6 +18,11 @@ import NIOCore
 @testable import P2PMux
 @testable import P2PNegotiation
 @testable import P2PProtocols
+@testable import P2P
+@testable import P2PIdentify
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
 
 /// Interoperability tests for Kademlia DHT protocol
 @Suite("Kademlia DHT Interop Tests", .serialized)
@@ -314,6 +320,200 @@ struct KademliaInteropTests {
 
         try await connection.close()
     }
+
+    @Test("go node enters the routing table on connect and leaves it on disconnect", .timeLimit(.minutes(2)))
+    func kadRoutingTableFollowsConnections() async throws {
+        let harness = try await GoProtocolHarness.start(
+            protocol: .kademlia(mode: "server")
+        )
+        defer { stopHarness(harness) }
+
+        let keyPair = KeyPair.generateEd25519()
+        let identify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        // k = 1, so the go node fills its bucket alone and a pending peer can
+        // replace it
+        let kademlia = KademliaService(
+            localPeerID: keyPair.peerID,
+            configuration: KademliaConfiguration(kValue: 1, cleanupInterval: nil)
+        )
+        let node = try Node(
+            keyPair: keyPair,
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ) {
+            Identify(identify)
+            Kademlia(kademlia)
+        }
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: Multiaddr(harness.nodeInfo.tcpAddress))
+        let info = try await identify.identify(goPeer, using: node)
+        #expect(info.protocols.contains(KademliaProtocol.protocolID))
+        try #require(await waitFor { kademlia.routingTable.contains(goPeer) })
+
+        let bucket = try #require(kademlia.routingTable.bucketIndex(for: goPeer))
+        let replacement = try #require((0..<1000).lazy
+            .map { _ in KeyPair.generateEd25519().peerID }
+            .first { kademlia.routingTable.bucketIndex(for: $0) == bucket })
+        guard case .pending = kademlia.addPeer(replacement) else {
+            Issue.record("Expected the replacement to be pending")
+            return
+        }
+
+        await node.disconnect(from: goPeer)
+        #expect(await waitFor { !kademlia.routingTable.contains(goPeer) })
+        #expect(kademlia.routingTable.peersInBucket(bucket).map(\.peerID) == [replacement])
+    }
+
+    @Test("A ping-only go node is not added to the routing table", .timeLimit(.minutes(2)))
+    func pingOnlyPeerIsNotAdded() async throws {
+        let harness = try await GoTCPHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let (node, identify, kademlia) = try makeKadNode(KademliaConfiguration(
+            cleanupInterval: nil,
+            identifyTimeout: .seconds(2)
+        ))
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: Multiaddr(harness.nodeInfo.address))
+        let info = try await identify.identify(goPeer, using: node)
+        #expect(info.protocols.contains("/ipfs/ping/1.0.0"))
+        #expect(!info.protocols.contains(KademliaProtocol.protocolID))
+
+        // Outlast identifyTimeout
+        try await Task.sleep(for: .seconds(3))
+        #expect(!kademlia.routingTable.contains(goPeer))
+        #expect(kademlia.routingTable.isEmpty)
+    }
+
+    @Test("The route table filter keeps out a go DHT server", .timeLimit(.minutes(2)))
+    func routeTableFilterRejectsDHTPeer() async throws {
+        let harness = try await GoProtocolHarness.start(
+            protocol: .kademlia(mode: "server")
+        )
+        defer { stopHarness(harness) }
+
+        let consulted = Mutex<[PeerID]>([])
+        let (node, identify, kademlia) = try makeKadNode(KademliaConfiguration(
+            cleanupInterval: nil,
+            routeTableFilter: { peer, _ in
+                consulted.withLock { $0.append(peer) }
+                return false
+            }
+        ))
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: Multiaddr(harness.nodeInfo.tcpAddress))
+        let info = try await identify.identify(goPeer, using: node)
+        #expect(info.protocols.contains(KademliaProtocol.protocolID))
+
+        #expect(await waitFor { consulted.withLock { $0.contains(goPeer) } })
+        #expect(!kademlia.routingTable.contains(goPeer))
+    }
+
+    @Test("Queued provides reach a go DHT server one at a time within the rate limit", .timeLimit(.minutes(2)))
+    func provideQueueAnnouncesToGoServer() async throws {
+        let harness = try await GoProtocolHarness.start(
+            protocol: .kademlia(mode: "server")
+        )
+        defer { stopHarness(harness) }
+
+        let (node, identify, kademlia) = try makeKadNode(KademliaConfiguration(
+            cleanupInterval: nil,
+            provideQueue: ProvideQueueConfiguration(maxConcurrent: 1, maxPerSecond: 2)
+        ))
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goAddress = try Multiaddr(harness.nodeInfo.tcpAddress)
+        let goPeer = try await node.connect(to: goAddress)
+        _ = try await identify.identify(goPeer, using: node)
+        try #require(await waitFor { kademlia.routingTable.contains(goPeer) })
+
+        let cids = (0..<4).map { CID(rawMultihash: Multihash.sha256(Data("provide-queue-\($0)".utf8))) }
+        for cid in cids {
+            #expect(kademlia.enqueueProvide(cid, addresses: []))
+            #expect(!kademlia.enqueueProvide(cid, addresses: []))
+        }
+        // 2 per second: the last start comes 1.5s after the first
+        #expect(kademlia.provideQueue.depth + kademlia.provideQueue.inFlightCount > 1)
+        #expect(await waitFor {
+            kademlia.provideQueue.depth == 0 && kademlia.provideQueue.inFlightCount == 0
+        })
+
+        let keys = cids.map { Data($0.routingKey) }
+        let provided = try keys.map { try #require(kademlia.provideQueue.lastProvided(for: $0)) }
+        #expect(provided == provided.sorted())
+        #expect(try #require(provided.last).timeIntervalSince(try #require(provided.first)) >= 1.0)
+        for key in keys {
+            #expect(kademlia.provideQueue.status(for: key)?.failures == 0)
+        }
+
+        // A second node that only knows the go server finds the Swift node
+        // as the provider
+        let (lookupNode, lookupIdentify, lookup) = try makeKadNode(KademliaConfiguration(cleanupInterval: nil))
+        try await lookupNode.start()
+        defer { Task { do { try await lookupNode.shutdown() } catch { } } }
+        _ = try await lookupIdentify.identify(try await lookupNode.connect(to: goAddress), using: lookupNode)
+        try #require(await waitFor { lookup.routingTable.contains(goPeer) })
+
+        let providerID = await node.peerID
+        for cid in cids {
+            let providers = try await lookup.getProviders(for: cid, using: lookupNode)
+            #expect(providers.map(\.id).contains(providerID))
+        }
+    }
+
+    /// A TCP node running Identify and Kademlia with `configuration`.
+    private func makeKadNode(
+        _ configuration: KademliaConfiguration
+    ) throws -> (Node, IdentifyService, KademliaService) {
+        let keyPair = KeyPair.generateEd25519()
+        let identify = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let kademlia = KademliaService(localPeerID: keyPair.peerID, configuration: configuration)
+        let node = try Node(
+            keyPair: keyPair,
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ) {
+            Identify(identify)
+            Kademlia(kademlia)
+        }
+        return (node, identify, kademlia)
+    }
+}
+
+/// Polls `condition` every 100ms for up to 10 seconds.
+private func waitFor(_ condition: @Sendable () async -> Bool) async -> Bool {
+    for _ in 0..<100 {
+        if await condition() {
+            return true
+        }
+        do {
+            try await Task.sleep(for: .milliseconds(100))
+        } catch {
+            return false
+        }
+    }
+    return false
 }
 
 private enum KademliaInteropWireError: Error {
@@ -0,0 +1,246 @@
+/// NoiseBulkTransferInteropTests - Large transfers over Noise with go-libp2p
+///
+/// Pushes 256MB each way through the go-libp2p noise node's
+/// /test/bulk/1.0.0 and /test/bulk-down/1.0.0 handlers. At that size every
+/// transfer crosses the 65535-byte Noise message boundary thousands of times,
+/// so any chunking bug shows up as a digest mismatch.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseBulkTransferInteropTests
+
+import Testing
+import Foundation
+import Crypto
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PNegotiation
+
+@Suite("Noise Bulk Transfer Interop Tests", .serialized)
+struct NoiseBulkTransferInteropTests {
+
+    static let bulkProtocol = "/test/bulk/1.0.0"
+    static let bulkDownProtocol = "/test/bulk-down/1.0.0"
+
+    static let transferSize = 256 << 20
+    static let chunkSize = 1 << 20
+
+    /// The byte(i % 251) pattern both sides send, long enough that a chunk
+    /// can start at any phase of it.
+    static let pattern: [UInt8] = (0..<(chunkSize + 251)).map { UInt8($0 % 251) }
+
+    @Test("256MB upload digest matches go-libp2p", .timeLimit(.minutes(10)))
+    func bulkUpload() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let muxedConnection = try await Self.connect(to: harness, keyPair: keyPair)
+        let stream = try await Self.openStream(on: muxedConnection, protocol: Self.bulkProtocol)
+
+        let clock = ContinuousClock()
+        let start = clock.now
+        let digest = try await Self.upload(Self.transferSize, on: stream)
+        let elapsed = clock.now - start
+        try await stream.close()
+
+        let done = try await Self.waitForDone(harness, peer: keyPair.peerID, dir: "up")
+        #expect(done.bytes == Self.transferSize)
+        #expect(done.digest == digest)
+        print("[Bulk] up: \(Self.throughput(Self.transferSize, elapsed)) MB/s (Swift), go: \(done.line)")
+
+        try await muxedConnection.close()
+    }
+
+    @Test("256MB download digest matches go-libp2p", .timeLimit(.minutes(10)))
+    func bulkDownload() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let muxedConnection = try await Self.connect(to: harness, keyPair: keyPair)
+        let stream = try await Self.openStream(on: muxedConnection, protocol: Self.bulkDownProtocol)
+
+        let clock = ContinuousClock()
+        let start = clock.now
+        try await stream.write(ByteBuffer(bytes: Self.lengthHeader(Self.transferSize)))
+        var hasher = SHA256()
+        var received = 0
+        var firstMismatch: Int?
+        while true {
+            let buffer = try await stream.read()
+            if buffer.readableBytes == 0 { break }
+            let bytes = buffer.readableBytesView
+            if firstMismatch == nil {
+                for (index, byte) in bytes.enumerated() where byte != UInt8((received + index) % 251) {
+                    firstMismatch = received + index
+                    break
+                }
+            }
+            hasher.update(data: Array(bytes))
+            received += bytes.count
+        }
+        let elapsed = clock.now - start
+        let digest = Array(hasher.finalize())
+        #expect(received == Self.transferSize)
+        #expect(firstMismatch == nil, "first differing byte at offset \(firstMismatch ?? -1)")
+        try await stream.close()
+
+        let done = try await Self.waitForDone(harness, peer: keyPair.peerID, dir: "down")
+        #expect(done.bytes == Self.transferSize)
+        #expect(done.digest == digest)
+        print("[Bulk] down: \(Self.throughput(received, elapsed)) MB/s (Swift), go: \(done.line)")
+
+        try await muxedConnection.close()
+    }
+
+    @Test("Upload completes under a fixed 256 KiB yamux window", .timeLimit(.minutes(5)))
+    func uploadWithFixedWindow() async throws {
+        let harness = try await Self.startHarness(environment: [
+            "YAMUX_WINDOW": "262144",
+            "YAMUX_MAX_WINDOW": "262144",
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+        let logs = await harness.logs()
+        #expect(logs.contains("YAMUX_CONFIG: window=262144 max_window=262144 autotune=false "))
+
+        // Without auto-tuning the node never grows the window, so the
+        // upload stalls every 256 KiB until a window update arrives
+        let keyPair = KeyPair.generateEd25519()
+        let muxedConnection = try await Self.connect(to: harness, keyPair: keyPair)
+        let stream = try await Self.openStream(on: muxedConnection, protocol: Self.bulkProtocol)
+        let size = 32 << 20
+        let digest = try await Self.upload(size, on: stream)
+        try await stream.close()
+
+        let done = try await Self.waitForDone(harness, peer: keyPair.peerID, dir: "up")
+        #expect(done.bytes == size)
+        #expect(done.digest == digest)
+
+        try await muxedConnection.close()
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: environment
+        )
+    }
+
+    /// Sends `size` pattern bytes to /test/bulk and checks the node's
+    /// digest and length reply. Returns the SHA-256 of what was sent.
+    private static func upload(_ size: Int, on stream: MuxedStream) async throws -> [UInt8] {
+        try await stream.write(ByteBuffer(bytes: lengthHeader(size)))
+        var hasher = SHA256()
+        var sent = 0
+        while sent < size {
+            let count = min(chunkSize, size - sent)
+            let phase = sent % 251
+            let chunk = pattern[phase..<(phase + count)]
+            try await stream.write(ByteBuffer(bytes: chunk))
+            hasher.update(data: chunk)
+            sent += count
+        }
+
+        let reply = try await readExactly(40, from: stream)
+        let digest = Array(hasher.finalize())
+        #expect(Array(reply[0..<32]) == digest)
+        #expect(reply[32..<40].reduce(0) { $0 << 8 | UInt64($1) } == UInt64(size))
+        return digest
+    }
+
+    /// Dials the go node and upgrades with Noise and Yamux.
+    private static func connect(to harness: GoTCPHarness, keyPair: KeyPair) async throws -> MuxedConnection {
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let securedConnection = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+
+        _ = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await securedConnection.read()) },
+            write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+        )
+        return try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+    }
+
+    private static func openStream(on connection: MuxedConnection, protocol protocolID: String) async throws -> MuxedStream {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [protocolID],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == protocolID)
+        return stream
+    }
+
+    private static func lengthHeader(_ length: Int) -> [UInt8] {
+        (0..<8).reversed().map { UInt8(truncatingIfNeeded: UInt64(length) >> (UInt64($0) * 8)) }
+    }
+
+    private static func readExactly(_ count: Int, from stream: MuxedStream) async throws -> [UInt8] {
+        var bytes: [UInt8] = []
+        while bytes.count < count {
+            let buffer = try await stream.read()
+            if buffer.readableBytes == 0 { break }
+            bytes.append(contentsOf: buffer.readableBytesView)
+        }
+        try #require(bytes.count == count, "stream ended after \(bytes.count) of \(count) bytes")
+        return bytes
+    }
+
+    /// Polls for the go node's BULK_DONE line for this peer and direction.
+    private static func waitForDone(
+        _ harness: GoTCPHarness,
+        peer: PeerID,
+        dir: String
+    ) async throws -> (line: String, bytes: Int, digest: [UInt8]) {
+        let prefix = "BULK_DONE: peer=\(peer) dir=\(dir) "
+        var logs = ""
+        for _ in 0..<20 {
+            logs = await harness.logs()
+            let lines = logs.components(separatedBy: "\n")
+            if let line = lines.first(where: { $0.hasPrefix(prefix) }) {
+                var fields: [String: String] = [:]
+                for field in line.split(separator: " ") {
+                    let parts = field.split(separator: "=", maxSplits: 1)
+                    if parts.count == 2 {
+                        fields[String(parts[0])] = String(parts[1])
+                    }
+                }
+                let bytes = try #require(fields["bytes"].flatMap { Int($0) })
+                let digest = try #require(fields["sha256"].flatMap { Hex.decode($0) })
+                return (line, bytes, digest)
+            }
+            if lines.contains(where: { $0.hasPrefix("BULK_FAILED: peer=\(peer) dir=\(dir) ") }) {
+                break
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("No BULK_DONE for dir=\(dir) in the go node logs:\n\(logs)")
+        throw TCPHarnessError.commandFailed("BULK_DONE dir=\(dir)")
+    }
+
+    private static func throughput(_ bytes: Int, _ elapsed: Duration) -> String {
+        let seconds = Double(elapsed.components.seconds) + Double(elapsed.components.attoseconds) / 1e18
+        return String(format: "%.2f", Double(bytes) / 1e6 / seconds)
+    }
+}
@@ -0,0 +1,64 @@
+/// NoiseChunkedWriteInteropTests - Initiator reassembly against a fragmenting responder
+///
+/// Starts the debug node with `WRITE_CHUNK_SIZE=1`, so every frame it writes
+/// (multistream messages, Message B and transport frames) arrives one byte at
+/// a time, length prefixes included. The Swift initiator must still negotiate,
+/// complete the handshake and read the echoed transport message.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseChunkedWriteInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("Noise Chunked Write Interop Tests", .serialized)
+struct NoiseChunkedWriteInteropTests {
+
+    @Test("Handshake completes when every responder write is one byte", .timeLimit(.minutes(2)))
+    func handshakeWithByteChunks() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: ["WRITE_CHUNK_SIZE": "1", "WRITE_DELAY_MS": "1"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let address = try Multiaddr(harness.nodeInfo.address)
+        let rawConnection = try await TCPTransport().dial(address)
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+        #expect(secured.remotePeer.description == harness.nodeInfo.peerID)
+
+        // The echoed transport frame is chunked as well
+        let message = "chunked-echo"
+        try await secured.write(ByteBuffer(string: message))
+        var echoed = ""
+        while echoed.utf8.count < message.utf8.count {
+            echoed += String(buffer: try await secured.read())
+        }
+        #expect(echoed == message)
+
+        let logs = await harness.logs()
+        #expect(logs.contains("Chunking writes: size=1 delay=1ms"))
+        #expect(logs.contains("WRITE_CHUNKS: frame=1 "))
+
+        try await secured.close()
+    }
+}
@@ -0,0 +1,111 @@
+/// NoiseCompatInteropTests - The Go debug node's go-libp2p compatibility mode
+///
+/// With COMPAT=strict the debug node answers and closes exactly where
+/// go-libp2p would; by default it is lenient and reports each input
+/// go-libp2p would have refused in the `leniencies` of its `TRANSCRIPT:`
+/// line. A conformant dialer needs no leniency in either mode.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseCompatInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("Noise Compat Interop Tests", .serialized)
+struct NoiseCompatInteropTests {
+
+    @Test("Swift completes the handshake against the strict responder", .timeLimit(.minutes(2)))
+    func strictHandshake() async throws {
+        let harness = try await Self.startHarness(environment: ["COMPAT": "strict"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+
+        let transcript = try await Self.waitForTranscript(harness)
+        #expect(transcript.success)
+        #expect(transcript.compat == "strict")
+        #expect(transcript.leniencies == nil)
+        #expect(transcript.remotePeerID == keyPair.peerID.description)
+
+        // Strict mode offers go-libp2p's default muxers as early data
+        let logs = await harness.logs()
+        #expect(logs.contains("Sending extensions: muxers=[/yamux/1.0.0]"))
+
+        try await secured.close()
+    }
+
+    @Test("The lenient responder answers ls and reports it as a leniency", .timeLimit(.minutes(2)))
+    func lenientListIsReported() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        try await rawConnection.write(Self.multistreamMessage("/multistream/1.0.0\n"))
+        try await rawConnection.write(Self.multistreamMessage("ls\n"))
+        var replies = ByteBuffer()
+        while !String(buffer: replies).contains("/noise\n\n") {
+            var chunk = try await rawConnection.read()
+            guard chunk.readableBytes > 0 else { break }
+            replies.writeBuffer(&chunk)
+        }
+        #expect(String(buffer: replies).contains("/noise\n\n"))
+        try await rawConnection.close()
+
+        let transcript = try await Self.waitForTranscript(harness)
+        #expect(!transcript.success)
+        #expect(transcript.compat == "lenient")
+        let leniency = try #require(transcript.leniencies?.first)
+        #expect(leniency.rule == "ls")
+        #expect(leniency.goLibp2p == "na")
+        #expect(leniency.granted)
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: environment
+        )
+    }
+
+    /// A multistream-select message with its one-byte length prefix.
+    private static func multistreamMessage(_ line: String) -> ByteBuffer {
+        var buffer = ByteBuffer()
+        buffer.writeInteger(UInt8(line.utf8.count))
+        buffer.writeString(line)
+        return buffer
+    }
+
+    /// Polls the node's logs until it reports a transcript.
+    private static func waitForTranscript(_ harness: GoTCPHarness) async throws -> NoiseHandshakeTranscript {
+        var transcript: NoiseHandshakeTranscript?
+        for _ in 0..<50 {
+            let logs = await harness.logs()
+            transcript = try NoiseHandshakeTranscript.last(in: logs)
+            if transcript != nil { break }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        return try #require(transcript)
+    }
+}
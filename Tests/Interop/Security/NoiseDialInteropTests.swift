@@ -0,0 +1,203 @@
+/// NoiseDialInteropTests - go-libp2p noise node dialing a Swift node
+///
+/// The go-libp2p noise node acts as initiator through its DIAL and PING
+/// stdin commands: DIAL connects, waits for identify and round-trips a 4KB
+/// payload over /test/echo/1.0.0; PING probes the Swift ping service, and
+/// PINGSTATS probes it over many ping streams at once.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseDialInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PIdentify
+@testable import P2PPing
+
+@Suite("Noise Node Dial Interop Tests", .serialized)
+struct NoiseDialInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("go dials Swift and verifies the echoed payload", .timeLimit(.minutes(2)))
+    func dialEcho() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(port: port)
+        await node.handle(Self.echoProtocol) { context in
+            await Self.echo(context.stream)
+        }
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_ECHO_OK: peer=\(peerID) rtt_ms="))
+    }
+
+    @Test("DIAL reports the negotiate stage when echo is not served", .timeLimit(.minutes(2)))
+    func dialWithoutEchoHandler() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(port: port)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_FAILED: stage=negotiate "))
+    }
+
+    @Test("DIAL reports the dial stage when nothing is listening", .timeLimit(.minutes(2)))
+    func dialUnreachable() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let peerID = KeyPair.generateEd25519().peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_FAILED: stage=dial "))
+    }
+
+    @Test("go pings Swift with per-probe RTT", .timeLimit(.minutes(2)))
+    func pingSwift() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(port: port)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("PING /dns4/host.docker.internal/tcp/\(port)/p2p/\(peerID) 3")
+
+        let logs = try await Self.waitForLog(harness, containing: ["PING_DONE: ", "PING_FAILED: peer=\(peerID) err="])
+        for seq in 1...3 {
+            #expect(logs.contains("PING_RTT: peer=\(peerID) seq=\(seq) rtt_ms="))
+        }
+        #expect(logs.contains("PING_DONE: peer=\(peerID) sent=3 ok=3"))
+    }
+
+    @Test("go sustains 10 parallel ping streams to Swift without corruption", .timeLimit(.minutes(2)))
+    func parallelPingStats() async throws {
+        // go-libp2p allows only 3 outbound ping streams per peer by default
+        let harness = try await Self.startHarness(environment: ["PING_PEER_STREAMS": "16"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(port: port)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("PING /dns4/host.docker.internal/tcp/\(port)/p2p/\(peerID) 1")
+        _ = try await Self.waitForLog(harness, containing: ["PING_DONE: ", "PING_FAILED: peer=\(peerID) err="])
+
+        try await harness.sendCommand("PINGSTATS \(peerID) 5 20 10")
+        let logs = try await Self.waitForLog(harness, containing: ["PINGSTATS: ", "PINGSTATS_FAILED: "])
+        let summary = try #require(logs.components(separatedBy: "\n").first { $0.hasPrefix("PINGSTATS: ") })
+        #expect(summary.hasPrefix("PINGSTATS: peer=\(peerID) streams=10 probes=50 ok=50 lost=0 loss_pct=0.0 "))
+        #expect(summary.contains(" p95_ms="))
+        #expect(summary.hasSuffix(" errors=[]"))
+        #expect(!logs.contains(" error=mismatch "))
+        for stream in 1...10 {
+            #expect(logs.contains("PINGSTATS_PROBE: peer=\(peerID) stream=\(stream) seq=5 rtt_ms="))
+        }
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: environment,
+            interactive: true
+        )
+    }
+
+    private static func makeNode(port: UInt16) -> Node {
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let pingService = PingService()
+        return Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [Multiaddr.tcp(host: "0.0.0.0", port: port)],
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            services: ServicePipeline {
+                service(identifyService) { component in
+                    component.handlesInboundStreams()
+                    component.observesPeers()
+                    component.consumesLocalIdentity()
+                    component.consumesListenAddresses()
+                    component.consumesSupportedProtocols()
+                    component.activatesWithStreamOpening()
+                }
+                service(pingService) { component in
+                    component.handlesInboundStreams()
+                }
+            }
+        ))
+    }
+
+    /// Writes back everything read until the remote closes its write side.
+    private static func echo(_ stream: MuxedStream) async {
+        do {
+            while true {
+                let data = try await stream.read()
+                if data.readableBytes == 0 { break }
+                try await stream.write(data)
+            }
+        } catch {
+            // Remote half-close or reset ends the echo.
+        }
+        do {
+            try await stream.close()
+        } catch {
+            // Ignore close failures in test handler cleanup.
+        }
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ harness: GoTCPHarness,
+        containing markers: [String],
+        attempts: Int = 60
+    ) async throws -> String {
+        var logs = ""
+        for _ in 0..<attempts {
+            logs = await harness.logs()
+            if markers.contains(where: { logs.contains($0) }) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(logs)")
+        return logs
+    }
+}
@@ -0,0 +1,172 @@
+/// NoiseEventLogInteropTests - Structured connectivity events from the go noise node
+///
+/// With EVENTS=1 the go-libp2p noise node prints every connectivity event as
+/// `EVENT: {json}` with a sequence number and an RFC3339 timestamp. These
+/// tests drive one connection and one echo stream from Swift and check the
+/// events go reports for them, in order.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseEventLogInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+
+@Suite("Noise Node Event Log Interop Tests", .serialized)
+struct NoiseEventLogInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("A connection and an echo stream are reported as ordered JSON events", .timeLimit(.minutes(2)))
+    func connectionLifecycle() async throws {
+        let harness = try await Self.startHarness(environment: ["EVENTS": "1"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let node = Self.makeNode(keyPair: keyPair)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        let stream = try await node.newStream(to: goPeer, protocol: Self.echoProtocol)
+        let payload = ByteBuffer(bytes: [UInt8](repeating: 0x5A, count: 1000))
+        try await stream.write(payload)
+        try await stream.closeWrite()
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.readableBytes {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(echoed == payload)
+        try await stream.close()
+        await node.disconnect(from: goPeer)
+
+        let logs = try await Self.waitForLog(harness, containing: "\"event\":\"connection_closed\"")
+        let events = try Self.events(in: logs)
+
+        // Sequence numbers count up from 1 without gaps and every timestamp
+        // is RFC3339
+        #expect(!events.isEmpty)
+        #expect(events.compactMap { $0["seq"] as? Int } == events.indices.map { $0 + 1 })
+        let formatter = ISO8601DateFormatter()
+        formatter.formatOptions = [.withInternetDateTime, .withFractionalSeconds]
+        #expect(events.allSatisfy { ($0["time"] as? String).flatMap(formatter.date(from:)) != nil })
+
+        let peer = keyPair.peerID.description
+        let kinds = events
+            .filter { ($0["peer"] as? String).map { $0 == peer } ?? true }
+            .compactMap { $0["event"] as? String }
+        #expect(Self.isSubsequence([
+            "listen_started",
+            "inbound_connection",
+            "security_negotiated",
+            "muxer_negotiated",
+            "connection_opened",
+            "stream_opened",
+            "stream_closed",
+            "connection_closed",
+        ], of: kinds))
+        #expect(kinds.contains("identify_completed"))
+
+        let opened = try #require(events.first { $0["event"] as? String == "connection_opened" })
+        #expect(opened["dir"] as? String == "inbound")
+        #expect(opened["security"] as? String == "/noise")
+        #expect(opened["muxer"] as? String == "/yamux/1.0.0")
+
+        let echoClosed = try #require(events.first {
+            $0["event"] as? String == "stream_closed" && $0["protocol"] as? String == Self.echoProtocol
+        })
+        #expect(echoClosed["bytes_in"] as? Int == 1000)
+        #expect(echoClosed["bytes_out"] as? Int == 1000)
+        #expect(echoClosed["duration_ms"] as? Double != nil)
+
+        let closed = try #require(events.first { $0["event"] as? String == "connection_closed" })
+        #expect(closed["conn"] as? String == opened["conn"] as? String)
+        #expect((closed["reason"] as? String)?.hasPrefix("remote") == true)
+    }
+
+    @Test("Without EVENTS the node prints no events", .timeLimit(.minutes(2)))
+    func disabledByDefault() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let node = Self.makeNode(keyPair: .generateEd25519())
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        _ = try await Self.waitForLog(harness, containing: "CONN_STATE: ")
+        await node.disconnect(from: goPeer)
+
+        let logs = await harness.logs()
+        #expect(!logs.contains("EVENT: "))
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: environment,
+            interactive: true
+        )
+    }
+
+    private static func makeNode(keyPair: KeyPair) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: keyPair,
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+
+    /// Decodes the JSON object of every EVENT line.
+    private static func events(in logs: String) throws -> [[String: Any]] {
+        try logs.components(separatedBy: "\n")
+            .filter { $0.hasPrefix("EVENT: ") }
+            .map { line in
+                let json = Data(line.dropFirst("EVENT: ".count).utf8)
+                return try #require(try JSONSerialization.jsonObject(with: json) as? [String: Any])
+            }
+    }
+
+    /// Whether `expected` appears in `actual` in order, not necessarily
+    /// contiguously.
+    private static func isSubsequence(_ expected: [String], of actual: [String]) -> Bool {
+        var remaining = expected[...]
+        for kind in actual where kind == remaining.first {
+            remaining = remaining.dropFirst()
+        }
+        return remaining.isEmpty
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoTCPHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<60 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(logs)")
+        return logs
+    }
+}
@@ -0,0 +1,60 @@
+/// NoiseExtensionsInteropTests - Noise handshake payload extensions against the Go debug node
+///
+/// Starts the debug node with `SEND_EXTENSIONS` so Message B carries a
+/// `NoiseExtensions` field, and checks that the Swift initiator accepts it and
+/// that the node reports the extensions it received.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseExtensionsInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("Noise Extensions Interop Tests", .serialized)
+struct NoiseExtensionsInteropTests {
+
+    @Test("Swift initiator accepts a payload carrying extensions", .timeLimit(.minutes(2)))
+    func acceptsResponderExtensions() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: ["SEND_EXTENSIONS": "/yamux/1.0.0"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let address = try Multiaddr(harness.nodeInfo.address)
+        let rawConnection = try await TCPTransport().dial(address)
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+        #expect(secured.remotePeer.description == harness.nodeInfo.peerID)
+
+        // The node logs the extensions it sent and those it received in Message C
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains("EXT: ") { break }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        #expect(logs.contains("Sending extensions: muxers=[/yamux/1.0.0] certhashes=0"))
+        #expect(logs.contains("EXT: "))
+
+        try await secured.close()
+    }
+}
@@ -0,0 +1,92 @@
+/// NoiseFaultInjectionInteropTests - Noise handshake error handling against a misbehaving peer
+///
+/// Dials the Go noise debug node with each `FAULT` mode and checks that the
+/// Swift upgrader fails with the expected error category.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseFaultInjectionInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+
+/// Responder fault modes understood by Dockerfile.noise.debug.go.
+enum NoiseResponderFault: String, CaseIterable, Sendable {
+    case corruptMAC = "corrupt-mac"
+    case wrongKey = "wrong-key"
+    case truncateB = "truncate-b"
+    case oversize = "oversize"
+    case stall = "stall"
+    case badSignature = "bad-signature"
+
+    /// Whether `error` is the failure this fault must produce.
+    func matches(_ error: any Error) -> Bool {
+        switch (self, error) {
+        case (.corruptMAC, NoiseError.decryptFailed(.messageB)):
+            // Payload auth tag no longer verifies
+            return true
+        case (.oversize, NoiseError.decryptFailed(.messageB)):
+            // A 2-byte prefix cannot declare 70000 bytes; the node declares the
+            // maximum and the zero padding breaks the payload tag
+            return true
+        case (.wrongKey, NoiseError.badSignature(.messageB)),
+             (.badSignature, NoiseError.badSignature(.messageB)):
+            return true
+        case (.truncateB, NoiseError.unexpectedMessage(.messageB, _)):
+            // 40 bytes is shorter than ephemeral + encrypted static key
+            return true
+        case (.stall, UpgradeError.timeout(.securityHandshake)):
+            return true
+        default:
+            return false
+        }
+    }
+}
+
+@Suite("Noise Fault Injection Interop Tests", .serialized)
+struct NoiseFaultInjectionInteropTests {
+
+    @Test(
+        "Swift initiator rejects a faulty go responder",
+        .timeLimit(.minutes(2)),
+        arguments: NoiseResponderFault.allCases
+    )
+    func rejectsFaultyResponder(fault: NoiseResponderFault) async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: ["FAULT": fault.rawValue]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let address = try Multiaddr(harness.nodeInfo.address)
+        let rawConnection = try await TCPTransport().dial(address)
+        let upgrader = NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            handshakeTimeout: .seconds(3)
+        )
+
+        do {
+            _ = try await upgrader.upgrade(
+                rawConnection,
+                localKeyPair: .generateEd25519(),
+                role: .initiator,
+                expectedPeer: nil
+            )
+            Issue.record("Handshake with FAULT=\(fault.rawValue) unexpectedly succeeded")
+        } catch {
+            #expect(fault.matches(error), "FAULT=\(fault.rawValue) produced \(error)")
+        }
+        try await rawConnection.close()
+
+        let logs = await harness.logs()
+        #expect(logs.contains("FAULT_ACTIVE: \(fault.rawValue)"))
+    }
+}
@@ -0,0 +1,182 @@
+/// NoiseIdentifyInteropTests - Identify and Identify Push against go-libp2p
+///
+/// The go-libp2p noise node prints what each peer identified as (IDENTIFY)
+/// and, for every later identify message, what changed (IDENTIFY_PUSH). These
+/// tests check that go sees the Swift node's identify fields and applies a
+/// push sent after a protocol handler was added mid-connection. AGENT_VERSION
+/// and PROTOCOL_VERSION are checked from the Swift side of an identify query.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseIdentifyInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PIdentify
+
+@Suite("Noise Node Identify Interop Tests", .serialized)
+struct NoiseIdentifyInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+    static let lateProtocol = "/test/late/1.0.0"
+
+    @Test("go reports the Swift node's identify fields and its own versions reach Swift", .timeLimit(.minutes(2)))
+    func identifyFields() async throws {
+        let harness = try await Self.startHarness(environment: [
+            "AGENT_VERSION": "go-noise-interop/2.0.0",
+            "PROTOCOL_VERSION": "ipfs/0.1.0-interop",
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let keyPair = KeyPair.generateEd25519()
+        let identifyService = IdentifyService(configuration: .init(
+            agentVersion: "swift-interop/1.0.0",
+            cleanupInterval: nil
+        ))
+        let node = Self.makeNode(port: port, keyPair: keyPair, identifyService: identifyService)
+        await node.handle(Self.echoProtocol) { context in
+            await Self.echo(context.stream)
+        }
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/p2p/\(keyPair.peerID)")
+        let logs = try await Self.waitForLog(harness, containing: ["IDENTIFY: peer=\(keyPair.peerID) "])
+        let line = try #require(Self.line(in: logs, prefix: "IDENTIFY: peer=\(keyPair.peerID) "))
+        #expect(line.contains(" agent=swift-interop/1.0.0 "))
+        #expect(line.contains(" proto_version=ipfs/0.1.0 "))
+        #expect(line.contains(" listen_addrs=[/ip4/"))
+        #expect(line.contains(Self.echoProtocol))
+        #expect(line.contains("/ipfs/id/push/1.0.0"))
+        #expect(line.contains(" observed=/ip4/"))
+
+        let goPeer = try PeerID(string: harness.nodeInfo.peerID)
+        let info = try await identifyService.identify(goPeer, using: node)
+        #expect(info.agentVersion == "go-noise-interop/2.0.0")
+        #expect(info.protocolVersion == "ipfs/0.1.0-interop")
+    }
+
+    @Test("go applies a push announcing a handler added mid-connection", .timeLimit(.minutes(2)))
+    func identifyPushAfterNewHandler() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let keyPair = KeyPair.generateEd25519()
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let node = Self.makeNode(port: port, keyPair: keyPair, identifyService: identifyService)
+        await node.handle(Self.echoProtocol) { context in
+            await Self.echo(context.stream)
+        }
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/p2p/\(keyPair.peerID)")
+        var logs = try await Self.waitForLog(harness, containing: ["IDENTIFY: peer=\(keyPair.peerID) "])
+        let initial = try #require(Self.line(in: logs, prefix: "IDENTIFY: peer=\(keyPair.peerID) "))
+        #expect(!initial.contains(Self.lateProtocol))
+
+        await node.handle(Self.lateProtocol) { context in
+            await Self.echo(context.stream)
+        }
+        let goPeer = try PeerID(string: harness.nodeInfo.peerID)
+        try await identifyService.push(
+            to: goPeer,
+            using: node,
+            localKeyPair: keyPair,
+            listenAddresses: await node.listenAddresses(),
+            supportedProtocols: await node.supportedProtocols()
+        )
+
+        logs = try await Self.waitForLog(harness, containing: ["IDENTIFY_PUSH: peer=\(keyPair.peerID) "])
+        let push = try #require(Self.line(in: logs, prefix: "IDENTIFY_PUSH: peer=\(keyPair.peerID) "))
+        #expect(push.contains(" changed=[protocols"))
+        #expect(push.contains(" added=[\(Self.lateProtocol)] "))
+        #expect(push.hasSuffix(" removed=[]"))
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: environment,
+            interactive: true
+        )
+    }
+
+    private static func makeNode(port: UInt16, keyPair: KeyPair, identifyService: IdentifyService) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: keyPair,
+            listenAddresses: [Multiaddr.tcp(host: "0.0.0.0", port: port)],
+            transports: [TCPTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            services: ServicePipeline {
+                service(identifyService) { component in
+                    component.handlesInboundStreams()
+                    component.observesPeers()
+                    component.consumesLocalIdentity()
+                    component.consumesListenAddresses()
+                    component.consumesSupportedProtocols()
+                    component.activatesWithStreamOpening()
+                }
+            }
+        ))
+    }
+
+    /// Writes back everything read until the remote closes its write side.
+    private static func echo(_ stream: MuxedStream) async {
+        do {
+            while true {
+                let data = try await stream.read()
+                if data.readableBytes == 0 { break }
+                try await stream.write(data)
+            }
+        } catch {
+            // Remote half-close or reset ends the echo.
+        }
+        do {
+            try await stream.close()
+        } catch {
+            // Ignore close failures in test handler cleanup.
+        }
+    }
+
+    private static func line(in logs: String, prefix: String) -> String? {
+        logs.components(separatedBy: "\n").first { $0.hasPrefix(prefix) }
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ harness: GoTCPHarness,
+        containing markers: [String],
+        attempts: Int = 60
+    ) async throws -> String {
+        var logs = ""
+        for _ in 0..<attempts {
+            logs = await harness.logs()
+            if markers.contains(where: { logs.contains($0) }) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(logs)")
+        return logs
+    }
+}
@@ -264,4 +264,158 @@ struct NoiseInteropTests {
 
         try await muxedConnection.close()
     }
+
+    // MARK: - Connection State Tests
+
+    @Test("go-libp2p reports negotiated security and muxer", .timeLimit(.minutes(2)))
+    func negotiatedConnectionState() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let transport = TCPTransport()
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let securedConnection = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        let muxNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await securedConnection.read()) },
+            write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(muxNegotiation.protocolID == "/yamux/1.0.0")
+        let muxedConnection = try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+
+        // The notifee runs once go-libp2p has added the upgraded connection
+        try await Task.sleep(for: .milliseconds(500))
+        let logs = await harness.logs()
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/noise muxer=/yamux/1.0.0 transport=tcp"
+        ))
+        #expect(logs.contains("HANDSHAKE: peer=\(keyPair.peerID) dir=inbound noise_ms="))
+
+        try await muxedConnection.close()
+    }
+
+    @Test("go-libp2p reports the stage of a failed upgrade", .timeLimit(.minutes(2)))
+    func failedUpgradeStage() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let transport = TCPTransport()
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(securityNegotiation.protocolID == "/noise")
+
+        // A 5-byte frame is too short to be the initiator's ephemeral key
+        try await rawConnection.write(ByteBuffer(bytes: [0x00, 0x05] + Array("hello".utf8)))
+        try await Task.sleep(for: .milliseconds(500))
+
+        let logs = await harness.logs()
+        #expect(logs.contains("UPGRADE_FAILED: stage=security"))
+        #expect(logs.contains("HANDSHAKE_FAIL: stage=noise "))
+        #expect(!logs.contains("CONN_STATE: "))
+
+        try await rawConnection.close()
+    }
+
+    // MARK: - Handshake Timing Tests
+
+    @Test("go-libp2p attributes a connection closed before negotiation to multistream", .timeLimit(.minutes(2)))
+    func multistreamFailureStage() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        try await rawConnection.write(ByteBuffer(bytes: Array("not multistream\n".utf8)))
+        try await rawConnection.close()
+        try await Task.sleep(for: .milliseconds(500))
+
+        let logs = await harness.logs()
+        #expect(logs.contains("HANDSHAKE_FAIL: stage=multistream "))
+        #expect(!logs.contains("HANDSHAKE: "))
+    }
+
+    @Test("Handshake timings from both sides", .timeLimit(.minutes(2)))
+    func handshakeTimings() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            interactive: true
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rounds = 5
+        let clock = ContinuousClock()
+        var swiftNoise: [Duration] = []
+        var swiftMuxer: [Duration] = []
+        for _ in 0..<rounds {
+            let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+            let securityNegotiation = try await MultistreamSelect.negotiate(
+                protocols: ["/noise"],
+                read: { Data(buffer: try await rawConnection.read()) },
+                write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+            )
+
+            let noiseStart = clock.now
+            let securedConnection = try await NoiseUpgrader().secure(
+                rawConnection,
+                localKeyPair: KeyPair.generateEd25519(),
+                as: .initiator,
+                expectedPeer: nil,
+                initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+            )
+            let muxerStart = clock.now
+            _ = try await MultistreamSelect.negotiate(
+                protocols: ["/yamux/1.0.0"],
+                read: { Data(buffer: try await securedConnection.read()) },
+                write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+            )
+            let muxedConnection = try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+            swiftNoise.append(muxerStart - noiseStart)
+            swiftMuxer.append(clock.now - muxerStart)
+
+            try await muxedConnection.close()
+        }
+
+        try await harness.sendCommand("HANDSHAKE_STATS")
+        try await Task.sleep(for: .milliseconds(500))
+        let logs = await harness.logs()
+
+        let goHandshakes = logs.components(separatedBy: "\n").filter { $0.hasPrefix("HANDSHAKE: ") }
+        #expect(goHandshakes.count == rounds)
+        #expect(goHandshakes.allSatisfy { $0.contains(" dir=inbound ") && $0.contains(" total_ms=") })
+        #expect(logs.contains("HANDSHAKE_STATS: count=\(rounds) failures=0 min_ms="))
+
+        // Printed next to go's lines so asymmetric slowness stands out
+        print("Swift noise durations: \(swiftNoise)")
+        print("Swift muxer durations: \(swiftMuxer)")
+        for line in goHandshakes {
+            print("go \(line)")
+        }
+    }
 }
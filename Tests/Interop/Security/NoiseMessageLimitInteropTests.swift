@@ -0,0 +1,117 @@
+/// NoiseMessageLimitInteropTests - The 65535-byte Noise message limit against the Go debug node
+///
+/// Noise transport messages are capped at 65535 bytes including the 16-byte
+/// tag, so writers split larger plaintexts. The debug node numbers every
+/// transport frame in its RECV lines and sends split payloads on request
+/// with `SEND_LARGE`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseMessageLimitInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("Noise Message Limit Interop Tests", .serialized)
+struct NoiseMessageLimitInteropTests {
+
+    /// 200KB: three full 65519-byte plaintext chunks and an 8243-byte rest.
+    static let payloadSize = 200 * 1024
+
+    @Test("A 200KB write reaches the node as four maximum-size frames", .timeLimit(.minutes(2)))
+    func swiftSplitsLargeWrite() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+        let secured = try await Self.connect(to: harness)
+
+        let payload = ByteBuffer(bytes: (0..<Self.payloadSize).map { UInt8(truncatingIfNeeded: $0 &* 7) })
+        try await secured.write(payload)
+        let echoed = try await Self.read(Self.payloadSize, from: secured)
+        #expect(echoed == payload)
+
+        let logs = await harness.logs()
+        let frames = logs
+            .split(separator: "\n")
+            .filter { $0.contains("RECV cs1 (initiator->responder) frame=") }
+        #expect(frames.count == 4)
+        #expect(frames.prefix(3).allSatisfy { $0.contains(" len=65535 ") })
+        #expect(frames.last?.contains(" frame=4 len=8259 ") == true)
+        #expect(!logs.contains("FRAME_TOO_LARGE"))
+
+        try await secured.close()
+    }
+
+    @Test("SEND_LARGE arrives intact from maximum-size frames", .timeLimit(.minutes(2)))
+    func nodeSplitsLargeSend() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+        let secured = try await Self.connect(to: harness)
+
+        // Wait for the node to install the session before commanding it
+        _ = try await Self.waitForLog(harness, containing: "Transport ready:")
+        try await harness.sendCommand("SEND_LARGE 200000")
+        let received = try await Self.read(200_000, from: secured)
+        #expect(received == ByteBuffer(bytes: (0..<200_000).map { UInt8($0 % 251) }))
+
+        let logs = try await Self.waitForLog(harness, containing: "SEND_LARGE: ")
+        #expect(logs.contains("SEND_LARGE: bytes=200000 frames=4 max_frame=65535 nonce=0->4"))
+
+        try await secured.close()
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness() async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            interactive: true
+        )
+    }
+
+    private static func connect(to harness: GoTCPHarness) async throws -> any SecuredConnection {
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        return try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+    }
+
+    /// Reads until `count` bytes have arrived.
+    private static func read(_ count: Int, from connection: any SecuredConnection) async throws -> ByteBuffer {
+        var buffer = ByteBuffer()
+        while buffer.readableBytes < count {
+            var chunk = try await connection.read()
+            guard chunk.readableBytes > 0 else { break }
+            buffer.writeBuffer(&chunk)
+        }
+        return buffer
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoTCPHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the debug node logs:\n\(logs)")
+        return logs
+    }
+}
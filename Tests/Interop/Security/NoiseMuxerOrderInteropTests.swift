@@ -0,0 +1,249 @@
+/// NoiseMuxerOrderInteropTests - Muxer ordering against go-libp2p
+///
+/// The go-libp2p noise node offers the muxers listed in MUXERS, in order.
+/// Over multistream-select the dialer's preference decides, as it does for
+/// security protocols. In early muxer negotiation the listener chooses
+/// instead: go's TLS server takes the first of its own ALPN entries the
+/// client also offers. Swift's Noise handshake carries no muxer extension, so
+/// Noise connections always fall back to multistream-select. The stack a
+/// `NegotiatingUpgrader` connection reports is checked against the node's
+/// CONN_STATE line.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseMuxerOrderInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PSecurityTLS
+@testable import P2PMuxYamux
+@testable import P2PMuxMplex
+@testable import P2PTransport
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PNegotiation
+@testable import P2PProtocols
+
+@Suite("Noise Node Muxer Order Interop Tests", .serialized)
+struct NoiseMuxerOrderInteropTests {
+
+    static let yamux = "/yamux/1.0.0"
+    static let mplex = "/mplex/6.7.0"
+
+    @Test("Swift preference for mplex wins over a yamux-first listener", .timeLimit(.minutes(2)))
+    func mplexPreferredAgainstYamuxFirst() async throws {
+        let harness = try await Self.startHarness(muxers: "yamux,mplex")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let secured = try await Self.secureWithNoise(harness, keyPair: keyPair)
+        let muxed = try await Self.multiplex(secured, proposing: [Self.mplex, Self.yamux])
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains("MUXER_ORDER: /yamux/1.0.0,/mplex/6.7.0"))
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/noise muxer=/mplex/6.7.0 transport=tcp muxer_via=multistream"
+        ))
+
+        try await muxed.close()
+    }
+
+    @Test("Swift preference for yamux wins over an mplex-first listener", .timeLimit(.minutes(2)))
+    func yamuxPreferredAgainstMplexFirst() async throws {
+        let harness = try await Self.startHarness(muxers: "mplex,yamux")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let secured = try await Self.secureWithNoise(harness, keyPair: keyPair)
+        let muxed = try await Self.multiplex(secured, proposing: [Self.yamux, Self.mplex])
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains("MUXER_ORDER: /mplex/6.7.0,/yamux/1.0.0"))
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/noise muxer=/yamux/1.0.0 transport=tcp muxer_via=multistream"
+        ))
+
+        try await muxed.close()
+    }
+
+    @Test("mplex-only listener falls back from yamux", .timeLimit(.minutes(2)))
+    func mplexOnlyFallback() async throws {
+        let harness = try await Self.startHarness(muxers: "mplex")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let secured = try await Self.secureWithNoise(harness, keyPair: keyPair)
+        let muxed = try await Self.multiplex(secured, proposing: [Self.yamux, Self.mplex])
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains("MUXER_ORDER: /mplex/6.7.0\n"))
+        #expect(logs.contains("CONN_STATE: peer=\(keyPair.peerID) security=/noise muxer=/mplex/6.7.0 "))
+
+        try await muxed.close()
+    }
+
+    @Test("TLS early muxer negotiation follows the listener's order", .timeLimit(.minutes(2)))
+    func tlsEarlyMuxerUsesListenerOrder() async throws {
+        let harness = try await Self.startHarness(muxers: "mplex,yamux", security: "tls")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [tlsProtocolID],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == tlsProtocolID)
+
+        let (secured, negotiatedMuxer) = try await TLSUpgrader().secureWithEarlyMuxer(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: try PeerID(string: harness.nodeInfo.peerID),
+            muxerProtocols: [Self.yamux, Self.mplex]
+        )
+        #expect(negotiatedMuxer == Self.mplex)
+
+        let muxed = try await MplexMuxer().multiplex(secured, isInitiator: true)
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/tls/1.0.0 muxer=/mplex/6.7.0 transport=tcp muxer_via=early-data"
+        ))
+
+        try await muxed.close()
+    }
+
+    @Test("Upgraded connections report the Noise stack with a multistream muxer", .timeLimit(.minutes(2)))
+    func upgraderReportsNoiseStack() async throws {
+        let harness = try await Self.startHarness(muxers: "yamux,mplex")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let result = try await Self.upgrade(
+            harness,
+            keyPair: keyPair,
+            upgrader: NegotiatingUpgrader(security: [NoiseUpgrader()], muxers: [MplexMuxer(), YamuxMuxer()])
+        )
+        let muxed = result.connection
+        #expect(muxed.transport == "tcp")
+        #expect(muxed.securityProtocol == "/noise")
+        #expect(muxed.muxerProtocol == Self.mplex)
+        #expect(muxed.negotiatedStack?.muxerSelection == .multistreamSelect)
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/noise muxer=/mplex/6.7.0 transport=tcp muxer_via=multistream"
+        ))
+
+        try await muxed.close()
+    }
+
+    @Test("Upgraded connections report a TLS muxer chosen in the handshake", .timeLimit(.minutes(2)))
+    func upgraderReportsTLSEarlyMuxer() async throws {
+        let harness = try await Self.startHarness(muxers: "mplex,yamux", security: "tls")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let result = try await Self.upgrade(
+            harness,
+            keyPair: keyPair,
+            upgrader: NegotiatingUpgrader(security: [TLSUpgrader()], muxers: [YamuxMuxer(), MplexMuxer()])
+        )
+        let muxed = result.connection
+        #expect(muxed.transport == "tcp")
+        #expect(muxed.securityProtocol == tlsProtocolID)
+        #expect(muxed.muxerProtocol == Self.mplex)
+        #expect(muxed.negotiatedStack?.muxerSelection == .securityHandshake)
+        try await Self.ping(over: muxed)
+
+        let logs = await harness.logs()
+        #expect(logs.contains(
+            "CONN_STATE: peer=\(keyPair.peerID) security=/tls/1.0.0 muxer=/mplex/6.7.0 transport=tcp muxer_via=early-data"
+        ))
+
+        try await muxed.close()
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(muxers: String, security: String = "noise") async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: ["MUXERS": muxers, "SECURITY": security]
+        )
+    }
+
+    private static func upgrade(
+        _ harness: GoTCPHarness,
+        keyPair: KeyPair,
+        upgrader: NegotiatingUpgrader
+    ) async throws -> UpgradeResult {
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        return try await upgrader.upgrade(
+            rawConnection,
+            localKeyPair: keyPair,
+            role: .initiator,
+            expectedPeer: try PeerID(string: harness.nodeInfo.peerID)
+        )
+    }
+
+    private static func secureWithNoise(_ harness: GoTCPHarness, keyPair: KeyPair) async throws -> any SecuredConnection {
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        return try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+    }
+
+    /// Negotiates a muxer over multistream-select, proposing `muxers` in order.
+    private static func multiplex(_ secured: any SecuredConnection, proposing muxers: [String]) async throws -> any MuxedConnection {
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: muxers,
+            read: { Data(buffer: try await secured.read()) },
+            write: { data in try await secured.write(ByteBuffer(bytes: data)) }
+        )
+        if negotiation.protocolID == Self.mplex {
+            return try await MplexMuxer().multiplex(secured, isInitiator: true)
+        }
+        #expect(negotiation.protocolID == Self.yamux)
+        return try await YamuxMuxer().multiplex(secured, isInitiator: true)
+    }
+
+    /// Round-trips one ping payload, which also gives the node time to log
+    /// CONN_STATE for the connection.
+    private static func ping(over muxed: any MuxedConnection) async throws {
+        let stream = try await muxed.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [ProtocolID.ping],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == ProtocolID.ping)
+
+        let payload = Data((0..<32).map { _ in UInt8.random(in: 0...255) })
+        try await stream.write(ByteBuffer(bytes: payload))
+        let response = try await stream.read()
+        #expect(Data(buffer: response) == payload)
+        try await stream.close()
+    }
+}
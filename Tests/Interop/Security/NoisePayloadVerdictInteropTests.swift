@@ -0,0 +1,77 @@
+/// NoisePayloadVerdictInteropTests - Go debug node's check of Message C
+///
+/// The debug node verifies the initiator's handshake payload (identity key and
+/// signature over the Noise static key) and logs a PAYLOAD_VERDICT line. These
+/// tests check that Swift's payload is judged valid, including under STRICT=1
+/// where an invalid payload would close the connection after Message C.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoisePayloadVerdictInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("Noise Payload Verdict Interop Tests", .serialized)
+struct NoisePayloadVerdictInteropTests {
+
+    @Test(
+        "Swift's Message C payload gets a valid verdict",
+        .timeLimit(.minutes(2)),
+        arguments: ["0", "1"]
+    )
+    func swiftPayloadIsValid(strict: String) async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: ["STRICT": strict]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+
+        // The session survives Message C: the node still echoes
+        let payload = ByteBuffer(string: "payload-verdict")
+        try await secured.write(payload)
+        let echoed = try await secured.read()
+        #expect(echoed == payload)
+        try await secured.close()
+
+        let logs = try await Self.waitForLog(harness, containing: "CONN_DONE: conn=1 ")
+        #expect(logs.contains("PAYLOAD_VERDICT: identity=\(keyPair.peerID) sig=valid reason=ok"))
+        #expect(!logs.contains("sig=invalid"))
+        #expect(logs.contains("CONN_DONE: conn=1 outcome=handshake_ok "))
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoTCPHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the debug node logs:\n\(logs)")
+        return logs
+    }
+}
@@ -0,0 +1,132 @@
+/// NoiseSecurityStackInteropTests - Security protocol ordering against go-libp2p
+///
+/// The go-libp2p noise node offers the protocols listed in SECURITY, in order.
+/// In multistream-select the listener accepts the first proposal it supports,
+/// so the dialer's preference decides which protocol is used regardless of
+/// the listener's order.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseSecurityStackInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PSecurityTLS
+@testable import P2PMuxYamux
+@testable import P2PTransport
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PNegotiation
+
+@Suite("Noise Node Security Stack Interop Tests", .serialized)
+struct NoiseSecurityStackInteropTests {
+
+    @Test("Swift preference for Noise wins over a TLS-first listener", .timeLimit(.minutes(2)))
+    func noisePreferredAgainstTLSFirst() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: ["SECURITY": "tls,noise"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise", tlsProtocolID],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == "/noise")
+
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+        let muxed = try await Self.upgradeToYamux(secured)
+
+        try await Task.sleep(for: .milliseconds(500))
+        let logs = await harness.logs()
+        #expect(logs.contains("SECURITY_ORDER: /tls/1.0.0,/noise"))
+        #expect(logs.contains("CONN_STATE: peer=\(keyPair.peerID) security=/noise "))
+
+        try await muxed.close()
+    }
+
+    @Test("Swift preference for TLS wins over a Noise-first listener", .timeLimit(.minutes(2)))
+    func tlsPreferredAgainstNoiseFirst() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: ["SECURITY": "noise,tls"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [tlsProtocolID, "/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == tlsProtocolID)
+        #expect(negotiation.remainder.isEmpty)
+
+        let secured = try await TLSUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: try PeerID(string: harness.nodeInfo.peerID)
+        )
+        let muxed = try await Self.upgradeToYamux(secured)
+
+        try await Task.sleep(for: .milliseconds(500))
+        let logs = await harness.logs()
+        #expect(logs.contains("SECURITY_ORDER: /noise,/tls/1.0.0"))
+        #expect(logs.contains("CONN_STATE: peer=\(keyPair.peerID) security=/tls/1.0.0 "))
+
+        try await muxed.close()
+    }
+
+    @Test("TLS-only listener refuses Noise", .timeLimit(.minutes(2)))
+    func tlsOnlyRefusesNoise() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: ["SECURITY": "tls"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+
+        await #expect(throws: NegotiationError.noAgreement) {
+            _ = try await MultistreamSelect.negotiate(
+                protocols: ["/noise"],
+                read: { Data(buffer: try await rawConnection.read()) },
+                write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+            )
+        }
+
+        try await rawConnection.close()
+    }
+
+    // MARK: - Helpers
+
+    private static func upgradeToYamux(_ secured: any SecuredConnection) async throws -> any MuxedConnection {
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await secured.read()) },
+            write: { data in try await secured.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == "/yamux/1.0.0")
+        return try await YamuxMuxer().multiplex(secured, isInitiator: true)
+    }
+}
@@ -0,0 +1,228 @@
+/// NoiseSimultaneousOpenInteropTests - Listen-port dials and TCP options
+///
+/// The go-libp2p noise node's DIAL_FROM_LISTEN_PORT command dials from its
+/// listen port as a simultaneous connect with a fixed handshake role. These
+/// tests accept that dial on a Swift listener and take the opposite role, so
+/// both role assignments of a TCP simultaneous open are exercised without
+/// depending on the two SYNs actually crossing. The TCP_* environment options
+/// are checked through the node's TCP_OPTIONS line.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseSimultaneousOpenInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PTransport
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PNegotiation
+@testable import P2PProtocols
+
+@Suite("Noise Node Simultaneous Open Interop Tests", .serialized)
+struct NoiseSimultaneousOpenInteropTests {
+
+    static let yamux = "/yamux/1.0.0"
+
+    @Test("go initiates the handshake on a dial from its listen port", .timeLimit(.minutes(2)))
+    func listenPortDialAsInitiator() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let (listener, port) = try await Self.listen()
+        defer { Task { do { try await listener.close() } catch { } } }
+
+        let accepted = Task { try await listener.accept() }
+        try await harness.sendCommand(
+            "DIAL_FROM_LISTEN_PORT /dns4/host.docker.internal/tcp/\(port)/p2p/\(keyPair.peerID) initiator"
+        )
+        let rawConnection = try await accepted.value
+
+        // Swift takes the listener side of every stage
+        let securityNegotiation = try await MultistreamSelect.handle(
+            supported: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .responder,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        _ = try await MultistreamSelect.handle(
+            supported: [Self.yamux],
+            read: { Data(buffer: try await secured.read()) },
+            write: { data in try await secured.write(ByteBuffer(bytes: data)) }
+        )
+        let muxed = try await YamuxMuxer().multiplex(secured, isInitiator: false)
+
+        let logs = try await Self.waitForLog(
+            harness,
+            containing: ["DIAL_FROM_LISTEN_PORT_OK: ", "DIAL_FROM_LISTEN_PORT_FAILED: "]
+        )
+        #expect(logs.contains("TCP_OPTIONS: reuseport=true nodelay=true keepalive_s=30"))
+        #expect(logs.contains("SIMOPEN: peer=\(keyPair.peerID) role=initiator local=/ip4/"))
+        let ok = try #require(Self.line(in: logs, prefix: "DIAL_FROM_LISTEN_PORT_OK: peer=\(keyPair.peerID) "))
+        #expect(ok.contains(" role=initiator "))
+        #expect(ok.contains("/tcp/4001 remote="))
+        #expect(ok.hasSuffix(" dir=outbound"))
+
+        try await muxed.close()
+    }
+
+    @Test("go responds on its own dial when given the responder role", .timeLimit(.minutes(2)))
+    func listenPortDialAsResponder() async throws {
+        let harness = try await Self.startHarness()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let (listener, port) = try await Self.listen()
+        defer { Task { do { try await listener.close() } catch { } } }
+
+        let accepted = Task { try await listener.accept() }
+        try await harness.sendCommand(
+            "DIAL_FROM_LISTEN_PORT /dns4/host.docker.internal/tcp/\(port)/p2p/\(keyPair.peerID) responder"
+        )
+        let rawConnection = try await accepted.value
+
+        // The accepted connection carries the dialer side of every stage
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: try PeerID(string: harness.nodeInfo.peerID),
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        _ = try await MultistreamSelect.negotiate(
+            protocols: [Self.yamux],
+            read: { Data(buffer: try await secured.read()) },
+            write: { data in try await secured.write(ByteBuffer(bytes: data)) }
+        )
+        let muxed = try await YamuxMuxer().multiplex(secured, isInitiator: true)
+        try await Self.ping(over: muxed)
+
+        let logs = try await Self.waitForLog(
+            harness,
+            containing: ["DIAL_FROM_LISTEN_PORT_OK: ", "DIAL_FROM_LISTEN_PORT_FAILED: "]
+        )
+        #expect(logs.contains("SIMOPEN: peer=\(keyPair.peerID) role=responder local=/ip4/"))
+        let ok = try #require(Self.line(in: logs, prefix: "DIAL_FROM_LISTEN_PORT_OK: peer=\(keyPair.peerID) "))
+        #expect(ok.contains(" role=responder "))
+        #expect(ok.contains("/tcp/4001 remote="))
+        #expect(ok.hasSuffix(" dir=inbound"))
+
+        try await muxed.close()
+    }
+
+    @Test("TCP options from the environment are reported and reuseport gates the command", .timeLimit(.minutes(2)))
+    func socketOptions() async throws {
+        let harness = try await Self.startHarness(environment: [
+            "TCP_REUSEPORT": "0",
+            "TCP_NODELAY": "0",
+            "TCP_KEEPALIVE_S": "5",
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        // Connections still upgrade and carry traffic with Nagle on
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        _ = try await MultistreamSelect.negotiate(
+            protocols: [Self.yamux],
+            read: { Data(buffer: try await secured.read()) },
+            write: { data in try await secured.write(ByteBuffer(bytes: data)) }
+        )
+        let muxed = try await YamuxMuxer().multiplex(secured, isInitiator: true)
+        try await Self.ping(over: muxed)
+        try await muxed.close()
+
+        let peerID = KeyPair.generateEd25519().peerID
+        try await harness.sendCommand("DIAL_FROM_LISTEN_PORT /dns4/host.docker.internal/tcp/4001/p2p/\(peerID)")
+        let logs = try await Self.waitForLog(
+            harness,
+            containing: ["DIAL_FROM_LISTEN_PORT_OK: ", "DIAL_FROM_LISTEN_PORT_FAILED: "]
+        )
+        #expect(logs.contains("TCP_OPTIONS: reuseport=false nodelay=false keepalive_s=5"))
+        #expect(logs.contains("DIAL_FROM_LISTEN_PORT_FAILED: stage=dial "))
+        #expect(logs.contains("err=reuseport is disabled or unavailable"))
+    }
+
+    // MARK: - Helpers
+
+    private static func startHarness(environment: [String: String] = [:]) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.go",
+            imageName: "go-libp2p-noise-test",
+            environment: environment,
+            interactive: true
+        )
+    }
+
+    private static func listen() async throws -> (any Listener, UInt16) {
+        let port = UInt16.random(in: 10000..<60000)
+        let listener = try await TCPTransport().listen(Multiaddr.tcp(host: "0.0.0.0", port: port))
+        return (listener, port)
+    }
+
+    /// Round-trips one ping payload over a new stream.
+    private static func ping(over muxed: any MuxedConnection) async throws {
+        let stream = try await muxed.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [ProtocolID.ping],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == ProtocolID.ping)
+
+        let payload = Data((0..<32).map { _ in UInt8.random(in: 0...255) })
+        try await stream.write(ByteBuffer(bytes: payload))
+        let response = try await stream.read()
+        #expect(Data(buffer: response) == payload)
+        try await stream.close()
+    }
+
+    private static func line(in logs: String, prefix: String) -> String? {
+        logs.components(separatedBy: "\n").first { $0.hasPrefix(prefix) }
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ harness: GoTCPHarness,
+        containing markers: [String],
+        attempts: Int = 60
+    ) async throws -> String {
+        var logs = ""
+        for _ in 0..<attempts {
+            logs = await harness.logs()
+            if markers.contains(where: { logs.contains($0) }) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(logs)")
+        return logs
+    }
+}
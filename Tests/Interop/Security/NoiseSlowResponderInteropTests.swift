@@ -0,0 +1,137 @@
+/// NoiseSlowResponderInteropTests - The Swift handshake timeout against a slow Go responder
+///
+/// The debug node's RESPONSE_DELAY_MS delays every frame it writes and
+/// PAUSE_AFTER holds the handshake until the stdin command `RESUME`, each
+/// wait logged as a DELAY_START/DELAY_END pair. A slow responder must be
+/// tolerated up to the upgrader's handshake timeout and then fail with
+/// `UpgradeError.timeout(.securityHandshake)`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseSlowResponderInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2P
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+
+@Suite("Noise Slow Responder Interop Tests", .serialized)
+struct NoiseSlowResponderInteropTests {
+
+    @Test("A responder delaying every frame finishes within the timeout", .timeLimit(.minutes(2)))
+    func delayedResponder() async throws {
+        let harness = try await Self.startHarness(environment: ["RESPONSE_DELAY_MS": "500"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await Self.upgrader(handshakeTimeout: .seconds(5)).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        #expect(result.securityProtocol == "/noise")
+
+        // Header, /noise and Message B were each held back
+        let logs = try await Self.waitForLog(harness, containing: "DELAY_END: kind=response frame=3 ")
+        #expect(logs.contains("DELAY_START: kind=response frame=3 bytes="))
+        #expect(logs.contains("delay_ms=500"))
+
+        try await result.connection.close()
+    }
+
+    @Test("A paused responder fails the handshake with a timeout", .timeLimit(.minutes(2)))
+    func pausedResponderTimesOut() async throws {
+        let harness = try await Self.startHarness(environment: ["PAUSE_AFTER": "multistream"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let clock = ContinuousClock()
+        let start = clock.now
+        do {
+            _ = try await Self.upgrader(handshakeTimeout: .seconds(2)).upgrade(
+                rawConnection,
+                localKeyPair: .generateEd25519(),
+                role: .initiator,
+                expectedPeer: nil
+            )
+            Issue.record("Handshake with a paused responder unexpectedly succeeded")
+        } catch UpgradeError.timeout(.securityHandshake) {
+            let elapsed = clock.now - start
+            #expect(elapsed >= .seconds(2))
+            #expect(elapsed < .seconds(10))
+        } catch {
+            Issue.record("Paused responder produced \(error)")
+        }
+
+        let logs = await harness.logs()
+        #expect(logs.contains("DELAY_START: kind=pause after=multistream"))
+        #expect(!logs.contains("DELAY_END: kind=pause"))
+    }
+
+    @Test("RESUME within the timeout lets the handshake complete", .timeLimit(.minutes(2)))
+    func resumedResponder() async throws {
+        let harness = try await Self.startHarness(environment: ["PAUSE_AFTER": "multistream"], interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        async let upgraded = Self.upgrader(handshakeTimeout: .seconds(20)).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+
+        _ = try await Self.waitForLog(harness, containing: "DELAY_START: kind=pause after=multistream")
+        try await harness.sendCommand("RESUME")
+        let result = try await upgraded
+        #expect(result.securityProtocol == "/noise")
+
+        let logs = try await Self.waitForLog(harness, containing: "DELAY_END: kind=pause after=multistream")
+        #expect(logs.contains("RESUME: released=1"))
+
+        try await result.connection.close()
+    }
+
+    // MARK: - Helpers
+
+    /// The node offers yamux as early data, so no muxer negotiation runs
+    /// over its echoing transport session.
+    private static func startHarness(
+        environment: [String: String],
+        interactive: Bool = false
+    ) async throws -> GoTCPHarness {
+        try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: environment.merging(["SEND_EXTENSIONS": "/yamux/1.0.0"]) { current, _ in current },
+            interactive: interactive
+        )
+    }
+
+    private static func upgrader(handshakeTimeout: Duration) -> NegotiatingUpgrader {
+        NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            handshakeTimeout: handshakeTimeout
+        )
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoTCPHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the debug node logs:\n\(logs)")
+        return logs
+    }
+}
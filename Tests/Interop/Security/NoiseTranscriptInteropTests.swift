@@ -0,0 +1,116 @@
+/// NoiseTranscriptInteropTests - Handshake hash agreement with the Go debug node
+///
+/// The debug node logs a `TRANSCRIPT:` JSON line per handshake. These tests
+/// check that the handshake hash (channel binding) it reports matches the one
+/// computed by the Swift Noise implementation.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseTranscriptInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+/// Fields of the debug node's `TRANSCRIPT:` line used by these tests.
+struct NoiseHandshakeTranscript: Decodable {
+    struct Message: Decodable {
+        let message: String
+        let sender: String
+        let length: Int
+        let ephemeral: String?
+        let payloadDecrypted: Bool?
+    }
+
+    /// Input the responder accepted or refused where go-libp2p differs.
+    struct Leniency: Decodable {
+        let rule: String
+        let detail: String?
+        let goLibp2p: String
+        let granted: Bool
+    }
+
+    let role: String
+    let success: Bool
+    let error: String?
+    let messages: [Message]
+    let remoteStatic: String?
+    let remotePeerID: String?
+    let handshakeHash: String?
+    let compat: String?
+    let leniencies: [Leniency]?
+
+    enum CodingKeys: String, CodingKey {
+        case role, success, error, messages, remoteStatic, handshakeHash, compat, leniencies
+        // remote_peer_id after convertFromSnakeCase
+        case remotePeerID = "remotePeerId"
+    }
+
+    /// Decodes the last `TRANSCRIPT:` line in `logs`, if any.
+    static func last(in logs: String) throws -> NoiseHandshakeTranscript? {
+        let marker = "TRANSCRIPT: "
+        guard let line = logs.split(separator: "\n").last(where: { $0.contains(marker) }),
+              let range = line.range(of: marker) else {
+            return nil
+        }
+        let decoder = JSONDecoder()
+        decoder.keyDecodingStrategy = .convertFromSnakeCase
+        return try decoder.decode(Self.self, from: Data(line[range.upperBound...].utf8))
+    }
+}
+
+@Suite("Noise Transcript Interop Tests", .serialized)
+struct NoiseTranscriptInteropTests {
+
+    @Test("Swift and Go compute the same handshake hash", .timeLimit(.minutes(2)))
+    func handshakeHashMatches() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let address = try Multiaddr(harness.nodeInfo.address)
+        let rawConnection = try await TCPTransport().dial(address)
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+        let noiseConnection = try #require(secured as? NoiseConnection)
+        let swiftHash = noiseConnection.handshakeHash.map { String(format: "%02x", $0) }.joined()
+
+        // The responder logs its transcript once Message C is processed
+        var transcript: NoiseHandshakeTranscript?
+        for _ in 0..<50 {
+            let logs = await harness.logs()
+            transcript = try NoiseHandshakeTranscript.last(in: logs)
+            if transcript != nil { break }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        let goTranscript = try #require(transcript)
+
+        #expect(goTranscript.role == "responder")
+        #expect(goTranscript.success)
+        #expect(goTranscript.messages.map(\.message) == ["A", "B", "C"])
+        #expect(goTranscript.messages.last?.payloadDecrypted == true)
+        #expect(goTranscript.remotePeerID == keyPair.peerID.description)
+        #expect(swiftHash.count == 64)
+        #expect(goTranscript.handshakeHash == swiftHash)
+
+        try await secured.close()
+    }
+}
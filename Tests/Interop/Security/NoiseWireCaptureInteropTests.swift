@@ -0,0 +1,202 @@
+/// NoiseWireCaptureInteropTests - Wire captures and replay on the Go debug node
+///
+/// With CAPTURE_DIR set the debug node records each connection's raw bytes to
+/// a .cap file with a JSON index. These tests parse a capture of a Swift
+/// handshake with the format described next to captureConn in the node's
+/// main.go, and replay it against the node with `--replay`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter NoiseWireCaptureInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PCore
+@testable import P2PNegotiation
+
+/// A debug node wire capture (.cap file).
+struct NoiseWireCapture {
+    enum Direction: UInt8 {
+        case inbound = 0
+        case outbound = 1
+    }
+
+    enum Phase: UInt8, Comparable {
+        case multistream = 0
+        case handshake = 1
+        case transport = 2
+
+        static func < (lhs: Phase, rhs: Phase) -> Bool { lhs.rawValue < rhs.rawValue }
+    }
+
+    struct Record {
+        let direction: Direction
+        let phase: Phase
+        /// Nanoseconds since the capture started.
+        let time: UInt64
+        /// Position of `data` in the file.
+        let offset: Int
+        let data: [UInt8]
+    }
+
+    enum ParseError: Error, Equatable {
+        case badHeader
+        case unsupportedVersion(UInt8)
+        case truncatedRecord(Int)
+        case unknownTag(Int)
+    }
+
+    static let magic = Array("NDCAP".utf8)
+    static let recordHeaderSize = 14
+
+    /// True when the capturing node was the responder.
+    let capturedByResponder: Bool
+    let records: [Record]
+
+    init(bytes: [UInt8]) throws {
+        guard bytes.count >= Self.magic.count + 2, Array(bytes[0..<Self.magic.count]) == Self.magic else {
+            throw ParseError.badHeader
+        }
+        let version = bytes[Self.magic.count]
+        guard version == 1 else { throw ParseError.unsupportedVersion(version) }
+        capturedByResponder = bytes[Self.magic.count + 1] == 1
+
+        var records: [Record] = []
+        var position = Self.magic.count + 2
+        while position < bytes.count {
+            guard bytes.count - position >= Self.recordHeaderSize else {
+                throw ParseError.truncatedRecord(records.count)
+            }
+            let header = bytes[position..<(position + Self.recordHeaderSize)]
+            let time = header.dropFirst(2).prefix(8).reduce(UInt64(0)) { $0 << 8 | UInt64($1) }
+            let length = Int(header.suffix(4).reduce(UInt32(0)) { $0 << 8 | UInt32($1) })
+            let start = position + Self.recordHeaderSize
+            guard bytes.count - start >= length else {
+                throw ParseError.truncatedRecord(records.count)
+            }
+            guard let direction = Direction(rawValue: header[header.startIndex]),
+                  let phase = Phase(rawValue: header[header.startIndex + 1]) else {
+                throw ParseError.unknownTag(records.count)
+            }
+            records.append(Record(
+                direction: direction,
+                phase: phase,
+                time: time,
+                offset: start,
+                data: Array(bytes[start..<(start + length)])
+            ))
+            position = start + length
+        }
+        self.records = records
+    }
+
+    func bytes(_ direction: Direction) -> [UInt8] {
+        records.filter { $0.direction == direction }.flatMap(\.data)
+    }
+}
+
+/// The JSON index written next to a capture.
+struct NoiseWireCaptureIndex: Decodable {
+    struct Record: Decodable {
+        let seq: Int
+        let dir: String
+        let phase: String
+        let offset: Int
+        let length: Int
+    }
+
+    let version: Int
+    let role: String
+    let conn: Int
+    let records: [Record]
+}
+
+@Suite("Noise Wire Capture Interop Tests", .serialized)
+struct NoiseWireCaptureInteropTests {
+
+    static let capturePath = "/tmp/captures/conn-1-responder.cap"
+
+    @Test("A captured Swift handshake parses and replays against the node", .timeLimit(.minutes(2)))
+    func captureAndReplay() async throws {
+        let harness = try await GoTCPHarness.start(
+            dockerfile: "Dockerfiles/Dockerfile.noise.debug.go",
+            imageName: "go-noise-debug-test",
+            environment: ["CAPTURE_DIR": "/tmp/captures"]
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let rawConnection = try await TCPTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let secured = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: negotiation.remainder)
+        )
+        let payload = ByteBuffer(string: "wire-capture")
+        try await secured.write(payload)
+        #expect(try await secured.read() == payload)
+        try await secured.close()
+
+        let logs = try await Self.waitForLog(harness, containing: "CAPTURE: file=\(Self.capturePath) ")
+        let captureLine = try #require(logs.split(separator: "\n").first { $0.contains("CAPTURE: file=") })
+
+        let encoded = try await harness.exec(["base64", Self.capturePath])
+        let fileBytes = try #require(Data(base64Encoded: encoded, options: .ignoreUnknownCharacters))
+        let capture = try NoiseWireCapture(bytes: Array(fileBytes))
+        #expect(capture.capturedByResponder)
+        #expect(captureLine.contains(" records=\(capture.records.count) "))
+        #expect(captureLine.contains(" bytes_in=\(capture.bytes(.inbound).count) "))
+        #expect(captureLine.hasSuffix(" bytes_out=\(capture.bytes(.outbound).count)"))
+
+        // Swift's bytes start with the multistream header; phases only advance
+        let inbound = capture.bytes(.inbound)
+        #expect(Array(inbound.prefix(20)) == [0x13] + Array("/multistream/1.0.0\n".utf8))
+        #expect(capture.records.first?.phase == .multistream)
+        #expect(zip(capture.records, capture.records.dropFirst()).allSatisfy { $0.phase <= $1.phase })
+        #expect(capture.records.contains { $0.phase == .handshake && $0.direction == .outbound })
+        #expect(capture.records.contains { $0.phase == .transport && $0.direction == .inbound })
+
+        let indexJSON = try await harness.exec(["cat", "/tmp/captures/conn-1-responder.json"])
+        let index = try JSONDecoder().decode(NoiseWireCaptureIndex.self, from: Data(indexJSON.utf8))
+        #expect(index.version == 1)
+        #expect(index.role == "responder")
+        #expect(index.conn == 1)
+        #expect(index.records.map(\.offset) == capture.records.map(\.offset))
+        #expect(index.records.map(\.length) == capture.records.map(\.data.count))
+
+        // Multistream replays byte for byte; the node's fresh keys make
+        // Message B differ, and the replayed Message C fails its handshake
+        let replay = try await harness.exec(["noise-debug", "--replay", Self.capturePath])
+        let firstExpect = try #require(replay.split(separator: "\n").first { $0.contains(" expect bytes=") })
+        #expect(firstExpect.contains(" phase=multistream "))
+        #expect(firstExpect.hasSuffix(" match=true"))
+        let done = try #require(replay.split(separator: "\n").first { $0.contains("REPLAY_DONE: ") })
+        #expect(!done.contains("diverged_at=-1"))
+        let afterReplay = try await Self.waitForLog(harness, containing: "CONN_DONE: conn=2 ")
+        #expect(afterReplay.contains("CONN_DONE: conn=2 outcome=handshake_failed "))
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoTCPHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the debug node logs:\n\(logs)")
+        return logs
+    }
+}
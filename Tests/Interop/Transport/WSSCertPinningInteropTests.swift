@@ -0,0 +1,148 @@
+/// WSSCertPinningInteropTests - Pinning the go-libp2p WSS node's certificate
+///
+/// The WSS node publishes the SHA-256 of its leaf certificate (over the DER
+/// encoding) at startup and after every rotation, as `CERT_SHA256: <hex>` and
+/// as a `/certhash/` component on `PinnedAddr: <multiaddr>` lines. Every full
+/// handshake logs `CERT_PRESENTED: sha256=<hex> current=<bool>`. The Swift
+/// dialer pins the published value through
+/// `WebSocketTLSConfiguration.pinnedCertificateSHA256`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSCertPinningInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+
+@Suite("WSS Certificate Pinning Interop Tests", .serialized)
+struct WSSCertPinningInteropTests {
+
+    @Test("A pinned fingerprint connects until the node rotates away from it", .timeLimit(.minutes(2)))
+    func pinnedFingerprintFollowsRotation() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        // Trust both baked-in certificates so only the pin decides
+        let trusted = [harness.serverCertificatePEM, try harness.certificatePEM(at: "/cert2.pem")]
+
+        let startup = try await Self.waitForLines(harness, prefix: "CERT_SHA256: ", count: 1)
+        let oldFingerprint = try #require(Self.fingerprints(in: startup).first)
+        let oldPinned = Self.pinnedHashes(in: startup)
+        #expect(!oldPinned.isEmpty)
+        #expect(oldPinned.allSatisfy { $0 == [0x12, 0x20] + oldFingerprint })
+        #expect(startup.contains("/p2p/\(harness.nodeInfo.peerID)"))
+
+        let connection = try await Self.connect(harness, trusting: trusted, pinning: oldFingerprint)
+        try await connection.close()
+        let presented = try await Self.waitForLines(harness, prefix: "CERT_PRESENTED: ", count: 1)
+        #expect(presented.contains("CERT_PRESENTED: sha256=\(Self.hex(oldFingerprint)) current=true"))
+
+        try await harness.sendCommand("ROTATE_CERT /cert2.pem /key2.pem")
+        let rotated = try await Self.waitForLines(harness, prefix: "CERT_SHA256: ", count: 2)
+        let newFingerprint = try #require(Self.fingerprints(in: rotated).last)
+        #expect(newFingerprint != oldFingerprint)
+        #expect(Self.pinnedHashes(in: rotated).contains([0x12, 0x20] + newFingerprint))
+
+        // The old pin no longer matches even though the new certificate is trusted
+        await #expect(throws: (any Error).self) {
+            let stale = try await Self.connect(harness, trusting: trusted, pinning: oldFingerprint)
+            try await stale.close()
+        }
+
+        let fresh = try await Self.connect(harness, trusting: trusted, pinning: newFingerprint)
+        try await fresh.close()
+        let afterRotation = try await Self.waitForLines(harness, prefix: "CERT_PRESENTED: ", count: 3)
+        #expect(afterRotation.contains("CERT_PRESENTED: sha256=\(Self.hex(newFingerprint)) current=true"))
+        #expect(!afterRotation.contains("current=false"))
+    }
+
+    // MARK: - Helpers
+
+    /// Dials the node over WSS, trusting `pems` and pinning `fingerprint`.
+    private static func connect(
+        _ harness: GoWSSHarness,
+        trusting pems: [String],
+        pinning fingerprint: [UInt8]
+    ) async throws -> MuxedConnection {
+        var certificates: [NIOSSLCertificate] = []
+        for pem in pems {
+            certificates += try NIOSSLCertificate.fromPEMBytes(Array(pem.utf8))
+        }
+
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(certificates)
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(
+            client: clientTLS,
+            pinnedCertificateSHA256: [fingerprint]
+        ))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    /// The digests of the `CERT_SHA256:` lines, in order.
+    private static func fingerprints(in logs: String) -> [[UInt8]] {
+        logs.split(separator: "\n")
+            .filter { $0.hasPrefix("CERT_SHA256: ") }
+            .compactMap { Data(hexString: String($0.dropFirst("CERT_SHA256: ".count))).map { Array($0) } }
+    }
+
+    /// The /certhash/ multihashes of the `PinnedAddr:` lines. go-libp2p
+    /// advertises wss as /tls/ws, which Multiaddr does not parse, so only the
+    /// certhash component is decoded.
+    private static func pinnedHashes(in logs: String) -> [[UInt8]] {
+        logs.split(separator: "\n")
+            .filter { $0.hasPrefix("PinnedAddr: ") }
+            .compactMap { line -> [UInt8]? in
+                let components = line.split(separator: "/")
+                guard let index = components.firstIndex(of: "certhash"), index + 1 < components.count else {
+                    Issue.record("No certhash in \(line)")
+                    return nil
+                }
+                do {
+                    let certhash = try Multiaddr("/certhash/\(components[index + 1])")
+                    guard case .certhash(let hash) = certhash.protocols.first else { return nil }
+                    return Array(hash)
+                } catch {
+                    Issue.record("Unparseable certhash in \(line): \(error)")
+                    return nil
+                }
+            }
+    }
+
+    private static func hex(_ bytes: [UInt8]) -> String {
+        bytes.map { String(format: "%02x", $0) }.joined()
+    }
+
+    /// Polls the node's logs until `count` lines start with `prefix`.
+    private static func waitForLines(_ harness: GoWSSHarness, prefix: String, count: Int) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.split(separator: "\n").filter({ $0.hasPrefix(prefix) }).count >= count {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(count) \(prefix) lines did not appear in the WSS node logs:\n\(logs)")
+        return logs
+    }
+}
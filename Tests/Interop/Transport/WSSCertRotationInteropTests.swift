@@ -0,0 +1,181 @@
+/// WSSCertRotationInteropTests - TLS certificate rotation on a live go-libp2p WSS node
+///
+/// The WSS node serves its certificate through `tls.Config.GetCertificate`
+/// and swaps it on SIGHUP or the stdin command `ROTATE_CERT <cert> <key>`,
+/// logging `CERT_ROTATED: notAfter=... sha256=...`. A rotation must leave
+/// established connections (and their streams) open while new connections
+/// get the new certificate; a file that fails to load keeps the old one.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSCertRotationInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS Certificate Rotation Interop Tests", .serialized)
+struct WSSCertRotationInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("An echo stream survives ROTATE_CERT and new connections see the new certificate", .timeLimit(.minutes(2)))
+    func rotateKeepsEstablishedStream() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let oldPEM = harness.serverCertificatePEM
+        let newPEM = try harness.certificatePEM(at: "/cert2.pem")
+
+        let connection = try await Self.connect(harness, trusting: [oldPEM, newPEM])
+        let stream = try await Self.openEcho(on: connection)
+        try await Self.echo(Array("before rotation".utf8), on: stream)
+
+        let loadedLogs = try await Self.waitForLog(harness, containing: "CERT_LOADED: ")
+        let oldFingerprint = try #require(Self.fingerprint(in: loadedLogs, prefix: "CERT_LOADED: "))
+
+        try await harness.sendCommand("ROTATE_CERT /cert2.pem /key2.pem")
+        let rotatedLogs = try await Self.waitForLog(harness, containing: "CERT_ROTATED: ")
+        let newFingerprint = try #require(Self.fingerprint(in: rotatedLogs, prefix: "CERT_ROTATED: "))
+        #expect(newFingerprint != oldFingerprint)
+
+        // The stream opened before the swap keeps echoing on the old session
+        try await Self.echo(Array("after rotation".utf8), on: stream)
+
+        // A client that only trusts the new certificate can now connect...
+        let fresh = try await Self.connect(harness, trusting: [newPEM])
+        let freshStream = try await Self.openEcho(on: fresh)
+        try await Self.echo(Array("new certificate".utf8), on: freshStream)
+
+        // ...and one that only trusts the old certificate no longer can
+        await #expect(throws: (any Error).self) {
+            let stale = try await Self.connect(harness, trusting: [oldPEM])
+            try await stale.close()
+        }
+
+        try await Self.echo(Array("still open".utf8), on: stream)
+        try await stream.close()
+        try await freshStream.close()
+        try await connection.close()
+        try await fresh.close()
+    }
+
+    @Test("A failed rotation keeps serving the old certificate", .timeLimit(.minutes(2)))
+    func failedRotationKeepsOldCertificate() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        try await harness.sendCommand("ROTATE_CERT /missing.pem /key2.pem")
+        let logs = try await Self.waitForLog(harness, containing: "CERT_ROTATE_FAILED: ")
+        #expect(logs.contains("CERT_ROTATE_FAILED: source=command err="))
+        #expect(!logs.contains("CERT_ROTATED: "))
+
+        // The node is still up and still presents the original certificate
+        let connection = try await Self.connect(harness, trusting: [harness.serverCertificatePEM])
+        let stream = try await Self.openEcho(on: connection)
+        try await Self.echo(Array("old certificate".utf8), on: stream)
+        try await stream.close()
+        try await connection.close()
+    }
+
+    @Test("SIGHUP reloads the current certificate files", .timeLimit(.minutes(2)))
+    func sighupReloads() async throws {
+        let harness = try await GoWSSHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let connection = try await Self.connect(harness, trusting: [harness.serverCertificatePEM])
+        let stream = try await Self.openEcho(on: connection)
+
+        try await harness.signal("HUP")
+        let logs = try await Self.waitForLog(harness, containing: "CERT_ROTATED: ")
+        // The files did not change, so neither did the certificate
+        #expect(
+            Self.fingerprint(in: logs, prefix: "CERT_ROTATED: ")
+                == Self.fingerprint(in: logs, prefix: "CERT_LOADED: ")
+        )
+
+        try await Self.echo(Array("after sighup".utf8), on: stream)
+        try await stream.close()
+        try await connection.close()
+    }
+
+    // MARK: - Helpers
+
+    /// Dials the node over WSS with a client that trusts only `pems`.
+    private static func connect(_ harness: GoWSSHarness, trusting pems: [String]) async throws -> MuxedConnection {
+        var certificates: [NIOSSLCertificate] = []
+        for pem in pems {
+            certificates += try NIOSSLCertificate.fromPEMBytes(Array(pem.utf8))
+        }
+
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(certificates)
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func openEcho(on connection: MuxedConnection) async throws -> MuxedStream {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+        return stream
+    }
+
+    private static func echo(_ payload: [UInt8], on stream: MuxedStream) async throws {
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+    }
+
+    /// Extracts the `sha256=` value from the first log line starting with `prefix`.
+    private static func fingerprint(in logs: String, prefix: String) -> String? {
+        guard let line = logs.split(separator: "\n").first(where: { $0.hasPrefix(prefix) }),
+              let field = line.split(separator: " ").first(where: { $0.hasPrefix("sha256=") }) else {
+            return nil
+        }
+        return String(field.dropFirst("sha256=".count))
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoWSSHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the WSS node logs:\n\(logs)")
+        return logs
+    }
+}
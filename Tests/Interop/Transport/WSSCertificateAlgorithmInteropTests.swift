@@ -0,0 +1,120 @@
+/// WSSCertificateAlgorithmInteropTests - Swift dialer against WSS certificates of each key type
+///
+/// With `CERT_ALGO=rsa2048|ecdsa-p256|ecdsa-p384|ed25519` and no `CERT_FILE`,
+/// the WSS node generates a self-signed localhost certificate of that type at
+/// startup. Generated or loaded, the served certificate is reported as
+/// `CERT_INFO: algo= sha256= notBefore= notAfter= file= source=`, which the
+/// harness parses so the dialer can pre-trust it.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSCertificateAlgorithmInteropTests
+
+import Testing
+import Foundation
+import Crypto
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS Certificate Algorithm Interop Tests", .serialized)
+struct WSSCertificateAlgorithmInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test(
+        "The Swift dialer verifies a generated certificate",
+        .timeLimit(.minutes(2)),
+        arguments: ["rsa2048", "ecdsa-p256", "ecdsa-p384", "ed25519"]
+    )
+    func dialsGeneratedCertificate(algorithm: String) async throws {
+        let harness = try await GoWSSHarness.start(certAlgorithm: algorithm)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let info = harness.certificateInfo
+        #expect(info.algorithm == algorithm)
+        #expect(info.generated)
+        #expect(info.file == "/generated-cert.pem")
+        #expect(info.notBefore < Date())
+        #expect(info.notAfter > Date().addingTimeInterval(300 * 24 * 60 * 60))
+        #expect(try Self.fingerprint(of: harness.serverCertificatePEM) == info.sha256)
+
+        let connection = try await Self.connect(harness)
+        try await Self.echo(Array(algorithm.utf8), on: connection)
+        try await connection.close()
+    }
+
+    @Test("The baked-in certificate is reported the same way", .timeLimit(.minutes(2)))
+    func reportsLoadedCertificate() async throws {
+        let harness = try await GoWSSHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let info = harness.certificateInfo
+        #expect(info.algorithm == "rsa2048")
+        #expect(!info.generated)
+        #expect(info.file == "/cert.pem")
+        #expect(try Self.fingerprint(of: harness.serverCertificatePEM) == info.sha256)
+
+        let logs = await harness.logs()
+        #expect(logs.contains("CERT_INFO: algo=rsa2048 sha256=\(info.sha256) "))
+        #expect(logs.contains(" file=/cert.pem source=file"))
+    }
+
+    // MARK: - Helpers
+
+    /// Hex SHA-256 of the PEM certificate's DER encoding.
+    private static func fingerprint(of pem: String) throws -> String {
+        let certificate = try NIOSSLCertificate(bytes: Array(pem.utf8), format: .pem)
+        return SHA256.hash(data: try certificate.toDERBytes())
+            .map { String(format: "%02x", $0) }
+            .joined()
+    }
+
+    /// Dials the node over WSS, trusting only its certificate.
+    private static func connect(_ harness: GoWSSHarness) async throws -> MuxedConnection {
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(
+            try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
+        )
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+        try await stream.close()
+    }
+}
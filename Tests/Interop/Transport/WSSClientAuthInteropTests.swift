@@ -0,0 +1,178 @@
+/// WSSClientAuthInteropTests - Mutual TLS between the Swift dialer and a go-libp2p WSS node
+///
+/// With `CLIENT_AUTH=require|request`, the WSS node asks for a client
+/// certificate, checks it against `CLIENT_CA_FILE` and logs
+/// `TLS_CLIENT_CERT: present=<bool> subject=<dn> verified=<bool>` for every
+/// handshake. In require mode a missing or unverified certificate fails the
+/// handshake with a bad_certificate alert, logged as
+/// `TLS_CLIENT_AUTH_FAILED: alert=bad_certificate err=...`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSClientAuthInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS Client Auth Interop Tests", .serialized)
+struct WSSClientAuthInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("require accepts a client certificate signed by the client CA", .timeLimit(.minutes(2)))
+    func requireAcceptsTrustedCertificate() async throws {
+        let harness = try await GoWSSHarness.start(clientAuth: "require")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let identity = try Self.clientIdentity(harness, certificate: "/client-cert.pem", key: "/client-key.pem")
+        let connection = try await Self.connect(harness, presenting: identity)
+        try await Self.echo(Array("mutual tls".utf8), on: connection)
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_CLIENT_CERT: ")
+        #expect(logs.contains("TLS_CLIENT_CERT: present=true subject=CN=swift-client verified=true"))
+        #expect(!logs.contains("TLS_CLIENT_AUTH_FAILED: "))
+        try await connection.close()
+    }
+
+    @Test("require rejects a dialer without a client certificate", .timeLimit(.minutes(2)))
+    func requireRejectsMissingCertificate() async throws {
+        let harness = try await GoWSSHarness.start(clientAuth: "require")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(harness, presenting: nil)
+            try await connection.close()
+        }
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_CLIENT_AUTH_FAILED: ")
+        #expect(logs.contains("TLS_CLIENT_CERT: present=false subject= verified=false"))
+        #expect(logs.contains("TLS_CLIENT_AUTH_FAILED: alert=bad_certificate err=no client certificate presented"))
+    }
+
+    @Test("require rejects a certificate the client CA did not sign", .timeLimit(.minutes(2)))
+    func requireRejectsUntrustedCertificate() async throws {
+        let harness = try await GoWSSHarness.start(clientAuth: "require")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let identity = try Self.clientIdentity(
+            harness,
+            certificate: "/client-untrusted-cert.pem",
+            key: "/client-untrusted-key.pem"
+        )
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(harness, presenting: identity)
+            try await connection.close()
+        }
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_CLIENT_AUTH_FAILED: ")
+        #expect(logs.contains("TLS_CLIENT_CERT: present=true subject=CN=stranger verified=false"))
+        #expect(logs.contains("TLS_CLIENT_AUTH_FAILED: alert=bad_certificate err=x509: "))
+    }
+
+    @Test("request lets an unverified certificate through and logs it", .timeLimit(.minutes(2)))
+    func requestAllowsUnverifiedCertificate() async throws {
+        let harness = try await GoWSSHarness.start(clientAuth: "request")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let identity = try Self.clientIdentity(
+            harness,
+            certificate: "/client-untrusted-cert.pem",
+            key: "/client-untrusted-key.pem"
+        )
+        let connection = try await Self.connect(harness, presenting: identity)
+        try await Self.echo(Array("requested".utf8), on: connection)
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_CLIENT_CERT: ")
+        #expect(logs.contains("TLS_CLIENT_CERT: present=true subject=CN=stranger verified=false"))
+        #expect(!logs.contains("TLS_CLIENT_AUTH_FAILED: "))
+        try await connection.close()
+    }
+
+    // MARK: - Helpers
+
+    private struct ClientIdentity {
+        let chain: [NIOSSLCertificate]
+        let key: NIOSSLPrivateKey
+    }
+
+    /// Reads a client certificate and key from the node's image.
+    private static func clientIdentity(
+        _ harness: GoWSSHarness,
+        certificate: String,
+        key: String
+    ) throws -> ClientIdentity {
+        ClientIdentity(
+            chain: try NIOSSLCertificate.fromPEMBytes(Array(harness.certificatePEM(at: certificate).utf8)),
+            key: try NIOSSLPrivateKey(bytes: Array(harness.privateKeyPEM(at: key).utf8), format: .pem)
+        )
+    }
+
+    /// Dials the node over WSS, presenting `identity` as the client certificate.
+    private static func connect(_ harness: GoWSSHarness, presenting identity: ClientIdentity?) async throws -> MuxedConnection {
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(
+            try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
+        )
+        if let identity {
+            clientTLS.certificateChain = identity.chain.map { .certificate($0) }
+            clientTLS.privateKey = .privateKey(identity.key)
+        }
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+        try await stream.close()
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoWSSHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the WSS node logs:\n\(logs)")
+        return logs
+    }
+}
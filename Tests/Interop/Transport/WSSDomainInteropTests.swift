@@ -0,0 +1,141 @@
+/// WSSDomainInteropTests - Dialing a go-libp2p WSS node by DNS name
+///
+/// With `DOMAIN` set, the WSS node serves a certificate for that name,
+/// advertises `/dns4/<domain>/tcp/<port>/wss` next to its IP addresses and
+/// logs the SNI of every handshake as `TLS_SNI: <name>`. A name its
+/// certificate does not cover is logged as `TLS_SNI_MISMATCH:` with the
+/// verification error and left for the client to refuse.
+///
+/// The container joins its own Docker network under the domain as an alias.
+/// The domain is a `*.localhost` name, so the test host resolves it to the
+/// loopback address the node's port is published on.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSDomainInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS Domain Interop Tests", .serialized)
+struct WSSDomainInteropTests {
+
+    static let domain = "wss-node.localhost"
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("The node advertises its domain and accepts a dial by that name", .timeLimit(.minutes(2)))
+    func dialByDomain() async throws {
+        let harness = try await GoWSSHarness.start(domain: Self.domain)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        #expect(harness.nodeInfo.serverHostname == Self.domain)
+        let logs = await harness.logs()
+        #expect(logs.contains("CERT_DOMAIN: domain=\(Self.domain) source=generated"))
+        #expect(logs.contains("Listen: /dns4/\(Self.domain)/tcp/4001/wss/p2p/\(harness.nodeInfo.peerID)"))
+        #expect(logs.contains("Listen: /ip4/"))
+
+        let connection = try await Self.connect(to: harness.nodeInfo.address, trusting: harness.serverCertificatePEM)
+        #expect(connection.remotePeer.description == harness.nodeInfo.peerID)
+        try await Self.echo(Array("by name".utf8), on: connection)
+
+        let handshakeLogs = try await Self.waitForLog(harness, containing: "TLS_SNI: \(Self.domain)")
+        #expect(!handshakeLogs.contains("TLS_SNI_MISMATCH: "))
+        try await connection.close()
+    }
+
+    @Test("Another name the certificate covers still completes", .timeLimit(.minutes(2)))
+    func coveredNameCompletes() async throws {
+        let harness = try await GoWSSHarness.start(domain: Self.domain)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        // The generated certificate covers localhost as well as the domain
+        let address = harness.nodeInfo.address.replacingOccurrences(of: "/dns4/\(Self.domain)/", with: "/dns4/localhost/")
+        let connection = try await Self.connect(to: address, trusting: harness.serverCertificatePEM)
+        try await Self.echo(Array("covered".utf8), on: connection)
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_SNI: localhost")
+        #expect(!logs.contains("TLS_SNI_MISMATCH: "))
+        try await connection.close()
+    }
+
+    @Test("A name the certificate does not cover is refused and its reason logged", .timeLimit(.minutes(2)))
+    func uncoveredNameLogsReason() async throws {
+        let harness = try await GoWSSHarness.start(domain: Self.domain)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let name = "other.localhost"
+        let address = harness.nodeInfo.address.replacingOccurrences(of: "/dns4/\(Self.domain)/", with: "/dns4/\(name)/")
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(to: address, trusting: harness.serverCertificatePEM)
+            try await connection.close()
+        }
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_SNI_MISMATCH: name=\(name) err=")
+        #expect(logs.contains("TLS_SNI: \(name)"))
+    }
+
+    // MARK: - Helpers
+
+    /// Dials `address` over WSS with a client that trusts only `pem`.
+    private static func connect(to address: String, trusting pem: String) async throws -> MuxedConnection {
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(try NIOSSLCertificate.fromPEMBytes(Array(pem.utf8)))
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+        try await stream.close()
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoWSSHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the WSS node logs:\n\(logs)")
+        return logs
+    }
+}
@@ -0,0 +1,219 @@
+/// WSSInlineCertificateInteropTests - WSS certificates passed to the go node as PEM text
+///
+/// `CERT_PEM` and `KEY_PEM` carry the served pair inline (a literal `\n`
+/// stands for a newline) and take precedence over `CERT_FILE`/`KEY_FILE`.
+/// The node writes them to /inline-cert.pem and /inline-key.pem and reports
+/// them with the usual `CERT_INFO:` line, `source=env`, so the harness reads
+/// the certificate the same way as for any other source. A pair that does not
+/// parse fails startup with `malformed CERT_PEM|KEY_PEM`, one whose key does
+/// not match with `CERT_PEM and KEY_PEM do not match`. `CA_PEM` fills the
+/// client CA pool for `CLIENT_AUTH`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSInlineCertificateInteropTests
+
+import Testing
+import Foundation
+import Crypto
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS Inline Certificate Interop Tests", .serialized)
+struct WSSInlineCertificateInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    /// PEM files baked into the node's image.
+    struct ImagePEM: Sendable {
+        let cert: String
+        let key: String
+        let cert2: String
+        let key2: String
+        let clientCA: String
+    }
+
+    @Test("CERT_PEM and KEY_PEM win over CERT_FILE and are reported as CERT_INFO", .timeLimit(.minutes(3)))
+    func servesInlinePair() async throws {
+        let pem = try await Self.imagePEM()
+        let harness = try await GoWSSHarness.start(environment: [
+            "CERT_FILE": "/cert.pem",
+            "KEY_FILE": "/key.pem",
+            "CERT_PEM": pem.cert2,
+            // Escaped newlines are accepted too
+            "KEY_PEM": pem.key2.replacingOccurrences(of: "\n", with: "\\n"),
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let info = harness.certificateInfo
+        #expect(info.file == "/inline-cert.pem")
+        #expect(!info.generated)
+        #expect(info.sha256 == (try Self.fingerprint(of: pem.cert2)))
+        #expect(info.sha256 != (try Self.fingerprint(of: pem.cert)))
+        #expect(await harness.logs().contains(" file=/inline-cert.pem source=env"))
+
+        let connection = try await Self.connect(harness, presenting: nil)
+        try await Self.echo(Array("inline".utf8), on: connection)
+        try await connection.close()
+    }
+
+    @Test("A mismatched pair and malformed PEM fail startup with different errors", .timeLimit(.minutes(3)))
+    func rejectsInvalidPair() async throws {
+        let pem = try await Self.imagePEM()
+
+        let mismatched = try Self.startupError(["CERT_PEM": pem.cert2, "KEY_PEM": pem.key])
+        #expect(mismatched.contains("CERT_PEM and KEY_PEM do not match"))
+        #expect(!mismatched.contains("malformed"))
+
+        let malformed = try Self.startupError(["CERT_PEM": pem.key2, "KEY_PEM": pem.key2])
+        #expect(malformed.contains("malformed CERT_PEM"))
+
+        let truncatedKey = String(pem.key2.prefix(pem.key2.count / 2))
+        let malformedKey = try Self.startupError(["CERT_PEM": pem.cert2, "KEY_PEM": truncatedKey])
+        #expect(malformedKey.contains("malformed KEY_PEM"))
+    }
+
+    @Test("CA_PEM fills the client CA pool for CLIENT_AUTH=require", .timeLimit(.minutes(3)))
+    func caPEMVerifiesClientCertificates() async throws {
+        let pem = try await Self.imagePEM()
+        let harness = try await GoWSSHarness.start(environment: [
+            "CLIENT_AUTH": "require",
+            "CA_PEM": pem.clientCA,
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let trusted = try Self.clientIdentity(harness, certificate: "/client-cert.pem", key: "/client-key.pem")
+        let connection = try await Self.connect(harness, presenting: trusted)
+        try await Self.echo(Array("ca pem".utf8), on: connection)
+        try await connection.close()
+
+        let untrusted = try Self.clientIdentity(
+            harness,
+            certificate: "/client-untrusted-cert.pem",
+            key: "/client-untrusted-key.pem"
+        )
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(harness, presenting: untrusted)
+            try await connection.close()
+        }
+
+        let logs = try await Self.waitForLog(harness, containing: "TLS_CLIENT_AUTH_FAILED: ")
+        #expect(logs.contains("TLS_CLIENT_CERT: present=true subject=CN=swift-client verified=true"))
+        #expect(logs.contains("TLS_CLIENT_CERT: present=true subject=CN=stranger verified=false"))
+    }
+
+    // MARK: - Helpers
+
+    /// Reads the baked-in pairs and client CA from a node started with defaults.
+    private static func imagePEM() async throws -> ImagePEM {
+        let donor = try await GoWSSHarness.start()
+        defer { Task { do { try await donor.stop() } catch { } } }
+        return ImagePEM(
+            cert: try donor.certificatePEM(at: "/cert.pem"),
+            key: try donor.privateKeyPEM(at: "/key.pem"),
+            cert2: try donor.certificatePEM(at: "/cert2.pem"),
+            key2: try donor.privateKeyPEM(at: "/key2.pem"),
+            clientCA: try donor.certificatePEM(at: "/client-ca.pem")
+        )
+    }
+
+    /// Runs a node that is expected to exit at startup and returns its output.
+    private static func startupError(_ environment: [String: String]) throws -> String {
+        let output = try GoWSSHarness.startupOutput(environment: environment)
+        #expect(output.contains("Failed to load certificate: "), "\(output)")
+        return output
+    }
+
+    /// Hex SHA-256 of the PEM certificate's DER encoding.
+    private static func fingerprint(of pem: String) throws -> String {
+        let certificate = try NIOSSLCertificate(bytes: Array(pem.utf8), format: .pem)
+        return SHA256.hash(data: try certificate.toDERBytes())
+            .map { String(format: "%02x", $0) }
+            .joined()
+    }
+
+    private struct ClientIdentity {
+        let chain: [NIOSSLCertificate]
+        let key: NIOSSLPrivateKey
+    }
+
+    /// Reads a client certificate and key from the node's image.
+    private static func clientIdentity(
+        _ harness: GoWSSHarness,
+        certificate: String,
+        key: String
+    ) throws -> ClientIdentity {
+        ClientIdentity(
+            chain: try NIOSSLCertificate.fromPEMBytes(Array(harness.certificatePEM(at: certificate).utf8)),
+            key: try NIOSSLPrivateKey(bytes: Array(harness.privateKeyPEM(at: key).utf8), format: .pem)
+        )
+    }
+
+    /// Dials the node over WSS, trusting only its certificate and presenting
+    /// `identity` as the client certificate.
+    private static func connect(_ harness: GoWSSHarness, presenting identity: ClientIdentity?) async throws -> MuxedConnection {
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        clientTLS.trustRoots = .certificates(
+            try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
+        )
+        if let identity {
+            clientTLS.certificateChain = identity.chain.map { .certificate($0) }
+            clientTLS.privateKey = .privateKey(identity.key)
+        }
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+        try await stream.close()
+    }
+
+    /// Polls the node's logs until `marker` appears.
+    private static func waitForLog(_ harness: GoWSSHarness, containing marker: String) async throws -> String {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if logs.contains(marker) {
+                return logs
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the WSS node logs:\n\(logs)")
+        return logs
+    }
+}
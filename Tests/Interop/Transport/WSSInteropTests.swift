@@ -65,7 +65,10 @@ struct WSSInteropTests {
         throw WSSInteropError.missingCertificate
     }
 
-    private func makeWSSClientTransport(_ harness: GoWSSHarness) throws -> WebSocketTransport {
+    private func makeWSSClientTransport(
+        _ harness: GoWSSHarness,
+        dnsLookup: any DNSLookup = SystemDNSLookup()
+    ) throws -> WebSocketTransport {
         let certificates = try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
         guard !certificates.isEmpty else {
             throw WSSInteropError.missingCertificate
@@ -75,7 +78,13 @@ struct WSSInteropTests {
         clientTLS.certificateVerification = .fullVerification
         clientTLS.trustRoots = .certificates(certificates)
 
-        return WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        return WebSocketTransport(tlsConfiguration: .init(client: clientTLS), dnsLookup: dnsLookup)
+    }
+
+    /// The harness address with `/dns4/` swapped for `/dns/` (or another
+    /// hostname protocol and name).
+    private func dnsAddress(_ harness: GoWSSHarness, as replacement: String = "/dns/localhost/") throws -> Multiaddr {
+        try Multiaddr(harness.nodeInfo.address.replacingOccurrences(of: "/dns4/localhost/", with: replacement))
     }
 
     // MARK: - Connection Tests
@@ -171,6 +180,48 @@ struct WSSInteropTests {
         }
     }
 
+    // MARK: - DNS Tests
+
+    @Test("Dial the go node at /dns/localhost/tcp/<port>/wss and authenticate it", .timeLimit(.minutes(2)))
+    func dialDNSHostname() async throws {
+        try await withWSSHarness { harness in
+            let transport = try makeWSSClientTransport(harness)
+            let rawConnection = try await transport.dial(try dnsAddress(harness))
+
+            // TLS checked the hostname; Noise still authenticates the peer
+            let securityNegotiation = try await MultistreamSelect.negotiate(
+                protocols: ["/noise"],
+                read: { Data(buffer: try await rawConnection.read()) },
+                write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+            )
+            let securedConnection = try await NoiseUpgrader().secure(
+                rawConnection,
+                localKeyPair: KeyPair.generateEd25519(),
+                as: .initiator,
+                expectedPeer: nil,
+                initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+            )
+            #expect(securedConnection.remotePeer.description == harness.nodeInfo.peerID)
+            try await securedConnection.close()
+        }
+    }
+
+    @Test("A hostname the certificate does not cover fails TLS even when it resolves to the node", .timeLimit(.minutes(2)))
+    func dialDNSHostnameNotInCertificate() async throws {
+        let harness = try await GoWSSHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        // relay.invalid resolves to the node, but the certificate is for
+        // localhost, so SNI and verification use the name, not the IP
+        let transport = try makeWSSClientTransport(harness, dnsLookup: LoopbackDNSLookup())
+        await #expect(throws: (any Error).self) {
+            _ = try await transport.dial(try dnsAddress(harness, as: "/dns/relay.invalid/"))
+        }
+
+        let resolved = try await transport.dial(try dnsAddress(harness))
+        try await resolved.close()
+    }
+
     // MARK: - Identify Tests
 
     @Test("Identify go-libp2p node via WSS", .timeLimit(.minutes(2)))
@@ -314,3 +365,14 @@ struct WSSInteropTests {
         }
     }
 }
+
+/// Resolves every hostname to the loopback address.
+private struct LoopbackDNSLookup: DNSLookup {
+    func addresses(of hostname: String, family: DNSAddressFamily) async throws -> DNSAnswer {
+        DNSAnswer(values: [family == .ipv4 ? "127.0.0.1" : "::1"], ttl: .zero)
+    }
+
+    func textRecords(of name: String) async throws -> DNSAnswer {
+        DNSAnswer(values: [], ttl: .zero)
+    }
+}
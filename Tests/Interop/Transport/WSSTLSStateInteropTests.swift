@@ -0,0 +1,194 @@
+/// WSSTLSStateInteropTests - Negotiated TLS parameters between the Swift dialer and a go-libp2p WSS node
+///
+/// The WSS node logs every completed handshake as
+/// `TLS_STATE: version=<v> cipher=<name> alpn=<proto> resumed=<bool>` and
+/// answers the `TLS_STATS` stdin command with one
+/// `TLS_STATS: version=<v> cipher=<name> count=<n>` line per combination,
+/// closed by `TLS_STATS_END: handshakes=<n>`. `TLS_MIN_VERSION`,
+/// `TLS_MAX_VERSION` and `TLS_CIPHER_SUITES` restrict what it negotiates.
+/// A failed handshake is logged as `TLS_FAIL: <json>` with its category and
+/// what the client offered, and counted in `TLS_FAIL_STATS: category=<c>
+/// count=<n>` lines before `TLS_STATS_END`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WSSTLSStateInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WSS TLS State Interop Tests", .serialized)
+struct WSSTLSStateInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("A default handshake negotiates TLS 1.3 and is counted", .timeLimit(.minutes(2)))
+    func defaultHandshakeIsTLS13() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let connection = try await Self.connect(harness)
+        try await Self.echo(Array("tls state".utf8), on: connection)
+
+        let state = try #require(try await Self.waitForLine(harness, prefix: "TLS_STATE: "))
+        #expect(state.hasPrefix("TLS_STATE: version=1.3 cipher=TLS_"))
+        #expect(state.hasSuffix(" resumed=false"))
+
+        try await harness.sendCommand("TLS_STATS")
+        _ = try await Self.waitForLine(harness, prefix: "TLS_STATS_END: ")
+        let logs = await harness.logs()
+        let cipher = try #require(Self.field("cipher", in: state))
+        #expect(logs.contains("TLS_STATS: version=1.3 cipher=\(cipher) count=1"))
+        #expect(logs.contains("TLS_STATS_END: handshakes=1"))
+        try await connection.close()
+    }
+
+    @Test("The Swift dialer downgrades to a TLS 1.2-only node", .timeLimit(.minutes(2)))
+    func downgradesToTLS12() async throws {
+        let harness = try await GoWSSHarness.start(
+            interactive: true,
+            tlsMinVersion: "1.2",
+            tlsMaxVersion: "1.2",
+            tlsCipherSuites: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let logs = await harness.logs()
+        #expect(logs.contains("TLS_POLICY: min=1.2 max=1.2 ciphers=TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"))
+
+        let connection = try await Self.connect(harness)
+        try await Self.echo(Array("downgraded".utf8), on: connection)
+
+        let state = try #require(try await Self.waitForLine(harness, prefix: "TLS_STATE: "))
+        #expect(state.hasPrefix("TLS_STATE: version=1.2 cipher=TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256 "))
+
+        try await harness.sendCommand("TLS_STATS")
+        _ = try await Self.waitForLine(harness, prefix: "TLS_STATS_END: ")
+        let stats = await harness.logs()
+        #expect(stats.contains("TLS_STATS: version=1.2 cipher=TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256 count=1"))
+        #expect(stats.contains("TLS_STATS_END: handshakes=1"))
+        try await connection.close()
+    }
+
+    @Test("A TLS 1.3-only dialer fails against a TLS 1.2-only node", .timeLimit(.minutes(2)))
+    func tls13OnlyDialerFailsAgainstTLS12Node() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true, tlsMinVersion: "1.2", tlsMaxVersion: "1.2")
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(harness, minimumVersion: .tlsv13)
+            try await connection.close()
+        }
+
+        let failure = try #require(try await Self.waitForLine(harness, prefix: "TLS_FAIL: "))
+        #expect(failure.contains(#""category":"version""#))
+        #expect(failure.contains(#""hello":true"#))
+        #expect(failure.contains(#""versions":["1.3"]"#))
+
+        try await harness.sendCommand("TLS_STATS")
+        _ = try await Self.waitForLine(harness, prefix: "TLS_STATS_END: ")
+        let logs = await harness.logs()
+        #expect(!logs.contains("TLS_STATE: "))
+        #expect(logs.contains("TLS_FAIL_STATS: category=version count=1"))
+        #expect(logs.contains("TLS_STATS_END: handshakes=0"))
+    }
+
+    @Test("A dialer that does not trust the certificate is logged as a cert failure", .timeLimit(.minutes(2)))
+    func untrustedCertificateIsCategorized() async throws {
+        let harness = try await GoWSSHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        await #expect(throws: (any Error).self) {
+            let connection = try await Self.connect(harness, trustNode: false)
+            try await connection.close()
+        }
+
+        let failure = try #require(try await Self.waitForLine(harness, prefix: "TLS_FAIL: "))
+        #expect(failure.contains(#""category":"cert""#))
+        #expect(failure.contains(#""hello":true"#))
+    }
+
+    // MARK: - Helpers
+
+    /// Dials the node over WSS, trusting its certificate unless `trustNode`
+    /// is false.
+    private static func connect(
+        _ harness: GoWSSHarness,
+        minimumVersion: TLSVersion? = nil,
+        trustNode: Bool = true
+    ) async throws -> MuxedConnection {
+        var clientTLS = TLSConfiguration.makeClientConfiguration()
+        clientTLS.certificateVerification = .fullVerification
+        if trustNode {
+            clientTLS.trustRoots = .certificates(
+                try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
+            )
+        }
+        if let minimumVersion {
+            clientTLS.minimumTLSVersion = minimumVersion
+        }
+
+        let transport = WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        let rawConnection = try await transport.dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = ByteBuffer()
+        while echoed.readableBytes < payload.count {
+            var chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed.writeBuffer(&chunk)
+        }
+        #expect(Array(echoed.readableBytesView) == payload)
+        try await stream.close()
+    }
+
+    /// The value of `name=` in a log line.
+    private static func field(_ name: String, in line: String) -> String? {
+        line.split(separator: " ")
+            .first { $0.hasPrefix("\(name)=") }
+            .map { String($0.dropFirst(name.count + 1)) }
+    }
+
+    /// Polls the node's logs until a line starting with `prefix` appears.
+    private static func waitForLine(_ harness: GoWSSHarness, prefix: String) async throws -> String? {
+        var logs = ""
+        for _ in 0..<50 {
+            logs = await harness.logs()
+            if let line = logs.split(separator: "\n").first(where: { $0.hasPrefix(prefix) }) {
+                return String(line)
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(prefix) did not appear in the WSS node logs:\n\(logs)")
+        return nil
+    }
+}
@@ -0,0 +1,199 @@
+/// WebSocketBandwidthInteropTests - Byte counters on the go ws node
+///
+/// The go ws and wss nodes register a bandwidth counter as the host's
+/// reporter, so every stream's bytes are counted, ping and identify as well
+/// as echo. The stdin command `BANDWIDTH [peerID]` prints
+/// `BANDWIDTH: scope=total|peer|protocol ... in= out= rate_in= rate_out=`
+/// lines ending with `BANDWIDTH_END: peers= protocols=`, `BANDWIDTH_RESET`
+/// zeroes the counters, and `BW_INTERVAL_S=<n>` prints a
+/// `BW: t_ms= in= out= rate_in= rate_out= peers=` line every n seconds.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketBandwidthInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+@testable import P2PProtocols
+
+@Suite("WebSocket Bandwidth Interop Tests", .serialized)
+struct WebSocketBandwidthInteropTests {
+
+    static let echoProtocol = "/test/echo/1.0.0"
+
+    @Test("BANDWIDTH counts echo and ping bytes per peer and per protocol", .timeLimit(.minutes(2)))
+    func countsPerPeerAndProtocol() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let keyPair = KeyPair.generateEd25519()
+        let connection = try await Self.connect(harness, keyPair: keyPair)
+        let payload = (0..<100_000).map { UInt8(truncatingIfNeeded: $0) }
+        try await Self.echo(payload, on: connection)
+        try await Self.ping(on: connection)
+
+        try await harness.sendCommand("BANDWIDTH \(keyPair.peerID)")
+        let logs = try await Self.waitForLog(harness.logs, containing: "BANDWIDTH_END: ")
+        #expect(logs.contains("BANDWIDTH_END: peers=1 "))
+
+        let peer = try #require(Self.counters(in: logs, line: "BANDWIDTH: scope=peer peer=\(keyPair.peerID) "))
+        #expect(peer.received >= payload.count + 32)
+        #expect(peer.sent >= payload.count + 32)
+
+        let echo = try #require(Self.counters(in: logs, line: "BANDWIDTH: scope=protocol protocol=\(Self.echoProtocol) "))
+        #expect(echo.received >= payload.count)
+        #expect(echo.sent >= payload.count)
+        let ping = try #require(Self.counters(in: logs, line: "BANDWIDTH: scope=protocol protocol=\(ProtocolID.ping) "))
+        #expect(ping.received >= 32)
+        #expect(ping.sent >= 32)
+
+        let total = try #require(Self.counters(in: logs, line: "BANDWIDTH: scope=total "))
+        #expect(total.received >= peer.received)
+        #expect(total.sent >= peer.sent)
+
+        try await connection.close()
+    }
+
+    @Test("BANDWIDTH_RESET zeroes the counters", .timeLimit(.minutes(2)))
+    func resetZeroesCounters() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let connection = try await Self.connect(harness, keyPair: .generateEd25519())
+        try await Self.ping(on: connection)
+        try await connection.close()
+
+        try await harness.sendCommand("BANDWIDTH_RESET")
+        _ = try await Self.waitForLog(harness.logs, containing: "BANDWIDTH_RESET: ok")
+        try await harness.sendCommand("BANDWIDTH")
+        let logs = try await Self.waitForLog(harness.logs, containing: "BANDWIDTH_END: ")
+        #expect(logs.contains("BANDWIDTH: scope=total in=0 out=0 "))
+        #expect(logs.contains("BANDWIDTH_END: peers=0 protocols=0"))
+    }
+
+    @Test("BW_INTERVAL_S prints a running total while traffic flows", .timeLimit(.minutes(2)))
+    func periodicSummary() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: ["BW_INTERVAL_S": "1"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let connection = try await Self.connect(harness, keyPair: .generateEd25519())
+        let payload = [UInt8](repeating: 0x42, count: 50_000)
+        try await Self.echo(payload, on: connection)
+
+        // The line after the echo shows its bytes
+        var summaries: [(t: Int, received: Int)] = []
+        for _ in 0..<50 {
+            summaries = await Self.summaries(in: harness.logs())
+            if let last = summaries.last, last.received >= payload.count {
+                break
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        let last = try #require(summaries.last)
+        #expect(last.received >= payload.count)
+        #expect(summaries.count >= 2)
+        #expect(summaries.map(\.t) == summaries.map(\.t).sorted())
+
+        try await connection.close()
+    }
+
+    // MARK: - Helpers
+
+    private static func connect(_ harness: GoWebSocketHarness, keyPair: KeyPair) async throws -> MuxedConnection {
+        let rawConnection = try await WebSocketTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let result = try await NegotiatingUpgrader(
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()]
+        ).upgrade(
+            rawConnection,
+            localKeyPair: keyPair,
+            role: .initiator,
+            expectedPeer: nil
+        )
+        return result.connection
+    }
+
+    private static func echo(_ payload: [UInt8], on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [echoProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == echoProtocol)
+
+        try await stream.write(ByteBuffer(bytes: payload))
+        var echoed = 0
+        while echoed < payload.count {
+            let chunk = try await stream.read()
+            if chunk.readableBytes == 0 { break }
+            echoed += chunk.readableBytes
+        }
+        #expect(echoed == payload.count)
+        try await stream.close()
+    }
+
+    /// Sends one 32-byte ping probe.
+    private static func ping(on connection: MuxedConnection) async throws {
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [ProtocolID.ping],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == ProtocolID.ping)
+
+        let probe = (0..<32).map { _ in UInt8.random(in: 0...255) }
+        try await stream.write(ByteBuffer(bytes: probe))
+        #expect(Array(try await stream.read().readableBytesView) == probe)
+        try await stream.close()
+    }
+
+    /// The `in=` and `out=` byte counts, as seen by the go node, on the first
+    /// log line starting with `line`.
+    private static func counters(in logs: String, line prefix: String) -> (received: Int, sent: Int)? {
+        guard let line = logs.split(separator: "\n").first(where: { $0.hasPrefix(prefix) }),
+              let bytesIn = field("in", of: line),
+              let bytesOut = field("out", of: line) else {
+            return nil
+        }
+        return (bytesIn, bytesOut)
+    }
+
+    /// `t_ms=` and `in=` of every `BW:` line.
+    private static func summaries(in logs: String) -> [(t: Int, received: Int)] {
+        logs.split(separator: "\n")
+            .filter { $0.hasPrefix("BW: ") }
+            .compactMap { line in
+                guard let t = field("t_ms", of: line), let bytesIn = field("in", of: line) else { return nil }
+                return (t, bytesIn)
+            }
+    }
+
+    private static func field(_ name: String, of line: Substring) -> Int? {
+        line.split(separator: " ")
+            .first { $0.hasPrefix("\(name)=") }
+            .flatMap { Int($0.dropFirst(name.count + 1)) }
+    }
+
+    /// Polls the node's logs until marker appears.
+    private static func waitForLog(_ logs: () async -> String, containing marker: String) async throws -> String {
+        var output = ""
+        for _ in 0..<50 {
+            output = await logs()
+            if output.contains(marker) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(output)")
+        return output
+    }
+}
@@ -0,0 +1,214 @@
+/// WebSocketDialInteropTests - go-libp2p WebSocket nodes dialing a Swift listener
+///
+/// The ws and wss nodes act as initiator through their DIAL and PING stdin
+/// commands: DIAL connects to a /ws or /wss multiaddr, waits for identify and
+/// round-trips a 64KB payload over /test/echo/1.0.0. Every stage prints
+/// `DIAL_STAGE: stage=<stage> result=ok`, and the first one to fail prints
+/// `DIAL_FAILED: stage=<stage>`, so a failure names the layer
+/// (ws-connect, security, muxer, identify, echo) that broke.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketDialInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PIdentify
+@testable import P2PPing
+
+@Suite("WebSocket Node Dial Interop Tests", .serialized)
+struct WebSocketDialInteropTests {
+
+    static let connectStages = ["ws-connect", "security", "muxer", "identify"]
+
+    @Test("go dials a Swift /ws listener and verifies a 64KB echo", .timeLimit(.minutes(2)))
+    func dialEchoOverWS() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(listenAddress: .ws(host: "0.0.0.0", port: port), transport: WebSocketTransport())
+        await EchoProtocol.registerEcho(on: node)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/ws/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        for stage in Self.connectStages + ["echo"] {
+            #expect(logs.contains("DIAL_STAGE: stage=\(stage) result=ok"))
+        }
+        #expect(logs.contains("DIAL_ECHO_OK: peer=\(peerID) bytes=65536 rtt_ms="))
+    }
+
+    @Test("DIAL reports the echo stage when echo is not served", .timeLimit(.minutes(2)))
+    func dialWithoutEchoHandler() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(listenAddress: .ws(host: "0.0.0.0", port: port), transport: WebSocketTransport())
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/ws/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_STAGE: stage=identify result=ok"))
+        #expect(logs.contains("DIAL_FAILED: stage=echo "))
+    }
+
+    @Test("DIAL reports the ws-connect stage when nothing is listening", .timeLimit(.minutes(2)))
+    func dialUnreachable() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let peerID = KeyPair.generateEd25519().peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/ws/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_FAILED: stage=ws-connect "))
+        #expect(!logs.contains("DIAL_STAGE: "))
+    }
+
+    @Test("go pings a Swift /ws listener", .timeLimit(.minutes(2)))
+    func pingOverWS() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(listenAddress: .ws(host: "0.0.0.0", port: port), transport: WebSocketTransport())
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("PING /dns4/host.docker.internal/tcp/\(port)/ws/p2p/\(peerID) 3")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["PING_DONE: ", "PING_FAILED: stage="])
+        for stage in Self.connectStages {
+            #expect(logs.contains("PING_STAGE: stage=\(stage) result=ok"))
+        }
+        for seq in 1...3 {
+            #expect(logs.contains("PING_RTT: peer=\(peerID) seq=\(seq) rtt_ms="))
+        }
+        #expect(logs.contains("PING_DONE: peer=\(peerID) sent=3 ok=3"))
+    }
+
+    @Test("go dials a Swift /wss listener with a self-signed certificate", .timeLimit(.minutes(2)))
+    func dialEchoOverWSS() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true, insecureSkipVerify: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(
+            listenAddress: .wss(host: "0.0.0.0", port: port),
+            transport: WebSocketTransport(tlsConfiguration: try Self.serverTLS(from: harness))
+        )
+        await EchoProtocol.registerEcho(on: node)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/wss/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        for stage in Self.connectStages + ["echo"] {
+            #expect(logs.contains("DIAL_STAGE: stage=\(stage) result=ok"))
+        }
+        #expect(logs.contains("DIAL_ECHO_OK: peer=\(peerID) bytes=65536 rtt_ms="))
+    }
+
+    @Test("A verifying dialer fails at ws-connect on a self-signed certificate", .timeLimit(.minutes(2)))
+    func dialVerifiesCertificate() async throws {
+        let harness = try await GoWSSHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(
+            listenAddress: .wss(host: "0.0.0.0", port: port),
+            transport: WebSocketTransport(tlsConfiguration: try Self.serverTLS(from: harness))
+        )
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/wss/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        let failure = try #require(logs.components(separatedBy: "\n").first { $0.hasPrefix("DIAL_FAILED: ") })
+        #expect(failure.hasPrefix("DIAL_FAILED: stage=ws-connect "))
+        #expect(failure.contains("x509"))
+    }
+
+    // MARK: - Helpers
+
+    /// Serves the image's second self-signed localhost pair.
+    private static func serverTLS(from harness: GoWSSHarness) throws -> WebSocketTLSConfiguration {
+        let certificates = try NIOSSLCertificate.fromPEMBytes(Array(harness.certificatePEM(at: "/cert2.pem").utf8))
+        let key = try NIOSSLPrivateKey(bytes: Array(harness.privateKeyPEM(at: "/key2.pem").utf8), format: .pem)
+        return WebSocketTLSConfiguration(server: .makeServerConfiguration(
+            certificateChain: certificates.map { .certificate($0) },
+            privateKey: .privateKey(key)
+        ))
+    }
+
+    private static func makeNode(listenAddress: Multiaddr, transport: WebSocketTransport) -> Node {
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let pingService = PingService()
+        return Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [listenAddress],
+            transports: [transport],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            services: ServicePipeline {
+                service(identifyService) { component in
+                    component.handlesInboundStreams()
+                    component.observesPeers()
+                    component.consumesLocalIdentity()
+                    component.consumesListenAddresses()
+                    component.consumesSupportedProtocols()
+                    component.activatesWithStreamOpening()
+                }
+                service(pingService) { component in
+                    component.handlesInboundStreams()
+                }
+            }
+        ))
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ logs: () async -> String,
+        containing markers: [String],
+        attempts: Int = 60
+    ) async throws -> String {
+        var output = ""
+        for _ in 0..<attempts {
+            output = await logs()
+            if markers.contains(where: { output.contains($0) }) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(output)")
+        return output
+    }
+}
@@ -0,0 +1,183 @@
+/// WebSocketFragmentationInteropTests - Websocket framing and reassembly against the go ws node
+///
+/// The go node serves `/test/frag/1.0.0`: the sender writes a payload in
+/// many small writes, then its 32-byte SHA-256, closes its write side and
+/// reads "OK\n" or "BAD\n" from the receiver. `FRAG_SEND <peerID>
+/// <total_bytes> <write_size>` drives the go side as the sender
+/// (`FRAG_SEND_OK:` / `FRAG_SEND_FAILED:`); received streams log
+/// `FRAG_RECV: peer= bytes= sha256_ok=`.
+///
+/// The go node dials the Swift listener, so it is the websocket client, and
+/// runs with WS_WRITE_BUFFER_BYTES=64: every write becomes its own
+/// websocket message, and those larger than 64 bytes arrive as a first frame
+/// plus continuation frames.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketFragmentationInteropTests
+
+import Testing
+import Foundation
+import Crypto
+import Synchronization
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+
+@Suite("WebSocket Fragmentation Interop Tests", .serialized)
+struct WebSocketFragmentationInteropTests {
+
+    static let fragProtocol = "/test/frag/1.0.0"
+
+    /// A received payload: its size and whether the trailing digest matched.
+    struct Received: Sendable, Equatable {
+        let bytes: Int
+        let digestMatches: Bool
+    }
+
+    @Test("A 1MB payload sent by the go node in small writes is reassembled", .timeLimit(.minutes(10)))
+    func goSendsFragmentedPayload() async throws {
+        let harness = try await GoWebSocketHarness.start(
+            environment: ["WS_WRITE_BUFFER_BYTES": "64"],
+            interactive: true
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+        #expect(await harness.logs().contains("WS_BUFFERS: read=4096 write=64"))
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [.ws(host: "0.0.0.0", port: port)],
+            transports: [WebSocketTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(600)
+            ),
+            healthCheck: nil
+        ))
+        let received = Mutex<[Received]>([])
+        await EchoProtocol.registerEcho(on: node)
+        await node.handleRaw(Self.fragProtocol) { raw in
+            if let result = await Self.receive(raw) {
+                received.withLock { $0.append(result) }
+            }
+        }
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await harness.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/ws/p2p/\(peerID)")
+        let dialed = try await Self.waitForLog(harness.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        try #require(dialed.contains("DIAL_ECHO_OK: peer=\(peerID)"))
+
+        let total = 1024 * 1024
+        for writeSize in [1023, 7, 1] {
+            try await harness.sendCommand("FRAG_SEND \(peerID) \(total) \(writeSize)")
+            let logs = try await Self.waitForLog(
+                harness.logs,
+                containing: ["FRAG_SEND_OK: peer=\(peerID) bytes=\(total) write_size=\(writeSize) ", "FRAG_SEND_FAILED: "],
+                attempts: 1500
+            )
+            #expect(!logs.contains("FRAG_SEND_FAILED: "), "write size \(writeSize)")
+            #expect(logs.contains(
+                "FRAG_SEND_OK: peer=\(peerID) bytes=\(total) write_size=\(writeSize) writes=\((total + writeSize - 1) / writeSize) "
+            ))
+        }
+        #expect(received.withLock { $0 } == Array(repeating: Received(bytes: total, digestMatches: true), count: 3))
+    }
+
+    @Test("A payload sent by Swift in 7-byte writes is reassembled by the go node", .timeLimit(.minutes(3)))
+    func swiftSendsFragmentedPayload() async throws {
+        let harness = try await GoWebSocketHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let node = Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [],
+            transports: [WebSocketTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        let raw = try await node.newRawStream(to: goPeer, protocol: Self.fragProtocol)
+
+        let payload = (0..<(64 * 1024)).map { _ in UInt8.random(in: 0...255) }
+        var offset = 0
+        while offset < payload.count {
+            let end = min(offset + 7, payload.count)
+            try await raw.write(Array(payload[offset..<end]))
+            offset = end
+        }
+        try await raw.write(Array(SHA256.hash(data: payload)))
+        try await raw.closeWrite()
+
+        var reply: [UInt8] = []
+        while true {
+            let chunk = try await raw.read()
+            if chunk.isEmpty { break }
+            reply += chunk
+        }
+        #expect(reply == Array("OK\n".utf8))
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["FRAG_RECV: "])
+        #expect(logs.contains("FRAG_RECV: peer=\(await node.peerID) bytes=\(payload.count) sha256_ok=true "))
+        try await raw.close()
+    }
+
+    // MARK: - Helpers
+
+    /// Reads one `/test/frag/1.0.0` payload, checks its trailing digest and
+    /// answers OK or BAD.
+    private static func receive(_ raw: RawStream) async -> Received? {
+        do {
+            var data: [UInt8] = []
+            while true {
+                let chunk = try await raw.read()
+                if chunk.isEmpty { break }
+                data += chunk
+            }
+            let digestSize = SHA256.byteCount
+            let payload = data.count >= digestSize ? data.count - digestSize : 0
+            let matches = data.count >= digestSize
+                && Array(SHA256.hash(data: data[..<payload])) == Array(data[payload...])
+            try await raw.write(Array((matches ? "OK\n" : "BAD\n").utf8))
+            try await raw.close()
+            return Received(bytes: payload, digestMatches: matches)
+        } catch {
+            Issue.record("frag stream failed: \(error)")
+            return nil
+        }
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ logs: () async -> String,
+        containing markers: [String],
+        attempts: Int = 100
+    ) async throws -> String {
+        var output = ""
+        for _ in 0..<attempts {
+            output = await logs()
+            if markers.contains(where: output.contains) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(200))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(output)")
+        return output
+    }
+}
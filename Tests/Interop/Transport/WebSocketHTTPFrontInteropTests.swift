@@ -0,0 +1,200 @@
+/// WebSocketHTTPFrontInteropTests - Plain HTTP next to libp2p on the go ws port
+///
+/// With HTTP_FRONT=1 the go ws node answers ordinary HTTP requests on the
+/// port it advertises (200 on /healthz, 404 elsewhere, each logged as
+/// `HTTP: <method> <path> <status>`) and forwards WebSocket upgrades to
+/// libp2p, the way a load balancer health check meets a real deployment.
+/// The front validates each handshake first: a malformed one is refused and
+/// logged as `WS_UPGRADE_FAILED: remote= path= status= err=`, a completed one
+/// as `WS_UPGRADE: remote= path= subprotocol= active=`, and MAX_WS_CONNS
+/// refuses upgrades past the limit with `WS_REJECTED: reason=limit`.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketHTTPFrontInteropTests
+
+import Testing
+import Foundation
+#if canImport(FoundationNetworking)
+import FoundationNetworking
+#endif
+import NIOCore
+@testable import P2PTransportWebSocket
+@testable import P2PTransportTCP
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WebSocket HTTP Front Interop Tests", .serialized)
+struct WebSocketHTTPFrontInteropTests {
+
+    static let environment = ["HTTP_FRONT": "1"]
+
+    @Test("The libp2p port answers plain HTTP", .timeLimit(.minutes(2)))
+    func serverRespondsToHTTPToo() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: Self.environment)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = try #require(try Multiaddr(harness.nodeInfo.address).tcpPort)
+        #expect(try await Self.get("/healthz", port: port) == 200)
+        #expect(try await Self.get("/missing", port: port) == 404)
+
+        let logs = try await Self.waitForLog(harness.logs, containing: "HTTP: GET /missing 404")
+        #expect(logs.contains("HTTP: GET /healthz 200"))
+    }
+
+    @Test("libp2p over /ws still works behind the HTTP front", .timeLimit(.minutes(2)))
+    func upgradeReachesLibp2p() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: Self.environment)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = try #require(try Multiaddr(harness.nodeInfo.address).tcpPort)
+        #expect(try await Self.get("/healthz", port: port) == 200)
+
+        let rawConnection = try await WebSocketTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let securedConnection = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        #expect(securedConnection.remotePeer.description == harness.nodeInfo.peerID)
+
+        _ = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await securedConnection.read()) },
+            write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let connection = try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/test/echo/1.0.0"],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == "/test/echo/1.0.0")
+
+        let payload = Array("through the front".utf8)
+        try await stream.write(ByteBuffer(bytes: payload))
+        try await stream.closeWrite()
+        var echoed = negotiation.remainder
+        while true {
+            let data = try await stream.read()
+            if data.readableBytes == 0 { break }
+            echoed.append(Data(buffer: data))
+        }
+        #expect(Array(echoed) == payload)
+
+        // Upgrades are forwarded and logged as such, not answered by the front
+        let logs = try await Self.waitForLog(harness.logs, containing: "ECHO_DONE: ")
+        #expect(!logs.contains("HTTP: GET / "))
+        #expect(logs.contains(" path=/ subprotocol=none active=1"))
+        #expect(!logs.contains("WS_UPGRADE_FAILED: "))
+
+        try await stream.close()
+        try await connection.close()
+    }
+
+    @Test("Malformed handshakes are refused and logged with their reason", .timeLimit(.minutes(2)))
+    func malformedHandshakes() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: Self.environment)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let port = try #require(try Multiaddr(harness.nodeInfo.address).tcpPort)
+        let key = "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+        let cases: [(headers: String, status: Int, reason: String)] = [
+            ("Upgrade: h2c\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" + key, 400, "bad Upgrade header"),
+            ("Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 8\r\n" + key, 426, "bad version"),
+            ("Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n", 400, "missing key"),
+        ]
+        for (index, testCase) in cases.enumerated() {
+            let path = "/bad-\(index)"
+            let head = try await Self.rawRequest(
+                "GET \(path) HTTP/1.1\r\nHost: 127.0.0.1\r\n\(testCase.headers)\r\n",
+                port: port
+            )
+            #expect(head.hasPrefix("HTTP/1.1 \(testCase.status) "))
+
+            let logs = try await Self.waitForLog(harness.logs, containing: "path=\(path) status=\(testCase.status) err=\(testCase.reason)")
+            #expect(logs.contains("WS_UPGRADE_FAILED: remote="))
+        }
+    }
+
+    @Test("MAX_WS_CONNS refuses upgrades past the limit", .timeLimit(.minutes(2)))
+    func connectionLimit() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: ["MAX_WS_CONNS": "1"])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let address = try Multiaddr(harness.nodeInfo.address)
+        let first = try await WebSocketTransport().dial(address)
+        await #expect(throws: (any Error).self) {
+            let second = try await WebSocketTransport().dial(address)
+            try await second.close()
+        }
+        let logs = try await Self.waitForLog(harness.logs, containing: "WS_REJECTED: reason=limit")
+        #expect(logs.contains(" active=1 max=1"))
+
+        // Closing the first connection frees its slot
+        try await first.close()
+        var admitted = false
+        for _ in 0..<20 where !admitted {
+            do {
+                let third = try await WebSocketTransport().dial(address)
+                try await third.close()
+                admitted = true
+            } catch {
+                try await Task.sleep(for: .milliseconds(100))
+            }
+        }
+        #expect(admitted)
+    }
+
+    // MARK: - Helpers
+
+    /// Sends a hand-written HTTP request over plain TCP and returns the
+    /// response head.
+    private static func rawRequest(_ request: String, port: UInt16) async throws -> String {
+        let connection = try await TCPTransport().dial(try Multiaddr("/ip4/127.0.0.1/tcp/\(port)"))
+        defer { Task { do { try await connection.close() } catch { } } }
+
+        try await connection.write(ByteBuffer(string: request))
+        var response = ""
+        while !response.contains("\r\n\r\n") {
+            let chunk = try await connection.read()
+            if chunk.readableBytes == 0 { break }
+            response += String(buffer: chunk)
+        }
+        return response
+    }
+
+    /// Issues a GET against the node's port on the host and returns the status.
+    private static func get(_ path: String, port: UInt16) async throws -> Int {
+        let url = try #require(URL(string: "http://127.0.0.1:\(port)\(path)"))
+        var request = URLRequest(url: url)
+        request.timeoutInterval = 10
+        let (_, response) = try await URLSession.shared.data(for: request)
+        return try #require(response as? HTTPURLResponse).statusCode
+    }
+
+    /// Polls the node's logs until marker appears.
+    private static func waitForLog(_ logs: () async -> String, containing marker: String) async throws -> String {
+        var output = ""
+        for _ in 0..<50 {
+            output = await logs()
+            if output.contains(marker) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(output)")
+        return output
+    }
+}
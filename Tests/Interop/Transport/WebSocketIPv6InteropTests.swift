@@ -0,0 +1,116 @@
+/// WebSocketIPv6InteropTests - The go ws node listening on IPv6 and dual-stack
+///
+/// LISTEN_ADDRS gives the node a comma-separated list of multiaddrs to
+/// listen on instead of the default /ip4/0.0.0.0 one. The node prints every
+/// socket it bound as `Bound: <multiaddr> family=<ip4|ip6>`, with the
+/// concrete port for /tcp/0, and every connection as `CONN: dir= family=
+/// remote= peer=`.
+///
+/// The host reaches the container through the published port, so whether
+/// the node sees the /ip6 dial as IPv6 depends on Docker's IPv6 setup; the
+/// tests assert what holds either way.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketIPv6InteropTests
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PIdentify
+@testable import P2PProtocols
+
+@Suite("WebSocket IPv6 Interop Tests", .serialized)
+struct WebSocketIPv6InteropTests {
+
+    static let dualStack = "/ip6/::/tcp/4001/ws,/ip4/0.0.0.0/tcp/4001/ws"
+
+    @Test("A Swift client dials the /ip6 ws address and completes identify", .timeLimit(.minutes(2)))
+    func identifyOverIPv6() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: ["LISTEN_ADDRS": Self.dualStack])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let startup = try await Self.waitForLog(harness.logs, containing: "Bound: /ip6/::/tcp/4001/ws family=ip6")
+        #expect(startup.contains("Bound: /ip4/0.0.0.0/tcp/4001/ws family=ip4"))
+
+        let port = try #require(try Multiaddr(harness.nodeInfo.address).tcpPort)
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let node = Self.makeNode(identifyService: identifyService)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let address = try Multiaddr("/ip6/::1/tcp/\(port)/ws/p2p/\(harness.nodeInfo.peerID)")
+        let goPeer = try await node.connect(to: address)
+        #expect(goPeer.description == harness.nodeInfo.peerID)
+
+        let info = try await identifyService.identify(goPeer, using: node)
+        #expect(info.protocols.contains(ProtocolID.identify))
+        #expect(info.listenAddresses.contains { $0.description.hasPrefix("/ip6/") })
+
+        let localPeer = await node.peerID
+        let logs = try await Self.waitForLog(harness.logs, containing: "CONN: dir=inbound ")
+        #expect(logs.contains(" peer=\(localPeer)"))
+        #expect(logs.contains("family=ip4 ") || logs.contains("family=ip6 "))
+    }
+
+    @Test("A /tcp/0 listener is reported with its bound port", .timeLimit(.minutes(2)))
+    func ephemeralPortReported() async throws {
+        let harness = try await GoWebSocketHarness.start(environment: [
+            "LISTEN_ADDRS": "/ip4/0.0.0.0/tcp/4001/ws,/ip6/::/tcp/0/ws",
+        ])
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let logs = try await Self.waitForLog(harness.logs, containing: "Bound: /ip6/")
+        let line = try #require(logs.split(separator: "\n").first { $0.hasPrefix("Bound: /ip6/") })
+        let bound = try Multiaddr(String(line.dropFirst("Bound: ".count).prefix { $0 != " " }))
+        let port = try #require(bound.tcpPort)
+        #expect(port != 0)
+        #expect(line.hasSuffix(" family=ip6"))
+    }
+
+    // MARK: - Helpers
+
+    private static func makeNode(identifyService: IdentifyService) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [],
+            transports: [WebSocketTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            services: ServicePipeline {
+                service(identifyService) { component in
+                    component.handlesInboundStreams()
+                    component.observesPeers()
+                    component.consumesLocalIdentity()
+                    component.consumesListenAddresses()
+                    component.consumesSupportedProtocols()
+                    component.activatesWithStreamOpening()
+                }
+            }
+        ))
+    }
+
+    /// Polls the node's logs until marker appears.
+    private static func waitForLog(_ logs: () async -> String, containing marker: String) async throws -> String {
+        var output = ""
+        for _ in 0..<50 {
+            output = await logs()
+            if output.contains(marker) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(output)")
+        return output
+    }
+}
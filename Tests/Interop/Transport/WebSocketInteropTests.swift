@@ -24,12 +24,13 @@ import NIOCore
 @Suite("WebSocket Transport Interop Tests", .serialized)
 struct WebSocketInteropTests {
     private func withWSHarness<T: Sendable>(
+        environment: [String: String] = [:],
         _ operation: @Sendable (GoWebSocketHarness) async throws -> T
     ) async throws -> T {
         var lastError: (any Error)?
 
         for attempt in 1...2 {
-            let harness = try await GoWebSocketHarness.start()
+            let harness = try await GoWebSocketHarness.start(environment: environment)
             do {
                 let result = try await operation(harness)
                 do {
@@ -295,4 +296,103 @@ struct WebSocketInteropTests {
             try await muxedConnection.close()
         }
     }
+
+    // MARK: - Echo Tests
+
+    @Test("Echo 10MB through the go node without losing bytes", .timeLimit(.minutes(2)))
+    func largeEchoViaWS() async throws {
+        let total = 10 * 1024 * 1024
+        try await withWSHarness(environment: ["ECHO_BUF_BYTES": "65536"]) { harness in
+            let nodeInfo = harness.nodeInfo
+            let keyPair = KeyPair.generateEd25519()
+            let transport = WebSocketTransport()
+            let address = try Multiaddr(nodeInfo.address)
+            let rawConnection = try await transport.dial(address)
+
+            let securityNegotiation = try await MultistreamSelect.negotiate(
+                protocols: ["/noise"],
+                read: { Data(buffer: try await rawConnection.read()) },
+                write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+            )
+            #expect(securityNegotiation.protocolID == "/noise")
+
+            let noiseUpgrader = NoiseUpgrader()
+            let securedConnection = try await noiseUpgrader.secure(
+                rawConnection,
+                localKeyPair: keyPair,
+                as: .initiator,
+                expectedPeer: nil,
+                initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+            )
+
+            let muxNegotiation = try await MultistreamSelect.negotiate(
+                protocols: ["/yamux/1.0.0"],
+                read: { Data(buffer: try await securedConnection.read()) },
+                write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+            )
+            #expect(muxNegotiation.protocolID == "/yamux/1.0.0")
+
+            let yamuxMuxer = YamuxMuxer()
+            let muxedConnection = try await yamuxMuxer.multiplex(
+                securedConnection,
+                isInitiator: true
+            )
+
+            let stream = try await muxedConnection.newStream()
+
+            let negotiationResult = try await MultistreamSelect.negotiate(
+                protocols: ["/test/echo/1.0.0"],
+                read: { Data(buffer: try await stream.read()) },
+                write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+            )
+            #expect(negotiationResult.protocolID == "/test/echo/1.0.0")
+
+            // Write and read concurrently: the echo would stall on flow
+            // control if the whole payload were written first
+            let writer = Task {
+                let chunkSize = 64 * 1024
+                var sent = 0
+                while sent < total {
+                    let size = min(chunkSize, total - sent)
+                    try await stream.write(ByteBuffer(bytes: (sent..<sent + size).map { UInt8($0 % 251) }))
+                    sent += size
+                }
+                try await stream.closeWrite()
+            }
+
+            var received = 0
+            var firstMismatch: Int?
+            func check(_ bytes: some Sequence<UInt8>) {
+                for byte in bytes {
+                    if firstMismatch == nil, byte != UInt8(received % 251) {
+                        firstMismatch = received
+                    }
+                    received += 1
+                }
+            }
+            check(negotiationResult.remainder)
+            while true {
+                let data = try await stream.read()
+                if data.readableBytes == 0 { break }
+                check(data.readableBytesView)
+            }
+            try await writer.value
+
+            #expect(received == total)
+            #expect(firstMismatch == nil, "First differing byte at offset \(firstMismatch ?? -1)")
+
+            // The node reports the same count once its side of the stream closes
+            let marker = "ECHO_DONE: peer=\(keyPair.peerID) bytes=\(total) "
+            var logs = ""
+            for _ in 0..<20 {
+                logs = await harness.logs()
+                if logs.contains(marker) { break }
+                try await Task.sleep(for: .milliseconds(250))
+            }
+            #expect(logs.contains(marker), "No \(marker) in go node logs:\n\(logs)")
+
+            try await stream.close()
+            try await muxedConnection.close()
+        }
+    }
 }
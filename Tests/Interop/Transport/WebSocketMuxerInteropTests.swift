@@ -0,0 +1,231 @@
+/// WebSocketMuxerInteropTests - Muxer selection between Swift and the go ws/wss nodes
+///
+/// The go nodes offer the muxers in MUXERS (yamux, mplex, in order) and print
+/// `MUXER: peer= remote= muxer=` for every connection. STREAMS <peerID> lists
+/// the open streams to a peer as `STREAM: peer= muxer= dir= protocol=
+/// opened_ms=` lines followed by `STREAMS_END: peer= conns= count=`.
+///
+/// Each cell of {ws, wss} x {yamux, mplex} x {Swift dials, go dials} runs
+/// with the Swift node supporting only the muxer under test and the go node
+/// preferring the other one, so the negotiation has to fall back to it.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketMuxerInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+import NIOSSL
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMuxMplex
+@testable import P2PMux
+@testable import P2PCore
+
+@Suite("WebSocket Muxer Interop Tests", .serialized)
+struct WebSocketMuxerInteropTests {
+
+    enum Scheme: String, CaseIterable, Sendable {
+        case ws
+        case wss
+    }
+
+    enum MuxerKind: String, CaseIterable, Sendable {
+        case yamux
+        case mplex
+
+        var protocolID: String {
+            switch self {
+            case .yamux: return "/yamux/1.0.0"
+            case .mplex: return mplexProtocolID
+            }
+        }
+
+        var other: MuxerKind {
+            self == .yamux ? .mplex : .yamux
+        }
+
+        /// MUXERS for the go node: the other muxer first.
+        var goPreference: String {
+            "\(other.rawValue),\(rawValue)"
+        }
+
+        func make() -> any Muxer {
+            switch self {
+            case .yamux: return YamuxMuxer()
+            case .mplex: return MplexMuxer()
+            }
+        }
+    }
+
+    @Test("Swift dials the go node and both sides agree on the muxer", .timeLimit(.minutes(3)),
+          arguments: Scheme.allCases, MuxerKind.allCases)
+    func swiftDials(scheme: Scheme, muxer: MuxerKind) async throws {
+        let goNode = try await GoNode.start(scheme: scheme, muxers: muxer.goPreference)
+        defer { Task { do { try await goNode.stop() } catch { } } }
+
+        let startup = await goNode.logs()
+        #expect(startup.contains("MUXER_ORDER: \(muxer.other.protocolID),\(muxer.protocolID)"))
+
+        let node = Self.makeNode(
+            listenAddresses: [],
+            transport: scheme == .ws ? WebSocketTransport() : try goNode.clientTransport(),
+            muxer: muxer
+        )
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: try Multiaddr(goNode.address))
+        #expect(await node.connection(to: goPeer)?.muxerProtocol == muxer.protocolID)
+
+        // Keep an echo stream open while the go node lists its streams
+        let raw = try await node.newRawStream(to: goPeer, protocol: EchoProtocol.interopProtocolID)
+        try await raw.write(Array("muxer".utf8))
+        #expect(try await raw.readExactly(5) == Array("muxer".utf8))
+
+        let localPeer = await node.peerID
+        try await goNode.sendCommand("STREAMS \(localPeer)")
+        let logs = try await Self.waitForLog(goNode.logs, containing: ["STREAMS_END: peer=\(localPeer) "])
+        #expect(logs.contains("MUXER: peer=\(localPeer) "))
+        #expect(logs.contains(" muxer=\(muxer.protocolID)\n"))
+        #expect(logs.contains(
+            "STREAM: peer=\(localPeer) muxer=\(muxer.protocolID) dir=inbound protocol=\(EchoProtocol.interopProtocolID) opened_ms="
+        ))
+        #expect(logs.contains("STREAMS_END: peer=\(localPeer) conns=1 "))
+        #expect(!logs.contains("STREAMS_END: peer=\(localPeer) conns=1 count=0"))
+
+        try await raw.close()
+    }
+
+    @Test("The go node dials a Swift listener and both sides agree on the muxer", .timeLimit(.minutes(3)),
+          arguments: Scheme.allCases, MuxerKind.allCases)
+    func goDials(scheme: Scheme, muxer: MuxerKind) async throws {
+        let goNode = try await GoNode.start(scheme: scheme, muxers: muxer.goPreference)
+        defer { Task { do { try await goNode.stop() } catch { } } }
+
+        let port = UInt16.random(in: 10000..<60000)
+        let node = Self.makeNode(
+            listenAddresses: [scheme == .ws ? .ws(host: "0.0.0.0", port: port) : .wss(host: "0.0.0.0", port: port)],
+            transport: scheme == .ws ? WebSocketTransport() : try goNode.serverTransport(),
+            muxer: muxer
+        )
+        await EchoProtocol.registerEcho(on: node)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let peerID = await node.peerID
+        try await goNode.sendCommand("DIAL /dns4/host.docker.internal/tcp/\(port)/\(scheme.rawValue)/p2p/\(peerID)")
+
+        let logs = try await Self.waitForLog(goNode.logs, containing: ["DIAL_ECHO_OK: ", "DIAL_FAILED: "])
+        #expect(logs.contains("DIAL_ECHO_OK: peer=\(peerID) bytes=65536 rtt_ms="))
+        #expect(logs.contains("MUXER: peer=\(peerID) "))
+        #expect(logs.contains(" muxer=\(muxer.protocolID)\n"))
+
+        let goPeer = try PeerID(string: goNode.peerID)
+        #expect(await node.connection(to: goPeer)?.muxerProtocol == muxer.protocolID)
+
+        // DIAL keeps the connection after the echo
+        try await goNode.sendCommand("STREAMS \(peerID)")
+        let streams = try await Self.waitForLog(goNode.logs, containing: ["STREAMS_END: peer=\(peerID) "])
+        #expect(streams.contains("STREAMS_END: peer=\(peerID) conns=1 "))
+    }
+
+    // MARK: - Helpers
+
+    /// The ws or wss go node behind one interface.
+    private struct GoNode: Sendable {
+        let address: String
+        let peerID: String
+        let logs: @Sendable () async -> String
+        let sendCommand: @Sendable (String) async throws -> Void
+        let stop: @Sendable () async throws -> Void
+        let wss: GoWSSHarness?
+
+        /// Starts the node with stdin open for STREAMS and DIAL.
+        static func start(scheme: Scheme, muxers: String) async throws -> GoNode {
+            switch scheme {
+            case .ws:
+                let harness = try await GoWebSocketHarness.start(
+                    environment: ["MUXERS": muxers],
+                    interactive: true
+                )
+                return GoNode(
+                    address: harness.nodeInfo.address,
+                    peerID: harness.nodeInfo.peerID,
+                    logs: { await harness.logs() },
+                    sendCommand: { try await harness.sendCommand($0) },
+                    stop: { try await harness.stop() },
+                    wss: nil
+                )
+            case .wss:
+                let harness = try await GoWSSHarness.start(
+                    interactive: true,
+                    insecureSkipVerify: true,
+                    environment: ["MUXERS": muxers]
+                )
+                return GoNode(
+                    address: harness.nodeInfo.address,
+                    peerID: harness.nodeInfo.peerID,
+                    logs: { await harness.logs() },
+                    sendCommand: { try await harness.sendCommand($0) },
+                    stop: { try await harness.stop() },
+                    wss: harness
+                )
+            }
+        }
+
+        /// Trusts the certificate the wss node serves.
+        func clientTransport() throws -> WebSocketTransport {
+            let harness = try #require(wss)
+            let certificates = try NIOSSLCertificate.fromPEMBytes(Array(harness.serverCertificatePEM.utf8))
+            var clientTLS = TLSConfiguration.makeClientConfiguration()
+            clientTLS.certificateVerification = .fullVerification
+            clientTLS.trustRoots = .certificates(certificates)
+            return WebSocketTransport(tlsConfiguration: .init(client: clientTLS))
+        }
+
+        /// Serves the image's second self-signed localhost pair.
+        func serverTransport() throws -> WebSocketTransport {
+            let harness = try #require(wss)
+            let certificates = try NIOSSLCertificate.fromPEMBytes(Array(harness.certificatePEM(at: "/cert2.pem").utf8))
+            let key = try NIOSSLPrivateKey(bytes: Array(harness.privateKeyPEM(at: "/key2.pem").utf8), format: .pem)
+            return WebSocketTransport(tlsConfiguration: WebSocketTLSConfiguration(server: .makeServerConfiguration(
+                certificateChain: certificates.map { .certificate($0) },
+                privateKey: .privateKey(key)
+            )))
+        }
+    }
+
+    private static func makeNode(listenAddresses: [Multiaddr], transport: WebSocketTransport, muxer: MuxerKind) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: listenAddresses,
+            transports: [transport],
+            security: [NoiseUpgrader()],
+            muxers: [muxer.make()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil
+        ))
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(_ logs: () async -> String, containing markers: [String]) async throws -> String {
+        var output = ""
+        for _ in 0..<100 {
+            output = await logs()
+            if markers.contains(where: output.contains) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(200))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(output)")
+        return output
+    }
+}
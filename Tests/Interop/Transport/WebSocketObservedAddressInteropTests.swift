@@ -0,0 +1,132 @@
+/// WebSocketObservedAddressInteropTests - Identify observed addresses over ws with the go node
+///
+/// Once identify with a peer completes, the go ws node prints the address it
+/// reports to that peer as `OBSERVED_SENT: peer= addr=` (the connection's
+/// remote address, also printed as `CONN: ... remote=`) and the address the
+/// peer reported for it as `OBSERVED_RECV: addr= peer=`. The stdin command
+/// `OBSERVED` lists the self addresses reported over open connections as
+/// `OBSERVED: addr= peers= active=` lines ending with `OBSERVED_END: count=`.
+///
+/// The Swift node dials through the published port, so the go node sees the
+/// Docker-network side of that connection, and Swift sees 127.0.0.1 and the
+/// published port.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketObservedAddressInteropTests
+
+import Testing
+import Foundation
+@testable import P2P
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PIdentify
+
+@Suite("WebSocket Observed Address Interop Tests", .serialized)
+struct WebSocketObservedAddressInteropTests {
+
+    @Test("The observed address from the go node is the source address it saw, with /ws", .timeLimit(.minutes(2)))
+    func observedAddressMatchesSource() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let node = Self.makeNode(identifyService: identifyService)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goPeer = try await node.connect(to: try Multiaddr(harness.nodeInfo.address))
+        let info = try await identifyService.identify(goPeer, using: node)
+        let observed = try #require(info.observedAddress)
+        #expect(observed.description.hasSuffix("/ws"))
+        #expect(observed.tcpPort != nil)
+
+        let localPeer = await node.peerID
+        let logs = try await Self.waitForLog(harness.logs, containing: "OBSERVED_SENT: peer=\(localPeer) ")
+        let sent = try #require(Self.field("addr", in: logs, line: "OBSERVED_SENT: peer=\(localPeer) "))
+        #expect(try Multiaddr(sent) == observed)
+        #expect(logs.contains("CONN: dir=inbound "))
+        #expect(logs.contains(" remote=\(sent) peer=\(localPeer)"))
+    }
+
+    @Test("The go node records what Swift observed and lists it for OBSERVED", .timeLimit(.minutes(2)))
+    func goRecordsObservedAddress() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let identifyService = IdentifyService(configuration: .init(cleanupInterval: nil))
+        let node = Self.makeNode(identifyService: identifyService)
+        try await node.start()
+        defer { Task { do { try await node.shutdown() } catch { } } }
+
+        let goAddress = try Multiaddr(harness.nodeInfo.address)
+        let port = try #require(goAddress.tcpPort)
+        _ = try await node.connect(to: goAddress)
+
+        // The go node identifies every new connection on its own
+        let localPeer = await node.peerID
+        let logs = try await Self.waitForLog(harness.logs, containing: "OBSERVED_RECV: ")
+        let received = try #require(Self.field("addr", in: logs, line: "OBSERVED_RECV: "))
+        #expect(received == "/ip4/127.0.0.1/tcp/\(port)/ws")
+        #expect(logs.contains("OBSERVED_RECV: addr=\(received) peer=\(localPeer)"))
+
+        try await harness.sendCommand("OBSERVED")
+        let listed = try await Self.waitForLog(harness.logs, containing: "OBSERVED_END: ")
+        #expect(listed.contains("OBSERVED: addr=\(received) peers=1 active="))
+        #expect(listed.contains("OBSERVED_END: count=1"))
+    }
+
+    // MARK: - Helpers
+
+    private static func makeNode(identifyService: IdentifyService) -> Node {
+        Node(configuration: NodeConfiguration(
+            keyPair: .generateEd25519(),
+            listenAddresses: [],
+            transports: [WebSocketTransport()],
+            security: [NoiseUpgrader()],
+            muxers: [YamuxMuxer()],
+            pool: PoolConfiguration(
+                limits: .development,
+                reconnectionPolicy: .disabled,
+                idleTimeout: .seconds(300)
+            ),
+            healthCheck: nil,
+            services: ServicePipeline {
+                service(identifyService) { component in
+                    component.handlesInboundStreams()
+                    component.observesPeers()
+                    component.consumesLocalIdentity()
+                    component.consumesListenAddresses()
+                    component.consumesSupportedProtocols()
+                    component.activatesWithStreamOpening()
+                }
+            }
+        ))
+    }
+
+    /// The value of `name=` on the first log line starting with `line`.
+    private static func field(_ name: String, in logs: String, line prefix: String) -> String? {
+        guard let line = logs.split(separator: "\n").first(where: { $0.hasPrefix(prefix) }) else {
+            return nil
+        }
+        return line.split(separator: " ")
+            .first { $0.hasPrefix("\(name)=") }
+            .map { String($0.dropFirst(name.count + 1)) }
+    }
+
+    /// Polls the node's logs until marker appears.
+    private static func waitForLog(_ logs: () async -> String, containing marker: String) async throws -> String {
+        var output = ""
+        for _ in 0..<50 {
+            output = await logs()
+            if output.contains(marker) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        Issue.record("\(marker) did not appear in the go node logs:\n\(output)")
+        return output
+    }
+}
@@ -0,0 +1,151 @@
+/// WebSocketPerfInteropTests - libp2p perf protocol against the go WebSocket node
+///
+/// The ws node serves /perf/1.0.0: the client sends the byte count it wants
+/// back as an 8-byte big-endian integer, uploads, closes its write side and
+/// reads the reply. The node logs `PERF: peer=<id> up_bytes=<a>
+/// down_bytes=<b> up_mbps=<x> down_mbps=<y> duration_ms=<d>` per stream,
+/// and its `PERF <multiaddr> <upload_bytes> <download_bytes>` stdin command
+/// runs the client side, logging `PERF_RESULT:` with the same fields.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketPerfInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WebSocket Perf Interop Tests", .serialized)
+struct WebSocketPerfInteropTests {
+
+    static let perfProtocol = "/perf/1.0.0"
+
+    @Test("Swift perf client against the go ws node", .timeLimit(.minutes(2)))
+    func swiftClientAgainstGoServer() async throws {
+        let harness = try await GoWebSocketHarness.start()
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let upload = 4 * 1024 * 1024
+        let download = 8 * 1024 * 1024
+        let keyPair = KeyPair.generateEd25519()
+        let connection = try await Self.connect(to: harness, keyPair: keyPair)
+        let stream = try await connection.newStream()
+
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: [Self.perfProtocol],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == Self.perfProtocol)
+
+        let writer = Task {
+            var header = ByteBuffer()
+            header.writeInteger(UInt64(download))
+            try await stream.write(header)
+            let block = ByteBuffer(repeating: 0, count: 64 * 1024)
+            var sent = 0
+            while sent < upload {
+                let size = min(block.readableBytes, upload - sent)
+                try await stream.write(block.getSlice(at: 0, length: size)!)
+                sent += size
+            }
+            try await stream.closeWrite()
+        }
+
+        var received = negotiation.remainder.count
+        while true {
+            let data = try await stream.read()
+            if data.readableBytes == 0 { break }
+            received += data.readableBytes
+        }
+        try await writer.value
+        #expect(received == download)
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["PERF: ", "PERF_FAILED: "])
+        #expect(logs.contains("PERF: peer=\(keyPair.peerID) up_bytes=\(upload) down_bytes=\(download) up_mbps="))
+
+        try await stream.close()
+        try await connection.close()
+    }
+
+    @Test("PERF command drives a go perf client against another go node", .timeLimit(.minutes(3)))
+    func goClientAgainstGoServer() async throws {
+        let server = try await GoWebSocketHarness.start()
+        defer { Task { do { try await server.stop() } catch { } } }
+        let client = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await client.stop() } catch { } } }
+
+        // The client reaches the server through the host's port mapping
+        let target = server.nodeInfo.address.replacingOccurrences(of: "/ip4/127.0.0.1/", with: "/dns4/host.docker.internal/")
+        try await client.sendCommand("PERF \(target) 1048576 2097152")
+
+        let clientLogs = try await Self.waitForLog(client.logs, containing: ["PERF_RESULT: ", "PERF_FAILED: "])
+        #expect(clientLogs.contains("PERF_RESULT: peer=\(server.nodeInfo.peerID) up_bytes=1048576 down_bytes=2097152 up_mbps="))
+
+        let serverLogs = try await Self.waitForLog(server.logs, containing: ["PERF: ", "PERF_FAILED: "])
+        #expect(serverLogs.contains("PERF: peer="))
+        #expect(serverLogs.contains(" up_bytes=1048576 down_bytes=2097152 "))
+    }
+
+    @Test("PERF reports a usage error for missing byte counts", .timeLimit(.minutes(2)))
+    func perfUsage() async throws {
+        let harness = try await GoWebSocketHarness.start(interactive: true)
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        try await harness.sendCommand("PERF \(harness.nodeInfo.address) 1024")
+
+        let logs = try await Self.waitForLog(harness.logs, containing: ["PERF_FAILED: "])
+        #expect(logs.contains("PERF_FAILED: err=usage: PERF <multiaddr> <upload_bytes> <download_bytes>"))
+    }
+
+    // MARK: - Helpers
+
+    /// Dials the node over /ws and upgrades with Noise and Yamux.
+    private static func connect(to harness: GoWebSocketHarness, keyPair: KeyPair) async throws -> any MuxedConnection {
+        let rawConnection = try await WebSocketTransport().dial(try Multiaddr(harness.nodeInfo.address))
+
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let securedConnection = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: keyPair,
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+
+        _ = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await securedConnection.read()) },
+            write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+        )
+        return try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+    }
+
+    /// Polls the node's logs until one of the markers appears.
+    private static func waitForLog(
+        _ logs: () async -> String,
+        containing markers: [String],
+        attempts: Int = 120
+    ) async throws -> String {
+        var output = ""
+        for _ in 0..<attempts {
+            output = await logs()
+            if markers.contains(where: { output.contains($0) }) {
+                return output
+            }
+            try await Task.sleep(for: .milliseconds(500))
+        }
+        Issue.record("None of \(markers) appeared in the go node logs:\n\(output)")
+        return output
+    }
+}
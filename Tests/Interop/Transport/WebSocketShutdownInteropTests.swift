@@ -0,0 +1,110 @@
+/// WebSocketShutdownInteropTests - Graceful shutdown of the go ws node
+///
+/// SIGTERM or the SHUTDOWN command makes the go ws node stop listening, close
+/// the write side of active echo streams, wait up to DRAIN_TIMEOUT_S for them
+/// to end and then close every connection through the host: a Yamux GoAway,
+/// a WebSocket Close frame and only then the TCP FIN. The node logs
+/// `SHUTDOWN: reason= conns= streams= timeout_ms=`, one
+/// `DRAIN: peer= remote= streams= drained= cut= close=` per connection and
+/// `SHUTDOWN_DONE:` before exiting 0.
+///
+/// Prerequisites:
+/// - Docker must be installed and running
+/// - Tests run with: swift test --filter WebSocketShutdownInteropTests
+
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PTransportWebSocket
+@testable import P2PSecurityNoise
+@testable import P2PMuxYamux
+@testable import P2PMux
+@testable import P2PCore
+@testable import P2PNegotiation
+
+@Suite("WebSocket Shutdown Interop Tests", .serialized)
+struct WebSocketShutdownInteropTests {
+
+    @Test("SHUTDOWN mid-transfer ends in a WebSocket close, not a reset", .timeLimit(.minutes(2)))
+    func shutdownDuringTransfer() async throws {
+        let harness = try await GoWebSocketHarness.start(
+            environment: ["DRAIN_TIMEOUT_S": "5"],
+            interactive: true
+        )
+        defer { Task { do { try await harness.stop() } catch { } } }
+
+        let rawConnection = try await WebSocketTransport().dial(try Multiaddr(harness.nodeInfo.address))
+        let webSocket = try #require(rawConnection as? WebSocketConnection)
+        let securityNegotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/noise"],
+            read: { Data(buffer: try await rawConnection.read()) },
+            write: { data in try await rawConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let securedConnection = try await NoiseUpgrader().secure(
+            rawConnection,
+            localKeyPair: .generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil,
+            initialBuffer: ByteBuffer(bytes: securityNegotiation.remainder)
+        )
+        _ = try await MultistreamSelect.negotiate(
+            protocols: ["/yamux/1.0.0"],
+            read: { Data(buffer: try await securedConnection.read()) },
+            write: { data in try await securedConnection.write(ByteBuffer(bytes: data)) }
+        )
+        let connection = try await YamuxMuxer().multiplex(securedConnection, isInitiator: true)
+        let stream = try await connection.newStream()
+        let negotiation = try await MultistreamSelect.negotiate(
+            protocols: ["/test/echo/1.0.0"],
+            read: { Data(buffer: try await stream.read()) },
+            write: { data in try await stream.write(ByteBuffer(bytes: data)) }
+        )
+        #expect(negotiation.protocolID == "/test/echo/1.0.0")
+
+        // A 30-second transfer: 16KB every 10ms, echoed back
+        let clock = ContinuousClock()
+        let start = clock.now
+        let writer = Task {
+            let chunk = ByteBuffer(bytes: [UInt8](repeating: 0x5A, count: 16 * 1024))
+            while !Task.isCancelled, clock.now - start < .seconds(30) {
+                do {
+                    try await stream.write(chunk)
+                    try await Task.sleep(for: .milliseconds(10))
+                } catch {
+                    return
+                }
+            }
+        }
+        let reader = Task { () -> Int in
+            var echoed = negotiation.remainder.count
+            while true {
+                let data = try await stream.read()
+                if data.readableBytes == 0 { return echoed }
+                echoed += data.readableBytes
+            }
+        }
+
+        try await Task.sleep(for: .seconds(10))
+        try await harness.sendCommand("SHUTDOWN")
+
+        // The node closes its side of the echo: EOF, well before the 30s
+        let echoed = try await reader.value
+        #expect(echoed > 0)
+        #expect(clock.now - start < .seconds(20))
+        let logs = await harness.logs()
+        #expect(logs.contains("SHUTDOWN: reason=command conns=1 streams=1 "))
+
+        // Finish our side so the stream drains instead of being cut
+        writer.cancel()
+        await writer.value
+        try await stream.closeWrite()
+
+        // The connection ends with the node's Close frame, not a reset
+        for _ in 0..<100 where webSocket.remoteCloseCode == nil {
+            try await Task.sleep(for: .milliseconds(100))
+        }
+        #expect(webSocket.remoteCloseCode == 1000)
+
+        try await connection.close()
+    }
+}
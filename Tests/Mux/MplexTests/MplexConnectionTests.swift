@@ -334,6 +334,62 @@ struct MplexConnectionTests {
         try await connection.close()
     }
 
+    @Test("A peer opening 10k streams is held to maxIncomingStreams", .timeLimit(.minutes(1)))
+    func incomingStreamFloodIsBounded() async throws {
+        let config = MplexConfiguration(maxIncomingStreams: 64, maxPendingInboundStreams: 10_000)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+        try await Task.sleep(for: .milliseconds(50))
+
+        // In batches so the control queue keeps draining
+        for batch in stride(from: 0, to: 10_000, by: 100) {
+            var bytes = ByteBuffer()
+            for id in batch..<(batch + 100) {
+                MplexFrame.newStream(id: UInt64(id)).encode(into: &bytes)
+            }
+            mock.injectInbound(Data(buffer: bytes))
+            try await Task.sleep(for: .milliseconds(5))
+        }
+        try await Task.sleep(for: .milliseconds(200))
+
+        let resets = mock.captureOutbound().filter { data in
+            guard let (frame, _) = try? MplexFrame.decode(from: data) else { return false }
+            return frame.flag == .resetReceiver
+        }
+        #expect(resets.count == 10_000 - 64)
+        #expect(!mock.wasClosed)
+
+        try await connection.close()
+    }
+
+    @Test("newStream fails past maxOutgoingStreams", .timeLimit(.minutes(1)))
+    func maxOutgoingStreamsEnforced() async throws {
+        let config = MplexConfiguration(maxOutgoingStreams: 2)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+        try await Task.sleep(for: .milliseconds(50))
+
+        _ = try await connection.newStream() // ID 0
+        _ = try await connection.newStream() // ID 1
+        mock.clearOutbound()
+
+        do {
+            _ = try await connection.newStream()
+            Issue.record("newStream succeeded past maxOutgoingStreams")
+        } catch MplexError.maxStreamsExceeded(let current, let max) {
+            #expect(current == 2)
+            #expect(max == 2)
+        }
+        #expect(mock.captureOutbound().isEmpty)
+
+        // The outgoing limit leaves the peer's budget alone; IDs are per direction
+        injectFrame(mock, MplexFrame.newStream(id: 0))
+        let inbound = try await connection.acceptStream()
+        #expect(inbound.id == 0)
+
+        try await connection.close()
+    }
+
     // MARK: - Close Tests
 
     @Test("Close closes underlying connection")
@@ -748,6 +804,26 @@ struct MplexConnectionTests {
             _ = try await connection.newStream()
         }
     }
+
+    @Test("Wire tracer sees NewStream, Close and Reset but not messages")
+    func wireTracerSeesControlFrames() async throws {
+        let traced = TracedFrames()
+        let config = MplexConfiguration(wireTracer: traced.tracer)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        let stream = try await connection.newStream()
+        try await stream.write(ByteBuffer(bytes: [1, 2, 3]))
+        injectFrame(mock, .reset(id: stream.id, isInitiator: false))
+        try await Task.sleep(for: .milliseconds(100))
+
+        let entries = traced.entries.withLock { $0 }
+        #expect(entries.map(\.phase) == [.mplexNewStream, .mplexReset])
+        #expect(entries.map(\.direction) == [.outbound, .inbound])
+        var newStream = ByteBuffer()
+        MplexFrame.newStream(id: stream.id).encode(into: &newStream)
+        #expect(entries.first?.bytes == Array(newStream.readableBytesView))
+    }
 }
 
 // MARK: - Test Errors
@@ -757,3 +833,20 @@ enum TestError: Error {
     case incompleteDecode
     case unexpectedStreamType
 }
+
+/// Collects what a `WireTracer` is handed.
+private final class TracedFrames: Sendable {
+    struct Entry: Sendable {
+        let direction: WireDirection
+        let phase: WirePhase
+        let bytes: [UInt8]
+    }
+
+    let entries = Mutex<[Entry]>([])
+
+    var tracer: WireTracer {
+        WireTracer { direction, phase, bytes in
+            self.entries.withLock { $0.append(Entry(direction: direction, phase: phase, bytes: bytes)) }
+        }
+    }
+}
@@ -691,4 +691,76 @@ struct MplexStreamTests {
 
         try await connection.close()
     }
+
+    // MARK: - Backpressure
+
+    @Test("Full stream buffer pauses the read loop until the application reads", .timeLimit(.minutes(1)))
+    func fullBufferPausesReadLoop() async throws {
+        let configuration = MplexConfiguration(
+            maxReadBufferSizePerStream: 8,
+            receiveTimeout: .seconds(30)
+        )
+        let (connection, mock) = createTestConnection(isInitiator: true, configuration: configuration)
+        connection.start()
+
+        let slow = try await connection.newStream()
+        let other = try await connection.newStream()
+
+        // Fill the slow stream's buffer, then overflow it; a frame for another
+        // stream queued behind it must not be processed while the read loop waits.
+        injectFrame(mock, MplexFrame.message(id: 0, isInitiator: false, data: Data("12345678".utf8)))
+        injectFrame(mock, MplexFrame.message(id: 0, isInitiator: false, data: Data("ab".utf8)))
+        injectFrame(mock, MplexFrame.message(id: 1, isInitiator: false, data: Data("other".utf8)))
+
+        let otherRead = Task {
+            let data = try await other.read()
+            return (String(buffer: data), ContinuousClock.now)
+        }
+        try await Task.sleep(for: .milliseconds(200))
+
+        let drainedAt = ContinuousClock.now
+        #expect(String(buffer: try await slow.read()) == "12345678")
+
+        let (otherData, otherReadAt) = try await otherRead.value
+        #expect(otherData == "other")
+        #expect(otherReadAt >= drainedAt, "Sender must be throttled until the slow reader drains")
+
+        // The held frame is delivered, not dropped.
+        #expect(String(buffer: try await slow.read()) == "ab")
+
+        try await connection.close()
+    }
+
+    @Test("Stream is reset when its buffer is not drained within the receive timeout", .timeLimit(.minutes(1)))
+    func undrainedBufferResetsAfterTimeout() async throws {
+        let configuration = MplexConfiguration(
+            maxReadBufferSizePerStream: 8,
+            receiveTimeout: .milliseconds(100)
+        )
+        let (connection, mock) = createTestConnection(isInitiator: true, configuration: configuration)
+        connection.start()
+
+        let stalled = try await connection.newStream()
+        let other = try await connection.newStream()
+
+        injectFrame(mock, MplexFrame.message(id: 0, isInitiator: false, data: Data("12345678".utf8)))
+        injectFrame(mock, MplexFrame.message(id: 0, isInitiator: false, data: Data("ab".utf8)))
+        try await Task.sleep(for: .milliseconds(400))
+
+        await #expect(throws: MplexError.self) {
+            _ = try await stalled.read()
+        }
+
+        let resetSent = mock.captureOutbound().contains { data in
+            guard let (frame, _) = try? MplexFrame.decode(from: data) else { return false }
+            return frame.streamID == 0 && frame.flag == .resetInitiator
+        }
+        #expect(resetSent)
+
+        // The read loop resumes for the remaining streams.
+        injectFrame(mock, MplexFrame.message(id: 1, isInitiator: false, data: Data("alive".utf8)))
+        #expect(String(buffer: try await other.read()) == "alive")
+
+        try await connection.close()
+    }
 }
@@ -0,0 +1,247 @@
+import Testing
+import Foundation
+import NIOCore
+import Synchronization
+@testable import P2PMux
+
+@Suite("CoalescingMuxedStream Tests")
+struct CoalescingMuxedStreamTests {
+
+    // MARK: - Recording stream
+
+    enum Event: Equatable {
+        case write([UInt8])
+        case read
+        case closeWrite
+        case close
+        case reset
+    }
+
+    /// A MuxedStream that records every call made on it.
+    final class RecordingStream: MuxedStream, Sendable {
+        let id: UInt64 = 7
+        let protocolID: String? = "/test/1.0.0"
+
+        private let recorded = Mutex<[Event]>([])
+
+        var events: [Event] { recorded.withLock { $0 } }
+
+        var writes: [[UInt8]] {
+            events.compactMap {
+                if case .write(let bytes) = $0 { return bytes }
+                return nil
+            }
+        }
+
+        func read() async throws -> ByteBuffer {
+            recorded.withLock { $0.append(.read) }
+            return ByteBuffer(bytes: [0xFF])
+        }
+
+        func write(_ data: ByteBuffer) async throws {
+            recorded.withLock { $0.append(.write(Array(data.readableBytesView))) }
+        }
+
+        func closeWrite() async throws {
+            recorded.withLock { $0.append(.closeWrite) }
+        }
+
+        func closeRead() async throws {}
+
+        func close() async throws {
+            recorded.withLock { $0.append(.close) }
+        }
+
+        func reset() async throws {
+            recorded.withLock { $0.append(.reset) }
+        }
+    }
+
+    struct WriteFailure: Error {}
+
+    /// A MuxedStream whose writes fail after counting them.
+    final class FailingStream: MuxedStream, Sendable {
+        let id: UInt64 = 8
+        let protocolID: String? = nil
+
+        private let attempts = Mutex(0)
+
+        var writeAttempts: Int { attempts.withLock { $0 } }
+
+        func read() async throws -> ByteBuffer { ByteBuffer() }
+
+        func write(_ data: ByteBuffer) async throws {
+            attempts.withLock { $0 += 1 }
+            throw WriteFailure()
+        }
+
+        func closeWrite() async throws {}
+        func closeRead() async throws {}
+        func close() async throws {}
+        func reset() async throws {}
+    }
+
+    /// Configuration whose flush window never elapses during a test.
+    private let manualFlush = WriteCoalescingConfiguration(
+        flushThreshold: 64,
+        flushDelay: .seconds(60)
+    )
+
+    // MARK: - Coalescing
+
+    @Test("Small writes are combined into one write on flush")
+    func smallWritesCoalesced() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [1, 2]))
+        try await stream.write(ByteBuffer(bytes: [3]))
+        try await stream.write(ByteBuffer(bytes: [4, 5, 6]))
+        #expect(base.writes.isEmpty)
+
+        try await stream.flush()
+        #expect(base.writes == [[1, 2, 3, 4, 5, 6]])
+    }
+
+    @Test("Reaching the threshold sends immediately")
+    func thresholdTriggersWrite() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [UInt8](repeating: 1, count: 40)))
+        #expect(base.writes.isEmpty)
+
+        try await stream.write(ByteBuffer(bytes: [UInt8](repeating: 2, count: 30)))
+        #expect(base.writes.count == 1)
+        #expect(base.writes.first?.count == 70)
+    }
+
+    @Test("Large write after pending data keeps order")
+    func largeWriteKeepsOrder() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [9]))
+        try await stream.write(ByteBuffer(bytes: [UInt8](repeating: 3, count: 100)))
+
+        #expect(base.writes == [[9] + [UInt8](repeating: 3, count: 100)])
+    }
+
+    @Test("Pending writes are sent when the flush window elapses")
+    func flushWindowElapses() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: WriteCoalescingConfiguration(
+            flushThreshold: 1024,
+            flushDelay: .milliseconds(10)
+        ))
+
+        try await stream.write(ByteBuffer(bytes: [1]))
+        try await stream.write(ByteBuffer(bytes: [2]))
+
+        for _ in 0..<100 where base.writes.isEmpty {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        #expect(base.writes == [[1, 2]])
+    }
+
+    @Test("Flush with nothing pending does not write")
+    func emptyFlush() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.flush()
+        #expect(base.events.isEmpty)
+    }
+
+    // MARK: - Request/response correctness
+
+    @Test("closeWrite sends pending data before the half-close")
+    func closeWriteFlushesFirst() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [1, 2, 3]))
+        try await stream.closeWrite()
+
+        #expect(base.events == [.write([1, 2, 3]), .closeWrite])
+    }
+
+    @Test("close sends pending data before closing")
+    func closeFlushesFirst() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [4]))
+        try await stream.close()
+
+        #expect(base.events == [.write([4]), .close])
+    }
+
+    @Test("read hands pending request data to the muxer")
+    func readFlushesPending() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [5, 6]))
+        let response = try await stream.read()
+        #expect(Array(response.readableBytesView) == [0xFF])
+
+        // Waits for the write started by read() without adding a new one
+        try await stream.flush()
+        #expect(base.writes == [[5, 6]])
+    }
+
+    @Test("reset discards pending data")
+    func resetDiscardsPending() async throws {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites(configuration: manualFlush)
+
+        try await stream.write(ByteBuffer(bytes: [7, 8]))
+        try await stream.reset()
+        try await stream.flush()
+
+        #expect(base.events == [.reset])
+    }
+
+    // MARK: - Errors
+
+    @Test("A failed deferred send fails every later write and flush")
+    func deferredFailureIsSticky() async throws {
+        let base = FailingStream()
+        let stream = base.coalescingWrites(configuration: WriteCoalescingConfiguration(
+            flushThreshold: 1024,
+            flushDelay: .milliseconds(10)
+        ))
+
+        // Buffered; the flush window sends it in the background
+        try await stream.write(ByteBuffer(bytes: [1]))
+        for _ in 0..<100 where base.writeAttempts == 0 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        #expect(base.writeAttempts == 1)
+
+        await #expect(throws: WriteFailure.self) {
+            try await stream.flush()
+        }
+        await #expect(throws: WriteFailure.self) {
+            try await stream.write(ByteBuffer(bytes: [2]))
+        }
+        await #expect(throws: WriteFailure.self) {
+            try await stream.flush()
+        }
+        await #expect(throws: WriteFailure.self) {
+            try await stream.close()
+        }
+        // Nothing after the failure reaches the base stream
+        #expect(base.writeAttempts == 1)
+    }
+
+    @Test("Stream identity is forwarded from the base stream")
+    func forwardsIdentity() {
+        let base = RecordingStream()
+        let stream = base.coalescingWrites()
+
+        #expect(stream.id == 7)
+        #expect(stream.protocolID == "/test/1.0.0")
+    }
+}
@@ -0,0 +1,139 @@
+/// YamuxAcceptBackpressureTests - Bounded accept queue with deferred ACKs
+///
+/// Inbound streams past `maxPendingInboundStreams` are kept but not ACKed
+/// until the consumer accepts one, so the peer is throttled instead of reset
+/// or buffered without bound. The queue depth is observable through
+/// `pendingInboundStreamCount` and the configured metrics exporter.
+import Testing
+import Foundation
+import NIOCore
+@testable import P2PMuxYamux
+@testable import P2PCore
+@testable import P2PMux
+
+private func decodeFrame(from data: Data) throws -> YamuxFrame? {
+    var buffer = ByteBuffer(bytes: data)
+    return try YamuxFrame.decode(from: &buffer)
+}
+
+@Suite("Yamux Accept Backpressure Tests", .serialized)
+struct YamuxAcceptBackpressureTests {
+
+    private func createTestConnection(
+        configuration: YamuxConfiguration
+    ) -> (YamuxConnection, MockSecuredConnection) {
+        let mock = MockSecuredConnection()
+        let connection = YamuxConnection(
+            underlying: mock,
+            localPeer: mock.localPeer,
+            remotePeer: mock.remotePeer,
+            isInitiator: true,
+            configuration: configuration
+        )
+        return (connection, mock)
+    }
+
+    /// Injects SYNs for the given even stream IDs.
+    private func injectSYNs(_ ids: [UInt32], into mock: MockSecuredConnection) {
+        var bytes = ByteBuffer()
+        for id in ids {
+            YamuxFrame(type: .data, flags: .syn, streamID: id, length: 0, data: nil).encode(into: &bytes)
+        }
+        mock.injectInbound(bytes)
+    }
+
+    private func sentFrames(_ mock: MockSecuredConnection) -> [YamuxFrame] {
+        mock.captureOutbound().compactMap { try? decodeFrame(from: $0) }
+    }
+
+    private func waitForDepth(_ depth: Int, in connection: YamuxConnection) async throws {
+        for _ in 0..<500 where connection.pendingInboundStreamCount != depth {
+            try await Task.sleep(for: .milliseconds(2))
+        }
+        #expect(connection.pendingInboundStreamCount == depth)
+    }
+
+    @Test("Streams past the queue bound are held unacknowledged until one is accepted", .timeLimit(.minutes(1)))
+    func ackDeferredPastBound() async throws {
+        let config = YamuxConfiguration(maxPendingInboundStreams: 2, enableKeepAlive: false)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        injectSYNs([2, 4, 6, 8], into: mock)
+        try await waitForDepth(4, in: connection)
+        try await Task.sleep(for: .milliseconds(50))
+
+        var frames = sentFrames(mock)
+        #expect(Set(frames.filter { $0.flags.contains(.ack) }.map(\.streamID)) == [2, 4])
+        #expect(!frames.contains { $0.flags.contains(.rst) })
+
+        // Accepting the oldest frees a slot for stream 6, which is ACKed now
+        mock.clearOutbound()
+        let first = try await connection.acceptStream()
+        #expect((first as? YamuxStream)?.id == 2)
+        try await Task.sleep(for: .milliseconds(50))
+        frames = sentFrames(mock)
+        #expect(frames.filter { $0.flags.contains(.ack) }.map(\.streamID) == [6])
+        #expect(connection.pendingInboundStreamCount == 3)
+
+        // Arrival order is preserved across the deferred streams
+        var accepted: [UInt64] = []
+        for await stream in connection.inboundStreams {
+            accepted.append(try #require(stream as? YamuxStream).id)
+            if accepted.count == 3 { break }
+        }
+        #expect(accepted == [4, 6, 8])
+        #expect(connection.pendingInboundStreamCount == 0)
+
+        try await connection.close()
+    }
+
+    @Test("A deferred stream reset by the peer leaves the queue", .timeLimit(.minutes(1)))
+    func resetDeferredStreamIsDropped() async throws {
+        let config = YamuxConfiguration(maxPendingInboundStreams: 1, enableKeepAlive: false)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        injectSYNs([2, 4], into: mock)
+        try await waitForDepth(2, in: connection)
+
+        mock.injectInbound(YamuxFrame(type: .data, flags: .rst, streamID: 4, length: 0, data: nil).encode())
+        try await waitForDepth(1, in: connection)
+
+        mock.clearOutbound()
+        let stream = try await connection.acceptStream()
+        #expect((stream as? YamuxStream)?.id == 2)
+        try await Task.sleep(for: .milliseconds(50))
+        #expect(!sentFrames(mock).contains { $0.flags.contains(.ack) && $0.streamID == 4 })
+        #expect(connection.pendingInboundStreamCount == 0)
+
+        try await connection.close()
+    }
+
+    @Test("The queue depth of a muxer's connections is published as one series", .timeLimit(.minutes(1)))
+    func depthExported() async throws {
+        let exporter = PrometheusExporter()
+        let muxer = YamuxMuxer(configuration: YamuxConfiguration(maxPendingInboundStreams: 1, enableKeepAlive: false))
+            .withMetrics(exporter)
+        let firstMock = MockSecuredConnection()
+        let secondMock = MockSecuredConnection()
+        let first = try #require(try await muxer.multiplex(firstMock, isInitiator: true) as? YamuxConnection)
+        let second = try #require(try await muxer.multiplex(secondMock, isInitiator: true) as? YamuxConnection)
+
+        injectSYNs([2, 4, 6], into: firstMock)
+        injectSYNs([2, 4], into: secondMock)
+        try await waitForDepth(3, in: first)
+        try await waitForDepth(2, in: second)
+        let series = "libp2p_muxer_accept_queue_depth{muxer=\"yamux\"}"
+        #expect(exporter.scrape().contains("\(series) 5\n"))
+        #expect(!exporter.scrape().contains("peer="))
+
+        _ = try await first.acceptStream()
+        #expect(exporter.scrape().contains("\(series) 4\n"))
+
+        try await second.close()
+        #expect(exporter.scrape().contains("\(series) 2\n"))
+        try await first.close()
+        #expect(exporter.scrape().contains("\(series) 0.0\n"))
+    }
+}
@@ -2,6 +2,7 @@
 import Testing
 import Foundation
 import NIOCore
+import Synchronization
 @testable import P2PMuxYamux
 @testable import P2PCore
 @testable import P2PMux
@@ -305,10 +306,68 @@ struct YamuxConnectionTests {
         #expect(rstCount > 0)
     }
 
+    @Test("A peer opening 10k streams is held to maxIncomingStreams", .timeLimit(.minutes(1)))
+    func incomingStreamFloodIsBounded() async throws {
+        let config = YamuxConfiguration(
+            maxIncomingStreams: 64,
+            maxPendingInboundStreams: 10_000,
+            enableKeepAlive: false
+        )
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        // Even IDs 2, 4, ... 20000, in batches so the control queue keeps draining
+        for batch in stride(from: 0, to: 10_000, by: 100) {
+            var bytes = ByteBuffer()
+            for i in batch..<(batch + 100) {
+                YamuxFrame(type: .data, flags: .syn, streamID: UInt32(2 + i * 2), length: 0, data: nil).encode(into: &bytes)
+            }
+            mock.injectInbound(bytes)
+            try await Task.sleep(for: .milliseconds(5))
+        }
+        try await Task.sleep(for: .milliseconds(200))
+
+        let frames = mock.captureOutbound().compactMap { try? decodeFrame(from: $0) }
+        let acked = frames.filter { $0.flags.contains(.ack) && $0.streamID != 0 }.count
+        let reset = frames.filter { $0.flags.contains(.rst) }.count
+        #expect(acked == 64)
+        #expect(reset == 10_000 - 64)
+        #expect(!mock.wasClosed)
+    }
+
+    @Test("newStream fails past maxOutgoingStreams without sending a SYN")
+    func outgoingStreamLimitEnforced() async throws {
+        let config = YamuxConfiguration(maxOutgoingStreams: 2, enableKeepAlive: false)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        _ = try await connection.newStream() // ID 1
+        _ = try await connection.newStream() // ID 3
+        mock.clearOutbound()
+
+        do {
+            _ = try await connection.newStream()
+            Issue.record("newStream succeeded past maxOutgoingStreams")
+        } catch YamuxError.maxStreamsExceeded(let current, let max) {
+            #expect(current == 2)
+            #expect(max == 2)
+        }
+        let sentSYN = mock.captureOutbound().contains { data in
+            guard let frame = try? decodeFrame(from: data) else { return false }
+            return frame.flags.contains(.syn)
+        }
+        #expect(!sentSYN)
+
+        // The outgoing limit leaves the peer's budget alone
+        mock.injectInbound(YamuxFrame(type: .data, flags: .syn, streamID: 2, length: 0, data: nil).encode())
+        let inbound = try await connection.acceptStream()
+        #expect(inbound.id == 2)
+    }
+
     // MARK: - GoAway Tests
 
-    @Test("GoAway received closes connection")
-    func goAwayClosesConnection() async throws {
+    @Test("GoAway received stops new outbound streams with its reason")
+    func goAwayStopsNewStreams() async throws {
         let (connection, mock) = createTestConnection()
         connection.start()
 
@@ -319,46 +378,97 @@ struct YamuxConnectionTests {
         // Wait for processing
         try await Task.sleep(for: .milliseconds(100))
 
-        // newStream should fail
-        await #expect(throws: YamuxError.self) {
+        #expect(connection.remoteGoAwayReason == .normal)
+        do {
             _ = try await connection.newStream()
+            Issue.record("newStream succeeded after GoAway")
+        } catch YamuxError.remoteGoAway(let reason) {
+            #expect(reason == .normal)
         }
+
+        // No SYN went out for the refused stream
+        let sentSYN = mock.captureOutbound().contains { data in
+            guard let frame = try? decodeFrame(from: data) else { return false }
+            return frame.flags.contains(.syn)
+        }
+        #expect(!sentSYN)
     }
 
-    @Test("GoAway received notifies all streams")
-    func goAwayNotifiesAllStreams() async throws {
+    @Test("GoAway received is reported as a session event")
+    func goAwayEmitsSessionEvent() async throws {
         let (connection, mock) = createTestConnection()
+        let events = connection.events
         connection.start()
 
-        // Create some streams
-        let stream1 = try await connection.newStream()
-        let stream2 = try await connection.newStream()
+        let goAwayFrame = YamuxFrame.goAway(reason: .internalError)
+        mock.injectInbound(goAwayFrame.encode())
+        // A repeated GoAway is not reported again
+        mock.injectInbound(YamuxFrame.goAway(reason: .normal).encode())
 
-        // Start reads on streams
-        let readTask1 = Task { try await stream1.read() }
-        let readTask2 = Task { try await stream2.read() }
+        var iterator = events.makeAsyncIterator()
+        #expect(await iterator.next() == .sessionGoAway(reason: .internalError))
 
-        // Give tasks time to start
-        try await Task.sleep(for: .milliseconds(50))
+        try await Task.sleep(for: .milliseconds(100))
+        #expect(connection.remoteGoAwayReason == .internalError)
 
-        // Inject GoAway frame
-        let goAwayFrame = YamuxFrame.goAway(reason: .normal)
+        try await connection.close()
+        #expect(await iterator.next() == nil)
+    }
+
+    @Test("Unknown GoAway codes are reported as protocol errors")
+    func unknownGoAwayCode() async throws {
+        let (connection, mock) = createTestConnection()
+        connection.start()
+
+        let goAwayFrame = YamuxFrame(type: .goAway, flags: [], streamID: 0, length: 42, data: nil)
         mock.injectInbound(goAwayFrame.encode())
+        try await Task.sleep(for: .milliseconds(100))
 
-        // Wait for processing
+        #expect(connection.remoteGoAwayReason == .protocolError)
+    }
+
+    @Test("Existing streams keep draining after GoAway")
+    func goAwayLetsExistingStreamsDrain() async throws {
+        let (connection, mock) = createTestConnection()
+        connection.start()
+
+        let stream = try await connection.newStream()
+
+        mock.injectInbound(YamuxFrame.goAway(reason: .normal).encode())
         try await Task.sleep(for: .milliseconds(100))
 
-        // Reads should throw (streams should be notified)
-        await #expect(throws: YamuxError.self) {
-            _ = try await readTask1.value
-        }
-        await #expect(throws: YamuxError.self) {
-            _ = try await readTask2.value
+        // Data still flows in both directions on the open stream
+        mock.injectInbound(YamuxFrame.data(
+            streamID: UInt32(stream.id),
+            data: ByteBuffer(bytes: Array("after go-away".utf8))
+        ).encode())
+        let received = try await stream.read()
+        #expect(Array(received.readableBytesView) == Array("after go-away".utf8))
+
+        mock.clearOutbound()
+        try await stream.write(ByteBuffer(bytes: Array("reply".utf8)))
+        let wroteData = mock.captureOutbound().contains { data in
+            guard let frame = try? decodeFrame(from: data) else { return false }
+            return frame.type == .data && frame.streamID == UInt32(stream.id) && frame.length == 5
         }
+        #expect(wroteData)
+        #expect(connection.hasActiveStreams)
     }
 
-    @Test("GoAway received resumes pending accepts")
-    func goAwayResumesPendingAccepts() async throws {
+    @Test("Inbound streams are still accepted after GoAway received")
+    func goAwayStillAcceptsInboundStreams() async throws {
+        let (connection, mock) = createTestConnection(isInitiator: true)
+        connection.start()
+
+        mock.injectInbound(YamuxFrame.goAway(reason: .normal).encode())
+        mock.injectInbound(YamuxFrame(type: .data, flags: .syn, streamID: 2, length: 0, data: nil).encode())
+
+        let accepted = try await connection.acceptStream()
+        #expect(accepted.id == 2)
+    }
+
+    @Test("Pending accepts resume when the connection closes after GoAway")
+    func goAwayResumesPendingAcceptsOnClose() async throws {
         let (connection, mock) = createTestConnection()
         connection.start()
 
@@ -370,12 +480,14 @@ struct YamuxConnectionTests {
         // Give task time to start
         try await Task.sleep(for: .milliseconds(50))
 
-        // Inject GoAway frame
+        // Inject GoAway frame; the session drains, so the accept stays parked
         let goAwayFrame = YamuxFrame.goAway(reason: .protocolError)
         mock.injectInbound(goAwayFrame.encode())
-
-        // Wait for processing
         try await Task.sleep(for: .milliseconds(100))
+        #expect(connection.pendingAcceptCountForTesting == 1)
+
+        // The peer then closes the connection
+        mock.forceClose()
 
         // Accept should throw
         await #expect(throws: YamuxError.self) {
@@ -383,6 +495,24 @@ struct YamuxConnectionTests {
         }
     }
 
+    @Test("goAway() refuses new inbound streams but keeps open ones")
+    func localGoAwayRefusesInboundStreams() async throws {
+        let (connection, mock) = createTestConnection(isInitiator: true)
+        connection.start()
+
+        let stream = try await connection.newStream()
+        try await connection.goAway(reason: .normal)
+
+        mock.injectInbound(YamuxFrame(type: .data, flags: .syn, streamID: 2, length: 0, data: nil).encode())
+        try await Task.sleep(for: .milliseconds(100))
+
+        let outbound = mock.captureOutbound().compactMap { try? decodeFrame(from: $0) }
+        #expect(outbound.filter { $0.type == .goAway }.map(\.length) == [YamuxGoAwayReason.normal.rawValue])
+        #expect(outbound.contains { $0.flags.contains(.rst) && $0.streamID == 2 })
+
+        try await stream.write(ByteBuffer(bytes: Array("still open".utf8)))
+    }
+
     // MARK: - Ping Tests
 
     @Test("Ping request receives pong response")
@@ -425,6 +555,24 @@ struct YamuxConnectionTests {
         #expect(hasGoAway)
     }
 
+    @Test("Close sends a normal GoAway once, before closing streams")
+    func closeSendsGoAwayBeforeFIN() async throws {
+        let (connection, mock) = createTestConnection()
+        connection.start()
+        _ = try await connection.newStream()
+        try await connection.goAway()
+        try await connection.close()
+
+        let frames = mock.captureOutbound().compactMap { try? decodeFrame(from: $0) }
+        let goAways = frames.enumerated().filter { $0.element.type == .goAway }
+        #expect(goAways.count == 1)
+        #expect(goAways.first?.element.length == YamuxGoAwayReason.normal.rawValue)
+        let firstFIN = frames.firstIndex { $0.flags.contains(.fin) }
+        if let goAwayIndex = goAways.first?.offset, let firstFIN {
+            #expect(goAwayIndex < firstFIN)
+        }
+    }
+
     @Test("Close notifies all streams")
     func closeNotifiesAllStreams() async throws {
         let (connection, _) = createTestConnection()
@@ -715,4 +863,114 @@ struct YamuxConnectionTests {
 
         try await connection.close()
     }
+
+    // MARK: - Keep-Alive Probe Tests
+
+    @Test("Probe returns when its ping is acked", .timeLimit(.minutes(1)))
+    func probeReturnsOnAck() async throws {
+        let (connection, mock) = createTestConnection()
+        connection.start()
+        #expect(connection.keepAliveProbe?.mechanism == .muxerPing)
+
+        let probe = Task { try await connection.probe() }
+
+        var pingID: UInt32?
+        for _ in 0..<50 where pingID == nil {
+            try await Task.sleep(for: .milliseconds(10))
+            pingID = mock.captureOutbound().lazy
+                .compactMap { try? decodeFrame(from: $0) }
+                .first { $0.type == .ping && !$0.flags.contains(.ack) }?
+                .length
+        }
+        let id = try #require(pingID)
+        mock.injectInbound(YamuxFrame.ping(opaque: id, ack: true).encode())
+
+        let rtt = try await probe.value
+        #expect(rtt >= .zero)
+
+        try await connection.close()
+    }
+
+    @Test("Probe fails when the connection closes", .timeLimit(.minutes(1)))
+    func probeFailsOnClose() async throws {
+        let (connection, _) = createTestConnection()
+        connection.start()
+
+        let probe = Task { try await connection.probe() }
+        try await Task.sleep(for: .milliseconds(50))
+        try await connection.close()
+
+        await #expect(throws: YamuxError.self) {
+            _ = try await probe.value
+        }
+        await #expect(throws: YamuxError.self) {
+            _ = try await connection.probe()
+        }
+    }
+
+    @Test("Disabling the built-in keep-alive stops its pings and timeout")
+    func disableBuiltInKeepAlive() async throws {
+        let config = YamuxConfiguration(
+            enableKeepAlive: true,
+            keepAliveInterval: .milliseconds(50),
+            keepAliveTimeout: .milliseconds(100)
+        )
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.disableBuiltInKeepAlive()
+        connection.start()
+
+        try await Task.sleep(for: .milliseconds(250))
+
+        let hasPing = mock.captureOutbound().contains { data in
+            guard let frame = try? decodeFrame(from: data) else { return false }
+            return frame.type == .ping && !frame.flags.contains(.ack)
+        }
+        #expect(!hasPing)
+        let stream = try await connection.newStream()
+        #expect(stream.id > 0)
+
+        try await connection.close()
+    }
+
+    @Test("Wire tracer sees control frame headers but not plain data")
+    func wireTracerSeesControlFrames() async throws {
+        let traced = TracedFrames()
+        let config = YamuxConfiguration(enableKeepAlive: false, wireTracer: traced.tracer)
+        let (connection, mock) = createTestConnection(configuration: config)
+        connection.start()
+
+        let stream = try await connection.newStream()
+        try await stream.write(ByteBuffer(bytes: [1, 2, 3]))
+        mock.injectInbound(YamuxFrame.ping(opaque: 7).encode())
+        try await Task.sleep(for: .milliseconds(100))
+
+        let entries = traced.entries.withLock { $0 }
+        #expect(entries.map(\.phase) == [.yamuxData, .yamuxPing, .yamuxPing])
+        #expect(entries.map(\.direction) == [.outbound, .inbound, .outbound])
+        // Only the 12-byte header is traced
+        #expect(entries.allSatisfy { $0.bytes.count == 12 })
+        let syn = try #require(try decodeFrame(from: Data(entries[0].bytes)))
+        #expect(syn.flags.contains(.syn))
+        let ack = try #require(try decodeFrame(from: Data(entries[2].bytes)))
+        #expect(ack.flags.contains(.ack))
+
+        try await connection.close()
+    }
+}
+
+/// Collects what a `WireTracer` is handed.
+private final class TracedFrames: Sendable {
+    struct Entry: Sendable {
+        let direction: WireDirection
+        let phase: WirePhase
+        let bytes: [UInt8]
+    }
+
+    let entries = Mutex<[Entry]>([])
+
+    var tracer: WireTracer {
+        WireTracer { direction, phase, bytes in
+            self.entries.withLock { $0.append(Entry(direction: direction, phase: phase, bytes: bytes)) }
+        }
+    }
 }
@@ -236,4 +236,91 @@ struct YamuxFlowControlTests {
         #expect(connectionWindowUpdate,
                 "Consuming data must return the connection budget via a stream-0 window update")
     }
+
+    // MARK: - Backpressure (mirrors /test/flow/1.0.0)
+
+    @Test("Window update is withheld until the application reads")
+    func windowUpdateWithheldUntilRead() async throws {
+        let windowSize: UInt32 = 1024
+        let config = YamuxConfiguration(
+            initialWindowSize: windowSize,
+            enableKeepAlive: false,
+            enableWindowAutoTuning: false
+        )
+        let (connection, mock) = makeConnection(configuration: config)
+        connection.start()
+        let stream = YamuxStream(id: 1, connection: connection, initialWindowSize: windowSize)
+        mock.clearOutbound()
+
+        // The sender fills the whole window while nobody reads.
+        let payload = ByteBuffer(bytes: Data(repeating: 0x11, count: Int(windowSize)))
+        #expect(stream.dataReceived(payload))
+        try await Task.sleep(for: .milliseconds(100))
+
+        #expect(totalWindowReturned(decodeAllFrames(mock.captureOutbound()), streamID: 1) == 0,
+                "Buffered-but-unread data must not return window to the sender")
+
+        // Once the application reads, the window is returned.
+        let read = try await stream.read()
+        #expect(read.readableBytes == Int(windowSize))
+        try await Task.sleep(for: .milliseconds(100))
+
+        #expect(totalWindowReturned(decodeAllFrames(mock.captureOutbound()), streamID: 1) == windowSize)
+    }
+
+    @Test("Unread buffering is capped at the receive window")
+    func unreadBufferingCappedAtWindow() async throws {
+        let windowSize: UInt32 = 1024
+        let config = YamuxConfiguration(
+            initialWindowSize: windowSize,
+            enableKeepAlive: false,
+            enableWindowAutoTuning: false
+        )
+        let (connection, _) = makeConnection(configuration: config)
+        connection.start()
+        let stream = YamuxStream(id: 1, connection: connection, initialWindowSize: windowSize)
+
+        let payload = ByteBuffer(bytes: Data(repeating: 0x11, count: Int(windowSize)))
+        #expect(stream.dataReceived(payload))
+
+        // A sender ignoring backpressure is a protocol violation, not more buffering.
+        let extra = ByteBuffer(bytes: Data([0x22]))
+        #expect(!stream.dataReceived(extra))
+    }
+
+    @Test("Sender blocks until the receiver returns window", .timeLimit(.minutes(1)))
+    func senderThrottledByWindow() async throws {
+        let windowSize: UInt32 = 1024
+        let config = YamuxConfiguration(
+            initialWindowSize: windowSize,
+            enableKeepAlive: false,
+            enableWindowAutoTuning: false
+        )
+        let (connection, mock) = makeConnection(configuration: config)
+        connection.start()
+        let stream = YamuxStream(id: 1, connection: connection, initialWindowSize: windowSize)
+
+        // Write twice the window; only the first half may go out immediately.
+        let payload = ByteBuffer(bytes: Data(repeating: 0x33, count: Int(windowSize) * 2))
+        let writeTask = Task {
+            try await stream.write(payload)
+            return ContinuousClock.now
+        }
+        try await Task.sleep(for: .milliseconds(200))
+
+        let sentBeforeUpdate = decodeAllFrames(mock.captureOutbound())
+            .filter { $0.type == .data && $0.streamID == 1 }
+            .reduce(0) { $0 + Int($1.length) }
+        #expect(sentBeforeUpdate == Int(windowSize), "Sender must stop at the window boundary")
+
+        let updatedAt = ContinuousClock.now
+        stream.windowUpdate(delta: windowSize)
+        let finishedAt = try await writeTask.value
+        #expect(finishedAt >= updatedAt)
+
+        let sentTotal = decodeAllFrames(mock.captureOutbound())
+            .filter { $0.type == .data && $0.streamID == 1 }
+            .reduce(0) { $0 + Int($1.length) }
+        #expect(sentTotal == Int(windowSize) * 2)
+    }
 }
@@ -638,6 +638,8 @@ struct YamuxConfigurationTests {
     func defaultConfiguration() {
         let config = YamuxConfiguration.default
         #expect(config.maxConcurrentStreams == 1000)
+        #expect(config.maxIncomingStreams == 1000)
+        #expect(config.maxOutgoingStreams == 1000)
         #expect(config.initialWindowSize == 256 * 1024)
     }
 
@@ -0,0 +1,280 @@
+/// FetchServiceTests - Unit tests for the fetch protocol
+import Testing
+import Foundation
+import NIOCore
+import Synchronization
+@testable import P2PFetch
+@testable import P2PCore
+@testable import P2PMux
+@testable import P2PProtocols
+
+// MARK: - Protobuf
+
+@Suite("FetchProtobuf Tests")
+struct FetchProtobufTests {
+
+    @Test("Protocol ID matches go-libp2p")
+    func protocolID() {
+        #expect(FetchProtocol.protocolID == "/libp2p/fetch/0.0.1")
+    }
+
+    @Test("Request encodes identifier as field 1")
+    func encodeRequest() {
+        let encoded = FetchProtobuf.encode(FetchRequest(identifier: "ab"))
+        #expect(Array(encoded.readableBytesView) == [0x0A, 0x02, 0x61, 0x62])
+    }
+
+    @Test("Request round-trips")
+    func requestRoundTrip() throws {
+        let request = FetchRequest(identifier: "/record/key")
+        let decoded = try FetchProtobuf.decodeRequest(FetchProtobuf.encode(request))
+        #expect(decoded == request)
+    }
+
+    @Test("OK response omits the status field")
+    func encodeOKResponse() {
+        let encoded = FetchProtobuf.encode(FetchResponse(status: .ok, data: Data([0x01])))
+        #expect(Array(encoded.readableBytesView) == [0x12, 0x01, 0x01])
+    }
+
+    @Test("NOT_FOUND response carries only the status")
+    func encodeNotFoundResponse() {
+        let encoded = FetchProtobuf.encode(FetchResponse(status: .notFound))
+        #expect(Array(encoded.readableBytesView) == [0x08, 0x01])
+    }
+
+    @Test("Empty response decodes as OK with no data")
+    func decodeEmptyResponse() throws {
+        let decoded = try FetchProtobuf.decodeResponse(ByteBuffer())
+        #expect(decoded == FetchResponse(status: .ok))
+    }
+
+    @Test("Response round-trips for every status")
+    func responseRoundTrip() throws {
+        for response in [
+            FetchResponse(status: .ok, data: Data("value".utf8)),
+            FetchResponse(status: .notFound),
+            FetchResponse(status: .error),
+        ] {
+            let decoded = try FetchProtobuf.decodeResponse(FetchProtobuf.encode(response))
+            #expect(decoded == response)
+        }
+    }
+
+    @Test("Unknown status code is rejected")
+    func unknownStatus() {
+        #expect(throws: FetchError.unknownStatus(7)) {
+            try FetchProtobuf.decodeResponse(ByteBuffer(bytes: [0x08, 0x07]))
+        }
+    }
+
+    @Test("Unknown fields are skipped")
+    func unknownFieldsSkipped() throws {
+        // field 3 varint, then identifier
+        let bytes: [UInt8] = [0x18, 0x05, 0x0A, 0x01, 0x6B]
+        let decoded = try FetchProtobuf.decodeRequest(ByteBuffer(bytes: bytes))
+        #expect(decoded.identifier == "k")
+    }
+
+    @Test("Truncated field is rejected")
+    func truncatedField() {
+        #expect(throws: FetchError.self) {
+            try FetchProtobuf.decodeRequest(ByteBuffer(bytes: [0x0A, 0x05, 0x61]))
+        }
+    }
+
+    @Test("Invalid UTF-8 identifier is rejected")
+    func invalidUTF8() {
+        #expect(throws: FetchError.self) {
+            try FetchProtobuf.decodeRequest(ByteBuffer(bytes: [0x0A, 0x01, 0xFF]))
+        }
+    }
+}
+
+// MARK: - Mocks
+
+/// Mock stream that delivers fixed chunks then EOF and records writes.
+private final class FetchMockStream: MuxedStream, Sendable {
+    let id: UInt64 = 1
+    let protocolID: String? = FetchProtocol.protocolID
+
+    private let state: Mutex<State>
+    private struct State: Sendable {
+        var toDeliver: [ByteBuffer]
+        var written: [ByteBuffer] = []
+        var writeClosed = false
+        var closed = false
+    }
+
+    init(deliver: [ByteBuffer]) {
+        self.state = Mutex(State(toDeliver: deliver))
+    }
+
+    var written: [ByteBuffer] { state.withLock { $0.written } }
+    var writeClosed: Bool { state.withLock { $0.writeClosed } }
+
+    func read() async throws -> ByteBuffer {
+        state.withLock { s in
+            if s.toDeliver.isEmpty { return ByteBuffer() } // EOF
+            return s.toDeliver.removeFirst()
+        }
+    }
+    func write(_ data: ByteBuffer) async throws { state.withLock { $0.written.append(data) } }
+    func closeWrite() async throws { state.withLock { $0.writeClosed = true } }
+    func closeRead() async throws {}
+    func close() async throws { state.withLock { $0.closed = true } }
+    func reset() async throws { state.withLock { $0.closed = true } }
+}
+
+/// Opener that hands out a prepared stream.
+private struct FetchMockOpener: StreamOpener {
+    let stream: FetchMockStream
+
+    func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
+        stream
+    }
+}
+
+private func delimited(_ message: ByteBuffer) -> ByteBuffer {
+    var buffer = ByteBuffer()
+    Varint.encode(UInt64(message.readableBytes), into: &buffer)
+    buffer.writeImmutableBuffer(message)
+    return buffer
+}
+
+private func makeContext(_ stream: MuxedStream) -> StreamContext {
+    StreamContext(
+        stream: stream,
+        remotePeer: KeyPair.generateEd25519().peerID,
+        remoteAddress: Multiaddr.tcp(host: "127.0.0.1", port: 4001),
+        localPeer: KeyPair.generateEd25519().peerID,
+        localAddress: nil,
+        protocolID: FetchProtocol.protocolID
+    )
+}
+
+// MARK: - Service
+
+@Suite("FetchService Tests", .serialized)
+struct FetchServiceTests {
+
+    /// Runs the inbound handler for `key` and decodes the written response.
+    private func serve(_ service: FetchService, key: String) async throws -> FetchResponse {
+        let request = delimited(FetchProtobuf.encode(FetchRequest(identifier: key)))
+        let stream = FetchMockStream(deliver: [request])
+        await service.handleInboundStream(makeContext(stream))
+
+        var written = ByteBuffer()
+        for var chunk in stream.written {
+            written.writeBuffer(&chunk)
+        }
+        let length = try Varint.decode(from: &written)
+        let message = try #require(written.readSlice(length: Int(length)))
+        return try FetchProtobuf.decodeResponse(message)
+    }
+
+    @Test("Resolver value is served with OK", .timeLimit(.minutes(1)))
+    func servesValue() async throws {
+        let service = FetchService()
+        service.setResolver { key in key == "a" ? Data("value-a".utf8) : nil }
+
+        let response = try await serve(service, key: "a")
+        #expect(response == FetchResponse(status: .ok, data: Data("value-a".utf8)))
+        try await service.shutdown()
+    }
+
+    @Test("Missing key is answered with NOT_FOUND", .timeLimit(.minutes(1)))
+    func servesNotFound() async throws {
+        let service = FetchService()
+        service.setResolver { _ in nil }
+
+        let response = try await serve(service, key: "missing")
+        #expect(response.status == .notFound)
+        try await service.shutdown()
+    }
+
+    @Test("No resolver is answered with NOT_FOUND", .timeLimit(.minutes(1)))
+    func servesWithoutResolver() async throws {
+        let service = FetchService()
+        let response = try await serve(service, key: "a")
+        #expect(response.status == .notFound)
+        try await service.shutdown()
+    }
+
+    @Test("Throwing resolver is answered with ERROR", .timeLimit(.minutes(1)))
+    func servesError() async throws {
+        struct LookupFailed: Error {}
+        let service = FetchService()
+        service.setResolver { _ in throw LookupFailed() }
+
+        let response = try await serve(service, key: "a")
+        #expect(response.status == .error)
+        try await service.shutdown()
+    }
+
+    @Test("fetch returns the value and half-closes the stream", .timeLimit(.minutes(1)))
+    func fetchValue() async throws {
+        let response = delimited(FetchProtobuf.encode(FetchResponse(status: .ok, data: Data([1, 2, 3]))))
+        let stream = FetchMockStream(deliver: [response])
+        let service = FetchService()
+
+        let value = try await service.fetch(
+            peer: KeyPair.generateEd25519().peerID,
+            key: "k",
+            using: FetchMockOpener(stream: stream)
+        )
+        #expect(value == Data([1, 2, 3]))
+        #expect(stream.writeClosed)
+
+        var written = ByteBuffer()
+        for var chunk in stream.written {
+            written.writeBuffer(&chunk)
+        }
+        #expect(Array(written.readableBytesView) == [0x03, 0x0A, 0x01, 0x6B])
+        try await service.shutdown()
+    }
+
+    @Test("fetch returns nil for NOT_FOUND", .timeLimit(.minutes(1)))
+    func fetchNotFound() async throws {
+        let response = delimited(FetchProtobuf.encode(FetchResponse(status: .notFound)))
+        let service = FetchService()
+
+        let value = try await service.fetch(
+            peer: KeyPair.generateEd25519().peerID,
+            key: "k",
+            using: FetchMockOpener(stream: FetchMockStream(deliver: [response]))
+        )
+        #expect(value == nil)
+        try await service.shutdown()
+    }
+
+    @Test("fetch throws remoteError for ERROR", .timeLimit(.minutes(1)))
+    func fetchRemoteError() async throws {
+        let response = delimited(FetchProtobuf.encode(FetchResponse(status: .error)))
+        let service = FetchService()
+
+        await #expect(throws: FetchError.remoteError) {
+            try await service.fetch(
+                peer: KeyPair.generateEd25519().peerID,
+                key: "k",
+                using: FetchMockOpener(stream: FetchMockStream(deliver: [response]))
+            )
+        }
+        try await service.shutdown()
+    }
+
+    @Test("fetch rejects an oversized identifier", .timeLimit(.minutes(1)))
+    func fetchIdentifierTooLarge() async throws {
+        let service = FetchService()
+        let key = String(repeating: "k", count: FetchProtocol.maxIdentifierSize + 1)
+
+        await #expect(throws: FetchError.identifierTooLarge(key.utf8.count)) {
+            try await service.fetch(
+                peer: KeyPair.generateEd25519().peerID,
+                key: key,
+                using: FetchMockOpener(stream: FetchMockStream(deliver: []))
+            )
+        }
+        try await service.shutdown()
+    }
+}
@@ -0,0 +1,140 @@
+/// BlacklistTests - Tests for GossipSub peer blacklisting
+import Testing
+import Foundation
+@testable import P2PGossipSub
+@testable import P2PCore
+@testable import P2PMux
+
+@Suite("Blacklist Tests", .serialized)
+struct BlacklistTests {
+
+    // MARK: - Helpers
+
+    private func makePeerID() -> PeerID {
+        KeyPair.generateEd25519().peerID
+    }
+
+    private func makeRouter(
+        configuration: GossipSubConfiguration = .testing
+    ) -> GossipSubRouter {
+        GossipSubRouter(localPeerID: makePeerID(), configuration: configuration)
+    }
+
+    private func addPeer(_ peer: PeerID, subscribedTo topic: Topic, in router: GossipSubRouter) {
+        let state = PeerState(peerID: peer, version: .v11, direction: .outbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+        router.peerState.updatePeer(peer) { s in
+            s.subscriptions.insert(topic)
+        }
+    }
+
+    private func makeMessage(from source: PeerID, topic: Topic) -> GossipSubMessage {
+        GossipSubMessage(
+            source: source,
+            data: Data("Hello".utf8),
+            sequenceNumber: Data([0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08]),
+            topic: topic
+        )
+    }
+
+    // MARK: - Tests
+
+    @Test("Blacklisting removes the peer from meshes and routing")
+    func blacklistRemovesFromMesh() throws {
+        let router = makeRouter()
+        let topic = Topic("test-topic")
+        _ = try router.subscribe(to: topic)
+
+        let peer = makePeerID()
+        addPeer(peer, subscribedTo: topic, in: router)
+        router.meshState.addToMesh(peer, for: topic)
+
+        router.blacklistPeer(peer)
+
+        #expect(router.isBlacklisted(peer))
+        #expect(!router.meshState.isInMesh(peer, for: topic))
+        #expect(router.peerState.getPeer(peer) == nil)
+        #expect(!router.peersForPublish(topic: topic).contains(peer))
+    }
+
+    @Test("RPCs from a blacklisted peer are dropped")
+    func blacklistedRPCDropped() async throws {
+        let router = makeRouter()
+        let topic = Topic("test-topic")
+        _ = try router.subscribe(to: topic)
+
+        let peer = makePeerID()
+        let meshPeer = makePeerID()
+        router.meshState.addToMesh(meshPeer, for: topic)
+        router.blacklistPeer(peer)
+
+        var control = ControlMessageBatch()
+        control.grafts.append(ControlMessage.Graft(topic: topic))
+        var rpc = GossipSubRPC(subscriptions: [.subscribe(to: topic)], control: control)
+        let message = makeMessage(from: peer, topic: topic)
+        rpc.messages.append(message)
+
+        let result = await router.handleRPC(rpc, from: peer)
+
+        #expect(result.response == nil)
+        #expect(result.forwardMessages.isEmpty)
+        #expect(!router.seenCache.contains(message.id))
+        #expect(!router.meshState.isInMesh(peer, for: topic))
+    }
+
+    @Test("Messages originated by a blacklisted peer are dropped when relayed")
+    func blacklistedSourceDropped() async throws {
+        let router = makeRouter()
+        let topic = Topic("test-topic")
+        _ = try router.subscribe(to: topic)
+
+        let source = makePeerID()
+        let relay = makePeerID()
+        let meshPeer = makePeerID()
+        router.meshState.addToMesh(meshPeer, for: topic)
+        router.blacklistPeer(source)
+
+        var rpc = GossipSubRPC()
+        rpc.messages.append(makeMessage(from: source, topic: topic))
+        let result = await router.handleRPC(rpc, from: relay)
+
+        #expect(result.forwardMessages.isEmpty)
+    }
+
+    @Test("Heartbeat never grafts a blacklisted peer")
+    func heartbeatSkipsBlacklisted() throws {
+        let router = makeRouter()
+        let topic = Topic("test-topic")
+        _ = try router.subscribe(to: topic)
+
+        let peer = makePeerID()
+        addPeer(peer, subscribedTo: topic, in: router)
+        router.blacklistPeer(peer)
+
+        let actions = router.maintainMesh()
+
+        #expect(!actions.contains(where: { $0.peer == peer }))
+        #expect(!router.meshState.isInMesh(peer, for: topic))
+    }
+
+    @Test("Removing from the blacklist accepts RPCs again")
+    func removeFromBlacklist() async throws {
+        let router = makeRouter()
+        let topic = Topic("test-topic")
+        _ = try router.subscribe(to: topic)
+
+        let peer = makePeerID()
+        let meshPeer = makePeerID()
+        router.meshState.addToMesh(meshPeer, for: topic)
+
+        router.blacklistPeer(peer)
+        router.removeFromBlacklist(peer)
+        #expect(!router.isBlacklisted(peer))
+
+        var rpc = GossipSubRPC()
+        rpc.messages.append(makeMessage(from: peer, topic: topic))
+        let result = await router.handleRPC(rpc, from: peer)
+
+        #expect(result.forwardMessages.count == 1)
+    }
+}
@@ -0,0 +1,149 @@
+/// DirectConnectTests - Redialing GossipSub direct peers through the
+/// dialer set with `setDialer`.
+
+import Testing
+import Foundation
+import Synchronization
+@testable import P2PGossipSub
+@testable import P2PCore
+@testable import P2PMux
+
+@Suite("Direct Connect Tests", .serialized)
+struct DirectConnectTests {
+
+    /// Records every dial and fails them while `failing` is set.
+    private final class RecordingDialer: Sendable {
+        private struct State {
+            var dials: [(address: Multiaddr, at: ContinuousClock.Instant)] = []
+            var failing: Bool
+        }
+
+        private let state: Mutex<State>
+
+        init(failing: Bool) {
+            self.state = Mutex(State(failing: failing))
+        }
+
+        var addresses: [Multiaddr] {
+            state.withLock { $0.dials.map(\.address) }
+        }
+
+        var instants: [ContinuousClock.Instant] {
+            state.withLock { $0.dials.map(\.at) }
+        }
+
+        func dial(_ address: Multiaddr) throws {
+            let failing = state.withLock { s -> Bool in
+                s.dials.append((address, .now))
+                return s.failing
+            }
+            if failing {
+                throw DialFailure()
+            }
+        }
+
+        struct DialFailure: Error {}
+    }
+
+    private func makeService(
+        peer: PeerID,
+        address: Multiaddr,
+        initialDelay: Duration,
+        backoff: Duration,
+        maxBackoff: Duration
+    ) -> GossipSubService {
+        var config = GossipSubConfiguration.testing
+        config.directConnectPeers = [AddrInfo(peer: peer, addresses: [address])]
+        config.directConnectInitialDelay = initialDelay
+        config.directConnectBackoff = backoff
+        config.directConnectMaxBackoff = maxBackoff
+        return GossipSubService(localPeerID: KeyPair.generateEd25519().peerID, configuration: config)
+    }
+
+    /// Polls until `dialer` has recorded `count` dials.
+    private func waitForDials(_ dialer: RecordingDialer, count: Int) async throws {
+        for _ in 0..<200 {
+            if dialer.addresses.count >= count { return }
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        Issue.record("expected \(count) dials, saw \(dialer.addresses.count)")
+    }
+
+    @Test("Disconnected direct peer is redialed through the dialer", .timeLimit(.minutes(1)))
+    func disconnectedDirectPeerIsRedialed() async throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let address = try Multiaddr("/ip4/127.0.0.1/tcp/4001")
+        let service = makeService(
+            peer: peer,
+            address: address,
+            initialDelay: .milliseconds(10),
+            backoff: .milliseconds(10),
+            maxBackoff: .milliseconds(100)
+        )
+        let dialer = RecordingDialer(failing: false)
+        service.setDialer { try dialer.dial($0) }
+        service.start()
+        defer { Task { try? await service.shutdown() } }
+
+        // The initial dial happens after start
+        try await waitForDials(dialer, count: 1)
+        service.handlePeerConnected(peer, protocolID: "/meshsub/1.1.0", direction: .outbound, stream: GossipSubMockStream())
+        try await Task.sleep(for: .milliseconds(50))
+
+        service.handlePeerDisconnected(peer)
+        try await waitForDials(dialer, count: 2)
+
+        let expected = try address.appending(.p2p(peer))
+        #expect(dialer.addresses == [expected, expected])
+    }
+
+    @Test("Failed redials back off exponentially up to the maximum", .timeLimit(.minutes(1)))
+    func failedRedialsBackOff() async throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let service = makeService(
+            peer: peer,
+            address: try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            initialDelay: .milliseconds(10),
+            backoff: .milliseconds(10),
+            maxBackoff: .milliseconds(80)
+        )
+        let dialer = RecordingDialer(failing: true)
+        service.setDialer { try dialer.dial($0) }
+        service.start()
+        defer { Task { try? await service.shutdown() } }
+
+        // Delays before each dial: 10, 20, 40, 80, 80 ms
+        try await waitForDials(dialer, count: 5)
+        let instants = dialer.instants
+        let gaps = zip(instants.dropFirst(), instants).map { $0 - $1 }
+        #expect(gaps[0] >= .milliseconds(20))
+        #expect(gaps[1] >= .milliseconds(40))
+        #expect(gaps[2] >= .milliseconds(80))
+        #expect(gaps[3] >= .milliseconds(80))
+        #expect(gaps[3] < .milliseconds(160))
+    }
+
+    @Test("Redialing stops once the direct peer is connected", .timeLimit(.minutes(1)))
+    func redialStopsWhenConnected() async throws {
+        let peer = KeyPair.generateEd25519().peerID
+        let service = makeService(
+            peer: peer,
+            address: try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+            initialDelay: .milliseconds(10),
+            backoff: .milliseconds(10),
+            maxBackoff: .milliseconds(20)
+        )
+        let dialer = RecordingDialer(failing: true)
+        service.setDialer { try dialer.dial($0) }
+        service.start()
+        defer { Task { try? await service.shutdown() } }
+
+        try await waitForDials(dialer, count: 1)
+        service.handlePeerConnected(peer, protocolID: "/meshsub/1.1.0", direction: .outbound, stream: GossipSubMockStream())
+        // At most one dial already past the connected check
+        try await Task.sleep(for: .milliseconds(50))
+        let count = dialer.addresses.count
+        try await Task.sleep(for: .milliseconds(150))
+        #expect(dialer.addresses.count == count)
+    }
+}
@@ -306,4 +306,129 @@ struct ExplicitPeeringTests {
         let responsePrunes = result.response?.control?.prunes.filter { $0.topic == topic } ?? []
         #expect(!responsePrunes.isEmpty)
     }
+
+    // MARK: - Address-Based Direct Peers
+
+    @Test("Direct connect peers are direct for every topic and protected")
+    func directConnectPeersApplyToAllTopics() throws {
+        let peer = makePeerID()
+        var config = GossipSubConfiguration.testing
+        config.directConnectPeers = [
+            AddrInfo(peer: peer, addresses: [try Multiaddr("/ip4/127.0.0.1/tcp/4001")])
+        ]
+        let router = makeRouter(configuration: config)
+
+        #expect(router.isDirectPeer(peer))
+        #expect(router.directPeers(for: Topic("a")).contains(peer))
+        #expect(router.directPeers(for: Topic("b")).contains(peer))
+        #expect(router.peerScorer.isProtected(peer))
+
+        // Topic-level removal must not drop protection for a configured peer
+        router.addDirectPeer(peer, for: Topic("a"))
+        router.removeDirectPeer(peer, from: Topic("a"))
+        #expect(router.peerScorer.isProtected(peer))
+    }
+
+    @Test("Subscription from a direct peer is answered with GRAFT")
+    func directPeerSubscriptionGrafts() async throws {
+        let peer = makePeerID()
+        var config = GossipSubConfiguration.testing
+        config.directConnectPeers = [AddrInfo(peer: peer, addresses: [])]
+        let router = makeRouter(configuration: config)
+        let topic = Topic("test-topic")
+
+        _ = try router.subscribe(to: topic)
+
+        let state = PeerState(peerID: peer, version: .v11, direction: .outbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+
+        let rpc = GossipSubRPC(subscriptions: [.subscribe(to: topic)])
+        let result = await router.handleRPC(rpc, from: peer)
+
+        let grafts = result.response?.control?.grafts.filter { $0.topic == topic } ?? []
+        #expect(grafts.count == 1)
+        #expect(router.meshState.isInMesh(peer, for: topic))
+    }
+
+    @Test("Heartbeat grafts a direct peer missing from the mesh")
+    func heartbeatGraftsDirectPeer() throws {
+        let peer = makePeerID()
+        var config = GossipSubConfiguration.testing
+        config.directConnectPeers = [AddrInfo(peer: peer, addresses: [])]
+        let router = makeRouter(configuration: config)
+        let topic = Topic("test-topic")
+
+        _ = try router.subscribe(to: topic)
+
+        let state = PeerState(peerID: peer, version: .v11, direction: .outbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+        router.peerState.updatePeer(peer) { s in
+            s.subscriptions.insert(topic)
+        }
+
+        let actions = router.maintainMesh()
+
+        let grafted = actions.filter { $0.control.grafts.contains(where: { $0.topic == topic }) }
+            .map(\.peer)
+        #expect(grafted.contains(peer))
+        #expect(router.meshState.isInMesh(peer, for: topic))
+    }
+
+    @Test("Heartbeat does not re-graft a direct peer that pruned us with backoff")
+    func heartbeatRespectsDirectPeerBackoff() throws {
+        let peer = makePeerID()
+        var config = GossipSubConfiguration.testing
+        config.directConnectPeers = [AddrInfo(peer: peer, addresses: [])]
+        let router = makeRouter(configuration: config)
+        let topic = Topic("test-topic")
+
+        _ = try router.subscribe(to: topic)
+
+        let state = PeerState(peerID: peer, version: .v11, direction: .outbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+        router.peerState.updatePeer(peer) { s in
+            s.subscriptions.insert(topic)
+            s.setBackoff(for: topic, duration: .seconds(60))
+        }
+
+        let actions = router.maintainMesh()
+
+        #expect(!actions.contains(where: { $0.peer == peer }))
+        #expect(!router.meshState.isInMesh(peer, for: topic))
+    }
+
+    @Test("Penalized direct peer stays meshed through heartbeat")
+    func penalizedDirectPeerStaysMeshed() throws {
+        let peer = makePeerID()
+        var config = GossipSubConfiguration.testing
+        config.meshDegree = 2
+        config.meshDegreeLow = 1
+        config.meshDegreeHigh = 3
+        config.directConnectPeers = [AddrInfo(peer: peer, addresses: [])]
+        let router = makeRouter(configuration: config)
+        let topic = Topic("test-topic")
+
+        _ = try router.subscribe(to: topic)
+
+        for p in [peer] + (0..<5).map({ _ in makePeerID() }) {
+            let state = PeerState(peerID: p, version: .v11, direction: .outbound)
+            router.peerState.addPeer(state, stream: GossipSubMockStream())
+            router.peerState.updatePeer(p) { s in
+                s.subscriptions.insert(topic)
+            }
+            router.meshState.addToMesh(p, for: topic)
+        }
+
+        // Would push a regular peer's score negative and get it pruned
+        for _ in 0..<10 {
+            router.peerScorer.recordInvalidMessage(from: peer)
+        }
+
+        let actions = router.maintainMesh()
+
+        let pruned = actions.filter { $0.control.prunes.contains(where: { $0.topic == topic }) }
+            .map(\.peer)
+        #expect(!pruned.contains(peer))
+        #expect(router.meshState.isInMesh(peer, for: topic))
+    }
 }
@@ -0,0 +1,161 @@
+/// SubscriptionFilterTests - Tests for topic subscription filters
+import Testing
+import Foundation
+@testable import P2PGossipSub
+@testable import P2PCore
+@testable import P2PMux
+
+@Suite("Subscription Filter Tests")
+struct SubscriptionFilterTests {
+
+    // MARK: - Helpers
+
+    private func makePeerID() -> PeerID {
+        KeyPair.generateEd25519().peerID
+    }
+
+    private func subscriptions(_ topics: String...) -> [GossipSubRPC.SubscriptionOpt] {
+        topics.map { .subscribe(to: Topic($0)) }
+    }
+
+    // MARK: - Filters
+
+    @Test("Whitelist filter drops topics outside the allowlist")
+    func whitelistDropsUnknownTopics() throws {
+        let filter = WhitelistSubscriptionFilter(allowedTopics: ["blocks"])
+
+        #expect(filter.canSubscribe(to: "blocks"))
+        #expect(!filter.canSubscribe(to: "spam"))
+
+        let accepted = try filter.filterIncomingSubscriptions(
+            subscriptions("blocks", "spam"),
+            currentlySubscribed: []
+        )
+        #expect(accepted.map(\.topic) == ["blocks"])
+    }
+
+    @Test("Regex filter accepts only matching topics")
+    func regexFilterMatchesPattern() throws {
+        let filter = try RegexSubscriptionFilter(pattern: "^/eth2/[0-9a-f]+/beacon_block$")
+
+        #expect(filter.canSubscribe(to: "/eth2/abcd/beacon_block"))
+        #expect(!filter.canSubscribe(to: "/eth2/abcd/beacon_block/extra"))
+        #expect(!filter.canSubscribe(to: "random"))
+
+        let accepted = try filter.filterIncomingSubscriptions(
+            subscriptions("/eth2/00ff/beacon_block", "random"),
+            currentlySubscribed: []
+        )
+        #expect(accepted.map(\.topic) == ["/eth2/00ff/beacon_block"])
+    }
+
+    @Test("Invalid regex pattern throws")
+    func invalidRegexThrows() {
+        #expect(throws: (any Error).self) {
+            _ = try RegexSubscriptionFilter(pattern: "(unclosed")
+        }
+    }
+
+    @Test("Max count filter rejects peers exceeding the per-peer limit")
+    func maxCountRejectsPerPeerOverflow() throws {
+        let filter = MaxCountSubscriptionFilter(
+            maxSubscriptionsPerPeer: 2,
+            maxSubscriptionsPerRequest: 10
+        )
+
+        // Re-subscribing to known topics does not count toward the limit
+        _ = try filter.filterIncomingSubscriptions(
+            subscriptions("a", "b"),
+            currentlySubscribed: ["a", "b"]
+        )
+
+        #expect(throws: GossipSubError.self) {
+            _ = try filter.filterIncomingSubscriptions(
+                subscriptions("c"),
+                currentlySubscribed: ["a", "b"]
+            )
+        }
+    }
+
+    @Test("Max count filter rejects oversized requests")
+    func maxCountRejectsOversizedRequest() {
+        let filter = MaxCountSubscriptionFilter(
+            maxSubscriptionsPerPeer: 100,
+            maxSubscriptionsPerRequest: 2
+        )
+
+        #expect(throws: GossipSubError.self) {
+            _ = try filter.filterIncomingSubscriptions(
+                subscriptions("a", "b", "c"),
+                currentlySubscribed: []
+            )
+        }
+    }
+
+    @Test("Max count filter applies the inner filter")
+    func maxCountWrapsInnerFilter() throws {
+        let filter = MaxCountSubscriptionFilter(
+            inner: try RegexSubscriptionFilter(pattern: "^allowed-"),
+            maxSubscriptionsPerPeer: 10,
+            maxSubscriptionsPerRequest: 10
+        )
+
+        #expect(!filter.canSubscribe(to: "other"))
+
+        let accepted = try filter.filterIncomingSubscriptions(
+            subscriptions("allowed-1", "other"),
+            currentlySubscribed: []
+        )
+        #expect(accepted.map(\.topic) == ["allowed-1"])
+    }
+
+    // MARK: - Router Integration
+
+    @Test("Router ignores filtered remote subscriptions")
+    func routerIgnoresFilteredSubscriptions() async throws {
+        var config = GossipSubConfiguration.testing
+        config.subscriptionFilter = try RegexSubscriptionFilter(pattern: "^allowed$")
+        let router = GossipSubRouter(localPeerID: makePeerID(), configuration: config)
+
+        let peer = makePeerID()
+        let state = PeerState(peerID: peer, version: .v11, direction: .inbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+
+        let rpc = GossipSubRPC(subscriptions: subscriptions("allowed", "spam"))
+        _ = await router.handleRPC(rpc, from: peer)
+
+        let subscribed = router.peerState.getPeer(peer)?.subscriptions ?? []
+        #expect(subscribed == ["allowed"])
+    }
+
+    @Test("Router discards the whole RPC when the filter throws")
+    func routerDiscardsRejectedRPC() async throws {
+        var config = GossipSubConfiguration.testing
+        config.subscriptionFilter = MaxCountSubscriptionFilter(
+            maxSubscriptionsPerPeer: 1,
+            maxSubscriptionsPerRequest: 10
+        )
+        let router = GossipSubRouter(localPeerID: makePeerID(), configuration: config)
+
+        let peer = makePeerID()
+        let state = PeerState(peerID: peer, version: .v11, direction: .inbound)
+        router.peerState.addPeer(state, stream: GossipSubMockStream())
+
+        let rpc = GossipSubRPC(subscriptions: subscriptions("a", "b"))
+        _ = await router.handleRPC(rpc, from: peer)
+
+        #expect(router.peerState.getPeer(peer)?.subscriptions.isEmpty == true)
+    }
+
+    @Test("Local subscribe is rejected by the filter")
+    func localSubscribeRejected() throws {
+        var config = GossipSubConfiguration.testing
+        config.subscriptionFilter = WhitelistSubscriptionFilter(allowedTopics: ["blocks"])
+        let router = GossipSubRouter(localPeerID: makePeerID(), configuration: config)
+
+        #expect(throws: GossipSubError.self) {
+            _ = try router.subscribe(to: "spam")
+        }
+        _ = try router.subscribe(to: "blocks")
+    }
+}
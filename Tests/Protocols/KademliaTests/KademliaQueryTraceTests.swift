@@ -0,0 +1,170 @@
+/// KademliaQueryTraceTests - Per-hop events of traced Kademlia queries.
+///
+/// Covers:
+/// - Hops are reported in order with the returned and accepted peers
+/// - Failing peers and query timeouts are reported
+import Testing
+import Foundation
+import Synchronization
+@testable import P2PKademlia
+@testable import P2PCore
+
+@Suite("Kademlia Query Trace Tests")
+struct KademliaQueryTraceTests {
+
+    @Test("A traced lookup reports each hop and why it stopped")
+    func reportsHops() async throws {
+        let target = KeyPair.generateEd25519().peerID
+        let targetKey = KademliaKey(from: target)
+        let distance = { (peer: PeerID) in KademliaKey(from: peer).distance(to: targetKey) }
+
+        // far is the seed; near is closer to the target than far
+        let pair = [KeyPair.generateEd25519().peerID, KeyPair.generateEd25519().peerID]
+            .sorted { distance($0) < distance($1) }
+        let near = pair[0]
+        let far = pair[1]
+        let targetAddress = try Multiaddr("/ip4/127.0.0.1/tcp/4001")
+
+        let delegate = TopologyDelegate(responses: [
+            far: [KademliaPeer(id: near, addresses: []), KademliaPeer(id: target, addresses: [targetAddress])],
+            near: [KademliaPeer(id: target, addresses: [targetAddress])],
+            target: [],
+        ])
+        let events = Mutex<[KademliaQueryTraceEvent]>([])
+        let query = KademliaQuery(
+            type: .findNode(targetKey),
+            config: KademliaQueryConfig(alpha: 1),
+            trace: { event in events.withLock { $0.append(event) } }
+        )
+
+        _ = try await query.execute(initialPeers: [KademliaPeer(id: far, addresses: [])], delegate: delegate)
+
+        // The target (distance 0) is contacted before near, after which no
+        // closer peer is left and the lookup converges without asking near
+        let trace = events.withLock { $0 }
+        try #require(trace.count == 5)
+
+        guard case .queried(let first) = trace[0] else {
+            Issue.record("Expected queried, got \(trace[0])")
+            return
+        }
+        #expect(first == KademliaQueryHop(path: 0, round: 0, peer: far, distance: distance(far)))
+
+        guard case .responded(let hop, let closerPeers, let accepted, _) = trace[1] else {
+            Issue.record("Expected responded, got \(trace[1])")
+            return
+        }
+        #expect(hop.peer == far)
+        #expect(closerPeers.map(\.id) == [near, target])
+        #expect(accepted == 2)
+
+        guard case .queried(let second) = trace[2] else {
+            Issue.record("Expected queried, got \(trace[2])")
+            return
+        }
+        #expect(second == KademliaQueryHop(path: 0, round: 1, peer: target, distance: distance(target)))
+        #expect(second.distance.leadingZeroBits == 256)
+
+        guard case .responded(let targetHop, _, 0, _) = trace[3] else {
+            Issue.record("Expected responded with nothing accepted, got \(trace[3])")
+            return
+        }
+        #expect(targetHop.peer == target)
+
+        guard case .finished(0, .converged, let rounds, let closest) = trace[4] else {
+            Issue.record("Expected converged, got \(trace[4])")
+            return
+        }
+        #expect(rounds == 2)
+        #expect(closest.map(\.id) == [target, far])
+        #expect(delegate.queried == [far, target])
+    }
+
+    @Test("A failing peer is reported with its error")
+    func reportsFailures() async throws {
+        let target = KademliaKey(from: KeyPair.generateEd25519().peerID)
+        let peer = KeyPair.generateEd25519().peerID
+        let delegate = TopologyDelegate(responses: [:])
+        let events = Mutex<[KademliaQueryTraceEvent]>([])
+        let query = KademliaQuery(
+            type: .findNode(target),
+            trace: { event in events.withLock { $0.append(event) } }
+        )
+
+        let result = try await query.execute(initialPeers: [KademliaPeer(id: peer, addresses: [])], delegate: delegate)
+        guard case .nodes(let peers) = result else {
+            Issue.record("Expected nodes result")
+            return
+        }
+        #expect(peers.isEmpty)
+
+        let trace = events.withLock { $0 }
+        try #require(trace.count == 3)
+        guard case .failed(let hop, let error, _) = trace[1] else {
+            Issue.record("Expected failed, got \(trace[1])")
+            return
+        }
+        #expect(hop.peer == peer)
+        #expect(error.contains("peerNotFound"))
+        guard case .finished(_, _, 1, let closest) = trace[2] else {
+            Issue.record("Expected finished after one round, got \(trace[2])")
+            return
+        }
+        #expect(closest.isEmpty)
+    }
+
+    @Test("A query timeout is reported")
+    func reportsTimeout() async throws {
+        let delegate = MockKademliaQueryDelegate()
+        delegate.responseDelay = .seconds(10)
+        let events = Mutex<[KademliaQueryTraceEvent]>([])
+        let query = KademliaQuery(
+            type: .findNode(KademliaKey(hashing: Data("slow".utf8))),
+            config: KademliaQueryConfig(timeout: .milliseconds(100)),
+            trace: { event in events.withLock { $0.append(event) } }
+        )
+
+        await #expect(throws: KademliaError.timeout) {
+            try await query.execute(
+                initialPeers: [KademliaPeer(id: KeyPair.generateEd25519().peerID, addresses: [])],
+                delegate: delegate
+            )
+        }
+
+        let trace = events.withLock { $0 }
+        guard case .queried = trace.first, case .timedOut = trace.last else {
+            Issue.record("Expected queried ... timedOut, got \(trace)")
+            return
+        }
+    }
+}
+
+/// Query delegate answering FIND_NODE from a fixed topology; peers missing
+/// from it fail with `peerNotFound`.
+private final class TopologyDelegate: KademliaQueryDelegate, Sendable {
+    private let responses: [PeerID: [KademliaPeer]]
+    private let log = Mutex<[PeerID]>([])
+
+    init(responses: [PeerID: [KademliaPeer]]) {
+        self.responses = responses
+    }
+
+    /// The peers asked, in order.
+    var queried: [PeerID] { log.withLock { $0 } }
+
+    func sendFindNode(to peer: PeerID, key: KademliaKey) async throws -> [KademliaPeer] {
+        log.withLock { $0.append(peer) }
+        guard let response = responses[peer] else {
+            throw KademliaError.peerNotFound(peer)
+        }
+        return response
+    }
+
+    func sendGetValue(to peer: PeerID, key: Data) async throws -> (record: KademliaRecord?, closerPeers: [KademliaPeer]) {
+        (nil, [])
+    }
+
+    func sendGetProviders(to peer: PeerID, key: Data) async throws -> (providers: [KademliaPeer], closerPeers: [KademliaPeer]) {
+        ([], [])
+    }
+}
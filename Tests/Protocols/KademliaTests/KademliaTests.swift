@@ -4,6 +4,8 @@ import Testing
 import Foundation
 @testable import P2PKademlia
 @testable import P2PCore
+import P2PMux
+import P2PProtocols
 
 @Suite("Kademlia Tests")
 struct KademliaTests {
@@ -278,6 +280,26 @@ struct KademliaTests {
             #expect(bucket.entry(for: peer3) != nil)
         }
 
+        @Test("Replace only removes from a full bucket with a pending entry")
+        func replaceNeedsPending() throws {
+            var bucket = KBucket(maxSize: 1)
+
+            let peer1 = KeyPair.generateEd25519().peerID
+            let peer2 = KeyPair.generateEd25519().peerID
+
+            _ = bucket.insert(peer1)
+            #expect(bucket.replace(peer1) == nil)
+            #expect(bucket.entry(for: peer1) != nil)
+
+            _ = bucket.insert(peer2)  // Goes to pending
+            #expect(bucket.replace(peer2) == nil)
+
+            let replaced = try #require(bucket.replace(peer1))
+            #expect(replaced.removed.peerID == peer1)
+            #expect(replaced.replacement.peerID == peer2)
+            #expect(bucket.allEntries.map(\.peerID) == [peer2])
+        }
+
         @Test("Entries sorted by distance")
         func entriesSortedByDistance() throws {
             var bucket = KBucket(maxSize: 10)
@@ -728,6 +750,39 @@ struct KademliaTests {
             #expect(service.protocolIDs.contains(KademliaProtocol.protocolID))
         }
 
+        @Test("Protocol prefix sets the advertised protocol ID")
+        func protocolPrefix() throws {
+            let localPeer = KeyPair.generateEd25519().peerID
+
+            #expect(KademliaProtocol.protocolID == "/ipfs/kad/1.0.0")
+            #expect(KademliaConfiguration.default.protocolID == KademliaProtocol.protocolID)
+
+            let service = KademliaService(
+                localPeerID: localPeer,
+                configuration: KademliaConfiguration(protocolPrefix: "/lan")
+            )
+            #expect(service.protocolIDs == ["/lan/kad/1.0.0"])
+        }
+
+        @Test("Public address filter needs one public IP address")
+        func publicAddressFilter() throws {
+            let peer = KeyPair.generateEd25519().peerID
+            let filter = RouteTableFilters.publicAddresses
+
+            #expect(!filter(peer, []))
+            #expect(!filter(peer, [
+                try Multiaddr("/ip4/127.0.0.1/tcp/4001"),
+                try Multiaddr("/ip4/192.168.1.10/tcp/4001"),
+                try Multiaddr("/ip4/169.254.0.1/tcp/4001"),
+                try Multiaddr("/ip6/fd00::1/tcp/4001"),
+                try Multiaddr("/dns4/example.com/tcp/4001"),
+            ]))
+            #expect(filter(peer, [
+                try Multiaddr("/ip4/10.0.0.1/tcp/4001"),
+                try Multiaddr("/ip4/8.8.8.8/tcp/4001"),
+            ]))
+        }
+
         @Test("Add peer to service routing table")
         func addPeerToService() throws {
             let localKeyPair = KeyPair.generateEd25519()
@@ -777,6 +832,27 @@ struct KademliaTests {
             service.setMode(.client)
             #expect(service.mode == .client)
         }
+
+        @Test("CID forms of one multihash share provider records")
+        func cidFormsShareProviderKey() async throws {
+            let service = KademliaService(localPeerID: KeyPair.generateEd25519().peerID)
+
+            // go-libp2p-kad-dht keys providers by the CID's multihash
+            let v0 = try CID(string: "QmVb1pRS1pRRwjY5xNozxg87RszPf9D93oy39a4Y2zvHQf")
+            let v1 = try CID(string: "bafybeidlv7hmlrxzvkaptkslgevne6upuro2wbtmjgua7ewoi63kga52kq")
+            let raw = try CID(cidOrMultihash: "12206bafcec5c6f9aa80f9aa4b312ad27a8fa45dab066c49a80f92ce47b6a303ba54")
+            #expect(v0.routingKey == v1.routingKey)
+            #expect(v1.routingKey == raw.routingKey)
+
+            // With no peers the announcement fails, but the local record stays
+            await #expect(throws: KademliaError.noPeersAvailable) {
+                try await service.provide(v0, addresses: [], using: UnusedStreamOpener())
+            }
+            for cid in [v0, v1, raw] {
+                let providers = try await service.getProviders(for: cid, using: UnusedStreamOpener())
+                #expect(providers.map(\.id) == [service.localPeerID])
+            }
+        }
     }
 
     // MARK: - Protocol Input Validation Tests
@@ -903,6 +979,15 @@ struct KademliaTests {
     }
 }
 
+// MARK: - Unused Opener
+
+/// Stream opener for calls that must be answered locally.
+private struct UnusedStreamOpener: StreamOpener {
+    func newStream(to peer: PeerID, protocol protocolID: String) async throws -> MuxedStream {
+        throw KademliaError.noPeersAvailable
+    }
+}
+
 // MARK: - Mock Delegate
 
 import Synchronization
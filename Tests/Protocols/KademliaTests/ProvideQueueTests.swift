@@ -0,0 +1,278 @@
+/// ProvideQueueTests - Concurrency, rate limiting, dedupe and retry of the provide queue.
+
+import Testing
+import Foundation
+import Synchronization
+@testable import P2PKademlia
+@testable import P2PCore
+
+@Suite("ProvideQueue Tests")
+struct ProvideQueueTests {
+
+    /// Records the provider calls the queue makes.
+    private final class Recorder: Sendable {
+        private struct State {
+            var calls: [Data] = []
+            var starts: [ContinuousClock.Instant] = []
+            var running = 0
+            var peakRunning = 0
+        }
+
+        private let state = Mutex(State())
+
+        var calls: [Data] { state.withLock { $0.calls } }
+        var starts: [ContinuousClock.Instant] { state.withLock { $0.starts } }
+        var peakRunning: Int { state.withLock { $0.peakRunning } }
+
+        func begin(_ key: Data) {
+            state.withLock { s in
+                s.calls.append(key)
+                s.starts.append(.now)
+                s.running += 1
+                s.peakRunning = max(s.peakRunning, s.running)
+            }
+        }
+
+        func end() {
+            state.withLock { $0.running -= 1 }
+        }
+    }
+
+    private struct Flaky: Error {}
+
+    private static func key(_ i: Int) -> Data {
+        Data("key-\(i)".utf8)
+    }
+
+    /// Polls until the queue is empty and nothing is in flight.
+    private static func drain(_ queue: ProvideQueue) async throws {
+        for _ in 0..<200 {
+            if queue.depth == 0 && queue.inFlightCount == 0 {
+                return
+            }
+            try await Task.sleep(for: .milliseconds(25))
+        }
+        Issue.record("queue did not drain: depth=\(queue.depth) inFlight=\(queue.inFlightCount)")
+    }
+
+    @Test("Announces each queued key once and records when", .timeLimit(.minutes(1)))
+    func announcesQueuedKeys() async throws {
+        let queue = ProvideQueue(configuration: .init(maxPerSecond: nil))
+        let recorder = Recorder()
+        for i in 0..<5 {
+            #expect(queue.enqueue(key: Self.key(i), addresses: []))
+        }
+        #expect(queue.depth == 5)
+        #expect(queue.status(for: Self.key(0))?.state == .pending)
+        #expect(queue.status(for: Self.key(99)) == nil)
+
+        let before = Date()
+        queue.start { key, _ in
+            recorder.begin(key)
+            recorder.end()
+        }
+        defer { queue.stop() }
+        try await Self.drain(queue)
+
+        #expect(recorder.calls == (0..<5).map(Self.key))
+        for i in 0..<5 {
+            let status = try #require(queue.status(for: Self.key(i)))
+            #expect(status.state == .idle)
+            #expect(status.failures == 0)
+            let provided = try #require(queue.lastProvided(for: Self.key(i)))
+            #expect(provided >= before)
+        }
+    }
+
+    @Test("A key already pending or in flight is not queued again", .timeLimit(.minutes(1)))
+    func dedupesKeys() async throws {
+        let queue = ProvideQueue(configuration: .init(maxPerSecond: nil))
+        let recorder = Recorder()
+        let release = AsyncStream.makeStream(of: Void.self)
+
+        #expect(queue.enqueue(key: Self.key(0), addresses: []))
+        #expect(!queue.enqueue(key: Self.key(0), addresses: []))
+        #expect(queue.depth == 1)
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            for await _ in release.stream { break }
+            recorder.end()
+        }
+        defer { queue.stop() }
+
+        for _ in 0..<100 where queue.inFlightCount == 0 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        #expect(queue.status(for: Self.key(0))?.state == .inFlight)
+        #expect(!queue.enqueue(key: Self.key(0), addresses: []))
+        #expect(queue.depth == 0)
+
+        release.continuation.yield()
+        try await Self.drain(queue)
+        #expect(recorder.calls == [Self.key(0)])
+
+        // Once announced, the key can be queued again
+        #expect(queue.enqueue(key: Self.key(0), addresses: []))
+    }
+
+    @Test("No more than maxConcurrent announcements run at once", .timeLimit(.minutes(1)))
+    func capsConcurrency() async throws {
+        let queue = ProvideQueue(configuration: .init(maxConcurrent: 2, maxPerSecond: nil))
+        let recorder = Recorder()
+        for i in 0..<8 {
+            queue.enqueue(key: Self.key(i), addresses: [])
+        }
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            try? await Task.sleep(for: .milliseconds(50))
+            recorder.end()
+        }
+        defer { queue.stop() }
+        try await Self.drain(queue)
+
+        #expect(recorder.calls.count == 8)
+        #expect(recorder.peakRunning == 2)
+    }
+
+    @Test("Starts are spaced by maxPerSecond", .timeLimit(.minutes(1)))
+    func limitsRate() async throws {
+        let queue = ProvideQueue(configuration: .init(maxConcurrent: 8, maxPerSecond: 10))
+        let recorder = Recorder()
+        for i in 0..<5 {
+            queue.enqueue(key: Self.key(i), addresses: [])
+        }
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            recorder.end()
+        }
+        defer { queue.stop() }
+        try await Self.drain(queue)
+
+        let starts = recorder.starts
+        #expect(starts.count == 5)
+        for (earlier, later) in zip(starts, starts.dropFirst()) {
+            #expect(later - earlier >= .milliseconds(95))
+        }
+    }
+
+    @Test("A failed announcement is retried after a backoff", .timeLimit(.minutes(1)))
+    func retriesWithBackoff() async throws {
+        let queue = ProvideQueue(configuration: .init(
+            maxPerSecond: nil,
+            initialBackoff: .milliseconds(100),
+            maxBackoff: .seconds(1)
+        ))
+        let recorder = Recorder()
+        queue.enqueue(key: Self.key(0), addresses: [])
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            recorder.end()
+            if recorder.calls.count < 3 {
+                throw Flaky()
+            }
+        }
+        defer { queue.stop() }
+
+        for _ in 0..<100 where queue.status(for: Self.key(0))?.failures == 0 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        let failed = try #require(queue.status(for: Self.key(0)))
+        #expect(failed.failures == 1)
+        #expect(failed.lastError != nil)
+        #expect(queue.lastProvided(for: Self.key(0)) == nil)
+
+        try await Self.drain(queue)
+        let starts = recorder.starts
+        #expect(starts.count == 3)
+        // 100ms after the first failure, 200ms after the second
+        #expect(starts[1] - starts[0] >= .milliseconds(95))
+        #expect(starts[2] - starts[1] >= .milliseconds(195))
+
+        let status = try #require(queue.status(for: Self.key(0)))
+        #expect(status.state == .idle)
+        #expect(status.failures == 0)
+        #expect(status.lastError == nil)
+        #expect(queue.lastProvided(for: Self.key(0)) != nil)
+    }
+
+    @Test("A key is dropped after maxAttempts failures", .timeLimit(.minutes(1)))
+    func givesUpAfterMaxAttempts() async throws {
+        let queue = ProvideQueue(configuration: .init(
+            maxPerSecond: nil,
+            maxAttempts: 3,
+            initialBackoff: .milliseconds(10),
+            maxBackoff: .milliseconds(10)
+        ))
+        let recorder = Recorder()
+        queue.enqueue(key: Self.key(0), addresses: [])
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            recorder.end()
+            throw Flaky()
+        }
+        defer { queue.stop() }
+        try await Self.drain(queue)
+
+        #expect(recorder.calls.count == 3)
+        let status = try #require(queue.status(for: Self.key(0)))
+        #expect(status.state == .idle)
+        #expect(status.failures == 3)
+        #expect(queue.lastProvided(for: Self.key(0)) == nil)
+    }
+
+    @Test("Stopping puts the announcement in flight back at the front", .timeLimit(.minutes(1)))
+    func stopRequeuesInFlight() async throws {
+        let queue = ProvideQueue(configuration: .init(maxConcurrent: 1, maxPerSecond: nil))
+        let recorder = Recorder()
+        queue.enqueue(key: Self.key(0), addresses: [])
+        queue.enqueue(key: Self.key(1), addresses: [])
+
+        queue.start { key, _ in
+            recorder.begin(key)
+            defer { recorder.end() }
+            try await Task.sleep(for: .seconds(30))
+        }
+        for _ in 0..<100 where queue.inFlightCount == 0 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+        queue.stop()
+        for _ in 0..<100 where queue.inFlightCount > 0 {
+            try await Task.sleep(for: .milliseconds(10))
+        }
+
+        #expect(queue.depth == 2)
+        let status = try #require(queue.status(for: Self.key(0)))
+        #expect(status.state == .pending)
+        #expect(status.failures == 0)
+
+        let resumed = Recorder()
+        queue.start { key, _ in
+            resumed.begin(key)
+            resumed.end()
+        }
+        defer { queue.stop() }
+        try await Self.drain(queue)
+        #expect(resumed.calls == [Self.key(0), Self.key(1)])
+    }
+
+    @Test("enqueueProvide stores the local provider and queues the key")
+    func serviceEnqueueProvide() throws {
+        let peer = PeerID(publicKey: KeyPair.generateEd25519().publicKey)
+        let service = KademliaService(
+            localPeerID: peer,
+            configuration: KademliaConfiguration(provideQueue: .init(maxConcurrent: 2, maxPerSecond: 1))
+        )
+        let address = try Multiaddr("/ip4/127.0.0.1/tcp/4001")
+
+        #expect(service.enqueueProvide(key: Self.key(0), addresses: [address]))
+        #expect(!service.enqueueProvide(key: Self.key(0), addresses: [address]))
+        #expect(service.provideQueue.depth == 1)
+        #expect(service.provideQueue.configuration.maxConcurrent == 2)
+        #expect(service.providerStore.getProviders(for: Self.key(0)).map(\.peerID) == [peer])
+    }
+}
@@ -300,6 +300,50 @@ struct NoiseHandshakeTests {
         }
     }
 
+    @Test("Message B failures name the phase and the cause")
+    func testMessageBFailurePhase() throws {
+        var initiator = NoiseHandshake(localKeyPair: .generateEd25519(), isInitiator: true)
+        var responder = NoiseHandshake(localKeyPair: .generateEd25519(), isInitiator: false)
+
+        try responder.readMessageA(try initiator.writeMessageA())
+        var messageB = try responder.writeMessageB()
+        messageB[50] ^= 0xFF
+
+        var tamperedInitiator = initiator
+        do {
+            _ = try tamperedInitiator.readMessageB(messageB)
+            Issue.record("Tampered Message B was accepted")
+        } catch NoiseError.decryptFailed(let phase) {
+            #expect(phase == .messageB)
+        }
+
+        do {
+            _ = try initiator.readMessageB(messageB.prefix(40))
+            Issue.record("Truncated Message B was accepted")
+        } catch NoiseError.unexpectedMessage(let phase, let reason) {
+            #expect(phase == .messageB)
+            #expect(reason == "message too short")
+        }
+    }
+
+    @Test("A payload in Message A is rejected as invalid early data")
+    func testMessageAEarlyData() throws {
+        var initiator = NoiseHandshake(localKeyPair: .generateEd25519(), isInitiator: true)
+        var responder = NoiseHandshake(localKeyPair: .generateEd25519(), isInitiator: false)
+
+        let messageA = try initiator.writeMessageA() + Data("hello".utf8)
+        do {
+            try responder.readMessageA(messageA)
+            Issue.record("Message A with a payload was accepted")
+        } catch let error as NoiseError {
+            guard case .earlyDataInvalid(.messageA, _) = error else {
+                Issue.record("Expected earlyDataInvalid, got \(error)")
+                return
+            }
+            #expect(error.handshakePhase == .messageA)
+        }
+    }
+
     @Test("Handshake fails with tampered Message B")
     func testHandshakeTamperedMessageB() throws {
         let initiatorKeyPair = KeyPair.generateEd25519()
@@ -56,6 +56,46 @@ struct NoiseIntegrationTests {
         #expect(upgrader.protocolID == "/noise")
     }
 
+    @Test("Wire tracer sees each handshake message as both sides put it on the wire", .timeLimit(.minutes(1)))
+    func testNoiseUpgraderWireTracer() async throws {
+        let initiatorTrace = WireTraceRecorder()
+        let responderTrace = WireTraceRecorder()
+
+        let (clientConn, serverConn) = MockPipe.create()
+        let initiator = NoiseUpgrader(wireTracer: initiatorTrace.tracer)
+        let responder = NoiseUpgrader(wireTracer: responderTrace.tracer)
+
+        async let initiatorResult = initiator.secure(
+            clientConn,
+            localKeyPair: KeyPair.generateEd25519(),
+            as: .initiator,
+            expectedPeer: nil
+        )
+        async let responderResult = responder.secure(
+            serverConn,
+            localKeyPair: KeyPair.generateEd25519(),
+            as: .responder,
+            expectedPeer: nil
+        )
+        let (initiatorSecured, responderSecured) = try await (initiatorResult, responderResult)
+
+        let sent = initiatorTrace.entries
+        let received = responderTrace.entries
+        #expect(sent.map(\.phase) == [.noiseMessageA, .noiseMessageB, .noiseMessageC])
+        #expect(sent.map(\.direction) == [.outbound, .inbound, .outbound])
+        #expect(received.map(\.direction) == [.inbound, .outbound, .inbound])
+        #expect(sent.map(\.bytes) == received.map(\.bytes))
+
+        // Message A is the 2-byte length prefix and a 32-byte ephemeral key
+        // (plus the empty payload, which has no tag before a key is set)
+        let messageA = try #require(sent.first?.bytes)
+        #expect(messageA.count == 34)
+        #expect(messageA.prefix(2) == [0, 32])
+
+        try await initiatorSecured.close()
+        try await responderSecured.close()
+    }
+
     // MARK: - NoiseConnection Tests
 
     @Test("NoiseConnection read and write roundtrip", .timeLimit(.minutes(1)))
@@ -238,7 +278,14 @@ struct NoiseIntegrationTests {
                 )
                 Issue.record("Expected peer mismatch error")
             } catch {
-                // Expected - close connection to unblock responder
+                if case NoiseError.identityMismatch(let phase, let expected, let actual) = error {
+                    #expect(phase == .messageB)
+                    #expect(expected == wrongPeerKeyPair.peerID)
+                    #expect(actual == responderKeyPair.peerID)
+                } else {
+                    Issue.record("Expected identityMismatch, got \(error)")
+                }
+                // Close connection to unblock responder
                 do {
                     try await clientConn.close()
                 } catch {
@@ -295,6 +342,27 @@ struct NoiseIntegrationTests {
 
 // MARK: - Mock Implementations
 
+/// Collects what a `WireTracer` is handed.
+final class WireTraceRecorder: Sendable {
+    struct Entry: Sendable {
+        let direction: WireDirection
+        let phase: WirePhase
+        let bytes: [UInt8]
+    }
+
+    private let storage = Mutex<[Entry]>([])
+
+    var entries: [Entry] {
+        storage.withLock { $0 }
+    }
+
+    var tracer: WireTracer {
+        WireTracer { direction, phase, bytes in
+            self.storage.withLock { $0.append(Entry(direction: direction, phase: phase, bytes: bytes)) }
+        }
+    }
+}
+
 /// A mock raw connection for testing.
 /// Uses direct peer reference instead of closures for simplicity and thread safety.
 final class MockRawConnection: RawConnection, Sendable {
@@ -255,6 +255,40 @@ struct MemoryTransportTests {
         }
     }
 
+    // MARK: - Address Allocation Tests
+
+    @Test("Listening on /memory/0 picks distinct unused addresses")
+    func testAllocatedAddresses() async throws {
+        let hub = MemoryHub()
+        let transport = MemoryTransport(hub: hub)
+
+        // An explicitly registered numeric address is never handed out
+        let taken = try await transport.listen(.memory(id: "1"))
+        let first = try await transport.listen(.memory(id: "0"))
+        let second = try await transport.listen(.memory(id: "0"))
+
+        let ids = [first, second].compactMap { $0.localAddress.memoryID }
+        #expect(ids.count == 2)
+        #expect(Set(ids).count == 2)
+        #expect(!ids.contains("0"))
+        #expect(!ids.contains(taken.localAddress.memoryID ?? ""))
+
+        async let acceptTask = second.accept()
+        let clientConn = try await transport.dial(second.localAddress)
+        let serverConn = try await acceptTask
+
+        let message = Data("allocated".utf8)
+        try await clientConn.write(ByteBuffer(bytes: message))
+        let received = try await serverConn.read()
+        #expect(Data(buffer: received) == message)
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await taken.close()
+        try await first.close()
+        try await second.close()
+    }
+
     // MARK: - canDial/canListen Tests
 
     @Test("canDial returns true for memory addresses")
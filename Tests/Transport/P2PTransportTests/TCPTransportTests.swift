@@ -379,6 +379,34 @@ struct TCPTransportTests {
         try await listener.close()
     }
 
+    @Test("IPv6 wildcard listener also accepts IPv4", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testDualStackListener() async throws {
+        let transport = TCPTransport()
+
+        let listener = try await transport.listen(.tcp(host: "::", port: 0))
+        let port = try #require(listener.localAddress.tcpPort)
+        #expect(listener.localAddress.ipAddress == "::")
+        #expect(listener.localAddresses.map(\.ipAddress) == ["::", "0.0.0.0"])
+        #expect(listener.localAddresses.allSatisfy { $0.tcpPort == port })
+
+        for host in ["127.0.0.1", "::1"] {
+            async let acceptTask = listener.accept()
+            let clientConn = try await transport.dial(.tcp(host: host, port: port))
+            let serverConn = try await acceptTask
+
+            // IPv4 peers are reported as /ip4, not as v4-mapped /ip6
+            #expect(serverConn.remoteAddress.ipAddress == host)
+
+            try await clientConn.write(ByteBuffer(string: host))
+            #expect(String(buffer: try await serverConn.read()) == host)
+
+            try await clientConn.close()
+            try await serverConn.close()
+        }
+
+        try await listener.close()
+    }
+
     // MARK: - EventLoopGroup Lifecycle Tests
 
     @Test("Transport with external EventLoopGroup", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
@@ -409,6 +437,102 @@ struct TCPTransportTests {
         try await group.shutdownGracefully()
     }
 
+    // MARK: - Port Reuse Tests
+
+    @Test("Dial uses the listen port as source port", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testDialReusesListenPort() async throws {
+        let dialer = TCPTransport()
+        let remote = TCPTransport()
+
+        let dialerListener = try await dialer.listen(.tcp(host: "127.0.0.1", port: 0))
+        let remoteListener = try await remote.listen(.tcp(host: "127.0.0.1", port: 0))
+        let listenPort = try #require(dialerListener.localAddress.tcpPort)
+
+        async let acceptTask = remoteListener.accept()
+        let clientConn = try await dialer.dial(remoteListener.localAddress)
+        let serverConn = try await acceptTask
+
+        #expect(clientConn.localAddress?.tcpPort == listenPort)
+        #expect(serverConn.remoteAddress.tcpPort == listenPort)
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await dialerListener.close()
+        try await remoteListener.close()
+    }
+
+    @Test("Second dial to the same address falls back to an ephemeral port", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testPortReuseFallback() async throws {
+        let dialer = TCPTransport()
+        let remote = TCPTransport()
+
+        let dialerListener = try await dialer.listen(.tcp(host: "127.0.0.1", port: 0))
+        let remoteListener = try await remote.listen(.tcp(host: "127.0.0.1", port: 0))
+        let listenPort = try #require(dialerListener.localAddress.tcpPort)
+
+        async let firstAccept = remoteListener.accept()
+        let first = try await dialer.dial(remoteListener.localAddress)
+        let firstServer = try await firstAccept
+
+        // Same 4-tuple is taken by the first connection
+        async let secondAccept = remoteListener.accept()
+        let second = try await dialer.dial(remoteListener.localAddress)
+        let secondServer = try await secondAccept
+
+        #expect(first.localAddress?.tcpPort == listenPort)
+        #expect(second.localAddress?.tcpPort != listenPort)
+
+        try await second.write(ByteBuffer(string: "ping"))
+        #expect(String(buffer: try await secondServer.read()) == "ping")
+
+        for conn in [first, firstServer, second, secondServer] {
+            try await conn.close()
+        }
+        try await dialerListener.close()
+        try await remoteListener.close()
+    }
+
+    @Test("Port reuse disabled dials from an ephemeral port", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testPortReuseDisabled() async throws {
+        let dialer = TCPTransport(portReuse: false)
+        let remote = TCPTransport()
+
+        let dialerListener = try await dialer.listen(.tcp(host: "127.0.0.1", port: 0))
+        let remoteListener = try await remote.listen(.tcp(host: "127.0.0.1", port: 0))
+
+        async let acceptTask = remoteListener.accept()
+        let clientConn = try await dialer.dial(remoteListener.localAddress)
+        let serverConn = try await acceptTask
+
+        #expect(serverConn.remoteAddress.tcpPort != dialerListener.localAddress.tcpPort)
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await dialerListener.close()
+        try await remoteListener.close()
+    }
+
+    @Test("Closed listener's port is not reused", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testClosedListenerPortNotReused() async throws {
+        let dialer = TCPTransport()
+        let remote = TCPTransport()
+
+        let dialerListener = try await dialer.listen(.tcp(host: "127.0.0.1", port: 0))
+        let listenPort = dialerListener.localAddress.tcpPort
+        try await dialerListener.close()
+        let remoteListener = try await remote.listen(.tcp(host: "127.0.0.1", port: 0))
+
+        async let acceptTask = remoteListener.accept()
+        let clientConn = try await dialer.dial(remoteListener.localAddress)
+        let serverConn = try await acceptTask
+
+        #expect(serverConn.remoteAddress.tcpPort != listenPort)
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await remoteListener.close()
+    }
+
     // MARK: - Multiaddr Tests
 
     @Test("TCP Multiaddr parsing")
@@ -908,6 +1032,90 @@ struct TCPTransportTests {
         }
     }
 
+    // MARK: - Socket Options Tests
+
+    @Test("Default socket options match go-libp2p")
+    func testDefaultSocketOptions() {
+        let configuration = TCPTransportConfiguration()
+        let noDelay = configuration.socketOptions.first { $0.0.level == .tcp && $0.0.name == .tcp_nodelay }
+        let keepAlive = configuration.socketOptions.first { $0.0.level == .socket && $0.0.name == .so_keepalive }
+        #expect(noDelay?.1 == 1)
+        #expect(keepAlive?.1 == 1)
+        #expect(configuration.connectTimeAmount == .seconds(10))
+        #if !os(Windows)
+        // Keepalive idle time and probe interval, both 30s
+        #expect(configuration.socketOptions.filter { $0.0.level == .tcp && $0.1 == 30 }.count == 2)
+        #endif
+    }
+
+    @Test("Disabled options are cleared rather than omitted")
+    func testDisabledSocketOptions() {
+        let configuration = TCPTransportConfiguration(noDelay: false, keepAliveInterval: nil)
+        let noDelay = configuration.socketOptions.first { $0.0.level == .tcp && $0.0.name == .tcp_nodelay }
+        let keepAlive = configuration.socketOptions.first { $0.0.level == .socket && $0.0.name == .so_keepalive }
+        #expect(noDelay?.1 == 0)
+        #expect(keepAlive?.1 == 0)
+        #expect(configuration.socketOptions.count == 3)
+    }
+
+    @Test("Small split writes round-trip without a Nagle stall", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testSmallWritesWithoutNagleStall() async throws {
+        let transport = TCPTransport(configuration: TCPTransportConfiguration(noDelay: true))
+        let listener = try await transport.listen(.tcp(host: "127.0.0.1", port: 0))
+
+        async let acceptTask = listener.accept()
+        let clientConn = try await transport.dial(listener.localAddress)
+        let serverConn = try await acceptTask
+
+        // Each request is a 1-byte header and a 15-byte body in separate
+        // writes. With Nagle on, the body waits for the header's ACK, which
+        // the server delays (~40ms) because it has nothing to send yet.
+        let rounds = 20
+        let echo = Task {
+            for _ in 0..<rounds {
+                var received = 0
+                while received < 16 {
+                    received += try await serverConn.read().readableBytes
+                }
+                try await serverConn.write(ByteBuffer(bytes: [0x01]))
+            }
+        }
+
+        let clock = ContinuousClock()
+        let elapsed = try await clock.measure {
+            for _ in 0..<rounds {
+                try await clientConn.write(ByteBuffer(bytes: [0x10]))
+                try await clientConn.write(ByteBuffer(repeating: 0xAB, count: 15))
+                let reply = try await clientConn.read()
+                #expect(reply.readableBytes == 1)
+            }
+        }
+        try await echo.value
+
+        // 20 stalls would take ~800ms
+        #expect(elapsed < .milliseconds(20 * rounds / 2))
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await listener.close()
+    }
+
+    @Test("Connect timeout bounds an unanswered dial", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testConnectTimeout() async throws {
+        let transport = TCPTransport(
+            portReuse: false,
+            configuration: TCPTransportConfiguration(connectTimeout: .milliseconds(200))
+        )
+
+        // TEST-NET-1 (RFC 5737) is not routed, so the SYN goes unanswered
+        let clock = ContinuousClock()
+        let start = clock.now
+        await #expect(throws: (any Error).self) {
+            _ = try await transport.dial(.tcp(host: "192.0.2.1", port: 4001))
+        }
+        #expect(clock.now - start < .seconds(5))
+    }
+
     // MARK: - Other Edge Cases
 
     @Test("Close on already-inactive channel does not throw", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
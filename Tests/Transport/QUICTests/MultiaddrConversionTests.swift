@@ -152,6 +152,16 @@ struct MultiaddrConversionTests {
 
     // MARK: - Round-trip Tests
 
+    @Test("IPv4-mapped SocketAddress converts to an ip4 multiaddr")
+    func convertMappedIPv4SocketToMultiaddr() throws {
+        let socketAddr = QUIC.SocketAddress(ipAddress: "::ffff:192.0.2.7", port: 4433)
+        let multiaddr = socketAddr.toQUICMultiaddr()
+
+        #expect(multiaddr.protocols.first?.name == "ip4")
+        #expect(multiaddr.ipAddress == "192.0.2.7")
+        #expect(multiaddr.udpPort == 4433)
+    }
+
     @Test("IPv4 round-trip conversion preserves address")
     func ipv4RoundTrip() throws {
         let original = try Multiaddr("/ip4/172.16.0.1/udp/12345/quic-v1")
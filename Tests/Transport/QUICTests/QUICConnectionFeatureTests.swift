@@ -0,0 +1,268 @@
+/// QUICConnectionFeatureTests - Datagrams and stream priority on QUICConnection
+///
+/// `QUICMuxedConnection` forwards datagrams and priorities to engine
+/// connections/streams that adopt `QUICDatagramCapable` /
+/// `QUICPrioritizableStream`, and reports not-supported otherwise.
+import Testing
+import Foundation
+import NIOCore
+import Synchronization
+@testable import P2PTransportQUIC
+@testable import P2PTransport
+@testable import P2PCore
+@testable import P2PMux
+import QUIC
+
+@Suite("QUIC Connection Feature Tests")
+struct QUICConnectionFeatureTests {
+
+    @Test("QUIC connections are reachable through a MuxedConnection type-assert")
+    func typeAssertFromMuxedConnection() throws {
+        let connection: any MuxedConnection = try makeConnection(BasicQUICConnection())
+        #expect(connection is any QUICConnection)
+    }
+
+    @Test("Datagrams are sent and received when the engine supports them", .timeLimit(.minutes(1)))
+    func datagramRoundTrip() async throws {
+        let engine = DatagramQUICConnection(maxPayloadSize: 1200)
+        let muxed = try makeConnection(engine)
+        #expect(muxed.maxDatagramPayloadSize == 1200)
+
+        try await muxed.sendDatagram(ByteBuffer(bytes: [1, 2, 3]))
+        #expect(engine.sent.withLock { $0 } == [Data([1, 2, 3])])
+
+        engine.deliver(Data([9, 8]))
+        engine.finishIncoming()
+        var received: [ByteBuffer] = []
+        for await datagram in muxed.incomingDatagrams {
+            received.append(datagram)
+        }
+        #expect(received == [ByteBuffer(bytes: [9, 8])])
+    }
+
+    @Test("A datagram larger than the peer's maximum is rejected")
+    func oversizedDatagram() async throws {
+        let engine = DatagramQUICConnection(maxPayloadSize: 4)
+        let muxed = try makeConnection(engine)
+
+        await #expect(throws: QUICTransportError.self) {
+            try await muxed.sendDatagram(ByteBuffer(bytes: [UInt8](repeating: 0, count: 5)))
+        }
+        #expect(engine.sent.withLock { $0 }.isEmpty)
+    }
+
+    @Test("Without engine support datagrams report not supported", .timeLimit(.minutes(1)))
+    func datagramsNotSupported() async throws {
+        let muxed = try makeConnection(BasicQUICConnection())
+        #expect(muxed.maxDatagramPayloadSize == nil)
+
+        do {
+            try await muxed.sendDatagram(ByteBuffer(bytes: [1]))
+            Issue.record("sendDatagram should throw")
+        } catch QUICTransportError.datagramsNotSupported {
+            // Expected
+        }
+        var count = 0
+        for await _ in muxed.incomingDatagrams {
+            count += 1
+        }
+        #expect(count == 0)
+    }
+
+    @Test("Priority reaches the engine stream through a wrapping stream")
+    func priorityThroughWrapper() async throws {
+        let engine = BasicQUICConnection(prioritizable: true)
+        let muxed = try makeConnection(engine)
+        let stream = try await muxed.newStream()
+
+        let priority = QUICStreamPriority(urgency: 1, incremental: true)
+        try await muxed.setPriority(priority, for: IDOnlyStream(id: stream.id))
+        let applied = try #require(engine.streams.withLock { $0.first })
+        #expect(applied.priorities.withLock { $0 } == [priority])
+    }
+
+    @Test("Priority fails for closed streams and engines without support")
+    func priorityErrors() async throws {
+        let plain = try makeConnection(BasicQUICConnection())
+        let stream = try await plain.newStream()
+        do {
+            try await plain.setPriority(.default, for: stream)
+            Issue.record("setPriority should throw")
+        } catch QUICTransportError.streamPriorityNotSupported {
+            // Expected
+        }
+
+        let prioritized = try makeConnection(BasicQUICConnection(prioritizable: true))
+        let closed = try await prioritized.newStream()
+        try await closed.close()
+        do {
+            try await prioritized.setPriority(.default, for: closed)
+            Issue.record("setPriority should throw")
+        } catch QUICTransportError.unknownStream(let id) {
+            #expect(id == closed.id)
+        }
+    }
+
+    @Test("Engines that can ping give the connection a QUIC PING probe")
+    func keepAliveProbe() async throws {
+        let plain = try makeConnection(BasicQUICConnection())
+        #expect(plain.keepAliveProbe == nil)
+
+        let pingable = try makeConnection(PingQUICConnection())
+        let probe = try #require(pingable.keepAliveProbe)
+        #expect(probe.mechanism == .quicPing)
+        let rtt = try await probe.probe()
+        #expect(rtt == .milliseconds(7))
+    }
+
+    @Test("Urgency is clamped to 7")
+    func urgencyClamped() {
+        #expect(QUICStreamPriority(urgency: 12).urgency == 7)
+        #expect(QUICStreamPriority.default == QUICStreamPriority(urgency: 3, incremental: false))
+    }
+
+    private func makeConnection(_ engine: any QUICConnectionProtocol) throws -> QUICMuxedConnection {
+        QUICMuxedConnection(
+            quicConnection: engine,
+            localPeer: KeyPair.generateEd25519().peerID,
+            remotePeer: KeyPair.generateEd25519().peerID,
+            localAddress: nil,
+            remoteAddress: try Multiaddr("/ip4/127.0.0.1/udp/4001/quic-v1")
+        )
+    }
+}
+
+// MARK: - Mocks
+
+/// A `QUICStreamProtocol` that records the priorities applied to it.
+private final class RecordingQUICStream: QUICStreamProtocol, Sendable {
+    let id: UInt64
+    let priorities = Mutex<[QUICStreamPriority]>([])
+    var isUnidirectional: Bool { false }
+    var isBidirectional: Bool { true }
+
+    init(id: UInt64) {
+        self.id = id
+    }
+
+    func read() async throws -> Data { Data() }
+    func read(maxBytes: Int) async throws -> Data { Data() }
+    func write(_ data: Data) async throws {}
+    func closeWrite() async throws {}
+    func reset(errorCode: UInt64) async {}
+    func stopSending(errorCode: UInt64) async throws {}
+}
+
+/// A stream whose engine schedules by priority.
+private final class PrioritizableQUICStream: QUICStreamProtocol, QUICPrioritizableStream, Sendable {
+    private let base: RecordingQUICStream
+    var id: UInt64 { base.id }
+    var isUnidirectional: Bool { false }
+    var isBidirectional: Bool { true }
+
+    init(base: RecordingQUICStream) {
+        self.base = base
+    }
+
+    func read() async throws -> Data { Data() }
+    func read(maxBytes: Int) async throws -> Data { Data() }
+    func write(_ data: Data) async throws {}
+    func closeWrite() async throws {}
+    func reset(errorCode: UInt64) async {}
+    func stopSending(errorCode: UInt64) async throws {}
+
+    func setPriority(_ priority: QUICStreamPriority) async throws {
+        base.priorities.withLock { $0.append(priority) }
+    }
+}
+
+/// A `QUICConnectionProtocol` without datagram support.
+private class BasicQUICConnection: QUICConnectionProtocol, @unchecked Sendable {
+    private let nextStreamID = Mutex<UInt64>(0)
+    private let prioritizable: Bool
+    let streams = Mutex<[RecordingQUICStream]>([])
+
+    init(prioritizable: Bool = false) {
+        self.prioritizable = prioritizable
+    }
+
+    var localAddress: QUIC.SocketAddress? { nil }
+    var remoteAddress: QUIC.SocketAddress { QUIC.SocketAddress(ipAddress: "127.0.0.1", port: 4001) }
+    var currentRemoteAddress: QUIC.SocketAddress { remoteAddress }
+    var isEstablished: Bool { true }
+
+    func openStream() async throws -> any QUICStreamProtocol {
+        let id = nextStreamID.withLock { current -> UInt64 in
+            let value = current
+            current += 4
+            return value
+        }
+        let stream = RecordingQUICStream(id: id)
+        streams.withLock { $0.append(stream) }
+        return prioritizable ? PrioritizableQUICStream(base: stream) : stream
+    }
+
+    func openUniStream() async throws -> any QUICStreamProtocol {
+        try await openStream()
+    }
+
+    var incomingStreams: AsyncStream<any QUICStreamProtocol> {
+        AsyncStream { $0.finish() }
+    }
+
+    func close(error: UInt64?) async {}
+    func close(applicationError errorCode: UInt64, reason: String) async {}
+}
+
+/// A connection whose engine negotiated datagrams.
+private final class DatagramQUICConnection: BasicQUICConnection, QUICDatagramCapable, @unchecked Sendable {
+    let maxDatagramPayloadSize: Int?
+    let sent = Mutex<[Data]>([])
+    let incomingDatagrams: AsyncStream<Data>
+    private let incoming: AsyncStream<Data>.Continuation
+
+    init(maxPayloadSize: Int) {
+        self.maxDatagramPayloadSize = maxPayloadSize
+        let (stream, continuation) = AsyncStream.makeStream(of: Data.self)
+        self.incomingDatagrams = stream
+        self.incoming = continuation
+        super.init()
+    }
+
+    func sendDatagram(_ data: Data) async throws {
+        sent.withLock { $0.append(data) }
+    }
+
+    func deliver(_ data: Data) {
+        incoming.yield(data)
+    }
+
+    func finishIncoming() {
+        incoming.finish()
+    }
+}
+
+/// A connection whose engine can send PING frames.
+private final class PingQUICConnection: BasicQUICConnection, QUICPingCapable, @unchecked Sendable {
+    func ping() async throws -> Duration {
+        .milliseconds(7)
+    }
+}
+
+/// A stream wrapper that only forwards the ID, like the node's tracking
+/// wrappers.
+private final class IDOnlyStream: MuxedStream, Sendable {
+    let id: UInt64
+    let protocolID: String? = nil
+
+    init(id: UInt64) {
+        self.id = id
+    }
+
+    func read() async throws -> ByteBuffer { ByteBuffer() }
+    func write(_ data: ByteBuffer) async throws {}
+    func closeWrite() async throws {}
+    func closeRead() async throws {}
+    func close() async throws {}
+    func reset() async throws {}
+}
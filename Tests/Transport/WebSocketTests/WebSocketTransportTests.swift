@@ -5,6 +5,7 @@ import NIOPosix
 import NIOEmbedded
 import NIOWebSocket
 import NIOSSL
+import Synchronization
 import P2PTestSupport
 @testable import P2PCore
 @testable import P2PTransport
@@ -493,6 +494,87 @@ struct WebSocketTransportTests {
         }
     }
 
+    @Test("Host header carries the hostname, with the port unless it is the scheme default")
+    func testHostHeader() {
+        #expect(WebSocketTransport.hostHeader(host: "relay.example", port: 443, isSecure: true) == "relay.example")
+        #expect(WebSocketTransport.hostHeader(host: "relay.example", port: 8443, isSecure: true) == "relay.example:8443")
+        #expect(WebSocketTransport.hostHeader(host: "relay.example", port: 80, isSecure: false) == "relay.example")
+        #expect(WebSocketTransport.hostHeader(host: "relay.example", port: 443, isSecure: false) == "relay.example:443")
+        #expect(WebSocketTransport.hostHeader(host: "::1", port: 4001, isSecure: false) == "[::1]:4001")
+    }
+
+    @Test("/dns resolves both address families, /dns4 and /dns6 one each")
+    func testConnectTargets() async throws {
+        let lookup = StubDNSLookup(
+            ipv4: ["relay.example": ["203.0.113.7"]],
+            ipv6: ["relay.example": ["2001:db8::7"]]
+        )
+        let transport = WebSocketTransport(tlsConfiguration: .init(), dnsLookup: lookup)
+
+        let dns = Multiaddr(uncheckedProtocols: [.dns("relay.example"), .tcp(443), .wss])
+        #expect(try await transport.connectTargets(for: dns, host: "relay.example") == ["2001:db8::7", "203.0.113.7"])
+        let dns4 = Multiaddr(uncheckedProtocols: [.dns4("relay.example"), .tcp(443), .wss])
+        #expect(try await transport.connectTargets(for: dns4, host: "relay.example") == ["203.0.113.7"])
+        let dns6 = Multiaddr(uncheckedProtocols: [.dns6("relay.example"), .tcp(443), .wss])
+        #expect(try await transport.connectTargets(for: dns6, host: "relay.example") == ["2001:db8::7"])
+
+        // IP literals are not looked up
+        #expect(try await transport.connectTargets(for: .ws(host: "127.0.0.1", port: 80), host: "127.0.0.1") == ["127.0.0.1"])
+        #expect(lookup.lookups.count == 4)
+    }
+
+    @Test("/dns still dials when only one family resolves, and fails when none does")
+    func testConnectTargetsPartialResolution() async throws {
+        let lookup = StubDNSLookup(ipv4: ["v4only.example": ["203.0.113.8"]])
+        let transport = WebSocketTransport(tlsConfiguration: .init(), dnsLookup: lookup)
+
+        let v4only = Multiaddr(uncheckedProtocols: [.dns("v4only.example"), .tcp(443), .wss])
+        #expect(try await transport.connectTargets(for: v4only, host: "v4only.example") == ["203.0.113.8"])
+
+        let missing = Multiaddr(uncheckedProtocols: [.dns("missing.example"), .tcp(443), .wss])
+        await #expect(throws: TransportError.self) {
+            _ = try await transport.connectTargets(for: missing, host: "missing.example")
+        }
+    }
+
+    @Test("Dialing /dns/<host>/tcp/443/wss looks the hostname up and connects to the result", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testDialDNSWSSResolvesHostname() async throws {
+        // Nothing listens on 127.0.0.1:443, so the dial fails at connect,
+        // after resolution and before TLS
+        let lookup = StubDNSLookup(ipv4: ["relay.example": ["127.0.0.1"]])
+        let transport = WebSocketTransport(tlsConfiguration: .init(), dnsLookup: lookup)
+        let address = Multiaddr(uncheckedProtocols: [.dns("relay.example"), .tcp(443), .wss])
+        #expect(transport.canDial(address))
+
+        await #expect(throws: (any Error).self) {
+            _ = try await transport.dial(address)
+        }
+        #expect(lookup.lookups.sorted() == ["relay.example/ipv4", "relay.example/ipv6"])
+    }
+
+    @Test("Dialing /dns/<host>/tcp/<port>/ws connects to the resolved address", .timeLimit(.minutes(1)), .enabled(if: liveNetworkTestsEnabled, "Set SWIFT_LIBP2P_ENABLE_LIVE_NETWORK_TESTS=1"))
+    func testDialDNSWSConnectsToResolvedAddress() async throws {
+        let server = WebSocketTransport()
+        let listener = try await server.listen(.ws(host: "127.0.0.1", port: 0))
+        let port = try #require(listener.localAddress.tcpPort)
+
+        let lookup = StubDNSLookup(ipv4: ["relay.example": ["127.0.0.1"]])
+        let client = WebSocketTransport(tlsConfiguration: .init(), dnsLookup: lookup)
+        let address = Multiaddr(uncheckedProtocols: [.dns("relay.example"), .tcp(port), .ws])
+
+        async let accepted = listener.accept()
+        let clientConn = try await client.dial(address)
+        let serverConn = try await accepted
+        #expect(clientConn.remoteAddress == address)
+
+        try await clientConn.write(ByteBuffer(bytes: Array("hello".utf8)))
+        #expect(Data(buffer: try await serverConn.read()) == Data("hello".utf8))
+
+        try await clientConn.close()
+        try await serverConn.close()
+        try await listener.close()
+    }
+
     @Test("WSS Multiaddr factory")
     func testWSSMultiaddrFactory() {
         let addr = Multiaddr.wss(host: "127.0.0.1", port: 443)
@@ -654,6 +736,7 @@ struct WebSocketTransportTests {
         await #expect(throws: Error.self) {
             _ = try await serverConn.read()
         }
+        #expect((serverConn as? WebSocketConnection)?.remoteCloseCode == 1000)
 
         try await serverConn.close()
         try await listener.close()
@@ -668,12 +751,14 @@ struct WebSocketTransportTests {
         let clientConn = try await transport.dial(listener.localAddress)
         let serverConn = try await acceptTask
 
+        #expect((clientConn as? WebSocketConnection)?.remoteCloseCode == nil)
         try await serverConn.close()
         try await Task.sleep(for: .milliseconds(100))
 
         await #expect(throws: Error.self) {
             _ = try await clientConn.read()
         }
+        #expect((clientConn as? WebSocketConnection)?.remoteCloseCode == 1000)
 
         try await clientConn.close()
         try await listener.close()
@@ -1228,3 +1313,31 @@ struct WebSocketTransportTests {
         }
     }
 }
+
+/// Answers A/AAAA lookups from fixed tables and records what was asked.
+private final class StubDNSLookup: DNSLookup, Sendable {
+    private let ipv4: [String: [String]]
+    private let ipv6: [String: [String]]
+    private let recorded = Mutex<[String]>([])
+
+    init(ipv4: [String: [String]] = [:], ipv6: [String: [String]] = [:]) {
+        self.ipv4 = ipv4
+        self.ipv6 = ipv6
+    }
+
+    /// `<hostname>/ipv4` or `<hostname>/ipv6` per lookup, in order.
+    var lookups: [String] { recorded.withLock { $0 } }
+
+    func addresses(of hostname: String, family: DNSAddressFamily) async throws -> DNSAnswer {
+        recorded.withLock { $0.append("\(hostname)/\(family == .ipv4 ? "ipv4" : "ipv6")") }
+        let table = family == .ipv4 ? ipv4 : ipv6
+        guard let values = table[hostname], !values.isEmpty else {
+            throw DNSResolverError.noAddressesFound(hostname: hostname, family: family == .ipv4 ? "IPv4" : "IPv6")
+        }
+        return DNSAnswer(values: values, ttl: .zero)
+    }
+
+    func textRecords(of name: String) async throws -> DNSAnswer {
+        DNSAnswer(values: [], ttl: .zero)
+    }
+}